@@ -26,6 +26,15 @@ func main() {
 
 	configureSlog()
 
+	if len(os.Args) > 1 && os.Args[1] == "rewrap-master-key" {
+		err := rewrapMasterKey(os.Args[2:])
+		if err != nil {
+			slog.Error(fmt.Sprintf("%+v", err))
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	slog.Info("application starting")
 
 	dir, err := os.Getwd()
@@ -75,12 +84,83 @@ func main() {
 	sqlStore.Cleanup(time.Minute * 10)
 	slog.Info("initialized session store")
 
+	err = lib.InitAuditSinks(database)
+	if err != nil {
+		slog.Error(fmt.Sprintf("%+v", err))
+		os.Exit(1)
+	}
+	startCleanups(database)
+
 	r := chi.NewRouter()
 	s := server.NewServer(r, database, sqlStore)
+	go s.RunKeyRotationScheduler(time.Hour)
+	go s.RunBackchannelLogoutWorker(time.Minute)
 
 	s.Start(settings)
 }
 
+// startCleanups starts deleting, every hour, the rows that are no longer needed
+func startCleanups(database data.Database) {
+	// the retention period is configured in the settings. A retention of 0 days keeps the events forever
+	go runPeriodicCleanup("old audit events", time.Hour, func() error {
+		settings, err := database.GetSettingsById(nil, 1)
+		if err != nil {
+			return err
+		}
+		if settings.AuditLogRetentionInDays == 0 {
+			return nil
+		}
+		return database.DeleteAuditEventsOlderThan(nil, time.Now().UTC().AddDate(0, 0, -settings.AuditLogRetentionInDays))
+	})
+
+	// the jtis are only kept to reject replayed assertions, request objects and DPoP proofs,
+	// and an expired one is rejected anyway
+	go runPeriodicCleanup("expired client assertion jtis", time.Hour, func() error {
+		return database.DeleteExpiredClientAssertionJtis(nil)
+	})
+	go runPeriodicCleanup("expired request object jtis", time.Hour, func() error {
+		return database.DeleteExpiredRequestObjectJtis(nil)
+	})
+	go runPeriodicCleanup("expired DPoP proof jtis", time.Hour, func() error {
+		return database.DeleteExpiredDPoPProofJtis(nil)
+	})
+
+	// pushed authorization requests that expired before the client used them
+	go runPeriodicCleanup("expired pushed authorization requests", time.Hour, func() error {
+		return database.DeleteExpiredPushedAuthorizationRequests(nil)
+	})
+
+	// device codes that expired, whether the user approved them or not
+	go runPeriodicCleanup("expired device codes", time.Hour, func() error {
+		return database.DeleteExpiredDeviceCodes(nil)
+	})
+
+	// only the last few minutes of invalid user codes are counted to limit guessing
+	go runPeriodicCleanup("old device user code attempts", time.Hour, func() error {
+		return database.DeleteDeviceUserCodeAttemptsOlderThan(nil, time.Now().UTC().Add(-time.Hour))
+	})
+
+	// back-channel logout deliveries are kept for a week, whatever their status
+	go runPeriodicCleanup("old backchannel logout deliveries", time.Hour, func() error {
+		return database.DeleteBackchannelLogoutDeliveriesOlderThan(nil, time.Now().UTC().AddDate(0, 0, -7))
+	})
+}
+
+// runPeriodicCleanup calls fn right away and then once every interval. The name says what
+// fn deletes, for the log
+func runPeriodicCleanup(name string, interval time.Duration, fn func() error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := fn()
+		if err != nil {
+			slog.Warn(fmt.Sprintf("unable to delete %v: %+v", name, err))
+		}
+		<-ticker.C
+	}
+}
+
 func configureSlog() {
 
 	w := os.Stderr
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+
+	"github.com/leodip/goiabada/internal/data"
+	"github.com/leodip/goiabada/internal/initialization"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/pkg/errors"
+)
+
+// rewrapMasterKey implements the rewrap-master-key command. The AES encryption key and the
+// session keys are unwrapped with the current master key (GOIABADA_MASTER_KEY_FILE) and wrapped
+// again with the new one. Everything else is encrypted with the AES encryption key, so it stays as it is
+func rewrapMasterKey(args []string) error {
+	flags := flag.NewFlagSet("rewrap-master-key", flag.ContinueOnError)
+	newMasterKeyFile := flags.String("new-master-key-file", "", "file with the new master key (32 random bytes, base64 encoded)")
+	unwrap := flags.Bool("unwrap", false, "store the AES encryption key and the session keys unwrapped, to stop using a master key")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if len(*newMasterKeyFile) == 0 && !*unwrap {
+		return errors.WithStack(errors.New("please specify the new master key file with -new-master-key-file, or use -unwrap"))
+	}
+	if len(*newMasterKeyFile) > 0 && *unwrap {
+		return errors.WithStack(errors.New("-new-master-key-file and -unwrap can't be used together"))
+	}
+
+	var newMasterKey []byte
+	if len(*newMasterKeyFile) > 0 {
+		newMasterKey, err = lib.ReadMasterKeyFile(*newMasterKeyFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	initialization.InitViper()
+
+	database, err := data.NewDatabase()
+	if err != nil {
+		return err
+	}
+
+	err = data.RewrapSettingsKeys(database, newMasterKey)
+	if err != nil {
+		return err
+	}
+
+	if newMasterKey == nil {
+		slog.Info("the AES encryption key and the session keys are now stored unwrapped. Unset GOIABADA_MASTER_KEY_FILE before starting the server")
+	} else {
+		slog.Info("the AES encryption key and the session keys were wrapped with the new master key. Point GOIABADA_MASTER_KEY_FILE to the new master key file before starting the server")
+	}
+	return nil
+}
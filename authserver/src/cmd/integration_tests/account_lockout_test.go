@@ -0,0 +1,217 @@
+package integrationtests
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leodip/goiabada/internal/constants"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func createLockoutTestUser(t *testing.T, password string) *entities.User {
+	passwordHash, err := lib.HashPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &entities.User{
+		Subject:      uuid.New(),
+		Enabled:      true,
+		Email:        "lockout-" + uuid.New().String()[:8] + "@example.com",
+		GivenName:    "Lockout",
+		FamilyName:   "Test",
+		PasswordHash: passwordHash,
+	}
+	err = database.CreateUser(nil, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func updateLockoutSettings(t *testing.T, threshold int, durationInSeconds int, permanentThreshold int,
+	delayInMilliseconds int) func() {
+
+	settings, err := database.GetSettingsById(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := *settings
+
+	settings.AccountLockoutThreshold = threshold
+	settings.AccountLockoutDurationInSeconds = durationInSeconds
+	settings.AccountPermanentLockoutThreshold = permanentThreshold
+	settings.FailedLoginDelayInMilliseconds = delayInMilliseconds
+	err = database.UpdateSettings(nil, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		err := database.UpdateSettings(nil, &original)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func startAuthorizeForLockoutTest(t *testing.T) (*http.Client, string) {
+	codeChallenge := "bQCdz4Hkhb3ctpajAwCCN899mNNfQGmRvMwruYT1Y9Y"
+	destUrl := lib.GetBaseUrl() +
+		"/auth/authorize/?client_id=test-client-1&redirect_uri=https://goiabada-test-client:8090/callback.html&response_type=code" +
+		"&code_challenge_method=S256&code_challenge=" + codeChallenge +
+		"&state=a1b2c3&response_mode=query&scope=openid" +
+		"&acr_values=" + enums.AcrLevel1.String()
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	resp, err := httpClient.Get(destUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+
+	// the auth context is now in the session; csrf protection is not enabled on this server
+	return httpClient, ""
+}
+
+func authenticateAndReadBody(t *testing.T, httpClient *http.Client, email string, password string, csrf string) (int, string) {
+	resp := authenticateWithPassword(t, httpClient, email, password, csrf)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func expireLockout(t *testing.T, userId int64) {
+	user, err := database.GetUserById(nil, userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().UTC().Add(-1 * time.Minute)
+	user.LockedUntil = sql.NullTime{Time: past, Valid: true}
+	user.LastFailedLoginAt = sql.NullTime{Time: past, Valid: true}
+	err = database.UpdateUser(nil, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAccountLockout_TemporaryAndPermanent(t *testing.T) {
+	setup()
+
+	restoreSettings := updateLockoutSettings(t, 3, 900, 5, 0)
+	defer restoreSettings()
+
+	user := createLockoutTestUser(t, "abc123")
+	httpClient, csrf := startAuthorizeForLockoutTest(t)
+
+	for i := 0; i < 2; i++ {
+		statusCode, body := authenticateAndReadBody(t, httpClient, user.Email, "wrong-password", csrf)
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.Contains(t, body, "Authentication failed.")
+	}
+
+	// third failure reaches the threshold. The message doesn't reveal the lock
+	_, body := authenticateAndReadBody(t, httpClient, user.Email, "wrong-password", csrf)
+	assert.Contains(t, body, "Authentication failed.")
+	assert.NotContains(t, body, "locked")
+
+	user, err := database.GetUserById(nil, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, user.FailedLoginAttempts)
+	assert.True(t, user.LockedUntil.Valid)
+	assertTimeWithinRange(t, time.Now().UTC().Add(900*time.Second), user.LockedUntil.Time, 10)
+	assert.False(t, user.LockedPermanently)
+
+	// the correct password is rejected while locked
+	_, body = authenticateAndReadBody(t, httpClient, user.Email, "abc123", csrf)
+	assert.Contains(t, body, "Authentication failed.")
+
+	// audit events are written to the database asynchronously
+	assert.Eventually(t, func() bool {
+		auditEvents, _, err := database.SearchAuditEventsPaginated(nil, constants.AuditAccountLocked, user.Id, 0,
+			time.Time{}, time.Time{}, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(auditEvents) == 1
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// after the lock expires, another failure locks the account again
+	expireLockout(t, user.Id)
+	authenticateAndReadBody(t, httpClient, user.Email, "wrong-password", csrf)
+	user, err = database.GetUserById(nil, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, user.IsLocked(time.Now().UTC()))
+
+	// reaching the permanent threshold
+	expireLockout(t, user.Id)
+	authenticateAndReadBody(t, httpClient, user.Email, "wrong-password", csrf)
+
+	user, err = database.GetUserById(nil, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 5, user.FailedLoginAttempts)
+	assert.True(t, user.LockedPermanently)
+
+	expireLockout(t, user.Id)
+	resp := authenticateWithPassword(t, httpClient, user.Email, "abc123", csrf)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestAccountLockout_ProgressiveDelay(t *testing.T) {
+	setup()
+
+	restoreSettings := updateLockoutSettings(t, 0, 900, 0, 10000)
+	defer restoreSettings()
+
+	user := createLockoutTestUser(t, "abc123")
+	httpClient, csrf := startAuthorizeForLockoutTest(t)
+
+	_, body := authenticateAndReadBody(t, httpClient, user.Email, "wrong-password", csrf)
+	assert.Contains(t, body, "Authentication failed.")
+
+	// the next attempt comes too soon, even with the correct password
+	_, body = authenticateAndReadBody(t, httpClient, user.Email, "abc123", csrf)
+	assert.Contains(t, body, "Authentication failed.")
+
+	user, err := database.GetUserById(nil, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, user.FailedLoginAttempts)
+	assert.False(t, user.LockedUntil.Valid)
+
+	// once the delay has elapsed the user can authenticate, and the counter is reset
+	expireLockout(t, user.Id)
+	resp := authenticateWithPassword(t, httpClient, user.Email, "abc123", csrf)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+
+	user, err = database.GetUserById(nil, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, user.FailedLoginAttempts)
+	assert.False(t, user.LastFailedLoginAt.Valid)
+	assert.False(t, user.LockedUntil.Valid)
+}
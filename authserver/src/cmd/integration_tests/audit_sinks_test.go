@@ -0,0 +1,327 @@
+package integrationtests
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAuditEvent(event string) *lib.AuditEvent {
+	return &lib.AuditEvent{
+		Timestamp: time.Now().UTC(),
+		Event:     event,
+		Actor:     "f1b2c3d4",
+		IpAddress: "127.0.0.1",
+		RequestId: "req-1",
+		UserAgent: "integration-tests",
+		Details: map[string]interface{}{
+			"userId":   1,
+			"clientId": 2,
+		},
+	}
+}
+
+func TestAuditSink_Webhook_RetriesUntilSuccess(t *testing.T) {
+	var attempts int32
+	received := make(chan lib.AuditEvent, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer abc", r.Header.Get("Authorization"))
+		body, _ := io.ReadAll(r.Body)
+		var auditEvent lib.AuditEvent
+		json.Unmarshal(body, &auditEvent)
+		received <- auditEvent
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink, err := lib.NewAuditWebhookSink(server.URL, "Bearer abc", time.Second, 3, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the retries happen in the background
+	err = sink.Write(newTestAuditEvent("webhook_retry_test"))
+	assert.Nil(t, err)
+
+	var receivedEvent lib.AuditEvent
+	select {
+	case receivedEvent = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the audit event was not delivered")
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+	assert.Equal(t, "webhook_retry_test", receivedEvent.Event)
+	assert.Equal(t, "req-1", receivedEvent.RequestId)
+	assert.Equal(t, float64(2), receivedEvent.Details["clientId"])
+}
+
+func TestAuditSink_Webhook_GivesUpAfterMaxRetries(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	sink, err := lib.NewAuditWebhookSink(server.URL, "", time.Second, 2, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	droppedBefore := lib.GetAuditDroppedEvents()["webhook"]
+
+	err = sink.Write(newTestAuditEvent("webhook_give_up_test"))
+	assert.Nil(t, err)
+
+	assert.Eventually(t, func() bool {
+		return lib.GetAuditDroppedEvents()["webhook"] == droppedBefore+1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+}
+
+func TestAuditSink_Webhook_RetriesDontBlockOtherEvents(t *testing.T) {
+	var failingAttempts int32
+	var delivered int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "webhook_failing_test") {
+			atomic.AddInt32(&failingAttempts, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		atomic.AddInt32(&delivered, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	webhookSink, err := lib.NewAuditWebhookSink(server.URL, "", time.Second, 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := lib.NewAsyncAuditSink(webhookSink, 10)
+
+	err = sink.Write(newTestAuditEvent("webhook_failing_test"))
+	assert.Nil(t, err)
+	err = sink.Write(newTestAuditEvent("webhook_ok_test"))
+	assert.Nil(t, err)
+
+	// the second event is delivered while the first one waits an hour for its retry
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&delivered) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&failingAttempts))
+}
+
+func TestAuditSink_Webhook_ClientErrorIsNotRetried(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	sink, err := lib.NewAuditWebhookSink(server.URL, "", time.Second, 5, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sink.Write(newTestAuditEvent("webhook_client_error_test"))
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+func TestAuditSink_Async_DropsEventsWhenQueueIsFull(t *testing.T) {
+	release := make(chan struct{})
+	var received int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		atomic.AddInt32(&received, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	webhookSink, err := lib.NewAuditWebhookSink(server.URL, "", 5*time.Second, 0, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := lib.NewAsyncAuditSink(webhookSink, 1)
+
+	droppedBefore := lib.GetAuditDroppedEvents()["webhook"]
+
+	// the first event is taken by the worker (blocked on the server), the second one
+	// fills the queue and the remaining ones are dropped
+	failed := 0
+	for i := 0; i < 5; i++ {
+		err = sink.Write(newTestAuditEvent(fmt.Sprintf("async_test_%v", i)))
+		if err != nil {
+			failed++
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	close(release)
+
+	assert.Equal(t, 3, failed)
+	assert.Equal(t, droppedBefore+3, lib.GetAuditDroppedEvents()["webhook"])
+
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&received) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestAuditSink_File_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit", "audit.log")
+
+	sink, err := lib.NewAuditFileSink(path, 600, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	for i := 0; i < 20; i++ {
+		err = sink.Write(newTestAuditEvent(fmt.Sprintf("file_test_%v", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assert.FileExists(t, path)
+	assert.FileExists(t, path+".1")
+	assert.FileExists(t, path+".2")
+	assert.NoFileExists(t, path+".3")
+
+	for _, fileName := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.LessOrEqual(t, info.Size(), int64(600))
+
+		file, err := os.Open(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner := bufio.NewScanner(file)
+		lines := 0
+		for scanner.Scan() {
+			var auditEvent lib.AuditEvent
+			err = json.Unmarshal(scanner.Bytes(), &auditEvent)
+			assert.Nil(t, err)
+			assert.True(t, strings.HasPrefix(auditEvent.Event, "file_test_"))
+			lines++
+		}
+		file.Close()
+		assert.Greater(t, lines, 0)
+	}
+
+	// the most recent event is in the current file
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(content), "file_test_19")
+}
+
+func TestAuditSink_Syslog_UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sink, err := lib.NewAuditSyslogSink("udp", conn.LocalAddr().String(), "goiabada")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sink.Write(newTestAuditEvent("syslog_udp_test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 8192)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(buf[:n])
+
+	assert.True(t, strings.HasPrefix(msg, "<86>1 "))
+	parts := strings.SplitN(msg, " ", 8)
+	assert.Len(t, parts, 8)
+	assert.Equal(t, "goiabada", parts[3])
+	assert.Equal(t, strconv.Itoa(os.Getpid()), parts[4])
+	assert.Equal(t, "syslog_udp_test", parts[5])
+	assert.Equal(t, "-", parts[6])
+
+	var auditEvent lib.AuditEvent
+	err = json.Unmarshal([]byte(parts[7]), &auditEvent)
+	assert.Nil(t, err)
+	assert.Equal(t, "syslog_udp_test", auditEvent.Event)
+}
+
+func TestAuditSink_Syslog_TCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		length, err := reader.ReadString(' ')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(length))
+		msg := make([]byte, n)
+		io.ReadFull(reader, msg)
+		received <- string(msg)
+	}()
+
+	sink, err := lib.NewAuditSyslogSink("tcp", listener.Addr().String(), "goiabada")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sink.Write(newTestAuditEvent("syslog_tcp_test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-received:
+		assert.True(t, strings.HasPrefix(msg, "<86>1 "))
+		assert.Contains(t, msg, " syslog_tcp_test - ")
+		assert.True(t, strings.HasSuffix(msg, "}"))
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the syslog message")
+	}
+}
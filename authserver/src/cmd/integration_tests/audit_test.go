@@ -0,0 +1,67 @@
+package integrationtests
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/leodip/goiabada/internal/constants"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAudit_EventIsPersisted(t *testing.T) {
+	setup()
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	client, err := database.GetClientByClientIdentifier(nil, "test-client-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	formData := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"test-client-1"},
+		"client_secret": {getClientSecret(t, "test-client-1")},
+		"scope":         {"backend-svcA:create-product"},
+	}
+	respData := postToTokenEndpoint(t, httpClient, lib.GetBaseUrl()+"/auth/token", formData)
+	assert.NotEmpty(t, respData["access_token"])
+
+	// the database sink is asynchronous
+	var auditEvents []entities.AuditEvent
+	assert.Eventually(t, func() bool {
+		var err error
+		auditEvents, _, err = database.SearchAuditEventsPaginated(nil, constants.AuditTokenIssuedClientCredentialsResponse,
+			0, client.Id, time.Now().UTC().Add(-1*time.Minute), time.Time{}, 1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(auditEvents) == 1
+	}, 5*time.Second, 50*time.Millisecond)
+	if len(auditEvents) == 0 {
+		t.FailNow()
+	}
+
+	auditEvent := auditEvents[0]
+	assert.Equal(t, constants.AuditTokenIssuedClientCredentialsResponse, auditEvent.Event)
+	assert.True(t, auditEvent.ClientId.Valid)
+	assert.Equal(t, client.Id, auditEvent.ClientId.Int64)
+	assert.False(t, auditEvent.UserId.Valid)
+	assert.NotEmpty(t, auditEvent.IpAddress)
+	assert.NotEmpty(t, auditEvent.RequestId)
+	assert.NotEmpty(t, auditEvent.UserAgent)
+	assertTimeWithinRange(t, time.Now().UTC(), auditEvent.CreatedAt.Time, 10)
+
+	var details map[string]interface{}
+	err = json.Unmarshal([]byte(auditEvent.Details), &details)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, float64(client.Id), details["clientId"])
+}
@@ -0,0 +1,220 @@
+package integrationtests
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	core_token "github.com/leodip/goiabada/internal/core/token"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+// setBackchannelLogoutURI sets the backchannel_logout_uri of test-client-2, and returns
+// a function that puts the client back the way it was
+func setBackchannelLogoutURI(t *testing.T, backchannelLogoutURI string) func() {
+	client, err := database.GetClientByClientIdentifier(nil, "test-client-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := *client
+
+	client.BackchannelLogoutURI = backchannelLogoutURI
+	err = database.UpdateClient(nil, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		err := database.UpdateClient(nil, &original)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func postLogout(t *testing.T, httpClient *http.Client) {
+	formData := url.Values{
+		"gorilla.csrf.Token": {""},
+	}
+	request, err := http.NewRequest("POST", lib.GetBaseUrl()+"/auth/logout", strings.NewReader(formData.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+}
+
+// waitForBackchannelLogoutDelivery waits until the background worker has processed the
+// delivery for the user, and returns it
+func waitForBackchannelLogoutDelivery(t *testing.T, user *entities.User) *entities.BackchannelLogoutDelivery {
+	client, err := database.GetClientByClientIdentifier(nil, "test-client-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 50; i++ {
+		deliveries, err := database.GetBackchannelLogoutDeliveriesByClientId(nil, client.Id, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for idx, delivery := range deliveries {
+			if delivery.Subject == user.Subject.String() && delivery.Attempts > 0 {
+				return &deliveries[idx]
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatal("the backchannel logout delivery was not processed")
+	return nil
+}
+
+func TestBackchannelLogout_Delivered(t *testing.T) {
+	setup()
+
+	var mu sync.Mutex
+	logoutTokens := []string{}
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, "application/x-www-form-urlencoded", r.Header.Get("Content-Type"))
+		logoutTokens = append(logoutTokens, r.FormValue("logout_token"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer stub.Close()
+	defer setBackchannelLogoutURI(t, stub.URL+"/backchannel-logout")()
+
+	user := createLockoutTestUser(t, "abc123")
+	httpClient, idToken := loginForPromptTest(t, user.Email, "abc123")
+
+	postLogout(t, httpClient)
+
+	delivery := waitForBackchannelLogoutDelivery(t, user)
+	assert.Equal(t, enums.BackchannelLogoutStatusDelivered.String(), delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.True(t, delivery.DeliveredAt.Valid)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !assert.Len(t, logoutTokens, 1) {
+		return
+	}
+
+	ctx := getSettingsContext(t)
+	tokenParser := core_token.NewTokenParser(database)
+	logoutToken, err := tokenParser.ParseToken(ctx, logoutTokens[0], true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedIdToken, err := tokenParser.ParseToken(ctx, idToken, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the session ended, so its refresh tokens can't be used anymore
+	userSession, err := database.GetUserSessionBySessionIdentifier(nil, parsedIdToken.GetStringClaim("sid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, userSession)
+
+	assert.Equal(t, user.Subject.String(), logoutToken.GetStringClaim("sub"))
+	assert.Equal(t, parsedIdToken.GetStringClaim("sid"), logoutToken.GetStringClaim("sid"))
+	assert.Equal(t, []string{"test-client-2"}, logoutToken.GetAudience())
+	assert.NotEmpty(t, logoutToken.GetStringClaim("jti"))
+	assert.Nil(t, logoutToken.Claims["nonce"])
+	events, _ := logoutToken.Claims["events"].(map[string]interface{})
+	assert.Contains(t, events, "http://schemas.openid.net/event/backchannel-logout")
+
+	// like the id token, the logout token is signed with the current key
+	currentKey, err := database.GetCurrentSigningKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unverified, _, err := jwt.NewParser().ParseUnverified(logoutTokens[0], jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, currentKey.KeyIdentifier, unverified.Header["kid"])
+}
+
+func TestBackchannelLogout_DeliveryIsClaimedOnce(t *testing.T) {
+	setup()
+
+	client, err := database.GetClientByClientIdentifier(nil, "test-client-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// not due yet, so the background worker leaves it alone
+	now := time.Now().UTC()
+	delivery := &entities.BackchannelLogoutDelivery{
+		ClientId:          client.Id,
+		Subject:           uuid.New().String(),
+		SessionIdentifier: uuid.New().String(),
+		Status:            enums.BackchannelLogoutStatusPending.String(),
+		NextAttemptAt:     sql.NullTime{Time: now.Add(time.Hour), Valid: true},
+	}
+	err = database.CreateBackchannelLogoutDelivery(nil, delivery)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// two instances that see the delivery as due: only the first one gets it
+	due := now.Add(2 * time.Hour)
+	claimed, err := database.ClaimBackchannelLogoutDelivery(nil, delivery.Id, due, due.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, claimed)
+
+	claimed, err = database.ClaimBackchannelLogoutDelivery(nil, delivery.Id, due, due.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, claimed)
+
+	delivery.Status = enums.BackchannelLogoutStatusFailed.String()
+	err = database.UpdateBackchannelLogoutDelivery(nil, delivery)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackchannelLogout_Retried(t *testing.T) {
+	setup()
+
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer stub.Close()
+	defer setBackchannelLogoutURI(t, stub.URL+"/backchannel-logout")()
+
+	user := createLockoutTestUser(t, "abc123")
+	httpClient, _ := loginForPromptTest(t, user.Email, "abc123")
+
+	postLogout(t, httpClient)
+
+	// the delivery failed, and is scheduled to be retried later
+	delivery := waitForBackchannelLogoutDelivery(t, user)
+	assert.Equal(t, enums.BackchannelLogoutStatusPending.String(), delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Contains(t, delivery.LastError, "500")
+	assert.True(t, delivery.NextAttemptAt.Time.After(time.Now().UTC()))
+	assert.False(t, delivery.DeliveredAt.Valid)
+}
@@ -0,0 +1,368 @@
+package integrationtests
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	core_validators "github.com/leodip/goiabada/internal/core/validators"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func postWithBasicAuth(t *testing.T, destUrl string, clientId string, clientSecret string, formData url.Values) *http.Response {
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	request, err := http.NewRequest("POST", destUrl, strings.NewReader(formData.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.SetBasicAuth(url.QueryEscape(clientId), url.QueryEscape(clientSecret))
+
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// usePrivateKeyJwtAuth configures the client to authenticate with private_key_jwt, and
+// returns a function that puts the client back the way it was
+func usePrivateKeyJwtAuth(t *testing.T, clientIdentifier string, jwks string, jwksURI string) func() {
+	client, err := database.GetClientByClientIdentifier(nil, clientIdentifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := *client
+
+	client.TokenEndpointAuthMethod = enums.TokenEndpointAuthMethodPrivateKeyJwt.String()
+	client.JWKS = jwks
+	client.JWKSURI = jwksURI
+	err = database.UpdateClient(nil, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		err := database.UpdateClient(nil, &original)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func createClientAssertion(t *testing.T, method jwt.SigningMethod, privateKey crypto.PrivateKey, kid string,
+	clientIdentifier string, audience string) string {
+	now := time.Now().UTC()
+	token := jwt.NewWithClaims(method, jwt.RegisteredClaims{
+		Issuer:    clientIdentifier,
+		Subject:   clientIdentifier,
+		Audience:  jwt.ClaimStrings{audience},
+		ID:        uuid.New().String(),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+	})
+	token.Header["kid"] = kid
+
+	assertion, err := token.SignedString(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return assertion
+}
+
+func TestClientAuthentication_ClientSecretBasic(t *testing.T) {
+	setup()
+
+	formData := url.Values{
+		"grant_type": {"client_credentials"},
+	}
+	resp := postWithBasicAuth(t, lib.GetBaseUrl()+"/auth/token", "test-client-1", getClientSecret(t, "test-client-1"), formData)
+	defer resp.Body.Close()
+
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotEmpty(t, data["access_token"])
+}
+
+func TestClientAuthentication_ClientSecretBasicFailed(t *testing.T) {
+	setup()
+
+	formData := url.Values{
+		"grant_type": {"client_credentials"},
+	}
+	resp := postWithBasicAuth(t, lib.GetBaseUrl()+"/auth/token", "test-client-1", "invalid", formData)
+	defer resp.Body.Close()
+
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("WWW-Authenticate"))
+	assert.Equal(t, "invalid_client", data["error"])
+	assert.Equal(t, "Client authentication failed.", data["error_description"])
+}
+
+func TestClientAuthentication_MoreThanOneMethod(t *testing.T) {
+	setup()
+
+	clientSecret := getClientSecret(t, "test-client-1")
+	formData := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_secret": {clientSecret},
+	}
+	resp := postWithBasicAuth(t, lib.GetBaseUrl()+"/auth/token", "test-client-1", clientSecret, formData)
+	defer resp.Body.Close()
+
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "invalid_request", data["error"])
+}
+
+func TestClientAuthentication_PrivateKeyJwt(t *testing.T) {
+	setup()
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid := uuid.New().String()
+	jwk, err := lib.MarshalECPublicKeyToJWK(&privateKey.PublicKey, "ES256", kid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer usePrivateKeyJwtAuth(t, "test-client-1", fmt.Sprintf(`{"keys": [%s]}`, jwk), "")()
+
+	destUrl := lib.GetBaseUrl() + "/auth/token"
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	// the client secret is no longer accepted
+	formData := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"test-client-1"},
+		"client_secret": {getClientSecret(t, "test-client-1")},
+	}
+	data := postToTokenEndpoint(t, httpClient, destUrl, formData)
+	assert.Equal(t, "invalid_request", data["error"])
+
+	// client_id is optional, it's taken from the assertion
+	assertion := createClientAssertion(t, jwt.SigningMethodES256, privateKey, kid, "test-client-1", destUrl)
+	formData = url.Values{
+		"grant_type":            {"client_credentials"},
+		"client_assertion_type": {core_validators.ClientAssertionTypeJwtBearer},
+		"client_assertion":      {assertion},
+	}
+	data = postToTokenEndpoint(t, httpClient, destUrl, formData)
+	assert.NotEmpty(t, data["access_token"])
+
+	// the same assertion can't be used twice
+	data = postToTokenEndpoint(t, httpClient, destUrl, formData)
+	assert.Equal(t, "invalid_client", data["error"])
+	assert.Contains(t, data["error_description"], "the assertion has already been used")
+
+	// assertions addressed to another server are rejected
+	assertion = createClientAssertion(t, jwt.SigningMethodES256, privateKey, kid, "test-client-1", "https://example.com")
+	formData.Set("client_assertion", assertion)
+	data = postToTokenEndpoint(t, httpClient, destUrl, formData)
+	assert.Equal(t, "invalid_client", data["error"])
+
+	// assertions signed with an unknown key are rejected
+	otherPrivateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertion = createClientAssertion(t, jwt.SigningMethodES256, otherPrivateKey, kid, "test-client-1", destUrl)
+	formData.Set("client_assertion", assertion)
+	data = postToTokenEndpoint(t, httpClient, destUrl, formData)
+	assert.Equal(t, "invalid_client", data["error"])
+}
+
+func TestClientAuthentication_PrivateKeyJwtWithJwksUri(t *testing.T) {
+	setup()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid := uuid.New().String()
+	jwk, err := lib.MarshalEdDSAPublicKeyToJWK(publicKey, kid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"keys": [%s]}`, jwk)
+	}))
+	defer jwksServer.Close()
+	defer usePrivateKeyJwtAuth(t, "test-client-1", "", jwksServer.URL)()
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	settings, err := database.GetSettingsById(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	formData := url.Values{
+		"client_id":             {"test-client-1"},
+		"client_assertion_type": {core_validators.ClientAssertionTypeJwtBearer},
+		"client_assertion":      {createClientAssertion(t, jwt.SigningMethodEdDSA, privateKey, kid, "test-client-1", settings.Issuer)},
+		"token":                 {"abc"},
+	}
+	resp := postToIntrospectionEndpoint(t, httpClient, formData)
+	defer resp.Body.Close()
+
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, false, data["active"])
+}
+
+func TestClientAuthentication_JwksUriIsFetchedOnce(t *testing.T) {
+	setup()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid := uuid.New().String()
+	jwk, err := lib.MarshalEdDSAPublicKeyToJWK(publicKey, kid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fetches atomic.Int32
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		time.Sleep(500 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"keys": [%s]}`, jwk)
+	}))
+	defer jwksServer.Close()
+	defer usePrivateKeyJwtAuth(t, "test-client-1", "", jwksServer.URL)()
+
+	settings, err := database.GetSettingsById(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	introspect := func(kid string) int {
+		httpClient := createHttpClient(&createHttpClientInput{
+			T: t,
+		})
+		formData := url.Values{
+			"client_id":             {"test-client-1"},
+			"client_assertion_type": {core_validators.ClientAssertionTypeJwtBearer},
+			"client_assertion":      {createClientAssertion(t, jwt.SigningMethodEdDSA, privateKey, kid, "test-client-1", settings.Issuer)},
+			"token":                 {"abc"},
+		}
+		resp := postToIntrospectionEndpoint(t, httpClient, formData)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// concurrent requests wait for the same fetch
+	var wg sync.WaitGroup
+	statusCodes := make([]int, 5)
+	for i := range statusCodes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			statusCodes[i] = introspect(kid)
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, []int{200, 200, 200, 200, 200}, statusCodes)
+	assert.Equal(t, int32(1), fetches.Load())
+
+	// an unknown kid doesn't fetch the keys again right after they were fetched
+	for i := 0; i < 3; i++ {
+		assert.NotEqual(t, http.StatusOK, introspect(uuid.New().String()))
+	}
+	assert.Equal(t, int32(1), fetches.Load())
+}
+
+func TestClientAuthentication_SmallRSAKeyIsRejected(t *testing.T) {
+	setup()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid := uuid.New().String()
+	jwk, err := lib.MarshalRSAPublicKeyToJWK(&privateKey.PublicKey, kid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer usePrivateKeyJwtAuth(t, "test-client-1", fmt.Sprintf(`{"keys": [%s]}`, jwk), "")()
+
+	destUrl := lib.GetBaseUrl() + "/auth/token"
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+	formData := url.Values{
+		"grant_type":            {"client_credentials"},
+		"client_assertion_type": {core_validators.ClientAssertionTypeJwtBearer},
+		"client_assertion":      {createClientAssertion(t, jwt.SigningMethodRS256, privateKey, kid, "test-client-1", destUrl)},
+	}
+	data := postToTokenEndpoint(t, httpClient, destUrl, formData)
+	assert.Equal(t, "invalid_client", data["error"])
+	assert.Contains(t, data["error_description"], "must be at least 2048 bits")
+}
+
+func TestClientAuthentication_JtiIsRemembered(t *testing.T) {
+	setup()
+
+	client, err := database.GetClientByClientIdentifier(nil, "test-client-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jti := uuid.New().String()
+	err = database.CreateClientAssertionJti(nil, &entities.ClientAssertionJti{
+		ClientId:  client.Id,
+		Jti:       jti,
+		ExpiresAt: sql.NullTime{Time: time.Now().UTC().Add(-time.Minute), Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientAssertionJti, err := database.GetClientAssertionJti(nil, client.Id, jti)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotNil(t, clientAssertionJti)
+
+	// expired jtis are deleted
+	err = database.DeleteExpiredClientAssertionJtis(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientAssertionJti, err = database.GetClientAssertionJti(nil, client.Id, jti)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, clientAssertionJti)
+}
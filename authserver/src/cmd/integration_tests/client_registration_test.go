@@ -0,0 +1,311 @@
+package integrationtests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+// setClientRegistrationMode changes the client registration settings, and returns a function
+// that puts them back the way they were
+func setClientRegistrationMode(t *testing.T, mode enums.ClientRegistrationMode, initialAccessToken string) func() {
+	settings, err := database.GetSettingsById(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := *settings
+
+	settings.ClientRegistrationMode = mode.String()
+	settings.ClientRegistrationInitialAccessTokenEncrypted = nil
+	if len(initialAccessToken) > 0 {
+		settings.ClientRegistrationInitialAccessTokenEncrypted, err = lib.EncryptText(initialAccessToken, settings.AESEncryptionKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = database.UpdateSettings(nil, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		err := database.UpdateSettings(nil, &original)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func sendClientRegistrationRequest(t *testing.T, method string, url string, token string, body interface{}) *http.Response {
+	var bodyReader *bytes.Reader
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bodyReader = bytes.NewReader(bodyBytes)
+	} else {
+		bodyReader = bytes.NewReader([]byte{})
+	}
+
+	request, err := http.NewRequest(method, url, bodyReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if len(token) > 0 {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestClientRegistration_Disabled(t *testing.T) {
+	setup()
+	defer setClientRegistrationMode(t, enums.ClientRegistrationModeDisabled, "")()
+
+	resp := sendClientRegistrationRequest(t, "POST", lib.GetBaseUrl()+"/connect/register", "", map[string]interface{}{
+		"redirect_uris": []string{"https://app.example.com/callback"},
+	})
+	defer resp.Body.Close()
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, "access_denied", data["error"])
+}
+
+func TestClientRegistration_RegisterReadUpdateDelete(t *testing.T) {
+	setup()
+	defer setClientRegistrationMode(t, enums.ClientRegistrationModeOpen, "")()
+
+	resp := sendClientRegistrationRequest(t, "POST", lib.GetBaseUrl()+"/connect/register", "", map[string]interface{}{
+		"client_name":                "Ephemeral environment",
+		"redirect_uris":              []string{"https://app.example.com/callback"},
+		"web_origins":                []string{"https://app.example.com"},
+		"grant_types":                []string{"authorization_code", "client_credentials"},
+		"token_endpoint_auth_method": "client_secret_post",
+	})
+	defer resp.Body.Close()
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	clientIdentifier, _ := data["client_id"].(string)
+	registrationAccessToken, _ := data["registration_access_token"].(string)
+	assert.NotEmpty(t, clientIdentifier)
+	assert.NotEmpty(t, registrationAccessToken)
+	assert.Len(t, data["client_secret"], 60)
+	assert.Equal(t, float64(0), data["client_secret_expires_at"])
+	assert.Equal(t, lib.GetBaseUrl()+"/connect/register/"+clientIdentifier, data["registration_client_uri"])
+	assert.Equal(t, "client_secret_post", data["token_endpoint_auth_method"])
+	assert.Equal(t, []interface{}{"authorization_code", "client_credentials", "refresh_token"}, data["grant_types"])
+	assert.Equal(t, []interface{}{"code"}, data["response_types"])
+
+	client, err := database.GetClientByClientIdentifier(nil, clientIdentifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Ephemeral environment", client.Description)
+	assert.True(t, client.AuthorizationCodeEnabled)
+	assert.True(t, client.ClientCredentialsEnabled)
+	assert.False(t, client.IsPublic)
+	err = database.ClientLoadWebOrigins(nil, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, client.WebOrigins, 1)
+	assert.Equal(t, "https://app.example.com", client.WebOrigins[0].Origin)
+
+	registrationClientURI := data["registration_client_uri"].(string)
+
+	// read
+	resp = sendClientRegistrationRequest(t, "GET", registrationClientURI, registrationAccessToken, nil)
+	defer resp.Body.Close()
+	readData := unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, clientIdentifier, readData["client_id"])
+	assert.Equal(t, data["client_secret"], readData["client_secret"])
+	assert.Equal(t, []interface{}{"https://app.example.com/callback"}, readData["redirect_uris"])
+	assert.Nil(t, readData["registration_access_token"])
+
+	resp = sendClientRegistrationRequest(t, "GET", registrationClientURI, "invalid", nil)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, `Bearer error="invalid_token"`, resp.Header.Get("WWW-Authenticate"))
+
+	// the registration access token only works for its own client
+	resp = sendClientRegistrationRequest(t, "GET", lib.GetBaseUrl()+"/connect/register/test-client-1", registrationAccessToken, nil)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// update, turning it into a public client
+	resp = sendClientRegistrationRequest(t, "PUT", registrationClientURI, registrationAccessToken, map[string]interface{}{
+		"client_id":                  clientIdentifier,
+		"redirect_uris":              []string{"https://app.example.com/callback2", "https://app.example.com/callback3"},
+		"post_logout_redirect_uris":  []string{"https://app.example.com/logged-out"},
+		"token_endpoint_auth_method": "none",
+	})
+	defer resp.Body.Close()
+	updateData := unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "none", updateData["token_endpoint_auth_method"])
+	assert.Nil(t, updateData["client_secret"])
+	assert.Equal(t, []interface{}{"https://app.example.com/callback2", "https://app.example.com/callback3"}, updateData["redirect_uris"])
+	assert.Equal(t, []interface{}{"https://app.example.com/logged-out"}, updateData["post_logout_redirect_uris"])
+	assert.Equal(t, []interface{}{}, updateData["web_origins"])
+	assert.Equal(t, []interface{}{"authorization_code", "refresh_token"}, updateData["grant_types"])
+
+	client, err = database.GetClientByClientIdentifier(nil, clientIdentifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, client.IsPublic)
+	assert.False(t, client.ClientCredentialsEnabled)
+
+	resp = sendClientRegistrationRequest(t, "PUT", registrationClientURI, registrationAccessToken, map[string]interface{}{
+		"client_id":     "another-client",
+		"redirect_uris": []string{"https://app.example.com/callback"},
+	})
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// delete
+	resp = sendClientRegistrationRequest(t, "DELETE", registrationClientURI, registrationAccessToken, nil)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	client, err = database.GetClientByClientIdentifier(nil, clientIdentifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, client)
+
+	resp = sendClientRegistrationRequest(t, "GET", registrationClientURI, registrationAccessToken, nil)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestClientRegistration_InitialAccessToken(t *testing.T) {
+	setup()
+	initialAccessToken := lib.GenerateSecureRandomString(60)
+	defer setClientRegistrationMode(t, enums.ClientRegistrationModeInitialAccessToken, initialAccessToken)()
+
+	metadata := map[string]interface{}{
+		"redirect_uris": []string{"https://app.example.com/callback"},
+	}
+
+	resp := sendClientRegistrationRequest(t, "POST", lib.GetBaseUrl()+"/connect/register", "", metadata)
+	defer resp.Body.Close()
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "invalid_token", data["error"])
+
+	resp = sendClientRegistrationRequest(t, "POST", lib.GetBaseUrl()+"/connect/register", "invalid", metadata)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = sendClientRegistrationRequest(t, "POST", lib.GetBaseUrl()+"/connect/register", initialAccessToken, metadata)
+	defer resp.Body.Close()
+	data = unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "client_secret_basic", data["token_endpoint_auth_method"])
+
+	clientIdentifier, _ := data["client_id"].(string)
+	resp = sendClientRegistrationRequest(t, "DELETE", lib.GetBaseUrl()+"/connect/register/"+clientIdentifier,
+		data["registration_access_token"].(string), nil)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestClientRegistration_InvalidMetadata(t *testing.T) {
+	setup()
+	defer setClientRegistrationMode(t, enums.ClientRegistrationModeOpen, "")()
+
+	testCases := []struct {
+		metadata      map[string]interface{}
+		expectedError string
+	}{
+		{map[string]interface{}{}, "invalid_redirect_uri"},
+		{map[string]interface{}{"redirect_uris": []string{"/callback"}}, "invalid_redirect_uri"},
+		{map[string]interface{}{"redirect_uris": []string{"https://app.example.com/callback#fragment"}}, "invalid_redirect_uri"},
+		{map[string]interface{}{"redirect_uris": []string{"https://app.example.com/callback"}, "grant_types": []string{"implicit"}}, "invalid_client_metadata"},
+		{map[string]interface{}{"redirect_uris": []string{"https://app.example.com/callback"}, "response_types": []string{"token"}}, "invalid_client_metadata"},
+		{map[string]interface{}{"redirect_uris": []string{"https://app.example.com/callback"}, "token_endpoint_auth_method": "invalid"}, "invalid_client_metadata"},
+		{map[string]interface{}{"redirect_uris": []string{"https://app.example.com/callback"}, "web_origins": []string{"https://app.example.com/path"}}, "invalid_client_metadata"},
+		{map[string]interface{}{"redirect_uris": []string{"https://app.example.com/callback"}, "token_endpoint_auth_method": "private_key_jwt"}, "invalid_client_metadata"},
+		{map[string]interface{}{"grant_types": []string{"client_credentials"}, "token_endpoint_auth_method": "none"}, "invalid_client_metadata"},
+		{map[string]interface{}{"redirect_uris": []string{"http://app.example.com/callback"}}, "invalid_redirect_uri"},
+		{map[string]interface{}{"redirect_uris": []string{"javascript://app.example.com/%0aalert(1)"}}, "invalid_redirect_uri"},
+		{map[string]interface{}{"redirect_uris": []string{"com.example.app://callback"}}, "invalid_redirect_uri"},
+		{map[string]interface{}{"redirect_uris": []string{"https://app.example.com/callback"}, "post_logout_redirect_uris": []string{"javascript://app.example.com/%0aalert(1)"}}, "invalid_client_metadata"},
+		{map[string]interface{}{"redirect_uris": []string{"https://app.example.com/callback"}, "post_logout_redirect_uris": []string{"data://app.example.com/text/html,x"}}, "invalid_client_metadata"},
+		{map[string]interface{}{"redirect_uris": []string{"https://app.example.com/callback"}, "token_endpoint_auth_method": "private_key_jwt", "jwks_uri": "http://app.example.com/jwks"}, "invalid_client_metadata"},
+		{map[string]interface{}{"redirect_uris": []string{"https://app.example.com/callback"}, "token_endpoint_auth_method": "private_key_jwt", "jwks_uri": "https://127.0.0.1/jwks"}, "invalid_client_metadata"},
+		{map[string]interface{}{"redirect_uris": []string{"https://app.example.com/callback"}, "token_endpoint_auth_method": "private_key_jwt", "jwks_uri": "https://localhost/jwks"}, "invalid_client_metadata"},
+		{map[string]interface{}{"redirect_uris": []string{"https://app.example.com/callback"}, "token_endpoint_auth_method": "private_key_jwt", "jwks_uri": "https://169.254.169.254/jwks"}, "invalid_client_metadata"},
+		{map[string]interface{}{"redirect_uris": []string{"https://app.example.com/callback"}, "token_endpoint_auth_method": "private_key_jwt", "jwks_uri": "https://10.0.0.1/jwks"}, "invalid_client_metadata"},
+		{map[string]interface{}{"redirect_uris": []string{"https://app.example.com/callback"}, "token_endpoint_auth_method": "private_key_jwt", "jwks_uri": "https://100.64.0.1/jwks"}, "invalid_client_metadata"},
+		{map[string]interface{}{"redirect_uris": []string{"https://app.example.com/callback"}, "token_endpoint_auth_method": "private_key_jwt", "jwks_uri": "https://0.1.2.3/jwks"}, "invalid_client_metadata"},
+	}
+
+	for _, testCase := range testCases {
+		resp := sendClientRegistrationRequest(t, "POST", lib.GetBaseUrl()+"/connect/register", "", testCase.metadata)
+		defer resp.Body.Close()
+		data := unmarshalToMap(t, resp)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, testCase.expectedError, data["error"], testCase.metadata)
+	}
+}
+
+func TestClientRegistration_HttpRedirectURIOnlyForLoopback(t *testing.T) {
+	setup()
+	defer setClientRegistrationMode(t, enums.ClientRegistrationModeOpen, "")()
+
+	resp := sendClientRegistrationRequest(t, "POST", lib.GetBaseUrl()+"/connect/register", "", map[string]interface{}{
+		"redirect_uris":             []string{"http://localhost:3000/callback", "http://127.0.0.1/callback", "http://[::1]:3000/callback"},
+		"post_logout_redirect_uris": []string{"http://localhost:3000/logged-out"},
+	})
+	defer resp.Body.Close()
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	clientIdentifier, _ := data["client_id"].(string)
+	client, err := database.GetClientByClientIdentifier(nil, clientIdentifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.NotNil(t, client) {
+		database.DeleteClient(nil, client.Id)
+	}
+}
+
+func TestClientRegistration_Discovery(t *testing.T) {
+	setup()
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	restore := setClientRegistrationMode(t, enums.ClientRegistrationModeDisabled, "")
+	resp := getPage(t, httpClient, lib.GetBaseUrl()+"/.well-known/openid-configuration")
+	defer resp.Body.Close()
+	data := unmarshalToMap(t, resp)
+	assert.Nil(t, data["registration_endpoint"])
+	restore()
+
+	defer setClientRegistrationMode(t, enums.ClientRegistrationModeOpen, "")()
+	resp = getPage(t, httpClient, lib.GetBaseUrl()+"/.well-known/openid-configuration")
+	defer resp.Body.Close()
+	data = unmarshalToMap(t, resp)
+	assert.Equal(t, lib.GetBaseUrl()+"/connect/register", data["registration_endpoint"])
+}
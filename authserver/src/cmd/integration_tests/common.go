@@ -730,6 +730,15 @@ func createNewKeyPair(t *testing.T) *entities.KeyPair {
 	}
 	privateKeyPEM := lib.EncodePrivateKeyToPEM(privateKey)
 
+	settings, err := database.GetSettingsById(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKeyPEMEncrypted, err := lib.EncryptText(string(privateKeyPEM), settings.AESEncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	publicKeyASN1_DER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
 	if err != nil {
 		t.Fatal("unable to marshal public key to PKIX")
@@ -749,18 +758,30 @@ func createNewKeyPair(t *testing.T) *entities.KeyPair {
 	}
 
 	keyPair := &entities.KeyPair{
-		State:             enums.KeyStateCurrent.String(),
-		KeyIdentifier:     kid,
-		Type:              "RSA",
-		Algorithm:         "RS256",
-		PrivateKeyPEM:     privateKeyPEM,
-		PublicKeyPEM:      publicKeyPEM,
-		PublicKeyASN1_DER: publicKeyASN1_DER,
-		PublicKeyJWK:      publicKeyJWK,
+		State:                  enums.KeyStateCurrent.String(),
+		KeyIdentifier:          kid,
+		Type:                   "RSA",
+		Algorithm:              "RS256",
+		PrivateKeyPEMEncrypted: privateKeyPEMEncrypted,
+		PublicKeyPEM:           publicKeyPEM,
+		PublicKeyASN1_DER:      publicKeyASN1_DER,
+		PublicKeyJWK:           publicKeyJWK,
 	}
 	return keyPair
 }
 
+func getPrivateKeyPEM(t *testing.T, keyPair *entities.KeyPair) []byte {
+	settings, err := database.GetSettingsById(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKeyPEM, err := lib.DecryptText(keyPair.PrivateKeyPEMEncrypted, settings.AESEncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []byte(privateKeyPEM)
+}
+
 func loginToAccountArea(t *testing.T, email string, password string) *http.Client {
 	setup()
 
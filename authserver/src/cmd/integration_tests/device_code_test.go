@@ -0,0 +1,364 @@
+package integrationtests
+
+import (
+	"database/sql"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	core_validators "github.com/leodip/goiabada/internal/core/validators"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+// enableDeviceCode turns on the device authorization flow for test-client-1, and returns
+// a function that puts the client back the way it was
+func enableDeviceCode(t *testing.T) func() {
+	client, err := database.GetClientByClientIdentifier(nil, "test-client-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := *client
+
+	client.DeviceCodeEnabled = true
+	err = database.UpdateClient(nil, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		err := database.UpdateClient(nil, &original)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func requestDeviceCode(t *testing.T) (deviceCode string, userCode string) {
+	formData := url.Values{
+		"scope": {"openid"},
+	}
+	resp := postWithBasicAuth(t, lib.GetBaseUrl()+"/auth/device_authorization", "test-client-1",
+		getClientSecret(t, "test-client-1"), formData)
+	defer resp.Body.Close()
+
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, lib.GetBaseUrl()+"/device", data["verification_uri"])
+	assert.Equal(t, float64(600), data["expires_in"])
+	assert.Equal(t, float64(5), data["interval"])
+
+	deviceCode, _ = data["device_code"].(string)
+	userCode, _ = data["user_code"].(string)
+	assert.NotEmpty(t, deviceCode)
+	assert.Regexp(t, "^[A-Z]{4}-[A-Z]{4}$", userCode)
+	assert.Equal(t, lib.GetBaseUrl()+"/device?user_code="+userCode, data["verification_uri_complete"])
+	return deviceCode, userCode
+}
+
+func pollTokenEndpoint(t *testing.T, httpClient *http.Client, deviceCode string) map[string]interface{} {
+	formData := url.Values{
+		"client_id":     {"test-client-1"},
+		"client_secret": {getClientSecret(t, "test-client-1")},
+		"grant_type":    {core_validators.DeviceCodeGrantType},
+		"device_code":   {deviceCode},
+	}
+	return postToTokenEndpoint(t, httpClient, lib.GetBaseUrl()+"/auth/token", formData)
+}
+
+// resetLastPolledAt lets the device poll again right away, without getting slow_down
+func resetLastPolledAt(t *testing.T, deviceCode string) {
+	deviceCodeHash, err := lib.HashString(deviceCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deviceCodeEntity, err := database.GetDeviceCodeByDeviceCodeHash(nil, deviceCodeHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deviceCodeEntity.LastPolledAt = sql.NullTime{}
+	deviceCodeEntity.IntervalInSeconds = 5
+	err = database.UpdateDeviceCode(nil, deviceCodeEntity)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func postUserCode(t *testing.T, httpClient *http.Client, userCode string) *http.Response {
+	formData := url.Values{
+		"userCode":           {userCode},
+		"gorilla.csrf.Token": {""},
+	}
+	request, err := http.NewRequest("POST", lib.GetBaseUrl()+"/device", strings.NewReader(formData.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestDeviceCode_Flow(t *testing.T) {
+	setup()
+	defer enableDeviceCode(t)()
+
+	user := createLockoutTestUser(t, "abc123")
+	deviceCode, userCode := requestDeviceCode(t)
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	// the user hasn't approved the device yet
+	data := pollTokenEndpoint(t, httpClient, deviceCode)
+	assert.Equal(t, "authorization_pending", data["error"])
+
+	data = pollTokenEndpoint(t, httpClient, deviceCode)
+	assert.Equal(t, "slow_down", data["error"])
+
+	resp := getPage(t, httpClient, lib.GetBaseUrl()+"/device?user_code="+url.QueryEscape(userCode))
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), userCode)
+
+	// the user code is case insensitive, and the dash is optional
+	resp = postUserCode(t, httpClient, strings.ToLower(strings.ReplaceAll(userCode, "-", "")))
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, lib.GetBaseUrl()+"/auth/pwd", resp.Header.Get("Location"))
+
+	resp = authenticateWithPassword(t, httpClient, user.Email, "abc123", "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/consent")
+
+	resp = postConsent(t, httpClient, []int{0}, "")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "Your device is now connected.")
+
+	resetLastPolledAt(t, deviceCode)
+	data = pollTokenEndpoint(t, httpClient, deviceCode)
+	assert.NotEmpty(t, data["access_token"])
+	assert.NotEmpty(t, data["id_token"])
+	assert.Equal(t, "openid authserver:userinfo", data["scope"])
+
+	// the device code can only be redeemed once
+	resetLastPolledAt(t, deviceCode)
+	data = pollTokenEndpoint(t, httpClient, deviceCode)
+	assert.Equal(t, "invalid_grant", data["error"])
+
+	// and the user code can't be entered again
+	resp = postUserCode(t, httpClient, userCode)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "The code is invalid or has expired.")
+}
+
+func TestDeviceCode_Denied(t *testing.T) {
+	setup()
+	defer enableDeviceCode(t)()
+
+	user := createLockoutTestUser(t, "abc123")
+	deviceCode, userCode := requestDeviceCode(t)
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	resp := postUserCode(t, httpClient, userCode)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, lib.GetBaseUrl()+"/auth/pwd", resp.Header.Get("Location"))
+
+	resp = authenticateWithPassword(t, httpClient, user.Email, "abc123", "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/consent")
+
+	resp = postConsent(t, httpClient, []int{}, "")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "The user did not provide consent")
+
+	data := pollTokenEndpoint(t, httpClient, deviceCode)
+	assert.Equal(t, "access_denied", data["error"])
+}
+
+func TestDeviceCode_ConsentIsAlwaysAsked(t *testing.T) {
+	setup()
+	defer enableDeviceCode(t)()
+
+	client, err := database.GetClientByClientIdentifier(nil, "test-client-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.ConsentRequired = false
+	err = database.UpdateClient(nil, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := createLockoutTestUser(t, "abc123")
+	_, userCode := requestDeviceCode(t)
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	resp := postUserCode(t, httpClient, userCode)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, lib.GetBaseUrl()+"/auth/pwd", resp.Header.Get("Location"))
+
+	// the client doesn't require consent, but the user must confirm the device
+	resp = authenticateWithPassword(t, httpClient, user.Email, "abc123", "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/consent")
+}
+
+func TestDeviceCode_UserCodeEntryIsLocked(t *testing.T) {
+	setup()
+	defer enableDeviceCode(t)()
+
+	// the invalid codes entered by the other tests, from the same address, don't count
+	err := database.DeleteDeviceUserCodeAttemptsOlderThan(nil, time.Now().UTC().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := database.DeleteDeviceUserCodeAttemptsOlderThan(nil, time.Now().UTC().Add(time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	_, userCode := requestDeviceCode(t)
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	for i := 0; i < 4; i++ {
+		resp := postUserCode(t, httpClient, "AAAA-AAAA")
+		defer resp.Body.Close()
+		assert.Contains(t, readBody(t, resp), "The code is invalid or has expired.")
+	}
+
+	resp := postUserCode(t, httpClient, "AAAA-AAAA")
+	defer resp.Body.Close()
+	assert.Contains(t, readBody(t, resp), "Too many invalid codes were entered.")
+
+	// while locked, not even the right code is accepted
+	resp = postUserCode(t, httpClient, userCode)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "Too many invalid codes were entered.")
+
+	// starting a new session doesn't lift the lock
+	httpClient = createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+	resp = postUserCode(t, httpClient, userCode)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "Too many invalid codes were entered.")
+}
+
+func TestDeviceCode_PollDoesNotUndoTheUserDecision(t *testing.T) {
+	setup()
+	defer enableDeviceCode(t)()
+
+	deviceCode, _ := requestDeviceCode(t)
+	deviceCodeHash, err := lib.HashString(deviceCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deviceCodeEntity, err := database.GetDeviceCodeByDeviceCodeHash(nil, deviceCodeHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	denied, err := database.DenyDeviceCode(nil, deviceCodeEntity.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, denied)
+
+	// a poll that loaded the device code while it was still pending
+	updated, err := database.UpdateDeviceCodePolling(nil, deviceCodeEntity.Id, time.Now().UTC(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, updated)
+
+	denied, err = database.DenyDeviceCode(nil, deviceCodeEntity.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, denied)
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+	data := pollTokenEndpoint(t, httpClient, deviceCode)
+	assert.Equal(t, "access_denied", data["error"])
+}
+
+func TestDeviceCode_InvalidRequests(t *testing.T) {
+	setup()
+
+	destUrl := lib.GetBaseUrl() + "/auth/device_authorization"
+	clientSecret := getClientSecret(t, "test-client-1")
+
+	// the flow is not enabled for the client
+	resp := postWithBasicAuth(t, destUrl, "test-client-1", clientSecret, url.Values{"scope": {"openid"}})
+	defer resp.Body.Close()
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "unauthorized_client", data["error"])
+
+	defer enableDeviceCode(t)()
+
+	resp = postWithBasicAuth(t, destUrl, "test-client-1", clientSecret, url.Values{"scope": {"invalid"}})
+	defer resp.Body.Close()
+	data = unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "invalid_scope", data["error"])
+
+	resp = postWithBasicAuth(t, destUrl, "test-client-1", "invalid", url.Values{"scope": {"openid"}})
+	defer resp.Body.Close()
+	data = unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "invalid_client", data["error"])
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	data = pollTokenEndpoint(t, httpClient, "invalid")
+	assert.Equal(t, "invalid_grant", data["error"])
+
+	// an expired device code
+	deviceCode, _ := requestDeviceCode(t)
+	deviceCodeHash, err := lib.HashString(deviceCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deviceCodeEntity, err := database.GetDeviceCodeByDeviceCodeHash(nil, deviceCodeHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deviceCodeEntity.ExpiresAt = sql.NullTime{Time: time.Now().UTC().Add(-time.Minute), Valid: true}
+	err = database.UpdateDeviceCode(nil, deviceCodeEntity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data = pollTokenEndpoint(t, httpClient, deviceCode)
+	assert.Equal(t, "expired_token", data["error"])
+}
@@ -0,0 +1,347 @@
+package integrationtests
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	b64 "encoding/base64"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	core_token "github.com/leodip/goiabada/internal/core/token"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+type dpopKey struct {
+	privateKey *ecdsa.PrivateKey
+	jwk        map[string]interface{}
+	jkt        string
+}
+
+func createDPoPKey(t *testing.T) *dpopKey {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwkJSON, err := lib.MarshalECPublicKeyToJWK(&privateKey.PublicKey, "ES256", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := &dpopKey{privateKey: privateKey}
+	err = json.Unmarshal(jwkJSON, &key.jwk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jwk lib.JSONWebKey
+	err = json.Unmarshal(jwkJSON, &jwk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key.jkt, err = jwk.Thumbprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func (key *dpopKey) createProof(t *testing.T, method string, destUrl string, accessToken string, nonce string) string {
+	claims := jwt.MapClaims{
+		"jti": uuid.New().String(),
+		"htm": method,
+		"htu": destUrl,
+		"iat": time.Now().UTC().Unix(),
+	}
+	if len(accessToken) > 0 {
+		hash := sha256.Sum256([]byte(accessToken))
+		claims["ath"] = b64.RawURLEncoding.EncodeToString(hash[:])
+	}
+	if len(nonce) > 0 {
+		claims["nonce"] = nonce
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["typ"] = "dpop+jwt"
+	token.Header["jwk"] = key.jwk
+
+	proof, err := token.SignedString(key.privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return proof
+}
+
+// useDPoP configures the DPoP settings of the client, and returns a function that puts the
+// client back the way it was
+func useDPoP(t *testing.T, clientIdentifier string, dpopMode enums.DPoPMode, nonceRequired bool) func() {
+	client, err := database.GetClientByClientIdentifier(nil, clientIdentifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := *client
+
+	client.DPoPMode = dpopMode.String()
+	client.DPoPNonceRequired = nonceRequired
+	err = database.UpdateClient(nil, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		err := database.UpdateClient(nil, &original)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func postWithDPoP(t *testing.T, destUrl string, formData url.Values, proof string) *http.Response {
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	request, err := http.NewRequest("POST", destUrl, strings.NewReader(formData.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if len(proof) > 0 {
+		request.Header.Set("DPoP", proof)
+	}
+
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func getUserInfoWithDPoP(t *testing.T, scheme string, accessToken string, proof string) *http.Response {
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	request, err := http.NewRequest("GET", lib.GetBaseUrl()+"/userinfo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Authorization", scheme+" "+accessToken)
+	if len(proof) > 0 {
+		request.Header.Set("DPoP", proof)
+	}
+
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func getClientCredentialsFormData(t *testing.T) url.Values {
+	return url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"test-client-1"},
+		"client_secret": {getClientSecret(t, "test-client-1")},
+		"scope":         {"backend-svcA:create-product"},
+	}
+}
+
+func TestDPoP_ClientCredentials(t *testing.T) {
+	setup()
+
+	defer useDPoP(t, "test-client-1", enums.DPoPModeOptional, false)()
+
+	destUrl := lib.GetBaseUrl() + "/auth/token"
+	key := createDPoPKey(t)
+
+	proof := key.createProof(t, "POST", destUrl, "", "")
+	resp := postWithDPoP(t, destUrl, getClientCredentialsFormData(t), proof)
+	defer resp.Body.Close()
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "DPoP", data["token_type"])
+
+	accessToken, err := core_token.NewTokenParser(database).ParseToken(getSettingsContext(t), data["access_token"].(string), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, key.jkt, accessToken.GetConfirmationClaim()["jkt"])
+
+	// the same proof can't be used twice
+	resp = postWithDPoP(t, destUrl, getClientCredentialsFormData(t), proof)
+	defer resp.Body.Close()
+	data = unmarshalToMap(t, resp)
+	assert.Equal(t, "invalid_dpop_proof", data["error"])
+	assert.Contains(t, data["error_description"], "the proof has already been used")
+
+	// the proof must be bound to the request
+	proof = key.createProof(t, "POST", lib.GetBaseUrl()+"/auth/introspect", "", "")
+	resp = postWithDPoP(t, destUrl, getClientCredentialsFormData(t), proof)
+	defer resp.Body.Close()
+	data = unmarshalToMap(t, resp)
+	assert.Equal(t, "invalid_dpop_proof", data["error"])
+
+	// without a proof, a bearer token is issued
+	resp = postWithDPoP(t, destUrl, getClientCredentialsFormData(t), "")
+	defer resp.Body.Close()
+	data = unmarshalToMap(t, resp)
+	assert.Equal(t, "Bearer", data["token_type"])
+}
+
+func TestDPoP_Required(t *testing.T) {
+	setup()
+
+	defer useDPoP(t, "test-client-1", enums.DPoPModeRequired, false)()
+
+	resp := postWithDPoP(t, lib.GetBaseUrl()+"/auth/token", getClientCredentialsFormData(t), "")
+	defer resp.Body.Close()
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "invalid_dpop_proof", data["error"])
+}
+
+func TestDPoP_Nonce(t *testing.T) {
+	setup()
+
+	defer useDPoP(t, "test-client-1", enums.DPoPModeRequired, true)()
+
+	destUrl := lib.GetBaseUrl() + "/auth/token"
+	key := createDPoPKey(t)
+
+	resp := postWithDPoP(t, destUrl, getClientCredentialsFormData(t), key.createProof(t, "POST", destUrl, "", ""))
+	defer resp.Body.Close()
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "use_dpop_nonce", data["error"])
+	nonce := resp.Header.Get("DPoP-Nonce")
+	assert.NotEmpty(t, nonce)
+
+	resp = postWithDPoP(t, destUrl, getClientCredentialsFormData(t), key.createProof(t, "POST", destUrl, "", nonce))
+	defer resp.Body.Close()
+	data = unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "DPoP", data["token_type"])
+
+	// a nonce made up by the client is rejected
+	resp = postWithDPoP(t, destUrl, getClientCredentialsFormData(t), key.createProof(t, "POST", destUrl, "", "abc"))
+	defer resp.Body.Close()
+	data = unmarshalToMap(t, resp)
+	assert.Equal(t, "use_dpop_nonce", data["error"])
+}
+
+func TestDPoP_UserInfoAndRefreshTokenOfPublicClient(t *testing.T) {
+	setup()
+
+	defer useDPoP(t, "test-client-2", enums.DPoPModeOptional, false)()
+
+	client, err := database.GetClientByClientIdentifier(nil, "test-client-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := database.GetUserByEmail(nil, "mauro@outlook.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const scope = "openid offline_access"
+	now := time.Now().UTC()
+	code := &entities.Code{
+		CodeHash:          uuid.New().String(),
+		ClientId:          client.Id,
+		Scope:             scope,
+		RedirectURI:       "https://goiabada-test-client:8090/callback.html",
+		UserId:            user.Id,
+		ResponseMode:      "query",
+		AuthenticatedAt:   now,
+		SessionIdentifier: uuid.New().String(),
+		AcrLevel:          enums.AcrLevel1.String(),
+		AuthMethods:       enums.AuthMethodPassword.String(),
+		Used:              true,
+	}
+	err = database.CreateCode(nil, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userConsent := &entities.UserConsent{
+		UserId:    user.Id,
+		ClientId:  client.Id,
+		Scope:     scope,
+		GrantedAt: sql.NullTime{Time: now, Valid: true},
+	}
+	err = database.CreateUserConsent(nil, userConsent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.DeleteUserConsent(nil, userConsent.Id)
+
+	key := createDPoPKey(t)
+	ctx := getSettingsContext(t)
+	tokenIssuer := core_token.NewTokenIssuer(database, core_token.NewTokenParser(database))
+	tokenResponse, err := tokenIssuer.GenerateTokenResponseForAuthCode(ctx, &core_token.GenerateTokenResponseForAuthCodeInput{
+		Code:         code,
+		Confirmation: &core_token.TokenConfirmation{Jkt: key.jkt},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "DPoP", tokenResponse.TokenType)
+
+	// the bound access token can't be used as a bearer token
+	resp := getUserInfoWithDPoP(t, "Bearer", tokenResponse.AccessToken, "")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	userInfoUrl := lib.GetBaseUrl() + "/userinfo"
+	resp = getUserInfoWithDPoP(t, "DPoP", tokenResponse.AccessToken, key.createProof(t, "GET", userInfoUrl, tokenResponse.AccessToken, ""))
+	defer resp.Body.Close()
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, user.Subject.String(), data["sub"])
+
+	// a proof signed with another key is rejected
+	otherKey := createDPoPKey(t)
+	resp = getUserInfoWithDPoP(t, "DPoP", tokenResponse.AccessToken, otherKey.createProof(t, "GET", userInfoUrl, tokenResponse.AccessToken, ""))
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("WWW-Authenticate"), `error="invalid_dpop_proof"`)
+
+	// the refresh token of a public client is bound to the DPoP key as well
+	destUrl := lib.GetBaseUrl() + "/auth/token"
+	formData := url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {"test-client-2"},
+		"refresh_token": {tokenResponse.RefreshToken},
+	}
+	resp = postWithDPoP(t, destUrl, formData, "")
+	defer resp.Body.Close()
+	data = unmarshalToMap(t, resp)
+	assert.Equal(t, "invalid_dpop_proof", data["error"])
+
+	resp = postWithDPoP(t, destUrl, formData, otherKey.createProof(t, "POST", destUrl, "", ""))
+	defer resp.Body.Close()
+	data = unmarshalToMap(t, resp)
+	assert.Equal(t, "invalid_dpop_proof", data["error"])
+
+	resp = postWithDPoP(t, destUrl, formData, key.createProof(t, "POST", destUrl, "", ""))
+	defer resp.Body.Close()
+	data = unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "DPoP", data["token_type"])
+
+	refreshToken, err := core_token.NewTokenParser(database).ParseToken(ctx, data["refresh_token"].(string), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, key.jkt, refreshToken.GetConfirmationClaim()["jkt"])
+}
@@ -0,0 +1,203 @@
+package integrationtests
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+// enableEmailOtpWithPendingCode enables OTP by email for the user, with a code that was
+// sent at issuedAt (no email is actually sent)
+func enableEmailOtpWithPendingCode(t *testing.T, user *entities.User, otpCode string, issuedAt time.Time) {
+	settings, err := database.GetSettingsById(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otpCodeEncrypted, err := lib.EncryptText(otpCode, settings.AESEncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user.OTPEnabled = true
+	user.OTPMethod = enums.OTPMethodEmail.String()
+	user.OTPCodeEncrypted = otpCodeEncrypted
+	user.OTPCodeIssuedAt = sql.NullTime{Time: issuedAt, Valid: true}
+	err = database.UpdateUser(nil, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestEmailOtp_LoginWithCode(t *testing.T) {
+	setup()
+
+	restoreSettings := updateLockoutSettings(t, 0, 900, 0, 0)
+	defer restoreSettings()
+
+	user := createLockoutTestUser(t, "abc123")
+	enableEmailOtpWithPendingCode(t, user, "123456", time.Now().UTC())
+
+	httpClient := startAuthorizeWithAcrLevel(t, enums.AcrLevel2)
+
+	// with an email OTP, AcrLevel2 requires the code
+	resp := authenticateWithPassword(t, httpClient, user.Email, "abc123", "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/otp")
+
+	// a code is pending, so the page doesn't send another one
+	resp = getPage(t, httpClient, lib.GetBaseUrl()+"/auth/otp")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "We sent a six-digit code to")
+
+	resp = authenticateWithOtp(t, httpClient, "654321", "")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "Incorrect or expired code")
+
+	resp = authenticateWithOtp(t, httpClient, "123456", "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/consent")
+
+	assertLatestUserSession(t, user.Id, enums.AuthMethodPassword.String()+" "+enums.AuthMethodOTP.String(),
+		enums.AcrLevel2)
+
+	// the code can be used only once
+	user, err := database.GetUserById(nil, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, user.OTPCodeEncrypted)
+	assert.False(t, user.OTPCodeIssuedAt.Valid)
+	assert.Equal(t, 0, user.FailedLoginAttempts)
+}
+
+func TestEmailOtp_ExpiredCodeIsRejected(t *testing.T) {
+	setup()
+
+	restoreSettings := updateLockoutSettings(t, 0, 900, 0, 0)
+	defer restoreSettings()
+
+	user := createLockoutTestUser(t, "abc123")
+	enableEmailOtpWithPendingCode(t, user, "123456", time.Now().UTC().Add(-6*time.Minute))
+
+	httpClient := startAuthorizeWithAcrLevel(t, enums.AcrLevel3)
+	resp := authenticateWithPassword(t, httpClient, user.Email, "abc123", "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/otp")
+
+	resp = authenticateWithOtp(t, httpClient, "123456", "")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "Incorrect or expired code")
+
+	user, err := database.GetUserById(nil, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, user.FailedLoginAttempts)
+}
+
+func TestEmailOtp_NewCodeIsRateLimited(t *testing.T) {
+	setup()
+
+	user := createLockoutTestUser(t, "abc123")
+	enableEmailOtpWithPendingCode(t, user, "123456", time.Now().UTC().Add(-10*time.Second))
+
+	httpClient := startAuthorizeWithAcrLevel(t, enums.AcrLevel2)
+	resp := authenticateWithPassword(t, httpClient, user.Email, "abc123", "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/otp")
+
+	resp, err := httpClient.Post(lib.GetBaseUrl()+"/auth/otp/send", "application/x-www-form-urlencoded", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "Please wait")
+
+	// the pending code is still valid
+	resp = authenticateWithOtp(t, httpClient, "123456", "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/consent")
+}
+
+func TestEmailOtp_CodeIsInvalidatedAfterTooManyAttempts(t *testing.T) {
+	setup()
+
+	restoreSettings := updateLockoutSettings(t, 0, 900, 0, 0)
+	defer restoreSettings()
+
+	user := createLockoutTestUser(t, "abc123")
+	enableEmailOtpWithPendingCode(t, user, "123456", time.Now().UTC())
+
+	httpClient := startAuthorizeWithAcrLevel(t, enums.AcrLevel2)
+	resp := authenticateWithPassword(t, httpClient, user.Email, "abc123", "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/otp")
+
+	for i := 0; i < 5; i++ {
+		resp = authenticateWithOtp(t, httpClient, "654321", "")
+		defer resp.Body.Close()
+		assert.Contains(t, readBody(t, resp), "Incorrect or expired code")
+	}
+
+	// the right code doesn't work anymore; a new one must be requested
+	resp = authenticateWithOtp(t, httpClient, "123456", "")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "Incorrect or expired code")
+
+	user, err := database.GetUserById(nil, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, user.OTPCodeEncrypted)
+	assert.False(t, user.OTPCodeIssuedAt.Valid)
+	assert.Equal(t, 0, user.OTPCodeAttempts)
+}
+
+func TestEmailOtp_PageDoesNotSendCode(t *testing.T) {
+	setup()
+
+	user := createLockoutTestUser(t, "abc123")
+	enableEmailOtpWithPendingCode(t, user, "123456", time.Now().UTC())
+	user.OTPCodeEncrypted = nil
+	user.OTPCodeIssuedAt = sql.NullTime{Valid: false}
+	err := database.UpdateUser(nil, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpClient := startAuthorizeWithAcrLevel(t, enums.AcrLevel2)
+	resp := authenticateWithPassword(t, httpClient, user.Email, "abc123", "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/otp")
+
+	resp = getPage(t, httpClient, lib.GetBaseUrl()+"/auth/otp")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "Send me a code")
+
+	user, err = database.GetUserById(nil, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, user.OTPCodeIssuedAt.Valid)
+}
@@ -0,0 +1,144 @@
+package integrationtests
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+// setFrontchannelLogoutURI sets the frontchannel_logout_uri of test-client-2, and returns
+// a function that puts the client back the way it was
+func setFrontchannelLogoutURI(t *testing.T, frontchannelLogoutURI string) func() {
+	client, err := database.GetClientByClientIdentifier(nil, "test-client-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := *client
+
+	client.FrontchannelLogoutURI = frontchannelLogoutURI
+	err = database.UpdateClient(nil, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		err := database.UpdateClient(nil, &original)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func getLogoutUrl(idToken string, postLogoutRedirectURI string) string {
+	return lib.GetBaseUrl() + "/auth/logout?id_token_hint=" + url.QueryEscape(idToken) +
+		"&post_logout_redirect_uri=" + url.QueryEscape(postLogoutRedirectURI) +
+		"&state=XYZ123"
+}
+
+func TestFrontchannelLogout(t *testing.T) {
+	setup()
+	defer setFrontchannelLogoutURI(t, "https://goiabada-test-client:8090/frontchannel-logout?x=1")()
+
+	user := createLockoutTestUser(t, "abc123")
+	httpClient, idToken := loginForPromptTest(t, user.Email, "abc123")
+
+	settings, err := database.GetSettingsById(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := getPage(t, httpClient, getLogoutUrl(idToken, "https://oauthdebugger.com/debug"))
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iframes := doc.Find("iframe")
+	assert.Equal(t, 1, iframes.Length())
+	src, _ := iframes.Attr("src")
+	frontchannelLogoutURI, err := url.Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "goiabada-test-client:8090", frontchannelLogoutURI.Host)
+	assert.Equal(t, "/frontchannel-logout", frontchannelLogoutURI.Path)
+	assert.Equal(t, "1", frontchannelLogoutURI.Query().Get("x"))
+	assert.Equal(t, settings.Issuer, frontchannelLogoutURI.Query().Get("iss"))
+	sid := frontchannelLogoutURI.Query().Get("sid")
+	assert.NotEmpty(t, sid)
+
+	continueLink, _ := doc.Find("a").Attr("href")
+	assert.Contains(t, continueLink, "https://oauthdebugger.com/debug")
+	assert.Contains(t, continueLink, "sid="+sid)
+	assert.Contains(t, continueLink, "state=XYZ123")
+
+	// the whole SSO session has ended
+	userSession, err := database.GetUserSessionBySessionIdentifier(nil, sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, userSession)
+}
+
+func TestFrontchannelLogout_NoFrontchannelLogoutURI(t *testing.T) {
+	setup()
+
+	user := createLockoutTestUser(t, "abc123")
+	httpClient, idToken := loginForPromptTest(t, user.Email, "abc123")
+
+	resp := getPage(t, httpClient, getLogoutUrl(idToken, "https://oauthdebugger.com/debug"))
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/debug")
+	assert.Contains(t, resp.Header.Get("Location"), "https://oauthdebugger.com/debug")
+	assert.Contains(t, resp.Header.Get("Location"), "state=XYZ123")
+}
+
+func TestAccountLogout_PostLogoutRedirectURIsAreSeparate(t *testing.T) {
+	setup()
+
+	client, err := database.GetClientByClientIdentifier(nil, "test-client-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// registered for the login only
+	redirectURI := &entities.RedirectURI{
+		ClientId: client.Id,
+		URI:      "https://goiabada-test-client:8090/login-only.html",
+	}
+	err = database.CreateRedirectURI(nil, redirectURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.DeleteRedirectURI(nil, redirectURI.Id)
+
+	// registered for the logout only
+	postLogoutRedirectURI := &entities.PostLogoutRedirectURI{
+		ClientId: client.Id,
+		URI:      "https://goiabada-test-client:8090/logged-out.html",
+	}
+	err = database.CreatePostLogoutRedirectURI(nil, postLogoutRedirectURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.DeletePostLogoutRedirectURI(nil, postLogoutRedirectURI.Id)
+
+	user := createLockoutTestUser(t, "abc123")
+	httpClient, idToken := loginForPromptTest(t, user.Email, "abc123")
+
+	resp := getPage(t, httpClient, getLogoutUrl(idToken, redirectURI.URI))
+	defer resp.Body.Close()
+	assert.Contains(t, readBody(t, resp), "The post_logout_redirect_uri parameter is invalid")
+
+	resp = getPage(t, httpClient, getLogoutUrl(idToken, postLogoutRedirectURI.URI))
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/logged-out.html")
+}
@@ -0,0 +1,284 @@
+package integrationtests
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+type requestObjectKey struct {
+	privateKey *ecdsa.PrivateKey
+	kid        string
+}
+
+// useRequestObjectKey registers a new public key on test-client-1, and returns a function
+// that puts the client back the way it was
+func useRequestObjectKey(t *testing.T, requireSignedRequestObject bool) (*requestObjectKey, func()) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid := uuid.New().String()
+	jwk, err := lib.MarshalECPublicKeyToJWK(&privateKey.PublicKey, "ES256", kid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := database.GetClientByClientIdentifier(nil, "test-client-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := *client
+
+	client.JWKS = fmt.Sprintf(`{"keys": [%s]}`, jwk)
+	client.RequireSignedRequestObject = requireSignedRequestObject
+	err = database.UpdateClient(nil, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &requestObjectKey{privateKey: privateKey, kid: kid}, func() {
+		err := database.UpdateClient(nil, &original)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func getRequestObjectClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":                   "test-client-1",
+		"aud":                   lib.GetBaseUrl(),
+		"client_id":             "test-client-1",
+		"redirect_uri":          "https://goiabada-test-client:8090/callback.html",
+		"response_type":         "code",
+		"code_challenge_method": "S256",
+		"code_challenge":        "0BnoD4e6xPCPip8rqZ9Zc2RqWOFfvryu9vzXJN4egoY",
+		"response_mode":         "query",
+		"scope":                 "openid",
+		"state":                 "d4e5f6",
+		"exp":                   time.Now().Add(5 * time.Minute).Unix(),
+		"jti":                   uuid.New().String(),
+	}
+}
+
+func (key *requestObjectKey) sign(t *testing.T, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = key.kid
+
+	requestObject, err := token.SignedString(key.privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return requestObject
+}
+
+func createUnsignedRequestObject(t *testing.T, claims jwt.MapClaims) string {
+	requestObject, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return requestObject
+}
+
+func TestJAR_AuthorizationCodeFlow(t *testing.T) {
+	setup()
+
+	key, restore := useRequestObjectKey(t, false)
+	defer restore()
+
+	user := createLockoutTestUser(t, "abc123")
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	// the state outside of the request object is ignored
+	params := url.Values{
+		"client_id":     {"test-client-1"},
+		"response_type": {"code"},
+		"state":         {"ignored"},
+		"request":       {key.sign(t, getRequestObjectClaims())},
+	}
+	resp, err := httpClient.Get(lib.GetBaseUrl() + "/auth/authorize/?" + params.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+
+	resp = authenticateWithPassword(t, httpClient, user.Email, "abc123", "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/consent")
+
+	resp = postConsent(t, httpClient, []int{0}, "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/callback.html")
+	code, state := getCodeAndStateFromUrl(t, resp)
+	assert.Equal(t, "d4e5f6", state)
+
+	formData := url.Values{
+		"client_id":     {"test-client-1"},
+		"client_secret": {getClientSecret(t, "test-client-1")},
+		"grant_type":    {"authorization_code"},
+		"redirect_uri":  {"https://goiabada-test-client:8090/callback.html"},
+		"code":          {code},
+		"code_verifier": {"DdazqdVNuDmRLGGRGQKKehEaoFeatACtNsM2UYGwuHkhBhDsTSzaCqWttcBc0kGx"},
+	}
+	data := postToTokenEndpoint(t, httpClient, lib.GetBaseUrl()+"/auth/token", formData)
+	assert.NotEmpty(t, data["access_token"])
+}
+
+func TestJAR_InvalidRequestObject(t *testing.T) {
+	setup()
+
+	key, restore := useRequestObjectKey(t, false)
+	defer restore()
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+	getErrorMessage := func(requestObject string) string {
+		params := url.Values{
+			"client_id":     {"test-client-1"},
+			"response_type": {"code"},
+			"request":       {requestObject},
+		}
+		return getAuthorizeErrorMessage(t, httpClient, lib.GetBaseUrl()+"/auth/authorize/?"+params.Encode())
+	}
+
+	// signed with a key that is not registered on the client
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := getErrorMessage((&requestObjectKey{privateKey: otherKey, kid: key.kid}).sign(t, getRequestObjectClaims()))
+	assert.Contains(t, body, "The request object is invalid")
+
+	claims := getRequestObjectClaims()
+	claims["aud"] = "https://example.com"
+	body = getErrorMessage(key.sign(t, claims))
+	assert.Contains(t, body, "the aud claim does not identify this authorization server")
+
+	claims = getRequestObjectClaims()
+	claims["iss"] = "test-client-2"
+	body = getErrorMessage(key.sign(t, claims))
+	assert.Contains(t, body, "the iss claim must be the client identifier")
+
+	claims = getRequestObjectClaims()
+	claims["client_id"] = "test-client-2"
+	body = getErrorMessage(key.sign(t, claims))
+	assert.Contains(t, body, "the client_id claim does not match the client_id parameter")
+
+	claims = getRequestObjectClaims()
+	delete(claims, "exp")
+	body = getErrorMessage(key.sign(t, claims))
+	assert.Contains(t, body, "the exp claim is missing")
+
+	claims = getRequestObjectClaims()
+	claims["exp"] = time.Now().Add(2 * time.Hour).Unix()
+	body = getErrorMessage(key.sign(t, claims))
+	assert.Contains(t, body, "the exp claim can&#39;t be more than 60 minutes in the future")
+
+	claims = getRequestObjectClaims()
+	delete(claims, "jti")
+	body = getErrorMessage(key.sign(t, claims))
+	assert.Contains(t, body, "the jti claim is missing")
+}
+
+func TestJAR_RequestObjectCantBeReplayed(t *testing.T) {
+	setup()
+
+	key, restore := useRequestObjectKey(t, false)
+	defer restore()
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+	params := url.Values{
+		"client_id":     {"test-client-1"},
+		"response_type": {"code"},
+		"request":       {key.sign(t, getRequestObjectClaims())},
+	}
+	resp := getPage(t, httpClient, lib.GetBaseUrl()+"/auth/authorize/?"+params.Encode())
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+
+	body := getAuthorizeErrorMessage(t, httpClient, lib.GetBaseUrl()+"/auth/authorize/?"+params.Encode())
+	assert.Contains(t, body, "the request object has already been used")
+
+	// a request object that was pushed can't be sent again directly either
+	requestObject := key.sign(t, getRequestObjectClaims())
+	requestURI := pushAuthorizationRequest(t, url.Values{"request": {requestObject}})
+	resp = getPage(t, httpClient, lib.GetBaseUrl()+"/auth/authorize/?client_id=test-client-1&request_uri="+url.QueryEscape(requestURI))
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+
+	params.Set("request", requestObject)
+	body = getAuthorizeErrorMessage(t, httpClient, lib.GetBaseUrl()+"/auth/authorize/?"+params.Encode())
+	assert.Contains(t, body, "the request object has already been used")
+}
+
+func TestJAR_Required(t *testing.T) {
+	setup()
+
+	key, restore := useRequestObjectKey(t, true)
+	defer restore()
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	params := getPushedAuthorizationRequestFormData()
+	params.Set("client_id", "test-client-1")
+	body := getAuthorizeErrorMessage(t, httpClient, lib.GetBaseUrl()+"/auth/authorize/?"+params.Encode())
+	assert.Contains(t, body, "is required to send the authorization request as a signed request object (JAR)")
+
+	params = url.Values{
+		"client_id":     {"test-client-1"},
+		"response_type": {"code"},
+		"request":       {createUnsignedRequestObject(t, getRequestObjectClaims())},
+	}
+	body = getAuthorizeErrorMessage(t, httpClient, lib.GetBaseUrl()+"/auth/authorize/?"+params.Encode())
+	assert.Contains(t, body, "The request object is invalid")
+
+	params.Set("request", key.sign(t, getRequestObjectClaims()))
+	resp := getPage(t, httpClient, lib.GetBaseUrl()+"/auth/authorize/?"+params.Encode())
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+}
+
+func TestJAR_PushedAuthorizationRequest(t *testing.T) {
+	setup()
+
+	key, restore := useRequestObjectKey(t, true)
+	defer restore()
+
+	// the pushed request object is verified at the PAR endpoint
+	claims := getRequestObjectClaims()
+	claims["aud"] = "https://example.com"
+	resp := postWithBasicAuth(t, lib.GetBaseUrl()+"/auth/par", "test-client-1", getClientSecret(t, "test-client-1"),
+		url.Values{"request": {key.sign(t, claims)}})
+	defer resp.Body.Close()
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "invalid_request_object", data["error"])
+
+	requestURI := pushAuthorizationRequest(t, url.Values{"request": {key.sign(t, getRequestObjectClaims())}})
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+	resp = getPage(t, httpClient, lib.GetBaseUrl()+"/auth/authorize/?client_id=test-client-1&request_uri="+url.QueryEscape(requestURI))
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+}
@@ -0,0 +1,298 @@
+package integrationtests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	core_token "github.com/leodip/goiabada/internal/core/token"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/stretchr/testify/assert"
+)
+
+// snapshotSigningKeys returns a function that puts the signing keys back the way they were,
+// deleting any key created in the meantime
+func snapshotSigningKeys(t *testing.T) func() {
+	originalKeys, err := database.GetAllSigningKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		allSigningKeys, err := database.GetAllSigningKeys(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, signingKey := range allSigningKeys {
+			found := false
+			for _, originalKey := range originalKeys {
+				if originalKey.Id == signingKey.Id {
+					found = true
+				}
+			}
+			if !found {
+				err = database.DeleteKeyPair(nil, signingKey.Id)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		for i := range originalKeys {
+			err = database.UpdateKeyPair(nil, &originalKeys[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func getSigningKeyByKid(t *testing.T, kid string) *entities.KeyPair {
+	allSigningKeys, err := database.GetAllSigningKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range allSigningKeys {
+		if allSigningKeys[i].KeyIdentifier == kid {
+			return &allSigningKeys[i]
+		}
+	}
+	return nil
+}
+
+func updateKeyRotationSettings(t *testing.T, intervalInDays int, overlapInDays int) *entities.Settings {
+	settings, err := database.GetSettingsById(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings.KeyRotationIntervalInDays = intervalInDays
+	settings.KeyRotationOverlapInDays = overlapInDays
+	err = database.UpdateSettings(nil, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return settings
+}
+
+func TestKeyRotation_DisabledByDefault(t *testing.T) {
+	setup()
+
+	settings, err := database.GetSettingsById(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, settings.KeyRotationIntervalInDays)
+
+	keyRotator := core_token.NewKeyRotator(database, core_token.NewTokenParser(database))
+	schedule, err := keyRotator.GetSchedule(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, schedule.NextRotation.IsZero())
+	assert.True(t, schedule.PreviousKeyRevocation.IsZero())
+}
+
+func TestKeyRotation_ScheduledRotation(t *testing.T) {
+	setup()
+
+	defer snapshotSigningKeys(t)()
+	defer updateKeyRotationSettings(t, 0, 7)
+	settings := updateKeyRotationSettings(t, 90, 7)
+
+	currentKey, err := database.GetCurrentSigningKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyRotator := core_token.NewKeyRotator(database, core_token.NewTokenParser(database))
+	schedule, err := keyRotator.GetSchedule(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	activatedAt := currentKey.ActivatedAt
+	if !activatedAt.Valid {
+		activatedAt = currentKey.CreatedAt
+	}
+	assert.False(t, schedule.NextRotation.Before(activatedAt.Time.AddDate(0, 0, 90)))
+
+	// nothing is due yet
+	err = keyRotator.RunScheduledRotation(context.Background(), settings, time.Now().UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	unchangedKey, err := database.GetCurrentSigningKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, currentKey.KeyIdentifier, unchangedKey.KeyIdentifier)
+
+	allSigningKeys, err := database.GetAllSigningKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nextKey *entities.KeyPair
+	for i := range allSigningKeys {
+		if allSigningKeys[i].State == enums.KeyStateNext.String() {
+			nextKey = &allSigningKeys[i]
+		}
+	}
+	if nextKey == nil {
+		t.Fatal("no next key found")
+	}
+
+	err = keyRotator.RunScheduledRotation(context.Background(), settings, schedule.NextRotation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newCurrentKey, err := database.GetCurrentSigningKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, nextKey.KeyIdentifier, newCurrentKey.KeyIdentifier)
+	assert.True(t, newCurrentKey.ActivatedAt.Valid)
+
+	previousKey := getSigningKeyByKid(t, currentKey.KeyIdentifier)
+	assert.Equal(t, enums.KeyStatePrevious.String(), previousKey.State)
+	assert.True(t, previousKey.DeactivatedAt.Valid)
+
+	allSigningKeys, err = database.GetAllSigningKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextKeys := 0
+	for _, signingKey := range allSigningKeys {
+		if signingKey.State == enums.KeyStateNext.String() {
+			nextKeys++
+			assert.Equal(t, nextKey.Algorithm, signingKey.Algorithm)
+		}
+	}
+	assert.Equal(t, 1, nextKeys)
+}
+
+func TestKeyRotation_PreviousKeyRevokedAfterLongestTokenLifetime(t *testing.T) {
+	setup()
+
+	defer snapshotSigningKeys(t)()
+	defer updateKeyRotationSettings(t, 0, 7)
+	settings := updateKeyRotationSettings(t, 3650, 7)
+
+	keyRotator := core_token.NewKeyRotator(database, core_token.NewTokenParser(database))
+	err := keyRotator.RotateKeys(context.Background(), settings, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allSigningKeys, err := database.GetAllSigningKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var previousKey *entities.KeyPair
+	for i := range allSigningKeys {
+		if allSigningKeys[i].State == enums.KeyStatePrevious.String() {
+			previousKey = &allSigningKeys[i]
+		}
+	}
+	if previousKey == nil {
+		t.Fatal("no previous key found")
+	}
+
+	schedule, err := keyRotator.GetSchedule(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the refresh tokens for offline access have the longest lifetime
+	longestTokenLifetime := time.Duration(settings.RefreshTokenOfflineMaxLifetimeInSeconds) * time.Second
+	assert.False(t, schedule.PreviousKeyRevocation.Before(previousKey.DeactivatedAt.Time.Add(longestTokenLifetime)))
+
+	err = keyRotator.RunScheduledRotation(context.Background(), settings, schedule.PreviousKeyRevocation.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousKey = getSigningKeyByKid(t, previousKey.KeyIdentifier)
+	assert.Equal(t, enums.KeyStatePrevious.String(), previousKey.State)
+
+	err = keyRotator.RunScheduledRotation(context.Background(), settings, schedule.PreviousKeyRevocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousKey = getSigningKeyByKid(t, previousKey.KeyIdentifier)
+	assert.Equal(t, enums.KeyStateRevoked.String(), previousKey.State)
+	assert.Nil(t, previousKey.PrivateKeyPEMEncrypted)
+}
+
+func TestKeyRotation_RotationWaitsForThePreviousKeyRevocation(t *testing.T) {
+	setup()
+
+	defer snapshotSigningKeys(t)()
+	defer updateKeyRotationSettings(t, 0, 7)
+	settings := updateKeyRotationSettings(t, 1, 0)
+
+	keyRotator := core_token.NewKeyRotator(database, core_token.NewTokenParser(database))
+	err := keyRotator.RotateKeys(context.Background(), settings, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	currentKey, err := database.GetCurrentSigningKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schedule, err := keyRotator.GetSchedule(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, schedule.PreviousKeyRevocation, schedule.NextRotation)
+
+	// the rotation interval has passed, but the previous key can't be revoked yet
+	err = keyRotator.RunScheduledRotation(context.Background(), settings, time.Now().UTC().AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unchangedKey, err := database.GetCurrentSigningKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, currentKey.KeyIdentifier, unchangedKey.KeyIdentifier)
+}
+
+func TestKeyRotation_ConcurrentScheduledRotationsRotateOnce(t *testing.T) {
+	setup()
+
+	defer snapshotSigningKeys(t)()
+	defer updateKeyRotationSettings(t, 0, 7)
+	settings := updateKeyRotationSettings(t, 90, 7)
+
+	keyRotator := core_token.NewKeyRotator(database, core_token.NewTokenParser(database))
+	schedule, err := keyRotator.GetSchedule(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// two instances run the schedule at the same time
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			otherKeyRotator := core_token.NewKeyRotator(database, core_token.NewTokenParser(database))
+			errs <- otherKeyRotator.RunScheduledRotation(context.Background(), settings, schedule.NextRotation)
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, <-errs)
+	}
+
+	allSigningKeys, err := database.GetAllSigningKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keysByState := map[string]int{}
+	for _, signingKey := range allSigningKeys {
+		keysByState[signingKey.State]++
+	}
+	assert.Equal(t, 1, keysByState[enums.KeyStateCurrent.String()])
+	assert.Equal(t, 1, keysByState[enums.KeyStateNext.String()])
+	assert.Equal(t, 1, keysByState[enums.KeyStatePrevious.String()])
+}
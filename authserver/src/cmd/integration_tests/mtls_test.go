@@ -0,0 +1,244 @@
+package integrationtests
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leodip/goiabada/internal/common"
+	"github.com/leodip/goiabada/internal/core"
+	core_token "github.com/leodip/goiabada/internal/core/token"
+	core_validators "github.com/leodip/goiabada/internal/core/validators"
+	"github.com/leodip/goiabada/internal/customerrors"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+// createTestCertificate creates a certificate for the public key, signed by the parent. When
+// parent is nil the certificate is self-signed
+func createTestCertificate(t *testing.T, subject pkix.Name, isCA bool, publicKey crypto.PublicKey,
+	parent *x509.Certificate, parentKey crypto.Signer) *x509.Certificate {
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               subject,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	if isCA {
+		template.IsCA = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent = template
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, parent, publicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func createTestCA(t *testing.T, commonName string) (*x509.Certificate, crypto.Signer) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert := createTestCertificate(t, pkix.Name{CommonName: commonName}, true, &caKey.PublicKey, nil, caKey)
+	return caCert, caKey
+}
+
+// useTLSClientAuth configures the client to authenticate with a client certificate, and returns
+// a function that puts the client back the way it was
+func useTLSClientAuth(t *testing.T, clientIdentifier string, authMethod enums.TokenEndpointAuthMethod,
+	subjectDN string, jwks string) func() {
+	client, err := database.GetClientByClientIdentifier(nil, clientIdentifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := *client
+
+	client.TokenEndpointAuthMethod = authMethod.String()
+	client.TLSClientAuthSubjectDN = subjectDN
+	client.JWKS = jwks
+	client.JWKSURI = ""
+	err = database.UpdateClient(nil, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		err := database.UpdateClient(nil, &original)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func getSettingsContext(t *testing.T) context.Context {
+	settings, err := database.GetSettingsById(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return context.WithValue(context.Background(), common.ContextKeySettings, settings)
+}
+
+func validateClientCredentialsWithCertificates(ctx context.Context, clientIdentifier string,
+	certificates []*x509.Certificate) (*core_validators.ValidateTokenRequestResult, error) {
+
+	tokenValidator := core_validators.NewTokenValidator(database, core_token.NewTokenParser(database),
+		core.NewPermissionChecker(database))
+	return tokenValidator.ValidateTokenRequest(ctx, &core_validators.ValidateTokenRequestInput{
+		ClientCredentials: core_validators.ClientCredentials{
+			ClientId:           clientIdentifier,
+			ClientCertificates: certificates,
+		},
+		GrantType: "client_credentials",
+	})
+}
+
+func assertInvalidClient(t *testing.T, err error) {
+	valError, ok := err.(*customerrors.ValidationError)
+	if assert.True(t, ok, fmt.Sprintf("expected a validation error, got %v", err)) {
+		assert.Equal(t, "invalid_client", valError.Code)
+	}
+}
+
+func TestMTLS_TLSClientAuth(t *testing.T) {
+	setup()
+
+	caCert, caKey := createTestCA(t, "Test CA")
+	caCertPool := x509.NewCertPool()
+	caCertPool.AddCert(caCert)
+	lib.SetMTLSCACertPool(caCertPool)
+	defer lib.SetMTLSCACertPool(nil)
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject := pkix.Name{CommonName: "test-client-1", Organization: []string{"Goiabada"}}
+	clientCert := createTestCertificate(t, subject, false, &clientKey.PublicKey, caCert, caKey)
+
+	defer useTLSClientAuth(t, "test-client-1", enums.TokenEndpointAuthMethodTLSClientAuth, subject.String(), "")()
+
+	ctx := getSettingsContext(t)
+
+	result, err := validateClientCredentialsWithCertificates(ctx, "test-client-1", []*x509.Certificate{clientCert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "test-client-1", result.Client.ClientIdentifier)
+
+	// no certificate
+	_, err = validateClientCredentialsWithCertificates(ctx, "test-client-1", nil)
+	assertInvalidClient(t, err)
+
+	// a certificate with another subject
+	otherCert := createTestCertificate(t, pkix.Name{CommonName: "other"}, false, &clientKey.PublicKey, caCert, caKey)
+	_, err = validateClientCredentialsWithCertificates(ctx, "test-client-1", []*x509.Certificate{otherCert})
+	assertInvalidClient(t, err)
+
+	// a certificate issued by an untrusted CA
+	untrustedCACert, untrustedCAKey := createTestCA(t, "Untrusted CA")
+	untrustedCert := createTestCertificate(t, subject, false, &clientKey.PublicKey, untrustedCACert, untrustedCAKey)
+	_, err = validateClientCredentialsWithCertificates(ctx, "test-client-1", []*x509.Certificate{untrustedCert})
+	assertInvalidClient(t, err)
+}
+
+func TestMTLS_SelfSignedTLSClientAuth(t *testing.T) {
+	setup()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk, err := lib.MarshalEdDSAPublicKeyToJWK(publicKey, uuid.New().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	selfSignedCert := createTestCertificate(t, pkix.Name{CommonName: "test-client-1"}, false, publicKey, nil, privateKey)
+
+	defer useTLSClientAuth(t, "test-client-1", enums.TokenEndpointAuthMethodSelfSignedTLSClientAuth,
+		"", fmt.Sprintf(`{"keys": [%s]}`, jwk))()
+
+	ctx := getSettingsContext(t)
+
+	result, err := validateClientCredentialsWithCertificates(ctx, "test-client-1", []*x509.Certificate{selfSignedCert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "test-client-1", result.Client.ClientIdentifier)
+
+	// a self-signed certificate with a key that is not registered
+	otherPublicKey, otherPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherCert := createTestCertificate(t, pkix.Name{CommonName: "test-client-1"}, false, otherPublicKey, nil, otherPrivateKey)
+	_, err = validateClientCredentialsWithCertificates(ctx, "test-client-1", []*x509.Certificate{otherCert})
+	assertInvalidClient(t, err)
+}
+
+func TestMTLS_CertificateBoundAccessToken(t *testing.T) {
+	setup()
+
+	caCert, caKey := createTestCA(t, "Test CA")
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCert := createTestCertificate(t, pkix.Name{CommonName: "test-client-1"}, false, &clientKey.PublicKey, caCert, caKey)
+
+	client, err := database.GetClientByClientIdentifier(nil, "test-client-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := getSettingsContext(t)
+	tokenParser := core_token.NewTokenParser(database)
+	tokenIssuer := core_token.NewTokenIssuer(database, tokenParser)
+
+	thumbprint := lib.GetCertificateThumbprint(clientCert)
+	tokenResponse, err := tokenIssuer.GenerateTokenResponseForClientCred(ctx, client, "backend-svcA:create-product",
+		&core_token.TokenConfirmation{X5tS256: thumbprint})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accessToken, err := tokenParser.ParseToken(ctx, tokenResponse.AccessToken, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, thumbprint, accessToken.GetConfirmationClaim()["x5t#S256"])
+
+	// tokens issued without a certificate are not bound
+	tokenResponse, err = tokenIssuer.GenerateTokenResponseForClientCred(ctx, client, "backend-svcA:create-product", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessToken, err = tokenParser.ParseToken(ctx, tokenResponse.AccessToken, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, accessToken.Claims["cnf"])
+}
@@ -0,0 +1,186 @@
+package integrationtests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/leodip/goiabada/internal/constants"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/assert"
+)
+
+func enrollOtpWithRecoveryCodes(t *testing.T, user *entities.User, count int) []string {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      "Goiabada",
+		AccountName: user.Email,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.OTPEnabled = true
+	user.OTPSecret = key.Secret()
+	err = database.UpdateUser(nil, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codes := []string{}
+	for i := 0; i < count; i++ {
+		code := lib.GenerateRecoveryCode()
+		codeHash, err := lib.HashString(lib.NormalizeRecoveryCode(code))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = database.CreateUserRecoveryCode(nil, &entities.UserRecoveryCode{
+			UserId:   user.Id,
+			CodeHash: codeHash,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		codes = append(codes, code)
+	}
+	return codes
+}
+
+func TestOtpRecoveryCodes_NormalizeRecoveryCode(t *testing.T) {
+	code := lib.GenerateRecoveryCode()
+	assert.Len(t, code, 11)
+	assert.Equal(t, "-", code[5:6])
+	assert.Regexp(t, "^[0-9abcdefghjkmnpqrstvwxyz]{5}-[0-9abcdefghjkmnpqrstvwxyz]{5}$", code)
+
+	assert.Equal(t, "abcde12345", lib.NormalizeRecoveryCode("ABCDE-12345"))
+	assert.Equal(t, "abcde12345", lib.NormalizeRecoveryCode(" abcde 12345 "))
+	assert.Equal(t, "", lib.NormalizeRecoveryCode(" - "))
+}
+
+func TestOtpRecoveryCodes_LoginWithRecoveryCode(t *testing.T) {
+	setup()
+
+	user := createLockoutTestUser(t, "abc123")
+	codes := enrollOtpWithRecoveryCodes(t, user, 3)
+
+	httpClient := startAuthorizeWithAcrLevel(t, enums.AcrLevel2)
+
+	resp := authenticateWithPassword(t, httpClient, user.Email, "abc123", "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/otp")
+
+	// codes are accepted regardless of case and separators
+	resp = authenticateWithOtp(t, httpClient, " "+lib.NormalizeRecoveryCode(codes[1])+" ", "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/consent")
+
+	assertLatestUserSession(t, user.Id, enums.AuthMethodPassword.String()+" "+enums.AuthMethodOTP.String(),
+		enums.AcrLevel2)
+
+	userRecoveryCodes, err := database.GetUserRecoveryCodesByUserId(nil, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, userRecoveryCodes, 2)
+
+	// audit events are written to the database asynchronously
+	var auditEvents []entities.AuditEvent
+	assert.Eventually(t, func() bool {
+		auditEvents, _, err = database.SearchAuditEventsPaginated(nil, constants.AuditAuthSuccessRecoveryCode,
+			user.Id, 0, time.Now().UTC().Add(-1*time.Minute), time.Time{}, 1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(auditEvents) == 1
+	}, 5*time.Second, 50*time.Millisecond)
+	if len(auditEvents) == 1 {
+		var details map[string]interface{}
+		err = json.Unmarshal([]byte(auditEvents[0].Details), &details)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, float64(2), details["remainingRecoveryCodes"])
+	}
+}
+
+func TestOtpRecoveryCodes_CodeCanBeUsedOnlyOnce(t *testing.T) {
+	setup()
+
+	// no progressive delay, so that consecutive failures are all evaluated
+	restoreSettings := updateLockoutSettings(t, 0, 900, 0, 0)
+	defer restoreSettings()
+
+	user := createLockoutTestUser(t, "abc123")
+	codes := enrollOtpWithRecoveryCodes(t, user, 2)
+
+	httpClient := startAuthorizeWithAcrLevel(t, enums.AcrLevel2)
+	resp := authenticateWithPassword(t, httpClient, user.Email, "abc123", "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/otp")
+
+	resp = authenticateWithOtp(t, httpClient, codes[0], "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/consent")
+
+	httpClient = startAuthorizeWithAcrLevel(t, enums.AcrLevel2)
+	resp = authenticateWithPassword(t, httpClient, user.Email, "abc123", "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/otp")
+
+	resp = authenticateWithOtp(t, httpClient, codes[0], "")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(body), "Incorrect OTP Code")
+
+	userRecoveryCodes, err := database.GetUserRecoveryCodesByUserId(nil, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, userRecoveryCodes, 1)
+
+	// a code of another user is not accepted either
+	otherUser := createLockoutTestUser(t, "abc123")
+	otherCodes := enrollOtpWithRecoveryCodes(t, otherUser, 1)
+	resp = authenticateWithOtp(t, httpClient, otherCodes[0], "")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	user, err = database.GetUserById(nil, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, user.FailedLoginAttempts)
+}
+
+func TestOtpRecoveryCodes_ConcurrentUseOfTheSameCode(t *testing.T) {
+	setup()
+
+	user := createLockoutTestUser(t, "abc123")
+	enrollOtpWithRecoveryCodes(t, user, 1)
+
+	userRecoveryCodes, err := database.GetUserRecoveryCodesByUserId(nil, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, userRecoveryCodes, 1)
+
+	// two requests read the same code; only the first one to delete it can use it
+	deleted, err := database.DeleteUserRecoveryCode(nil, userRecoveryCodes[0].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, deleted)
+
+	deleted, err = database.DeleteUserRecoveryCode(nil, userRecoveryCodes[0].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, deleted)
+}
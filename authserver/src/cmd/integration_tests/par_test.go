@@ -0,0 +1,209 @@
+package integrationtests
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func getPushedAuthorizationRequestFormData() url.Values {
+	return url.Values{
+		"redirect_uri":          {"https://goiabada-test-client:8090/callback.html"},
+		"response_type":         {"code"},
+		"code_challenge_method": {"S256"},
+		"code_challenge":        {"0BnoD4e6xPCPip8rqZ9Zc2RqWOFfvryu9vzXJN4egoY"},
+		"response_mode":         {"query"},
+		"scope":                 {"openid"},
+		"state":                 {"a1b2c3"},
+		"acr_values":            {enums.AcrLevel1.String()},
+	}
+}
+
+func pushAuthorizationRequest(t *testing.T, formData url.Values) string {
+	resp := postWithBasicAuth(t, lib.GetBaseUrl()+"/auth/par", "test-client-1", getClientSecret(t, "test-client-1"), formData)
+	defer resp.Body.Close()
+
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, float64(60), data["expires_in"])
+
+	requestURI, _ := data["request_uri"].(string)
+	assert.True(t, strings.HasPrefix(requestURI, "urn:ietf:params:oauth:request_uri:"))
+	return requestURI
+}
+
+func getAuthorizeErrorMessage(t *testing.T, httpClient *http.Client, destUrl string) string {
+	resp := getPage(t, httpClient, destUrl)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestPAR_AuthorizationCodeFlow(t *testing.T) {
+	setup()
+
+	user := createLockoutTestUser(t, "abc123")
+	requestURI := pushAuthorizationRequest(t, getPushedAuthorizationRequestFormData())
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	destUrl := lib.GetBaseUrl() + "/auth/authorize/?client_id=test-client-1&request_uri=" + url.QueryEscape(requestURI)
+	resp, err := httpClient.Get(destUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+
+	resp = authenticateWithPassword(t, httpClient, user.Email, "abc123", "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/consent")
+
+	resp = postConsent(t, httpClient, []int{0}, "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/callback.html")
+	code, state := getCodeAndStateFromUrl(t, resp)
+	assert.Equal(t, "a1b2c3", state)
+
+	formData := url.Values{
+		"client_id":     {"test-client-1"},
+		"client_secret": {getClientSecret(t, "test-client-1")},
+		"grant_type":    {"authorization_code"},
+		"redirect_uri":  {"https://goiabada-test-client:8090/callback.html"},
+		"code":          {code},
+		"code_verifier": {"DdazqdVNuDmRLGGRGQKKehEaoFeatACtNsM2UYGwuHkhBhDsTSzaCqWttcBc0kGx"},
+	}
+	data := postToTokenEndpoint(t, httpClient, lib.GetBaseUrl()+"/auth/token", formData)
+	assert.NotEmpty(t, data["access_token"])
+
+	// the request_uri can only be used once
+	body := getAuthorizeErrorMessage(t, httpClient, destUrl)
+	assert.Contains(t, body, "The request_uri parameter is invalid or has expired.")
+}
+
+func TestPAR_InvalidRequest(t *testing.T) {
+	setup()
+
+	destUrl := lib.GetBaseUrl() + "/auth/par"
+	clientSecret := getClientSecret(t, "test-client-1")
+
+	formData := getPushedAuthorizationRequestFormData()
+	formData.Set("scope", "invalid")
+	resp := postWithBasicAuth(t, destUrl, "test-client-1", clientSecret, formData)
+	defer resp.Body.Close()
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "invalid_scope", data["error"])
+
+	formData = getPushedAuthorizationRequestFormData()
+	formData.Set("redirect_uri", "https://example.com/callback")
+	resp = postWithBasicAuth(t, destUrl, "test-client-1", clientSecret, formData)
+	defer resp.Body.Close()
+	data = unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "invalid_request", data["error"])
+
+	formData = getPushedAuthorizationRequestFormData()
+	formData.Set("request_uri", "urn:ietf:params:oauth:request_uri:abc")
+	resp = postWithBasicAuth(t, destUrl, "test-client-1", clientSecret, formData)
+	defer resp.Body.Close()
+	data = unmarshalToMap(t, resp)
+	assert.Equal(t, "invalid_request", data["error"])
+
+	resp = postWithBasicAuth(t, destUrl, "test-client-1", "invalid", getPushedAuthorizationRequestFormData())
+	defer resp.Body.Close()
+	data = unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "invalid_client", data["error"])
+}
+
+func TestPAR_RequestURIOfAnotherClient(t *testing.T) {
+	setup()
+
+	requestURI := pushAuthorizationRequest(t, getPushedAuthorizationRequestFormData())
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+	body := getAuthorizeErrorMessage(t, httpClient,
+		lib.GetBaseUrl()+"/auth/authorize/?client_id=test-client-2&request_uri="+url.QueryEscape(requestURI))
+	assert.Contains(t, body, "The client_id parameter does not match the client that pushed the authorization request.")
+}
+
+func TestPAR_ExpiredRequestURI(t *testing.T) {
+	setup()
+
+	client, err := database.GetClientByClientIdentifier(nil, "test-client-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requestURI := "urn:ietf:params:oauth:request_uri:" + lib.GenerateSecureRandomString(32)
+	requestURIHash, err := lib.HashString(requestURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = database.CreatePushedAuthorizationRequest(nil, &entities.PushedAuthorizationRequest{
+		ClientId:       client.Id,
+		RequestURIHash: requestURIHash,
+		Parameters:     "client_id=test-client-1",
+		ExpiresAt:      sql.NullTime{Time: time.Now().UTC().Add(-time.Minute), Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+	body := getAuthorizeErrorMessage(t, httpClient,
+		lib.GetBaseUrl()+"/auth/authorize/?client_id=test-client-1&request_uri="+url.QueryEscape(requestURI))
+	assert.Contains(t, body, "The request_uri parameter is invalid or has expired.")
+}
+
+func TestPAR_Required(t *testing.T) {
+	setup()
+
+	client, err := database.GetClientByClientIdentifier(nil, "test-client-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.RequirePushedAuthorizationRequests = true
+	err = database.UpdateClient(nil, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		client.RequirePushedAuthorizationRequests = false
+		database.UpdateClient(nil, client)
+	}()
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	params := getPushedAuthorizationRequestFormData()
+	params.Set("client_id", "test-client-1")
+	body := getAuthorizeErrorMessage(t, httpClient, lib.GetBaseUrl()+"/auth/authorize/?"+params.Encode())
+	assert.Contains(t, body, "is required to use pushed authorization requests (PAR)")
+
+	requestURI := pushAuthorizationRequest(t, getPushedAuthorizationRequestFormData())
+	resp := getPage(t, httpClient, lib.GetBaseUrl()+"/auth/authorize/?client_id=test-client-1&request_uri="+url.QueryEscape(requestURI))
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+}
@@ -0,0 +1,231 @@
+package integrationtests
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func getPromptAuthorizeUrl(clientIdentifier string, extraParams url.Values) string {
+	params := url.Values{
+		"client_id":             {clientIdentifier},
+		"redirect_uri":          {"https://goiabada-test-client:8090/callback.html"},
+		"response_type":         {"code"},
+		"code_challenge_method": {"S256"},
+		"code_challenge":        {"0BnoD4e6xPCPip8rqZ9Zc2RqWOFfvryu9vzXJN4egoY"},
+		"response_mode":         {"query"},
+		"scope":                 {"openid profile email"},
+		"state":                 {"a1b2c3"},
+		"nonce":                 {"m9n8b7"},
+	}
+	for name, values := range extraParams {
+		params[name] = values
+	}
+	return lib.GetBaseUrl() + "/auth/authorize/?" + params.Encode()
+}
+
+// loginForPromptTest logs the user in with test-client-2, which doesn't require consent,
+// and returns the http client with the user session and the id token that was issued
+func loginForPromptTest(t *testing.T, email string, password string) (*http.Client, string) {
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	resp := getPage(t, httpClient, getPromptAuthorizeUrl("test-client-2", nil))
+	defer resp.Body.Close()
+
+	resp = authenticateWithPassword(t, httpClient, email, password, "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/consent")
+
+	resp = getPage(t, httpClient, lib.GetBaseUrl()+"/auth/consent")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/callback.html")
+	code, _ := getCodeAndStateFromUrl(t, resp)
+
+	formData := url.Values{
+		"client_id":     {"test-client-2"},
+		"grant_type":    {"authorization_code"},
+		"redirect_uri":  {"https://goiabada-test-client:8090/callback.html"},
+		"code":          {code},
+		"code_verifier": {"DdazqdVNuDmRLGGRGQKKehEaoFeatACtNsM2UYGwuHkhBhDsTSzaCqWttcBc0kGx"},
+	}
+	data := postToTokenEndpoint(t, httpClient, lib.GetBaseUrl()+"/auth/token", formData)
+	idToken, _ := data["id_token"].(string)
+	assert.NotEmpty(t, idToken)
+
+	return httpClient, idToken
+}
+
+func getAuthorizeError(t *testing.T, resp *http.Response) (string, string) {
+	assertRedirect(t, resp, "/callback.html")
+	redirectLocation, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return redirectLocation.Query().Get("error"), redirectLocation.Query().Get("state")
+}
+
+func TestAuthorize_PromptNone_NoSession(t *testing.T) {
+	setup()
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	resp := getPage(t, httpClient, getPromptAuthorizeUrl("test-client-2", url.Values{"prompt": {"none"}}))
+	defer resp.Body.Close()
+
+	errorCode, state := getAuthorizeError(t, resp)
+	assert.Equal(t, "login_required", errorCode)
+	assert.Equal(t, "a1b2c3", state)
+}
+
+func TestAuthorize_PromptNone_WithSession(t *testing.T) {
+	setup()
+
+	user := createLockoutTestUser(t, "abc123")
+	httpClient, _ := loginForPromptTest(t, user.Email, "abc123")
+
+	// silent authentication
+	resp := getPage(t, httpClient, getPromptAuthorizeUrl("test-client-2", url.Values{"prompt": {"none"}}))
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/consent")
+
+	resp = getPage(t, httpClient, lib.GetBaseUrl()+"/auth/consent")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/callback.html")
+	getCodeAndStateFromUrl(t, resp)
+
+	// test-client-1 requires consent, which the user hasn't given yet
+	resp = getPage(t, httpClient, getPromptAuthorizeUrl("test-client-1", url.Values{"prompt": {"none"}}))
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/consent")
+
+	resp = getPage(t, httpClient, lib.GetBaseUrl()+"/auth/consent")
+	defer resp.Body.Close()
+	errorCode, _ := getAuthorizeError(t, resp)
+	assert.Equal(t, "consent_required", errorCode)
+}
+
+func TestAuthorize_PromptLogin(t *testing.T) {
+	setup()
+
+	user := createLockoutTestUser(t, "abc123")
+	httpClient, _ := loginForPromptTest(t, user.Email, "abc123")
+
+	// the session is still valid, but the user must authenticate again
+	resp := getPage(t, httpClient, getPromptAuthorizeUrl("test-client-2", url.Values{"prompt": {"login"}}))
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.NotContains(t, resp.Header.Get("Location"), "/auth/consent")
+
+	resp = authenticateWithPassword(t, httpClient, user.Email, "abc123", "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/consent")
+}
+
+func TestAuthorize_PromptConsent(t *testing.T) {
+	setup()
+
+	user := createLockoutTestUser(t, "abc123")
+	httpClient, _ := loginForPromptTest(t, user.Email, "abc123")
+
+	// test-client-2 doesn't require consent, unless the client asks for it
+	resp := getPage(t, httpClient, getPromptAuthorizeUrl("test-client-2", url.Values{"prompt": {"consent"}}))
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/consent")
+
+	resp = getPage(t, httpClient, lib.GetBaseUrl()+"/auth/consent")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "consent0")
+}
+
+func TestAuthorize_InvalidPrompt(t *testing.T) {
+	setup()
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	for _, prompt := range []string{"invalid", "none login"} {
+		resp := getPage(t, httpClient, getPromptAuthorizeUrl("test-client-2", url.Values{"prompt": {prompt}}))
+		defer resp.Body.Close()
+
+		errorCode, _ := getAuthorizeError(t, resp)
+		assert.Equal(t, "invalid_request", errorCode)
+	}
+}
+
+func TestAuthorize_LoginHint(t *testing.T) {
+	setup()
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	resp := getPage(t, httpClient, getPromptAuthorizeUrl("test-client-2", url.Values{"login_hint": {"hint@example.com"}}))
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, lib.GetBaseUrl()+"/auth/pwd", resp.Header.Get("Location"))
+
+	resp = getPage(t, httpClient, resp.Header.Get("Location"))
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "hint@example.com")
+}
+
+func TestAuthorize_IdTokenHint(t *testing.T) {
+	setup()
+
+	user := createLockoutTestUser(t, "abc123")
+	httpClient, idToken := loginForPromptTest(t, user.Email, "abc123")
+
+	// the session belongs to the user of the id token
+	resp := getPage(t, httpClient, getPromptAuthorizeUrl("test-client-2", url.Values{
+		"prompt":        {"none"},
+		"id_token_hint": {idToken},
+	}))
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/consent")
+
+	// the session belongs to someone else
+	otherUser := createLockoutTestUser(t, "abc123")
+	otherHttpClient, _ := loginForPromptTest(t, otherUser.Email, "abc123")
+
+	resp = getPage(t, otherHttpClient, getPromptAuthorizeUrl("test-client-2", url.Values{
+		"prompt":        {"none"},
+		"id_token_hint": {idToken},
+	}))
+	defer resp.Body.Close()
+	errorCode, _ := getAuthorizeError(t, resp)
+	assert.Equal(t, "login_required", errorCode)
+
+	// without a session, the email of the user is filled in on the login page
+	newHttpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+	resp = getPage(t, newHttpClient, getPromptAuthorizeUrl("test-client-2", url.Values{"id_token_hint": {idToken}}))
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, lib.GetBaseUrl()+"/auth/pwd", resp.Header.Get("Location"))
+
+	resp = getPage(t, newHttpClient, resp.Header.Get("Location"))
+	defer resp.Body.Close()
+	assert.Contains(t, readBody(t, resp), user.Email)
+
+	// an id token issued to another client
+	resp = getPage(t, httpClient, getPromptAuthorizeUrl("test-client-1", url.Values{"id_token_hint": {idToken}}))
+	defer resp.Body.Close()
+	errorCode, _ = getAuthorizeError(t, resp)
+	assert.Equal(t, "invalid_request", errorCode)
+
+	resp = getPage(t, httpClient, getPromptAuthorizeUrl("test-client-2", url.Values{"id_token_hint": {"invalid"}}))
+	defer resp.Body.Close()
+	errorCode, _ = getAuthorizeError(t, resp)
+	assert.Equal(t, "invalid_request", errorCode)
+}
@@ -0,0 +1,136 @@
+package integrationtests
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+	"github.com/leodip/goiabada/internal/data"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecretsAtRest_SigningPrivateKeysAreEncrypted(t *testing.T) {
+	setup()
+
+	settings, err := database.GetSettingsById(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allSigningKeys, err := database.GetAllSigningKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, signingKey := range allSigningKeys {
+		if signingKey.State == enums.KeyStateRevoked.String() {
+			continue
+		}
+		assert.False(t, bytes.HasPrefix(signingKey.PrivateKeyPEMEncrypted, []byte("-----BEGIN")))
+
+		privateKeyPEM, err := lib.DecryptText(signingKey.PrivateKeyPEMEncrypted, settings.AESEncryptionKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = lib.ParseSigningPrivateKeyFromPEM(signingKey.Algorithm, []byte(privateKeyPEM))
+		assert.Nil(t, err)
+	}
+}
+
+func TestSecretsAtRest_PlaintextPrivateKeyIsEncryptedOnStartup(t *testing.T) {
+	setup()
+
+	// a key pair as stored by earlier versions, with the private key in plaintext
+	keyPair := createNewKeyPair(t)
+	privateKeyPEM := getPrivateKeyPEM(t, keyPair)
+	keyPair.State = enums.KeyStatePrevious.String()
+	keyPair.PrivateKeyPEMEncrypted = privateKeyPEM
+	err := database.CreateKeyPair(nil, keyPair)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.DeleteKeyPair(nil, keyPair.Id)
+
+	_, err = data.NewDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storedKeyPair, err := database.GetKeyPairById(nil, keyPair.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEqual(t, privateKeyPEM, storedKeyPair.PrivateKeyPEMEncrypted)
+	assert.Equal(t, privateKeyPEM, getPrivateKeyPEM(t, storedKeyPair))
+}
+
+func TestSecretsAtRest_SettingsKeysWrappedByMasterKey(t *testing.T) {
+	setup()
+
+	settings, err := database.GetSettingsById(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, settings.AESEncryptionKeyWrapped)
+	assert.False(t, settings.SessionKeysWrapped)
+	aesEncryptionKey := settings.AESEncryptionKey
+	sessionAuthenticationKey := settings.SessionAuthenticationKey
+	sessionEncryptionKey := settings.SessionEncryptionKey
+
+	masterKey := securecookie.GenerateRandomKey(32)
+	defer func() {
+		lib.SetMasterKey(masterKey)
+		err := data.RewrapSettingsKeys(database, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	lib.SetMasterKey(masterKey)
+	err = database.UpdateSettings(nil, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, settings.AESEncryptionKeyWrapped)
+	assert.True(t, settings.SessionKeysWrapped)
+	assert.Equal(t, aesEncryptionKey, settings.AESEncryptionKey)
+	assert.Equal(t, sessionAuthenticationKey, settings.SessionAuthenticationKey)
+	assert.Equal(t, sessionEncryptionKey, settings.SessionEncryptionKey)
+
+	settings, err = database.GetSettingsById(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, settings.AESEncryptionKeyWrapped)
+	assert.True(t, settings.SessionKeysWrapped)
+	assert.Equal(t, aesEncryptionKey, settings.AESEncryptionKey)
+	assert.Equal(t, sessionAuthenticationKey, settings.SessionAuthenticationKey)
+	assert.Equal(t, sessionEncryptionKey, settings.SessionEncryptionKey)
+
+	// without the master key, the settings can't be read
+	lib.SetMasterKey(nil)
+	_, err = database.GetSettingsById(nil, 1)
+	assert.ErrorContains(t, err, "GOIABADA_MASTER_KEY_FILE is not set")
+
+	// re-wrapped with a new master key
+	lib.SetMasterKey(masterKey)
+	newMasterKey := securecookie.GenerateRandomKey(32)
+	err = data.RewrapSettingsKeys(database, newMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	masterKey = newMasterKey
+
+	settings, err = database.GetSettingsById(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, aesEncryptionKey, settings.AESEncryptionKey)
+	assert.Equal(t, sessionAuthenticationKey, settings.SessionAuthenticationKey)
+	assert.Equal(t, sessionEncryptionKey, settings.SessionEncryptionKey)
+
+	lib.SetMasterKey(securecookie.GenerateRandomKey(32))
+	_, err = database.GetSettingsById(nil, 1)
+	assert.ErrorContains(t, err, "unable to unwrap the AES encryption key")
+}
@@ -299,9 +299,11 @@ func seedTestData(db data.Database) error {
 		IsPublic:                                false,
 		ClientSecretEncrypted:                   encClientSecret,
 		RedirectURIs:                            []entities.RedirectURI{{URI: "https://goiabada-test-client:8090/callback.html"}, {URI: "https://oauthdebugger.com/debug"}},
+		PostLogoutRedirectURIs:                  []entities.PostLogoutRedirectURI{{URI: "https://goiabada-test-client:8090/callback.html"}, {URI: "https://oauthdebugger.com/debug"}},
 		Permissions:                             []entities.Permission{*permission1, *permission3},
 		DefaultAcrLevel:                         enums.AcrLevel2,
 		IncludeOpenIDConnectClaimsInAccessToken: enums.ThreeStateSettingDefault.String(),
+		RefreshTokenRotationEnabled:             enums.ThreeStateSettingDefault.String(),
 		AuthorizationCodeEnabled:                true,
 		ClientCredentialsEnabled:                true,
 	}
@@ -318,6 +320,14 @@ func seedTestData(db data.Database) error {
 		}
 	}
 
+	for _, uri := range client.PostLogoutRedirectURIs {
+		uri.ClientId = client.Id
+		err = db.CreatePostLogoutRedirectURI(nil, &uri)
+		if err != nil {
+			return err
+		}
+	}
+
 	for _, perm := range client.Permissions {
 		err = db.CreateClientPermission(nil, &entities.ClientPermission{
 			ClientId:     client.Id,
@@ -335,8 +345,10 @@ func seedTestData(db data.Database) error {
 		ConsentRequired:                         false,
 		IsPublic:                                true,
 		RedirectURIs:                            []entities.RedirectURI{{URI: "https://goiabada-test-client:8090/callback.html"}, {URI: "https://oauthdebugger.com/debug"}},
+		PostLogoutRedirectURIs:                  []entities.PostLogoutRedirectURI{{URI: "https://goiabada-test-client:8090/callback.html"}, {URI: "https://oauthdebugger.com/debug"}},
 		DefaultAcrLevel:                         enums.AcrLevel2,
 		IncludeOpenIDConnectClaimsInAccessToken: enums.ThreeStateSettingDefault.String(),
+		RefreshTokenRotationEnabled:             enums.ThreeStateSettingDefault.String(),
 		AuthorizationCodeEnabled:                true,
 		ClientCredentialsEnabled:                false,
 	}
@@ -353,6 +365,14 @@ func seedTestData(db data.Database) error {
 		}
 	}
 
+	for _, uri := range client.PostLogoutRedirectURIs {
+		uri.ClientId = client.Id
+		err = db.CreatePostLogoutRedirectURI(nil, &uri)
+		if err != nil {
+			return err
+		}
+	}
+
 	client = &entities.Client{
 		ClientIdentifier:                        "test-client-3",
 		Description:                             "Test client 3 (integration tests)",
@@ -360,8 +380,10 @@ func seedTestData(db data.Database) error {
 		ConsentRequired:                         false,
 		IsPublic:                                true,
 		RedirectURIs:                            []entities.RedirectURI{{URI: "https://goiabada-test-client:8090/callback.html"}, {URI: "https://oauthdebugger.com/debug"}},
+		PostLogoutRedirectURIs:                  []entities.PostLogoutRedirectURI{{URI: "https://goiabada-test-client:8090/callback.html"}, {URI: "https://oauthdebugger.com/debug"}},
 		DefaultAcrLevel:                         enums.AcrLevel2,
 		IncludeOpenIDConnectClaimsInAccessToken: enums.ThreeStateSettingDefault.String(),
+		RefreshTokenRotationEnabled:             enums.ThreeStateSettingDefault.String(),
 		AuthorizationCodeEnabled:                true,
 		ClientCredentialsEnabled:                false,
 	}
@@ -378,6 +400,14 @@ func seedTestData(db data.Database) error {
 		}
 	}
 
+	for _, uri := range client.PostLogoutRedirectURIs {
+		uri.ClientId = client.Id
+		err = db.CreatePostLogoutRedirectURI(nil, &uri)
+		if err != nil {
+			return err
+		}
+	}
+
 	settings.SMTPHost = "mailhog"
 	settings.SMTPPort = 1025
 	settings.SMTPFromName = "Goiabada"
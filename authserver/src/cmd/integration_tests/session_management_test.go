@@ -0,0 +1,122 @@
+package integrationtests
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/leodip/goiabada/internal/common"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+// addWebOrigin adds a web origin to test-client-2, and returns a function that removes it
+func addWebOrigin(t *testing.T, origin string) func() {
+	client, err := database.GetClientByClientIdentifier(nil, "test-client-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	webOrigin := &entities.WebOrigin{
+		ClientId: client.Id,
+		Origin:   origin,
+	}
+	err = database.CreateWebOrigin(nil, webOrigin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		err := database.DeleteWebOrigin(nil, webOrigin.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func getBrowserStateCookie(t *testing.T, httpClient *http.Client) string {
+	baseUrl, err := url.Parse(lib.GetBaseUrl())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cookie := range httpClient.Jar.Cookies(baseUrl) {
+		if cookie.Name == common.BrowserStateCookieName {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+func getSessionStateFromAuthorize(t *testing.T, httpClient *http.Client) string {
+	resp := getPage(t, httpClient, getPromptAuthorizeUrl("test-client-2", url.Values{"prompt": {"none"}}))
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/consent")
+
+	resp = getPage(t, httpClient, lib.GetBaseUrl()+"/auth/consent")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/callback.html")
+
+	redirectLocation, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return redirectLocation.Query().Get("session_state")
+}
+
+func TestSessionManagement_SessionState(t *testing.T) {
+	setup()
+	defer addWebOrigin(t, "https://goiabada-test-client:8090")()
+
+	user := createLockoutTestUser(t, "abc123")
+	httpClient, _ := loginForPromptTest(t, user.Email, "abc123")
+
+	browserState := getBrowserStateCookie(t, httpClient)
+	assert.NotEmpty(t, browserState)
+
+	sessionState := getSessionStateFromAuthorize(t, httpClient)
+	parts := strings.SplitN(sessionState, ".", 2)
+	if !assert.Len(t, parts, 2) {
+		return
+	}
+
+	// the relying party can compute the same value, with the salt that follows the dot
+	expected, err := lib.GetSessionState("test-client-2", "https://goiabada-test-client:8090", browserState, parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, expected, sessionState)
+
+	resp := getPage(t, httpClient, lib.GetBaseUrl()+"/auth/check_session_iframe")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), common.BrowserStateCookieName)
+
+	// the browser state cookie is removed on logout
+	postLogout(t, httpClient)
+	assert.Empty(t, getBrowserStateCookie(t, httpClient))
+}
+
+func TestSessionManagement_OriginNotAllowed(t *testing.T) {
+	setup()
+
+	user := createLockoutTestUser(t, "abc123")
+	httpClient, _ := loginForPromptTest(t, user.Email, "abc123")
+
+	// the origin of the redirect URI is not one of the web origins of the client
+	assert.Empty(t, getSessionStateFromAuthorize(t, httpClient))
+}
+
+func TestSessionManagement_Discovery(t *testing.T) {
+	setup()
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	resp := getPage(t, httpClient, lib.GetBaseUrl()+"/.well-known/openid-configuration")
+	defer resp.Body.Close()
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, lib.GetBaseUrl()+"/auth/check_session_iframe", data["check_session_iframe"])
+}
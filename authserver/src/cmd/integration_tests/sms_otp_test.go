@@ -0,0 +1,252 @@
+package integrationtests
+
+import (
+	"bufio"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leodip/goiabada/internal/constants"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func updateSMSOtpSettings(t *testing.T, allowedAcrLevel2 bool, allowedAcrLevel3 bool) func() {
+	settings, err := database.GetSettingsById(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	originalSMSProvider := settings.SMSProvider
+	settings.SMSProvider = "test"
+	err = database.UpdateSettings(nil, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := database.GetClientByClientIdentifier(nil, "test-client-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	originalClient := *client
+	client.SMSOTPAllowedAcrLevel2 = allowedAcrLevel2
+	client.SMSOTPAllowedAcrLevel3 = allowedAcrLevel3
+	err = database.UpdateClient(nil, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		settings.SMSProvider = originalSMSProvider
+		err := database.UpdateSettings(nil, settings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = database.UpdateClient(nil, &originalClient)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func createUserWithVerifiedPhone(t *testing.T) *entities.User {
+	user := createLockoutTestUser(t, "abc123")
+	user.PhoneNumber = "+1 555 " + strings.ReplaceAll(uuid.New().String()[:8], "-", "")
+	user.PhoneNumberVerified = true
+	err := database.UpdateUser(nil, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// getLastSMSOtpCode returns the last code sent to the phone number by the test SMS provider
+func getLastSMSOtpCode(t *testing.T, phoneNumber string) string {
+	file, err := os.Open(filepath.Join(os.TempDir(), "sms_messages.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	codeRegex := regexp.MustCompile(`sign-in code is (\d{6})`)
+	code := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		parts := strings.Split(scanner.Text(), "|")
+		if len(parts) == 2 && parts[0] == phoneNumber {
+			matches := codeRegex.FindStringSubmatch(parts[1])
+			if len(matches) == 2 {
+				code = matches[1]
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestSMSOtp_LoginWithCodeBySMS(t *testing.T) {
+	setup()
+
+	restoreSMSOtpSettings := updateSMSOtpSettings(t, false, true)
+	defer restoreSMSOtpSettings()
+
+	// the user is not enrolled in OTP, and picks SMS instead of enrolling an authenticator app
+	user := createUserWithVerifiedPhone(t)
+
+	httpClient := startAuthorizeWithAcrLevel(t, enums.AcrLevel3)
+	resp := authenticateWithPassword(t, httpClient, user.Email, "abc123", "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/otp")
+
+	resp = getPage(t, httpClient, lib.GetBaseUrl()+"/auth/otp")
+	defer resp.Body.Close()
+	assert.Contains(t, readBody(t, resp), "Send me a code by SMS instead")
+
+	resp, err := httpClient.Post(lib.GetBaseUrl()+"/auth/otp/sms", "application/x-www-form-urlencoded", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/otp")
+
+	resp = getPage(t, httpClient, lib.GetBaseUrl()+"/auth/otp")
+	defer resp.Body.Close()
+	assert.Contains(t, readBody(t, resp), "We sent a six-digit code by SMS")
+
+	code := getLastSMSOtpCode(t, user.PhoneNumber)
+	if !assert.Len(t, code, 6) {
+		return
+	}
+
+	resp = authenticateWithOtp(t, httpClient, code, "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/consent")
+
+	assertLatestUserSession(t, user.Id, enums.AuthMethodPassword.String()+" "+enums.AuthMethodOTP.String(),
+		enums.AcrLevel3)
+
+	// audit events are written to the database asynchronously
+	var auditEvents []entities.AuditEvent
+	assert.Eventually(t, func() bool {
+		var err error
+		auditEvents, _, err = database.SearchAuditEventsPaginated(nil, constants.AuditSentOTPCode,
+			user.Id, 0, time.Now().UTC().Add(-1*time.Minute), time.Time{}, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(auditEvents) == 1
+	}, 5*time.Second, 50*time.Millisecond)
+	if len(auditEvents) == 1 {
+		assert.Contains(t, auditEvents[0].Details, `"otpMethod":"sms"`)
+	}
+}
+
+func TestSMSOtp_WrongCodeCountsAsFailedAttempt(t *testing.T) {
+	setup()
+
+	restoreSettings := updateLockoutSettings(t, 0, 900, 0, 0)
+	defer restoreSettings()
+
+	restoreSMSOtpSettings := updateSMSOtpSettings(t, false, true)
+	defer restoreSMSOtpSettings()
+
+	// the user is not enrolled in OTP; a code sent by SMS still counts as a second factor
+	user := createUserWithVerifiedPhone(t)
+	assert.False(t, user.OTPEnabled)
+
+	httpClient := startAuthorizeWithAcrLevel(t, enums.AcrLevel3)
+	resp := authenticateWithPassword(t, httpClient, user.Email, "abc123", "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/otp")
+
+	resp, err := httpClient.Post(lib.GetBaseUrl()+"/auth/otp/sms", "application/x-www-form-urlencoded", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/otp")
+
+	code := getLastSMSOtpCode(t, user.PhoneNumber)
+	if !assert.Len(t, code, 6) {
+		return
+	}
+	wrongCode := "000000"
+	if code == wrongCode {
+		wrongCode = "111111"
+	}
+
+	resp = authenticateWithOtp(t, httpClient, wrongCode, "")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "Incorrect or expired code")
+
+	user, err = database.GetUserById(nil, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, user.FailedLoginAttempts)
+}
+
+func TestSMSOtp_NotAllowedForAcrLevel(t *testing.T) {
+	setup()
+
+	restoreSMSOtpSettings := updateSMSOtpSettings(t, false, true)
+	defer restoreSMSOtpSettings()
+
+	user := createUserWithVerifiedPhone(t)
+	enableEmailOtpWithPendingCode(t, user, "123456", time.Now().UTC())
+
+	// SMS is allowed for level 3 only
+	httpClient := startAuthorizeWithAcrLevel(t, enums.AcrLevel2)
+	resp := authenticateWithPassword(t, httpClient, user.Email, "abc123", "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/otp")
+
+	resp = getPage(t, httpClient, lib.GetBaseUrl()+"/auth/otp")
+	defer resp.Body.Close()
+	assert.NotContains(t, readBody(t, resp), "Send me a code by SMS instead")
+
+	resp, err := httpClient.Post(lib.GetBaseUrl()+"/auth/otp/sms", "application/x-www-form-urlencoded", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "Receiving the code by SMS is not available.")
+}
+
+func TestSMSOtp_RequiresVerifiedPhone(t *testing.T) {
+	setup()
+
+	restoreSMSOtpSettings := updateSMSOtpSettings(t, true, true)
+	defer restoreSMSOtpSettings()
+
+	user := createUserWithVerifiedPhone(t)
+	user.PhoneNumberVerified = false
+	err := database.UpdateUser(nil, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpClient := startAuthorizeWithAcrLevel(t, enums.AcrLevel3)
+	resp := authenticateWithPassword(t, httpClient, user.Email, "abc123", "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/otp")
+
+	resp, err = httpClient.Post(lib.GetBaseUrl()+"/auth/otp/sms", "application/x-www-form-urlencoded", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "Receiving the code by SMS is not available.")
+	assert.Empty(t, getLastSMSOtpCode(t, user.PhoneNumber))
+}
@@ -0,0 +1,216 @@
+package integrationtests
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func postToIntrospectionEndpoint(t *testing.T, client *http.Client, formData url.Values) *http.Response {
+	destUrl := lib.GetBaseUrl() + "/auth/introspect"
+
+	request, err := http.NewRequest("POST", destUrl, strings.NewReader(formData.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestTokenIntrospection_PublicClientNotAllowed(t *testing.T) {
+	setup()
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	formData := url.Values{
+		"client_id": {"test-client-2"},
+		"token":     {"abc"},
+	}
+	resp := postToIntrospectionEndpoint(t, httpClient, formData)
+	defer resp.Body.Close()
+
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "unauthorized_client", data["error"])
+	assert.Equal(t, "Public clients are not allowed to use the introspection endpoint.", data["error_description"])
+}
+
+func TestTokenIntrospection_ClientAuthFailed(t *testing.T) {
+	setup()
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	formData := url.Values{
+		"client_id":     {"test-client-1"},
+		"client_secret": {"invalid"},
+		"token":         {"abc"},
+	}
+	resp := postToIntrospectionEndpoint(t, httpClient, formData)
+	defer resp.Body.Close()
+
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, "invalid_client", data["error"])
+	assert.Equal(t, "Client authentication failed.", data["error_description"])
+}
+
+func TestTokenIntrospection_MissingToken(t *testing.T) {
+	setup()
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	formData := url.Values{
+		"client_id":     {"test-client-1"},
+		"client_secret": {getClientSecret(t, "test-client-1")},
+	}
+	resp := postToIntrospectionEndpoint(t, httpClient, formData)
+	defer resp.Body.Close()
+
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, "invalid_request", data["error"])
+	assert.Equal(t, "Missing required token parameter.", data["error_description"])
+}
+
+func TestTokenIntrospection_InvalidToken(t *testing.T) {
+	setup()
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	formData := url.Values{
+		"client_id":     {"test-client-1"},
+		"client_secret": {getClientSecret(t, "test-client-1")},
+		"token":         {"this-is-not-a-valid-token"},
+	}
+	resp := postToIntrospectionEndpoint(t, httpClient, formData)
+	defer resp.Body.Close()
+
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, false, data["active"])
+	assert.Len(t, data, 1)
+}
+
+func TestTokenIntrospection_AccessToken(t *testing.T) {
+	setup()
+
+	respData, httpClient, clientSecret := issueTokensForRevocationTest(t)
+
+	formData := url.Values{
+		"client_id":       {"test-client-1"},
+		"client_secret":   {clientSecret},
+		"token":           {respData["access_token"].(string)},
+		"token_type_hint": {"access_token"},
+	}
+	resp := postToIntrospectionEndpoint(t, httpClient, formData)
+	defer resp.Body.Close()
+
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, true, data["active"])
+	assert.Equal(t, "Bearer", data["token_type"])
+	assert.Equal(t, "test-client-1", data["client_id"])
+	assert.Equal(t, respData["scope"], data["scope"])
+	assert.NotEmpty(t, data["sub"])
+	assert.NotEmpty(t, data["sid"])
+	assert.NotEmpty(t, data["exp"])
+	assert.NotEmpty(t, data["iat"])
+	assert.NotEmpty(t, data["jti"])
+}
+
+func TestTokenIntrospection_ClientCredentialsAccessToken(t *testing.T) {
+	setup()
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	clientSecret := getClientSecret(t, "test-client-1")
+	formData := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"test-client-1"},
+		"client_secret": {clientSecret},
+		"scope":         {"backend-svcA:create-product"},
+	}
+	respData := postToTokenEndpoint(t, httpClient, lib.GetBaseUrl()+"/auth/token", formData)
+	assert.NotEmpty(t, respData["access_token"])
+
+	formData = url.Values{
+		"client_id":     {"test-client-1"},
+		"client_secret": {clientSecret},
+		"token":         {respData["access_token"].(string)},
+	}
+	resp := postToIntrospectionEndpoint(t, httpClient, formData)
+	defer resp.Body.Close()
+
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, true, data["active"])
+	assert.Equal(t, "test-client-1", data["client_id"])
+	assert.Equal(t, "test-client-1", data["sub"])
+	assert.Equal(t, "backend-svcA:create-product", data["scope"])
+	assert.Equal(t, "backend-svcA", data["aud"])
+}
+
+func TestTokenIntrospection_IdTokenIsInactive(t *testing.T) {
+	setup()
+
+	respData, httpClient, clientSecret := issueTokensForRevocationTest(t)
+
+	formData := url.Values{
+		"client_id":     {"test-client-1"},
+		"client_secret": {clientSecret},
+		"token":         {respData["id_token"].(string)},
+	}
+	resp := postToIntrospectionEndpoint(t, httpClient, formData)
+	defer resp.Body.Close()
+
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, false, data["active"])
+}
+
+func TestTokenIntrospection_RefreshToken(t *testing.T) {
+	setup()
+
+	respData, httpClient, clientSecret := issueTokensForRevocationTest(t)
+	refreshToken := respData["refresh_token"].(string)
+
+	formData := url.Values{
+		"client_id":       {"test-client-1"},
+		"client_secret":   {clientSecret},
+		"token":           {refreshToken},
+		"token_type_hint": {"refresh_token"},
+	}
+	resp := postToIntrospectionEndpoint(t, httpClient, formData)
+	defer resp.Body.Close()
+
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, true, data["active"])
+	assert.Equal(t, "Refresh", data["token_type"])
+	assert.Equal(t, "test-client-1", data["client_id"])
+
+	// once revoked, the refresh token is no longer active
+	resp = postToRevocationEndpoint(t, httpClient, formData)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = postToIntrospectionEndpoint(t, httpClient, formData)
+	defer resp.Body.Close()
+
+	data = unmarshalToMap(t, resp)
+	assert.Equal(t, false, data["active"])
+}
@@ -0,0 +1,205 @@
+package integrationtests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	core_token "github.com/leodip/goiabada/internal/core/token"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func postToRevocationEndpoint(t *testing.T, client *http.Client, formData url.Values) *http.Response {
+	destUrl := lib.GetBaseUrl() + "/auth/revoke"
+
+	request, err := http.NewRequest("POST", destUrl, strings.NewReader(formData.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func issueTokensForRevocationTest(t *testing.T) (map[string]interface{}, *http.Client, string) {
+	scope := "openid profile email backend-svcA:read-product"
+	code, httpClient := createAuthCode(t, scope)
+
+	clientSecret := getClientSecret(t, "test-client-1")
+
+	formData := url.Values{
+		"client_id":     {"test-client-1"},
+		"client_secret": {clientSecret},
+		"grant_type":    {"authorization_code"},
+		"redirect_uri":  {code.RedirectURI},
+		"code":          {code.Code},
+		"code_verifier": {"DdazqdVNuDmRLGGRGQKKehEaoFeatACtNsM2UYGwuHkhBhDsTSzaCqWttcBc0kGx"},
+	}
+	respData := postToTokenEndpoint(t, httpClient, lib.GetBaseUrl()+"/auth/token", formData)
+	assert.NotEmpty(t, respData["refresh_token"])
+
+	return respData, httpClient, clientSecret
+}
+
+func TestTokenRevocation_MissingToken(t *testing.T) {
+	setup()
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	formData := url.Values{
+		"client_id":     {"test-client-1"},
+		"client_secret": {getClientSecret(t, "test-client-1")},
+	}
+	resp := postToRevocationEndpoint(t, httpClient, formData)
+	defer resp.Body.Close()
+
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "invalid_request", data["error"])
+	assert.Equal(t, "Missing required token parameter.", data["error_description"])
+}
+
+func TestTokenRevocation_ClientAuthFailed(t *testing.T) {
+	setup()
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	formData := url.Values{
+		"client_id":     {"test-client-1"},
+		"client_secret": {"invalid"},
+		"token":         {"abc"},
+	}
+	resp := postToRevocationEndpoint(t, httpClient, formData)
+	defer resp.Body.Close()
+
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, "invalid_client", data["error"])
+	assert.Equal(t, "Client authentication failed.", data["error_description"])
+}
+
+func TestTokenRevocation_InvalidTokenIsIgnored(t *testing.T) {
+	setup()
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	formData := url.Values{
+		"client_id":     {"test-client-1"},
+		"client_secret": {getClientSecret(t, "test-client-1")},
+		"token":         {"this-is-not-a-valid-token"},
+	}
+	resp := postToRevocationEndpoint(t, httpClient, formData)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestTokenRevocation_AccessTokenNotSupported(t *testing.T) {
+	setup()
+
+	respData, httpClient, clientSecret := issueTokensForRevocationTest(t)
+
+	formData := url.Values{
+		"client_id":       {"test-client-1"},
+		"client_secret":   {clientSecret},
+		"token":           {respData["access_token"].(string)},
+		"token_type_hint": {"access_token"},
+	}
+	resp := postToRevocationEndpoint(t, httpClient, formData)
+	defer resp.Body.Close()
+
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, "unsupported_token_type", data["error"])
+}
+
+func TestTokenRevocation_RefreshToken(t *testing.T) {
+	setup()
+
+	respData, httpClient, clientSecret := issueTokensForRevocationTest(t)
+	refreshToken := respData["refresh_token"].(string)
+
+	formData := url.Values{
+		"client_id":       {"test-client-1"},
+		"client_secret":   {clientSecret},
+		"token":           {refreshToken},
+		"token_type_hint": {"refresh_token"},
+	}
+	resp := postToRevocationEndpoint(t, httpClient, formData)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, body)
+
+	formData = url.Values{
+		"client_id":     {"test-client-1"},
+		"client_secret": {clientSecret},
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	}
+	refreshData := postToTokenEndpoint(t, httpClient, lib.GetBaseUrl()+"/auth/token", formData)
+
+	assert.Equal(t, "invalid_grant", refreshData["error"])
+	assert.Equal(t, "This refresh token has been revoked.", refreshData["error_description"])
+}
+
+func TestTokenRevocation_RefreshTokenChain(t *testing.T) {
+	setup()
+
+	respData, httpClient, clientSecret := issueTokensForRevocationTest(t)
+
+	formData := url.Values{
+		"client_id":     {"test-client-1"},
+		"client_secret": {clientSecret},
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {respData["refresh_token"].(string)},
+	}
+	respData2 := postToTokenEndpoint(t, httpClient, lib.GetBaseUrl()+"/auth/token", formData)
+	assert.NotEmpty(t, respData2["refresh_token"])
+
+	formData = url.Values{
+		"client_id":     {"test-client-1"},
+		"client_secret": {clientSecret},
+		"token":         {respData2["refresh_token"].(string)},
+		"revoke_chain":  {"true"},
+	}
+	resp := postToRevocationEndpoint(t, httpClient, formData)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	tokenParser := core_token.NewTokenParser(database)
+	refreshTokenJwt, err := tokenParser.ParseToken(context.Background(), respData2["refresh_token"].(string), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refreshTokenInfo, err := database.GetRefreshTokenByJti(nil, refreshTokenJwt.GetStringClaim("jti"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := database.GetRefreshTokensByFirstRefreshTokenJti(nil, refreshTokenInfo.FirstRefreshTokenJti)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, chain, 2)
+	for _, rt := range chain {
+		assert.True(t, rt.Revoked)
+	}
+}
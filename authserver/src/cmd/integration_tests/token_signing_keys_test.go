@@ -0,0 +1,419 @@
+package integrationtests
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	core_token "github.com/leodip/goiabada/internal/core/token"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func createSigningKeyWithState(t *testing.T, keyState enums.KeyState) *entities.KeyPair {
+	keyPair := createNewKeyPair(t)
+	keyPair.State = keyState.String()
+	err := database.CreateKeyPair(nil, keyPair)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyPair
+}
+
+// waitForServerToReloadKeys waits until a key created behind the server's back can be found
+// by the server, which reloads its keys for an unknown kid at most once every 10 seconds
+func waitForServerToReloadKeys() {
+	time.Sleep(11 * time.Second)
+}
+
+func signTokenWithKeyPair(t *testing.T, keyPair *entities.KeyPair, claims jwt.MapClaims) string {
+	privKey, err := jwt.ParseRSAPrivateKeyFromPEM(getPrivateKeyPEM(t, keyPair))
+	if err != nil {
+		t.Fatal("unable to parse private key from PEM")
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyPair.KeyIdentifier
+	tokenStr, err := token.SignedString(privKey)
+	if err != nil {
+		t.Fatal("unable to sign token")
+	}
+	return tokenStr
+}
+
+func getRefreshTokenClaims(t *testing.T) jwt.MapClaims {
+	settings, err := database.GetSettingsById(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	claims := make(jwt.MapClaims)
+	claims["iss"] = settings.Issuer
+	claims["iat"] = now.Unix()
+	claims["jti"] = uuid.New().String()
+	claims["aud"] = settings.Issuer
+	claims["typ"] = enums.TokenTypeRefresh.String()
+	claims["exp"] = now.Add(time.Hour).Unix()
+	return claims
+}
+
+func TestTokenSigningKeys_TokenSignedWithPreviousKeyIsAccepted(t *testing.T) {
+	setup()
+
+	keyPair := createSigningKeyWithState(t, enums.KeyStatePrevious)
+	defer database.DeleteKeyPair(nil, keyPair.Id)
+	waitForServerToReloadKeys()
+
+	now := time.Now().UTC()
+	claims := jwt.MapClaims{
+		"iss":       lib.GetBaseUrl(),
+		"iat":       now.Unix(),
+		"exp":       now.Add(time.Hour).Unix(),
+		"jti":       uuid.New().String(),
+		"typ":       enums.TokenTypeBearer.String(),
+		"sub":       "test-client-1",
+		"client_id": "test-client-1",
+	}
+	accessToken := signTokenWithKeyPair(t, keyPair, claims)
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+	formData := url.Values{
+		"client_id":     {"test-client-1"},
+		"client_secret": {getClientSecret(t, "test-client-1")},
+		"token":         {accessToken},
+	}
+	resp := postToIntrospectionEndpoint(t, httpClient, formData)
+	defer resp.Body.Close()
+
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, true, data["active"])
+	assert.Equal(t, claims["jti"], data["jti"])
+}
+
+func TestTokenSigningKeys_TokenSignedWithRevokedKeyIsRejected(t *testing.T) {
+	setup()
+
+	keyPair := createSigningKeyWithState(t, enums.KeyStateRevoked)
+	defer database.DeleteKeyPair(nil, keyPair.Id)
+	waitForServerToReloadKeys()
+
+	refreshToken := signTokenWithKeyPair(t, keyPair, getRefreshTokenClaims(t))
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+	formData := url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {"test-client-1"},
+		"client_secret": {getClientSecret(t, "test-client-1")},
+		"refresh_token": {refreshToken},
+	}
+	respData := postToTokenEndpoint(t, httpClient, lib.GetBaseUrl()+"/auth/token", formData)
+	assert.Equal(t, "invalid_grant", respData["error"])
+	assert.Contains(t, respData["error_description"], "the token was signed with the revoked key "+keyPair.KeyIdentifier)
+}
+
+func TestTokenSigningKeys_TokenSignedWithUnknownKeyIsRejected(t *testing.T) {
+	setup()
+
+	// the key pair is never stored
+	keyPair := createNewKeyPair(t)
+	refreshToken := signTokenWithKeyPair(t, keyPair, getRefreshTokenClaims(t))
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+	formData := url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {"test-client-1"},
+		"client_secret": {getClientSecret(t, "test-client-1")},
+		"refresh_token": {refreshToken},
+	}
+	respData := postToTokenEndpoint(t, httpClient, lib.GetBaseUrl()+"/auth/token", formData)
+	assert.Equal(t, "invalid_grant", respData["error"])
+	assert.Contains(t, respData["error_description"], "unknown signing key "+keyPair.KeyIdentifier)
+}
+
+func TestTokenSigningKeys_KeySetIsReloadedWhenInvalidated(t *testing.T) {
+	setup()
+
+	keyPair := createSigningKeyWithState(t, enums.KeyStatePrevious)
+	defer database.DeleteKeyPair(nil, keyPair.Id)
+
+	refreshToken := signTokenWithKeyPair(t, keyPair, getRefreshTokenClaims(t))
+
+	tokenParser := core_token.NewTokenParser(database)
+	refreshTokenJwt, err := tokenParser.ParseToken(context.Background(), refreshToken, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, refreshTokenJwt.SignatureIsValid)
+
+	keyPair.State = enums.KeyStateRevoked.String()
+	err = database.UpdateKeyPair(nil, keyPair)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// still cached
+	_, err = tokenParser.ParseToken(context.Background(), refreshToken, true)
+	assert.Nil(t, err)
+
+	tokenParser.InvalidateKeySet()
+	_, err = tokenParser.ParseToken(context.Background(), refreshToken, true)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "the token was signed with the revoked key "+keyPair.KeyIdentifier)
+	}
+}
+
+func TestTokenSigningKeys_UnknownKidDoesNotReloadTheKeysEveryTime(t *testing.T) {
+	setup()
+
+	tokenParser := core_token.NewTokenParser(database)
+
+	// load the key set
+	keyPair := createSigningKeyWithState(t, enums.KeyStatePrevious)
+	defer database.DeleteKeyPair(nil, keyPair.Id)
+	_, err := tokenParser.ParseToken(context.Background(), signTokenWithKeyPair(t, keyPair, getRefreshTokenClaims(t)), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a key that is created right after the key set was loaded is not found, because
+	// reloads triggered by unknown kids are rate limited
+	newKeyPair := createSigningKeyWithState(t, enums.KeyStatePrevious)
+	defer database.DeleteKeyPair(nil, newKeyPair.Id)
+	refreshToken := signTokenWithKeyPair(t, newKeyPair, getRefreshTokenClaims(t))
+
+	_, err = tokenParser.ParseToken(context.Background(), refreshToken, true)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "unknown signing key "+newKeyPair.KeyIdentifier)
+	}
+
+	tokenParser.InvalidateKeySet()
+	_, err = tokenParser.ParseToken(context.Background(), refreshToken, true)
+	assert.Nil(t, err)
+}
+
+// issueTokensForNewUser runs the authorization code flow (scope openid) for a new user
+func issueTokensForNewUser(t *testing.T) map[string]interface{} {
+	httpClient, formData := getTokenRequestForNewUser(t)
+	return postToTokenEndpoint(t, httpClient, lib.GetBaseUrl()+"/auth/token", formData)
+}
+
+func getTokenRequestForNewUser(t *testing.T) (*http.Client, url.Values) {
+	user := createLockoutTestUser(t, "abc123")
+
+	destUrl := lib.GetBaseUrl() +
+		"/auth/authorize/?client_id=test-client-1&redirect_uri=https://goiabada-test-client:8090/callback.html&response_type=code" +
+		"&code_challenge_method=S256&code_challenge=0BnoD4e6xPCPip8rqZ9Zc2RqWOFfvryu9vzXJN4egoY" +
+		"&response_mode=query&scope=openid&state=a1b2c3&acr_values=" + enums.AcrLevel1.String()
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	resp, err := httpClient.Get(destUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+
+	resp = authenticateWithPassword(t, httpClient, user.Email, "abc123", "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/auth/consent")
+
+	resp = postConsent(t, httpClient, []int{0}, "")
+	defer resp.Body.Close()
+	assertRedirect(t, resp, "/callback.html")
+	code, _ := getCodeAndStateFromUrl(t, resp)
+
+	formData := url.Values{
+		"client_id":     {"test-client-1"},
+		"client_secret": {getClientSecret(t, "test-client-1")},
+		"grant_type":    {"authorization_code"},
+		"redirect_uri":  {"https://goiabada-test-client:8090/callback.html"},
+		"code":          {code},
+		"code_verifier": {"DdazqdVNuDmRLGGRGQKKehEaoFeatACtNsM2UYGwuHkhBhDsTSzaCqWttcBc0kGx"},
+	}
+	return httpClient, formData
+}
+
+func TestTokenSigningKeys_IdTokenSignedWithAlgorithmRequestedByClient(t *testing.T) {
+	setup()
+
+	client, err := database.GetClientByClientIdentifier(nil, "test-client-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.IdTokenSignedResponseAlg = enums.SigningAlgorithmRS256.String()
+	err = database.UpdateClient(nil, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		client.IdTokenSignedResponseAlg = ""
+		database.UpdateClient(nil, client)
+	}()
+
+	currentKey, err := database.GetCurrentSigningKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	respData := issueTokensForNewUser(t)
+	if !assert.NotEmpty(t, respData["id_token"]) {
+		return
+	}
+
+	idToken, _, err := jwt.NewParser().ParseUnverified(respData["id_token"].(string), jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "RS256", idToken.Header["alg"])
+	assert.Equal(t, currentKey.KeyIdentifier, idToken.Header["kid"])
+
+	tokenParser := core_token.NewTokenParser(database)
+	idTokenJwt, err := tokenParser.ParseToken(context.Background(), respData["id_token"].(string), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, idTokenJwt.SignatureIsValid)
+}
+
+func TestTokenSigningKeys_IdTokenIsNotSignedWithTheNextKey(t *testing.T) {
+	setup()
+
+	settings, err := database.GetSettingsById(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPair, err := lib.GenerateSigningKeyPair(enums.SigningAlgorithmES256, enums.KeyStateNext, settings.AESEncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = database.CreateKeyPair(nil, keyPair)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.DeleteKeyPair(nil, keyPair.Id)
+
+	client, err := database.GetClientByClientIdentifier(nil, "test-client-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.IdTokenSignedResponseAlg = enums.SigningAlgorithmES256.String()
+	err = database.UpdateClient(nil, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		client.IdTokenSignedResponseAlg = ""
+		database.UpdateClient(nil, client)
+	}()
+
+	// the next key has the requested algorithm, but it's not the current key
+	httpClient, formData := getTokenRequestForNewUser(t)
+	resp, err := httpClient.PostForm(lib.GetBaseUrl()+"/auth/token", formData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+}
+
+func TestTokenSigningKeys_DiscoveryListsOnlyTheAlgorithmOfTheCurrentKey(t *testing.T) {
+	setup()
+
+	settings, err := database.GetSettingsById(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPair, err := lib.GenerateSigningKeyPair(enums.SigningAlgorithmEdDSA, enums.KeyStateNext, settings.AESEncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = database.CreateKeyPair(nil, keyPair)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.DeleteKeyPair(nil, keyPair.Id)
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	resp := getPage(t, httpClient, lib.GetBaseUrl()+"/.well-known/openid-configuration")
+	defer resp.Body.Close()
+	data := unmarshalToMap(t, resp)
+	assert.Equal(t, []interface{}{"RS256"}, data["id_token_signing_alg_values_supported"])
+}
+
+func TestTokenSigningKeys_CertsPublishesKeysOfAllAlgorithms(t *testing.T) {
+	setup()
+
+	settings, err := database.GetSettingsById(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	testCases := []struct {
+		algorithm enums.SigningAlgorithm
+		kty       string
+		crv       string
+	}{
+		{enums.SigningAlgorithmES256, "EC", "P-256"},
+		{enums.SigningAlgorithmES384, "EC", "P-384"},
+		{enums.SigningAlgorithmEdDSA, "OKP", "Ed25519"},
+	}
+
+	for _, testCase := range testCases {
+		keyPair, err := lib.GenerateSigningKeyPair(testCase.algorithm, enums.KeyStateNext, settings.AESEncryptionKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = database.CreateKeyPair(nil, keyPair)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp := getPage(t, httpClient, lib.GetBaseUrl()+"/certs")
+		data := unmarshalToMap(t, resp)
+		resp.Body.Close()
+		database.DeleteKeyPair(nil, keyPair.Id)
+
+		var jwk map[string]interface{}
+		for _, key := range data["keys"].([]interface{}) {
+			if key.(map[string]interface{})["kid"] == keyPair.KeyIdentifier {
+				jwk = key.(map[string]interface{})
+			}
+		}
+		if !assert.NotNil(t, jwk, testCase.algorithm.String()) {
+			continue
+		}
+		assert.Equal(t, testCase.algorithm.String(), jwk["alg"])
+		assert.Equal(t, testCase.kty, jwk["kty"])
+		assert.Equal(t, testCase.crv, jwk["crv"])
+		assert.NotEmpty(t, jwk["x"])
+		assert.Nil(t, jwk["n"])
+		assert.Nil(t, jwk["e"])
+		if testCase.kty == "EC" {
+			assert.NotEmpty(t, jwk["y"])
+		} else {
+			assert.Nil(t, jwk["y"])
+		}
+	}
+}
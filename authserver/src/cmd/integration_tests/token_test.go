@@ -651,7 +651,7 @@ func TestToken_Refresh_TokenWithBadSignature(t *testing.T) {
 	claims["typ"] = enums.TokenTypeRefresh.String()
 	claims["exp"] = exp.Unix()
 	keyPair := createNewKeyPair(t)
-	privKey, err := jwt.ParseRSAPrivateKeyFromPEM(keyPair.PrivateKeyPEM)
+	privKey, err := jwt.ParseRSAPrivateKeyFromPEM(getPrivateKeyPEM(t, keyPair))
 	if err != nil {
 		t.Fatal("unable to parse private key from PEM")
 	}
@@ -703,7 +703,7 @@ func TestToken_Refresh_TokenExpired(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	privKey, err := jwt.ParseRSAPrivateKeyFromPEM(keyPair.PrivateKeyPEM)
+	privKey, err := jwt.ParseRSAPrivateKeyFromPEM(getPrivateKeyPEM(t, keyPair))
 	if err != nil {
 		t.Fatal("unable to parse private key from PEM")
 	}
@@ -1025,6 +1025,83 @@ func TestToken_Refresh_UseTokenTwice(t *testing.T) {
 	}
 	respData3 := postToTokenEndpoint(t, httpClient, destUrl, formData)
 
+	assert.Equal(t, "invalid_grant", respData3["error"])
+	assert.Equal(t, "This refresh token has already been used. As a security measure, all refresh tokens issued from the same authorization have been revoked.", respData3["error_description"])
+
+	// the refresh token obtained in the second request was revoked as well
+	formData = url.Values{
+		"client_id":     {"test-client-1"},
+		"client_secret": {clientSecret},
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {respData2["refresh_token"].(string)},
+	}
+	respData4 := postToTokenEndpoint(t, httpClient, destUrl, formData)
+
+	assert.Equal(t, "invalid_grant", respData4["error"])
+	assert.Equal(t, "This refresh token has been revoked.", respData4["error_description"])
+}
+
+func TestToken_Refresh_RotationDisabledForClient(t *testing.T) {
+	setup()
+
+	client, err := database.GetClientByClientIdentifier(nil, "test-client-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.RefreshTokenRotationEnabled = enums.ThreeStateSettingOff.String()
+	err = database.UpdateClient(nil, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		client.RefreshTokenRotationEnabled = enums.ThreeStateSettingDefault.String()
+		err = database.UpdateClient(nil, client)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	scope := "openid profile email backend-svcA:read-product"
+	code, httpClient := createAuthCode(t, scope)
+
+	destUrl := lib.GetBaseUrl() + "/auth/token"
+
+	clientSecret := getClientSecret(t, "test-client-1")
+
+	formData := url.Values{
+		"client_id":     {"test-client-1"},
+		"client_secret": {clientSecret},
+		"grant_type":    {"authorization_code"},
+		"redirect_uri":  {code.RedirectURI},
+		"code":          {code.Code},
+		"code_verifier": {"DdazqdVNuDmRLGGRGQKKehEaoFeatACtNsM2UYGwuHkhBhDsTSzaCqWttcBc0kGx"},
+	}
+	respData := postToTokenEndpoint(t, httpClient, destUrl, formData)
+	assert.NotEmpty(t, respData["refresh_token"])
+
+	formData = url.Values{
+		"client_id":     {"test-client-1"},
+		"client_secret": {clientSecret},
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {respData["refresh_token"].(string)},
+	}
+	respData2 := postToTokenEndpoint(t, httpClient, destUrl, formData)
+	assert.NotEmpty(t, respData2["access_token"])
+	assert.NotEmpty(t, respData2["refresh_token"])
+
+	// without rotation, the refresh token still can't be used twice
+	respData3 := postToTokenEndpoint(t, httpClient, destUrl, formData)
 	assert.Equal(t, "invalid_grant", respData3["error"])
 	assert.Equal(t, "This refresh token has been revoked.", respData3["error_description"])
+
+	// but there's no reuse detection, so the refresh token obtained in the second request is still valid
+	formData = url.Values{
+		"client_id":     {"test-client-1"},
+		"client_secret": {clientSecret},
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {respData2["refresh_token"].(string)},
+	}
+	respData4 := postToTokenEndpoint(t, httpClient, destUrl, formData)
+	assert.Empty(t, respData4["error"])
+	assert.NotEmpty(t, respData4["access_token"])
 }
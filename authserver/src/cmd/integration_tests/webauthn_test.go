@@ -0,0 +1,577 @@
+package integrationtests
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+// softwareAuthenticator emulates a WebAuthn authenticator with an ES256 key
+type softwareAuthenticator struct {
+	privateKey   *ecdsa.PrivateKey
+	credentialId []byte
+	signCount    uint32
+	rpId         string
+	origin       string
+}
+
+func newSoftwareAuthenticator(t *testing.T, rpId string, origin string) *softwareAuthenticator {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialId := make([]byte, 32)
+	_, err = rand.Read(credentialId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &softwareAuthenticator{
+		privateKey:   privateKey,
+		credentialId: credentialId,
+		rpId:         rpId,
+		origin:       origin,
+	}
+}
+
+func cborHead(majorType byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{majorType<<5 | byte(n)}
+	case n <= 0xff:
+		return []byte{majorType<<5 | 24, byte(n)}
+	case n <= 0xffff:
+		b := []byte{majorType<<5 | 25, 0, 0}
+		binary.BigEndian.PutUint16(b[1:], uint16(n))
+		return b
+	}
+	b := []byte{majorType<<5 | 26, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(b[1:], uint32(n))
+	return b
+}
+
+func cborInt(v int64) []byte {
+	if v >= 0 {
+		return cborHead(0, uint64(v))
+	}
+	return cborHead(1, uint64(-1-v))
+}
+
+func cborBytes(b []byte) []byte {
+	return append(cborHead(2, uint64(len(b))), b...)
+}
+
+func cborText(s string) []byte {
+	return append(cborHead(3, uint64(len(s))), []byte(s)...)
+}
+
+func (a *softwareAuthenticator) coseKey() []byte {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	a.privateKey.X.FillBytes(x)
+	a.privateKey.Y.FillBytes(y)
+
+	key := cborHead(5, 5)
+	key = append(key, cborInt(1)...)
+	key = append(key, cborInt(2)...) // kty: EC2
+	key = append(key, cborInt(3)...)
+	key = append(key, cborInt(-7)...) // alg: ES256
+	key = append(key, cborInt(-1)...)
+	key = append(key, cborInt(1)...) // crv: P-256
+	key = append(key, cborInt(-2)...)
+	key = append(key, cborBytes(x)...)
+	key = append(key, cborInt(-3)...)
+	key = append(key, cborBytes(y)...)
+	return key
+}
+
+func (a *softwareAuthenticator) authenticatorData(flags byte, attested bool) []byte {
+	rpIdHash := sha256.Sum256([]byte(a.rpId))
+	authData := append([]byte{}, rpIdHash[:]...)
+	authData = append(authData, flags)
+	signCount := make([]byte, 4)
+	binary.BigEndian.PutUint32(signCount, a.signCount)
+	authData = append(authData, signCount...)
+
+	if attested {
+		authData = append(authData, make([]byte, 16)...) // aaguid
+		credentialIdLength := make([]byte, 2)
+		binary.BigEndian.PutUint16(credentialIdLength, uint16(len(a.credentialId)))
+		authData = append(authData, credentialIdLength...)
+		authData = append(authData, a.credentialId...)
+		authData = append(authData, a.coseKey()...)
+	}
+	return authData
+}
+
+func (a *softwareAuthenticator) clientDataJSON(t *testing.T, ceremonyType string, challenge string) []byte {
+	clientData, err := json.Marshal(map[string]interface{}{
+		"type":        ceremonyType,
+		"challenge":   challenge,
+		"origin":      a.origin,
+		"crossOrigin": false,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clientData
+}
+
+func (a *softwareAuthenticator) create(t *testing.T, challenge string) *lib.WebAuthnRegistrationResponse {
+	authData := a.authenticatorData(0x01|0x04|0x40, true)
+
+	attestationObject := cborHead(5, 3)
+	attestationObject = append(attestationObject, cborText("fmt")...)
+	attestationObject = append(attestationObject, cborText("none")...)
+	attestationObject = append(attestationObject, cborText("attStmt")...)
+	attestationObject = append(attestationObject, cborHead(5, 0)...)
+	attestationObject = append(attestationObject, cborText("authData")...)
+	attestationObject = append(attestationObject, cborBytes(authData)...)
+
+	return &lib.WebAuthnRegistrationResponse{
+		Id:                base64.RawURLEncoding.EncodeToString(a.credentialId),
+		ClientDataJSON:    base64.RawURLEncoding.EncodeToString(a.clientDataJSON(t, "webauthn.create", challenge)),
+		AttestationObject: base64.RawURLEncoding.EncodeToString(attestationObject),
+		Transports:        []string{"internal"},
+	}
+}
+
+func (a *softwareAuthenticator) get(t *testing.T, challenge string, userVerified bool, userHandle string) *lib.WebAuthnAssertionResponse {
+	flags := byte(0x01)
+	if userVerified {
+		flags |= 0x04
+	}
+	authData := a.authenticatorData(flags, false)
+	clientDataJSON := a.clientDataJSON(t, "webauthn.get", challenge)
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	hash := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.privateKey, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &lib.WebAuthnAssertionResponse{
+		Id:                base64.RawURLEncoding.EncodeToString(a.credentialId),
+		ClientDataJSON:    base64.RawURLEncoding.EncodeToString(clientDataJSON),
+		AuthenticatorData: base64.RawURLEncoding.EncodeToString(authData),
+		Signature:         base64.RawURLEncoding.EncodeToString(signature),
+		UserHandle:        userHandle,
+	}
+}
+
+func getTestRelyingParty(t *testing.T) *lib.WebAuthnRelyingParty {
+	rp, err := lib.NewWebAuthnRelyingParty(lib.GetBaseUrl(), "goiabada")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rp
+}
+
+// registerPasskeyForUser registers a new credential of the software authenticator for the user
+func registerPasskeyForUser(t *testing.T, user *entities.User) *softwareAuthenticator {
+	rp := getTestRelyingParty(t)
+	authenticator := newSoftwareAuthenticator(t, rp.Id, rp.Origin)
+
+	challenge, err := lib.GenerateWebAuthnChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialData, err := lib.VerifyWebAuthnRegistration(rp, challenge, authenticator.create(t, challenge))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = database.CreateWebAuthnCredential(nil, &entities.WebAuthnCredential{
+		UserId:       user.Id,
+		Name:         "Test passkey",
+		CredentialId: credentialData.CredentialId,
+		PublicKey:    credentialData.PublicKey,
+		SignCount:    credentialData.SignCount,
+		AAGUID:       credentialData.AAGUID,
+		Transports:   "internal",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authenticator
+}
+
+func postPasskeyJson(t *testing.T, httpClient *http.Client, path string, body interface{}) (int, map[string]interface{}) {
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := httpClient.Post(lib.GetBaseUrl()+path, "application/json", bytes.NewReader(bodyBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result map[string]interface{}
+	err = json.Unmarshal(respBody, &result)
+	if err != nil {
+		t.Fatalf("unable to parse response %v: %v", string(respBody), err)
+	}
+	return resp.StatusCode, result
+}
+
+func startAuthorizeWithAcrLevel(t *testing.T, acrLevel enums.AcrLevel) *http.Client {
+	codeChallenge := "bQCdz4Hkhb3ctpajAwCCN899mNNfQGmRvMwruYT1Y9Y"
+	destUrl := lib.GetBaseUrl() +
+		"/auth/authorize/?client_id=test-client-1&redirect_uri=https://goiabada-test-client:8090/callback.html&response_type=code" +
+		"&code_challenge_method=S256&code_challenge=" + codeChallenge +
+		"&state=a1b2c3&response_mode=query&scope=openid" +
+		"&acr_values=" + acrLevel.String()
+
+	httpClient := createHttpClient(&createHttpClientInput{
+		T: t,
+	})
+
+	resp, err := httpClient.Get(destUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+
+	return httpClient
+}
+
+func assertLatestUserSession(t *testing.T, userId int64, authMethods string, acrLevel enums.AcrLevel) {
+	userSessions, err := database.GetUserSessionsByUserId(nil, userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.NotEmpty(t, userSessions) {
+		return
+	}
+	userSession := userSessions[len(userSessions)-1]
+	assert.Equal(t, authMethods, userSession.AuthMethods)
+	assert.Equal(t, acrLevel.String(), userSession.AcrLevel)
+}
+
+func TestWebAuthn_VerifyRegistration(t *testing.T) {
+	rp, err := lib.NewWebAuthnRelyingParty("https://auth.example.com:8443", "goiabada")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "auth.example.com", rp.Id)
+	assert.Equal(t, "https://auth.example.com:8443", rp.Origin)
+
+	authenticator := newSoftwareAuthenticator(t, rp.Id, rp.Origin)
+	authenticator.signCount = 3
+
+	credentialData, err := lib.VerifyWebAuthnRegistration(rp, "challenge-1", authenticator.create(t, "challenge-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(authenticator.credentialId), credentialData.CredentialId)
+	assert.Equal(t, int64(3), credentialData.SignCount)
+	assert.Equal(t, uuid.Nil.String(), credentialData.AAGUID)
+	assert.True(t, credentialData.UserVerified)
+
+	// wrong challenge
+	_, err = lib.VerifyWebAuthnRegistration(rp, "challenge-2", authenticator.create(t, "challenge-1"))
+	assert.ErrorContains(t, err, "Error validating challenge")
+
+	// wrong origin
+	otherOrigin := newSoftwareAuthenticator(t, rp.Id, "https://evil.example.com")
+	_, err = lib.VerifyWebAuthnRegistration(rp, "challenge-1", otherOrigin.create(t, "challenge-1"))
+	assert.ErrorContains(t, err, "Error validating origin")
+
+	// wrong relying party id
+	otherRpId := newSoftwareAuthenticator(t, "evil.example.com", rp.Origin)
+	_, err = lib.VerifyWebAuthnRegistration(rp, "challenge-1", otherRpId.create(t, "challenge-1"))
+	assert.ErrorContains(t, err, "Error validating the authenticator response")
+
+	// an assertion can't be used as a registration
+	assertion := authenticator.get(t, "challenge-1", true, "")
+	registration := authenticator.create(t, "challenge-1")
+	registration.ClientDataJSON = assertion.ClientDataJSON
+	_, err = lib.VerifyWebAuthnRegistration(rp, "challenge-1", registration)
+	assert.ErrorContains(t, err, "Error validating ceremony type")
+
+	// assertion signed by the registered key
+	authenticator.signCount = 4
+	result, err := lib.VerifyWebAuthnAssertion(rp, "challenge-3", authenticator.get(t, "challenge-3", false, ""),
+		credentialData.PublicKey, credentialData.SignCount, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(4), result.SignCount)
+	assert.False(t, result.UserVerified)
+
+	// user verification required
+	_, err = lib.VerifyWebAuthnAssertion(rp, "challenge-3", authenticator.get(t, "challenge-3", false, ""),
+		credentialData.PublicKey, credentialData.SignCount, true)
+	assert.ErrorContains(t, err, "Error validating the authenticator response")
+
+	// signed by another key
+	other := newSoftwareAuthenticator(t, rp.Id, rp.Origin)
+	other.signCount = 10
+	_, err = lib.VerifyWebAuthnAssertion(rp, "challenge-3", other.get(t, "challenge-3", true, ""),
+		credentialData.PublicKey, credentialData.SignCount, false)
+	assert.ErrorContains(t, err, "Error validating the assertion signature")
+}
+
+func TestWebAuthn_PasswordlessLogin(t *testing.T) {
+	setup()
+
+	restoreSettings := updateLockoutSettings(t, 0, 900, 0, 0)
+	defer restoreSettings()
+
+	user := createLockoutTestUser(t, "abc123")
+	authenticator := registerPasskeyForUser(t, user)
+	userHandle := base64.RawURLEncoding.EncodeToString([]byte(user.Subject.String()))
+
+	httpClient := startAuthorizeWithAcrLevel(t, enums.AcrLevel2)
+
+	statusCode, options := postPasskeyJson(t, httpClient, "/auth/passkey/login/begin", map[string]interface{}{})
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, "required", options["userVerification"])
+	assert.Equal(t, getTestRelyingParty(t).Id, options["rpId"])
+	assert.Empty(t, options["allowCredentials"])
+	challenge := options["challenge"].(string)
+
+	// without user verification the passkey is not enough on its own
+	authenticator.signCount = 1
+	statusCode, result := postPasskeyJson(t, httpClient, "/auth/passkey/login/finish",
+		authenticator.get(t, challenge, false, userHandle))
+	assert.Equal(t, http.StatusBadRequest, statusCode)
+	assert.Equal(t, "Authentication with the passkey failed.", result["error_description"])
+
+	// the challenge was consumed by the previous attempt
+	authenticator.signCount = 2
+	statusCode, _ = postPasskeyJson(t, httpClient, "/auth/passkey/login/finish",
+		authenticator.get(t, challenge, true, userHandle))
+	assert.Equal(t, http.StatusBadRequest, statusCode)
+
+	_, options = postPasskeyJson(t, httpClient, "/auth/passkey/login/begin", map[string]interface{}{})
+	challenge = options["challenge"].(string)
+
+	authenticator.signCount = 3
+	statusCode, result = postPasskeyJson(t, httpClient, "/auth/passkey/login/finish",
+		authenticator.get(t, challenge, true, userHandle))
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, true, result["Success"])
+	assert.Equal(t, lib.GetBaseUrl()+"/auth/consent", result["RedirectUri"])
+
+	assertLatestUserSession(t, user.Id, enums.AuthMethodPasskey.String(), enums.AcrLevelPasskey)
+
+	webAuthnCredentials, err := database.GetWebAuthnCredentialsByUserId(nil, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, webAuthnCredentials, 1)
+	assert.Equal(t, int64(3), webAuthnCredentials[0].SignCount)
+	assert.True(t, webAuthnCredentials[0].LastUsedAt.Valid)
+
+	// failed attempts were counted, and reset after the successful authentication
+	user, err = database.GetUserById(nil, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, user.FailedLoginAttempts)
+}
+
+func TestWebAuthn_SignCountRegressionIsRejected(t *testing.T) {
+	setup()
+
+	restoreSettings := updateLockoutSettings(t, 0, 900, 0, 0)
+	defer restoreSettings()
+
+	user := createLockoutTestUser(t, "abc123")
+	authenticator := registerPasskeyForUser(t, user)
+	userHandle := base64.RawURLEncoding.EncodeToString([]byte(user.Subject.String()))
+
+	httpClient := startAuthorizeWithAcrLevel(t, enums.AcrLevel1)
+
+	_, options := postPasskeyJson(t, httpClient, "/auth/passkey/login/begin", map[string]interface{}{})
+	authenticator.signCount = 10
+	statusCode, _ := postPasskeyJson(t, httpClient, "/auth/passkey/login/finish",
+		authenticator.get(t, options["challenge"].(string), true, userHandle))
+	assert.Equal(t, http.StatusOK, statusCode)
+
+	// a cloned authenticator would replay an older counter
+	_, options = postPasskeyJson(t, httpClient, "/auth/passkey/login/begin", map[string]interface{}{})
+	authenticator.signCount = 10
+	statusCode, _ = postPasskeyJson(t, httpClient, "/auth/passkey/login/finish",
+		authenticator.get(t, options["challenge"].(string), true, userHandle))
+	assert.Equal(t, http.StatusBadRequest, statusCode)
+
+	user, err := database.GetUserById(nil, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, user.FailedLoginAttempts)
+
+	// once the authenticator reported a counter, it can't go back to zero
+	_, options = postPasskeyJson(t, httpClient, "/auth/passkey/login/begin", map[string]interface{}{})
+	authenticator.signCount = 0
+	statusCode, _ = postPasskeyJson(t, httpClient, "/auth/passkey/login/finish",
+		authenticator.get(t, options["challenge"].(string), true, userHandle))
+	assert.Equal(t, http.StatusBadRequest, statusCode)
+
+	webAuthnCredentials, err := database.GetWebAuthnCredentialsByUserId(nil, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, webAuthnCredentials, 1)
+	assert.Equal(t, int64(10), webAuthnCredentials[0].SignCount)
+}
+
+func TestWebAuthn_AuthenticatorWithoutSignCount(t *testing.T) {
+	setup()
+
+	restoreSettings := updateLockoutSettings(t, 0, 900, 0, 0)
+	defer restoreSettings()
+
+	user := createLockoutTestUser(t, "abc123")
+	authenticator := registerPasskeyForUser(t, user)
+	userHandle := base64.RawURLEncoding.EncodeToString([]byte(user.Subject.String()))
+
+	httpClient := startAuthorizeWithAcrLevel(t, enums.AcrLevel1)
+
+	// an authenticator that doesn't implement a counter always returns zero
+	for i := 0; i < 2; i++ {
+		_, options := postPasskeyJson(t, httpClient, "/auth/passkey/login/begin", map[string]interface{}{})
+		authenticator.signCount = 0
+		statusCode, _ := postPasskeyJson(t, httpClient, "/auth/passkey/login/finish",
+			authenticator.get(t, options["challenge"].(string), true, userHandle))
+		assert.Equal(t, http.StatusOK, statusCode)
+	}
+
+	webAuthnCredentials, err := database.GetWebAuthnCredentialsByUserId(nil, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, webAuthnCredentials, 1)
+	assert.Equal(t, int64(0), webAuthnCredentials[0].SignCount)
+}
+
+func TestWebAuthn_PasswordlessLoginRequiresTheUserHandle(t *testing.T) {
+	setup()
+
+	restoreSettings := updateLockoutSettings(t, 0, 900, 0, 0)
+	defer restoreSettings()
+
+	user := createLockoutTestUser(t, "abc123")
+	authenticator := registerPasskeyForUser(t, user)
+	otherUser := createLockoutTestUser(t, "abc123")
+
+	httpClient := startAuthorizeWithAcrLevel(t, enums.AcrLevel1)
+
+	// no user handle
+	_, options := postPasskeyJson(t, httpClient, "/auth/passkey/login/begin", map[string]interface{}{})
+	authenticator.signCount = 1
+	statusCode, result := postPasskeyJson(t, httpClient, "/auth/passkey/login/finish",
+		authenticator.get(t, options["challenge"].(string), true, ""))
+	assert.Equal(t, http.StatusBadRequest, statusCode)
+	assert.Equal(t, "Authentication with the passkey failed.", result["error_description"])
+
+	// the user handle of another user
+	_, options = postPasskeyJson(t, httpClient, "/auth/passkey/login/begin", map[string]interface{}{})
+	authenticator.signCount = 2
+	statusCode, _ = postPasskeyJson(t, httpClient, "/auth/passkey/login/finish",
+		authenticator.get(t, options["challenge"].(string), true,
+			base64.RawURLEncoding.EncodeToString([]byte(otherUser.Subject.String()))))
+	assert.Equal(t, http.StatusBadRequest, statusCode)
+}
+
+func TestWebAuthn_DisabledUserIsRejected(t *testing.T) {
+	setup()
+
+	restoreSettings := updateLockoutSettings(t, 0, 900, 0, 0)
+	defer restoreSettings()
+
+	user := createLockoutTestUser(t, "abc123")
+	authenticator := registerPasskeyForUser(t, user)
+	userHandle := base64.RawURLEncoding.EncodeToString([]byte(user.Subject.String()))
+
+	user.Enabled = false
+	err := database.UpdateUser(nil, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpClient := startAuthorizeWithAcrLevel(t, enums.AcrLevel1)
+
+	_, options := postPasskeyJson(t, httpClient, "/auth/passkey/login/begin", map[string]interface{}{})
+	authenticator.signCount = 5
+	statusCode, result := postPasskeyJson(t, httpClient, "/auth/passkey/login/finish",
+		authenticator.get(t, options["challenge"].(string), true, userHandle))
+	assert.Equal(t, http.StatusBadRequest, statusCode)
+	assert.Equal(t, "Your account is disabled.", result["error_description"])
+
+	// the credential was not touched
+	webAuthnCredentials, err := database.GetWebAuthnCredentialsByUserId(nil, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, webAuthnCredentials, 1)
+	assert.Equal(t, int64(0), webAuthnCredentials[0].SignCount)
+	assert.False(t, webAuthnCredentials[0].LastUsedAt.Valid)
+}
+
+func TestWebAuthn_PasskeyAfterPassword(t *testing.T) {
+	setup()
+
+	user := createLockoutTestUser(t, "abc123")
+	authenticator := registerPasskeyForUser(t, user)
+
+	httpClient := startAuthorizeWithAcrLevel(t, enums.AcrLevelPasskey)
+
+	// before the password, the user is unknown
+	statusCode, _ := postPasskeyJson(t, httpClient, "/auth/passkey/begin", map[string]interface{}{})
+	assert.Equal(t, http.StatusBadRequest, statusCode)
+
+	resp := authenticateWithPassword(t, httpClient, user.Email, "abc123", "")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, lib.GetBaseUrl()+"/auth/passkey", resp.Header.Get("Location"))
+
+	statusCode, options := postPasskeyJson(t, httpClient, "/auth/passkey/begin", map[string]interface{}{})
+	assert.Equal(t, http.StatusOK, statusCode)
+	allowCredentials := options["allowCredentials"].([]interface{})
+	assert.Len(t, allowCredentials, 1)
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(authenticator.credentialId),
+		allowCredentials[0].(map[string]interface{})["id"])
+
+	// a passkey of another user is rejected
+	otherUser := createLockoutTestUser(t, "abc123")
+	otherAuthenticator := registerPasskeyForUser(t, otherUser)
+	otherAuthenticator.signCount = 1
+	statusCode, _ = postPasskeyJson(t, httpClient, "/auth/passkey/finish",
+		otherAuthenticator.get(t, options["challenge"].(string), true, ""))
+	assert.Equal(t, http.StatusBadRequest, statusCode)
+
+	_, options = postPasskeyJson(t, httpClient, "/auth/passkey/begin", map[string]interface{}{})
+
+	// as a second factor, user presence is enough
+	authenticator.signCount = 1
+	statusCode, result := postPasskeyJson(t, httpClient, "/auth/passkey/finish",
+		authenticator.get(t, options["challenge"].(string), false, ""))
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, true, result["Success"])
+
+	assertLatestUserSession(t, user.Id, enums.AuthMethodPassword.String()+" "+enums.AuthMethodPasskey.String(),
+		enums.AcrLevelPasskey)
+}
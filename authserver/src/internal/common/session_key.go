@@ -5,6 +5,7 @@ const SessionName string = "goiabada"
 const SessionKeySessionIdentifier string = "SessionIdentifier"
 const SessionKeyOTPImage string = "OTPImage"
 const SessionKeyOTPSecret string = "OTPSecret"
+const SessionKeyWebAuthnChallenge string = "WebAuthnChallenge"
 const SessionKeyAuthContext string = "AuthContext"
 const SessionKeyJwt string = "Jwt"
 
@@ -15,3 +16,7 @@ const SessionKeyCodeVerifier string = "CodeVerifier"
 const SessionKeyReferrer string = "Referrer"
 
 const SessionKeyRedirToAuthorizeCount string = "RedirToAuthorizeCount"
+
+// BrowserStateCookieName is the cookie read by the check_session_iframe. Unlike the session
+// cookie, it's readable by javascript
+const BrowserStateCookieName string = "goiabada_browser_state"
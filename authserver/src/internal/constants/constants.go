@@ -10,8 +10,14 @@ const AdminWebsitePermissionIdentifier = "admin-website"
 
 const AuditAuthFailedPwd = "auth_failed_pwd"
 const AuditAuthFailedOtp = "auth_failed_otp"
+const AuditAuthFailedPasskey = "auth_failed_passkey"
+const AuditAuthFailedAccountLocked = "auth_failed_account_locked"
+const AuditAccountLocked = "account_locked"
+const AuditUnlockedUserAccount = "unlocked_user_account"
 const AuditAuthSuccessPwd = "auth_success_pwd"
 const AuditAuthSuccessOtp = "auth_success_otp"
+const AuditAuthSuccessPasskey = "auth_success_passkey"
+const AuditAuthSuccessRecoveryCode = "auth_success_recovery_code"
 const AuditUserDisabled = "user_disabled"
 const AuditStartedNewUserSesson = "started_new_user_session"
 const AuditBumpedUserSession = "bumped_user_session"
@@ -25,6 +31,7 @@ const AuditCreatedPreRegistration = "created_pre_registration"
 const AuditCreatedUser = "created_user"
 const AuditActivatedAccount = "activated_account"
 const AuditUpdatedRedirectURIs = "updated_redirect_uris"
+const AuditUpdatedPostLogoutRedirectURIs = "updated_post_logout_redirect_uris"
 const AuditUpdatedClientPermissions = "updated_client_permissions"
 const AuditDeletedClient = "deleted_client"
 const AuditCreatedClient = "created_client"
@@ -51,17 +58,28 @@ const AuditDeletedUser = "deleted_user"
 const AuditUpdatedSMTPSettings = "updated_smtp_settings"
 const AuditUpdatedGeneralSettings = "updated_general_settings"
 const AuditUpdatedSessionsSettings = "updated_sessions_settings"
+const AuditUpdatedSecuritySettings = "updated_security_settings"
 const AuditUpdatedSMSSettings = "updated_sms_settings"
 const AuditUpdatedTokensSettings = "updated_tokens_settings"
+const AuditUpdatedKeysSettings = "updated_keys_settings"
 const AuditUpdatedUIThemeSettings = "updated_ui_theme_settings"
 const AuditTokenIssuedAuthorizationCodeResponse = "token_issued_authorization_code_response"
 const AuditTokenIssuedClientCredentialsResponse = "token_issued_client_credentials_response"
 const AuditTokenIssuedRefreshTokenResponse = "token_issued_refresh_token_response"
+const AuditTokenIssuedDeviceCodeResponse = "token_issued_device_code_response"
+const AuditRevokedRefreshToken = "revoked_refresh_token"
+const AuditRefreshTokenReuseDetected = "refresh_token_reuse_detected"
+const AuditCreatedPushedAuthorizationRequest = "created_pushed_authorization_request"
+const AuditCreatedDeviceCode = "created_device_code"
+const AuditApprovedDeviceCode = "approved_device_code"
+const AuditDeniedDeviceCode = "denied_device_code"
+const AuditDeviceCodeEntryLocked = "device_code_entry_locked"
 const AuditUpdatedWebOrigins = "updated_web_origins"
 const AuditUpdatedClientSettings = "updated_client_settings"
 const AuditUpdatedClientTokens = "updated_client_tokens"
 const AuditUpdatedClientAuthentication = "updated_client_authentication"
 const AuditUpdatedClientOAuth2Flows = "updated_client_oauth2_flows"
+const AuditUpdatedClientLogout = "updated_client_logout"
 const AuditUpdatedUserDetails = "updated_user_details"
 const AuditUpdatedUserProfile = "updated_user_profile"
 const AuditUpdatedUserEmail = "updated_user_email"
@@ -69,9 +87,19 @@ const AuditUpdatedUserPhone = "updated_user_phone"
 const AuditUpdatedUserAddress = "updated_user_address"
 const AuditUpdatedUserAuthentication = "updated_user_authentication"
 const AuditDeletedUserConsent = "deleted_user_consent"
+const AuditRegisteredPasskey = "registered_passkey"
+const AuditDeletedPasskey = "deleted_passkey"
 const AuditVerifiedEmail = "verified_email"
 const AuditVerifiedPhone = "verified_phone"
 const AuditSentPhoneVerificationMessage = "sent_phone_verification_message"
 const AuditChangedPassword = "changed_password"
 const AuditEnrolledOTP = "enrolled_otp"
+const AuditGeneratedRecoveryCodes = "generated_recovery_codes"
+const AuditSentOTPCode = "sent_otp_code"
 const AuditLogout = "logout"
+const AuditDeliveredBackchannelLogout = "delivered_backchannel_logout"
+const AuditFailedBackchannelLogout = "failed_backchannel_logout"
+const AuditRegisteredClient = "registered_client"
+const AuditUpdatedRegisteredClient = "updated_registered_client"
+const AuditDeletedRegisteredClient = "deleted_registered_client"
+const AuditUpdatedClientRegistrationSettings = "updated_client_registration_settings"
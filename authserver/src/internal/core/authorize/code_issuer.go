@@ -84,7 +84,7 @@ func (ci *CodeIssuer) CreateAuthCode(ctx context.Context, input *CreateCodeInput
 		return nil, err
 	}
 
-	lib.LogAudit(constants.AuditCreatedAuthCode, map[string]interface{}{
+	lib.LogAudit(ctx, constants.AuditCreatedAuthCode, map[string]interface{}{
 		"userId":   input.UserId,
 		"clientId": client.Id,
 	})
@@ -17,6 +17,7 @@ type LoginManager struct {
 Acr Level 0 - cookie (no active authentication)
 Acr Level 1 - pwd
 Acr Level 2 - pwd + otp
+Acr Level passkey - passkey, either alone or after the pwd
 
 */
 
@@ -68,3 +69,18 @@ func (lm *LoginManager) MustPerformOTPAuth(ctx context.Context, client *entities
 
 	return false
 }
+
+func (lm *LoginManager) MustPerformPasskeyAuth(ctx context.Context, client *entities.Client,
+	userSession *entities.UserSession, targetAcrLevel enums.AcrLevel) bool {
+
+	if targetAcrLevel != enums.AcrLevelPasskey {
+		return false
+	}
+
+	currentAcrLevel, err := enums.AcrLevelFromString(userSession.AcrLevel)
+	if err != nil {
+		return true
+	}
+
+	return currentAcrLevel != enums.AcrLevelPasskey
+}
@@ -0,0 +1,248 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leodip/goiabada/internal/constants"
+	"github.com/leodip/goiabada/internal/data"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/pkg/errors"
+)
+
+const (
+	backchannelLogoutMaxAttempts    = 5
+	backchannelLogoutInitialBackoff = 30 * time.Second
+	backchannelLogoutMaxBackoff     = time.Hour
+	backchannelLogoutBatchSize      = 50
+	backchannelLogoutMaxErrorLength = 512
+
+	// backchannelLogoutConcurrency is how many notifications are sent at the same time
+	backchannelLogoutConcurrency = 10
+
+	// backchannelLogoutClaimDuration is how long a claimed delivery is kept from the other
+	// instances. If the instance that claimed it stops, the delivery is picked up again after that
+	backchannelLogoutClaimDuration = time.Minute
+)
+
+// BackchannelLogoutNotifier tells the clients of a user session that the session has ended,
+// by posting a logout token to their backchannel_logout_uri. Notifications are stored in the
+// database and delivered by a background worker, which retries failed deliveries
+type BackchannelLogoutNotifier struct {
+	database    data.Database
+	tokenIssuer *TokenIssuer
+	httpClient  *http.Client
+	pending     chan struct{}
+}
+
+func NewBackchannelLogoutNotifier(database data.Database, tokenIssuer *TokenIssuer) *BackchannelLogoutNotifier {
+	return &BackchannelLogoutNotifier{
+		database:    database,
+		tokenIssuer: tokenIssuer,
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+		pending: make(chan struct{}, 1),
+	}
+}
+
+// Pending receives a value whenever new notifications are queued, so the worker can deliver
+// them right away instead of waiting for its next tick
+func (n *BackchannelLogoutNotifier) Pending() <-chan struct{} {
+	return n.pending
+}
+
+// QueueLogout queues a notification for each client of the user session that has a
+// backchannel_logout_uri
+func (n *BackchannelLogoutNotifier) QueueLogout(userSession *entities.UserSession) error {
+	err := n.database.UserSessionLoadClients(nil, userSession)
+	if err != nil {
+		return err
+	}
+	err = n.database.UserSessionClientsLoadClients(nil, userSession.Clients)
+	if err != nil {
+		return err
+	}
+
+	user, err := n.database.GetUserById(nil, userSession.UserId)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return errors.WithStack(fmt.Errorf("user %v not found", userSession.UserId))
+	}
+
+	queued := false
+	for _, sessionClient := range userSession.Clients {
+		if len(sessionClient.Client.BackchannelLogoutURI) == 0 {
+			continue
+		}
+
+		delivery := &entities.BackchannelLogoutDelivery{
+			ClientId:          sessionClient.ClientId,
+			Subject:           user.Subject.String(),
+			SessionIdentifier: userSession.SessionIdentifier,
+			Status:            enums.BackchannelLogoutStatusPending.String(),
+			NextAttemptAt:     sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		}
+		err = n.database.CreateBackchannelLogoutDelivery(nil, delivery)
+		if err != nil {
+			return err
+		}
+		queued = true
+	}
+
+	if queued {
+		select {
+		case n.pending <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// DeliverPending sends the notifications that are due. They're sent concurrently, so that a
+// slow client doesn't hold up the others. A failed delivery is retried with exponential
+// backoff, and given up after a few attempts
+func (n *BackchannelLogoutNotifier) DeliverPending(settings *entities.Settings, now time.Time) error {
+	deliveries, err := n.database.GetDueBackchannelLogoutDeliveries(nil, now, backchannelLogoutBatchSize)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	var errMutex sync.Mutex
+	var firstErr error
+	semaphore := make(chan struct{}, backchannelLogoutConcurrency)
+
+	for i := range deliveries {
+		delivery := &deliveries[i]
+
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			err := n.deliverPending(settings, delivery, now)
+			if err != nil {
+				errMutex.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+// deliverPending sends a single notification and records the outcome. With several instances
+// running, the delivery is claimed first so that it's only sent by one of them
+func (n *BackchannelLogoutNotifier) deliverPending(settings *entities.Settings,
+	delivery *entities.BackchannelLogoutDelivery, now time.Time) error {
+
+	claimed, err := n.database.ClaimBackchannelLogoutDelivery(nil, delivery.Id, now, now.Add(backchannelLogoutClaimDuration))
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return nil
+	}
+
+	client, err := n.database.GetClientById(nil, delivery.ClientId)
+	if err != nil {
+		return err
+	}
+
+	if client == nil || len(client.BackchannelLogoutURI) == 0 {
+		// the client was changed after the notification was queued; there's nothing to retry
+		delivery.Status = enums.BackchannelLogoutStatusFailed.String()
+		delivery.LastError = "the client no longer has a backchannel logout uri"
+		return n.database.UpdateBackchannelLogoutDelivery(nil, delivery)
+	}
+
+	err = n.deliver(settings, client, delivery)
+	delivery.Attempts++
+	if err == nil {
+		delivery.Status = enums.BackchannelLogoutStatusDelivered.String()
+		delivery.LastError = ""
+		delivery.DeliveredAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+
+		lib.LogAudit(context.Background(), constants.AuditDeliveredBackchannelLogout, map[string]interface{}{
+			"clientId":          delivery.ClientId,
+			"sessionIdentifier": delivery.SessionIdentifier,
+		})
+	} else {
+		delivery.LastError = err.Error()
+		if len(delivery.LastError) > backchannelLogoutMaxErrorLength {
+			delivery.LastError = delivery.LastError[:backchannelLogoutMaxErrorLength]
+		}
+
+		if delivery.Attempts >= backchannelLogoutMaxAttempts {
+			delivery.Status = enums.BackchannelLogoutStatusFailed.String()
+
+			lib.LogAudit(context.Background(), constants.AuditFailedBackchannelLogout, map[string]interface{}{
+				"clientId":          delivery.ClientId,
+				"sessionIdentifier": delivery.SessionIdentifier,
+				"error":             delivery.LastError,
+			})
+		} else {
+			delivery.NextAttemptAt = sql.NullTime{Time: now.Add(backchannelLogoutBackoff(delivery.Attempts)), Valid: true}
+		}
+	}
+
+	return n.database.UpdateBackchannelLogoutDelivery(nil, delivery)
+}
+
+func (n *BackchannelLogoutNotifier) deliver(settings *entities.Settings, client *entities.Client,
+	delivery *entities.BackchannelLogoutDelivery) error {
+
+	logoutToken, err := n.tokenIssuer.GenerateLogoutToken(settings, client, delivery.Subject, delivery.SessionIdentifier)
+	if err != nil {
+		return err
+	}
+
+	formData := url.Values{
+		"logout_token": {logoutToken},
+	}
+	req, err := http.NewRequest(http.MethodPost, client.BackchannelLogoutURI, strings.NewReader(formData.Encode()))
+	if err != nil {
+		return errors.Wrap(err, "unable to create the backchannel logout request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "unable to reach the backchannel logout uri")
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.WithStack(fmt.Errorf("the backchannel logout uri returned status code %v", resp.StatusCode))
+	}
+	return nil
+}
+
+func backchannelLogoutBackoff(attempts int) time.Duration {
+	backoff := backchannelLogoutInitialBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff > backchannelLogoutMaxBackoff {
+			return backchannelLogoutMaxBackoff
+		}
+	}
+	return backoff
+}
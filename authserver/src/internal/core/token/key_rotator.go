@@ -0,0 +1,317 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/leodip/goiabada/internal/constants"
+	"github.com/leodip/goiabada/internal/data"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/pkg/errors"
+)
+
+// KeyRotator rotates and revokes the token signing keys, either on demand (from the admin
+// console) or following the automatic rotation schedule configured in the settings
+type KeyRotator struct {
+	database    data.Database
+	tokenParser *TokenParser
+}
+
+func NewKeyRotator(database data.Database, tokenParser *TokenParser) *KeyRotator {
+	return &KeyRotator{
+		database:    database,
+		tokenParser: tokenParser,
+	}
+}
+
+// KeyRotationSchedule holds the upcoming automatic key operations. Zero times mean that
+// nothing is scheduled
+type KeyRotationSchedule struct {
+	NextRotation          time.Time
+	PreviousKeyRevocation time.Time
+}
+
+type signingKeys struct {
+	current  *entities.KeyPair
+	next     *entities.KeyPair
+	previous *entities.KeyPair
+}
+
+func (kr *KeyRotator) getSigningKeys() (*signingKeys, error) {
+	allSigningKeys, err := kr.database.GetAllSigningKeys(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := &signingKeys{}
+	for i, signingKey := range allSigningKeys {
+		keyState, err := enums.KeyStateFromString(signingKey.State)
+		if err != nil {
+			return nil, err
+		}
+		switch keyState {
+		case enums.KeyStateCurrent:
+			keys.current = &allSigningKeys[i]
+		case enums.KeyStateNext:
+			keys.next = &allSigningKeys[i]
+		case enums.KeyStatePrevious:
+			keys.previous = &allSigningKeys[i]
+		}
+	}
+
+	if keys.current == nil {
+		return nil, errors.WithStack(fmt.Errorf("no current key found"))
+	}
+	if keys.next == nil {
+		return nil, errors.WithStack(fmt.Errorf("no next key found"))
+	}
+	return keys, nil
+}
+
+// errSigningKeysChanged is returned when another rotation or revocation (from another instance,
+// or from the admin console) changed the keys while they were being rotated
+var errSigningKeysChanged = errors.New("the signing keys were changed concurrently")
+
+// RotateKeys promotes the next key to current and the current key to previous, revoking the
+// existing previous key. A new next key is created with the given algorithm, or with the
+// algorithm of the existing next key when none is given. The keys only move if they're still
+// in the state they were read in, so that concurrent rotations don't step on each other
+func (kr *KeyRotator) RotateKeys(ctx context.Context, settings *entities.Settings, algorithmStr string,
+	auditDetails map[string]interface{}) error {
+	keys, err := kr.getSigningKeys()
+	if err != nil {
+		return err
+	}
+	return kr.rotateKeys(ctx, settings, keys, algorithmStr, auditDetails)
+}
+
+func (kr *KeyRotator) rotateKeys(ctx context.Context, settings *entities.Settings, keys *signingKeys,
+	algorithmStr string, auditDetails map[string]interface{}) error {
+	if len(algorithmStr) == 0 {
+		algorithmStr = keys.next.Algorithm
+	}
+	algorithm, err := enums.SigningAlgorithmFromString(algorithmStr)
+	if err != nil {
+		return err
+	}
+
+	keyPair, err := lib.GenerateSigningKeyPair(algorithm, enums.KeyStateNext, settings.AESEncryptionKey)
+	if err != nil {
+		return err
+	}
+
+	tx, err := kr.database.BeginTransaction()
+	if err != nil {
+		return err
+	}
+	defer kr.database.RollbackTransaction(tx)
+
+	if keys.previous != nil {
+		err = kr.revokeKey(tx, keys.previous)
+		if err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().UTC()
+
+	// current key becomes previous
+	keys.current.State = enums.KeyStatePrevious.String()
+	keys.current.DeactivatedAt = sql.NullTime{Time: now, Valid: true}
+	err = kr.updateKeyIfState(tx, keys.current, enums.KeyStateCurrent)
+	if err != nil {
+		return err
+	}
+
+	// next key becomes current
+	keys.next.State = enums.KeyStateCurrent.String()
+	keys.next.ActivatedAt = sql.NullTime{Time: now, Valid: true}
+	err = kr.updateKeyIfState(tx, keys.next, enums.KeyStateNext)
+	if err != nil {
+		return err
+	}
+
+	// create a new next key
+	err = kr.database.CreateKeyPair(tx, keyPair)
+	if err != nil {
+		return err
+	}
+
+	err = kr.database.CommitTransaction(tx)
+	if err != nil {
+		return err
+	}
+
+	kr.tokenParser.InvalidateKeySet()
+
+	if keys.previous != nil {
+		kr.logRevokedKey(ctx, keys.previous, auditDetails)
+	}
+
+	details := map[string]interface{}{
+		"algorithm": algorithm.String(),
+	}
+	for k, v := range auditDetails {
+		details[k] = v
+	}
+	lib.LogAudit(ctx, constants.AuditRotatedKeys, details)
+	return nil
+}
+
+// RevokeKey keeps the key identifier around, so that tokens signed with the key can be
+// rejected as such, but discards the private key since it will never sign again. Only the
+// previous key can be revoked
+func (kr *KeyRotator) RevokeKey(ctx context.Context, keyPair *entities.KeyPair, auditDetails map[string]interface{}) error {
+	err := kr.revokeKey(nil, keyPair)
+	if err != nil {
+		return err
+	}
+
+	kr.tokenParser.InvalidateKeySet()
+
+	kr.logRevokedKey(ctx, keyPair, auditDetails)
+	return nil
+}
+
+func (kr *KeyRotator) revokeKey(tx *sql.Tx, keyPair *entities.KeyPair) error {
+	keyPair.State = enums.KeyStateRevoked.String()
+	keyPair.PrivateKeyPEMEncrypted = nil
+	return kr.updateKeyIfState(tx, keyPair, enums.KeyStatePrevious)
+}
+
+func (kr *KeyRotator) updateKeyIfState(tx *sql.Tx, keyPair *entities.KeyPair, state enums.KeyState) error {
+	updated, err := kr.database.UpdateKeyPairIfState(tx, keyPair, state.String())
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return errors.WithStack(errSigningKeysChanged)
+	}
+	return nil
+}
+
+func (kr *KeyRotator) logRevokedKey(ctx context.Context, keyPair *entities.KeyPair, auditDetails map[string]interface{}) {
+	details := map[string]interface{}{
+		"keyId": keyPair.KeyIdentifier,
+	}
+	for k, v := range auditDetails {
+		details[k] = v
+	}
+	lib.LogAudit(ctx, constants.AuditRevokedKey, details)
+}
+
+// GetSchedule works out when the keys will be rotated and the previous key revoked. The current
+// key is rotated once it has been active for the rotation interval, provided the next key has
+// been published for at least the overlap window. The previous key is revoked once every
+// token it signed has expired, and since a rotation revokes the previous key, the keys are
+// not rotated before that
+func (kr *KeyRotator) GetSchedule(settings *entities.Settings) (*KeyRotationSchedule, error) {
+	if settings.KeyRotationIntervalInDays <= 0 {
+		return &KeyRotationSchedule{}, nil
+	}
+
+	keys, err := kr.getSigningKeys()
+	if err != nil {
+		return nil, err
+	}
+	return kr.getSchedule(settings, keys)
+}
+
+func (kr *KeyRotator) getSchedule(settings *entities.Settings, keys *signingKeys) (*KeyRotationSchedule, error) {
+	schedule := &KeyRotationSchedule{}
+
+	if keys.previous != nil {
+		longestTokenLifetime, err := kr.getLongestTokenLifetime(settings)
+		if err != nil {
+			return nil, err
+		}
+
+		deactivatedAt := keys.previous.DeactivatedAt
+		if !deactivatedAt.Valid {
+			deactivatedAt = keys.previous.UpdatedAt
+		}
+		schedule.PreviousKeyRevocation = deactivatedAt.Time.Add(longestTokenLifetime)
+	}
+
+	activatedAt := keys.current.ActivatedAt
+	if !activatedAt.Valid {
+		activatedAt = keys.current.CreatedAt
+	}
+	schedule.NextRotation = activatedAt.Time.AddDate(0, 0, settings.KeyRotationIntervalInDays)
+
+	publishedUntil := keys.next.CreatedAt.Time.AddDate(0, 0, settings.KeyRotationOverlapInDays)
+	if publishedUntil.After(schedule.NextRotation) {
+		schedule.NextRotation = publishedUntil
+	}
+
+	if schedule.PreviousKeyRevocation.After(schedule.NextRotation) {
+		schedule.NextRotation = schedule.PreviousKeyRevocation
+	}
+
+	return schedule, nil
+}
+
+// getLongestTokenLifetime is the longest time a token signed by a key can remain valid,
+// taking into account the per-client overrides
+func (kr *KeyRotator) getLongestTokenLifetime(settings *entities.Settings) (time.Duration, error) {
+	longest := max(settings.TokenExpirationInSeconds,
+		settings.RefreshTokenOfflineMaxLifetimeInSeconds,
+		settings.UserSessionMaxLifetimeInSeconds)
+
+	clients, err := kr.database.GetAllClients(nil)
+	if err != nil {
+		return 0, err
+	}
+	for _, client := range clients {
+		longest = max(longest, client.TokenExpirationInSeconds, client.RefreshTokenOfflineMaxLifetimeInSeconds)
+	}
+
+	return time.Duration(longest) * time.Second, nil
+}
+
+// RunScheduledRotation revokes the previous key and rotates the keys when they're due. Every
+// instance runs the schedule, and since the keys only move from the state they were read in,
+// an operation that another instance got to first is skipped
+func (kr *KeyRotator) RunScheduledRotation(ctx context.Context, settings *entities.Settings, now time.Time) error {
+	if settings.KeyRotationIntervalInDays <= 0 {
+		return nil
+	}
+
+	keys, err := kr.getSigningKeys()
+	if err != nil {
+		return err
+	}
+
+	schedule, err := kr.getSchedule(settings, keys)
+	if err != nil {
+		return err
+	}
+
+	auditDetails := map[string]interface{}{
+		"scheduled": true,
+	}
+
+	if !schedule.PreviousKeyRevocation.IsZero() && !now.Before(schedule.PreviousKeyRevocation) {
+		err = kr.RevokeKey(ctx, keys.previous, auditDetails)
+		if errors.Is(err, errSigningKeysChanged) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		keys.previous = nil
+	}
+
+	if !schedule.NextRotation.IsZero() && !now.Before(schedule.NextRotation) {
+		err = kr.rotateKeys(ctx, settings, keys, "", auditDetails)
+		if err != nil && !errors.Is(err, errSigningKeysChanged) {
+			return err
+		}
+	}
+
+	return nil
+}
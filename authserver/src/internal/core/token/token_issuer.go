@@ -2,7 +2,6 @@ package core
 
 import (
 	"context"
-	"crypto/rsa"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -35,15 +34,55 @@ func NewTokenIssuer(database data.Database, tokenParser *TokenParser) *TokenIssu
 	}
 }
 
+// TokenConfirmation binds an access token to a key held by the client (the cnf claim, RFC 7800),
+// so that the token can only be used by a client able to prove possession of that key
+type TokenConfirmation struct {
+	// X5tS256 is the thumbprint of the client certificate used for mutual TLS (RFC 8705)
+	X5tS256 string
+	// Jkt is the thumbprint of the public key of the DPoP proof (RFC 9449)
+	Jkt string
+}
+
+func (c *TokenConfirmation) claim() map[string]string {
+	cnf := map[string]string{}
+	if len(c.X5tS256) > 0 {
+		cnf["x5t#S256"] = c.X5tS256
+	}
+	if len(c.Jkt) > 0 {
+		cnf["jkt"] = c.Jkt
+	}
+	return cnf
+}
+
+// tokenType is DPoP for access tokens bound to a DPoP key, and Bearer otherwise
+func (c *TokenConfirmation) tokenType() string {
+	if c != nil && len(c.Jkt) > 0 {
+		return "DPoP"
+	}
+	return enums.TokenTypeBearer.String()
+}
+
+// refreshTokenConfirmation returns the binding of the refresh tokens. Only the refresh tokens
+// of public clients are bound to the DPoP key, since confidential clients authenticate when
+// they use them (RFC 9449, section 5)
+func refreshTokenConfirmation(client *entities.Client, confirmation *TokenConfirmation) *TokenConfirmation {
+	if !client.IsPublic || confirmation == nil || len(confirmation.Jkt) == 0 {
+		return nil
+	}
+	return &TokenConfirmation{Jkt: confirmation.Jkt}
+}
+
 type GenerateTokenForRefreshInput struct {
 	Code             *entities.Code
 	ScopeRequested   string
 	RefreshToken     *entities.RefreshToken
 	RefreshTokenInfo *dtos.JwtToken
+	Confirmation     *TokenConfirmation
 }
 
 type GenerateTokenResponseForAuthCodeInput struct {
-	Code *entities.Code
+	Code         *entities.Code
+	Confirmation *TokenConfirmation
 }
 
 func (t *TokenIssuer) GenerateTokenResponseForAuthCode(ctx context.Context,
@@ -62,20 +101,15 @@ func (t *TokenIssuer) GenerateTokenResponseForAuthCode(ctx context.Context,
 	}
 
 	var tokenResponse = dtos.TokenResponse{
-		TokenType: enums.TokenTypeBearer.String(),
+		TokenType: input.Confirmation.tokenType(),
 		ExpiresIn: int64(tokenExpirationInSeconds),
 	}
 
-	keyPair, err := t.database.GetCurrentSigningKey(nil)
+	signer, err := t.getCurrentTokenSigner(settings)
 	if err != nil {
 		return nil, err
 	}
 
-	privKey, err := jwt.ParseRSAPrivateKeyFromPEM(keyPair.PrivateKeyPEM)
-	if err != nil {
-		return nil, errors.Wrap(err, "unable to parse private key from PEM")
-	}
-
 	now := time.Now().UTC()
 
 	// access_token -----------------------------------------------------------------------
@@ -100,7 +134,7 @@ func (t *TokenIssuer) GenerateTokenResponseForAuthCode(ctx context.Context,
 		return nil, err
 	}
 
-	accessTokenStr, scopeFromAccessToken, err := t.generateAccessToken(settings, input.Code, input.Code.Scope, now, privKey, keyPair.KeyIdentifier)
+	accessTokenStr, scopeFromAccessToken, err := t.generateAccessToken(settings, input.Code, input.Code.Scope, now, signer, input.Confirmation)
 	if err != nil {
 		return nil, err
 	}
@@ -111,7 +145,11 @@ func (t *TokenIssuer) GenerateTokenResponseForAuthCode(ctx context.Context,
 
 	scopes := strings.Split(input.Code.Scope, " ")
 	if slices.Contains(scopes, "openid") {
-		idTokenStr, err := t.generateIdToken(settings, input.Code, input.Code.Scope, now, privKey, keyPair.KeyIdentifier)
+		idTokenSigner, err := t.getIdTokenSigner(&input.Code.Client, signer)
+		if err != nil {
+			return nil, err
+		}
+		idTokenStr, err := t.generateIdToken(settings, input.Code, input.Code.Scope, now, idTokenSigner)
 		if err != nil {
 			return nil, err
 		}
@@ -120,7 +158,8 @@ func (t *TokenIssuer) GenerateTokenResponseForAuthCode(ctx context.Context,
 
 	// refresh_token ----------------------------------------------------------------------
 
-	refreshToken, refreshExpiresIn, err := t.generateRefreshToken(settings, input.Code, scopeFromAccessToken, now, privKey, keyPair.KeyIdentifier, nil)
+	refreshToken, refreshExpiresIn, err := t.generateRefreshToken(settings, input.Code, scopeFromAccessToken, now, signer, nil,
+		refreshTokenConfirmation(&input.Code.Client, input.Confirmation))
 	if err != nil {
 		return nil, err
 	}
@@ -131,7 +170,7 @@ func (t *TokenIssuer) GenerateTokenResponseForAuthCode(ctx context.Context,
 }
 
 func (t *TokenIssuer) generateAccessToken(settings *entities.Settings, code *entities.Code, scope string,
-	now time.Time, signingKey *rsa.PrivateKey, keyIdentifier string) (string, string, error) {
+	now time.Time, signer *tokenSigner, confirmation *TokenConfirmation) (string, string, error) {
 
 	claims := make(jwt.MapClaims)
 
@@ -143,6 +182,7 @@ func (t *TokenIssuer) generateAccessToken(settings *entities.Settings, code *ent
 	claims["acr"] = code.AcrLevel
 	claims["amr"] = code.AuthMethods
 	claims["sid"] = code.SessionIdentifier
+	claims["client_id"] = code.Client.ClientIdentifier
 
 	scopes := strings.Split(scope, " ")
 
@@ -196,6 +236,9 @@ func (t *TokenIssuer) generateAccessToken(settings *entities.Settings, code *ent
 	if len(code.Nonce) > 0 {
 		claims["nonce"] = code.Nonce
 	}
+	if confirmation != nil {
+		claims["cnf"] = confirmation.claim()
+	}
 
 	includeOpenIDConnectClaimsInAccessToken := settings.IncludeOpenIDConnectClaimsInAccessToken
 	if code.Client.IncludeOpenIDConnectClaimsInAccessToken != enums.ThreeStateSettingDefault.String() {
@@ -240,9 +283,7 @@ func (t *TokenIssuer) generateAccessToken(settings *entities.Settings, code *ent
 		}
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	token.Header["kid"] = keyIdentifier
-	accessToken, err := token.SignedString(signingKey)
+	accessToken, err := signer.sign(claims)
 	if err != nil {
 		return "", "", errors.Wrap(err, "unable to sign access_token")
 	}
@@ -250,7 +291,7 @@ func (t *TokenIssuer) generateAccessToken(settings *entities.Settings, code *ent
 }
 
 func (t *TokenIssuer) generateIdToken(settings *entities.Settings, code *entities.Code, scope string,
-	now time.Time, signingKey *rsa.PrivateKey, keyIdentifier string) (string, error) {
+	now time.Time, signer *tokenSigner) (string, error) {
 
 	claims := make(jwt.MapClaims)
 
@@ -313,9 +354,7 @@ func (t *TokenIssuer) generateIdToken(settings *entities.Settings, code *entitie
 		}
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	token.Header["kid"] = keyIdentifier
-	idToken, err := token.SignedString(signingKey)
+	idToken, err := signer.sign(claims)
 	if err != nil {
 		return "", errors.Wrap(err, "unable to sign id_token")
 	}
@@ -323,7 +362,7 @@ func (t *TokenIssuer) generateIdToken(settings *entities.Settings, code *entitie
 }
 
 func (t *TokenIssuer) generateRefreshToken(settings *entities.Settings, code *entities.Code, scope string,
-	now time.Time, signingKey *rsa.PrivateKey, keyIdentifier string, refreshToken *entities.RefreshToken) (string, int64, error) {
+	now time.Time, signer *tokenSigner, refreshToken *entities.RefreshToken, confirmation *TokenConfirmation) (string, int64, error) {
 
 	claims := make(jwt.MapClaims)
 
@@ -379,6 +418,9 @@ func (t *TokenIssuer) generateRefreshToken(settings *entities.Settings, code *en
 		}
 	}
 	claims["scope"] = scope
+	if confirmation != nil {
+		claims["cnf"] = confirmation.claim()
+	}
 
 	// save 1st refresh token
 	refreshTokenEntity := &entities.RefreshToken{
@@ -410,9 +452,7 @@ func (t *TokenIssuer) generateRefreshToken(settings *entities.Settings, code *en
 		return "", 0, err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	token.Header["kid"] = keyIdentifier
-	rt, err := token.SignedString(signingKey)
+	rt, err := signer.sign(claims)
 	if err != nil {
 		return "", 0, errors.Wrap(err, "unable to sign refresh_token")
 	}
@@ -460,26 +500,21 @@ func (t *TokenIssuer) getRefreshTokenMaxLifetime(refreshTokenType string, now ti
 }
 
 func (t *TokenIssuer) GenerateTokenResponseForClientCred(ctx context.Context, client *entities.Client,
-	scope string) (*dtos.TokenResponse, error) {
+	scope string, confirmation *TokenConfirmation) (*dtos.TokenResponse, error) {
 
 	settings := ctx.Value(common.ContextKeySettings).(*entities.Settings)
 
 	var tokenResponse = dtos.TokenResponse{
-		TokenType: "Bearer",
+		TokenType: confirmation.tokenType(),
 		ExpiresIn: int64(settings.TokenExpirationInSeconds),
 		Scope:     scope,
 	}
 
-	keyPair, err := t.database.GetCurrentSigningKey(nil)
+	signer, err := t.getCurrentTokenSigner(settings)
 	if err != nil {
 		return nil, err
 	}
 
-	privKey, err := jwt.ParseRSAPrivateKeyFromPEM(keyPair.PrivateKeyPEM)
-	if err != nil {
-		return nil, errors.Wrap(err, "unable to parse private key from PEM")
-	}
-
 	now := time.Now().UTC()
 	claims := make(jwt.MapClaims)
 	scopes := strings.Split(scope, " ")
@@ -490,6 +525,7 @@ func (t *TokenIssuer) GenerateTokenResponseForClientCred(ctx context.Context, cl
 	claims["sub"] = client.ClientIdentifier
 	claims["iat"] = now.Unix()
 	claims["jti"] = uuid.New().String()
+	claims["client_id"] = client.ClientIdentifier
 
 	audCollection := []string{}
 	for _, scope := range scopes {
@@ -512,10 +548,11 @@ func (t *TokenIssuer) GenerateTokenResponseForClientCred(ctx context.Context, cl
 	claims["typ"] = enums.TokenTypeBearer.String()
 	claims["exp"] = now.Add(time.Duration(time.Second * time.Duration(settings.TokenExpirationInSeconds))).Unix()
 	claims["scope"] = scope
+	if confirmation != nil {
+		claims["cnf"] = confirmation.claim()
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	token.Header["kid"] = keyPair.KeyIdentifier
-	accessToken, err := token.SignedString(privKey)
+	accessToken, err := signer.sign(claims)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to sign access_token")
 	}
@@ -543,20 +580,15 @@ func (t *TokenIssuer) GenerateTokenResponseForRefresh(ctx context.Context, input
 	}
 
 	var tokenResponse = dtos.TokenResponse{
-		TokenType: enums.TokenTypeBearer.String(),
+		TokenType: input.Confirmation.tokenType(),
 		ExpiresIn: int64(tokenExpirationInSeconds),
 	}
 
-	keyPair, err := t.database.GetCurrentSigningKey(nil)
+	signer, err := t.getCurrentTokenSigner(settings)
 	if err != nil {
 		return nil, err
 	}
 
-	privKey, err := jwt.ParseRSAPrivateKeyFromPEM(keyPair.PrivateKeyPEM)
-	if err != nil {
-		return nil, errors.Wrap(err, "unable to parse private key from PEM")
-	}
-
 	now := time.Now().UTC()
 
 	// access_token -----------------------------------------------------------------------
@@ -581,7 +613,7 @@ func (t *TokenIssuer) GenerateTokenResponseForRefresh(ctx context.Context, input
 		return nil, err
 	}
 
-	accessTokenStr, scopeFromAccessToken, err := t.generateAccessToken(settings, input.Code, scopeToUse, now, privKey, keyPair.KeyIdentifier)
+	accessTokenStr, scopeFromAccessToken, err := t.generateAccessToken(settings, input.Code, scopeToUse, now, signer, input.Confirmation)
 	if err != nil {
 		return nil, err
 	}
@@ -592,7 +624,11 @@ func (t *TokenIssuer) GenerateTokenResponseForRefresh(ctx context.Context, input
 
 	scopes := strings.Split(scopeToUse, " ")
 	if slices.Contains(scopes, "openid") {
-		idTokenStr, err := t.generateIdToken(settings, input.Code, scopeToUse, now, privKey, keyPair.KeyIdentifier)
+		idTokenSigner, err := t.getIdTokenSigner(&input.Code.Client, signer)
+		if err != nil {
+			return nil, err
+		}
+		idTokenStr, err := t.generateIdToken(settings, input.Code, scopeToUse, now, idTokenSigner)
 		if err != nil {
 			return nil, err
 		}
@@ -601,7 +637,8 @@ func (t *TokenIssuer) GenerateTokenResponseForRefresh(ctx context.Context, input
 
 	// refresh_token ----------------------------------------------------------------------
 
-	refreshToken, refreshExpiresIn, err := t.generateRefreshToken(settings, input.Code, scopeFromAccessToken, now, privKey, keyPair.KeyIdentifier, input.RefreshToken)
+	refreshToken, refreshExpiresIn, err := t.generateRefreshToken(settings, input.Code, scopeFromAccessToken, now, signer, input.RefreshToken,
+		refreshTokenConfirmation(&input.Code.Client, input.Confirmation))
 	if err != nil {
 		return nil, err
 	}
@@ -653,3 +690,38 @@ func (tm *TokenIssuer) addClaimIfNotEmpty(claims jwt.MapClaims, claimName string
 		claims[claimName] = claimValue
 	}
 }
+
+// GenerateLogoutToken creates the logout token that is sent to the backchannel_logout_uri of a
+// client, as defined in OpenID Connect Back-Channel Logout 1.0
+func (t *TokenIssuer) GenerateLogoutToken(settings *entities.Settings, client *entities.Client,
+	subject string, sessionIdentifier string) (string, error) {
+
+	currentSigner, err := t.getCurrentTokenSigner(settings)
+	if err != nil {
+		return "", err
+	}
+	signer, err := t.getIdTokenSigner(client, currentSigner)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	claims := make(jwt.MapClaims)
+
+	claims["iss"] = settings.Issuer
+	claims["sub"] = subject
+	claims["aud"] = client.ClientIdentifier
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(2 * time.Minute).Unix()
+	claims["jti"] = uuid.New().String()
+	claims["sid"] = sessionIdentifier
+	claims["events"] = map[string]interface{}{
+		"http://schemas.openid.net/event/backchannel-logout": map[string]interface{}{},
+	}
+
+	logoutToken, err := signer.signWithType(claims, "logout+jwt")
+	if err != nil {
+		return "", errors.Wrap(err, "unable to sign logout_token")
+	}
+	return logoutToken, nil
+}
@@ -2,15 +2,49 @@ package core
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/leodip/goiabada/internal/data"
 	"github.com/leodip/goiabada/internal/dtos"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/pkg/errors"
 )
 
+// keySetCacheDuration is how long the verification keys are kept in memory. The key set is
+// also reloaded when a token references an unknown kid, and invalidated on rotation or revocation.
+// The invalidation only reaches the process that rotated or revoked the keys; other instances
+// pick up the change when their cache expires, so this is kept short
+const keySetCacheDuration = 1 * time.Minute
+
+// keySetMinReloadInterval limits how often a token with an unknown kid can trigger a reload
+// of the key set, so that tokens with made-up kids can't be used to flood the database
+const keySetMinReloadInterval = 10 * time.Second
+
+// maxUnknownKids bounds the negative cache of kids that were not found after a reload
+const maxUnknownKids = 1000
+
+type verificationKey struct {
+	publicKey interface{}
+	algorithm string
+	revoked   bool
+}
+
+type keySet struct {
+	keys        map[string]verificationKey
+	currentKid  string
+	loadedAt    time.Time
+	unknownKids map[string]struct{}
+}
+
 type TokenParser struct {
 	database data.Database
+
+	keySetMutex sync.Mutex
+	keySet      *keySet
 }
 
 func NewTokenParser(database data.Database) *TokenParser {
@@ -19,18 +53,119 @@ func NewTokenParser(database data.Database) *TokenParser {
 	}
 }
 
-func (tp *TokenParser) ParseTokenResponse(ctx context.Context, tokenResponse *dtos.TokenResponse) (*dtos.JwtInfo, error) {
+// InvalidateKeySet discards the cached verification keys, so that they're reloaded from the
+// database when the next token is parsed
+func (tp *TokenParser) InvalidateKeySet() {
+	tp.keySetMutex.Lock()
+	defer tp.keySetMutex.Unlock()
+	tp.keySet = nil
+}
 
-	keyPair, err := tp.database.GetCurrentSigningKey(nil)
+func (tp *TokenParser) getKeySet(forceReload bool) (*keySet, error) {
+	tp.keySetMutex.Lock()
+	defer tp.keySetMutex.Unlock()
+
+	if tp.keySet != nil {
+		age := time.Since(tp.keySet.loadedAt)
+		if (!forceReload && age < keySetCacheDuration) || (forceReload && age < keySetMinReloadInterval) {
+			return tp.keySet, nil
+		}
+	}
+
+	allSigningKeys, err := tp.database.GetAllSigningKeys(nil)
 	if err != nil {
 		return nil, err
 	}
 
-	pubKey, err := jwt.ParseRSAPublicKeyFromPEM(keyPair.PublicKeyPEM)
+	ks := &keySet{
+		keys:        make(map[string]verificationKey, len(allSigningKeys)),
+		loadedAt:    time.Now().UTC(),
+		unknownKids: make(map[string]struct{}),
+	}
+	for _, signingKey := range allSigningKeys {
+		keyState, err := enums.KeyStateFromString(signingKey.State)
+		if err != nil {
+			return nil, err
+		}
+		if keyState == enums.KeyStateRevoked {
+			ks.keys[signingKey.KeyIdentifier] = verificationKey{revoked: true}
+			continue
+		}
+
+		pubKey, err := lib.ParseSigningPublicKeyFromPEM(signingKey.Algorithm, signingKey.PublicKeyPEM)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("unable to parse the public key %v", signingKey.KeyIdentifier))
+		}
+		ks.keys[signingKey.KeyIdentifier] = verificationKey{
+			publicKey: pubKey,
+			algorithm: signingKey.Algorithm,
+		}
+		if keyState == enums.KeyStateCurrent {
+			ks.currentKid = signingKey.KeyIdentifier
+		}
+	}
+
+	tp.keySet = ks
+	return ks, nil
+}
+
+// isUnknownKid returns true if the kid was already looked up, and not found, since the key
+// set was loaded. Such kids don't trigger another reload until the key set expires
+func (tp *TokenParser) isUnknownKid(ks *keySet, kid string) bool {
+	tp.keySetMutex.Lock()
+	defer tp.keySetMutex.Unlock()
+	_, ok := ks.unknownKids[kid]
+	return ok
+}
+
+func (tp *TokenParser) addUnknownKid(ks *keySet, kid string) {
+	tp.keySetMutex.Lock()
+	defer tp.keySetMutex.Unlock()
+	if len(ks.unknownKids) < maxUnknownKids {
+		ks.unknownKids[kid] = struct{}{}
+	}
+}
+
+// getVerificationKey is the jwt.Keyfunc used to parse tokens. It picks the key by the kid
+// header; tokens without a kid are verified with the current key
+func (tp *TokenParser) getVerificationKey(token *jwt.Token) (interface{}, error) {
+	ks, err := tp.getKeySet(false)
 	if err != nil {
 		return nil, err
 	}
 
+	kid, _ := token.Header["kid"].(string)
+	if len(kid) == 0 {
+		kid = ks.currentKid
+	}
+
+	key, ok := ks.keys[kid]
+	if !ok && !tp.isUnknownKid(ks, kid) {
+		// the key may have been created after the key set was loaded
+		ks, err = tp.getKeySet(true)
+		if err != nil {
+			return nil, err
+		}
+		key, ok = ks.keys[kid]
+		if !ok {
+			tp.addUnknownKid(ks, kid)
+		}
+	}
+	if !ok {
+		return nil, errors.WithStack(fmt.Errorf("unknown signing key %v", kid))
+	}
+
+	if key.revoked {
+		return nil, errors.WithStack(fmt.Errorf("the token was signed with the revoked key %v", kid))
+	}
+	if token.Method.Alg() != key.algorithm {
+		return nil, errors.WithStack(fmt.Errorf("unexpected signing algorithm %v for key %v", token.Method.Alg(), kid))
+	}
+	return key.publicKey, nil
+}
+
+func (tp *TokenParser) ParseTokenResponse(ctx context.Context, tokenResponse *dtos.TokenResponse) (*dtos.JwtInfo, error) {
+
 	result := &dtos.JwtInfo{
 		TokenResponse: *tokenResponse,
 	}
@@ -41,9 +176,7 @@ func (tp *TokenParser) ParseTokenResponse(ctx context.Context, tokenResponse *dt
 			TokenBase64: tokenResponse.AccessToken,
 		}
 
-		token, err := jwt.ParseWithClaims(tokenResponse.AccessToken, claimsAccessToken, func(token *jwt.Token) (interface{}, error) {
-			return pubKey, nil
-		})
+		token, err := jwt.ParseWithClaims(tokenResponse.AccessToken, claimsAccessToken, tp.getVerificationKey)
 		if err != nil {
 			return nil, err
 		}
@@ -65,9 +198,7 @@ func (tp *TokenParser) ParseTokenResponse(ctx context.Context, tokenResponse *dt
 			TokenBase64: tokenResponse.IdToken,
 		}
 
-		token, err := jwt.ParseWithClaims(tokenResponse.IdToken, claimsIdToken, func(token *jwt.Token) (interface{}, error) {
-			return pubKey, nil
-		})
+		token, err := jwt.ParseWithClaims(tokenResponse.IdToken, claimsIdToken, tp.getVerificationKey)
 		if err != nil {
 			return nil, err
 		}
@@ -89,9 +220,7 @@ func (tp *TokenParser) ParseTokenResponse(ctx context.Context, tokenResponse *dt
 			TokenBase64: tokenResponse.RefreshToken,
 		}
 
-		token, err := jwt.ParseWithClaims(tokenResponse.RefreshToken, claimsRefreshToken, func(token *jwt.Token) (interface{}, error) {
-			return pubKey, nil
-		})
+		token, err := jwt.ParseWithClaims(tokenResponse.RefreshToken, claimsRefreshToken, tp.getVerificationKey)
 		if err != nil {
 			return nil, err
 		}
@@ -111,16 +240,6 @@ func (tp *TokenParser) ParseTokenResponse(ctx context.Context, tokenResponse *dt
 }
 
 func (tp *TokenParser) ParseToken(ctx context.Context, token string, validateClaims bool) (*dtos.JwtToken, error) {
-	keyPair, err := tp.database.GetCurrentSigningKey(nil)
-	if err != nil {
-		return nil, err
-	}
-
-	pubKey, err := jwt.ParseRSAPublicKeyFromPEM(keyPair.PublicKeyPEM)
-	if err != nil {
-		return nil, err
-	}
-
 	result := &dtos.JwtToken{
 		TokenBase64: token,
 	}
@@ -128,19 +247,27 @@ func (tp *TokenParser) ParseToken(ctx context.Context, token string, validateCla
 	if len(token) > 0 {
 		claims := jwt.MapClaims{}
 
-		token, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
-			return pubKey, nil
-		})
+		// without claims validation, an expired token is still accepted (with IsExpired set),
+		// as long as its signature is valid. That's how hints like id_token_hint are used
+		parserOptions := []jwt.ParserOption{}
+		if !validateClaims {
+			parserOptions = append(parserOptions, jwt.WithoutClaimsValidation())
+		}
+
+		token, err := jwt.ParseWithClaims(token, claims, tp.getVerificationKey, parserOptions...)
 		if err != nil {
 			return nil, err
 		}
 
 		result.SignatureIsValid = token.Valid
-		exp := claims["exp"].(float64)
+		exp, _ := claims["exp"].(float64)
 		expirationTime := time.Unix(int64(exp), 0).UTC()
 		currentTime := time.Now().UTC()
 		if currentTime.After(expirationTime) {
 			result.IsExpired = true
+			if !validateClaims {
+				result.Claims = claims
+			}
 		} else {
 			result.Claims = claims
 		}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"crypto"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/pkg/errors"
+)
+
+// tokenSigner signs tokens with a key pair, using the algorithm of the key
+type tokenSigner struct {
+	keyIdentifier string
+	method        jwt.SigningMethod
+	privateKey    crypto.PrivateKey
+}
+
+func newTokenSigner(keyPair *entities.KeyPair, aesEncryptionKey []byte) (*tokenSigner, error) {
+	if len(keyPair.PrivateKeyPEMEncrypted) == 0 {
+		return nil, errors.WithStack(fmt.Errorf("the key %v has no private key", keyPair.KeyIdentifier))
+	}
+	privateKeyPEM, err := lib.DecryptText(keyPair.PrivateKeyPEMEncrypted, aesEncryptionKey)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("unable to decrypt the private key %v", keyPair.KeyIdentifier))
+	}
+
+	privateKey, err := lib.ParseSigningPrivateKeyFromPEM(keyPair.Algorithm, []byte(privateKeyPEM))
+	if err != nil {
+		return nil, err
+	}
+
+	method := jwt.GetSigningMethod(keyPair.Algorithm)
+	if method == nil {
+		return nil, errors.WithStack(fmt.Errorf("unsupported signing algorithm %v", keyPair.Algorithm))
+	}
+
+	return &tokenSigner{
+		keyIdentifier: keyPair.KeyIdentifier,
+		method:        method,
+		privateKey:    privateKey,
+	}, nil
+}
+
+func (ts *tokenSigner) sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(ts.method, claims)
+	token.Header["kid"] = ts.keyIdentifier
+	return token.SignedString(ts.privateKey)
+}
+
+// signWithType signs the claims with an explicit typ header, for tokens that must not be
+// confused with id tokens or access tokens
+func (ts *tokenSigner) signWithType(claims jwt.MapClaims, typ string) (string, error) {
+	token := jwt.NewWithClaims(ts.method, claims)
+	token.Header["kid"] = ts.keyIdentifier
+	token.Header["typ"] = typ
+	return token.SignedString(ts.privateKey)
+}
+
+func (t *TokenIssuer) getCurrentTokenSigner(settings *entities.Settings) (*tokenSigner, error) {
+	keyPair, err := t.database.GetCurrentSigningKey(nil)
+	if err != nil {
+		return nil, err
+	}
+	if keyPair == nil {
+		return nil, errors.WithStack(errors.New("no current signing key found"))
+	}
+	return newTokenSigner(keyPair, settings.AESEncryptionKey)
+}
+
+// getIdTokenSigner returns the signer for the id tokens of a client. Id tokens are only signed
+// with the current key, so when the client asked for another algorithm the token can't be issued
+func (t *TokenIssuer) getIdTokenSigner(client *entities.Client, currentSigner *tokenSigner) (*tokenSigner, error) {
+	algorithm := client.IdTokenSignedResponseAlg
+	if len(algorithm) == 0 || algorithm == currentSigner.method.Alg() {
+		return currentSigner, nil
+	}
+
+	return nil, errors.WithStack(fmt.Errorf("client %v requires id tokens signed with %v, but the current signing key uses %v",
+		client.ClientIdentifier, algorithm, currentSigner.method.Alg()))
+}
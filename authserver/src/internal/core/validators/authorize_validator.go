@@ -15,13 +15,22 @@ import (
 )
 
 type AuthorizeValidator struct {
-	database data.Database
+	database        data.Database
+	clientJWKSCache *clientJWKSCache
 }
 
 type ValidateClientAndRedirectURIInput struct {
 	RequestId   string
 	ClientId    string
 	RedirectURI string
+
+	// IsPushedAuthorizationRequest is true when the parameters were pushed by the
+	// client to the PAR endpoint (RFC 9126)
+	IsPushedAuthorizationRequest bool
+
+	// IsSignedRequestObject is true when the parameters were sent in a signed
+	// request object (RFC 9101)
+	IsSignedRequestObject bool
 }
 
 type ValidateRequestInput struct {
@@ -29,11 +38,13 @@ type ValidateRequestInput struct {
 	CodeChallengeMethod string
 	CodeChallenge       string
 	ResponseMode        string
+	Prompt              string
 }
 
 func NewAuthorizeValidator(database data.Database) *AuthorizeValidator {
 	return &AuthorizeValidator{
-		database: database,
+		database:        database,
+		clientJWKSCache: newClientJWKSCache(),
 	}
 }
 
@@ -112,6 +123,12 @@ func (val *AuthorizeValidator) ValidateClientAndRedirectURI(ctx context.Context,
 	if !client.AuthorizationCodeEnabled {
 		return customerrors.NewValidationError("", "The client associated with the provided client_id does not support authorization code flow.")
 	}
+	if client.RequirePushedAuthorizationRequests && !input.IsPushedAuthorizationRequest {
+		return customerrors.NewValidationError("", "The client associated with the provided client_id is required to use pushed authorization requests (PAR). Please push the authorization request to the PAR endpoint and use the request_uri it returns.")
+	}
+	if client.RequireSignedRequestObject && !input.IsSignedRequestObject {
+		return customerrors.NewValidationError("", "The client associated with the provided client_id is required to send the authorization request as a signed request object (JAR). Please provide the request parameter.")
+	}
 
 	if len(input.RedirectURI) == 0 {
 		return customerrors.NewValidationError("", "The redirect_uri parameter is missing.")
@@ -153,5 +170,15 @@ func (val *AuthorizeValidator) ValidateRequest(ctx context.Context, input *Valid
 			return customerrors.NewValidationError("invalid_request", "Please use 'query,' 'fragment,' or 'form_post' as the response_mode value.")
 		}
 	}
+
+	prompts := strings.Fields(input.Prompt)
+	for _, prompt := range prompts {
+		if !slices.Contains([]string{"none", "login", "consent", "select_account"}, prompt) {
+			return customerrors.NewValidationError("invalid_request", "Please use 'none', 'login', 'consent' or 'select_account' as the prompt value.")
+		}
+	}
+	if slices.Contains(prompts, "none") && len(prompts) > 1 {
+		return customerrors.NewValidationError("invalid_request", "The prompt value 'none' can't be combined with other values.")
+	}
 	return nil
 }
@@ -0,0 +1,392 @@
+package core
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+
+	"github.com/leodip/goiabada/internal/common"
+	"github.com/leodip/goiabada/internal/customerrors"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/leodip/goiabada/internal/lib"
+)
+
+// ClientAssertionTypeJwtBearer is the client_assertion_type of a JWT client assertion (RFC 7523)
+const ClientAssertionTypeJwtBearer = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// clientJWKSCacheDuration is how long the keys fetched from a client's jwks_uri are kept in
+// memory. The keys are fetched again when an assertion references an unknown kid
+const clientJWKSCacheDuration = 5 * time.Minute
+
+// clientJWKSMinReloadInterval limits how often an assertion with an unknown kid can trigger
+// another fetch of the client's jwks_uri
+const clientJWKSMinReloadInterval = 10 * time.Second
+
+// ClientAssertionSigningAlgorithms returns the algorithms a client can sign its assertions with
+func ClientAssertionSigningAlgorithms() []string {
+	return []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+}
+
+// ClientCredentials are the credentials presented by a client: a client secret, sent in the
+// request body (client_secret_post) or in the Authorization header (client_secret_basic), or a
+// client assertion signed with the client's private key (private_key_jwt), or the certificate
+// chain presented during the TLS handshake (tls_client_auth, self_signed_tls_client_auth)
+type ClientCredentials struct {
+	ClientId            string
+	ClientSecret        string
+	ClientAssertionType string
+	ClientAssertion     string
+	ClientCertificates  []*x509.Certificate
+}
+
+// resolveClientId takes the client identifier from the client assertion when the client_id
+// parameter is omitted, which RFC 7523 allows. The assertion is verified later on
+func (cc *ClientCredentials) resolveClientId() {
+	if len(cc.ClientId) > 0 || len(cc.ClientAssertion) == 0 {
+		return
+	}
+	claims := jwt.MapClaims{}
+	_, _, err := jwt.NewParser().ParseUnverified(cc.ClientAssertion, claims)
+	if err != nil {
+		return
+	}
+	cc.ClientId, _ = claims["sub"].(string)
+}
+
+type cachedClientJWKS struct {
+	keySet    *lib.JSONWebKeySet
+	fetchedAt time.Time
+}
+
+// clientJWKSFetch is a fetch of a jwks_uri in progress. Requests that need the same keys wait
+// for it instead of fetching them again
+type clientJWKSFetch struct {
+	done   chan struct{}
+	keySet *lib.JSONWebKeySet
+	err    error
+}
+
+type clientJWKSCache struct {
+	mutex    sync.Mutex
+	entries  map[string]cachedClientJWKS
+	inFlight map[string]*clientJWKSFetch
+}
+
+func newClientJWKSCache() *clientJWKSCache {
+	return &clientJWKSCache{
+		entries:  make(map[string]cachedClientJWKS),
+		inFlight: make(map[string]*clientJWKSFetch),
+	}
+}
+
+// authenticateConfidentialClient checks the credentials of a confidential client, according to
+// how the client is configured to authenticate. secretMismatchError is returned when the client
+// secret doesn't match, since the error code depends on the grant
+func (val *TokenValidator) authenticateConfidentialClient(ctx context.Context, client *entities.Client,
+	credentials *ClientCredentials, secretMismatchError error) error {
+
+	settings := ctx.Value(common.ContextKeySettings).(*entities.Settings)
+
+	if client.IsPrivateKeyJwtAuth() {
+		if len(credentials.ClientSecret) > 0 {
+			return customerrors.NewValidationError("invalid_request", "This client is configured to authenticate with a client assertion signed with its private key (private_key_jwt), which means a client_secret is not accepted. To proceed, please remove the client_secret from your request.")
+		}
+		if len(credentials.ClientAssertion) == 0 {
+			return customerrors.NewValidationError("invalid_request", "This client is configured to authenticate with a client assertion signed with its private key (private_key_jwt). Please provide the client_assertion and client_assertion_type parameters to proceed.")
+		}
+		return val.validateClientAssertion(ctx, client, credentials)
+	}
+
+	if client.IsTLSClientAuth() {
+		if len(credentials.ClientSecret) > 0 || len(credentials.ClientAssertion) > 0 {
+			return customerrors.NewValidationError("invalid_request", "This client is configured to authenticate with a client certificate (mutual TLS), which means a client_secret or client_assertion is not accepted. To proceed, please remove them from your request.")
+		}
+		return val.validateClientCertificate(settings, client, credentials)
+	}
+
+	if len(credentials.ClientAssertion) > 0 {
+		return customerrors.NewValidationError("invalid_request", "This client is configured to authenticate with a client secret, which means a client_assertion is not accepted. To proceed, please remove the client_assertion from your request.")
+	}
+
+	if len(credentials.ClientSecret) == 0 {
+		return customerrors.NewValidationError("invalid_request", "This client is configured as confidential (not public), which means a client_secret is required for authentication. Please provide a valid client_secret to proceed.")
+	}
+
+	clientSecretDecrypted, err := lib.DecryptText(client.ClientSecretEncrypted, settings.AESEncryptionKey)
+	if err != nil {
+		return err
+	}
+	if clientSecretDecrypted != credentials.ClientSecret {
+		return secretMismatchError
+	}
+	return nil
+}
+
+// validateClientCertificate verifies the certificate presented by the client during the TLS
+// handshake (RFC 8705). With tls_client_auth the certificate must chain to a trusted CA and have
+// the registered subject DN. With self_signed_tls_client_auth its public key must be one of the
+// keys registered for the client
+func (val *TokenValidator) validateClientCertificate(settings *entities.Settings, client *entities.Client,
+	credentials *ClientCredentials) error {
+
+	certificateFailed := func(reason string) error {
+		return customerrors.NewValidationError("invalid_client", "Client authentication failed. The client certificate is invalid ("+reason+").")
+	}
+
+	if len(credentials.ClientCertificates) == 0 {
+		return customerrors.NewValidationError("invalid_client", "Client authentication failed. This client is configured to authenticate with a client certificate, but no certificate was presented during the TLS handshake.")
+	}
+	leaf := credentials.ClientCertificates[0]
+
+	now := time.Now()
+	if now.Before(leaf.NotBefore) || now.After(leaf.NotAfter) {
+		return certificateFailed("the certificate is expired or not yet valid")
+	}
+
+	if client.TokenEndpointAuthMethod == enums.TokenEndpointAuthMethodSelfSignedTLSClientAuth.String() {
+		keySet, err := val.clientJWKSCache.getClientJWKS(settings, client, false)
+		if err != nil {
+			return err
+		}
+		for i := range keySet.Keys {
+			publicKey, err := keySet.Keys[i].PublicKey()
+			if err != nil {
+				return err
+			}
+			if key, ok := publicKey.(interface{ Equal(crypto.PublicKey) bool }); ok && key.Equal(leaf.PublicKey) {
+				return nil
+			}
+		}
+		return certificateFailed("the public key is not registered for the client")
+	}
+
+	caCertPool, err := lib.GetMTLSCACertPool()
+	if err != nil {
+		return err
+	}
+	if caCertPool == nil {
+		return certificateFailed("no trusted CA is configured")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range credentials.ClientCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         caCertPool,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return certificateFailed(err.Error())
+	}
+
+	if leaf.Subject.String() != client.TLSClientAuthSubjectDN {
+		return certificateFailed("the subject DN does not match")
+	}
+	return nil
+}
+
+// validateClientAssertion verifies a client assertion (RFC 7523, OpenID Connect Core section 9).
+// The assertion must be signed with one of the keys registered for the client, be addressed to
+// this server, and be used only once
+func (val *TokenValidator) validateClientAssertion(ctx context.Context, client *entities.Client, credentials *ClientCredentials) error {
+
+	settings := ctx.Value(common.ContextKeySettings).(*entities.Settings)
+
+	if credentials.ClientAssertionType != ClientAssertionTypeJwtBearer {
+		return customerrors.NewValidationError("invalid_request", fmt.Sprintf("Unsupported client_assertion_type. The only supported type is %v.", ClientAssertionTypeJwtBearer))
+	}
+
+	assertionFailed := func(reason string) error {
+		return customerrors.NewValidationError("invalid_client", "Client authentication failed. The client assertion is invalid ("+reason+").")
+	}
+
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(credentials.ClientAssertion, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			return val.clientJWKSCache.getVerificationKey(settings, client, token)
+		},
+		jwt.WithValidMethods(ClientAssertionSigningAlgorithms()),
+		jwt.WithIssuer(client.ClientIdentifier),
+		jwt.WithSubject(client.ClientIdentifier),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return assertionFailed(err.Error())
+	}
+
+	// the audience identifies this server, either by its issuer or by the endpoint receiving the assertion
+	validAudiences := []string{
+		settings.Issuer,
+		lib.GetBaseUrl() + "/auth/token",
+		lib.GetBaseUrl() + "/auth/revoke",
+		lib.GetBaseUrl() + "/auth/introspect",
+	}
+	audienceIsValid := false
+	for _, aud := range claims.Audience {
+		if slices.Contains(validAudiences, aud) {
+			audienceIsValid = true
+			break
+		}
+	}
+	if !audienceIsValid {
+		return assertionFailed("the aud claim does not identify this authorization server")
+	}
+
+	if len(claims.ID) == 0 {
+		return assertionFailed("the jti claim is missing")
+	}
+
+	// the jti is remembered until the assertion expires, so that it can't be replayed
+	clientAssertionJti, err := val.database.GetClientAssertionJti(nil, client.Id, claims.ID)
+	if err != nil {
+		return err
+	}
+	if clientAssertionJti != nil {
+		return assertionFailed("the assertion has already been used")
+	}
+
+	err = val.database.CreateClientAssertionJti(nil, &entities.ClientAssertionJti{
+		ClientId:  client.Id,
+		Jti:       claims.ID,
+		ExpiresAt: sql.NullTime{Time: claims.ExpiresAt.Time.UTC(), Valid: true},
+	})
+	if err != nil {
+		// a concurrent request with the same assertion got there first
+		clientAssertionJti, getErr := val.database.GetClientAssertionJti(nil, client.Id, claims.ID)
+		if getErr == nil && clientAssertionJti != nil {
+			return assertionFailed("the assertion has already been used")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// getVerificationKey picks the client key a JWT was signed with by its kid header. A JWT
+// without a kid can only be verified when the client has a single key
+func (cache *clientJWKSCache) getVerificationKey(settings *entities.Settings, client *entities.Client,
+	token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	findKey := func(keySet *lib.JSONWebKeySet) *lib.JSONWebKey {
+		if len(kid) == 0 && len(keySet.Keys) == 1 {
+			return &keySet.Keys[0]
+		}
+		for i := range keySet.Keys {
+			if len(kid) > 0 && keySet.Keys[i].Kid == kid {
+				return &keySet.Keys[i]
+			}
+		}
+		return nil
+	}
+
+	keySet, err := cache.getClientJWKS(settings, client, false)
+	if err != nil {
+		return nil, err
+	}
+	key := findKey(keySet)
+	if key == nil && len(client.JWKSURI) > 0 {
+		// the client may have published a new key since the keys were fetched
+		keySet, err = cache.getClientJWKS(settings, client, true)
+		if err != nil {
+			return nil, err
+		}
+		key = findKey(keySet)
+	}
+	if key == nil {
+		return nil, errors.WithStack(fmt.Errorf("unknown client key %v", kid))
+	}
+
+	if len(key.Alg) > 0 && key.Alg != token.Method.Alg() {
+		return nil, errors.WithStack(fmt.Errorf("unexpected signing algorithm %v for key %v", token.Method.Alg(), key.Kid))
+	}
+	return key.PublicKey()
+}
+
+// getClientJWKS returns the keys registered for the client, either inline or at its jwks_uri.
+// The keys at a jwks_uri are cached, and reloaded when forceReload is set, but no more often
+// than clientJWKSMinReloadInterval
+func (cache *clientJWKSCache) getClientJWKS(settings *entities.Settings, client *entities.Client,
+	forceReload bool) (*lib.JSONWebKeySet, error) {
+	if len(client.JWKSURI) == 0 {
+		if len(client.JWKS) == 0 {
+			return nil, errors.WithStack(errors.New("the client has no keys registered"))
+		}
+		return lib.ParseJSONWebKeySet([]byte(client.JWKS))
+	}
+
+	cache.mutex.Lock()
+	cached, ok := cache.entries[client.JWKSURI]
+	if ok {
+		age := time.Since(cached.fetchedAt)
+		if (!forceReload && age < clientJWKSCacheDuration) || (forceReload && age < clientJWKSMinReloadInterval) {
+			cache.mutex.Unlock()
+			return cached.keySet, nil
+		}
+	}
+
+	// the keys are fetched without holding the lock, so that a slow jwks_uri doesn't hold up
+	// the other clients
+	fetch, ok := cache.inFlight[client.JWKSURI]
+	if ok {
+		cache.mutex.Unlock()
+		<-fetch.done
+		return fetch.keySet, fetch.err
+	}
+	fetch = &clientJWKSFetch{done: make(chan struct{})}
+	cache.inFlight[client.JWKSURI] = fetch
+	cache.mutex.Unlock()
+
+	fetch.keySet, fetch.err = fetchClientJWKS(settings, client)
+
+	cache.mutex.Lock()
+	delete(cache.inFlight, client.JWKSURI)
+	if fetch.err == nil {
+		cache.entries[client.JWKSURI] = cachedClientJWKS{
+			keySet:    fetch.keySet,
+			fetchedAt: time.Now().UTC(),
+		}
+	}
+	cache.mutex.Unlock()
+	close(fetch.done)
+
+	return fetch.keySet, fetch.err
+}
+
+// fetchClientJWKS fetches the keys at the client's jwks_uri. The jwks_uri of a client
+// registered by anyone (open registration) is only fetched from a public address
+func fetchClientJWKS(settings *entities.Settings, client *entities.Client) (*lib.JSONWebKeySet, error) {
+	httpClient := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+	if client.IsDynamicallyRegistered() && settings.ClientRegistrationMode == enums.ClientRegistrationModeOpen.String() {
+		httpClient = lib.NewPublicOnlyHttpClient(10 * time.Second)
+	}
+	resp, err := httpClient.Get(client.JWKSURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch the client JWKS")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.WithStack(fmt.Errorf("unable to fetch the client JWKS, the server responded with status %v", resp.StatusCode))
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read the client JWKS")
+	}
+	return lib.ParseJSONWebKeySet(body)
+}
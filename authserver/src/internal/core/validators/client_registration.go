@@ -0,0 +1,217 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/leodip/goiabada/internal/common"
+	"github.com/leodip/goiabada/internal/customerrors"
+	"github.com/leodip/goiabada/internal/dtos"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/leodip/goiabada/internal/lib"
+)
+
+// TokenEndpointAuthMethodNone is the token endpoint auth method of a public client
+const TokenEndpointAuthMethodNone = "none"
+
+type ClientRegistrationValidator struct {
+}
+
+func NewClientRegistrationValidator() *ClientRegistrationValidator {
+	return &ClientRegistrationValidator{}
+}
+
+// ValidateClientMetadata checks the metadata sent to the client registration endpoint
+// (RFC 7591, section 2), and fills in the defaults for the values that were omitted
+func (val *ClientRegistrationValidator) ValidateClientMetadata(ctx context.Context, metadata *dtos.ClientMetadata) error {
+
+	metadata.ClientName = strings.TrimSpace(metadata.ClientName)
+	const maxLengthClientName = 100
+	if len(metadata.ClientName) > maxLengthClientName {
+		return customerrors.NewValidationError("invalid_client_metadata",
+			fmt.Sprintf("The client_name cannot exceed a maximum length of %v characters.", maxLengthClientName))
+	}
+
+	if len(metadata.TokenEndpointAuthMethod) == 0 {
+		metadata.TokenEndpointAuthMethod = enums.TokenEndpointAuthMethodClientSecretBasic.String()
+	}
+	var authMethod enums.TokenEndpointAuthMethod
+	if metadata.TokenEndpointAuthMethod != TokenEndpointAuthMethodNone {
+		var err error
+		authMethod, err = enums.TokenEndpointAuthMethodFromString(metadata.TokenEndpointAuthMethod)
+		if err != nil {
+			return customerrors.NewValidationError("invalid_client_metadata",
+				fmt.Sprintf("The token_endpoint_auth_method '%v' is not supported.", metadata.TokenEndpointAuthMethod))
+		}
+	}
+
+	if len(metadata.GrantTypes) == 0 {
+		metadata.GrantTypes = []string{"authorization_code"}
+	}
+	metadata.GrantTypes = distinct(metadata.GrantTypes)
+	for _, grantType := range metadata.GrantTypes {
+		switch grantType {
+		case "authorization_code", "refresh_token", "client_credentials", DeviceCodeGrantType:
+		default:
+			return customerrors.NewValidationError("invalid_client_metadata",
+				fmt.Sprintf("The grant type '%v' is not supported.", grantType))
+		}
+	}
+	authorizationCode := slices.Contains(metadata.GrantTypes, "authorization_code")
+
+	if slices.Contains(metadata.GrantTypes, "refresh_token") && !authorizationCode && !slices.Contains(metadata.GrantTypes, DeviceCodeGrantType) {
+		return customerrors.NewValidationError("invalid_client_metadata",
+			"The refresh_token grant type can only be used together with the authorization_code or the device code grant types.")
+	}
+
+	if slices.Contains(metadata.GrantTypes, "client_credentials") && metadata.TokenEndpointAuthMethod == TokenEndpointAuthMethodNone {
+		return customerrors.NewValidationError("invalid_client_metadata",
+			"A public client (token_endpoint_auth_method 'none') can't use the client_credentials grant type.")
+	}
+
+	if len(metadata.ResponseTypes) == 0 && authorizationCode {
+		metadata.ResponseTypes = []string{"code"}
+	}
+	metadata.ResponseTypes = distinct(metadata.ResponseTypes)
+	for _, responseType := range metadata.ResponseTypes {
+		if responseType != "code" {
+			return customerrors.NewValidationError("invalid_client_metadata",
+				fmt.Sprintf("The response type '%v' is not supported.", responseType))
+		}
+	}
+	if slices.Contains(metadata.ResponseTypes, "code") != authorizationCode {
+		return customerrors.NewValidationError("invalid_client_metadata",
+			"The 'code' response type and the authorization_code grant type must be used together.")
+	}
+
+	metadata.RedirectURIs = distinct(metadata.RedirectURIs)
+	if authorizationCode && len(metadata.RedirectURIs) == 0 {
+		return customerrors.NewValidationError("invalid_redirect_uri",
+			"At least one redirect URI is required for the authorization_code grant type.")
+	}
+	for _, redirectURI := range metadata.RedirectURIs {
+		if !isValidRegistrationURI(redirectURI) {
+			return customerrors.NewValidationError("invalid_redirect_uri",
+				fmt.Sprintf("The redirect URI '%v' is invalid. Please use an absolute https URI without a fragment (http is only allowed for localhost).", redirectURI))
+		}
+	}
+
+	metadata.PostLogoutRedirectURIs = distinct(metadata.PostLogoutRedirectURIs)
+	for _, postLogoutRedirectURI := range metadata.PostLogoutRedirectURIs {
+		if !isValidRegistrationURI(postLogoutRedirectURI) {
+			return customerrors.NewValidationError("invalid_client_metadata",
+				fmt.Sprintf("The post-logout redirect URI '%v' is invalid. Please use an absolute https URI without a fragment (http is only allowed for localhost).", postLogoutRedirectURI))
+		}
+	}
+
+	for i, webOrigin := range metadata.WebOrigins {
+		origin, err := lib.GetOrigin(webOrigin)
+		if err != nil || origin != strings.ToLower(strings.TrimSuffix(webOrigin, "/")) {
+			return customerrors.NewValidationError("invalid_client_metadata",
+				fmt.Sprintf("The web origin '%v' is invalid. Please use only the scheme, host and port, like https://example.com.", webOrigin))
+		}
+		metadata.WebOrigins[i] = origin
+	}
+	metadata.WebOrigins = distinct(metadata.WebOrigins)
+
+	// the public keys are used to verify the client assertions (private_key_jwt), or
+	// to match the self-signed certificate presented by the client
+	usesJWKS := metadata.TokenEndpointAuthMethod != TokenEndpointAuthMethodNone &&
+		(authMethod == enums.TokenEndpointAuthMethodPrivateKeyJwt || authMethod == enums.TokenEndpointAuthMethodSelfSignedTLSClientAuth)
+
+	if !usesJWKS {
+		metadata.JWKS = nil
+		metadata.JWKSURI = ""
+	} else {
+		if len(metadata.JWKS) == 0 && len(metadata.JWKSURI) == 0 {
+			return customerrors.NewValidationError("invalid_client_metadata",
+				fmt.Sprintf("The token_endpoint_auth_method '%v' requires either jwks or jwks_uri.", metadata.TokenEndpointAuthMethod))
+		}
+		if len(metadata.JWKS) > 0 && len(metadata.JWKSURI) > 0 {
+			return customerrors.NewValidationError("invalid_client_metadata", "Please provide either jwks or jwks_uri, not both.")
+		}
+		if len(metadata.JWKS) > 0 {
+			_, err := lib.ParseJSONWebKeySet(metadata.JWKS)
+			if err != nil {
+				return customerrors.NewValidationError("invalid_client_metadata", "The jwks is invalid: "+err.Error()+".")
+			}
+		}
+		if len(metadata.JWKSURI) > 0 {
+			jwksURI, err := url.ParseRequestURI(metadata.JWKSURI)
+			if err != nil || jwksURI.Scheme != "https" || len(jwksURI.Hostname()) == 0 {
+				return customerrors.NewValidationError("invalid_client_metadata", "The jwks_uri must be an absolute https URL.")
+			}
+			// anyone can register a client in open mode, so the jwks_uri can't be used to
+			// make the server call internal addresses
+			settings := ctx.Value(common.ContextKeySettings).(*entities.Settings)
+			if settings.ClientRegistrationMode == enums.ClientRegistrationModeOpen.String() && !isPublicHost(jwksURI.Hostname()) {
+				return customerrors.NewValidationError("invalid_client_metadata", "The jwks_uri must point to a public address.")
+			}
+		}
+	}
+
+	metadata.TLSClientAuthSubjectDN = strings.TrimSpace(metadata.TLSClientAuthSubjectDN)
+	if metadata.TokenEndpointAuthMethod == enums.TokenEndpointAuthMethodTLSClientAuth.String() {
+		if len(metadata.TLSClientAuthSubjectDN) == 0 {
+			return customerrors.NewValidationError("invalid_client_metadata",
+				"The token_endpoint_auth_method 'tls_client_auth' requires tls_client_auth_subject_dn.")
+		}
+		if len(metadata.TLSClientAuthSubjectDN) > 512 {
+			return customerrors.NewValidationError("invalid_client_metadata",
+				"The tls_client_auth_subject_dn cannot exceed a maximum length of 512 characters.")
+		}
+	} else {
+		metadata.TLSClientAuthSubjectDN = ""
+	}
+
+	return nil
+}
+
+// isValidRegistrationURI returns true for an absolute https URI without a fragment, that fits
+// in the database. Plain http is only accepted for the loopback addresses and the hosts allowed
+// in the configuration. Any other scheme (javascript:, data:...) is rejected, since the
+// post-logout redirect URIs end up in the pages rendered by the logout endpoint
+func isValidRegistrationURI(uri string) bool {
+	const maxLengthURI = 256
+	if len(uri) > maxLengthURI {
+		return false
+	}
+	u, err := url.ParseRequestURI(uri)
+	if err != nil || len(u.Hostname()) == 0 || strings.Contains(uri, "#") {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		return lib.IsLoopbackHost(u.Hostname()) ||
+			slices.Contains(lib.GetClientRegistrationAllowedHttpHosts(), strings.ToLower(u.Hostname()))
+	}
+	return false
+}
+
+// isPublicHost returns false for localhost and for the IP addresses that are not public. Host
+// names are resolved when connecting, and checked again then
+func isPublicHost(host string) bool {
+	if lib.IsLoopbackHost(host) {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip == nil || lib.IsPublicIP(ip)
+}
+
+func distinct(values []string) []string {
+	result := []string{}
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if !slices.Contains(result, v) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/leodip/goiabada/internal/constants"
+	"github.com/leodip/goiabada/internal/customerrors"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/leodip/goiabada/internal/lib"
+)
+
+// DeviceCodeGrantType is the grant type used by a device to poll for its tokens (RFC 8628)
+const DeviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+type ValidateDeviceAuthorizationRequestInput struct {
+	ClientCredentials
+}
+
+type ValidateDeviceAuthorizationRequestResult struct {
+	Client *entities.Client
+}
+
+// ValidateDeviceAuthorizationRequest authenticates the client starting a device authorization
+// (RFC 8628). The requested scopes are validated by the AuthorizeValidator
+func (val *TokenValidator) ValidateDeviceAuthorizationRequest(ctx context.Context, input *ValidateDeviceAuthorizationRequestInput) (*ValidateDeviceAuthorizationRequestResult, error) {
+
+	client, err := val.authenticateClient(ctx, &input.ClientCredentials)
+	if err != nil {
+		return nil, err
+	}
+
+	if !client.DeviceCodeEnabled {
+		return nil, customerrors.NewValidationError("unauthorized_client", "The client associated with the provided client_id does not support the device authorization grant.")
+	}
+
+	return &ValidateDeviceAuthorizationRequestResult{
+		Client: client,
+	}, nil
+}
+
+// validateDeviceCodeGrant checks a device polling the token endpoint. While the user hasn't
+// approved the request the device gets authorization_pending, or slow_down when it polls
+// faster than the interval it was given, which is then increased by 5 seconds
+func (val *TokenValidator) validateDeviceCodeGrant(ctx context.Context, client *entities.Client,
+	input *ValidateTokenRequestInput) (*ValidateTokenRequestResult, error) {
+
+	if !client.DeviceCodeEnabled {
+		return nil, customerrors.NewValidationError("unauthorized_client", "The client associated with the provided client_id does not support the device authorization grant.")
+	}
+
+	if !client.IsPublic {
+		err := val.authenticateConfidentialClient(ctx, client, &input.ClientCredentials,
+			customerrors.NewValidationError("invalid_grant", "Client authentication failed. Please review your client_secret."))
+		if err != nil {
+			return nil, err
+		}
+	} else if len(input.ClientSecret) > 0 {
+		return nil, customerrors.NewValidationError("invalid_request", "This client is configured as public, which means a client_secret is not required. To proceed, please remove the client_secret from your request.")
+	}
+
+	if len(input.DeviceCode) == 0 {
+		return nil, customerrors.NewValidationError("invalid_request", "Missing required device_code parameter.")
+	}
+
+	deviceCodeHash, err := lib.HashString(input.DeviceCode)
+	if err != nil {
+		return nil, err
+	}
+	deviceCode, err := val.database.GetDeviceCodeByDeviceCodeHash(nil, deviceCodeHash)
+	if err != nil {
+		return nil, err
+	}
+	if deviceCode == nil || deviceCode.ClientId != client.Id {
+		return nil, customerrors.NewValidationError("invalid_grant", "The device code is invalid.")
+	}
+
+	now := time.Now().UTC()
+	if now.After(deviceCode.ExpiresAt.Time) {
+		return nil, customerrors.NewValidationError("expired_token", "The device code has expired. Please start a new device authorization.")
+	}
+
+	if deviceCode.Status == enums.DeviceCodeStatusPending.String() {
+		interval := deviceCode.IntervalInSeconds
+		pollingTooFast := deviceCode.LastPolledAt.Valid &&
+			now.Before(deviceCode.LastPolledAt.Time.Add(time.Duration(interval)*time.Second))
+		if pollingTooFast {
+			interval += 5
+		}
+		updated, err := val.database.UpdateDeviceCodePolling(nil, deviceCode.Id, now, interval)
+		if err != nil {
+			return nil, err
+		}
+
+		if updated {
+			if pollingTooFast {
+				return nil, customerrors.NewValidationError("slow_down",
+					fmt.Sprintf("The device is polling too frequently. Please wait at least %v seconds between requests.", interval))
+			}
+			return nil, customerrors.NewValidationError("authorization_pending", "The user has not completed the authorization yet.")
+		}
+
+		// the user approved or denied the request in the meantime
+		deviceCode, err = val.database.GetDeviceCodeById(nil, deviceCode.Id)
+		if err != nil {
+			return nil, err
+		}
+		if deviceCode == nil {
+			return nil, customerrors.NewValidationError("invalid_grant", "The device code is invalid.")
+		}
+	}
+
+	if deviceCode.Status == enums.DeviceCodeStatusDenied.String() {
+		return nil, customerrors.NewValidationError("access_denied", "The user denied the authorization request.")
+	}
+
+	codeEntity, err := val.database.GetCodeById(nil, deviceCode.CodeId.Int64)
+	if err != nil {
+		return nil, err
+	}
+	if codeEntity == nil || codeEntity.Used {
+		return nil, customerrors.NewValidationError("invalid_grant", "The device code has already been used.")
+	}
+
+	err = val.database.CodeLoadClient(nil, codeEntity)
+	if err != nil {
+		return nil, err
+	}
+
+	err = val.database.CodeLoadUser(nil, codeEntity)
+	if err != nil {
+		return nil, err
+	}
+
+	if !codeEntity.User.Enabled {
+		lib.LogAudit(ctx, constants.AuditUserDisabled, map[string]interface{}{
+			"userId": codeEntity.User.Id,
+		})
+		return nil, customerrors.NewValidationError("invalid_grant", "The user account is disabled.")
+	}
+
+	return &ValidateTokenRequestResult{
+		CodeEntity: codeEntity,
+		Client:     client,
+	}, nil
+}
@@ -0,0 +1,227 @@
+package core
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	b64 "encoding/base64"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+
+	"github.com/leodip/goiabada/internal/common"
+	"github.com/leodip/goiabada/internal/customerrors"
+	"github.com/leodip/goiabada/internal/data"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/lib"
+)
+
+// dpopProofMaxAge is how far the iat of a DPoP proof can be from the current time. The jti of
+// a proof is remembered for as long as the proof is acceptable
+const dpopProofMaxAge = 5 * time.Minute
+
+// dpopNonceLifetime is how long a nonce issued by the server is accepted in DPoP proofs
+const dpopNonceLifetime = 5 * time.Minute
+
+type DPoPValidator struct {
+	database data.Database
+}
+
+func NewDPoPValidator(database data.Database) *DPoPValidator {
+	return &DPoPValidator{
+		database: database,
+	}
+}
+
+type ValidateDPoPProofInput struct {
+	Proof            string
+	HttpMethod       string
+	HttpURL          string
+	AccessToken      string
+	ClientIdentifier string
+}
+
+type ValidateDPoPProofResult struct {
+	// Jkt is the thumbprint of the public key in the proof (RFC 7638)
+	Jkt string
+}
+
+type dpopProofClaims struct {
+	jwt.RegisteredClaims
+	Htm   string `json:"htm"`
+	Htu   string `json:"htu"`
+	Ath   string `json:"ath,omitempty"`
+	Nonce string `json:"nonce,omitempty"`
+}
+
+// ValidateDPoPProof verifies a DPoP proof (RFC 9449, section 4.3). The proof must be signed with
+// the key in its jwk header, be bound to the HTTP method and URL of the request and, when an
+// access token is presented, to that token. Each proof can be used only once
+func (val *DPoPValidator) ValidateDPoPProof(ctx context.Context, input *ValidateDPoPProofInput) (*ValidateDPoPProofResult, error) {
+
+	settings := ctx.Value(common.ContextKeySettings).(*entities.Settings)
+
+	proofFailed := func(reason string) error {
+		return customerrors.NewValidationError("invalid_dpop_proof", "The DPoP proof is invalid ("+reason+").")
+	}
+
+	var jwk lib.JSONWebKey
+	claims := &dpopProofClaims{}
+	_, err := jwt.ParseWithClaims(input.Proof, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			if typ, _ := token.Header["typ"].(string); typ != "dpop+jwt" {
+				return nil, errors.WithStack(errors.New("the typ header must be dpop+jwt"))
+			}
+			jwkHeader, ok := token.Header["jwk"].(map[string]interface{})
+			if !ok {
+				return nil, errors.WithStack(errors.New("the jwk header is missing"))
+			}
+			if _, ok := jwkHeader["d"]; ok {
+				return nil, errors.WithStack(errors.New("the jwk header must not contain a private key"))
+			}
+			jwkJSON, err := json.Marshal(jwkHeader)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			err = json.Unmarshal(jwkJSON, &jwk)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return jwk.PublicKey()
+		},
+		jwt.WithValidMethods(ClientAssertionSigningAlgorithms()),
+	)
+	if err != nil {
+		return nil, proofFailed(err.Error())
+	}
+
+	if len(claims.ID) == 0 {
+		return nil, proofFailed("the jti claim is missing")
+	}
+
+	if claims.Htm != input.HttpMethod {
+		return nil, proofFailed("the htm claim does not match the HTTP method of the request")
+	}
+
+	if !matchesDPoPHtu(claims.Htu, input.HttpURL) {
+		return nil, proofFailed("the htu claim does not match the URL of the request")
+	}
+
+	now := time.Now().UTC()
+	if claims.IssuedAt == nil {
+		return nil, proofFailed("the iat claim is missing")
+	}
+	issuedAt := claims.IssuedAt.Time.UTC()
+	if issuedAt.Before(now.Add(-dpopProofMaxAge)) || issuedAt.After(now.Add(dpopProofMaxAge)) {
+		return nil, proofFailed("the iat claim is outside of the acceptable window")
+	}
+
+	if len(input.AccessToken) > 0 {
+		hash := sha256.Sum256([]byte(input.AccessToken))
+		if claims.Ath != b64.RawURLEncoding.EncodeToString(hash[:]) {
+			return nil, proofFailed("the ath claim does not match the access token")
+		}
+	}
+
+	if len(input.ClientIdentifier) > 0 {
+		client, err := val.database.GetClientByClientIdentifier(nil, input.ClientIdentifier)
+		if err != nil {
+			return nil, err
+		}
+		if client != nil && client.DPoPNonceRequired && !val.isNonceValid(settings, claims.Nonce, now) {
+			return nil, customerrors.NewValidationError("use_dpop_nonce", "The authorization server requires a nonce in the DPoP proof.")
+		}
+	}
+
+	jkt, err := jwk.Thumbprint()
+	if err != nil {
+		return nil, proofFailed(err.Error())
+	}
+
+	// the jti is remembered while the proof is acceptable, so that it can't be replayed
+	dpopProofJti, err := val.database.GetDPoPProofJti(nil, jkt, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if dpopProofJti != nil {
+		return nil, proofFailed("the proof has already been used")
+	}
+
+	err = val.database.CreateDPoPProofJti(nil, &entities.DPoPProofJti{
+		Jkt:       jkt,
+		Jti:       claims.ID,
+		ExpiresAt: sql.NullTime{Time: issuedAt.Add(dpopProofMaxAge), Valid: true},
+	})
+	if err != nil {
+		// a concurrent request with the same proof got there first
+		dpopProofJti, getErr := val.database.GetDPoPProofJti(nil, jkt, claims.ID)
+		if getErr == nil && dpopProofJti != nil {
+			return nil, proofFailed("the proof has already been used")
+		}
+		return nil, err
+	}
+
+	return &ValidateDPoPProofResult{
+		Jkt: jkt,
+	}, nil
+}
+
+// GenerateNonce returns a nonce for the clients to include in their DPoP proofs. The nonce is
+// the time it was issued, authenticated with an HMAC, so there's no need to store it
+func (val *DPoPValidator) GenerateNonce(settings *entities.Settings) string {
+	issuedAt := make([]byte, 8)
+	binary.BigEndian.PutUint64(issuedAt, uint64(time.Now().UTC().Unix()))
+	return b64.RawURLEncoding.EncodeToString(append(issuedAt, val.nonceMAC(settings, issuedAt)...))
+}
+
+func (val *DPoPValidator) isNonceValid(settings *entities.Settings, nonce string, now time.Time) bool {
+	nonceBytes, err := b64.RawURLEncoding.DecodeString(nonce)
+	if err != nil || len(nonceBytes) <= 8 {
+		return false
+	}
+	issuedAt, mac := nonceBytes[:8], nonceBytes[8:]
+	if !hmac.Equal(mac, val.nonceMAC(settings, issuedAt)) {
+		return false
+	}
+	issuedAtTime := time.Unix(int64(binary.BigEndian.Uint64(issuedAt)), 0)
+	return !issuedAtTime.After(now) && now.Sub(issuedAtTime) <= dpopNonceLifetime
+}
+
+func (val *DPoPValidator) nonceMAC(settings *entities.Settings, issuedAt []byte) []byte {
+	mac := hmac.New(sha256.New, settings.AESEncryptionKey)
+	mac.Write([]byte("dpop-nonce"))
+	mac.Write(issuedAt)
+	return mac.Sum(nil)
+}
+
+// matchesDPoPHtu compares the htu claim with the URL of the request, ignoring the query and
+// fragment parts (RFC 9449, section 4.3)
+func matchesDPoPHtu(htu string, requestURL string) bool {
+	normalize := func(rawURL string) (string, error) {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return "", err
+		}
+		if len(u.Scheme) == 0 || len(u.Host) == 0 {
+			return "", fmt.Errorf("not an absolute URL: %v", rawURL)
+		}
+		return fmt.Sprintf("%v://%v%v", u.Scheme, strings.ToLower(u.Host), u.EscapedPath()), nil
+	}
+	htuNormalized, err := normalize(htu)
+	if err != nil {
+		return false
+	}
+	requestURLNormalized, err := normalize(requestURL)
+	if err != nil {
+		return false
+	}
+	return htuNormalized == requestURLNormalized
+}
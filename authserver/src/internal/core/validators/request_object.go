@@ -0,0 +1,189 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/leodip/goiabada/internal/common"
+	"github.com/leodip/goiabada/internal/customerrors"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/lib"
+)
+
+// requestObjectMaxLifetime is how far in the future the exp claim of a signed request object
+// can be. The jti of the request object is remembered until then, to reject replays
+const requestObjectMaxLifetime = 60 * time.Minute
+
+// RequestObjectSigningAlgorithms returns the algorithms a request object can be signed with.
+// Unsigned request objects (none) are only accepted from clients that don't require signed ones
+func RequestObjectSigningAlgorithms() []string {
+	return append(ClientAssertionSigningAlgorithms(), jwt.SigningMethodNone.Alg())
+}
+
+type ValidateRequestObjectInput struct {
+	ClientId      string
+	ResponseType  string
+	RequestObject string
+	// IsPushedAuthorizationRequest is true when the request object comes from a request_uri. It
+	// was already used at the PAR endpoint, where its jti was remembered
+	IsPushedAuthorizationRequest bool
+}
+
+type ValidateRequestObjectResult struct {
+	// Parameters are the authorization request parameters carried by the request object
+	Parameters url.Values
+	IsSigned   bool
+}
+
+// ValidateRequestObject verifies a request object (RFC 9101). A signed request object must be
+// signed with one of the keys registered for the client, be issued by the client, be addressed
+// to this server, expire within the max lifetime and be used only once
+func (val *AuthorizeValidator) ValidateRequestObject(ctx context.Context, input *ValidateRequestObjectInput) (*ValidateRequestObjectResult, error) {
+
+	settings := ctx.Value(common.ContextKeySettings).(*entities.Settings)
+
+	requestObjectFailed := func(reason string) error {
+		return customerrors.NewValidationError("invalid_request_object", "The request object is invalid ("+reason+").")
+	}
+
+	if len(input.ClientId) == 0 {
+		return nil, customerrors.NewValidationError("invalid_request", "The client_id parameter is missing.")
+	}
+
+	client, err := val.database.GetClientByClientIdentifier(nil, input.ClientId)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, customerrors.NewValidationError("invalid_request", "We couldn't find a client associated with the provided client_id.")
+	}
+
+	validMethods := ClientAssertionSigningAlgorithms()
+	if !client.RequireSignedRequestObject {
+		validMethods = RequestObjectSigningAlgorithms()
+	}
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(input.RequestObject, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			if token.Method == jwt.SigningMethodNone {
+				return jwt.UnsafeAllowNoneSignatureType, nil
+			}
+			return val.clientJWKSCache.getVerificationKey(settings, client, token)
+		},
+		jwt.WithValidMethods(validMethods),
+	)
+	if err != nil {
+		return nil, requestObjectFailed(err.Error())
+	}
+	isSigned := token.Method != jwt.SigningMethodNone
+
+	var jti string
+	var expiresAt *jwt.NumericDate
+	if isSigned {
+		issuer, _ := claims.GetIssuer()
+		if issuer != client.ClientIdentifier {
+			return nil, requestObjectFailed("the iss claim must be the client identifier")
+		}
+
+		// the audience identifies this server, either by its issuer or by the endpoint receiving the request
+		validAudiences := []string{
+			settings.Issuer,
+			lib.GetBaseUrl() + "/auth/authorize",
+			lib.GetBaseUrl() + "/auth/par",
+		}
+		audience, _ := claims.GetAudience()
+		audienceIsValid := false
+		for _, aud := range audience {
+			if slices.Contains(validAudiences, aud) {
+				audienceIsValid = true
+				break
+			}
+		}
+		if !audienceIsValid {
+			return nil, requestObjectFailed("the aud claim does not identify this authorization server")
+		}
+
+		expiresAt, _ = claims.GetExpirationTime()
+		if expiresAt == nil {
+			return nil, requestObjectFailed("the exp claim is missing")
+		}
+		if time.Until(expiresAt.Time) > requestObjectMaxLifetime {
+			return nil, requestObjectFailed(fmt.Sprintf("the exp claim can't be more than %v minutes in the future",
+				requestObjectMaxLifetime.Minutes()))
+		}
+
+		jti, _ = claims["jti"].(string)
+		if len(jti) == 0 {
+			return nil, requestObjectFailed("the jti claim is missing")
+		}
+	}
+
+	if _, ok := claims["request"]; ok {
+		return nil, requestObjectFailed("the request claim is not allowed")
+	}
+	if _, ok := claims["request_uri"]; ok {
+		return nil, requestObjectFailed("the request_uri claim is not allowed")
+	}
+
+	parameters := url.Values{}
+	for name, value := range claims {
+		switch name {
+		case "iss", "aud", "exp", "iat", "nbf", "jti":
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			parameters.Set(name, v)
+		case float64:
+			parameters.Set(name, strconv.FormatFloat(v, 'f', -1, 64))
+		}
+	}
+
+	// client_id and response_type are also sent outside of the request object, and must match
+	if _, ok := claims["client_id"]; ok && parameters.Get("client_id") != client.ClientIdentifier {
+		return nil, requestObjectFailed("the client_id claim does not match the client_id parameter")
+	}
+	if responseType := parameters.Get("response_type"); len(responseType) > 0 && len(input.ResponseType) > 0 &&
+		responseType != input.ResponseType {
+		return nil, requestObjectFailed("the response_type claim does not match the response_type parameter")
+	}
+
+	// the jti is remembered until the request object expires, so that it can't be replayed. A
+	// request object that comes from a request_uri was already remembered by the PAR endpoint
+	if isSigned && !input.IsPushedAuthorizationRequest {
+		requestObjectJti, err := val.database.GetRequestObjectJti(nil, client.Id, jti)
+		if err != nil {
+			return nil, err
+		}
+		if requestObjectJti != nil {
+			return nil, requestObjectFailed("the request object has already been used")
+		}
+
+		err = val.database.CreateRequestObjectJti(nil, &entities.RequestObjectJti{
+			ClientId:  client.Id,
+			Jti:       jti,
+			ExpiresAt: sql.NullTime{Time: expiresAt.Time.UTC(), Valid: true},
+		})
+		if err != nil {
+			// a concurrent request with the same request object got there first
+			requestObjectJti, getErr := val.database.GetRequestObjectJti(nil, client.Id, jti)
+			if getErr == nil && requestObjectJti != nil {
+				return nil, requestObjectFailed("the request object has already been used")
+			}
+			return nil, err
+		}
+	}
+
+	return &ValidateRequestObjectResult{
+		Parameters: parameters,
+		IsSigned:   isSigned,
+	}, nil
+}
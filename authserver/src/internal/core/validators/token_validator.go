@@ -17,6 +17,7 @@ import (
 	"github.com/leodip/goiabada/internal/data"
 	"github.com/leodip/goiabada/internal/dtos"
 	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
 	"github.com/leodip/goiabada/internal/lib"
 )
 
@@ -24,6 +25,7 @@ type TokenValidator struct {
 	database          data.Database
 	tokenParser       *core_token.TokenParser
 	permissionChecker *core.PermissionChecker
+	clientJWKSCache   *clientJWKSCache
 }
 
 func NewTokenValidator(database data.Database, tokenParser *core_token.TokenParser,
@@ -32,18 +34,19 @@ func NewTokenValidator(database data.Database, tokenParser *core_token.TokenPars
 		database:          database,
 		tokenParser:       tokenParser,
 		permissionChecker: permissionChecker,
+		clientJWKSCache:   newClientJWKSCache(),
 	}
 }
 
 type ValidateTokenRequestInput struct {
+	ClientCredentials
 	GrantType    string
 	Code         string
 	RedirectURI  string
 	CodeVerifier string
-	ClientId     string
-	ClientSecret string
 	Scope        string
 	RefreshToken string
+	DeviceCode   string
 }
 
 type ValidateTokenRequestResult struct {
@@ -58,6 +61,7 @@ func (val *TokenValidator) ValidateTokenRequest(ctx context.Context, input *Vali
 
 	settings := ctx.Value(common.ContextKeySettings).(*entities.Settings)
 
+	input.resolveClientId()
 	if len(input.ClientId) == 0 {
 		return nil, customerrors.NewValidationError("invalid_request", "Missing required client_id parameter.")
 	}
@@ -73,8 +77,6 @@ func (val *TokenValidator) ValidateTokenRequest(ctx context.Context, input *Vali
 		return nil, customerrors.NewValidationError("invalid_grant", "Client is disabled.")
 	}
 
-	clientSecretRequiredErrorMsg := "This client is configured as confidential (not public), which means a client_secret is required for authentication. Please provide a valid client_secret to proceed."
-
 	switch input.GrantType {
 	case "authorization_code":
 		if !client.AuthorizationCodeEnabled {
@@ -124,7 +126,7 @@ func (val *TokenValidator) ValidateTokenRequest(ctx context.Context, input *Vali
 		}
 
 		if !codeEntity.User.Enabled {
-			lib.LogAudit(constants.AuditUserDisabled, map[string]interface{}{
+			lib.LogAudit(ctx, constants.AuditUserDisabled, map[string]interface{}{
 				"userId": codeEntity.User.Id,
 			})
 			return nil, customerrors.NewValidationError("invalid_grant", "The user account is disabled.")
@@ -142,17 +144,11 @@ func (val *TokenValidator) ValidateTokenRequest(ctx context.Context, input *Vali
 		}
 
 		if !client.IsPublic {
-			if len(input.ClientSecret) == 0 {
-				return nil, customerrors.NewValidationError("invalid_request", clientSecretRequiredErrorMsg)
-			}
-
-			clientSecretDecrypted, err := lib.DecryptText(client.ClientSecretEncrypted, settings.AESEncryptionKey)
+			err = val.authenticateConfidentialClient(ctx, client, &input.ClientCredentials,
+				customerrors.NewValidationError("invalid_grant", "Client authentication failed. Please review your client_secret."))
 			if err != nil {
 				return nil, err
 			}
-			if clientSecretDecrypted != input.ClientSecret {
-				return nil, customerrors.NewValidationError("invalid_grant", "Client authentication failed. Please review your client_secret.")
-			}
 		} else if len(input.ClientSecret) > 0 {
 			return nil, customerrors.NewValidationError("invalid_request", "This client is configured as public, which means a client_secret is not required. To proceed, please remove the client_secret from your request.")
 		}
@@ -164,6 +160,7 @@ func (val *TokenValidator) ValidateTokenRequest(ctx context.Context, input *Vali
 
 		return &ValidateTokenRequestResult{
 			CodeEntity: codeEntity,
+			Client:     client,
 		}, nil
 	case "client_credentials":
 		if !client.ClientCredentialsEnabled {
@@ -174,17 +171,11 @@ func (val *TokenValidator) ValidateTokenRequest(ctx context.Context, input *Vali
 			return nil, customerrors.NewValidationError("unauthorized_client", "A public client is not eligible for the client credentials flow. Please review the client configuration.")
 		}
 
-		if len(input.ClientSecret) == 0 {
-			return nil, customerrors.NewValidationError("invalid_request", clientSecretRequiredErrorMsg)
-		}
-
-		clientSecretDescrypted, err := lib.DecryptText(client.ClientSecretEncrypted, settings.AESEncryptionKey)
+		err = val.authenticateConfidentialClient(ctx, client, &input.ClientCredentials,
+			customerrors.NewValidationError("invalid_client", "Client authentication failed."))
 		if err != nil {
 			return nil, err
 		}
-		if clientSecretDescrypted != input.ClientSecret {
-			return nil, customerrors.NewValidationError("invalid_client", "Client authentication failed.")
-		}
 
 		err = val.database.ClientLoadPermissions(nil, client)
 		if err != nil {
@@ -217,23 +208,19 @@ func (val *TokenValidator) ValidateTokenRequest(ctx context.Context, input *Vali
 			Client: client,
 			Scope:  input.Scope,
 		}, nil
+	case DeviceCodeGrantType:
+		return val.validateDeviceCodeGrant(ctx, client, input)
 	case "refresh_token":
-		if !client.AuthorizationCodeEnabled {
+		if !client.AuthorizationCodeEnabled && !client.DeviceCodeEnabled {
 			return nil, customerrors.NewValidationError("unauthorized_client", "The client associated with the provided client_id does not support authorization code flow.")
 		}
 
 		if !client.IsPublic {
-			if len(input.ClientSecret) == 0 {
-				return nil, customerrors.NewValidationError("invalid_request", clientSecretRequiredErrorMsg)
-			}
-
-			clientSecretDecrypted, err := lib.DecryptText(client.ClientSecretEncrypted, settings.AESEncryptionKey)
+			err = val.authenticateConfidentialClient(ctx, client, &input.ClientCredentials,
+				customerrors.NewValidationError("invalid_grant", "Client authentication failed. Please review your client_secret."))
 			if err != nil {
 				return nil, err
 			}
-			if clientSecretDecrypted != input.ClientSecret {
-				return nil, customerrors.NewValidationError("invalid_grant", "Client authentication failed. Please review your client_secret.")
-			}
 		}
 
 		if len(input.RefreshToken) == 0 {
@@ -272,6 +259,20 @@ func (val *TokenValidator) ValidateTokenRequest(ctx context.Context, input *Vali
 			return nil, customerrors.NewValidationError("invalid_request", "The refresh token is invalid because it does not belong to the client.")
 		}
 
+		if refreshToken.Used {
+			// the refresh token was already rotated, so it's being replayed.
+			// assume it was leaked and revoke every refresh token of the family
+			err = val.revokeRefreshTokenFamily(ctx, refreshToken)
+			if err != nil {
+				return nil, err
+			}
+			return nil, customerrors.NewValidationError("invalid_grant", "This refresh token has already been used. As a security measure, all refresh tokens issued from the same authorization have been revoked.")
+		}
+
+		if refreshToken.Revoked {
+			return nil, customerrors.NewValidationError("invalid_grant", "This refresh token has been revoked.")
+		}
+
 		if !refreshToken.Code.User.Enabled {
 			return nil, customerrors.NewValidationError("invalid_grant", "The user account is disabled.")
 		}
@@ -400,6 +401,212 @@ func (val *TokenValidator) ValidateTokenRequest(ctx context.Context, input *Vali
 	}
 }
 
+type ValidateTokenRevocationRequestInput struct {
+	ClientCredentials
+	Token         string
+	TokenTypeHint string
+}
+
+type ValidateTokenRevocationRequestResult struct {
+	Client       *entities.Client
+	RefreshToken *entities.RefreshToken
+}
+
+func (val *TokenValidator) ValidateTokenRevocationRequest(ctx context.Context, input *ValidateTokenRevocationRequestInput) (*ValidateTokenRevocationRequestResult, error) {
+
+	client, err := val.authenticateClient(ctx, &input.ClientCredentials)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(input.Token) == 0 {
+		return nil, customerrors.NewValidationError("invalid_request", "Missing required token parameter.")
+	}
+
+	result := &ValidateTokenRevocationRequestResult{
+		Client: client,
+	}
+
+	// RFC 7009: invalid tokens do not cause an error response, the client can't handle it anyway
+	tokenInfo, err := val.tokenParser.ParseToken(ctx, input.Token, true)
+	if err != nil || tokenInfo.Claims == nil {
+		return result, nil
+	}
+
+	tokenType := tokenInfo.GetStringClaim("typ")
+	switch tokenType {
+	case "Refresh", "Offline":
+		jti := tokenInfo.GetStringClaim("jti")
+		if len(jti) == 0 {
+			return result, nil
+		}
+
+		refreshToken, err := val.database.GetRefreshTokenByJti(nil, jti)
+		if err != nil {
+			return nil, err
+		}
+		if refreshToken == nil {
+			return result, nil
+		}
+
+		err = val.database.RefreshTokenLoadCode(nil, refreshToken)
+		if err != nil {
+			return nil, err
+		}
+
+		if refreshToken.Code.ClientId != client.Id {
+			return nil, customerrors.NewValidationError("unauthorized_client", "The token was not issued to the client making the revocation request.")
+		}
+
+		result.RefreshToken = refreshToken
+		return result, nil
+	case enums.TokenTypeBearer.String(), enums.TokenTypeId.String():
+		return nil, customerrors.NewValidationError("unsupported_token_type", "Revocation of access tokens and id tokens is not supported. These tokens are self-contained and remain valid until they expire.")
+	default:
+		return result, nil
+	}
+}
+
+type ValidateTokenIntrospectionRequestInput struct {
+	ClientCredentials
+	Token         string
+	TokenTypeHint string
+}
+
+type ValidateTokenIntrospectionRequestResult struct {
+	Client *entities.Client
+}
+
+func (val *TokenValidator) ValidateTokenIntrospectionRequest(ctx context.Context, input *ValidateTokenIntrospectionRequestInput) (*ValidateTokenIntrospectionRequestResult, error) {
+
+	client, err := val.authenticateClient(ctx, &input.ClientCredentials)
+	if err != nil {
+		return nil, err
+	}
+
+	// introspection would allow anyone to probe tokens if public clients were accepted
+	if client.IsPublic {
+		return nil, customerrors.NewValidationError("unauthorized_client", "Public clients are not allowed to use the introspection endpoint.")
+	}
+
+	if len(input.Token) == 0 {
+		return nil, customerrors.NewValidationError("invalid_request", "Missing required token parameter.")
+	}
+
+	return &ValidateTokenIntrospectionRequestResult{
+		Client: client,
+	}, nil
+}
+
+type ValidatePushedAuthorizationRequestInput struct {
+	ClientCredentials
+	RequestURI string
+}
+
+type ValidatePushedAuthorizationRequestResult struct {
+	Client *entities.Client
+}
+
+// ValidatePushedAuthorizationRequest authenticates the client pushing an authorization
+// request (RFC 9126). The authorization parameters are validated by the AuthorizeValidator
+func (val *TokenValidator) ValidatePushedAuthorizationRequest(ctx context.Context, input *ValidatePushedAuthorizationRequestInput) (*ValidatePushedAuthorizationRequestResult, error) {
+
+	client, err := val.authenticateClient(ctx, &input.ClientCredentials)
+	if err != nil {
+		return nil, err
+	}
+
+	if !client.AuthorizationCodeEnabled {
+		return nil, customerrors.NewValidationError("unauthorized_client", "The client associated with the provided client_id does not support authorization code flow.")
+	}
+
+	if len(input.RequestURI) > 0 {
+		return nil, customerrors.NewValidationError("invalid_request", "The request_uri parameter is not allowed in a pushed authorization request.")
+	}
+
+	return &ValidatePushedAuthorizationRequestResult{
+		Client: client,
+	}, nil
+}
+
+func (val *TokenValidator) revokeRefreshTokenFamily(ctx context.Context, refreshToken *entities.RefreshToken) error {
+
+	refreshTokens, err := val.database.GetRefreshTokensByFirstRefreshTokenJti(nil, refreshToken.FirstRefreshTokenJti)
+	if err != nil {
+		return err
+	}
+
+	tx, err := val.database.BeginTransaction()
+	if err != nil {
+		return err
+	}
+	defer val.database.RollbackTransaction(tx)
+
+	revokedCount := 0
+	for i := range refreshTokens {
+		if refreshTokens[i].Revoked {
+			continue
+		}
+		refreshTokens[i].Revoked = true
+		err = val.database.UpdateRefreshToken(tx, &refreshTokens[i])
+		if err != nil {
+			return err
+		}
+		revokedCount++
+	}
+
+	err = val.database.CommitTransaction(tx)
+	if err != nil {
+		return err
+	}
+
+	lib.LogAudit(ctx, constants.AuditRefreshTokenReuseDetected, map[string]interface{}{
+		"clientId":             refreshToken.Code.ClientId,
+		"userId":               refreshToken.Code.UserId,
+		"refreshTokenJti":      refreshToken.RefreshTokenJti,
+		"firstRefreshTokenJti": refreshToken.FirstRefreshTokenJti,
+		"revokedCount":         revokedCount,
+	})
+
+	return nil
+}
+
+// authenticateClient checks the client credentials sent to the endpoints that
+// are not bound to a specific grant (revocation, introspection)
+func (val *TokenValidator) authenticateClient(ctx context.Context, credentials *ClientCredentials) (*entities.Client, error) {
+
+	credentials.resolveClientId()
+	if len(credentials.ClientId) == 0 {
+		return nil, customerrors.NewValidationError("invalid_request", "Missing required client_id parameter.")
+	}
+
+	client, err := val.database.GetClientByClientIdentifier(nil, credentials.ClientId)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, customerrors.NewValidationError("invalid_client", "Client does not exist.")
+	}
+	if !client.Enabled {
+		return nil, customerrors.NewValidationError("invalid_client", "Client is disabled.")
+	}
+
+	if client.IsPublic {
+		if len(credentials.ClientSecret) > 0 {
+			return nil, customerrors.NewValidationError("invalid_request", "This client is configured as public, which means a client_secret is not required. To proceed, please remove the client_secret from your request.")
+		}
+		return client, nil
+	}
+
+	err = val.authenticateConfidentialClient(ctx, client, credentials,
+		customerrors.NewValidationError("invalid_client", "Client authentication failed."))
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
 func (val *TokenValidator) validateClientCredentialsScopes(ctx context.Context, scope string, client *entities.Client) error {
 
 	if len(scope) == 0 {
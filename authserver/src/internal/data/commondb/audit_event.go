@@ -0,0 +1,196 @@
+package commondb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/pkg/errors"
+)
+
+func (d *CommonDatabase) CreateAuditEvent(tx *sql.Tx, auditEvent *entities.AuditEvent) error {
+
+	originalCreatedAt := auditEvent.CreatedAt
+	auditEvent.CreatedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+
+	auditEventStruct := sqlbuilder.NewStruct(new(entities.AuditEvent)).
+		For(d.Flavor)
+
+	insertBuilder := auditEventStruct.WithoutTag("pk").InsertInto("audit_events", auditEvent)
+
+	sql, args := insertBuilder.Build()
+	result, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		auditEvent.CreatedAt = originalCreatedAt
+		return errors.Wrap(err, "unable to insert auditEvent")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		auditEvent.CreatedAt = originalCreatedAt
+		return errors.Wrap(err, "unable to get last insert id")
+	}
+
+	auditEvent.Id = id
+	return nil
+}
+
+func (d *CommonDatabase) applyAuditEventFilters(selectBuilder *sqlbuilder.SelectBuilder, event string,
+	userId int64, clientId int64, from time.Time, to time.Time) {
+
+	if event != "" {
+		selectBuilder.Where(selectBuilder.Equal("event", event))
+	}
+	if userId > 0 {
+		selectBuilder.Where(selectBuilder.Equal("user_id", userId))
+	}
+	if clientId > 0 {
+		selectBuilder.Where(selectBuilder.Equal("client_id", clientId))
+	}
+	if !from.IsZero() {
+		selectBuilder.Where(selectBuilder.GreaterEqualThan("created_at", from))
+	}
+	if !to.IsZero() {
+		selectBuilder.Where(selectBuilder.LessThan("created_at", to))
+	}
+}
+
+func (d *CommonDatabase) SearchAuditEventsPaginated(tx *sql.Tx, event string, userId int64, clientId int64,
+	from time.Time, to time.Time, page int, pageSize int) ([]entities.AuditEvent, int, error) {
+
+	if page < 1 {
+		page = 1
+	}
+
+	if pageSize < 1 {
+		pageSize = 10
+	}
+
+	auditEventStruct := sqlbuilder.NewStruct(new(entities.AuditEvent)).
+		For(d.Flavor)
+
+	selectBuilder := auditEventStruct.SelectFrom("audit_events")
+	d.applyAuditEventFilters(selectBuilder, event, userId, clientId, from, to)
+	selectBuilder.OrderBy("audit_events.id").Desc()
+	selectBuilder.Offset((page - 1) * pageSize)
+	selectBuilder.Limit(pageSize)
+
+	sql, args := selectBuilder.Build()
+	rows, err := d.QuerySql(tx, sql, args...)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "unable to query database")
+	}
+	defer rows.Close()
+
+	var auditEvents []entities.AuditEvent
+	for rows.Next() {
+		var auditEvent entities.AuditEvent
+		addr := auditEventStruct.Addr(&auditEvent)
+		err = rows.Scan(addr...)
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "unable to scan auditEvent")
+		}
+		auditEvents = append(auditEvents, auditEvent)
+	}
+
+	selectBuilder = d.Flavor.NewSelectBuilder()
+	selectBuilder.Select("count(*)").From("audit_events")
+	d.applyAuditEventFilters(selectBuilder, event, userId, clientId, from, to)
+
+	sql, args = selectBuilder.Build()
+	rows2, err := d.QuerySql(tx, sql, args...)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "unable to query database")
+	}
+	defer rows2.Close()
+
+	var total int
+	if rows2.Next() {
+		rows2.Scan(&total)
+	}
+
+	return auditEvents, total, nil
+}
+
+// GetAuditEventsAfterId returns the matching audit events with an id greater than afterId, in
+// ascending order. It's used to walk through all the events (keyset pagination)
+func (d *CommonDatabase) GetAuditEventsAfterId(tx *sql.Tx, event string, userId int64, clientId int64,
+	from time.Time, to time.Time, afterId int64, limit int) ([]entities.AuditEvent, error) {
+
+	if limit < 1 {
+		limit = 10
+	}
+
+	auditEventStruct := sqlbuilder.NewStruct(new(entities.AuditEvent)).
+		For(d.Flavor)
+
+	selectBuilder := auditEventStruct.SelectFrom("audit_events")
+	d.applyAuditEventFilters(selectBuilder, event, userId, clientId, from, to)
+	selectBuilder.Where(selectBuilder.GreaterThan("audit_events.id", afterId))
+	selectBuilder.OrderBy("audit_events.id").Asc()
+	selectBuilder.Limit(limit)
+
+	sql, args := selectBuilder.Build()
+	rows, err := d.QuerySql(tx, sql, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to query database")
+	}
+	defer rows.Close()
+
+	var auditEvents []entities.AuditEvent
+	for rows.Next() {
+		var auditEvent entities.AuditEvent
+		addr := auditEventStruct.Addr(&auditEvent)
+		err = rows.Scan(addr...)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to scan auditEvent")
+		}
+		auditEvents = append(auditEvents, auditEvent)
+	}
+
+	return auditEvents, nil
+}
+
+func (d *CommonDatabase) GetAuditEventTypes(tx *sql.Tx) ([]string, error) {
+
+	selectBuilder := d.Flavor.NewSelectBuilder()
+	selectBuilder.Select("event").Distinct().From("audit_events")
+	selectBuilder.OrderBy("event").Asc()
+
+	sql, args := selectBuilder.Build()
+	rows, err := d.QuerySql(tx, sql, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to query database")
+	}
+	defer rows.Close()
+
+	var eventTypes []string
+	for rows.Next() {
+		var eventType string
+		err = rows.Scan(&eventType)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to scan event type")
+		}
+		eventTypes = append(eventTypes, eventType)
+	}
+
+	return eventTypes, nil
+}
+
+func (d *CommonDatabase) DeleteAuditEventsOlderThan(tx *sql.Tx, cutoff time.Time) error {
+
+	auditEventStruct := sqlbuilder.NewStruct(new(entities.AuditEvent)).
+		For(d.Flavor)
+
+	deleteBuilder := auditEventStruct.DeleteFrom("audit_events")
+	deleteBuilder.Where(deleteBuilder.LessThan("created_at", cutoff))
+
+	sql, args := deleteBuilder.Build()
+	_, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "unable to delete old audit events")
+	}
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package commondb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/pkg/errors"
+)
+
+func (d *CommonDatabase) CreateBackchannelLogoutDelivery(tx *sql.Tx, delivery *entities.BackchannelLogoutDelivery) error {
+
+	if delivery.ClientId == 0 {
+		return errors.WithStack(errors.New("client id must be greater than 0"))
+	}
+
+	now := time.Now().UTC()
+
+	originalCreatedAt := delivery.CreatedAt
+	originalUpdatedAt := delivery.UpdatedAt
+	delivery.CreatedAt = sql.NullTime{Time: now, Valid: true}
+	delivery.UpdatedAt = sql.NullTime{Time: now, Valid: true}
+
+	deliveryStruct := sqlbuilder.NewStruct(new(entities.BackchannelLogoutDelivery)).
+		For(d.Flavor)
+
+	insertBuilder := deliveryStruct.WithoutTag("pk").InsertInto("backchannel_logout_deliveries", delivery)
+
+	sql, args := insertBuilder.Build()
+	result, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		delivery.CreatedAt = originalCreatedAt
+		delivery.UpdatedAt = originalUpdatedAt
+		return errors.Wrap(err, "unable to insert backchannelLogoutDelivery")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		delivery.CreatedAt = originalCreatedAt
+		delivery.UpdatedAt = originalUpdatedAt
+		return errors.Wrap(err, "unable to get last insert id")
+	}
+
+	delivery.Id = id
+	return nil
+}
+
+func (d *CommonDatabase) UpdateBackchannelLogoutDelivery(tx *sql.Tx, delivery *entities.BackchannelLogoutDelivery) error {
+
+	if delivery.Id == 0 {
+		return errors.WithStack(errors.New("can't update backchannelLogoutDelivery with id 0"))
+	}
+
+	originalUpdatedAt := delivery.UpdatedAt
+	delivery.UpdatedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+
+	deliveryStruct := sqlbuilder.NewStruct(new(entities.BackchannelLogoutDelivery)).
+		For(d.Flavor)
+
+	updateBuilder := deliveryStruct.WithoutTag("pk").Update("backchannel_logout_deliveries", delivery)
+	updateBuilder.Where(updateBuilder.Equal("id", delivery.Id))
+
+	sql, args := updateBuilder.Build()
+	_, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		delivery.UpdatedAt = originalUpdatedAt
+		return errors.Wrap(err, "unable to update backchannelLogoutDelivery")
+	}
+
+	return nil
+}
+
+// ClaimBackchannelLogoutDelivery takes a due delivery for the caller, by moving its next attempt
+// to claimedUntil. It returns false when the delivery is no longer due, because another instance
+// claimed it first
+func (d *CommonDatabase) ClaimBackchannelLogoutDelivery(tx *sql.Tx, deliveryId int64, now time.Time,
+	claimedUntil time.Time) (bool, error) {
+
+	if deliveryId == 0 {
+		return false, errors.WithStack(errors.New("can't update backchannelLogoutDelivery with id 0"))
+	}
+
+	updateBuilder := d.Flavor.NewUpdateBuilder()
+	updateBuilder.Update("backchannel_logout_deliveries")
+	updateBuilder.Set(
+		updateBuilder.Assign("next_attempt_at", claimedUntil),
+		updateBuilder.Assign("updated_at", time.Now().UTC()),
+	)
+	updateBuilder.Where(
+		updateBuilder.Equal("id", deliveryId),
+		updateBuilder.Equal("status", enums.BackchannelLogoutStatusPending.String()),
+		updateBuilder.LessEqualThan("next_attempt_at", now),
+	)
+
+	sql, args := updateBuilder.Build()
+	result, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to claim backchannelLogoutDelivery")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "unable to get rows affected")
+	}
+	return rowsAffected == 1, nil
+}
+
+func (d *CommonDatabase) getBackchannelLogoutDeliveriesCommon(tx *sql.Tx, selectBuilder *sqlbuilder.SelectBuilder,
+	deliveryStruct *sqlbuilder.Struct) ([]entities.BackchannelLogoutDelivery, error) {
+
+	sql, args := selectBuilder.Build()
+	rows, err := d.QuerySql(tx, sql, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to query database")
+	}
+	defer rows.Close()
+
+	var deliveries []entities.BackchannelLogoutDelivery
+	for rows.Next() {
+		var delivery entities.BackchannelLogoutDelivery
+		addr := deliveryStruct.Addr(&delivery)
+		err = rows.Scan(addr...)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to scan backchannelLogoutDelivery")
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+func (d *CommonDatabase) GetDueBackchannelLogoutDeliveries(tx *sql.Tx, now time.Time,
+	limit int) ([]entities.BackchannelLogoutDelivery, error) {
+
+	deliveryStruct := sqlbuilder.NewStruct(new(entities.BackchannelLogoutDelivery)).
+		For(d.Flavor)
+
+	selectBuilder := deliveryStruct.SelectFrom("backchannel_logout_deliveries")
+	selectBuilder.Where(
+		selectBuilder.Equal("status", enums.BackchannelLogoutStatusPending.String()),
+		selectBuilder.LessEqualThan("next_attempt_at", now),
+	)
+	selectBuilder.OrderBy("id").Asc()
+	selectBuilder.Limit(limit)
+
+	return d.getBackchannelLogoutDeliveriesCommon(tx, selectBuilder, deliveryStruct)
+}
+
+func (d *CommonDatabase) GetBackchannelLogoutDeliveriesByClientId(tx *sql.Tx, clientId int64,
+	limit int) ([]entities.BackchannelLogoutDelivery, error) {
+
+	deliveryStruct := sqlbuilder.NewStruct(new(entities.BackchannelLogoutDelivery)).
+		For(d.Flavor)
+
+	selectBuilder := deliveryStruct.SelectFrom("backchannel_logout_deliveries")
+	selectBuilder.Where(selectBuilder.Equal("client_id", clientId))
+	selectBuilder.OrderBy("id").Desc()
+	selectBuilder.Limit(limit)
+
+	return d.getBackchannelLogoutDeliveriesCommon(tx, selectBuilder, deliveryStruct)
+}
+
+func (d *CommonDatabase) DeleteBackchannelLogoutDeliveriesOlderThan(tx *sql.Tx, cutoff time.Time) error {
+
+	deliveryStruct := sqlbuilder.NewStruct(new(entities.BackchannelLogoutDelivery)).
+		For(d.Flavor)
+
+	deleteBuilder := deliveryStruct.DeleteFrom("backchannel_logout_deliveries")
+	deleteBuilder.Where(deleteBuilder.LessThan("created_at", cutoff))
+
+	sql, args := deleteBuilder.Build()
+	_, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "unable to delete old backchannelLogoutDeliveries")
+	}
+
+	return nil
+}
@@ -136,6 +136,21 @@ func (d *CommonDatabase) ClientLoadRedirectURIs(tx *sql.Tx, client *entities.Cli
 	return nil
 }
 
+func (d *CommonDatabase) ClientLoadPostLogoutRedirectURIs(tx *sql.Tx, client *entities.Client) error {
+
+	if client == nil {
+		return nil
+	}
+
+	var err error
+	client.PostLogoutRedirectURIs, err = d.GetPostLogoutRedirectURIsByClientId(tx, client.Id)
+	if err != nil {
+		return errors.Wrap(err, "unable to get post logout redirect URIs")
+	}
+
+	return nil
+}
+
 func (d *CommonDatabase) ClientLoadWebOrigins(tx *sql.Tx, client *entities.Client) error {
 
 	if client == nil {
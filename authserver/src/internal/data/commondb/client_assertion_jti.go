@@ -0,0 +1,86 @@
+package commondb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/pkg/errors"
+)
+
+func (d *CommonDatabase) CreateClientAssertionJti(tx *sql.Tx, clientAssertionJti *entities.ClientAssertionJti) error {
+
+	if clientAssertionJti.ClientId == 0 {
+		return errors.WithStack(errors.New("client id must be greater than 0"))
+	}
+
+	originalCreatedAt := clientAssertionJti.CreatedAt
+	clientAssertionJti.CreatedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+
+	clientAssertionJtiStruct := sqlbuilder.NewStruct(new(entities.ClientAssertionJti)).
+		For(d.Flavor)
+
+	insertBuilder := clientAssertionJtiStruct.WithoutTag("pk").InsertInto("client_assertion_jtis", clientAssertionJti)
+
+	sql, args := insertBuilder.Build()
+	result, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		clientAssertionJti.CreatedAt = originalCreatedAt
+		return errors.Wrap(err, "unable to insert clientAssertionJti")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		clientAssertionJti.CreatedAt = originalCreatedAt
+		return errors.Wrap(err, "unable to get last insert id")
+	}
+
+	clientAssertionJti.Id = id
+	return nil
+}
+
+func (d *CommonDatabase) GetClientAssertionJti(tx *sql.Tx, clientId int64, jti string) (*entities.ClientAssertionJti, error) {
+
+	clientAssertionJtiStruct := sqlbuilder.NewStruct(new(entities.ClientAssertionJti)).
+		For(d.Flavor)
+
+	selectBuilder := clientAssertionJtiStruct.SelectFrom("client_assertion_jtis")
+	selectBuilder.Where(selectBuilder.Equal("client_id", clientId))
+	selectBuilder.Where(selectBuilder.Equal("jti", jti))
+
+	sql, args := selectBuilder.Build()
+	rows, err := d.QuerySql(tx, sql, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to query database")
+	}
+	defer rows.Close()
+
+	var clientAssertionJti entities.ClientAssertionJti
+	if rows.Next() {
+		addr := clientAssertionJtiStruct.Addr(&clientAssertionJti)
+		err = rows.Scan(addr...)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to scan clientAssertionJti")
+		}
+		return &clientAssertionJti, nil
+	}
+	return nil, nil
+}
+
+func (d *CommonDatabase) DeleteExpiredClientAssertionJtis(tx *sql.Tx) error {
+
+	clientAssertionJtiStruct := sqlbuilder.NewStruct(new(entities.ClientAssertionJti)).
+		For(d.Flavor)
+
+	deleteBuilder := clientAssertionJtiStruct.DeleteFrom("client_assertion_jtis")
+	deleteBuilder.Where(deleteBuilder.LessThan("expires_at", time.Now().UTC()))
+
+	sql, args := deleteBuilder.Build()
+	_, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "unable to delete expired clientAssertionJtis")
+	}
+
+	return nil
+}
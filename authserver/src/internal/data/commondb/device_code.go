@@ -0,0 +1,237 @@
+package commondb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/pkg/errors"
+)
+
+func (d *CommonDatabase) CreateDeviceCode(tx *sql.Tx, deviceCode *entities.DeviceCode) error {
+
+	if deviceCode.ClientId == 0 {
+		return errors.WithStack(errors.New("client id must be greater than 0"))
+	}
+
+	if len(deviceCode.DeviceCodeHash) == 0 {
+		return errors.WithStack(errors.New("device code hash is required"))
+	}
+
+	if len(deviceCode.UserCodeHash) == 0 {
+		return errors.WithStack(errors.New("user code hash is required"))
+	}
+
+	now := time.Now().UTC()
+
+	originalCreatedAt := deviceCode.CreatedAt
+	originalUpdatedAt := deviceCode.UpdatedAt
+	deviceCode.CreatedAt = sql.NullTime{Time: now, Valid: true}
+	deviceCode.UpdatedAt = sql.NullTime{Time: now, Valid: true}
+
+	deviceCodeStruct := sqlbuilder.NewStruct(new(entities.DeviceCode)).
+		For(d.Flavor)
+
+	insertBuilder := deviceCodeStruct.WithoutTag("pk").InsertInto("device_codes", deviceCode)
+
+	sql, args := insertBuilder.Build()
+	result, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		deviceCode.CreatedAt = originalCreatedAt
+		deviceCode.UpdatedAt = originalUpdatedAt
+		return errors.Wrap(err, "unable to insert deviceCode")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		deviceCode.CreatedAt = originalCreatedAt
+		deviceCode.UpdatedAt = originalUpdatedAt
+		return errors.Wrap(err, "unable to get last insert id")
+	}
+
+	deviceCode.Id = id
+	return nil
+}
+
+func (d *CommonDatabase) UpdateDeviceCode(tx *sql.Tx, deviceCode *entities.DeviceCode) error {
+
+	if deviceCode.Id == 0 {
+		return errors.WithStack(errors.New("can't update deviceCode with id 0"))
+	}
+
+	originalUpdatedAt := deviceCode.UpdatedAt
+	deviceCode.UpdatedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+
+	deviceCodeStruct := sqlbuilder.NewStruct(new(entities.DeviceCode)).
+		For(d.Flavor)
+
+	updateBuilder := deviceCodeStruct.WithoutTag("pk").Update("device_codes", deviceCode)
+	updateBuilder.Where(updateBuilder.Equal("id", deviceCode.Id))
+
+	sql, args := updateBuilder.Build()
+	_, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		deviceCode.UpdatedAt = originalUpdatedAt
+		return errors.Wrap(err, "unable to update deviceCode")
+	}
+
+	return nil
+}
+
+// UpdateDeviceCodePolling records a poll of the device, while the device code is still pending.
+// It returns false when the user approved or denied the request in the meantime
+func (d *CommonDatabase) UpdateDeviceCodePolling(tx *sql.Tx, deviceCodeId int64, lastPolledAt time.Time,
+	intervalInSeconds int) (bool, error) {
+
+	if deviceCodeId == 0 {
+		return false, errors.WithStack(errors.New("can't update deviceCode with id 0"))
+	}
+
+	updateBuilder := d.Flavor.NewUpdateBuilder()
+	updateBuilder.Update("device_codes")
+	updateBuilder.Set(
+		updateBuilder.Assign("last_polled_at", lastPolledAt),
+		updateBuilder.Assign("interval_in_seconds", intervalInSeconds),
+		updateBuilder.Assign("updated_at", time.Now().UTC()),
+	)
+	updateBuilder.Where(
+		updateBuilder.Equal("id", deviceCodeId),
+		updateBuilder.Equal("status", enums.DeviceCodeStatusPending.String()),
+	)
+
+	return d.execDeviceCodeConditionalUpdate(tx, updateBuilder)
+}
+
+// ApproveDeviceCode approves a pending device code, with the authorization code the device will
+// redeem. It returns false when the device code was no longer pending
+func (d *CommonDatabase) ApproveDeviceCode(tx *sql.Tx, deviceCodeId int64, codeId int64) (bool, error) {
+
+	if deviceCodeId == 0 {
+		return false, errors.WithStack(errors.New("can't update deviceCode with id 0"))
+	}
+
+	updateBuilder := d.Flavor.NewUpdateBuilder()
+	updateBuilder.Update("device_codes")
+	updateBuilder.Set(
+		updateBuilder.Assign("status", enums.DeviceCodeStatusApproved.String()),
+		updateBuilder.Assign("code_id", codeId),
+		updateBuilder.Assign("updated_at", time.Now().UTC()),
+	)
+	updateBuilder.Where(
+		updateBuilder.Equal("id", deviceCodeId),
+		updateBuilder.Equal("status", enums.DeviceCodeStatusPending.String()),
+	)
+
+	return d.execDeviceCodeConditionalUpdate(tx, updateBuilder)
+}
+
+// DenyDeviceCode denies a pending device code. It returns false when the device code was no
+// longer pending
+func (d *CommonDatabase) DenyDeviceCode(tx *sql.Tx, deviceCodeId int64) (bool, error) {
+
+	if deviceCodeId == 0 {
+		return false, errors.WithStack(errors.New("can't update deviceCode with id 0"))
+	}
+
+	updateBuilder := d.Flavor.NewUpdateBuilder()
+	updateBuilder.Update("device_codes")
+	updateBuilder.Set(
+		updateBuilder.Assign("status", enums.DeviceCodeStatusDenied.String()),
+		updateBuilder.Assign("updated_at", time.Now().UTC()),
+	)
+	updateBuilder.Where(
+		updateBuilder.Equal("id", deviceCodeId),
+		updateBuilder.Equal("status", enums.DeviceCodeStatusPending.String()),
+	)
+
+	return d.execDeviceCodeConditionalUpdate(tx, updateBuilder)
+}
+
+func (d *CommonDatabase) execDeviceCodeConditionalUpdate(tx *sql.Tx, updateBuilder *sqlbuilder.UpdateBuilder) (bool, error) {
+
+	sql, args := updateBuilder.Build()
+	result, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to update deviceCode")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "unable to get rows affected")
+	}
+	return rowsAffected == 1, nil
+}
+
+func (d *CommonDatabase) getDeviceCodeCommon(tx *sql.Tx, selectBuilder *sqlbuilder.SelectBuilder,
+	deviceCodeStruct *sqlbuilder.Struct) (*entities.DeviceCode, error) {
+
+	sql, args := selectBuilder.Build()
+	rows, err := d.QuerySql(tx, sql, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to query database")
+	}
+	defer rows.Close()
+
+	var deviceCode entities.DeviceCode
+	if rows.Next() {
+		addr := deviceCodeStruct.Addr(&deviceCode)
+		err = rows.Scan(addr...)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to scan deviceCode")
+		}
+		return &deviceCode, nil
+	}
+	return nil, nil
+}
+
+func (d *CommonDatabase) GetDeviceCodeById(tx *sql.Tx, deviceCodeId int64) (*entities.DeviceCode, error) {
+
+	deviceCodeStruct := sqlbuilder.NewStruct(new(entities.DeviceCode)).
+		For(d.Flavor)
+
+	selectBuilder := deviceCodeStruct.SelectFrom("device_codes")
+	selectBuilder.Where(selectBuilder.Equal("id", deviceCodeId))
+
+	return d.getDeviceCodeCommon(tx, selectBuilder, deviceCodeStruct)
+}
+
+func (d *CommonDatabase) GetDeviceCodeByDeviceCodeHash(tx *sql.Tx, deviceCodeHash string) (*entities.DeviceCode, error) {
+
+	deviceCodeStruct := sqlbuilder.NewStruct(new(entities.DeviceCode)).
+		For(d.Flavor)
+
+	selectBuilder := deviceCodeStruct.SelectFrom("device_codes")
+	selectBuilder.Where(selectBuilder.Equal("device_code_hash", deviceCodeHash))
+
+	return d.getDeviceCodeCommon(tx, selectBuilder, deviceCodeStruct)
+}
+
+func (d *CommonDatabase) GetDeviceCodeByUserCodeHash(tx *sql.Tx, userCodeHash string) (*entities.DeviceCode, error) {
+
+	deviceCodeStruct := sqlbuilder.NewStruct(new(entities.DeviceCode)).
+		For(d.Flavor)
+
+	selectBuilder := deviceCodeStruct.SelectFrom("device_codes")
+	selectBuilder.Where(selectBuilder.Equal("user_code_hash", userCodeHash))
+
+	return d.getDeviceCodeCommon(tx, selectBuilder, deviceCodeStruct)
+}
+
+func (d *CommonDatabase) DeleteExpiredDeviceCodes(tx *sql.Tx) error {
+
+	deviceCodeStruct := sqlbuilder.NewStruct(new(entities.DeviceCode)).
+		For(d.Flavor)
+
+	deleteBuilder := deviceCodeStruct.DeleteFrom("device_codes")
+	deleteBuilder.Where(deleteBuilder.LessThan("expires_at", time.Now().UTC()))
+
+	sql, args := deleteBuilder.Build()
+	_, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "unable to delete expired deviceCodes")
+	}
+
+	return nil
+}
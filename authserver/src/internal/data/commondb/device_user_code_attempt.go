@@ -0,0 +1,86 @@
+package commondb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/pkg/errors"
+)
+
+func (d *CommonDatabase) CreateDeviceUserCodeAttempt(tx *sql.Tx, attempt *entities.DeviceUserCodeAttempt) error {
+
+	if len(attempt.IpAddress) == 0 {
+		return errors.WithStack(errors.New("ip address is required"))
+	}
+
+	originalCreatedAt := attempt.CreatedAt
+	attempt.CreatedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+
+	attemptStruct := sqlbuilder.NewStruct(new(entities.DeviceUserCodeAttempt)).
+		For(d.Flavor)
+
+	insertBuilder := attemptStruct.WithoutTag("pk").InsertInto("device_user_code_attempts", attempt)
+
+	sql, args := insertBuilder.Build()
+	result, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		attempt.CreatedAt = originalCreatedAt
+		return errors.Wrap(err, "unable to insert deviceUserCodeAttempt")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		attempt.CreatedAt = originalCreatedAt
+		return errors.Wrap(err, "unable to get last insert id")
+	}
+
+	attempt.Id = id
+	return nil
+}
+
+// CountDeviceUserCodeAttemptsSince returns how many invalid user codes were entered from the IP
+// address since the given time
+func (d *CommonDatabase) CountDeviceUserCodeAttemptsSince(tx *sql.Tx, ipAddress string, since time.Time) (int, error) {
+
+	selectBuilder := d.Flavor.NewSelectBuilder()
+	selectBuilder.Select("count(*)").From("device_user_code_attempts")
+	selectBuilder.Where(
+		selectBuilder.Equal("ip_address", ipAddress),
+		selectBuilder.GreaterThan("created_at", since),
+	)
+
+	sql, args := selectBuilder.Build()
+	rows, err := d.QuerySql(tx, sql, args...)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to query database")
+	}
+	defer rows.Close()
+
+	var count int
+	if rows.Next() {
+		err = rows.Scan(&count)
+		if err != nil {
+			return 0, errors.Wrap(err, "unable to scan count")
+		}
+	}
+	return count, nil
+}
+
+func (d *CommonDatabase) DeleteDeviceUserCodeAttemptsOlderThan(tx *sql.Tx, cutoff time.Time) error {
+
+	attemptStruct := sqlbuilder.NewStruct(new(entities.DeviceUserCodeAttempt)).
+		For(d.Flavor)
+
+	deleteBuilder := attemptStruct.DeleteFrom("device_user_code_attempts")
+	deleteBuilder.Where(deleteBuilder.LessThan("created_at", cutoff))
+
+	sql, args := deleteBuilder.Build()
+	_, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "unable to delete old deviceUserCodeAttempts")
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package commondb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/pkg/errors"
+)
+
+func (d *CommonDatabase) CreateDPoPProofJti(tx *sql.Tx, dpopProofJti *entities.DPoPProofJti) error {
+
+	if len(dpopProofJti.Jkt) == 0 {
+		return errors.WithStack(errors.New("jkt is required"))
+	}
+
+	originalCreatedAt := dpopProofJti.CreatedAt
+	dpopProofJti.CreatedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+
+	dpopProofJtiStruct := sqlbuilder.NewStruct(new(entities.DPoPProofJti)).
+		For(d.Flavor)
+
+	insertBuilder := dpopProofJtiStruct.WithoutTag("pk").InsertInto("dpop_proof_jtis", dpopProofJti)
+
+	sql, args := insertBuilder.Build()
+	result, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		dpopProofJti.CreatedAt = originalCreatedAt
+		return errors.Wrap(err, "unable to insert dpopProofJti")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		dpopProofJti.CreatedAt = originalCreatedAt
+		return errors.Wrap(err, "unable to get last insert id")
+	}
+
+	dpopProofJti.Id = id
+	return nil
+}
+
+func (d *CommonDatabase) GetDPoPProofJti(tx *sql.Tx, jkt string, jti string) (*entities.DPoPProofJti, error) {
+
+	dpopProofJtiStruct := sqlbuilder.NewStruct(new(entities.DPoPProofJti)).
+		For(d.Flavor)
+
+	selectBuilder := dpopProofJtiStruct.SelectFrom("dpop_proof_jtis")
+	selectBuilder.Where(selectBuilder.Equal("jkt", jkt))
+	selectBuilder.Where(selectBuilder.Equal("jti", jti))
+
+	sql, args := selectBuilder.Build()
+	rows, err := d.QuerySql(tx, sql, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to query database")
+	}
+	defer rows.Close()
+
+	var dpopProofJti entities.DPoPProofJti
+	if rows.Next() {
+		addr := dpopProofJtiStruct.Addr(&dpopProofJti)
+		err = rows.Scan(addr...)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to scan dpopProofJti")
+		}
+		return &dpopProofJti, nil
+	}
+	return nil, nil
+}
+
+func (d *CommonDatabase) DeleteExpiredDPoPProofJtis(tx *sql.Tx) error {
+
+	dpopProofJtiStruct := sqlbuilder.NewStruct(new(entities.DPoPProofJti)).
+		For(d.Flavor)
+
+	deleteBuilder := dpopProofJtiStruct.DeleteFrom("dpop_proof_jtis")
+	deleteBuilder.Where(deleteBuilder.LessThan("expires_at", time.Now().UTC()))
+
+	sql, args := deleteBuilder.Build()
+	_, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "unable to delete expired dpopProofJtis")
+	}
+
+	return nil
+}
@@ -68,6 +68,42 @@ func (d *CommonDatabase) UpdateKeyPair(tx *sql.Tx, keyPair *entities.KeyPair) er
 	return nil
 }
 
+// UpdateKeyPairIfState updates the key pair only while it's still in the given state, so that
+// concurrent rotations can't both move the same key. Returns false when the state had changed
+func (d *CommonDatabase) UpdateKeyPairIfState(tx *sql.Tx, keyPair *entities.KeyPair, state string) (bool, error) {
+
+	if keyPair.Id == 0 {
+		return false, errors.WithStack(errors.New("can't update keyPair with id 0"))
+	}
+
+	originalUpdatedAt := keyPair.UpdatedAt
+	keyPair.UpdatedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+
+	keyPairStruct := sqlbuilder.NewStruct(new(entities.KeyPair)).
+		For(d.Flavor)
+
+	updateBuilder := keyPairStruct.WithoutTag("pk").Update("key_pairs", keyPair)
+	updateBuilder.Where(
+		updateBuilder.Equal("id", keyPair.Id),
+		updateBuilder.Equal("state", state),
+	)
+
+	sql, args := updateBuilder.Build()
+	result, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		keyPair.UpdatedAt = originalUpdatedAt
+		return false, errors.Wrap(err, "unable to update keyPair")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		keyPair.UpdatedAt = originalUpdatedAt
+		return false, errors.Wrap(err, "unable to get rows affected")
+	}
+
+	return rowsAffected == 1, nil
+}
+
 func (d *CommonDatabase) getKeyPairCommon(tx *sql.Tx, selectBuilder *sqlbuilder.SelectBuilder,
 	keyPairStruct *sqlbuilder.Struct) (*entities.KeyPair, error) {
 
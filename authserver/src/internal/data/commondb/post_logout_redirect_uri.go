@@ -0,0 +1,89 @@
+package commondb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/pkg/errors"
+)
+
+func (d *CommonDatabase) CreatePostLogoutRedirectURI(tx *sql.Tx, postLogoutRedirectURI *entities.PostLogoutRedirectURI) error {
+
+	if postLogoutRedirectURI.ClientId == 0 {
+		return errors.WithStack(errors.New("client id must be greater than 0"))
+	}
+
+	now := time.Now().UTC()
+
+	originalCreatedAt := postLogoutRedirectURI.CreatedAt
+	postLogoutRedirectURI.CreatedAt = sql.NullTime{Time: now, Valid: true}
+
+	postLogoutRedirectURIStruct := sqlbuilder.NewStruct(new(entities.PostLogoutRedirectURI)).
+		For(d.Flavor)
+
+	insertBuilder := postLogoutRedirectURIStruct.WithoutTag("pk").InsertInto("post_logout_redirect_uris", postLogoutRedirectURI)
+
+	sql, args := insertBuilder.Build()
+	result, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		postLogoutRedirectURI.CreatedAt = originalCreatedAt
+		return errors.Wrap(err, "unable to insert postLogoutRedirectURI")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		postLogoutRedirectURI.CreatedAt = originalCreatedAt
+		return errors.Wrap(err, "unable to get last insert id")
+	}
+
+	postLogoutRedirectURI.Id = id
+	return nil
+}
+
+func (d *CommonDatabase) GetPostLogoutRedirectURIsByClientId(tx *sql.Tx, clientId int64) ([]entities.PostLogoutRedirectURI, error) {
+
+	postLogoutRedirectURIStruct := sqlbuilder.NewStruct(new(entities.PostLogoutRedirectURI)).
+		For(d.Flavor)
+
+	selectBuilder := postLogoutRedirectURIStruct.SelectFrom("post_logout_redirect_uris")
+	selectBuilder.Where(selectBuilder.Equal("client_id", clientId))
+
+	sql, args := selectBuilder.Build()
+	rows, err := d.QuerySql(tx, sql, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to query database")
+	}
+	defer rows.Close()
+
+	postLogoutRedirectURIs := []entities.PostLogoutRedirectURI{}
+	for rows.Next() {
+		var postLogoutRedirectURI entities.PostLogoutRedirectURI
+		addr := postLogoutRedirectURIStruct.Addr(&postLogoutRedirectURI)
+		err = rows.Scan(addr...)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to scan postLogoutRedirectURI")
+		}
+		postLogoutRedirectURIs = append(postLogoutRedirectURIs, postLogoutRedirectURI)
+	}
+
+	return postLogoutRedirectURIs, nil
+}
+
+func (d *CommonDatabase) DeletePostLogoutRedirectURI(tx *sql.Tx, postLogoutRedirectURIId int64) error {
+
+	postLogoutRedirectURIStruct := sqlbuilder.NewStruct(new(entities.PostLogoutRedirectURI)).
+		For(d.Flavor)
+
+	deleteBuilder := postLogoutRedirectURIStruct.DeleteFrom("post_logout_redirect_uris")
+	deleteBuilder.Where(deleteBuilder.Equal("id", postLogoutRedirectURIId))
+
+	sql, args := deleteBuilder.Build()
+	_, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "unable to delete postLogoutRedirectURI")
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package commondb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/pkg/errors"
+)
+
+func (d *CommonDatabase) CreatePushedAuthorizationRequest(tx *sql.Tx, pushedAuthorizationRequest *entities.PushedAuthorizationRequest) error {
+
+	if pushedAuthorizationRequest.ClientId == 0 {
+		return errors.WithStack(errors.New("client id must be greater than 0"))
+	}
+
+	if len(pushedAuthorizationRequest.RequestURIHash) == 0 {
+		return errors.WithStack(errors.New("request uri hash is required"))
+	}
+
+	originalCreatedAt := pushedAuthorizationRequest.CreatedAt
+	pushedAuthorizationRequest.CreatedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+
+	pushedAuthorizationRequestStruct := sqlbuilder.NewStruct(new(entities.PushedAuthorizationRequest)).
+		For(d.Flavor)
+
+	insertBuilder := pushedAuthorizationRequestStruct.WithoutTag("pk").InsertInto("pushed_authorization_requests", pushedAuthorizationRequest)
+
+	sql, args := insertBuilder.Build()
+	result, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		pushedAuthorizationRequest.CreatedAt = originalCreatedAt
+		return errors.Wrap(err, "unable to insert pushedAuthorizationRequest")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		pushedAuthorizationRequest.CreatedAt = originalCreatedAt
+		return errors.Wrap(err, "unable to get last insert id")
+	}
+
+	pushedAuthorizationRequest.Id = id
+	return nil
+}
+
+func (d *CommonDatabase) GetPushedAuthorizationRequestByRequestURIHash(tx *sql.Tx, requestURIHash string) (*entities.PushedAuthorizationRequest, error) {
+
+	pushedAuthorizationRequestStruct := sqlbuilder.NewStruct(new(entities.PushedAuthorizationRequest)).
+		For(d.Flavor)
+
+	selectBuilder := pushedAuthorizationRequestStruct.SelectFrom("pushed_authorization_requests")
+	selectBuilder.Where(selectBuilder.Equal("request_uri_hash", requestURIHash))
+
+	sql, args := selectBuilder.Build()
+	rows, err := d.QuerySql(tx, sql, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to query database")
+	}
+	defer rows.Close()
+
+	var pushedAuthorizationRequest entities.PushedAuthorizationRequest
+	if rows.Next() {
+		addr := pushedAuthorizationRequestStruct.Addr(&pushedAuthorizationRequest)
+		err = rows.Scan(addr...)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to scan pushedAuthorizationRequest")
+		}
+		return &pushedAuthorizationRequest, nil
+	}
+	return nil, nil
+}
+
+func (d *CommonDatabase) DeletePushedAuthorizationRequest(tx *sql.Tx, pushedAuthorizationRequestId int64) (bool, error) {
+
+	pushedAuthorizationRequestStruct := sqlbuilder.NewStruct(new(entities.PushedAuthorizationRequest)).
+		For(d.Flavor)
+
+	deleteBuilder := pushedAuthorizationRequestStruct.DeleteFrom("pushed_authorization_requests")
+	deleteBuilder.Where(deleteBuilder.Equal("id", pushedAuthorizationRequestId))
+
+	sql, args := deleteBuilder.Build()
+	result, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to delete pushedAuthorizationRequest")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "unable to get rows affected")
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (d *CommonDatabase) DeleteExpiredPushedAuthorizationRequests(tx *sql.Tx) error {
+
+	pushedAuthorizationRequestStruct := sqlbuilder.NewStruct(new(entities.PushedAuthorizationRequest)).
+		For(d.Flavor)
+
+	deleteBuilder := pushedAuthorizationRequestStruct.DeleteFrom("pushed_authorization_requests")
+	deleteBuilder.Where(deleteBuilder.LessThan("expires_at", time.Now().UTC()))
+
+	sql, args := deleteBuilder.Build()
+	_, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "unable to delete expired pushedAuthorizationRequests")
+	}
+
+	return nil
+}
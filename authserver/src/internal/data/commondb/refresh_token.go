@@ -67,6 +67,40 @@ func (d *CommonDatabase) UpdateRefreshToken(tx *sql.Tx, refreshToken *entities.R
 	return nil
 }
 
+// RedeemRefreshToken revokes the refresh token (and, with rotation, also marks it as used),
+// but only if it's still active. It returns false when another request redeemed it first
+func (d *CommonDatabase) RedeemRefreshToken(tx *sql.Tx, refreshTokenId int64, markAsUsed bool) (bool, error) {
+
+	updateBuilder := d.Flavor.NewUpdateBuilder()
+	updateBuilder.Update("refresh_tokens")
+	assignments := []string{
+		updateBuilder.Assign("revoked", true),
+		updateBuilder.Assign("updated_at", time.Now().UTC()),
+	}
+	if markAsUsed {
+		assignments = append(assignments, updateBuilder.Assign("used", true))
+	}
+	updateBuilder.Set(assignments...)
+	updateBuilder.Where(
+		updateBuilder.Equal("id", refreshTokenId),
+		updateBuilder.Equal("revoked", false),
+		updateBuilder.Equal("used", false),
+	)
+
+	sql, args := updateBuilder.Build()
+	result, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to redeem refreshToken")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "unable to get rows affected")
+	}
+
+	return rowsAffected == 1, nil
+}
+
 func (d *CommonDatabase) getRefreshTokenCommon(tx *sql.Tx, selectBuilder *sqlbuilder.SelectBuilder,
 	refreshTokenStruct *sqlbuilder.Struct) (*entities.RefreshToken, error) {
 
@@ -138,6 +172,35 @@ func (d *CommonDatabase) GetRefreshTokenByJti(tx *sql.Tx, jti string) (*entities
 	return refreshToken, nil
 }
 
+func (d *CommonDatabase) GetRefreshTokensByFirstRefreshTokenJti(tx *sql.Tx, firstRefreshTokenJti string) ([]entities.RefreshToken, error) {
+
+	refreshTokenStruct := sqlbuilder.NewStruct(new(entities.RefreshToken)).
+		For(d.Flavor)
+
+	selectBuilder := refreshTokenStruct.SelectFrom("refresh_tokens")
+	selectBuilder.Where(selectBuilder.Equal("first_refresh_token_jti", firstRefreshTokenJti))
+
+	sql, args := selectBuilder.Build()
+	rows, err := d.QuerySql(tx, sql, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to query database")
+	}
+	defer rows.Close()
+
+	var refreshTokens []entities.RefreshToken
+	for rows.Next() {
+		var refreshToken entities.RefreshToken
+		addr := refreshTokenStruct.Addr(&refreshToken)
+		err = rows.Scan(addr...)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to scan refreshToken")
+		}
+		refreshTokens = append(refreshTokens, refreshToken)
+	}
+
+	return refreshTokens, nil
+}
+
 func (d *CommonDatabase) DeleteRefreshToken(tx *sql.Tx, refreshTokenId int64) error {
 
 	userConsentStruct := sqlbuilder.NewStruct(new(entities.RefreshToken)).
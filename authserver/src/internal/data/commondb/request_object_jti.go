@@ -0,0 +1,86 @@
+package commondb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/pkg/errors"
+)
+
+func (d *CommonDatabase) CreateRequestObjectJti(tx *sql.Tx, requestObjectJti *entities.RequestObjectJti) error {
+
+	if requestObjectJti.ClientId == 0 {
+		return errors.WithStack(errors.New("client id must be greater than 0"))
+	}
+
+	originalCreatedAt := requestObjectJti.CreatedAt
+	requestObjectJti.CreatedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+
+	requestObjectJtiStruct := sqlbuilder.NewStruct(new(entities.RequestObjectJti)).
+		For(d.Flavor)
+
+	insertBuilder := requestObjectJtiStruct.WithoutTag("pk").InsertInto("request_object_jtis", requestObjectJti)
+
+	sql, args := insertBuilder.Build()
+	result, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		requestObjectJti.CreatedAt = originalCreatedAt
+		return errors.Wrap(err, "unable to insert requestObjectJti")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		requestObjectJti.CreatedAt = originalCreatedAt
+		return errors.Wrap(err, "unable to get last insert id")
+	}
+
+	requestObjectJti.Id = id
+	return nil
+}
+
+func (d *CommonDatabase) GetRequestObjectJti(tx *sql.Tx, clientId int64, jti string) (*entities.RequestObjectJti, error) {
+
+	requestObjectJtiStruct := sqlbuilder.NewStruct(new(entities.RequestObjectJti)).
+		For(d.Flavor)
+
+	selectBuilder := requestObjectJtiStruct.SelectFrom("request_object_jtis")
+	selectBuilder.Where(selectBuilder.Equal("client_id", clientId))
+	selectBuilder.Where(selectBuilder.Equal("jti", jti))
+
+	sql, args := selectBuilder.Build()
+	rows, err := d.QuerySql(tx, sql, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to query database")
+	}
+	defer rows.Close()
+
+	var requestObjectJti entities.RequestObjectJti
+	if rows.Next() {
+		addr := requestObjectJtiStruct.Addr(&requestObjectJti)
+		err = rows.Scan(addr...)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to scan requestObjectJti")
+		}
+		return &requestObjectJti, nil
+	}
+	return nil, nil
+}
+
+func (d *CommonDatabase) DeleteExpiredRequestObjectJtis(tx *sql.Tx) error {
+
+	requestObjectJtiStruct := sqlbuilder.NewStruct(new(entities.RequestObjectJti)).
+		For(d.Flavor)
+
+	deleteBuilder := requestObjectJtiStruct.DeleteFrom("request_object_jtis")
+	deleteBuilder.Where(deleteBuilder.LessThan("expires_at", time.Now().UTC()))
+
+	sql, args := deleteBuilder.Build()
+	_, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "unable to delete expired requestObjectJtis")
+	}
+
+	return nil
+}
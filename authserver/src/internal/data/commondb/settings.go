@@ -6,6 +6,7 @@ import (
 
 	"github.com/huandu/go-sqlbuilder"
 	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/lib"
 	"github.com/pkg/errors"
 )
 
@@ -18,10 +19,17 @@ func (d *CommonDatabase) CreateSettings(tx *sql.Tx, settings *entities.Settings)
 	settings.CreatedAt = sql.NullTime{Time: now, Valid: true}
 	settings.UpdatedAt = sql.NullTime{Time: now, Valid: true}
 
+	storedSettings, err := wrapSettingsKeys(settings)
+	if err != nil {
+		settings.CreatedAt = originalCreatedAt
+		settings.UpdatedAt = originalUpdatedAt
+		return err
+	}
+
 	settingsStruct := sqlbuilder.NewStruct(new(entities.Settings)).
 		For(d.Flavor)
 
-	insertBuilder := settingsStruct.WithoutTag("pk").InsertInto("settings", settings)
+	insertBuilder := settingsStruct.WithoutTag("pk").InsertInto("settings", storedSettings)
 
 	sql, args := insertBuilder.Build()
 	result, err := d.ExecSql(tx, sql, args...)
@@ -39,6 +47,8 @@ func (d *CommonDatabase) CreateSettings(tx *sql.Tx, settings *entities.Settings)
 	}
 
 	settings.Id = id
+	settings.AESEncryptionKeyWrapped = storedSettings.AESEncryptionKeyWrapped
+	settings.SessionKeysWrapped = storedSettings.SessionKeysWrapped
 	return nil
 }
 
@@ -51,19 +61,27 @@ func (d *CommonDatabase) UpdateSettings(tx *sql.Tx, settings *entities.Settings)
 	originalUpdatedAt := settings.UpdatedAt
 	settings.UpdatedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
 
+	storedSettings, err := wrapSettingsKeys(settings)
+	if err != nil {
+		settings.UpdatedAt = originalUpdatedAt
+		return err
+	}
+
 	settingsStruct := sqlbuilder.NewStruct(new(entities.Settings)).
 		For(d.Flavor)
 
-	updateBuilder := settingsStruct.WithoutTag("pk").Update("settings", settings)
+	updateBuilder := settingsStruct.WithoutTag("pk").Update("settings", storedSettings)
 	updateBuilder.Where(updateBuilder.Equal("id", settings.Id))
 
 	sql, args := updateBuilder.Build()
-	_, err := d.ExecSql(tx, sql, args...)
+	_, err = d.ExecSql(tx, sql, args...)
 	if err != nil {
 		settings.UpdatedAt = originalUpdatedAt
 		return errors.Wrap(err, "unable to update settings")
 	}
 
+	settings.AESEncryptionKeyWrapped = storedSettings.AESEncryptionKeyWrapped
+	settings.SessionKeysWrapped = storedSettings.SessionKeysWrapped
 	return nil
 }
 
@@ -84,6 +102,10 @@ func (d *CommonDatabase) getSettingsCommon(tx *sql.Tx, selectBuilder *sqlbuilder
 		if err != nil {
 			return nil, errors.Wrap(err, "unable to scan settings")
 		}
+		err = unwrapSettingsKeys(&settings)
+		if err != nil {
+			return nil, err
+		}
 		return &settings, nil
 	}
 	return nil, nil
@@ -104,3 +126,71 @@ func (d *CommonDatabase) GetSettingsById(tx *sql.Tx, settingsId int64) (*entitie
 
 	return settings, nil
 }
+
+// wrapSettingsKeys returns a copy of the settings to be stored, where the AES encryption key
+// and the session keys are wrapped by the master key (when one is configured). The settings
+// handed out to the rest of the application always hold the unwrapped keys
+func wrapSettingsKeys(settings *entities.Settings) (*entities.Settings, error) {
+	masterKey, err := lib.GetMasterKey()
+	if err != nil {
+		return nil, err
+	}
+
+	storedSettings := *settings
+	storedSettings.AESEncryptionKeyWrapped = false
+	storedSettings.SessionKeysWrapped = false
+	if masterKey != nil {
+		storedSettings.AESEncryptionKey, err = lib.EncryptText(string(settings.AESEncryptionKey), masterKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to wrap the AES encryption key")
+		}
+		storedSettings.AESEncryptionKeyWrapped = true
+
+		storedSettings.SessionAuthenticationKey, err = lib.EncryptText(string(settings.SessionAuthenticationKey), masterKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to wrap the session authentication key")
+		}
+		storedSettings.SessionEncryptionKey, err = lib.EncryptText(string(settings.SessionEncryptionKey), masterKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to wrap the session encryption key")
+		}
+		storedSettings.SessionKeysWrapped = true
+	}
+	return &storedSettings, nil
+}
+
+func unwrapSettingsKeys(settings *entities.Settings) error {
+	if !settings.AESEncryptionKeyWrapped && !settings.SessionKeysWrapped {
+		return nil
+	}
+
+	masterKey, err := lib.GetMasterKey()
+	if err != nil {
+		return err
+	}
+	if masterKey == nil {
+		return errors.WithStack(errors.New("the settings keys are wrapped by a master key, but GOIABADA_MASTER_KEY_FILE is not set"))
+	}
+
+	if settings.AESEncryptionKeyWrapped {
+		aesEncryptionKey, err := lib.DecryptText(settings.AESEncryptionKey, masterKey)
+		if err != nil {
+			return errors.Wrap(err, "unable to unwrap the AES encryption key with the master key")
+		}
+		settings.AESEncryptionKey = []byte(aesEncryptionKey)
+	}
+
+	if settings.SessionKeysWrapped {
+		sessionAuthenticationKey, err := lib.DecryptText(settings.SessionAuthenticationKey, masterKey)
+		if err != nil {
+			return errors.Wrap(err, "unable to unwrap the session authentication key with the master key")
+		}
+		sessionEncryptionKey, err := lib.DecryptText(settings.SessionEncryptionKey, masterKey)
+		if err != nil {
+			return errors.Wrap(err, "unable to unwrap the session encryption key with the master key")
+		}
+		settings.SessionAuthenticationKey = []byte(sessionAuthenticationKey)
+		settings.SessionEncryptionKey = []byte(sessionEncryptionKey)
+	}
+	return nil
+}
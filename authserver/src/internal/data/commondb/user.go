@@ -67,6 +67,178 @@ func (d *CommonDatabase) UpdateUser(tx *sql.Tx, user *entities.User) error {
 	return nil
 }
 
+// IncrementUserFailedLoginAttempts atomically increments the failed login counter of the user,
+// so concurrent failed attempts are not lost
+func (d *CommonDatabase) IncrementUserFailedLoginAttempts(tx *sql.Tx, userId int64) error {
+
+	if userId == 0 {
+		return errors.WithStack(errors.New("can't update user with id 0"))
+	}
+
+	now := time.Now().UTC()
+
+	updateBuilder := d.Flavor.NewUpdateBuilder()
+	updateBuilder.Update("users")
+	updateBuilder.Set(
+		updateBuilder.Incr("failed_login_attempts"),
+		updateBuilder.Assign("last_failed_login_at", now),
+		updateBuilder.Assign("updated_at", now),
+	)
+	updateBuilder.Where(updateBuilder.Equal("id", userId))
+
+	sql, args := updateBuilder.Build()
+	_, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "unable to increment user failed login attempts")
+	}
+
+	return nil
+}
+
+// SetUserOTPCode stores a new pending OTP code for the user and resets its attempts,
+// without touching the rest of the row
+func (d *CommonDatabase) SetUserOTPCode(tx *sql.Tx, userId int64, otpCodeEncrypted []byte, otpCodeIssuedAt sql.NullTime) error {
+
+	if userId == 0 {
+		return errors.WithStack(errors.New("can't update user with id 0"))
+	}
+
+	updateBuilder := d.Flavor.NewUpdateBuilder()
+	updateBuilder.Update("users")
+	updateBuilder.Set(
+		updateBuilder.Assign("otp_code_encrypted", otpCodeEncrypted),
+		updateBuilder.Assign("otp_code_issued_at", otpCodeIssuedAt),
+		updateBuilder.Assign("otp_code_attempts", 0),
+		updateBuilder.Assign("updated_at", time.Now().UTC()),
+	)
+	updateBuilder.Where(updateBuilder.Equal("id", userId))
+
+	sql, args := updateBuilder.Build()
+	_, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "unable to set user otp code")
+	}
+
+	return nil
+}
+
+// IncrementUserOTPCodeAttempts counts an attempt to verify the pending OTP code of the user, unless
+// the maximum was reached already. It returns false when no more attempts are allowed
+func (d *CommonDatabase) IncrementUserOTPCodeAttempts(tx *sql.Tx, userId int64, maxAttempts int) (bool, error) {
+
+	if userId == 0 {
+		return false, errors.WithStack(errors.New("can't update user with id 0"))
+	}
+
+	updateBuilder := d.Flavor.NewUpdateBuilder()
+	updateBuilder.Update("users")
+	updateBuilder.Set(
+		updateBuilder.Incr("otp_code_attempts"),
+		updateBuilder.Assign("updated_at", time.Now().UTC()),
+	)
+	updateBuilder.Where(
+		updateBuilder.Equal("id", userId),
+		updateBuilder.LessThan("otp_code_attempts", maxAttempts),
+	)
+
+	sql, args := updateBuilder.Build()
+	result, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to increment user otp code attempts")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "unable to get rows affected")
+	}
+	return rowsAffected == 1, nil
+}
+
+// ClearUserOTPCode removes the pending OTP code of the user. It returns false when there was
+// no pending code, for example because a concurrent request used it first
+func (d *CommonDatabase) ClearUserOTPCode(tx *sql.Tx, userId int64) (bool, error) {
+
+	if userId == 0 {
+		return false, errors.WithStack(errors.New("can't update user with id 0"))
+	}
+
+	updateBuilder := d.Flavor.NewUpdateBuilder()
+	updateBuilder.Update("users")
+	updateBuilder.Set(
+		updateBuilder.Assign("otp_code_encrypted", nil),
+		updateBuilder.Assign("otp_code_issued_at", nil),
+		updateBuilder.Assign("otp_code_attempts", 0),
+		updateBuilder.Assign("updated_at", time.Now().UTC()),
+	)
+	updateBuilder.Where(
+		updateBuilder.Equal("id", userId),
+		updateBuilder.IsNotNull("otp_code_issued_at"),
+	)
+
+	sql, args := updateBuilder.Build()
+	result, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to clear user otp code")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "unable to get rows affected")
+	}
+	return rowsAffected == 1, nil
+}
+
+// LockUser sets the lockout columns of the user, without touching the rest of the row
+func (d *CommonDatabase) LockUser(tx *sql.Tx, userId int64, lockedUntil sql.NullTime, lockedPermanently bool) error {
+
+	if userId == 0 {
+		return errors.WithStack(errors.New("can't update user with id 0"))
+	}
+
+	updateBuilder := d.Flavor.NewUpdateBuilder()
+	updateBuilder.Update("users")
+	updateBuilder.Set(
+		updateBuilder.Assign("locked_until", lockedUntil),
+		updateBuilder.Assign("locked_permanently", lockedPermanently),
+		updateBuilder.Assign("updated_at", time.Now().UTC()),
+	)
+	updateBuilder.Where(updateBuilder.Equal("id", userId))
+
+	sql, args := updateBuilder.Build()
+	_, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "unable to lock user")
+	}
+
+	return nil
+}
+
+// ResetUserFailedLoginAttempts clears the failed login counter and the temporary lockout of the user
+func (d *CommonDatabase) ResetUserFailedLoginAttempts(tx *sql.Tx, userId int64) error {
+
+	if userId == 0 {
+		return errors.WithStack(errors.New("can't update user with id 0"))
+	}
+
+	updateBuilder := d.Flavor.NewUpdateBuilder()
+	updateBuilder.Update("users")
+	updateBuilder.Set(
+		updateBuilder.Assign("failed_login_attempts", 0),
+		updateBuilder.Assign("last_failed_login_at", nil),
+		updateBuilder.Assign("locked_until", nil),
+		updateBuilder.Assign("updated_at", time.Now().UTC()),
+	)
+	updateBuilder.Where(updateBuilder.Equal("id", userId))
+
+	sql, args := updateBuilder.Build()
+	_, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "unable to reset user failed login attempts")
+	}
+
+	return nil
+}
+
 func (d *CommonDatabase) getUserCommon(tx *sql.Tx, selectBuilder *sqlbuilder.SelectBuilder,
 	userStruct *sqlbuilder.Struct) (*entities.User, error) {
 
@@ -0,0 +1,111 @@
+package commondb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/pkg/errors"
+)
+
+func (d *CommonDatabase) CreateUserRecoveryCode(tx *sql.Tx, userRecoveryCode *entities.UserRecoveryCode) error {
+
+	if userRecoveryCode.UserId == 0 {
+		return errors.WithStack(errors.New("user id must be greater than 0"))
+	}
+
+	originalCreatedAt := userRecoveryCode.CreatedAt
+	userRecoveryCode.CreatedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+
+	userRecoveryCodeStruct := sqlbuilder.NewStruct(new(entities.UserRecoveryCode)).
+		For(d.Flavor)
+
+	insertBuilder := userRecoveryCodeStruct.WithoutTag("pk").InsertInto("user_recovery_codes", userRecoveryCode)
+
+	sql, args := insertBuilder.Build()
+	result, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		userRecoveryCode.CreatedAt = originalCreatedAt
+		return errors.Wrap(err, "unable to insert userRecoveryCode")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		userRecoveryCode.CreatedAt = originalCreatedAt
+		return errors.Wrap(err, "unable to get last insert id")
+	}
+
+	userRecoveryCode.Id = id
+	return nil
+}
+
+func (d *CommonDatabase) GetUserRecoveryCodesByUserId(tx *sql.Tx, userId int64) ([]entities.UserRecoveryCode, error) {
+
+	userRecoveryCodeStruct := sqlbuilder.NewStruct(new(entities.UserRecoveryCode)).
+		For(d.Flavor)
+
+	selectBuilder := userRecoveryCodeStruct.SelectFrom("user_recovery_codes")
+	selectBuilder.Where(selectBuilder.Equal("user_id", userId))
+
+	sql, args := selectBuilder.Build()
+	rows, err := d.QuerySql(tx, sql, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to query database")
+	}
+	defer rows.Close()
+
+	var userRecoveryCodes []entities.UserRecoveryCode
+	for rows.Next() {
+		var userRecoveryCode entities.UserRecoveryCode
+		addr := userRecoveryCodeStruct.Addr(&userRecoveryCode)
+		err = rows.Scan(addr...)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to scan userRecoveryCode")
+		}
+		userRecoveryCodes = append(userRecoveryCodes, userRecoveryCode)
+	}
+
+	return userRecoveryCodes, nil
+}
+
+// DeleteUserRecoveryCode consumes a recovery code. It returns false when the code was already
+// deleted, for example by a concurrent request using the same code
+func (d *CommonDatabase) DeleteUserRecoveryCode(tx *sql.Tx, userRecoveryCodeId int64) (bool, error) {
+
+	userRecoveryCodeStruct := sqlbuilder.NewStruct(new(entities.UserRecoveryCode)).
+		For(d.Flavor)
+
+	deleteBuilder := userRecoveryCodeStruct.DeleteFrom("user_recovery_codes")
+	deleteBuilder.Where(deleteBuilder.Equal("id", userRecoveryCodeId))
+
+	sql, args := deleteBuilder.Build()
+	result, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to delete userRecoveryCode")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "unable to get rows affected")
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (d *CommonDatabase) DeleteUserRecoveryCodesByUserId(tx *sql.Tx, userId int64) error {
+
+	userRecoveryCodeStruct := sqlbuilder.NewStruct(new(entities.UserRecoveryCode)).
+		For(d.Flavor)
+
+	deleteBuilder := userRecoveryCodeStruct.DeleteFrom("user_recovery_codes")
+	deleteBuilder.Where(deleteBuilder.Equal("user_id", userId))
+
+	sql, args := deleteBuilder.Build()
+	_, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "unable to delete userRecoveryCodes")
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package commondb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/pkg/errors"
+)
+
+func (d *CommonDatabase) CreateWebAuthnCredential(tx *sql.Tx, webAuthnCredential *entities.WebAuthnCredential) error {
+
+	if webAuthnCredential.UserId == 0 {
+		return errors.WithStack(errors.New("user id must be greater than 0"))
+	}
+
+	now := time.Now().UTC()
+
+	originalCreatedAt := webAuthnCredential.CreatedAt
+	originalUpdatedAt := webAuthnCredential.UpdatedAt
+	webAuthnCredential.CreatedAt = sql.NullTime{Time: now, Valid: true}
+	webAuthnCredential.UpdatedAt = sql.NullTime{Time: now, Valid: true}
+
+	webAuthnCredentialStruct := sqlbuilder.NewStruct(new(entities.WebAuthnCredential)).
+		For(d.Flavor)
+
+	insertBuilder := webAuthnCredentialStruct.WithoutTag("pk").InsertInto("webauthn_credentials", webAuthnCredential)
+
+	sql, args := insertBuilder.Build()
+	result, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		webAuthnCredential.CreatedAt = originalCreatedAt
+		webAuthnCredential.UpdatedAt = originalUpdatedAt
+		return errors.Wrap(err, "unable to insert webAuthnCredential")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		webAuthnCredential.CreatedAt = originalCreatedAt
+		webAuthnCredential.UpdatedAt = originalUpdatedAt
+		return errors.Wrap(err, "unable to get last insert id")
+	}
+
+	webAuthnCredential.Id = id
+	return nil
+}
+
+func (d *CommonDatabase) UpdateWebAuthnCredential(tx *sql.Tx, webAuthnCredential *entities.WebAuthnCredential) error {
+
+	if webAuthnCredential.Id == 0 {
+		return errors.WithStack(errors.New("can't update webAuthnCredential with id 0"))
+	}
+
+	originalUpdatedAt := webAuthnCredential.UpdatedAt
+	webAuthnCredential.UpdatedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+
+	webAuthnCredentialStruct := sqlbuilder.NewStruct(new(entities.WebAuthnCredential)).
+		For(d.Flavor)
+
+	updateBuilder := webAuthnCredentialStruct.WithoutTag("pk").Update("webauthn_credentials", webAuthnCredential)
+	updateBuilder.Where(updateBuilder.Equal("id", webAuthnCredential.Id))
+
+	sql, args := updateBuilder.Build()
+	_, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		webAuthnCredential.UpdatedAt = originalUpdatedAt
+		return errors.Wrap(err, "unable to update webAuthnCredential")
+	}
+
+	return nil
+}
+
+func (d *CommonDatabase) getWebAuthnCredentialCommon(tx *sql.Tx, selectBuilder *sqlbuilder.SelectBuilder,
+	webAuthnCredentialStruct *sqlbuilder.Struct) (*entities.WebAuthnCredential, error) {
+
+	sql, args := selectBuilder.Build()
+	rows, err := d.QuerySql(tx, sql, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to query database")
+	}
+	defer rows.Close()
+
+	var webAuthnCredential entities.WebAuthnCredential
+	if rows.Next() {
+		addr := webAuthnCredentialStruct.Addr(&webAuthnCredential)
+		err = rows.Scan(addr...)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to scan webAuthnCredential")
+		}
+		return &webAuthnCredential, nil
+	}
+	return nil, nil
+}
+
+func (d *CommonDatabase) GetWebAuthnCredentialById(tx *sql.Tx, webAuthnCredentialId int64) (*entities.WebAuthnCredential, error) {
+
+	webAuthnCredentialStruct := sqlbuilder.NewStruct(new(entities.WebAuthnCredential)).
+		For(d.Flavor)
+
+	selectBuilder := webAuthnCredentialStruct.SelectFrom("webauthn_credentials")
+	selectBuilder.Where(selectBuilder.Equal("id", webAuthnCredentialId))
+
+	return d.getWebAuthnCredentialCommon(tx, selectBuilder, webAuthnCredentialStruct)
+}
+
+func (d *CommonDatabase) GetWebAuthnCredentialByCredentialId(tx *sql.Tx, credentialId string) (*entities.WebAuthnCredential, error) {
+
+	webAuthnCredentialStruct := sqlbuilder.NewStruct(new(entities.WebAuthnCredential)).
+		For(d.Flavor)
+
+	selectBuilder := webAuthnCredentialStruct.SelectFrom("webauthn_credentials")
+	selectBuilder.Where(selectBuilder.Equal("credential_id", credentialId))
+
+	return d.getWebAuthnCredentialCommon(tx, selectBuilder, webAuthnCredentialStruct)
+}
+
+func (d *CommonDatabase) GetWebAuthnCredentialsByUserId(tx *sql.Tx, userId int64) ([]entities.WebAuthnCredential, error) {
+
+	webAuthnCredentialStruct := sqlbuilder.NewStruct(new(entities.WebAuthnCredential)).
+		For(d.Flavor)
+
+	selectBuilder := webAuthnCredentialStruct.SelectFrom("webauthn_credentials")
+	selectBuilder.Where(selectBuilder.Equal("user_id", userId))
+	selectBuilder.OrderBy("id").Asc()
+
+	sql, args := selectBuilder.Build()
+	rows, err := d.QuerySql(tx, sql, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to query database")
+	}
+	defer rows.Close()
+
+	var webAuthnCredentials []entities.WebAuthnCredential
+	for rows.Next() {
+		var webAuthnCredential entities.WebAuthnCredential
+		addr := webAuthnCredentialStruct.Addr(&webAuthnCredential)
+		err = rows.Scan(addr...)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to scan webAuthnCredential")
+		}
+		webAuthnCredentials = append(webAuthnCredentials, webAuthnCredential)
+	}
+
+	return webAuthnCredentials, nil
+}
+
+func (d *CommonDatabase) DeleteWebAuthnCredential(tx *sql.Tx, webAuthnCredentialId int64) error {
+
+	webAuthnCredentialStruct := sqlbuilder.NewStruct(new(entities.WebAuthnCredential)).
+		For(d.Flavor)
+
+	deleteBuilder := webAuthnCredentialStruct.DeleteFrom("webauthn_credentials")
+	deleteBuilder.Where(deleteBuilder.Equal("id", webAuthnCredentialId))
+
+	sql, args := deleteBuilder.Build()
+	_, err := d.ExecSql(tx, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "unable to delete webAuthnCredential")
+	}
+
+	return nil
+}
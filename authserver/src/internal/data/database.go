@@ -3,6 +3,7 @@ package data
 import (
 	"database/sql"
 	"log/slog"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -26,11 +27,18 @@ type Database interface {
 	GetAllClients(tx *sql.Tx) ([]*entities.Client, error)
 	DeleteClient(tx *sql.Tx, clientId int64) error
 	ClientLoadRedirectURIs(tx *sql.Tx, client *entities.Client) error
+	ClientLoadPostLogoutRedirectURIs(tx *sql.Tx, client *entities.Client) error
 	ClientLoadWebOrigins(tx *sql.Tx, client *entities.Client) error
 	ClientLoadPermissions(tx *sql.Tx, client *entities.Client) error
 
 	CreateUser(tx *sql.Tx, user *entities.User) error
 	UpdateUser(tx *sql.Tx, user *entities.User) error
+	IncrementUserFailedLoginAttempts(tx *sql.Tx, userId int64) error
+	SetUserOTPCode(tx *sql.Tx, userId int64, otpCodeEncrypted []byte, otpCodeIssuedAt sql.NullTime) error
+	IncrementUserOTPCodeAttempts(tx *sql.Tx, userId int64, maxAttempts int) (bool, error)
+	ClearUserOTPCode(tx *sql.Tx, userId int64) (bool, error)
+	LockUser(tx *sql.Tx, userId int64, lockedUntil sql.NullTime, lockedPermanently bool) error
+	ResetUserFailedLoginAttempts(tx *sql.Tx, userId int64) error
 	GetUserById(tx *sql.Tx, userId int64) (*entities.User, error)
 	GetUsersByIds(tx *sql.Tx, userIds []int64) (map[int64]entities.User, error)
 	GetUserByUsername(tx *sql.Tx, username string) (*entities.User, error)
@@ -72,6 +80,7 @@ type Database interface {
 
 	CreateKeyPair(tx *sql.Tx, keyPair *entities.KeyPair) error
 	UpdateKeyPair(tx *sql.Tx, keyPair *entities.KeyPair) error
+	UpdateKeyPairIfState(tx *sql.Tx, keyPair *entities.KeyPair, state string) (bool, error)
 	GetKeyPairById(tx *sql.Tx, keyPairId int64) (*entities.KeyPair, error)
 	GetAllSigningKeys(tx *sql.Tx) ([]entities.KeyPair, error)
 	GetCurrentSigningKey(tx *sql.Tx) (*entities.KeyPair, error)
@@ -82,6 +91,10 @@ type Database interface {
 	GetRedirectURIsByClientId(tx *sql.Tx, clientId int64) ([]entities.RedirectURI, error)
 	DeleteRedirectURI(tx *sql.Tx, redirectURIId int64) error
 
+	CreatePostLogoutRedirectURI(tx *sql.Tx, postLogoutRedirectURI *entities.PostLogoutRedirectURI) error
+	GetPostLogoutRedirectURIsByClientId(tx *sql.Tx, clientId int64) ([]entities.PostLogoutRedirectURI, error)
+	DeletePostLogoutRedirectURI(tx *sql.Tx, postLogoutRedirectURIId int64) error
+
 	CreateWebOrigin(tx *sql.Tx, webOrigin *entities.WebOrigin) error
 	GetWebOriginById(tx *sql.Tx, webOriginId int64) (*entities.WebOrigin, error)
 	GetAllWebOrigins(tx *sql.Tx) ([]*entities.WebOrigin, error)
@@ -180,8 +193,10 @@ type Database interface {
 
 	CreateRefreshToken(tx *sql.Tx, refreshToken *entities.RefreshToken) error
 	UpdateRefreshToken(tx *sql.Tx, refreshToken *entities.RefreshToken) error
+	RedeemRefreshToken(tx *sql.Tx, refreshTokenId int64, markAsUsed bool) (bool, error)
 	GetRefreshTokenById(tx *sql.Tx, refreshTokenId int64) (*entities.RefreshToken, error)
 	GetRefreshTokenByJti(tx *sql.Tx, jti string) (*entities.RefreshToken, error)
+	GetRefreshTokensByFirstRefreshTokenJti(tx *sql.Tx, firstRefreshTokenJti string) ([]entities.RefreshToken, error)
 	DeleteRefreshToken(tx *sql.Tx, refreshTokenId int64) error
 	RefreshTokenLoadCode(tx *sql.Tx, refreshToken *entities.RefreshToken) error
 
@@ -199,6 +214,64 @@ type Database interface {
 	GetHttpSessionById(tx *sql.Tx, httpSessionId int64) (*entities.HttpSession, error)
 	DeleteHttpSession(tx *sql.Tx, httpSessionId int64) error
 	DeleteHttpSessionExpired(tx *sql.Tx) error
+
+	CreateAuditEvent(tx *sql.Tx, auditEvent *entities.AuditEvent) error
+	SearchAuditEventsPaginated(tx *sql.Tx, event string, userId int64, clientId int64,
+		from time.Time, to time.Time, page int, pageSize int) ([]entities.AuditEvent, int, error)
+	GetAuditEventsAfterId(tx *sql.Tx, event string, userId int64, clientId int64,
+		from time.Time, to time.Time, afterId int64, limit int) ([]entities.AuditEvent, error)
+	GetAuditEventTypes(tx *sql.Tx) ([]string, error)
+	DeleteAuditEventsOlderThan(tx *sql.Tx, cutoff time.Time) error
+
+	CreateWebAuthnCredential(tx *sql.Tx, webAuthnCredential *entities.WebAuthnCredential) error
+	UpdateWebAuthnCredential(tx *sql.Tx, webAuthnCredential *entities.WebAuthnCredential) error
+	GetWebAuthnCredentialById(tx *sql.Tx, webAuthnCredentialId int64) (*entities.WebAuthnCredential, error)
+	GetWebAuthnCredentialByCredentialId(tx *sql.Tx, credentialId string) (*entities.WebAuthnCredential, error)
+	GetWebAuthnCredentialsByUserId(tx *sql.Tx, userId int64) ([]entities.WebAuthnCredential, error)
+	DeleteWebAuthnCredential(tx *sql.Tx, webAuthnCredentialId int64) error
+
+	CreateUserRecoveryCode(tx *sql.Tx, userRecoveryCode *entities.UserRecoveryCode) error
+	GetUserRecoveryCodesByUserId(tx *sql.Tx, userId int64) ([]entities.UserRecoveryCode, error)
+	DeleteUserRecoveryCode(tx *sql.Tx, userRecoveryCodeId int64) (bool, error)
+	DeleteUserRecoveryCodesByUserId(tx *sql.Tx, userId int64) error
+
+	CreateClientAssertionJti(tx *sql.Tx, clientAssertionJti *entities.ClientAssertionJti) error
+	GetClientAssertionJti(tx *sql.Tx, clientId int64, jti string) (*entities.ClientAssertionJti, error)
+	DeleteExpiredClientAssertionJtis(tx *sql.Tx) error
+
+	CreateRequestObjectJti(tx *sql.Tx, requestObjectJti *entities.RequestObjectJti) error
+	GetRequestObjectJti(tx *sql.Tx, clientId int64, jti string) (*entities.RequestObjectJti, error)
+	DeleteExpiredRequestObjectJtis(tx *sql.Tx) error
+
+	CreateDPoPProofJti(tx *sql.Tx, dpopProofJti *entities.DPoPProofJti) error
+	GetDPoPProofJti(tx *sql.Tx, jkt string, jti string) (*entities.DPoPProofJti, error)
+	DeleteExpiredDPoPProofJtis(tx *sql.Tx) error
+
+	CreatePushedAuthorizationRequest(tx *sql.Tx, pushedAuthorizationRequest *entities.PushedAuthorizationRequest) error
+	GetPushedAuthorizationRequestByRequestURIHash(tx *sql.Tx, requestURIHash string) (*entities.PushedAuthorizationRequest, error)
+	DeletePushedAuthorizationRequest(tx *sql.Tx, pushedAuthorizationRequestId int64) (bool, error)
+	DeleteExpiredPushedAuthorizationRequests(tx *sql.Tx) error
+
+	CreateDeviceCode(tx *sql.Tx, deviceCode *entities.DeviceCode) error
+	UpdateDeviceCode(tx *sql.Tx, deviceCode *entities.DeviceCode) error
+	UpdateDeviceCodePolling(tx *sql.Tx, deviceCodeId int64, lastPolledAt time.Time, intervalInSeconds int) (bool, error)
+	ApproveDeviceCode(tx *sql.Tx, deviceCodeId int64, codeId int64) (bool, error)
+	DenyDeviceCode(tx *sql.Tx, deviceCodeId int64) (bool, error)
+	GetDeviceCodeById(tx *sql.Tx, deviceCodeId int64) (*entities.DeviceCode, error)
+	GetDeviceCodeByDeviceCodeHash(tx *sql.Tx, deviceCodeHash string) (*entities.DeviceCode, error)
+	GetDeviceCodeByUserCodeHash(tx *sql.Tx, userCodeHash string) (*entities.DeviceCode, error)
+	DeleteExpiredDeviceCodes(tx *sql.Tx) error
+
+	CreateDeviceUserCodeAttempt(tx *sql.Tx, attempt *entities.DeviceUserCodeAttempt) error
+	CountDeviceUserCodeAttemptsSince(tx *sql.Tx, ipAddress string, since time.Time) (int, error)
+	DeleteDeviceUserCodeAttemptsOlderThan(tx *sql.Tx, cutoff time.Time) error
+
+	CreateBackchannelLogoutDelivery(tx *sql.Tx, delivery *entities.BackchannelLogoutDelivery) error
+	UpdateBackchannelLogoutDelivery(tx *sql.Tx, delivery *entities.BackchannelLogoutDelivery) error
+	ClaimBackchannelLogoutDelivery(tx *sql.Tx, deliveryId int64, now time.Time, claimedUntil time.Time) (bool, error)
+	GetDueBackchannelLogoutDeliveries(tx *sql.Tx, now time.Time, limit int) ([]entities.BackchannelLogoutDelivery, error)
+	GetBackchannelLogoutDeliveriesByClientId(tx *sql.Tx, clientId int64, limit int) ([]entities.BackchannelLogoutDelivery, error)
+	DeleteBackchannelLogoutDeliveriesOlderThan(tx *sql.Tx, cutoff time.Time) error
 }
 
 func NewDatabase() (Database, error) {
@@ -241,6 +314,11 @@ func NewDatabase() (Database, error) {
 		slog.Info("database does not need seeding")
 	}
 
+	err = protectSecretsAtRest(database)
+	if err != nil {
+		return nil, err
+	}
+
 	return database, nil
 }
 
@@ -0,0 +1,30 @@
+package mysqldb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/leodip/goiabada/internal/entities"
+)
+
+func (d *MySQLDatabase) CreateAuditEvent(tx *sql.Tx, auditEvent *entities.AuditEvent) error {
+	return d.CommonDB.CreateAuditEvent(tx, auditEvent)
+}
+
+func (d *MySQLDatabase) SearchAuditEventsPaginated(tx *sql.Tx, event string, userId int64, clientId int64,
+	from time.Time, to time.Time, page int, pageSize int) ([]entities.AuditEvent, int, error) {
+	return d.CommonDB.SearchAuditEventsPaginated(tx, event, userId, clientId, from, to, page, pageSize)
+}
+
+func (d *MySQLDatabase) GetAuditEventsAfterId(tx *sql.Tx, event string, userId int64, clientId int64,
+	from time.Time, to time.Time, afterId int64, limit int) ([]entities.AuditEvent, error) {
+	return d.CommonDB.GetAuditEventsAfterId(tx, event, userId, clientId, from, to, afterId, limit)
+}
+
+func (d *MySQLDatabase) GetAuditEventTypes(tx *sql.Tx) ([]string, error) {
+	return d.CommonDB.GetAuditEventTypes(tx)
+}
+
+func (d *MySQLDatabase) DeleteAuditEventsOlderThan(tx *sql.Tx, cutoff time.Time) error {
+	return d.CommonDB.DeleteAuditEventsOlderThan(tx, cutoff)
+}
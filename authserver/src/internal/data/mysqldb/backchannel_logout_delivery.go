@@ -0,0 +1,32 @@
+package mysqldb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/leodip/goiabada/internal/entities"
+)
+
+func (d *MySQLDatabase) CreateBackchannelLogoutDelivery(tx *sql.Tx, delivery *entities.BackchannelLogoutDelivery) error {
+	return d.CommonDB.CreateBackchannelLogoutDelivery(tx, delivery)
+}
+
+func (d *MySQLDatabase) UpdateBackchannelLogoutDelivery(tx *sql.Tx, delivery *entities.BackchannelLogoutDelivery) error {
+	return d.CommonDB.UpdateBackchannelLogoutDelivery(tx, delivery)
+}
+
+func (d *MySQLDatabase) ClaimBackchannelLogoutDelivery(tx *sql.Tx, deliveryId int64, now time.Time, claimedUntil time.Time) (bool, error) {
+	return d.CommonDB.ClaimBackchannelLogoutDelivery(tx, deliveryId, now, claimedUntil)
+}
+
+func (d *MySQLDatabase) GetDueBackchannelLogoutDeliveries(tx *sql.Tx, now time.Time, limit int) ([]entities.BackchannelLogoutDelivery, error) {
+	return d.CommonDB.GetDueBackchannelLogoutDeliveries(tx, now, limit)
+}
+
+func (d *MySQLDatabase) GetBackchannelLogoutDeliveriesByClientId(tx *sql.Tx, clientId int64, limit int) ([]entities.BackchannelLogoutDelivery, error) {
+	return d.CommonDB.GetBackchannelLogoutDeliveriesByClientId(tx, clientId, limit)
+}
+
+func (d *MySQLDatabase) DeleteBackchannelLogoutDeliveriesOlderThan(tx *sql.Tx, cutoff time.Time) error {
+	return d.CommonDB.DeleteBackchannelLogoutDeliveriesOlderThan(tx, cutoff)
+}
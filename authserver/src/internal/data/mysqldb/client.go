@@ -26,6 +26,10 @@ func (d *MySQLDatabase) ClientLoadRedirectURIs(tx *sql.Tx, client *entities.Clie
 	return d.CommonDB.ClientLoadRedirectURIs(tx, client)
 }
 
+func (d *MySQLDatabase) ClientLoadPostLogoutRedirectURIs(tx *sql.Tx, client *entities.Client) error {
+	return d.CommonDB.ClientLoadPostLogoutRedirectURIs(tx, client)
+}
+
 func (d *MySQLDatabase) ClientLoadWebOrigins(tx *sql.Tx, client *entities.Client) error {
 	return d.CommonDB.ClientLoadWebOrigins(tx, client)
 }
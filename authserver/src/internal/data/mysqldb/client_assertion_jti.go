@@ -0,0 +1,19 @@
+package mysqldb
+
+import (
+	"database/sql"
+
+	"github.com/leodip/goiabada/internal/entities"
+)
+
+func (d *MySQLDatabase) CreateClientAssertionJti(tx *sql.Tx, clientAssertionJti *entities.ClientAssertionJti) error {
+	return d.CommonDB.CreateClientAssertionJti(tx, clientAssertionJti)
+}
+
+func (d *MySQLDatabase) GetClientAssertionJti(tx *sql.Tx, clientId int64, jti string) (*entities.ClientAssertionJti, error) {
+	return d.CommonDB.GetClientAssertionJti(tx, clientId, jti)
+}
+
+func (d *MySQLDatabase) DeleteExpiredClientAssertionJtis(tx *sql.Tx) error {
+	return d.CommonDB.DeleteExpiredClientAssertionJtis(tx)
+}
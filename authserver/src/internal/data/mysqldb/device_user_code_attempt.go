@@ -0,0 +1,20 @@
+package mysqldb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/leodip/goiabada/internal/entities"
+)
+
+func (d *MySQLDatabase) CreateDeviceUserCodeAttempt(tx *sql.Tx, attempt *entities.DeviceUserCodeAttempt) error {
+	return d.CommonDB.CreateDeviceUserCodeAttempt(tx, attempt)
+}
+
+func (d *MySQLDatabase) CountDeviceUserCodeAttemptsSince(tx *sql.Tx, ipAddress string, since time.Time) (int, error) {
+	return d.CommonDB.CountDeviceUserCodeAttemptsSince(tx, ipAddress, since)
+}
+
+func (d *MySQLDatabase) DeleteDeviceUserCodeAttemptsOlderThan(tx *sql.Tx, cutoff time.Time) error {
+	return d.CommonDB.DeleteDeviceUserCodeAttemptsOlderThan(tx, cutoff)
+}
@@ -0,0 +1,19 @@
+package mysqldb
+
+import (
+	"database/sql"
+
+	"github.com/leodip/goiabada/internal/entities"
+)
+
+func (d *MySQLDatabase) CreateDPoPProofJti(tx *sql.Tx, dpopProofJti *entities.DPoPProofJti) error {
+	return d.CommonDB.CreateDPoPProofJti(tx, dpopProofJti)
+}
+
+func (d *MySQLDatabase) GetDPoPProofJti(tx *sql.Tx, jkt string, jti string) (*entities.DPoPProofJti, error) {
+	return d.CommonDB.GetDPoPProofJti(tx, jkt, jti)
+}
+
+func (d *MySQLDatabase) DeleteExpiredDPoPProofJtis(tx *sql.Tx) error {
+	return d.CommonDB.DeleteExpiredDPoPProofJtis(tx)
+}
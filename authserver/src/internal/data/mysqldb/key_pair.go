@@ -14,6 +14,10 @@ func (d *MySQLDatabase) UpdateKeyPair(tx *sql.Tx, keyPair *entities.KeyPair) err
 	return d.CommonDB.UpdateKeyPair(tx, keyPair)
 }
 
+func (d *MySQLDatabase) UpdateKeyPairIfState(tx *sql.Tx, keyPair *entities.KeyPair, state string) (bool, error) {
+	return d.CommonDB.UpdateKeyPairIfState(tx, keyPair, state)
+}
+
 func (d *MySQLDatabase) GetKeyPairById(tx *sql.Tx, keyPairId int64) (*entities.KeyPair, error) {
 	return d.CommonDB.GetKeyPairById(tx, keyPairId)
 }
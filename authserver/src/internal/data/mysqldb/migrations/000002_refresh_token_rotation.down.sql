@@ -0,0 +1,9 @@
+-- BEGIN
+
+ALTER TABLE `refresh_tokens` DROP COLUMN `used`;
+
+ALTER TABLE `settings` DROP COLUMN `refresh_token_rotation_enabled`;
+
+ALTER TABLE `clients` DROP COLUMN `refresh_token_rotation_enabled`;
+
+-- END
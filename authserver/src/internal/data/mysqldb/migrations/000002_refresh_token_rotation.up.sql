@@ -0,0 +1,9 @@
+-- BEGIN
+
+ALTER TABLE `clients` ADD COLUMN `refresh_token_rotation_enabled` varchar(16) NOT NULL DEFAULT 'default';
+
+ALTER TABLE `settings` ADD COLUMN `refresh_token_rotation_enabled` tinyint(1) NOT NULL DEFAULT 1;
+
+ALTER TABLE `refresh_tokens` ADD COLUMN `used` tinyint(1) NOT NULL DEFAULT 0;
+
+-- END
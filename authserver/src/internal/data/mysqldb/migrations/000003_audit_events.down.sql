@@ -0,0 +1,7 @@
+-- BEGIN
+
+ALTER TABLE `settings` DROP COLUMN `audit_log_retention_in_days`;
+
+DROP TABLE IF EXISTS `audit_events`;
+
+-- END
@@ -0,0 +1,23 @@
+-- BEGIN
+
+CREATE TABLE `audit_events` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(6) DEFAULT NULL,
+  `event` varchar(128) NOT NULL,
+  `actor` varchar(64) NOT NULL,
+  `user_id` bigint unsigned DEFAULT NULL,
+  `client_id` bigint unsigned DEFAULT NULL,
+  `ip_address` varchar(512) NOT NULL,
+  `request_id` varchar(128) NOT NULL,
+  `user_agent` varchar(512) NOT NULL,
+  `details` longtext,
+  PRIMARY KEY (`id`),
+  KEY `idx_audit_events_created_at` (`created_at`),
+  KEY `idx_audit_events_event` (`event`),
+  KEY `idx_audit_events_user_id` (`user_id`),
+  KEY `idx_audit_events_client_id` (`client_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+ALTER TABLE `settings` ADD COLUMN `audit_log_retention_in_days` int NOT NULL DEFAULT 90;
+
+-- END
@@ -0,0 +1,19 @@
+-- BEGIN
+
+ALTER TABLE `settings` DROP COLUMN `failed_login_delay_in_milliseconds`;
+
+ALTER TABLE `settings` DROP COLUMN `account_permanent_lockout_threshold`;
+
+ALTER TABLE `settings` DROP COLUMN `account_lockout_duration_in_seconds`;
+
+ALTER TABLE `settings` DROP COLUMN `account_lockout_threshold`;
+
+ALTER TABLE `users` DROP COLUMN `locked_permanently`;
+
+ALTER TABLE `users` DROP COLUMN `locked_until`;
+
+ALTER TABLE `users` DROP COLUMN `last_failed_login_at`;
+
+ALTER TABLE `users` DROP COLUMN `failed_login_attempts`;
+
+-- END
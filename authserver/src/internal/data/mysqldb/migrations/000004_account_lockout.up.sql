@@ -0,0 +1,19 @@
+-- BEGIN
+
+ALTER TABLE `users` ADD COLUMN `failed_login_attempts` int NOT NULL DEFAULT 0;
+
+ALTER TABLE `users` ADD COLUMN `last_failed_login_at` datetime(6) DEFAULT NULL;
+
+ALTER TABLE `users` ADD COLUMN `locked_until` datetime(6) DEFAULT NULL;
+
+ALTER TABLE `users` ADD COLUMN `locked_permanently` tinyint(1) NOT NULL DEFAULT 0;
+
+ALTER TABLE `settings` ADD COLUMN `account_lockout_threshold` int NOT NULL DEFAULT 5;
+
+ALTER TABLE `settings` ADD COLUMN `account_lockout_duration_in_seconds` int NOT NULL DEFAULT 900;
+
+ALTER TABLE `settings` ADD COLUMN `account_permanent_lockout_threshold` int NOT NULL DEFAULT 0;
+
+ALTER TABLE `settings` ADD COLUMN `failed_login_delay_in_milliseconds` int NOT NULL DEFAULT 500;
+
+-- END
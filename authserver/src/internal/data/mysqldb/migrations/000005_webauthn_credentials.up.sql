@@ -0,0 +1,21 @@
+-- BEGIN
+
+CREATE TABLE `webauthn_credentials` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(6) DEFAULT NULL,
+  `updated_at` datetime(6) DEFAULT NULL,
+  `user_id` bigint unsigned NOT NULL,
+  `name` varchar(64) NOT NULL,
+  `credential_id` varchar(512) NOT NULL,
+  `public_key` blob NOT NULL,
+  `sign_count` bigint unsigned NOT NULL DEFAULT 0,
+  `aaguid` varchar(64) NOT NULL,
+  `transports` varchar(128) NOT NULL,
+  `last_used_at` datetime(6) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_webauthn_credentials_credential_id` (`credential_id`),
+  KEY `fk_webauthn_credentials_user` (`user_id`),
+  CONSTRAINT `fk_webauthn_credentials_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- END
@@ -0,0 +1,13 @@
+-- BEGIN
+
+CREATE TABLE `user_recovery_codes` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(6) DEFAULT NULL,
+  `user_id` bigint unsigned NOT NULL,
+  `code_hash` varchar(64) NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `fk_user_recovery_codes_user` (`user_id`),
+  CONSTRAINT `fk_user_recovery_codes_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- END
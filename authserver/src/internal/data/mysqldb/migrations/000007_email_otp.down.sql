@@ -0,0 +1,9 @@
+-- BEGIN
+
+ALTER TABLE `users` DROP COLUMN `otp_code_issued_at`;
+
+ALTER TABLE `users` DROP COLUMN `otp_code_encrypted`;
+
+ALTER TABLE `users` DROP COLUMN `otp_method`;
+
+-- END
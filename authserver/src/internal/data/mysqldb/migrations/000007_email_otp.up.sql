@@ -0,0 +1,9 @@
+-- BEGIN
+
+ALTER TABLE `users` ADD COLUMN `otp_method` varchar(16) NOT NULL DEFAULT 'totp';
+
+ALTER TABLE `users` ADD COLUMN `otp_code_encrypted` longblob;
+
+ALTER TABLE `users` ADD COLUMN `otp_code_issued_at` datetime(6) DEFAULT NULL;
+
+-- END
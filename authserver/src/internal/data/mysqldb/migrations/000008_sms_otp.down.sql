@@ -0,0 +1,7 @@
+-- BEGIN
+
+ALTER TABLE `clients` DROP COLUMN `sms_otp_allowed_acr_level3`;
+
+ALTER TABLE `clients` DROP COLUMN `sms_otp_allowed_acr_level2`;
+
+-- END
@@ -0,0 +1,7 @@
+-- BEGIN
+
+ALTER TABLE `clients` ADD COLUMN `sms_otp_allowed_acr_level2` tinyint(1) NOT NULL DEFAULT 0;
+
+ALTER TABLE `clients` ADD COLUMN `sms_otp_allowed_acr_level3` tinyint(1) NOT NULL DEFAULT 0;
+
+-- END
@@ -0,0 +1,5 @@
+-- BEGIN
+
+ALTER TABLE `clients` ADD COLUMN `id_token_signed_response_alg` varchar(10) NOT NULL DEFAULT '';
+
+-- END
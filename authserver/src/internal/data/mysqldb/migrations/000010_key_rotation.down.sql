@@ -0,0 +1,11 @@
+-- BEGIN
+
+ALTER TABLE `key_pairs` DROP COLUMN `activated_at`;
+
+ALTER TABLE `key_pairs` DROP COLUMN `deactivated_at`;
+
+ALTER TABLE `settings` DROP COLUMN `key_rotation_interval_in_days`;
+
+ALTER TABLE `settings` DROP COLUMN `key_rotation_overlap_in_days`;
+
+-- END
@@ -0,0 +1,11 @@
+-- BEGIN
+
+ALTER TABLE `key_pairs` ADD COLUMN `activated_at` datetime(6) DEFAULT NULL;
+
+ALTER TABLE `key_pairs` ADD COLUMN `deactivated_at` datetime(6) DEFAULT NULL;
+
+ALTER TABLE `settings` ADD COLUMN `key_rotation_interval_in_days` int NOT NULL DEFAULT 0;
+
+ALTER TABLE `settings` ADD COLUMN `key_rotation_overlap_in_days` int NOT NULL DEFAULT 7;
+
+-- END
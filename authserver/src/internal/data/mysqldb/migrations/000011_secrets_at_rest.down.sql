@@ -0,0 +1,7 @@
+-- BEGIN
+
+ALTER TABLE `key_pairs` RENAME COLUMN `private_key_pem_encrypted` TO `private_key_pem`;
+
+ALTER TABLE `settings` DROP COLUMN `aes_encryption_key_wrapped`;
+
+-- END
@@ -0,0 +1,7 @@
+-- BEGIN
+
+ALTER TABLE `key_pairs` RENAME COLUMN `private_key_pem` TO `private_key_pem_encrypted`;
+
+ALTER TABLE `settings` ADD COLUMN `aes_encryption_key_wrapped` tinyint(1) NOT NULL DEFAULT 0;
+
+-- END
@@ -0,0 +1,21 @@
+-- BEGIN
+
+ALTER TABLE `clients` ADD COLUMN `token_endpoint_auth_method` varchar(32) NOT NULL DEFAULT 'client_secret_basic';
+
+ALTER TABLE `clients` ADD COLUMN `jwks` text NOT NULL;
+
+ALTER TABLE `clients` ADD COLUMN `jwks_uri` varchar(512) NOT NULL DEFAULT '';
+
+CREATE TABLE `client_assertion_jtis` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(6) DEFAULT NULL,
+  `client_id` bigint unsigned NOT NULL,
+  `jti` varchar(255) NOT NULL,
+  `expires_at` datetime(6) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_client_assertion_jtis_client_id_jti` (`client_id`, `jti`),
+  KEY `idx_client_assertion_jtis_expires_at` (`expires_at`),
+  CONSTRAINT `fk_client_assertion_jtis_client` FOREIGN KEY (`client_id`) REFERENCES `clients` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- END
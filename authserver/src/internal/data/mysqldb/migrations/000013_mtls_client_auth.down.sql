@@ -0,0 +1,5 @@
+-- BEGIN
+
+ALTER TABLE `clients` DROP COLUMN `tls_client_auth_subject_dn`;
+
+-- END
@@ -0,0 +1,5 @@
+-- BEGIN
+
+ALTER TABLE `clients` ADD COLUMN `tls_client_auth_subject_dn` varchar(512) NOT NULL DEFAULT '';
+
+-- END
@@ -0,0 +1,18 @@
+-- BEGIN
+
+ALTER TABLE `clients` ADD COLUMN `dpop_mode` varchar(16) NOT NULL DEFAULT 'disabled';
+
+ALTER TABLE `clients` ADD COLUMN `dpop_nonce_required` tinyint(1) NOT NULL DEFAULT 0;
+
+CREATE TABLE `dpop_proof_jtis` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(6) DEFAULT NULL,
+  `jkt` varchar(64) NOT NULL,
+  `jti` varchar(255) NOT NULL,
+  `expires_at` datetime(6) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_dpop_proof_jtis_jkt_jti` (`jkt`, `jti`),
+  KEY `idx_dpop_proof_jtis_expires_at` (`expires_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- END
@@ -0,0 +1,18 @@
+-- BEGIN
+
+ALTER TABLE `clients` ADD COLUMN `require_pushed_authorization_requests` tinyint(1) NOT NULL DEFAULT 0;
+
+CREATE TABLE `pushed_authorization_requests` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(6) DEFAULT NULL,
+  `client_id` bigint unsigned NOT NULL,
+  `request_uri_hash` varchar(64) NOT NULL,
+  `parameters` text NOT NULL,
+  `expires_at` datetime(6) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_pushed_authorization_requests_request_uri_hash` (`request_uri_hash`),
+  KEY `idx_pushed_authorization_requests_expires_at` (`expires_at`),
+  CONSTRAINT `fk_pushed_authorization_requests_client` FOREIGN KEY (`client_id`) REFERENCES `clients` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- END
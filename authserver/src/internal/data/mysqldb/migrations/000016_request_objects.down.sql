@@ -0,0 +1,5 @@
+-- BEGIN
+
+ALTER TABLE `clients` DROP COLUMN `require_signed_request_object`;
+
+-- END
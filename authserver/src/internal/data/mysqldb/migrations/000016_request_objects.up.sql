@@ -0,0 +1,5 @@
+-- BEGIN
+
+ALTER TABLE `clients` ADD COLUMN `require_signed_request_object` tinyint(1) NOT NULL DEFAULT 0;
+
+-- END
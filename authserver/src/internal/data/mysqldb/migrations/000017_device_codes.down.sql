@@ -0,0 +1,7 @@
+-- BEGIN
+
+DROP TABLE IF EXISTS `device_codes`;
+
+ALTER TABLE `clients` DROP COLUMN `device_code_enabled`;
+
+-- END
@@ -0,0 +1,26 @@
+-- BEGIN
+
+ALTER TABLE `clients` ADD COLUMN `device_code_enabled` tinyint(1) NOT NULL DEFAULT 0;
+
+CREATE TABLE `device_codes` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(6) DEFAULT NULL,
+  `updated_at` datetime(6) DEFAULT NULL,
+  `client_id` bigint unsigned NOT NULL,
+  `device_code_hash` varchar(64) NOT NULL,
+  `user_code_hash` varchar(64) NOT NULL,
+  `scope` varchar(512) NOT NULL,
+  `status` varchar(16) NOT NULL,
+  `code_id` bigint unsigned DEFAULT NULL,
+  `interval_in_seconds` int NOT NULL,
+  `last_polled_at` datetime(6) DEFAULT NULL,
+  `expires_at` datetime(6) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_device_codes_device_code_hash` (`device_code_hash`),
+  UNIQUE KEY `idx_device_codes_user_code_hash` (`user_code_hash`),
+  KEY `idx_device_codes_expires_at` (`expires_at`),
+  CONSTRAINT `fk_device_codes_client` FOREIGN KEY (`client_id`) REFERENCES `clients` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `fk_device_codes_code` FOREIGN KEY (`code_id`) REFERENCES `codes` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- END
@@ -0,0 +1,7 @@
+-- BEGIN
+
+DROP TABLE IF EXISTS `post_logout_redirect_uris`;
+
+ALTER TABLE `clients` DROP COLUMN `frontchannel_logout_uri`;
+
+-- END
@@ -0,0 +1,18 @@
+-- BEGIN
+
+ALTER TABLE `clients` ADD COLUMN `frontchannel_logout_uri` varchar(512) NOT NULL DEFAULT '';
+
+CREATE TABLE `post_logout_redirect_uris` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(6) DEFAULT NULL,
+  `uri` varchar(256) NOT NULL,
+  `client_id` bigint unsigned NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `fk_clients_post_logout_redirect_uris` (`client_id`),
+  CONSTRAINT `fk_clients_post_logout_redirect_uris` FOREIGN KEY (`client_id`) REFERENCES `clients` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+INSERT INTO `post_logout_redirect_uris` (`created_at`, `uri`, `client_id`)
+  SELECT `created_at`, `uri`, `client_id` FROM `redirect_uris`;
+
+-- END
@@ -0,0 +1,9 @@
+-- BEGIN
+
+ALTER TABLE `settings` DROP COLUMN `client_registration_initial_access_token_encrypted`;
+
+ALTER TABLE `settings` DROP COLUMN `client_registration_mode`;
+
+ALTER TABLE `clients` DROP COLUMN `registration_access_token_hash`;
+
+-- END
@@ -0,0 +1,9 @@
+-- BEGIN
+
+ALTER TABLE `clients` ADD COLUMN `registration_access_token_hash` varchar(64) NOT NULL DEFAULT '';
+
+ALTER TABLE `settings` ADD COLUMN `client_registration_mode` varchar(32) NOT NULL DEFAULT 'disabled';
+
+ALTER TABLE `settings` ADD COLUMN `client_registration_initial_access_token_encrypted` longblob;
+
+-- END
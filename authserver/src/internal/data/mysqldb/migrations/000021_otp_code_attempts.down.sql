@@ -0,0 +1,5 @@
+-- BEGIN
+
+ALTER TABLE `users` DROP COLUMN `otp_code_attempts`;
+
+-- END
@@ -0,0 +1,5 @@
+-- BEGIN
+
+ALTER TABLE `users` ADD COLUMN `otp_code_attempts` int NOT NULL DEFAULT 0;
+
+-- END
@@ -0,0 +1,5 @@
+-- BEGIN
+
+DROP TABLE IF EXISTS `request_object_jtis`;
+
+-- END
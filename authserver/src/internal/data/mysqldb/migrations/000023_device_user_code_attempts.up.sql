@@ -0,0 +1,11 @@
+-- BEGIN
+
+CREATE TABLE `device_user_code_attempts` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(6) NOT NULL,
+  `ip_address` varchar(64) NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_device_user_code_attempts_ip_address_created_at` (`ip_address`, `created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- END
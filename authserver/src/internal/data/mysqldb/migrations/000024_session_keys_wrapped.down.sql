@@ -0,0 +1,5 @@
+-- BEGIN
+
+ALTER TABLE `settings` DROP COLUMN `session_keys_wrapped`;
+
+-- END
@@ -0,0 +1,5 @@
+-- BEGIN
+
+ALTER TABLE `settings` ADD COLUMN `session_keys_wrapped` tinyint(1) NOT NULL DEFAULT 0;
+
+-- END
@@ -0,0 +1,19 @@
+package mysqldb
+
+import (
+	"database/sql"
+
+	"github.com/leodip/goiabada/internal/entities"
+)
+
+func (d *MySQLDatabase) CreatePostLogoutRedirectURI(tx *sql.Tx, postLogoutRedirectURI *entities.PostLogoutRedirectURI) error {
+	return d.CommonDB.CreatePostLogoutRedirectURI(tx, postLogoutRedirectURI)
+}
+
+func (d *MySQLDatabase) GetPostLogoutRedirectURIsByClientId(tx *sql.Tx, clientId int64) ([]entities.PostLogoutRedirectURI, error) {
+	return d.CommonDB.GetPostLogoutRedirectURIsByClientId(tx, clientId)
+}
+
+func (d *MySQLDatabase) DeletePostLogoutRedirectURI(tx *sql.Tx, postLogoutRedirectURIId int64) error {
+	return d.CommonDB.DeletePostLogoutRedirectURI(tx, postLogoutRedirectURIId)
+}
@@ -0,0 +1,23 @@
+package mysqldb
+
+import (
+	"database/sql"
+
+	"github.com/leodip/goiabada/internal/entities"
+)
+
+func (d *MySQLDatabase) CreatePushedAuthorizationRequest(tx *sql.Tx, pushedAuthorizationRequest *entities.PushedAuthorizationRequest) error {
+	return d.CommonDB.CreatePushedAuthorizationRequest(tx, pushedAuthorizationRequest)
+}
+
+func (d *MySQLDatabase) GetPushedAuthorizationRequestByRequestURIHash(tx *sql.Tx, requestURIHash string) (*entities.PushedAuthorizationRequest, error) {
+	return d.CommonDB.GetPushedAuthorizationRequestByRequestURIHash(tx, requestURIHash)
+}
+
+func (d *MySQLDatabase) DeletePushedAuthorizationRequest(tx *sql.Tx, pushedAuthorizationRequestId int64) (bool, error) {
+	return d.CommonDB.DeletePushedAuthorizationRequest(tx, pushedAuthorizationRequestId)
+}
+
+func (d *MySQLDatabase) DeleteExpiredPushedAuthorizationRequests(tx *sql.Tx) error {
+	return d.CommonDB.DeleteExpiredPushedAuthorizationRequests(tx)
+}
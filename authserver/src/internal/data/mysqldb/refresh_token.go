@@ -14,6 +14,10 @@ func (d *MySQLDatabase) UpdateRefreshToken(tx *sql.Tx, refreshToken *entities.Re
 	return d.CommonDB.UpdateRefreshToken(tx, refreshToken)
 }
 
+func (d *MySQLDatabase) RedeemRefreshToken(tx *sql.Tx, refreshTokenId int64, markAsUsed bool) (bool, error) {
+	return d.CommonDB.RedeemRefreshToken(tx, refreshTokenId, markAsUsed)
+}
+
 func (d *MySQLDatabase) GetRefreshTokenById(tx *sql.Tx, refreshTokenId int64) (*entities.RefreshToken, error) {
 	return d.CommonDB.GetRefreshTokenById(tx, refreshTokenId)
 }
@@ -26,6 +30,10 @@ func (d *MySQLDatabase) GetRefreshTokenByJti(tx *sql.Tx, jti string) (*entities.
 	return d.CommonDB.GetRefreshTokenByJti(tx, jti)
 }
 
+func (d *MySQLDatabase) GetRefreshTokensByFirstRefreshTokenJti(tx *sql.Tx, firstRefreshTokenJti string) ([]entities.RefreshToken, error) {
+	return d.CommonDB.GetRefreshTokensByFirstRefreshTokenJti(tx, firstRefreshTokenJti)
+}
+
 func (d *MySQLDatabase) DeleteRefreshToken(tx *sql.Tx, refreshTokenId int64) error {
 	return d.CommonDB.DeleteRefreshToken(tx, refreshTokenId)
 }
@@ -0,0 +1,19 @@
+package mysqldb
+
+import (
+	"database/sql"
+
+	"github.com/leodip/goiabada/internal/entities"
+)
+
+func (d *MySQLDatabase) CreateRequestObjectJti(tx *sql.Tx, requestObjectJti *entities.RequestObjectJti) error {
+	return d.CommonDB.CreateRequestObjectJti(tx, requestObjectJti)
+}
+
+func (d *MySQLDatabase) GetRequestObjectJti(tx *sql.Tx, clientId int64, jti string) (*entities.RequestObjectJti, error) {
+	return d.CommonDB.GetRequestObjectJti(tx, clientId, jti)
+}
+
+func (d *MySQLDatabase) DeleteExpiredRequestObjectJtis(tx *sql.Tx) error {
+	return d.CommonDB.DeleteExpiredRequestObjectJtis(tx)
+}
@@ -14,6 +14,30 @@ func (d *MySQLDatabase) UpdateUser(tx *sql.Tx, user *entities.User) error {
 	return d.CommonDB.UpdateUser(tx, user)
 }
 
+func (d *MySQLDatabase) IncrementUserFailedLoginAttempts(tx *sql.Tx, userId int64) error {
+	return d.CommonDB.IncrementUserFailedLoginAttempts(tx, userId)
+}
+
+func (d *MySQLDatabase) SetUserOTPCode(tx *sql.Tx, userId int64, otpCodeEncrypted []byte, otpCodeIssuedAt sql.NullTime) error {
+	return d.CommonDB.SetUserOTPCode(tx, userId, otpCodeEncrypted, otpCodeIssuedAt)
+}
+
+func (d *MySQLDatabase) IncrementUserOTPCodeAttempts(tx *sql.Tx, userId int64, maxAttempts int) (bool, error) {
+	return d.CommonDB.IncrementUserOTPCodeAttempts(tx, userId, maxAttempts)
+}
+
+func (d *MySQLDatabase) ClearUserOTPCode(tx *sql.Tx, userId int64) (bool, error) {
+	return d.CommonDB.ClearUserOTPCode(tx, userId)
+}
+
+func (d *MySQLDatabase) LockUser(tx *sql.Tx, userId int64, lockedUntil sql.NullTime, lockedPermanently bool) error {
+	return d.CommonDB.LockUser(tx, userId, lockedUntil, lockedPermanently)
+}
+
+func (d *MySQLDatabase) ResetUserFailedLoginAttempts(tx *sql.Tx, userId int64) error {
+	return d.CommonDB.ResetUserFailedLoginAttempts(tx, userId)
+}
+
 func (d *MySQLDatabase) GetUsersByIds(tx *sql.Tx, userIds []int64) (map[int64]entities.User, error) {
 	return d.CommonDB.GetUsersByIds(tx, userIds)
 }
@@ -0,0 +1,23 @@
+package mysqldb
+
+import (
+	"database/sql"
+
+	"github.com/leodip/goiabada/internal/entities"
+)
+
+func (d *MySQLDatabase) CreateUserRecoveryCode(tx *sql.Tx, userRecoveryCode *entities.UserRecoveryCode) error {
+	return d.CommonDB.CreateUserRecoveryCode(tx, userRecoveryCode)
+}
+
+func (d *MySQLDatabase) GetUserRecoveryCodesByUserId(tx *sql.Tx, userId int64) ([]entities.UserRecoveryCode, error) {
+	return d.CommonDB.GetUserRecoveryCodesByUserId(tx, userId)
+}
+
+func (d *MySQLDatabase) DeleteUserRecoveryCode(tx *sql.Tx, userRecoveryCodeId int64) (bool, error) {
+	return d.CommonDB.DeleteUserRecoveryCode(tx, userRecoveryCodeId)
+}
+
+func (d *MySQLDatabase) DeleteUserRecoveryCodesByUserId(tx *sql.Tx, userId int64) error {
+	return d.CommonDB.DeleteUserRecoveryCodesByUserId(tx, userId)
+}
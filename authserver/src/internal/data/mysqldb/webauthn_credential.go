@@ -0,0 +1,31 @@
+package mysqldb
+
+import (
+	"database/sql"
+
+	"github.com/leodip/goiabada/internal/entities"
+)
+
+func (d *MySQLDatabase) CreateWebAuthnCredential(tx *sql.Tx, webAuthnCredential *entities.WebAuthnCredential) error {
+	return d.CommonDB.CreateWebAuthnCredential(tx, webAuthnCredential)
+}
+
+func (d *MySQLDatabase) UpdateWebAuthnCredential(tx *sql.Tx, webAuthnCredential *entities.WebAuthnCredential) error {
+	return d.CommonDB.UpdateWebAuthnCredential(tx, webAuthnCredential)
+}
+
+func (d *MySQLDatabase) GetWebAuthnCredentialById(tx *sql.Tx, webAuthnCredentialId int64) (*entities.WebAuthnCredential, error) {
+	return d.CommonDB.GetWebAuthnCredentialById(tx, webAuthnCredentialId)
+}
+
+func (d *MySQLDatabase) GetWebAuthnCredentialByCredentialId(tx *sql.Tx, credentialId string) (*entities.WebAuthnCredential, error) {
+	return d.CommonDB.GetWebAuthnCredentialByCredentialId(tx, credentialId)
+}
+
+func (d *MySQLDatabase) GetWebAuthnCredentialsByUserId(tx *sql.Tx, userId int64) ([]entities.WebAuthnCredential, error) {
+	return d.CommonDB.GetWebAuthnCredentialsByUserId(tx, userId)
+}
+
+func (d *MySQLDatabase) DeleteWebAuthnCredential(tx *sql.Tx, webAuthnCredentialId int64) error {
+	return d.CommonDB.DeleteWebAuthnCredential(tx, webAuthnCredentialId)
+}
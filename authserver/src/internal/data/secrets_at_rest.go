@@ -0,0 +1,77 @@
+package data
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/pkg/errors"
+)
+
+// protectSecretsAtRest encrypts the signing private keys stored in plaintext by earlier
+// versions, and wraps the AES encryption key and the session keys when a master key was
+// configured after they were stored
+func protectSecretsAtRest(database Database) error {
+	settings, err := database.GetSettingsById(nil, 1)
+	if err != nil {
+		return err
+	}
+
+	masterKey, err := lib.GetMasterKey()
+	if err != nil {
+		return err
+	}
+	if masterKey != nil && (!settings.AESEncryptionKeyWrapped || !settings.SessionKeysWrapped) {
+		// the keys get wrapped when the settings are stored
+		err = database.UpdateSettings(nil, settings)
+		if err != nil {
+			return err
+		}
+		slog.Info("wrapped the AES encryption key and the session keys with the master key")
+	}
+
+	allSigningKeys, err := database.GetAllSigningKeys(nil)
+	if err != nil {
+		return err
+	}
+	for i, signingKey := range allSigningKeys {
+		if !bytes.HasPrefix(signingKey.PrivateKeyPEMEncrypted, []byte("-----BEGIN")) {
+			continue
+		}
+		privateKeyPEMEncrypted, err := lib.EncryptText(string(signingKey.PrivateKeyPEMEncrypted), settings.AESEncryptionKey)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("unable to encrypt the private key %v", signingKey.KeyIdentifier))
+		}
+		allSigningKeys[i].PrivateKeyPEMEncrypted = privateKeyPEMEncrypted
+		err = database.UpdateKeyPair(nil, &allSigningKeys[i])
+		if err != nil {
+			return err
+		}
+		slog.Info(fmt.Sprintf("encrypted the private key %v", signingKey.KeyIdentifier))
+	}
+
+	return nil
+}
+
+// RewrapSettingsKeys stores the AES encryption key and the session keys wrapped by a new
+// master key, after unwrapping them with the current one. A nil master key stores them unwrapped
+func RewrapSettingsKeys(database Database, newMasterKey []byte) error {
+	settings, err := database.GetSettingsById(nil, 1)
+	if err != nil {
+		return err
+	}
+
+	currentMasterKey, err := lib.GetMasterKey()
+	if err != nil {
+		return err
+	}
+
+	lib.SetMasterKey(newMasterKey)
+	err = database.UpdateSettings(nil, settings)
+	if err != nil {
+		lib.SetMasterKey(currentMasterKey)
+		return err
+	}
+	return nil
+}
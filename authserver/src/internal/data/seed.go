@@ -1,10 +1,10 @@
 package data
 
 import (
-	"crypto/x509"
-	"encoding/pem"
+	"database/sql"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/securecookie"
@@ -12,7 +12,6 @@ import (
 	"github.com/leodip/goiabada/internal/entities"
 	"github.com/leodip/goiabada/internal/enums"
 	"github.com/leodip/goiabada/internal/lib"
-	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 )
 
@@ -34,6 +33,9 @@ func seed(database Database) error {
 		ClientCredentialsEnabled:                false,
 		ClientSecretEncrypted:                   clientSecretEncrypted,
 		IncludeOpenIDConnectClaimsInAccessToken: enums.ThreeStateSettingDefault.String(),
+		RefreshTokenRotationEnabled:             enums.ThreeStateSettingDefault.String(),
+		TokenEndpointAuthMethod:                 enums.TokenEndpointAuthMethodClientSecretBasic.String(),
+		DPoPMode:                                enums.DPoPModeDisabled.String(),
 	}
 
 	err := database.CreateClient(nil, client1)
@@ -134,81 +136,21 @@ func seed(database Database) error {
 	}
 
 	// key pair (current)
-
-	privateKey, err := lib.GeneratePrivateKey(4096)
-	if err != nil {
-		return errors.Wrap(err, "unable to generate a private key")
-	}
-	privateKeyPEM := lib.EncodePrivateKeyToPEM(privateKey)
-
-	publicKeyASN1_DER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
-	if err != nil {
-		return errors.Wrap(err, "unable to marshal public key to PKIX")
-	}
-
-	publicKeyPEM := pem.EncodeToMemory(
-		&pem.Block{
-			Type:  "RSA PUBLIC KEY",
-			Bytes: publicKeyASN1_DER,
-		},
-	)
-
-	kid := uuid.New().String()
-	publicKeyJWK, err := lib.MarshalRSAPublicKeyToJWK(&privateKey.PublicKey, kid)
+	keyPair, err := lib.GenerateSigningKeyPair(enums.SigningAlgorithmRS256, enums.KeyStateCurrent, encryptionKey)
 	if err != nil {
 		return err
 	}
-
-	keyPair := &entities.KeyPair{
-		State:             enums.KeyStateCurrent.String(),
-		KeyIdentifier:     kid,
-		Type:              "RSA",
-		Algorithm:         "RS256",
-		PrivateKeyPEM:     privateKeyPEM,
-		PublicKeyPEM:      publicKeyPEM,
-		PublicKeyASN1_DER: publicKeyASN1_DER,
-		PublicKeyJWK:      publicKeyJWK,
-	}
+	keyPair.ActivatedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
 	err = database.CreateKeyPair(nil, keyPair)
 	if err != nil {
 		return err
 	}
 
 	// key pair (next)
-	privateKey, err = lib.GeneratePrivateKey(4096)
-	if err != nil {
-		return errors.Wrap(err, "unable to generate a private key")
-	}
-	privateKeyPEM = lib.EncodePrivateKeyToPEM(privateKey)
-
-	publicKeyASN1_DER, err = x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
-	if err != nil {
-		return errors.Wrap(err, "unable to marshal public key to PKIX")
-	}
-
-	publicKeyPEM = pem.EncodeToMemory(
-		&pem.Block{
-			Type:  "RSA PUBLIC KEY",
-			Bytes: publicKeyASN1_DER,
-		},
-	)
-
-	kid = uuid.New().String()
-	publicKeyJWK, err = lib.MarshalRSAPublicKeyToJWK(&privateKey.PublicKey, kid)
+	keyPair, err = lib.GenerateSigningKeyPair(enums.SigningAlgorithmRS256, enums.KeyStateNext, encryptionKey)
 	if err != nil {
 		return err
 	}
-
-	keyPair = &entities.KeyPair{
-		State:             enums.KeyStateNext.String(),
-		KeyIdentifier:     kid,
-		Type:              "RSA",
-		Algorithm:         "RS256",
-		PrivateKeyPEM:     privateKeyPEM,
-		PublicKeyPEM:      publicKeyPEM,
-		PublicKeyASN1_DER: publicKeyASN1_DER,
-		PublicKeyJWK:      publicKeyJWK,
-	}
 	err = database.CreateKeyPair(nil, keyPair)
 	if err != nil {
 		return err
@@ -247,6 +189,15 @@ func seed(database Database) error {
 		UserSessionIdleTimeoutInSeconds:         7200,     // 2 hours
 		UserSessionMaxLifetimeInSeconds:         86400,    // 24 hours
 		IncludeOpenIDConnectClaimsInAccessToken: false,
+		RefreshTokenRotationEnabled:             true,
+		AuditLogRetentionInDays:                 90,
+		AccountLockoutThreshold:                 5,
+		AccountLockoutDurationInSeconds:         900, // 15 minutes
+		AccountPermanentLockoutThreshold:        0,
+		FailedLoginDelayInMilliseconds:          500,
+		KeyRotationIntervalInDays:               0, // automatic rotation disabled
+		KeyRotationOverlapInDays:                7,
+		ClientRegistrationMode:                  enums.ClientRegistrationModeDisabled.String(),
 	}
 	err = database.CreateSettings(nil, settings)
 	if err != nil {
@@ -26,6 +26,10 @@ func (d *SQLiteDatabase) ClientLoadRedirectURIs(tx *sql.Tx, client *entities.Cli
 	return d.CommonDB.ClientLoadRedirectURIs(tx, client)
 }
 
+func (d *SQLiteDatabase) ClientLoadPostLogoutRedirectURIs(tx *sql.Tx, client *entities.Client) error {
+	return d.CommonDB.ClientLoadPostLogoutRedirectURIs(tx, client)
+}
+
 func (d *SQLiteDatabase) ClientLoadWebOrigins(tx *sql.Tx, client *entities.Client) error {
 	return d.CommonDB.ClientLoadWebOrigins(tx, client)
 }
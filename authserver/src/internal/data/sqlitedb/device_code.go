@@ -0,0 +1,44 @@
+package sqlitedb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/leodip/goiabada/internal/entities"
+)
+
+func (d *SQLiteDatabase) CreateDeviceCode(tx *sql.Tx, deviceCode *entities.DeviceCode) error {
+	return d.CommonDB.CreateDeviceCode(tx, deviceCode)
+}
+
+func (d *SQLiteDatabase) UpdateDeviceCode(tx *sql.Tx, deviceCode *entities.DeviceCode) error {
+	return d.CommonDB.UpdateDeviceCode(tx, deviceCode)
+}
+
+func (d *SQLiteDatabase) UpdateDeviceCodePolling(tx *sql.Tx, deviceCodeId int64, lastPolledAt time.Time, intervalInSeconds int) (bool, error) {
+	return d.CommonDB.UpdateDeviceCodePolling(tx, deviceCodeId, lastPolledAt, intervalInSeconds)
+}
+
+func (d *SQLiteDatabase) ApproveDeviceCode(tx *sql.Tx, deviceCodeId int64, codeId int64) (bool, error) {
+	return d.CommonDB.ApproveDeviceCode(tx, deviceCodeId, codeId)
+}
+
+func (d *SQLiteDatabase) DenyDeviceCode(tx *sql.Tx, deviceCodeId int64) (bool, error) {
+	return d.CommonDB.DenyDeviceCode(tx, deviceCodeId)
+}
+
+func (d *SQLiteDatabase) GetDeviceCodeById(tx *sql.Tx, deviceCodeId int64) (*entities.DeviceCode, error) {
+	return d.CommonDB.GetDeviceCodeById(tx, deviceCodeId)
+}
+
+func (d *SQLiteDatabase) GetDeviceCodeByDeviceCodeHash(tx *sql.Tx, deviceCodeHash string) (*entities.DeviceCode, error) {
+	return d.CommonDB.GetDeviceCodeByDeviceCodeHash(tx, deviceCodeHash)
+}
+
+func (d *SQLiteDatabase) GetDeviceCodeByUserCodeHash(tx *sql.Tx, userCodeHash string) (*entities.DeviceCode, error) {
+	return d.CommonDB.GetDeviceCodeByUserCodeHash(tx, userCodeHash)
+}
+
+func (d *SQLiteDatabase) DeleteExpiredDeviceCodes(tx *sql.Tx) error {
+	return d.CommonDB.DeleteExpiredDeviceCodes(tx)
+}
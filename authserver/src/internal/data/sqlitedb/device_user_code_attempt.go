@@ -0,0 +1,20 @@
+package sqlitedb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/leodip/goiabada/internal/entities"
+)
+
+func (d *SQLiteDatabase) CreateDeviceUserCodeAttempt(tx *sql.Tx, attempt *entities.DeviceUserCodeAttempt) error {
+	return d.CommonDB.CreateDeviceUserCodeAttempt(tx, attempt)
+}
+
+func (d *SQLiteDatabase) CountDeviceUserCodeAttemptsSince(tx *sql.Tx, ipAddress string, since time.Time) (int, error) {
+	return d.CommonDB.CountDeviceUserCodeAttemptsSince(tx, ipAddress, since)
+}
+
+func (d *SQLiteDatabase) DeleteDeviceUserCodeAttemptsOlderThan(tx *sql.Tx, cutoff time.Time) error {
+	return d.CommonDB.DeleteDeviceUserCodeAttemptsOlderThan(tx, cutoff)
+}
@@ -14,6 +14,10 @@ func (d *SQLiteDatabase) UpdateKeyPair(tx *sql.Tx, keyPair *entities.KeyPair) er
 	return d.CommonDB.UpdateKeyPair(tx, keyPair)
 }
 
+func (d *SQLiteDatabase) UpdateKeyPairIfState(tx *sql.Tx, keyPair *entities.KeyPair, state string) (bool, error) {
+	return d.CommonDB.UpdateKeyPairIfState(tx, keyPair, state)
+}
+
 func (d *SQLiteDatabase) GetKeyPairById(tx *sql.Tx, keyPairId int64) (*entities.KeyPair, error) {
 	return d.CommonDB.GetKeyPairById(tx, keyPairId)
 }
@@ -0,0 +1,5 @@
+ALTER TABLE clients ADD COLUMN refresh_token_rotation_enabled TEXT NOT NULL DEFAULT 'default';
+
+ALTER TABLE settings ADD COLUMN refresh_token_rotation_enabled numeric NOT NULL DEFAULT 1;
+
+ALTER TABLE refresh_tokens ADD COLUMN used numeric NOT NULL DEFAULT 0;
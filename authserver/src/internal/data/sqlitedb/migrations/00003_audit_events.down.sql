@@ -0,0 +1,3 @@
+ALTER TABLE settings DROP COLUMN audit_log_retention_in_days;
+
+DROP TABLE IF EXISTS `audit_events`;
@@ -0,0 +1,19 @@
+CREATE TABLE audit_events (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  created_at DATETIME,
+  `event` TEXT NOT NULL,
+  actor TEXT NOT NULL,
+  user_id INTEGER NULL,
+  client_id INTEGER NULL,
+  ip_address TEXT NOT NULL,
+  request_id TEXT NOT NULL,
+  user_agent TEXT NOT NULL,
+  details longtext
+);
+
+CREATE INDEX `idx_audit_events_created_at` ON `audit_events`(`created_at`);
+CREATE INDEX `idx_audit_events_event` ON `audit_events`(`event`);
+CREATE INDEX `idx_audit_events_user_id` ON `audit_events`(`user_id`);
+CREATE INDEX `idx_audit_events_client_id` ON `audit_events`(`client_id`);
+
+ALTER TABLE settings ADD COLUMN audit_log_retention_in_days INTEGER NOT NULL DEFAULT 90;
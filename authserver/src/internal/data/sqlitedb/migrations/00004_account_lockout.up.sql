@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN failed_login_attempts INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE users ADD COLUMN last_failed_login_at DATETIME;
+
+ALTER TABLE users ADD COLUMN locked_until DATETIME;
+
+ALTER TABLE users ADD COLUMN locked_permanently numeric NOT NULL DEFAULT 0;
+
+ALTER TABLE settings ADD COLUMN account_lockout_threshold INTEGER NOT NULL DEFAULT 5;
+
+ALTER TABLE settings ADD COLUMN account_lockout_duration_in_seconds INTEGER NOT NULL DEFAULT 900;
+
+ALTER TABLE settings ADD COLUMN account_permanent_lockout_threshold INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE settings ADD COLUMN failed_login_delay_in_milliseconds INTEGER NOT NULL DEFAULT 500;
@@ -0,0 +1,17 @@
+CREATE TABLE webauthn_credentials (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  created_at DATETIME,
+  updated_at DATETIME,
+  user_id INTEGER NOT NULL,
+  `name` TEXT NOT NULL,
+  credential_id TEXT NOT NULL,
+  public_key BLOB NOT NULL,
+  sign_count INTEGER NOT NULL DEFAULT 0,
+  aaguid TEXT NOT NULL,
+  transports TEXT NOT NULL,
+  last_used_at DATETIME,
+  CONSTRAINT fk_webauthn_credentials_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX `idx_webauthn_credentials_credential_id` ON `webauthn_credentials`(`credential_id`);
+CREATE INDEX `idx_webauthn_credentials_user_id` ON `webauthn_credentials`(`user_id`);
@@ -0,0 +1,9 @@
+CREATE TABLE user_recovery_codes (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  created_at DATETIME,
+  user_id INTEGER NOT NULL,
+  code_hash TEXT NOT NULL,
+  CONSTRAINT fk_user_recovery_codes_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX `idx_user_recovery_codes_user_id` ON `user_recovery_codes`(`user_id`);
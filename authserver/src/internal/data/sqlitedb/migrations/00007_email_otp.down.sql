@@ -0,0 +1,5 @@
+ALTER TABLE users DROP COLUMN otp_code_issued_at;
+
+ALTER TABLE users DROP COLUMN otp_code_encrypted;
+
+ALTER TABLE users DROP COLUMN otp_method;
@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN otp_method TEXT NOT NULL DEFAULT 'totp';
+
+ALTER TABLE users ADD COLUMN otp_code_encrypted BLOB;
+
+ALTER TABLE users ADD COLUMN otp_code_issued_at DATETIME;
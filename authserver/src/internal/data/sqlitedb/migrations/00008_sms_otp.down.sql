@@ -0,0 +1,3 @@
+ALTER TABLE clients DROP COLUMN sms_otp_allowed_acr_level3;
+
+ALTER TABLE clients DROP COLUMN sms_otp_allowed_acr_level2;
@@ -0,0 +1,3 @@
+ALTER TABLE clients ADD COLUMN sms_otp_allowed_acr_level2 numeric NOT NULL DEFAULT 0;
+
+ALTER TABLE clients ADD COLUMN sms_otp_allowed_acr_level3 numeric NOT NULL DEFAULT 0;
@@ -0,0 +1,7 @@
+ALTER TABLE key_pairs ADD COLUMN activated_at DATETIME;
+
+ALTER TABLE key_pairs ADD COLUMN deactivated_at DATETIME;
+
+ALTER TABLE settings ADD COLUMN key_rotation_interval_in_days INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE settings ADD COLUMN key_rotation_overlap_in_days INTEGER NOT NULL DEFAULT 7;
@@ -0,0 +1,3 @@
+ALTER TABLE key_pairs RENAME COLUMN private_key_pem TO private_key_pem_encrypted;
+
+ALTER TABLE settings ADD COLUMN aes_encryption_key_wrapped numeric NOT NULL DEFAULT 0;
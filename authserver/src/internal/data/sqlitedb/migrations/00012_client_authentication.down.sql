@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS `client_assertion_jtis`;
+
+ALTER TABLE clients DROP COLUMN jwks_uri;
+
+ALTER TABLE clients DROP COLUMN jwks;
+
+ALTER TABLE clients DROP COLUMN token_endpoint_auth_method;
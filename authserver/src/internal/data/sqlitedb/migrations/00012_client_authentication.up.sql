@@ -0,0 +1,18 @@
+ALTER TABLE clients ADD COLUMN token_endpoint_auth_method TEXT NOT NULL DEFAULT 'client_secret_basic';
+
+ALTER TABLE clients ADD COLUMN jwks TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE clients ADD COLUMN jwks_uri TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE client_assertion_jtis (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  created_at DATETIME,
+  client_id INTEGER NOT NULL,
+  jti TEXT NOT NULL,
+  expires_at DATETIME NOT NULL,
+  CONSTRAINT fk_client_assertion_jtis_client FOREIGN KEY (client_id) REFERENCES clients (id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX `idx_client_assertion_jtis_client_id_jti` ON `client_assertion_jtis`(`client_id`, `jti`);
+
+CREATE INDEX `idx_client_assertion_jtis_expires_at` ON `client_assertion_jtis`(`expires_at`);
@@ -0,0 +1 @@
+ALTER TABLE clients DROP COLUMN tls_client_auth_subject_dn;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS `dpop_proof_jtis`;
+
+ALTER TABLE clients DROP COLUMN dpop_nonce_required;
+
+ALTER TABLE clients DROP COLUMN dpop_mode;
@@ -0,0 +1,15 @@
+ALTER TABLE clients ADD COLUMN dpop_mode TEXT NOT NULL DEFAULT 'disabled';
+
+ALTER TABLE clients ADD COLUMN dpop_nonce_required numeric NOT NULL DEFAULT 0;
+
+CREATE TABLE dpop_proof_jtis (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  created_at DATETIME,
+  jkt TEXT NOT NULL,
+  jti TEXT NOT NULL,
+  expires_at DATETIME NOT NULL
+);
+
+CREATE UNIQUE INDEX `idx_dpop_proof_jtis_jkt_jti` ON `dpop_proof_jtis`(`jkt`, `jti`);
+
+CREATE INDEX `idx_dpop_proof_jtis_expires_at` ON `dpop_proof_jtis`(`expires_at`);
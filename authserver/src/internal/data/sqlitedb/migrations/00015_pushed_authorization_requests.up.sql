@@ -0,0 +1,15 @@
+ALTER TABLE clients ADD COLUMN require_pushed_authorization_requests numeric NOT NULL DEFAULT 0;
+
+CREATE TABLE pushed_authorization_requests (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  created_at DATETIME,
+  client_id INTEGER NOT NULL,
+  request_uri_hash TEXT NOT NULL,
+  parameters TEXT NOT NULL,
+  expires_at DATETIME NOT NULL,
+  CONSTRAINT fk_pushed_authorization_requests_client FOREIGN KEY (client_id) REFERENCES clients (id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX `idx_pushed_authorization_requests_request_uri_hash` ON `pushed_authorization_requests`(`request_uri_hash`);
+
+CREATE INDEX `idx_pushed_authorization_requests_expires_at` ON `pushed_authorization_requests`(`expires_at`);
@@ -0,0 +1 @@
+ALTER TABLE clients ADD COLUMN require_signed_request_object numeric NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS `device_codes`;
+
+ALTER TABLE clients DROP COLUMN device_code_enabled;
@@ -0,0 +1,24 @@
+ALTER TABLE clients ADD COLUMN device_code_enabled numeric NOT NULL DEFAULT 0;
+
+CREATE TABLE device_codes (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  created_at DATETIME,
+  updated_at DATETIME,
+  client_id INTEGER NOT NULL,
+  device_code_hash TEXT NOT NULL,
+  user_code_hash TEXT NOT NULL,
+  scope TEXT NOT NULL,
+  `status` TEXT NOT NULL,
+  code_id INTEGER,
+  interval_in_seconds INTEGER NOT NULL,
+  last_polled_at DATETIME,
+  expires_at DATETIME NOT NULL,
+  CONSTRAINT fk_device_codes_client FOREIGN KEY (client_id) REFERENCES clients (id) ON DELETE CASCADE,
+  CONSTRAINT fk_device_codes_code FOREIGN KEY (code_id) REFERENCES codes (id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX `idx_device_codes_device_code_hash` ON `device_codes`(`device_code_hash`);
+
+CREATE UNIQUE INDEX `idx_device_codes_user_code_hash` ON `device_codes`(`user_code_hash`);
+
+CREATE INDEX `idx_device_codes_expires_at` ON `device_codes`(`expires_at`);
@@ -0,0 +1,20 @@
+ALTER TABLE clients ADD COLUMN backchannel_logout_uri TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE backchannel_logout_deliveries (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  created_at DATETIME,
+  updated_at DATETIME,
+  client_id INTEGER NOT NULL,
+  subject TEXT NOT NULL,
+  session_identifier TEXT NOT NULL,
+  `status` TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT NOT NULL DEFAULT '',
+  next_attempt_at DATETIME NOT NULL,
+  delivered_at DATETIME,
+  CONSTRAINT fk_backchannel_logout_deliveries_client FOREIGN KEY (client_id) REFERENCES clients (id) ON DELETE CASCADE
+);
+
+CREATE INDEX `idx_backchannel_logout_deliveries_status_next_attempt_at` ON `backchannel_logout_deliveries`(`status`, `next_attempt_at`);
+
+CREATE INDEX `idx_backchannel_logout_deliveries_created_at` ON `backchannel_logout_deliveries`(`created_at`);
@@ -0,0 +1,12 @@
+ALTER TABLE clients ADD COLUMN frontchannel_logout_uri TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE post_logout_redirect_uris (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  created_at DATETIME,
+  uri TEXT NOT NULL,
+  client_id INTEGER NOT NULL,
+  CONSTRAINT fk_clients_post_logout_redirect_uris FOREIGN KEY (client_id) REFERENCES clients (id) ON DELETE CASCADE
+);
+
+INSERT INTO post_logout_redirect_uris (created_at, uri, client_id)
+  SELECT created_at, uri, client_id FROM redirect_uris;
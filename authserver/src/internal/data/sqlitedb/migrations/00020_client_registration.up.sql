@@ -0,0 +1,5 @@
+ALTER TABLE clients ADD COLUMN registration_access_token_hash TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE settings ADD COLUMN client_registration_mode TEXT NOT NULL DEFAULT 'disabled';
+
+ALTER TABLE settings ADD COLUMN client_registration_initial_access_token_encrypted BLOB;
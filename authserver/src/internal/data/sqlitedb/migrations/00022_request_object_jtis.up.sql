@@ -0,0 +1,12 @@
+CREATE TABLE request_object_jtis (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  created_at DATETIME,
+  client_id INTEGER NOT NULL,
+  jti TEXT NOT NULL,
+  expires_at DATETIME NOT NULL,
+  CONSTRAINT fk_request_object_jtis_client FOREIGN KEY (client_id) REFERENCES clients (id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX `idx_request_object_jtis_client_id_jti` ON `request_object_jtis`(`client_id`, `jti`);
+
+CREATE INDEX `idx_request_object_jtis_expires_at` ON `request_object_jtis`(`expires_at`);
@@ -0,0 +1,7 @@
+CREATE TABLE device_user_code_attempts (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  created_at DATETIME NOT NULL,
+  ip_address TEXT NOT NULL
+);
+
+CREATE INDEX `idx_device_user_code_attempts_ip_address_created_at` ON `device_user_code_attempts`(`ip_address`, `created_at`);
@@ -0,0 +1 @@
+ALTER TABLE settings ADD COLUMN session_keys_wrapped numeric NOT NULL DEFAULT 0;
@@ -0,0 +1,19 @@
+package sqlitedb
+
+import (
+	"database/sql"
+
+	"github.com/leodip/goiabada/internal/entities"
+)
+
+func (d *SQLiteDatabase) CreatePostLogoutRedirectURI(tx *sql.Tx, postLogoutRedirectURI *entities.PostLogoutRedirectURI) error {
+	return d.CommonDB.CreatePostLogoutRedirectURI(tx, postLogoutRedirectURI)
+}
+
+func (d *SQLiteDatabase) GetPostLogoutRedirectURIsByClientId(tx *sql.Tx, clientId int64) ([]entities.PostLogoutRedirectURI, error) {
+	return d.CommonDB.GetPostLogoutRedirectURIsByClientId(tx, clientId)
+}
+
+func (d *SQLiteDatabase) DeletePostLogoutRedirectURI(tx *sql.Tx, postLogoutRedirectURIId int64) error {
+	return d.CommonDB.DeletePostLogoutRedirectURI(tx, postLogoutRedirectURIId)
+}
@@ -0,0 +1,23 @@
+package sqlitedb
+
+import (
+	"database/sql"
+
+	"github.com/leodip/goiabada/internal/entities"
+)
+
+func (d *SQLiteDatabase) CreatePushedAuthorizationRequest(tx *sql.Tx, pushedAuthorizationRequest *entities.PushedAuthorizationRequest) error {
+	return d.CommonDB.CreatePushedAuthorizationRequest(tx, pushedAuthorizationRequest)
+}
+
+func (d *SQLiteDatabase) GetPushedAuthorizationRequestByRequestURIHash(tx *sql.Tx, requestURIHash string) (*entities.PushedAuthorizationRequest, error) {
+	return d.CommonDB.GetPushedAuthorizationRequestByRequestURIHash(tx, requestURIHash)
+}
+
+func (d *SQLiteDatabase) DeletePushedAuthorizationRequest(tx *sql.Tx, pushedAuthorizationRequestId int64) (bool, error) {
+	return d.CommonDB.DeletePushedAuthorizationRequest(tx, pushedAuthorizationRequestId)
+}
+
+func (d *SQLiteDatabase) DeleteExpiredPushedAuthorizationRequests(tx *sql.Tx) error {
+	return d.CommonDB.DeleteExpiredPushedAuthorizationRequests(tx)
+}
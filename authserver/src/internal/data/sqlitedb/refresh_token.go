@@ -14,6 +14,10 @@ func (d *SQLiteDatabase) UpdateRefreshToken(tx *sql.Tx, refreshToken *entities.R
 	return d.CommonDB.UpdateRefreshToken(tx, refreshToken)
 }
 
+func (d *SQLiteDatabase) RedeemRefreshToken(tx *sql.Tx, refreshTokenId int64, markAsUsed bool) (bool, error) {
+	return d.CommonDB.RedeemRefreshToken(tx, refreshTokenId, markAsUsed)
+}
+
 func (d *SQLiteDatabase) GetRefreshTokenById(tx *sql.Tx, refreshTokenId int64) (*entities.RefreshToken, error) {
 	return d.CommonDB.GetRefreshTokenById(tx, refreshTokenId)
 }
@@ -26,6 +30,10 @@ func (d *SQLiteDatabase) GetRefreshTokenByJti(tx *sql.Tx, jti string) (*entities
 	return d.CommonDB.GetRefreshTokenByJti(tx, jti)
 }
 
+func (d *SQLiteDatabase) GetRefreshTokensByFirstRefreshTokenJti(tx *sql.Tx, firstRefreshTokenJti string) ([]entities.RefreshToken, error) {
+	return d.CommonDB.GetRefreshTokensByFirstRefreshTokenJti(tx, firstRefreshTokenJti)
+}
+
 func (d *SQLiteDatabase) DeleteRefreshToken(tx *sql.Tx, refreshTokenId int64) error {
 	return d.CommonDB.DeleteRefreshToken(tx, refreshTokenId)
 }
@@ -14,6 +14,30 @@ func (d *SQLiteDatabase) UpdateUser(tx *sql.Tx, user *entities.User) error {
 	return d.CommonDB.UpdateUser(tx, user)
 }
 
+func (d *SQLiteDatabase) IncrementUserFailedLoginAttempts(tx *sql.Tx, userId int64) error {
+	return d.CommonDB.IncrementUserFailedLoginAttempts(tx, userId)
+}
+
+func (d *SQLiteDatabase) SetUserOTPCode(tx *sql.Tx, userId int64, otpCodeEncrypted []byte, otpCodeIssuedAt sql.NullTime) error {
+	return d.CommonDB.SetUserOTPCode(tx, userId, otpCodeEncrypted, otpCodeIssuedAt)
+}
+
+func (d *SQLiteDatabase) IncrementUserOTPCodeAttempts(tx *sql.Tx, userId int64, maxAttempts int) (bool, error) {
+	return d.CommonDB.IncrementUserOTPCodeAttempts(tx, userId, maxAttempts)
+}
+
+func (d *SQLiteDatabase) ClearUserOTPCode(tx *sql.Tx, userId int64) (bool, error) {
+	return d.CommonDB.ClearUserOTPCode(tx, userId)
+}
+
+func (d *SQLiteDatabase) LockUser(tx *sql.Tx, userId int64, lockedUntil sql.NullTime, lockedPermanently bool) error {
+	return d.CommonDB.LockUser(tx, userId, lockedUntil, lockedPermanently)
+}
+
+func (d *SQLiteDatabase) ResetUserFailedLoginAttempts(tx *sql.Tx, userId int64) error {
+	return d.CommonDB.ResetUserFailedLoginAttempts(tx, userId)
+}
+
 func (d *SQLiteDatabase) GetUsersByIds(tx *sql.Tx, userIds []int64) (map[int64]entities.User, error) {
 	return d.CommonDB.GetUsersByIds(tx, userIds)
 }
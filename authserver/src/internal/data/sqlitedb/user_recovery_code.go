@@ -0,0 +1,23 @@
+package sqlitedb
+
+import (
+	"database/sql"
+
+	"github.com/leodip/goiabada/internal/entities"
+)
+
+func (d *SQLiteDatabase) CreateUserRecoveryCode(tx *sql.Tx, userRecoveryCode *entities.UserRecoveryCode) error {
+	return d.CommonDB.CreateUserRecoveryCode(tx, userRecoveryCode)
+}
+
+func (d *SQLiteDatabase) GetUserRecoveryCodesByUserId(tx *sql.Tx, userId int64) ([]entities.UserRecoveryCode, error) {
+	return d.CommonDB.GetUserRecoveryCodesByUserId(tx, userId)
+}
+
+func (d *SQLiteDatabase) DeleteUserRecoveryCode(tx *sql.Tx, userRecoveryCodeId int64) (bool, error) {
+	return d.CommonDB.DeleteUserRecoveryCode(tx, userRecoveryCodeId)
+}
+
+func (d *SQLiteDatabase) DeleteUserRecoveryCodesByUserId(tx *sql.Tx, userId int64) error {
+	return d.CommonDB.DeleteUserRecoveryCodesByUserId(tx, userId)
+}
@@ -0,0 +1,31 @@
+package sqlitedb
+
+import (
+	"database/sql"
+
+	"github.com/leodip/goiabada/internal/entities"
+)
+
+func (d *SQLiteDatabase) CreateWebAuthnCredential(tx *sql.Tx, webAuthnCredential *entities.WebAuthnCredential) error {
+	return d.CommonDB.CreateWebAuthnCredential(tx, webAuthnCredential)
+}
+
+func (d *SQLiteDatabase) UpdateWebAuthnCredential(tx *sql.Tx, webAuthnCredential *entities.WebAuthnCredential) error {
+	return d.CommonDB.UpdateWebAuthnCredential(tx, webAuthnCredential)
+}
+
+func (d *SQLiteDatabase) GetWebAuthnCredentialById(tx *sql.Tx, webAuthnCredentialId int64) (*entities.WebAuthnCredential, error) {
+	return d.CommonDB.GetWebAuthnCredentialById(tx, webAuthnCredentialId)
+}
+
+func (d *SQLiteDatabase) GetWebAuthnCredentialByCredentialId(tx *sql.Tx, credentialId string) (*entities.WebAuthnCredential, error) {
+	return d.CommonDB.GetWebAuthnCredentialByCredentialId(tx, credentialId)
+}
+
+func (d *SQLiteDatabase) GetWebAuthnCredentialsByUserId(tx *sql.Tx, userId int64) ([]entities.WebAuthnCredential, error) {
+	return d.CommonDB.GetWebAuthnCredentialsByUserId(tx, userId)
+}
+
+func (d *SQLiteDatabase) DeleteWebAuthnCredential(tx *sql.Tx, webAuthnCredentialId int64) error {
+	return d.CommonDB.DeleteWebAuthnCredential(tx, webAuthnCredentialId)
+}
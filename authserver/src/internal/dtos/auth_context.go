@@ -24,13 +24,18 @@ type AuthContext struct {
 	RequestedAcrValues  string
 	State               string
 	Nonce               string
+	Prompt              string
+	LoginHint           string
+	IdTokenHintSubject  string
 	UserAgent           string
 	IpAddress           string
 	AcrLevel            string
 	AuthMethods         string
+	OTPMethod           string
 	AuthTime            time.Time
 	UserId              int64
 	AuthCompleted       bool
+	DeviceCodeId        int64
 }
 
 func (ac *AuthContext) SetScope(scope string) {
@@ -57,6 +62,11 @@ func (ac *AuthContext) HasScope(scope string) bool {
 	return slices.Contains(strings.Split(ac.Scope, " "), scope)
 }
 
+// HasPrompt tells if the prompt parameter of the authorization request contains the value
+func (ac *AuthContext) HasPrompt(prompt string) bool {
+	return slices.Contains(strings.Fields(ac.Prompt), prompt)
+}
+
 func (ac *AuthContext) ParseRequestedMaxAge() *int {
 	var requestedMaxAge *int
 	if len(ac.MaxAge) > 0 {
@@ -82,13 +92,20 @@ func (ac *AuthContext) SetAcrLevel(targetAcrLevel enums.AcrLevel, userSession *e
 
 	switch targetAcrLevel {
 	case enums.AcrLevel1:
-		if userSessionAcrLevel == enums.AcrLevel2 || userSessionAcrLevel == enums.AcrLevel3 {
+		if userSessionAcrLevel == enums.AcrLevel2 || userSessionAcrLevel == enums.AcrLevel3 ||
+			userSessionAcrLevel == enums.AcrLevelPasskey {
 			ac.AcrLevel = userSessionAcrLevel.String()
 		} else {
 			ac.AcrLevel = targetAcrLevel.String()
 		}
 	case enums.AcrLevel2:
-		if userSessionAcrLevel == enums.AcrLevel3 {
+		if userSessionAcrLevel == enums.AcrLevel3 || userSessionAcrLevel == enums.AcrLevelPasskey {
+			ac.AcrLevel = userSessionAcrLevel.String()
+		} else {
+			ac.AcrLevel = targetAcrLevel.String()
+		}
+	case enums.AcrLevel3:
+		if userSessionAcrLevel == enums.AcrLevelPasskey {
 			ac.AcrLevel = userSessionAcrLevel.String()
 		} else {
 			ac.AcrLevel = targetAcrLevel.String()
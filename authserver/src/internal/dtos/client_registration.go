@@ -0,0 +1,28 @@
+package dtos
+
+import "encoding/json"
+
+// ClientMetadata is the metadata of a client, as sent to and returned by the client
+// registration endpoint (RFC 7591, section 2)
+type ClientMetadata struct {
+	ClientName              string          `json:"client_name,omitempty"`
+	RedirectURIs            []string        `json:"redirect_uris"`
+	PostLogoutRedirectURIs  []string        `json:"post_logout_redirect_uris"`
+	WebOrigins              []string        `json:"web_origins"`
+	GrantTypes              []string        `json:"grant_types"`
+	ResponseTypes           []string        `json:"response_types"`
+	TokenEndpointAuthMethod string          `json:"token_endpoint_auth_method"`
+	JWKS                    json.RawMessage `json:"jwks,omitempty"`
+	JWKSURI                 string          `json:"jwks_uri,omitempty"`
+	TLSClientAuthSubjectDN  string          `json:"tls_client_auth_subject_dn,omitempty"`
+}
+
+type ClientRegistrationResponse struct {
+	ClientId                string `json:"client_id"`
+	ClientSecret            string `json:"client_secret,omitempty"`
+	ClientIdIssuedAt        int64  `json:"client_id_issued_at"`
+	ClientSecretExpiresAt   int64  `json:"client_secret_expires_at"`
+	RegistrationAccessToken string `json:"registration_access_token,omitempty"`
+	RegistrationClientURI   string `json:"registration_client_uri"`
+	ClientMetadata
+}
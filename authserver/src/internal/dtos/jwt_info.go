@@ -87,6 +87,22 @@ func (jwt JwtToken) GetAddressClaim() map[string]string {
 	return map[string]string{}
 }
 
+// GetConfirmationClaim returns the cnf claim of a token bound to a client key (RFC 7800)
+func (jwt JwtToken) GetConfirmationClaim() map[string]string {
+	result := map[string]string{}
+	if jwt.Claims["cnf"] != nil {
+		cnfMap, ok := jwt.Claims["cnf"].(map[string]interface{})
+		if ok {
+			for k, v := range cnfMap {
+				if str, ok := v.(string); ok {
+					result[k] = str
+				}
+			}
+		}
+	}
+	return result
+}
+
 func (jwt JwtToken) HasScope(scope string) bool {
 	if jwt.Claims["scope"] != nil {
 		scopesStr, ok := jwt.Claims["scope"].(string)
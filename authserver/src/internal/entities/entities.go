@@ -13,25 +13,48 @@ import (
 )
 
 type Client struct {
-	Id                                      int64          `db:"id" fieldtag:"pk"`
-	CreatedAt                               sql.NullTime   `db:"created_at"`
-	UpdatedAt                               sql.NullTime   `db:"updated_at"`
-	ClientIdentifier                        string         `db:"client_identifier"`
-	ClientSecretEncrypted                   []byte         `db:"client_secret_encrypted"`
-	Description                             string         `db:"description"`
-	Enabled                                 bool           `db:"enabled"`
-	ConsentRequired                         bool           `db:"consent_required"`
-	IsPublic                                bool           `db:"is_public"`
-	AuthorizationCodeEnabled                bool           `db:"authorization_code_enabled"`
-	ClientCredentialsEnabled                bool           `db:"client_credentials_enabled"`
-	TokenExpirationInSeconds                int            `db:"token_expiration_in_seconds"`
-	RefreshTokenOfflineIdleTimeoutInSeconds int            `db:"refresh_token_offline_idle_timeout_in_seconds"`
-	RefreshTokenOfflineMaxLifetimeInSeconds int            `db:"refresh_token_offline_max_lifetime_in_seconds"`
-	IncludeOpenIDConnectClaimsInAccessToken string         `db:"include_open_id_connect_claims_in_access_token"`
-	DefaultAcrLevel                         enums.AcrLevel `db:"default_acr_level"`
-	Permissions                             []Permission   `db:"-"`
-	RedirectURIs                            []RedirectURI  `db:"-"`
-	WebOrigins                              []WebOrigin    `db:"-"`
+	Id                                      int64                   `db:"id" fieldtag:"pk"`
+	CreatedAt                               sql.NullTime            `db:"created_at"`
+	UpdatedAt                               sql.NullTime            `db:"updated_at"`
+	ClientIdentifier                        string                  `db:"client_identifier"`
+	ClientSecretEncrypted                   []byte                  `db:"client_secret_encrypted"`
+	Description                             string                  `db:"description"`
+	Enabled                                 bool                    `db:"enabled"`
+	ConsentRequired                         bool                    `db:"consent_required"`
+	IsPublic                                bool                    `db:"is_public"`
+	AuthorizationCodeEnabled                bool                    `db:"authorization_code_enabled"`
+	ClientCredentialsEnabled                bool                    `db:"client_credentials_enabled"`
+	TokenExpirationInSeconds                int                     `db:"token_expiration_in_seconds"`
+	RefreshTokenOfflineIdleTimeoutInSeconds int                     `db:"refresh_token_offline_idle_timeout_in_seconds"`
+	RefreshTokenOfflineMaxLifetimeInSeconds int                     `db:"refresh_token_offline_max_lifetime_in_seconds"`
+	IncludeOpenIDConnectClaimsInAccessToken string                  `db:"include_open_id_connect_claims_in_access_token"`
+	RefreshTokenRotationEnabled             string                  `db:"refresh_token_rotation_enabled"`
+	DefaultAcrLevel                         enums.AcrLevel          `db:"default_acr_level"`
+	SMSOTPAllowedAcrLevel2                  bool                    `db:"sms_otp_allowed_acr_level2"`
+	SMSOTPAllowedAcrLevel3                  bool                    `db:"sms_otp_allowed_acr_level3"`
+	IdTokenSignedResponseAlg                string                  `db:"id_token_signed_response_alg"`
+	TokenEndpointAuthMethod                 string                  `db:"token_endpoint_auth_method"`
+	JWKS                                    string                  `db:"jwks"`
+	JWKSURI                                 string                  `db:"jwks_uri"`
+	TLSClientAuthSubjectDN                  string                  `db:"tls_client_auth_subject_dn"`
+	DPoPMode                                string                  `db:"dpop_mode"`
+	DPoPNonceRequired                       bool                    `db:"dpop_nonce_required"`
+	RequirePushedAuthorizationRequests      bool                    `db:"require_pushed_authorization_requests"`
+	RequireSignedRequestObject              bool                    `db:"require_signed_request_object"`
+	DeviceCodeEnabled                       bool                    `db:"device_code_enabled"`
+	BackchannelLogoutURI                    string                  `db:"backchannel_logout_uri"`
+	FrontchannelLogoutURI                   string                  `db:"frontchannel_logout_uri"`
+	RegistrationAccessTokenHash             string                  `db:"registration_access_token_hash"`
+	Permissions                             []Permission            `db:"-"`
+	RedirectURIs                            []RedirectURI           `db:"-"`
+	PostLogoutRedirectURIs                  []PostLogoutRedirectURI `db:"-"`
+	WebOrigins                              []WebOrigin             `db:"-"`
+}
+
+// IsDynamicallyRegistered returns true if the client was created through the client
+// registration endpoint, and can be managed with its registration access token
+func (c *Client) IsDynamicallyRegistered() bool {
+	return len(c.RegistrationAccessTokenHash) > 0
 }
 
 func (c *Client) IsSystemLevelClient() bool {
@@ -46,6 +69,42 @@ func (c *Client) IsSystemLevelClient() bool {
 	return false
 }
 
+// IsSMSOTPAllowed returns true if a code sent by SMS is accepted as the second factor
+// when authenticating with the given ACR level
+func (c *Client) IsSMSOTPAllowed(acrLevel enums.AcrLevel) bool {
+	switch acrLevel {
+	case enums.AcrLevel2:
+		return c.SMSOTPAllowedAcrLevel2
+	case enums.AcrLevel3:
+		return c.SMSOTPAllowedAcrLevel3
+	}
+	return false
+}
+
+// IsPrivateKeyJwtAuth returns true if the client authenticates with a client assertion signed
+// with its private key, instead of a client secret
+func (c *Client) IsPrivateKeyJwtAuth() bool {
+	return c.TokenEndpointAuthMethod == enums.TokenEndpointAuthMethodPrivateKeyJwt.String()
+}
+
+// IsDPoPEnabled returns true if the access tokens of the client can be bound to a key
+// held by the client with DPoP proofs (RFC 9449)
+func (c *Client) IsDPoPEnabled() bool {
+	return c.DPoPMode == enums.DPoPModeOptional.String() || c.DPoPMode == enums.DPoPModeRequired.String()
+}
+
+// IsDPoPRequired returns true if the client can only obtain DPoP-bound access tokens
+func (c *Client) IsDPoPRequired() bool {
+	return c.DPoPMode == enums.DPoPModeRequired.String()
+}
+
+// IsTLSClientAuth returns true if the client authenticates with a client certificate during
+// the TLS handshake (mutual TLS), either issued by a trusted CA or self-signed
+func (c *Client) IsTLSClientAuth() bool {
+	return c.TokenEndpointAuthMethod == enums.TokenEndpointAuthMethodTLSClientAuth.String() ||
+		c.TokenEndpointAuthMethod == enums.TokenEndpointAuthMethodSelfSignedTLSClientAuth.String()
+}
+
 type WebOrigin struct {
 	Id        int64        `db:"id" fieldtag:"pk"`
 	CreatedAt sql.NullTime `db:"created_at"`
@@ -90,6 +149,13 @@ type RedirectURI struct {
 	ClientId  int64        `db:"client_id"`
 }
 
+type PostLogoutRedirectURI struct {
+	Id        int64        `db:"id" fieldtag:"pk"`
+	CreatedAt sql.NullTime `db:"created_at"`
+	URI       string       `db:"uri"`
+	ClientId  int64        `db:"client_id"`
+}
+
 type User struct {
 	Id                                   int64           `db:"id" fieldtag:"pk"`
 	CreatedAt                            sql.NullTime    `db:"created_at"`
@@ -124,13 +190,40 @@ type User struct {
 	PasswordHash                         string          `db:"password_hash"`
 	OTPSecret                            string          `db:"otp_secret"`
 	OTPEnabled                           bool            `db:"otp_enabled"`
+	OTPMethod                            string          `db:"otp_method"`
+	OTPCodeEncrypted                     []byte          `db:"otp_code_encrypted"`
+	OTPCodeIssuedAt                      sql.NullTime    `db:"otp_code_issued_at"`
+	OTPCodeAttempts                      int             `db:"otp_code_attempts"`
 	ForgotPasswordCodeEncrypted          []byte          `db:"forgot_password_code_encrypted"`
 	ForgotPasswordCodeIssuedAt           sql.NullTime    `db:"forgot_password_code_issued_at"`
+	FailedLoginAttempts                  int             `db:"failed_login_attempts"`
+	LastFailedLoginAt                    sql.NullTime    `db:"last_failed_login_at"`
+	LockedUntil                          sql.NullTime    `db:"locked_until"`
+	LockedPermanently                    bool            `db:"locked_permanently"`
 	Groups                               []Group         `db:"-"`
 	Permissions                          []Permission    `db:"-"`
 	Attributes                           []UserAttribute `db:"-"`
 }
 
+// IsLocked returns true if the account is permanently locked, or temporarily locked
+// at the given time, because of too many failed authentication attempts
+func (u *User) IsLocked(now time.Time) bool {
+	if u.LockedPermanently {
+		return true
+	}
+	return u.LockedUntil.Valid && now.Before(u.LockedUntil.Time)
+}
+
+// GetOTPMethod returns how the user receives OTP codes. When not set, the codes come from
+// an authenticator app
+func (u *User) GetOTPMethod() enums.OTPMethod {
+	otpMethod, err := enums.OTPMethodFromString(u.OTPMethod)
+	if err != nil {
+		return enums.OTPMethodTOTP
+	}
+	return otpMethod
+}
+
 func (u *User) HasAddress() bool {
 	if len(strings.TrimSpace(u.AddressLine1)) > 0 ||
 		len(strings.TrimSpace(u.AddressLine2)) > 0 ||
@@ -324,20 +417,23 @@ type RefreshToken struct {
 	ExpiresAt               sql.NullTime `db:"expires_at"`
 	MaxLifetime             sql.NullTime `db:"max_lifetime"`
 	Revoked                 bool         `db:"revoked"`
+	Used                    bool         `db:"used"`
 }
 
 type KeyPair struct {
-	Id                int64        `db:"id" fieldtag:"pk"`
-	CreatedAt         sql.NullTime `db:"created_at"`
-	UpdatedAt         sql.NullTime `db:"updated_at"`
-	State             string       `db:"state"`
-	KeyIdentifier     string       `db:"key_identifier"`
-	Type              string       `db:"type" fieldopt:"withquote"`
-	Algorithm         string       `db:"algorithm" fieldopt:"withquote"`
-	PrivateKeyPEM     []byte       `db:"private_key_pem"`
-	PublicKeyPEM      []byte       `db:"public_key_pem"`
-	PublicKeyASN1_DER []byte       `db:"public_key_asn1_der"`
-	PublicKeyJWK      []byte       `db:"public_key_jwk"`
+	Id                     int64        `db:"id" fieldtag:"pk"`
+	CreatedAt              sql.NullTime `db:"created_at"`
+	UpdatedAt              sql.NullTime `db:"updated_at"`
+	State                  string       `db:"state"`
+	KeyIdentifier          string       `db:"key_identifier"`
+	Type                   string       `db:"type" fieldopt:"withquote"`
+	Algorithm              string       `db:"algorithm" fieldopt:"withquote"`
+	PrivateKeyPEMEncrypted []byte       `db:"private_key_pem_encrypted"`
+	PublicKeyPEM           []byte       `db:"public_key_pem"`
+	PublicKeyASN1_DER      []byte       `db:"public_key_asn1_der"`
+	PublicKeyJWK           []byte       `db:"public_key_jwk"`
+	ActivatedAt            sql.NullTime `db:"activated_at"`
+	DeactivatedAt          sql.NullTime `db:"deactivated_at"`
 }
 
 type Settings struct {
@@ -356,9 +452,12 @@ type Settings struct {
 	UserSessionIdleTimeoutInSeconds           int                  `db:"user_session_idle_timeout_in_seconds"`
 	UserSessionMaxLifetimeInSeconds           int                  `db:"user_session_max_lifetime_in_seconds"`
 	IncludeOpenIDConnectClaimsInAccessToken   bool                 `db:"include_open_id_connect_claims_in_access_token"`
+	RefreshTokenRotationEnabled               bool                 `db:"refresh_token_rotation_enabled"`
 	SessionAuthenticationKey                  []byte               `db:"session_authentication_key"`
 	SessionEncryptionKey                      []byte               `db:"session_encryption_key"`
 	AESEncryptionKey                          []byte               `db:"aes_encryption_key"`
+	AESEncryptionKeyWrapped                   bool                 `db:"aes_encryption_key_wrapped"`
+	SessionKeysWrapped                        bool                 `db:"session_keys_wrapped"`
 	SMTPHost                                  string               `db:"smtp_host"`
 	SMTPPort                                  int                  `db:"smtp_port"`
 	SMTPUsername                              string               `db:"smtp_username"`
@@ -369,6 +468,16 @@ type Settings struct {
 	SMTPEnabled                               bool                 `db:"smtp_enabled"`
 	SMSProvider                               string               `db:"sms_provider"`
 	SMSConfigEncrypted                        []byte               `db:"sms_config_encrypted"`
+	AuditLogRetentionInDays                   int                  `db:"audit_log_retention_in_days"`
+	AccountLockoutThreshold                   int                  `db:"account_lockout_threshold"`
+	AccountLockoutDurationInSeconds           int                  `db:"account_lockout_duration_in_seconds"`
+	AccountPermanentLockoutThreshold          int                  `db:"account_permanent_lockout_threshold"`
+	FailedLoginDelayInMilliseconds            int                  `db:"failed_login_delay_in_milliseconds"`
+	KeyRotationIntervalInDays                 int                  `db:"key_rotation_interval_in_days"`
+	KeyRotationOverlapInDays                  int                  `db:"key_rotation_overlap_in_days"`
+	ClientRegistrationMode                    string               `db:"client_registration_mode"`
+
+	ClientRegistrationInitialAccessTokenEncrypted []byte `db:"client_registration_initial_access_token_encrypted"`
 }
 
 type PreRegistration struct {
@@ -454,3 +563,107 @@ type GroupPermission struct {
 	GroupId      int64        `db:"group_id"`
 	PermissionId int64        `db:"permission_id"`
 }
+
+type AuditEvent struct {
+	Id        int64         `db:"id" fieldtag:"pk"`
+	CreatedAt sql.NullTime  `db:"created_at"`
+	Event     string        `db:"event"`
+	Actor     string        `db:"actor"`
+	UserId    sql.NullInt64 `db:"user_id"`
+	ClientId  sql.NullInt64 `db:"client_id"`
+	IpAddress string        `db:"ip_address"`
+	RequestId string        `db:"request_id"`
+	UserAgent string        `db:"user_agent"`
+	Details   string        `db:"details"`
+}
+
+type WebAuthnCredential struct {
+	Id           int64        `db:"id" fieldtag:"pk"`
+	CreatedAt    sql.NullTime `db:"created_at"`
+	UpdatedAt    sql.NullTime `db:"updated_at"`
+	UserId       int64        `db:"user_id"`
+	Name         string       `db:"name"`
+	CredentialId string       `db:"credential_id"`
+	PublicKey    []byte       `db:"public_key"`
+	SignCount    int64        `db:"sign_count"`
+	AAGUID       string       `db:"aaguid"`
+	Transports   string       `db:"transports"`
+	LastUsedAt   sql.NullTime `db:"last_used_at"`
+}
+
+type UserRecoveryCode struct {
+	Id        int64        `db:"id" fieldtag:"pk"`
+	CreatedAt sql.NullTime `db:"created_at"`
+	UserId    int64        `db:"user_id"`
+	CodeHash  string       `db:"code_hash"`
+}
+
+type ClientAssertionJti struct {
+	Id        int64        `db:"id" fieldtag:"pk"`
+	CreatedAt sql.NullTime `db:"created_at"`
+	ClientId  int64        `db:"client_id"`
+	Jti       string       `db:"jti"`
+	ExpiresAt sql.NullTime `db:"expires_at"`
+}
+
+type RequestObjectJti struct {
+	Id        int64        `db:"id" fieldtag:"pk"`
+	CreatedAt sql.NullTime `db:"created_at"`
+	ClientId  int64        `db:"client_id"`
+	Jti       string       `db:"jti"`
+	ExpiresAt sql.NullTime `db:"expires_at"`
+}
+
+// DeviceUserCodeAttempt is an invalid user code entered at the device verification page. They're
+// counted per IP address, to limit guessing
+type DeviceUserCodeAttempt struct {
+	Id        int64        `db:"id" fieldtag:"pk"`
+	CreatedAt sql.NullTime `db:"created_at"`
+	IpAddress string       `db:"ip_address"`
+}
+
+type DPoPProofJti struct {
+	Id        int64        `db:"id" fieldtag:"pk"`
+	CreatedAt sql.NullTime `db:"created_at"`
+	Jkt       string       `db:"jkt"`
+	Jti       string       `db:"jti"`
+	ExpiresAt sql.NullTime `db:"expires_at"`
+}
+
+type PushedAuthorizationRequest struct {
+	Id             int64        `db:"id" fieldtag:"pk"`
+	CreatedAt      sql.NullTime `db:"created_at"`
+	ClientId       int64        `db:"client_id"`
+	RequestURIHash string       `db:"request_uri_hash"`
+	Parameters     string       `db:"parameters"`
+	ExpiresAt      sql.NullTime `db:"expires_at"`
+}
+
+type DeviceCode struct {
+	Id                int64         `db:"id" fieldtag:"pk"`
+	CreatedAt         sql.NullTime  `db:"created_at"`
+	UpdatedAt         sql.NullTime  `db:"updated_at"`
+	ClientId          int64         `db:"client_id"`
+	DeviceCodeHash    string        `db:"device_code_hash"`
+	UserCodeHash      string        `db:"user_code_hash"`
+	Scope             string        `db:"scope"`
+	Status            string        `db:"status"`
+	CodeId            sql.NullInt64 `db:"code_id"`
+	IntervalInSeconds int           `db:"interval_in_seconds"`
+	LastPolledAt      sql.NullTime  `db:"last_polled_at"`
+	ExpiresAt         sql.NullTime  `db:"expires_at"`
+}
+
+type BackchannelLogoutDelivery struct {
+	Id                int64        `db:"id" fieldtag:"pk"`
+	CreatedAt         sql.NullTime `db:"created_at"`
+	UpdatedAt         sql.NullTime `db:"updated_at"`
+	ClientId          int64        `db:"client_id"`
+	Subject           string       `db:"subject"`
+	SessionIdentifier string       `db:"session_identifier"`
+	Status            string       `db:"status"`
+	Attempts          int          `db:"attempts"`
+	LastError         string       `db:"last_error"`
+	NextAttemptAt     sql.NullTime `db:"next_attempt_at"`
+	DeliveredAt       sql.NullTime `db:"delivered_at"`
+}
@@ -26,6 +26,8 @@ const (
 	AcrLevel1 AcrLevel = "urn:goiabada:pwd"                // password
 	AcrLevel2 AcrLevel = "urn:goiabada:pwd:otp_ifpossible" // password + otp if enabled
 	AcrLevel3 AcrLevel = "urn:goiabada:pwd:otp_mandatory"  // password + mandatory otp
+
+	AcrLevelPasskey AcrLevel = "urn:goiabada:passkey" // passkey (webauthn)
 )
 
 func (acrl AcrLevel) String() string {
@@ -40,6 +42,8 @@ func AcrLevelFromString(s string) (AcrLevel, error) {
 		return AcrLevel2, nil
 	case AcrLevel3.String():
 		return AcrLevel3, nil
+	case AcrLevelPasskey.String():
+		return AcrLevelPasskey, nil
 	}
 	return "", errors.WithStack(errors.New("invalid ACR level " + s))
 }
@@ -49,10 +53,35 @@ type AuthMethod int
 const (
 	AuthMethodPassword AuthMethod = iota
 	AuthMethodOTP
+	AuthMethodPasskey
 )
 
 func (am AuthMethod) String() string {
-	return []string{"pwd", "otp"}[am]
+	return []string{"pwd", "otp", "passkey"}[am]
+}
+
+type OTPMethod int
+
+const (
+	OTPMethodTOTP OTPMethod = iota
+	OTPMethodEmail
+	OTPMethodSMS
+)
+
+func (m OTPMethod) String() string {
+	return []string{"totp", "email", "sms"}[m]
+}
+
+func OTPMethodFromString(s string) (OTPMethod, error) {
+	switch s {
+	case OTPMethodTOTP.String():
+		return OTPMethodTOTP, nil
+	case OTPMethodEmail.String():
+		return OTPMethodEmail, nil
+	case OTPMethodSMS.String():
+		return OTPMethodSMS, nil
+	}
+	return OTPMethodTOTP, errors.WithStack(errors.New("invalid OTP method " + s))
 }
 
 type Gender int
@@ -104,10 +133,11 @@ const (
 	KeyStateCurrent KeyState = iota
 	KeyStatePrevious
 	KeyStateNext
+	KeyStateRevoked
 )
 
 func (ks KeyState) String() string {
-	return []string{"current", "previous", "next"}[ks]
+	return []string{"current", "previous", "next", "revoked"}[ks]
 }
 
 func KeyStateFromString(s string) (KeyState, error) {
@@ -118,10 +148,44 @@ func KeyStateFromString(s string) (KeyState, error) {
 		return KeyStatePrevious, nil
 	case KeyStateNext.String():
 		return KeyStateNext, nil
+	case KeyStateRevoked.String():
+		return KeyStateRevoked, nil
 	}
 	return KeyStateCurrent, errors.WithStack(errors.New("invalid key state " + s))
 }
 
+type SigningAlgorithm int
+
+const (
+	SigningAlgorithmRS256 SigningAlgorithm = iota
+	SigningAlgorithmES256
+	SigningAlgorithmES384
+	SigningAlgorithmEdDSA
+)
+
+func (sa SigningAlgorithm) String() string {
+	return []string{"RS256", "ES256", "ES384", "EdDSA"}[sa]
+}
+
+func SigningAlgorithmFromString(s string) (SigningAlgorithm, error) {
+	switch s {
+	case SigningAlgorithmRS256.String():
+		return SigningAlgorithmRS256, nil
+	case SigningAlgorithmES256.String():
+		return SigningAlgorithmES256, nil
+	case SigningAlgorithmES384.String():
+		return SigningAlgorithmES384, nil
+	case SigningAlgorithmEdDSA.String():
+		return SigningAlgorithmEdDSA, nil
+	}
+	return SigningAlgorithmRS256, errors.WithStack(errors.New("invalid signing algorithm " + s))
+}
+
+// SigningAlgorithms returns the algorithms that can be used to sign tokens
+func SigningAlgorithms() []SigningAlgorithm {
+	return []SigningAlgorithm{SigningAlgorithmRS256, SigningAlgorithmES256, SigningAlgorithmES384, SigningAlgorithmEdDSA}
+}
+
 type SMTPEncryption int
 
 const (
@@ -169,3 +233,105 @@ func ThreeStateSettingFromString(s string) (ThreeStateSetting, error) {
 	}
 	return ThreeStateSettingOn, errors.WithStack(errors.New("invalid three state setting " + s))
 }
+
+type TokenEndpointAuthMethod int
+
+const (
+	TokenEndpointAuthMethodClientSecretBasic TokenEndpointAuthMethod = iota
+	TokenEndpointAuthMethodClientSecretPost
+	TokenEndpointAuthMethodPrivateKeyJwt
+	TokenEndpointAuthMethodTLSClientAuth
+	TokenEndpointAuthMethodSelfSignedTLSClientAuth
+)
+
+func (m TokenEndpointAuthMethod) String() string {
+	return []string{"client_secret_basic", "client_secret_post", "private_key_jwt", "tls_client_auth", "self_signed_tls_client_auth"}[m]
+}
+
+func TokenEndpointAuthMethodFromString(s string) (TokenEndpointAuthMethod, error) {
+	switch s {
+	case TokenEndpointAuthMethodClientSecretBasic.String():
+		return TokenEndpointAuthMethodClientSecretBasic, nil
+	case TokenEndpointAuthMethodClientSecretPost.String():
+		return TokenEndpointAuthMethodClientSecretPost, nil
+	case TokenEndpointAuthMethodPrivateKeyJwt.String():
+		return TokenEndpointAuthMethodPrivateKeyJwt, nil
+	case TokenEndpointAuthMethodTLSClientAuth.String():
+		return TokenEndpointAuthMethodTLSClientAuth, nil
+	case TokenEndpointAuthMethodSelfSignedTLSClientAuth.String():
+		return TokenEndpointAuthMethodSelfSignedTLSClientAuth, nil
+	}
+	return TokenEndpointAuthMethodClientSecretBasic, errors.WithStack(errors.New("invalid token endpoint auth method " + s))
+}
+
+type DPoPMode int
+
+const (
+	DPoPModeDisabled DPoPMode = iota
+	DPoPModeOptional
+	DPoPModeRequired
+)
+
+func (m DPoPMode) String() string {
+	return []string{"disabled", "optional", "required"}[m]
+}
+
+func DPoPModeFromString(s string) (DPoPMode, error) {
+	switch s {
+	case DPoPModeDisabled.String():
+		return DPoPModeDisabled, nil
+	case DPoPModeOptional.String():
+		return DPoPModeOptional, nil
+	case DPoPModeRequired.String():
+		return DPoPModeRequired, nil
+	}
+	return DPoPModeDisabled, errors.WithStack(errors.New("invalid DPoP mode " + s))
+}
+
+type DeviceCodeStatus int
+
+const (
+	DeviceCodeStatusPending DeviceCodeStatus = iota
+	DeviceCodeStatusApproved
+	DeviceCodeStatusDenied
+)
+
+func (s DeviceCodeStatus) String() string {
+	return []string{"pending", "approved", "denied"}[s]
+}
+
+type BackchannelLogoutStatus int
+
+const (
+	BackchannelLogoutStatusPending BackchannelLogoutStatus = iota
+	BackchannelLogoutStatusDelivered
+	BackchannelLogoutStatusFailed
+)
+
+func (s BackchannelLogoutStatus) String() string {
+	return []string{"pending", "delivered", "failed"}[s]
+}
+
+type ClientRegistrationMode int
+
+const (
+	ClientRegistrationModeDisabled ClientRegistrationMode = iota
+	ClientRegistrationModeInitialAccessToken
+	ClientRegistrationModeOpen
+)
+
+func (m ClientRegistrationMode) String() string {
+	return []string{"disabled", "initial_access_token", "open"}[m]
+}
+
+func ClientRegistrationModeFromString(s string) (ClientRegistrationMode, error) {
+	switch s {
+	case ClientRegistrationModeDisabled.String():
+		return ClientRegistrationModeDisabled, nil
+	case ClientRegistrationModeInitialAccessToken.String():
+		return ClientRegistrationModeInitialAccessToken, nil
+	case ClientRegistrationModeOpen.String():
+		return ClientRegistrationModeOpen, nil
+	}
+	return ClientRegistrationModeDisabled, errors.WithStack(errors.New("invalid client registration mode " + s))
+}
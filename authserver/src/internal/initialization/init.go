@@ -52,6 +52,20 @@ func InitViper() {
 	viper.SetDefault("RateLimiter.MaxRequests", 50)
 	viper.SetDefault("RateLimiter.WindowSizeInSeconds", 10)
 
+	viper.SetDefault("MTLS.Enabled", false)
+
+	viper.SetDefault("Auditing.Database.Enabled", true)
+	viper.SetDefault("Auditing.QueueSize", 1000)
+	viper.SetDefault("Auditing.File.Path", "./audit/audit.log")
+	viper.SetDefault("Auditing.File.MaxSizeInMB", 100)
+	viper.SetDefault("Auditing.File.MaxBackups", 5)
+	viper.SetDefault("Auditing.Syslog.Network", "udp")
+	viper.SetDefault("Auditing.Syslog.Address", "localhost:514")
+	viper.SetDefault("Auditing.Syslog.AppName", "goiabada")
+	viper.SetDefault("Auditing.Webhook.TimeoutInSeconds", 10)
+	viper.SetDefault("Auditing.Webhook.MaxRetries", 5)
+	viper.SetDefault("Auditing.Webhook.InitialBackoffInMilliseconds", 1000)
+
 	slog.Info("viper configuration initialized")
 }
 
@@ -1,33 +1,294 @@
 package lib
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"expvar"
 	"fmt"
 	"log/slog"
+	"sync"
+	"time"
 
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 )
 
 type AuditEvent struct {
-	Event   string                 `json:"event"`
-	Details map[string]interface{} `json:"details"`
+	Timestamp time.Time              `json:"timestamp"`
+	Event     string                 `json:"event"`
+	Actor     string                 `json:"actor,omitempty"`
+	IpAddress string                 `json:"ipAddress,omitempty"`
+	RequestId string                 `json:"requestId,omitempty"`
+	UserAgent string                 `json:"userAgent,omitempty"`
+	Details   map[string]interface{} `json:"details"`
 }
 
-func LogAudit(event string, details map[string]interface{}) {
-	auditEvent := AuditEvent{
-		Event:   event,
-		Details: details,
+// AuditSink is a destination for audit events (console, database, file, syslog, webhook...)
+type AuditSink interface {
+	Name() string
+	Write(auditEvent *AuditEvent) error
+}
+
+// AuditRequestInfo holds the details of the http request that triggered an audit event
+type AuditRequestInfo struct {
+	IpAddress string
+	RequestId string
+	UserAgent string
+}
+
+type auditRequestInfoKey struct{}
+
+func WithAuditRequestInfo(ctx context.Context, requestInfo AuditRequestInfo) context.Context {
+	return context.WithValue(ctx, auditRequestInfoKey{}, requestInfo)
+}
+
+type AuditEventStore interface {
+	CreateAuditEvent(tx *sql.Tx, auditEvent *entities.AuditEvent) error
+}
+
+// auditDroppedEvents counts, per sink, the audit events that could not be delivered
+// (queue full or delivery failed after all retries)
+var auditDroppedEvents = expvar.NewMap("audit_dropped_events")
+
+// maxPendingAuditEvents is how many events are kept while the sinks are not initialized yet
+const maxPendingAuditEvents = 1000
+
+var (
+	auditSinksMutex       sync.Mutex
+	auditSinks            []AuditSink
+	auditSinksInitialized bool
+	pendingAuditEvents    []*AuditEvent
+)
+
+// InitAuditSinks configures the audit sinks based on the Auditing.* settings. The database
+// sink uses the given store; every sink other than the console is asynchronous
+func InitAuditSinks(store AuditEventStore) error {
+
+	sinks := []AuditSink{}
+
+	if viper.GetBool("Auditing.ConsoleLog.Enabled") {
+		sinks = append(sinks, &consoleAuditSink{})
+	}
+
+	queueSize := viper.GetInt("Auditing.QueueSize")
+
+	if viper.GetBool("Auditing.Database.Enabled") && store != nil {
+		// written in the background, so the insert is not on the request path
+		sinks = append(sinks, NewAsyncAuditSink(&databaseAuditSink{store: store}, queueSize))
+	}
+
+	if viper.GetBool("Auditing.File.Enabled") {
+		fileSink, err := NewAuditFileSink(
+			viper.GetString("Auditing.File.Path"),
+			viper.GetInt64("Auditing.File.MaxSizeInMB")*1024*1024,
+			viper.GetInt("Auditing.File.MaxBackups"))
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, NewAsyncAuditSink(fileSink, queueSize))
+	}
+
+	if viper.GetBool("Auditing.Syslog.Enabled") {
+		syslogSink, err := NewAuditSyslogSink(
+			viper.GetString("Auditing.Syslog.Network"),
+			viper.GetString("Auditing.Syslog.Address"),
+			viper.GetString("Auditing.Syslog.AppName"))
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, NewAsyncAuditSink(syslogSink, queueSize))
+	}
+
+	if viper.GetBool("Auditing.Webhook.Enabled") {
+		webhookSink, err := NewAuditWebhookSink(
+			viper.GetString("Auditing.Webhook.Url"),
+			viper.GetString("Auditing.Webhook.AuthorizationHeader"),
+			time.Duration(viper.GetInt("Auditing.Webhook.TimeoutInSeconds"))*time.Second,
+			viper.GetInt("Auditing.Webhook.MaxRetries"),
+			time.Duration(viper.GetInt("Auditing.Webhook.InitialBackoffInMilliseconds"))*time.Millisecond)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, NewAsyncAuditSink(webhookSink, queueSize))
+	}
+
+	for _, sink := range sinks {
+		slog.Info(fmt.Sprintf("audit sink enabled: %v", sink.Name()))
+	}
+
+	// deliver the events that were logged before the sinks were ready
+	auditSinksMutex.Lock()
+	auditSinks = sinks
+	auditSinksInitialized = true
+	pending := pendingAuditEvents
+	pendingAuditEvents = nil
+	auditSinksMutex.Unlock()
+
+	for _, auditEvent := range pending {
+		writeAuditEvent(sinks, auditEvent)
+	}
+	return nil
+}
+
+// GetAuditDroppedEvents returns the number of audit events dropped by each sink since startup
+func GetAuditDroppedEvents() map[string]int64 {
+	result := map[string]int64{}
+	auditDroppedEvents.Do(func(kv expvar.KeyValue) {
+		if v, ok := kv.Value.(*expvar.Int); ok {
+			result[kv.Key] = v.Value()
+		}
+	})
+	return result
+}
+
+func LogAudit(ctx context.Context, event string, details map[string]interface{}) {
+	auditEvent := &AuditEvent{
+		Timestamp: time.Now().UTC(),
+		Event:     event,
+		Details:   details,
+	}
+
+	if ctx != nil {
+		if requestInfo, ok := ctx.Value(auditRequestInfoKey{}).(AuditRequestInfo); ok {
+			auditEvent.IpAddress = requestInfo.IpAddress
+			auditEvent.RequestId = requestInfo.RequestId
+			auditEvent.UserAgent = requestInfo.UserAgent
+		}
+	}
+
+	// the actor is the logged in user performing the action, when there is one
+	if loggedInUser, ok := details["loggedInUser"].(string); ok {
+		auditEvent.Actor = loggedInUser
+	}
+
+	auditSinksMutex.Lock()
+	if !auditSinksInitialized {
+		if len(pendingAuditEvents) < maxPendingAuditEvents {
+			pendingAuditEvents = append(pendingAuditEvents, auditEvent)
+		} else {
+			auditDroppedEvents.Add("pending", 1)
+		}
+		auditSinksMutex.Unlock()
+		return
+	}
+	sinks := auditSinks
+	auditSinksMutex.Unlock()
+
+	writeAuditEvent(sinks, auditEvent)
+}
+
+func writeAuditEvent(sinks []AuditSink, auditEvent *AuditEvent) {
+	for _, sink := range sinks {
+		err := sink.Write(auditEvent)
+		if err != nil {
+			slog.Error(fmt.Sprintf("audit sink %v was unable to write event %v: %+v", sink.Name(), auditEvent.Event, err))
+		}
 	}
+}
+
+type consoleAuditSink struct{}
 
+func (s *consoleAuditSink) Name() string {
+	return "console"
+}
+
+func (s *consoleAuditSink) Write(auditEvent *AuditEvent) error {
 	detailsJson, err := json.Marshal(auditEvent.Details)
 	if err != nil {
-		slog.Error(fmt.Sprintf("failed to marshal audit details: %+v", err))
 		slog.Info(fmt.Sprintf("audit: %v; (unable to marshal details)", auditEvent.Event))
-		return
+		return errors.Wrap(err, "failed to marshal audit details")
+	}
+
+	slog.Info(fmt.Sprintf("audit: %v; details: %v", auditEvent.Event, string(detailsJson)))
+	return nil
+}
+
+type databaseAuditSink struct {
+	store AuditEventStore
+}
+
+func (s *databaseAuditSink) Name() string {
+	return "database"
+}
+
+func (s *databaseAuditSink) Write(auditEvent *AuditEvent) error {
+	detailsJson, err := json.Marshal(auditEvent.Details)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal audit details")
 	}
 
-	consoleLogEnabled := viper.GetBool("Auditing.ConsoleLog.Enabled")
-	if consoleLogEnabled {
-		slog.Info(fmt.Sprintf("audit: %v; details: %v", auditEvent.Event, string(detailsJson)))
+	entity := &entities.AuditEvent{
+		Event:     auditEvent.Event,
+		Actor:     auditEvent.Actor,
+		IpAddress: auditEvent.IpAddress,
+		RequestId: auditEvent.RequestId,
+		UserAgent: auditEvent.UserAgent,
+		Details:   string(detailsJson),
+	}
+	if userId, ok := auditDetailToInt64(auditEvent.Details["userId"]); ok {
+		entity.UserId = sql.NullInt64{Int64: userId, Valid: true}
+	}
+	if clientId, ok := auditDetailToInt64(auditEvent.Details["clientId"]); ok {
+		entity.ClientId = sql.NullInt64{Int64: clientId, Valid: true}
+	}
+
+	return s.store.CreateAuditEvent(nil, entity)
+}
+
+func auditDetailToInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case uint:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+// AsyncAuditSink delivers the audit events to the wrapped sink in the background, through
+// a bounded queue. When the queue is full the event is dropped, so a slow or unavailable
+// sink never blocks the request that generated the event
+type AsyncAuditSink struct {
+	sink  AuditSink
+	queue chan *AuditEvent
+}
+
+func NewAsyncAuditSink(sink AuditSink, queueSize int) *AsyncAuditSink {
+	if queueSize < 1 {
+		queueSize = 1000
+	}
+	asyncSink := &AsyncAuditSink{
+		sink:  sink,
+		queue: make(chan *AuditEvent, queueSize),
+	}
+	go asyncSink.run()
+	return asyncSink
+}
+
+func (s *AsyncAuditSink) Name() string {
+	return s.sink.Name()
+}
+
+func (s *AsyncAuditSink) Write(auditEvent *AuditEvent) error {
+	select {
+	case s.queue <- auditEvent:
+		return nil
+	default:
+		auditDroppedEvents.Add(s.sink.Name(), 1)
+		return errors.WithStack(errors.New("audit queue is full, the event was dropped"))
+	}
+}
+
+func (s *AsyncAuditSink) run() {
+	for auditEvent := range s.queue {
+		err := s.sink.Write(auditEvent)
+		if err != nil {
+			auditDroppedEvents.Add(s.sink.Name(), 1)
+			slog.Error(fmt.Sprintf("audit sink %v was unable to write event %v: %+v", s.sink.Name(), auditEvent.Event, err))
+		}
 	}
 }
@@ -0,0 +1,127 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// AuditFileSink writes the audit events as JSON lines. When the file reaches the max
+// size it's rotated to <path>.1, <path>.2 and so on, keeping up to maxBackups files
+type AuditFileSink struct {
+	path           string
+	maxSizeInBytes int64
+	maxBackups     int
+
+	mutex sync.Mutex
+	file  *os.File
+	size  int64
+}
+
+func NewAuditFileSink(path string, maxSizeInBytes int64, maxBackups int) (*AuditFileSink, error) {
+	if len(path) == 0 {
+		return nil, errors.WithStack(errors.New("the audit file path is required"))
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), 0750)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create the audit file directory")
+	}
+
+	sink := &AuditFileSink{
+		path:           path,
+		maxSizeInBytes: maxSizeInBytes,
+		maxBackups:     maxBackups,
+	}
+
+	err = sink.open()
+	if err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+func (s *AuditFileSink) Name() string {
+	return "file"
+}
+
+func (s *AuditFileSink) Write(auditEvent *AuditEvent) error {
+	line, err := json.Marshal(auditEvent)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal audit event")
+	}
+	line = append(line, '\n')
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.maxSizeInBytes > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxSizeInBytes {
+		err = s.rotate()
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		return errors.Wrap(err, "unable to write to the audit file")
+	}
+	return nil
+}
+
+func (s *AuditFileSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.file.Close()
+}
+
+func (s *AuditFileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return errors.Wrap(err, "unable to open the audit file")
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return errors.Wrap(err, "unable to stat the audit file")
+	}
+
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+func (s *AuditFileSink) rotate() error {
+	err := s.file.Close()
+	if err != nil {
+		return errors.Wrap(err, "unable to close the audit file")
+	}
+
+	if s.maxBackups > 0 {
+		// shift the existing backups, the oldest one is overwritten
+		for i := s.maxBackups - 1; i >= 1; i-- {
+			src := fmt.Sprintf("%v.%v", s.path, i)
+			if _, err := os.Stat(src); err == nil {
+				err = os.Rename(src, fmt.Sprintf("%v.%v", s.path, i+1))
+				if err != nil {
+					return errors.Wrap(err, "unable to rotate the audit file")
+				}
+			}
+		}
+		err = os.Rename(s.path, s.path+".1")
+	} else {
+		err = os.Remove(s.path)
+	}
+	if err != nil {
+		return errors.Wrap(err, "unable to rotate the audit file")
+	}
+
+	return s.open()
+}
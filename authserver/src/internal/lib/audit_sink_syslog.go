@@ -0,0 +1,132 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// facility 10 (security/authorization messages), severity 6 (informational)
+	auditSyslogPriority = 10*8 + 6
+
+	auditSyslogMaxMsgIdLength = 32
+)
+
+// AuditSyslogSink sends the audit events to a syslog server, formatted according
+// to RFC 5424. Over TCP the messages are framed using octet counting (RFC 6587)
+type AuditSyslogSink struct {
+	network  string
+	address  string
+	appName  string
+	hostname string
+
+	mutex sync.Mutex
+	conn  net.Conn
+}
+
+func NewAuditSyslogSink(network string, address string, appName string) (*AuditSyslogSink, error) {
+	if network != "udp" && network != "tcp" {
+		return nil, errors.WithStack(fmt.Errorf("unsupported syslog network: %v (expected udp or tcp)", network))
+	}
+	if len(address) == 0 {
+		return nil, errors.WithStack(errors.New("the syslog address is required"))
+	}
+	if len(appName) == 0 {
+		appName = "goiabada"
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || len(hostname) == 0 {
+		hostname = "-"
+	}
+
+	return &AuditSyslogSink{
+		network:  network,
+		address:  address,
+		appName:  appName,
+		hostname: hostname,
+	}, nil
+}
+
+func (s *AuditSyslogSink) Name() string {
+	return "syslog"
+}
+
+func (s *AuditSyslogSink) Write(auditEvent *AuditEvent) error {
+	msg, err := s.formatMessage(auditEvent)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err = s.send(msg)
+	if err != nil {
+		// the connection may have been closed by the server, try again with a new one
+		s.closeConn()
+		err = s.send(msg)
+		if err != nil {
+			s.closeConn()
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *AuditSyslogSink) formatMessage(auditEvent *AuditEvent) (string, error) {
+	eventJson, err := json.Marshal(auditEvent)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal audit event")
+	}
+
+	msgId := auditEvent.Event
+	if len(msgId) > auditSyslogMaxMsgIdLength {
+		msgId = msgId[:auditSyslogMaxMsgIdLength]
+	}
+
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+	return fmt.Sprintf("<%d>1 %v %v %v %d %v - %v",
+		auditSyslogPriority,
+		auditEvent.Timestamp.UTC().Format(time.RFC3339Nano),
+		s.hostname,
+		s.appName,
+		os.Getpid(),
+		msgId,
+		string(eventJson)), nil
+}
+
+func (s *AuditSyslogSink) send(msg string) error {
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.network, s.address, 10*time.Second)
+		if err != nil {
+			return errors.Wrap(err, "unable to connect to the syslog server")
+		}
+		s.conn = conn
+	}
+
+	frame := msg
+	if s.network == "tcp" {
+		frame = fmt.Sprintf("%d %v", len(msg), msg)
+	}
+
+	s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := s.conn.Write([]byte(frame))
+	if err != nil {
+		return errors.Wrap(err, "unable to send message to the syslog server")
+	}
+	return nil
+}
+
+func (s *AuditSyslogSink) closeConn() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
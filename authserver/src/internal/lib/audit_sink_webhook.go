@@ -0,0 +1,138 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const auditWebhookMaxBackoff = time.Minute
+
+// auditWebhookMaxPendingRetries caps the events waiting for a retry, so an endpoint that
+// is down for a long time doesn't make them pile up in memory
+const auditWebhookMaxPendingRetries = 1000
+
+// AuditWebhookSink posts each audit event as JSON to an HTTP endpoint. Failed
+// deliveries are retried with exponential backoff, in the background, so that
+// the events behind them are not held up
+type AuditWebhookSink struct {
+	url                 string
+	authorizationHeader string
+	maxRetries          int
+	initialBackoff      time.Duration
+	httpClient          *http.Client
+	pendingRetries      atomic.Int32
+}
+
+func NewAuditWebhookSink(webhookUrl string, authorizationHeader string, timeout time.Duration,
+	maxRetries int, initialBackoff time.Duration) (*AuditWebhookSink, error) {
+
+	_, err := url.ParseRequestURI(webhookUrl)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid audit webhook url")
+	}
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	if initialBackoff <= 0 {
+		initialBackoff = time.Second
+	}
+
+	return &AuditWebhookSink{
+		url:                 webhookUrl,
+		authorizationHeader: authorizationHeader,
+		maxRetries:          maxRetries,
+		initialBackoff:      initialBackoff,
+		httpClient: &http.Client{
+			Timeout: timeout,
+		},
+	}, nil
+}
+
+func (s *AuditWebhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *AuditWebhookSink) Write(auditEvent *AuditEvent) error {
+	body, err := json.Marshal(auditEvent)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal audit event")
+	}
+
+	retryable, err := s.post(body)
+	if err == nil {
+		return nil
+	}
+	if !retryable || s.maxRetries == 0 {
+		return errors.Wrap(err, "audit webhook delivery failed after 1 attempt(s)")
+	}
+	if s.pendingRetries.Add(1) > auditWebhookMaxPendingRetries {
+		s.pendingRetries.Add(-1)
+		return errors.Wrap(err, "audit webhook delivery failed, and too many events are already waiting for a retry")
+	}
+	s.scheduleRetry(auditEvent.Event, body, 1, s.initialBackoff)
+	return nil
+}
+
+// scheduleRetry makes the given attempt after the backoff. When the last retry fails, the
+// event is dropped
+func (s *AuditWebhookSink) scheduleRetry(event string, body []byte, attempt int, backoff time.Duration) {
+	time.AfterFunc(backoff, func() {
+		retryable, err := s.post(body)
+		if err != nil && retryable && attempt < s.maxRetries {
+			backoff *= 2
+			if backoff > auditWebhookMaxBackoff {
+				backoff = auditWebhookMaxBackoff
+			}
+			s.scheduleRetry(event, body, attempt+1, backoff)
+			return
+		}
+
+		s.pendingRetries.Add(-1)
+		if err != nil {
+			auditDroppedEvents.Add(s.Name(), 1)
+			slog.Error(fmt.Sprintf("audit sink %v was unable to write event %v: %+v", s.Name(), event,
+				errors.Wrap(err, fmt.Sprintf("audit webhook delivery failed after %v attempt(s)", attempt+1))))
+		}
+	})
+}
+
+// post sends the event once. It returns whether the failure (if any) is worth retrying
+func (s *AuditWebhookSink) post(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, errors.Wrap(err, "unable to create the audit webhook request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(s.authorizationHeader) > 0 {
+		req.Header.Set("Authorization", s.authorizationHeader)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return true, errors.Wrap(err, "unable to reach the audit webhook")
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	err = errors.WithStack(fmt.Errorf("the audit webhook returned status code %v", resp.StatusCode))
+	retryable := resp.StatusCode >= 500 ||
+		resp.StatusCode == http.StatusRequestTimeout ||
+		resp.StatusCode == http.StatusTooManyRequests
+	return retryable, err
+}
@@ -0,0 +1,86 @@
+package lib
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// GetClientRegistrationAllowedHttpHosts returns the hosts, besides the loopback addresses, that
+// clients registered through the client registration endpoint may use in plain http redirect
+// URIs. They're read from GOIABADA_CLIENTREGISTRATION_ALLOWEDHTTPHOSTS, separated by commas
+func GetClientRegistrationAllowedHttpHosts() []string {
+	hosts := []string{}
+	for _, host := range strings.Split(viper.GetString("ClientRegistration.AllowedHttpHosts"), ",") {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if len(host) > 0 && !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// IsLoopbackHost returns true for localhost and for the loopback IP addresses
+func IsLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// nonPublicIPv4Networks are the ranges that are not public but are not covered by the net.IP
+// methods: the shared address space of carrier-grade NAT and "this network"
+var nonPublicIPv4Networks = []*net.IPNet{
+	{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)},
+	{IP: net.IPv4(0, 0, 0, 0), Mask: net.CIDRMask(8, 32)},
+}
+
+// IsPublicIP returns false for the loopback, private, shared, link-local, multicast and unspecified
+// addresses, which a server must not be tricked into calling
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, network := range nonPublicIPv4Networks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewPublicOnlyHttpClient returns an http client that refuses to connect to addresses that are
+// not public. The address is checked when connecting, after the host name is resolved, so a
+// host name that resolves to an internal address is refused as well
+func NewPublicOnlyHttpClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !IsPublicIP(ip) {
+				return errors.WithStack(fmt.Errorf("the address %v is not a public address", host))
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
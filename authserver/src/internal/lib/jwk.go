@@ -0,0 +1,139 @@
+package lib
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	b64 "encoding/base64"
+
+	"github.com/pkg/errors"
+)
+
+// minRSAKeySize is the smallest RSA modulus, in bits, accepted in a JSON Web Key
+const minRSAKeySize = 2048
+
+// JSONWebKey is a public key in the JSON Web Key format (RFC 7517)
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// ParseJSONWebKeySet parses a JWKS document, checking that every key in it can be used
+func ParseJSONWebKeySet(data []byte) (*JSONWebKeySet, error) {
+	var keySet JSONWebKeySet
+	err := json.Unmarshal(data, &keySet)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse the JWKS")
+	}
+	if len(keySet.Keys) == 0 {
+		return nil, errors.WithStack(errors.New("the JWKS does not contain any keys"))
+	}
+	for i := range keySet.Keys {
+		_, err = keySet.Keys[i].PublicKey()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &keySet, nil
+}
+
+// PublicKey returns the key as an *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey
+func (jwk *JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := b64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil || len(n) == 0 {
+			return nil, errors.WithStack(fmt.Errorf("invalid modulus in the RSA key %v", jwk.Kid))
+		}
+		e, err := b64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.WithStack(fmt.Errorf("invalid exponent in the RSA key %v", jwk.Kid))
+		}
+		modulus := new(big.Int).SetBytes(n)
+		if modulus.BitLen() < minRSAKeySize {
+			return nil, errors.WithStack(fmt.Errorf("the RSA key %v is too small, it must be at least %v bits", jwk.Kid, minRSAKeySize))
+		}
+		return &rsa.PublicKey{
+			N: modulus,
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		var ecdhCurve ecdh.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve, ecdhCurve = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, ecdhCurve = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, ecdhCurve = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, errors.WithStack(fmt.Errorf("unsupported curve %v in the EC key %v", jwk.Crv, jwk.Kid))
+		}
+		x, errX := b64.RawURLEncoding.DecodeString(jwk.X)
+		y, errY := b64.RawURLEncoding.DecodeString(jwk.Y)
+		coordinateSize := (curve.Params().BitSize + 7) / 8
+		if errX != nil || errY != nil || len(x) != coordinateSize || len(y) != coordinateSize {
+			return nil, errors.WithStack(fmt.Errorf("invalid coordinates in the EC key %v", jwk.Kid))
+		}
+
+		// uncompressed point: 0x04 || x || y. Parsing it checks that the point is on the curve
+		point := append(append([]byte{4}, x...), y...)
+		_, err := ecdhCurve.NewPublicKey(point)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("invalid point in the EC key %v", jwk.Kid))
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, errors.WithStack(fmt.Errorf("unsupported curve %v in the OKP key %v", jwk.Crv, jwk.Kid))
+		}
+		x, err := b64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.WithStack(fmt.Errorf("invalid public key in the OKP key %v", jwk.Kid))
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, errors.WithStack(fmt.Errorf("unsupported key type %v in the key %v", jwk.Kty, jwk.Kid))
+}
+
+// Thumbprint returns the base64url encoded SHA-256 thumbprint of the key (RFC 7638), computed
+// over its required members in lexicographic order
+func (jwk *JSONWebKey) Thumbprint() (string, error) {
+	var members string
+	switch jwk.Kty {
+	case "RSA":
+		members = fmt.Sprintf(`{"e":%q,"kty":%q,"n":%q}`, jwk.E, jwk.Kty, jwk.N)
+	case "EC":
+		members = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, jwk.Crv, jwk.Kty, jwk.X, jwk.Y)
+	case "OKP":
+		members = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q}`, jwk.Crv, jwk.Kty, jwk.X)
+	default:
+		return "", errors.WithStack(fmt.Errorf("unsupported key type %v in the key %v", jwk.Kty, jwk.Kid))
+	}
+	hash := sha256.Sum256([]byte(members))
+	return b64.RawURLEncoding.EncodeToString(hash[:]), nil
+}
@@ -0,0 +1,64 @@
+package lib
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// the master key is a key-encryption key that wraps the AES encryption key stored in the
+// settings, so that a copy of the database alone doesn't reveal the secrets it protects
+var masterKeyMutex sync.Mutex
+var masterKeyLoaded bool
+var masterKey []byte
+
+// GetMasterKey returns the master key read from the file in GOIABADA_MASTER_KEY_FILE. It's
+// read the first time it's needed, and is nil when no master key file is configured
+func GetMasterKey() ([]byte, error) {
+	masterKeyMutex.Lock()
+	defer masterKeyMutex.Unlock()
+
+	if !masterKeyLoaded {
+		masterKeyFile := viper.GetString("Master.Key.File")
+		if len(masterKeyFile) > 0 {
+			key, err := ReadMasterKeyFile(masterKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			masterKey = key
+		}
+		masterKeyLoaded = true
+	}
+	return masterKey, nil
+}
+
+// SetMasterKey replaces the master key used from now on. A nil key disables the wrapping
+func SetMasterKey(key []byte) {
+	masterKeyMutex.Lock()
+	defer masterKeyMutex.Unlock()
+
+	masterKey = key
+	masterKeyLoaded = true
+}
+
+// ReadMasterKeyFile reads a master key from a file holding 32 random bytes, base64 encoded
+func ReadMasterKeyFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("unable to read the master key file %v", path))
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil, errors.Wrap(err, "the master key must be base64 encoded")
+	}
+	if len(key) != 32 {
+		return nil, errors.WithStack(fmt.Errorf("the master key must have 32 bytes, but it has %v bytes", len(key)))
+	}
+	return key, nil
+}
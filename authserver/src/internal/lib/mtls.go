@@ -0,0 +1,66 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+
+	b64 "encoding/base64"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// the CA bundle holds the certificate authorities trusted to issue the client certificates
+// of the clients that authenticate with tls_client_auth (RFC 8705)
+var mtlsCACertPoolMutex sync.Mutex
+var mtlsCACertPoolLoaded bool
+var mtlsCACertPool *x509.CertPool
+
+// IsMTLSEnabled returns true if the server asks the clients for a certificate during the TLS
+// handshake. The certificate is optional, so browsers are not affected
+func IsMTLSEnabled() bool {
+	return IsHttpsEnabled() && viper.GetBool("MTLS.Enabled")
+}
+
+// GetMTLSCACertPool returns the CA bundle read from the file in GOIABADA_MTLS_CAFILE. It's read
+// the first time it's needed, and is nil when no CA file is configured
+func GetMTLSCACertPool() (*x509.CertPool, error) {
+	mtlsCACertPoolMutex.Lock()
+	defer mtlsCACertPoolMutex.Unlock()
+
+	if !mtlsCACertPoolLoaded {
+		caFile := viper.GetString("MTLS.CAFile")
+		if len(caFile) > 0 {
+			caPEM, err := os.ReadFile(caFile)
+			if err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("unable to read the CA file %v", caFile))
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(caPEM) {
+				return nil, errors.WithStack(fmt.Errorf("no certificates found in the CA file %v", caFile))
+			}
+			mtlsCACertPool = pool
+		}
+		mtlsCACertPoolLoaded = true
+	}
+	return mtlsCACertPool, nil
+}
+
+// SetMTLSCACertPool replaces the CA bundle used from now on
+func SetMTLSCACertPool(pool *x509.CertPool) {
+	mtlsCACertPoolMutex.Lock()
+	defer mtlsCACertPoolMutex.Unlock()
+
+	mtlsCACertPool = pool
+	mtlsCACertPoolLoaded = true
+}
+
+// GetCertificateThumbprint returns the base64url encoded SHA-256 hash of the DER encoding of
+// the certificate, as used in the x5t#S256 confirmation method
+func GetCertificateThumbprint(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.Raw)
+	return b64.RawURLEncoding.EncodeToString(hash[:])
+}
@@ -4,6 +4,8 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"strings"
+	"unicode"
 )
 
 func GenerateSecureRandomString(length int) string {
@@ -40,3 +42,67 @@ func GeneratePKCECodeChallenge(codeVerifier string) string {
 	bytes := sha256.Sum256([]byte(codeVerifier))
 	return base64.RawURLEncoding.EncodeToString(bytes[:])
 }
+
+// GenerateRecoveryCode returns a one-time recovery code in the format xxxxx-xxxxx. The
+// alphabet has 32 symbols (no i, l, o, u), so every symbol is equally likely
+func GenerateRecoveryCode() string {
+	const chars = "0123456789abcdefghjkmnpqrstvwxyz"
+	bytes := make([]byte, 10)
+
+	if _, err := rand.Read(bytes); err != nil {
+		return ""
+	}
+
+	for i, b := range bytes {
+		bytes[i] = chars[b%byte(len(chars))]
+	}
+
+	return string(bytes[:5]) + "-" + string(bytes[5:])
+}
+
+// NormalizeRecoveryCode removes separators and whitespace and lowercases the code, so that
+// it can be compared regardless of how the user typed it
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, code)
+}
+
+// GenerateUserCode returns a user code for the device authorization grant, in the format
+// XXXX-XXXX. The alphabet has 20 consonants (RFC 8628, section 6.1), so that the code is
+// easy to type and can't spell words. Random bytes that would bias the alphabet are discarded
+func GenerateUserCode() string {
+	const chars = "BCDFGHJKLMNPQRSTVWXZ"
+	const maxByte = 256 - 256%len(chars)
+	code := make([]byte, 0, 8)
+
+	buffer := make([]byte, 16)
+	for len(code) < 8 {
+		if _, err := rand.Read(buffer); err != nil {
+			return ""
+		}
+		for _, b := range buffer {
+			if int(b) < maxByte && len(code) < 8 {
+				code = append(code, chars[int(b)%len(chars)])
+			}
+		}
+	}
+
+	return string(code[:4]) + "-" + string(code[4:])
+}
+
+// NormalizeUserCode removes separators and whitespace and uppercases the user code, so that
+// it can be compared regardless of how the user typed it
+func NormalizeUserCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, code)
+}
@@ -0,0 +1,37 @@
+package lib
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// GetBrowserState returns the opaque value that represents the user session in the browser
+// state cookie, which is read by the check_session_iframe
+func GetBrowserState(sessionIdentifier string) (string, error) {
+	return HashString(sessionIdentifier)
+}
+
+// GetSessionState returns the session_state value of OpenID Connect Session Management.
+// The check_session_iframe computes the same hash in the browser, with the salt that
+// follows the dot
+func GetSessionState(clientIdentifier string, origin string, browserState string, salt string) (string, error) {
+	hash, err := HashString(clientIdentifier + " " + origin + " " + browserState + " " + salt)
+	if err != nil {
+		return "", err
+	}
+	return hash + "." + salt, nil
+}
+
+// GetOrigin returns the scheme, host and port of an URI, in lower case
+func GetOrigin(uri string) (string, error) {
+	u, err := url.ParseRequestURI(uri)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to parse uri")
+	}
+	if len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return "", errors.WithStack(errors.New("the uri has no scheme or host"))
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host), nil
+}
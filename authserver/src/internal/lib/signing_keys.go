@@ -0,0 +1,217 @@
+package lib
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+
+	b64 "encoding/base64"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/pkg/errors"
+)
+
+// GenerateSigningKeyPair creates a new token signing key pair, with a new key identifier. The
+// private key is encrypted with the AES encryption key
+func GenerateSigningKeyPair(algorithm enums.SigningAlgorithm, state enums.KeyState, aesEncryptionKey []byte) (*entities.KeyPair, error) {
+
+	kid := uuid.New().String()
+	keyPair := &entities.KeyPair{
+		State:         state.String(),
+		KeyIdentifier: kid,
+		Algorithm:     algorithm.String(),
+	}
+
+	var publicKey crypto.PublicKey
+	var privateKeyPEM []byte
+	publicKeyPEMType := "PUBLIC KEY"
+
+	switch algorithm {
+	case enums.SigningAlgorithmRS256:
+		privateKey, err := GeneratePrivateKey(4096)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to generate a private key")
+		}
+		keyPair.Type = "RSA"
+		privateKeyPEM = EncodePrivateKeyToPEM(privateKey)
+		keyPair.PublicKeyJWK, err = MarshalRSAPublicKeyToJWK(&privateKey.PublicKey, kid)
+		if err != nil {
+			return nil, err
+		}
+		publicKey = &privateKey.PublicKey
+		publicKeyPEMType = "RSA PUBLIC KEY"
+	case enums.SigningAlgorithmES256, enums.SigningAlgorithmES384:
+		curve := elliptic.P256()
+		if algorithm == enums.SigningAlgorithmES384 {
+			curve = elliptic.P384()
+		}
+		privateKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to generate a private key")
+		}
+		privateKeyDER, err := x509.MarshalECPrivateKey(privateKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to marshal the private key")
+		}
+		keyPair.Type = "EC"
+		privateKeyPEM = pem.EncodeToMemory(&pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: privateKeyDER,
+		})
+		keyPair.PublicKeyJWK, err = MarshalECPublicKeyToJWK(&privateKey.PublicKey, algorithm.String(), kid)
+		if err != nil {
+			return nil, err
+		}
+		publicKey = &privateKey.PublicKey
+	case enums.SigningAlgorithmEdDSA:
+		edPublicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to generate a private key")
+		}
+		privateKeyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to marshal the private key")
+		}
+		keyPair.Type = "OKP"
+		privateKeyPEM = pem.EncodeToMemory(&pem.Block{
+			Type:  "PRIVATE KEY",
+			Bytes: privateKeyDER,
+		})
+		keyPair.PublicKeyJWK, err = MarshalEdDSAPublicKeyToJWK(edPublicKey, kid)
+		if err != nil {
+			return nil, err
+		}
+		publicKey = edPublicKey
+	default:
+		return nil, errors.WithStack(errors.Errorf("unsupported signing algorithm %v", algorithm))
+	}
+
+	privateKeyPEMEncrypted, err := EncryptText(string(privateKeyPEM), aesEncryptionKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to encrypt the private key")
+	}
+	keyPair.PrivateKeyPEMEncrypted = privateKeyPEMEncrypted
+
+	publicKeyASN1_DER, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal public key to PKIX")
+	}
+	keyPair.PublicKeyASN1_DER = publicKeyASN1_DER
+	keyPair.PublicKeyPEM = pem.EncodeToMemory(&pem.Block{
+		Type:  publicKeyPEMType,
+		Bytes: publicKeyASN1_DER,
+	})
+
+	return keyPair, nil
+}
+
+// ParseSigningPrivateKeyFromPEM parses the private key of a signing key pair, according to its algorithm
+func ParseSigningPrivateKeyFromPEM(algorithm string, privateKeyPEM []byte) (crypto.PrivateKey, error) {
+	signingAlgorithm, err := enums.SigningAlgorithmFromString(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	var privateKey crypto.PrivateKey
+	switch signingAlgorithm {
+	case enums.SigningAlgorithmRS256:
+		privateKey, err = jwt.ParseRSAPrivateKeyFromPEM(privateKeyPEM)
+	case enums.SigningAlgorithmES256, enums.SigningAlgorithmES384:
+		privateKey, err = jwt.ParseECPrivateKeyFromPEM(privateKeyPEM)
+	case enums.SigningAlgorithmEdDSA:
+		privateKey, err = jwt.ParseEdPrivateKeyFromPEM(privateKeyPEM)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse private key from PEM")
+	}
+	return privateKey, nil
+}
+
+// ParseSigningPublicKeyFromPEM parses the public key of a signing key pair, according to its algorithm
+func ParseSigningPublicKeyFromPEM(algorithm string, publicKeyPEM []byte) (crypto.PublicKey, error) {
+	signingAlgorithm, err := enums.SigningAlgorithmFromString(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	var publicKey crypto.PublicKey
+	switch signingAlgorithm {
+	case enums.SigningAlgorithmRS256:
+		publicKey, err = jwt.ParseRSAPublicKeyFromPEM(publicKeyPEM)
+	case enums.SigningAlgorithmES256, enums.SigningAlgorithmES384:
+		publicKey, err = jwt.ParseECPublicKeyFromPEM(publicKeyPEM)
+	case enums.SigningAlgorithmEdDSA:
+		publicKey, err = jwt.ParseEdPublicKeyFromPEM(publicKeyPEM)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse public key from PEM")
+	}
+	return publicKey, nil
+}
+
+func MarshalECPublicKeyToJWK(publicKey *ecdsa.PublicKey, algorithm string, kid string) ([]byte, error) {
+	ecdhPublicKey, err := publicKey.ECDH()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to convert the public key")
+	}
+
+	// uncompressed point: 0x04 || x || y
+	point := ecdhPublicKey.Bytes()
+	coordinateSize := (len(point) - 1) / 2
+
+	jwk := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		Use string `json:"use"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	}{
+		Alg: algorithm,
+		Kid: kid,
+		Kty: "EC",
+		Use: "sig",
+		Crv: publicKey.Curve.Params().Name,
+		X:   b64.RawURLEncoding.EncodeToString(point[1 : 1+coordinateSize]),
+		Y:   b64.RawURLEncoding.EncodeToString(point[1+coordinateSize:]),
+	}
+
+	publicKeyJWK, err := json.MarshalIndent(jwk, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal public key to JSON")
+	}
+	return publicKeyJWK, nil
+}
+
+func MarshalEdDSAPublicKeyToJWK(publicKey ed25519.PublicKey, kid string) ([]byte, error) {
+	jwk := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		Use string `json:"use"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+	}{
+		Alg: enums.SigningAlgorithmEdDSA.String(),
+		Kid: kid,
+		Kty: "OKP",
+		Use: "sig",
+		Crv: "Ed25519",
+		X:   b64.RawURLEncoding.EncodeToString(publicKey),
+	}
+
+	publicKeyJWK, err := json.MarshalIndent(jwk, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal public key to JSON")
+	}
+	return publicKeyJWK, nil
+}
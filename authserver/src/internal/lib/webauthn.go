@@ -0,0 +1,233 @@
+package lib
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/url"
+	"strings"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// WebAuthnRelyingParty identifies this server to WebAuthn authenticators
+type WebAuthnRelyingParty struct {
+	Id     string
+	Name   string
+	Origin string
+}
+
+// NewWebAuthnRelyingParty derives the relying party id (the host name) and the
+// expected origin from the base url of the server
+func NewWebAuthnRelyingParty(baseUrl string, name string) (*WebAuthnRelyingParty, error) {
+	u, err := url.Parse(baseUrl)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse the base url")
+	}
+	if len(u.Scheme) == 0 || len(u.Hostname()) == 0 {
+		return nil, errors.WithStack(errors.New("the base url must be an absolute url"))
+	}
+
+	return &WebAuthnRelyingParty{
+		Id:     u.Hostname(),
+		Name:   name,
+		Origin: u.Scheme + "://" + u.Host,
+	}, nil
+}
+
+// WebAuthnRegistrationResponse is what the browser sends back after navigator.credentials.create().
+// Binary fields are base64url encoded
+type WebAuthnRegistrationResponse struct {
+	Id                string   `json:"id"`
+	ClientDataJSON    string   `json:"clientDataJSON"`
+	AttestationObject string   `json:"attestationObject"`
+	Transports        []string `json:"transports"`
+}
+
+// WebAuthnAssertionResponse is what the browser sends back after navigator.credentials.get().
+// Binary fields are base64url encoded
+type WebAuthnAssertionResponse struct {
+	Id                string `json:"id"`
+	ClientDataJSON    string `json:"clientDataJSON"`
+	AuthenticatorData string `json:"authenticatorData"`
+	Signature         string `json:"signature"`
+	UserHandle        string `json:"userHandle"`
+}
+
+type WebAuthnCredentialData struct {
+	CredentialId string
+	PublicKey    []byte
+	SignCount    int64
+	AAGUID       string
+	UserVerified bool
+}
+
+type WebAuthnAssertionResult struct {
+	SignCount    int64
+	UserVerified bool
+}
+
+// GenerateWebAuthnChallenge returns a random, base64url encoded challenge
+func GenerateWebAuthnChallenge() (string, error) {
+	challenge := make([]byte, 32)
+	_, err := rand.Read(challenge)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to generate the webauthn challenge")
+	}
+	return base64.RawURLEncoding.EncodeToString(challenge), nil
+}
+
+func DecodeWebAuthnBase64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// VerifyWebAuthnRegistration validates a new credential and returns the data to be stored.
+// Credentials are requested with attestation conveyance "none", so the attestation
+// statement itself is not verified
+func VerifyWebAuthnRegistration(rp *WebAuthnRelyingParty, expectedChallenge string,
+	response *WebAuthnRegistrationResponse) (*WebAuthnCredentialData, error) {
+
+	if len(expectedChallenge) == 0 {
+		return nil, errors.WithStack(errors.New("challenge does not match"))
+	}
+
+	rawId, err := DecodeWebAuthnBase64(response.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decode the credential id")
+	}
+	clientDataJSON, err := DecodeWebAuthnBase64(response.ClientDataJSON)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decode clientDataJSON")
+	}
+	attestationObject, err := DecodeWebAuthnBase64(response.AttestationObject)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decode attestationObject")
+	}
+
+	creationResponse := protocol.CredentialCreationResponse{
+		PublicKeyCredential: protocol.PublicKeyCredential{
+			Credential: protocol.Credential{
+				ID:   strings.TrimRight(response.Id, "="),
+				Type: string(protocol.PublicKeyCredentialType),
+			},
+			RawID: rawId,
+		},
+		AttestationResponse: protocol.AuthenticatorAttestationResponse{
+			AuthenticatorResponse: protocol.AuthenticatorResponse{
+				ClientDataJSON: clientDataJSON,
+			},
+			AttestationObject: attestationObject,
+			Transports:        response.Transports,
+		},
+	}
+	parsed, err := creationResponse.Parse()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse the registration response")
+	}
+
+	err = parsed.Verify(expectedChallenge, false, rp.Id, []string{rp.Origin})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	authData := parsed.Response.AttestationObject.AuthData
+	if !authData.Flags.HasAttestedCredentialData() {
+		return nil, errors.WithStack(errors.New("authenticator data does not contain attested credential data"))
+	}
+
+	credentialId := base64.RawURLEncoding.EncodeToString(authData.AttData.CredentialID)
+	if credentialId != parsed.ID {
+		return nil, errors.WithStack(errors.New("credential id does not match the attested credential data"))
+	}
+
+	// make sure the key is usable before storing it
+	_, err = webauthncose.ParsePublicKey(authData.AttData.CredentialPublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse the credential public key")
+	}
+
+	aaguid, err := uuid.FromBytes(authData.AttData.AAGUID)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid aaguid")
+	}
+
+	return &WebAuthnCredentialData{
+		CredentialId: credentialId,
+		PublicKey:    authData.AttData.CredentialPublicKey,
+		SignCount:    int64(authData.Counter),
+		AAGUID:       aaguid.String(),
+		UserVerified: authData.Flags.HasUserVerified(),
+	}, nil
+}
+
+// VerifyWebAuthnAssertion validates an authentication assertion against a stored credential
+func VerifyWebAuthnAssertion(rp *WebAuthnRelyingParty, expectedChallenge string, response *WebAuthnAssertionResponse,
+	publicKey []byte, storedSignCount int64, requireUserVerification bool) (*WebAuthnAssertionResult, error) {
+
+	if len(expectedChallenge) == 0 {
+		return nil, errors.WithStack(errors.New("challenge does not match"))
+	}
+
+	rawId, err := DecodeWebAuthnBase64(response.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decode the credential id")
+	}
+	clientDataJSON, err := DecodeWebAuthnBase64(response.ClientDataJSON)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decode clientDataJSON")
+	}
+	authenticatorData, err := DecodeWebAuthnBase64(response.AuthenticatorData)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decode authenticatorData")
+	}
+	signature, err := DecodeWebAuthnBase64(response.Signature)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decode signature")
+	}
+	userHandle, err := DecodeWebAuthnBase64(response.UserHandle)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decode userHandle")
+	}
+
+	assertionResponse := protocol.CredentialAssertionResponse{
+		PublicKeyCredential: protocol.PublicKeyCredential{
+			Credential: protocol.Credential{
+				ID:   strings.TrimRight(response.Id, "="),
+				Type: string(protocol.PublicKeyCredentialType),
+			},
+			RawID: rawId,
+		},
+		AssertionResponse: protocol.AuthenticatorAssertionResponse{
+			AuthenticatorResponse: protocol.AuthenticatorResponse{
+				ClientDataJSON: clientDataJSON,
+			},
+			AuthenticatorData: authenticatorData,
+			Signature:         signature,
+			UserHandle:        userHandle,
+		},
+	}
+	parsed, err := assertionResponse.Parse()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse the assertion response")
+	}
+
+	err = parsed.Verify(expectedChallenge, rp.Id, []string{rp.Origin}, "", requireUserVerification, publicKey)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// authenticators that do not implement a counter always return zero. A counter that is
+	// implemented must increase with every assertion, and can't go back to zero
+	newSignCount := int64(parsed.Response.AuthenticatorData.Counter)
+	if (newSignCount != 0 || storedSignCount != 0) && newSignCount <= storedSignCount {
+		return nil, errors.WithStack(errors.Errorf("signature counter did not increase (stored %v, received %v), the authenticator may have been cloned",
+			storedSignCount, newSignCount))
+	}
+
+	return &WebAuthnAssertionResult{
+		SignCount:    newSignCount,
+		UserVerified: parsed.Response.AuthenticatorData.Flags.HasUserVerified(),
+	}, nil
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/leodip/goiabada/internal/constants"
+	core_senders "github.com/leodip/goiabada/internal/core/senders"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/pkg/errors"
+)
+
+const maxFailedLoginDelay = 30 * time.Second
+
+// getFailedLoginDelay returns how long the user must wait, after the last failed attempt, before
+// trying to authenticate again. The delay doubles with each consecutive failed attempt
+func getFailedLoginDelay(user *entities.User, settings *entities.Settings) time.Duration {
+	if settings.FailedLoginDelayInMilliseconds <= 0 || user.FailedLoginAttempts <= 0 {
+		return 0
+	}
+
+	exponent := math.Min(float64(user.FailedLoginAttempts-1), 16)
+	delay := time.Duration(float64(settings.FailedLoginDelayInMilliseconds)*math.Pow(2, exponent)) * time.Millisecond
+	if delay > maxFailedLoginDelay {
+		delay = maxFailedLoginDelay
+	}
+	return delay
+}
+
+// getAccountLockoutMessage returns the message to show when the user is not allowed to attempt
+// to authenticate right now (account locked, or progressive delay not elapsed yet).
+// An empty string means the attempt can proceed
+func getAccountLockoutMessage(user *entities.User, settings *entities.Settings) string {
+	now := time.Now().UTC()
+
+	if user.LockedPermanently {
+		return "Your account has been locked due to too many failed authentication attempts. Please contact an administrator."
+	}
+
+	if user.IsLocked(now) {
+		minutes := int(math.Ceil(user.LockedUntil.Time.Sub(now).Minutes()))
+		return fmt.Sprintf("Your account is temporarily locked due to too many failed authentication attempts. Please try again in %v minute(s).", minutes)
+	}
+
+	if user.LastFailedLoginAt.Valid {
+		retryAt := user.LastFailedLoginAt.Time.Add(getFailedLoginDelay(user, settings))
+		if now.Before(retryAt) {
+			seconds := int(math.Ceil(retryAt.Sub(now).Seconds()))
+			return fmt.Sprintf("Too many failed attempts. Please wait %v second(s) before trying again.", seconds)
+		}
+	}
+
+	return ""
+}
+
+// registerFailedAuthAttempt increments the failed attempts counter of the user and locks the account
+// when one of the thresholds is reached. It returns the updated user
+func (s *Server) registerFailedAuthAttempt(r *http.Request, user *entities.User, settings *entities.Settings,
+	emailSender emailSender) (*entities.User, error) {
+
+	err := s.database.IncrementUserFailedLoginAttempts(nil, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err = s.database.GetUserById(nil, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.WithStack(errors.New("user not found"))
+	}
+
+	now := time.Now().UTC()
+	wasLocked := user.IsLocked(now)
+
+	if settings.AccountPermanentLockoutThreshold > 0 && user.FailedLoginAttempts >= settings.AccountPermanentLockoutThreshold {
+		user.LockedPermanently = true
+	} else if settings.AccountLockoutThreshold > 0 && user.FailedLoginAttempts >= settings.AccountLockoutThreshold {
+		// once the threshold is reached, every further failed attempt locks the account again
+		user.LockedUntil = sql.NullTime{
+			Time:  now.Add(time.Duration(settings.AccountLockoutDurationInSeconds) * time.Second),
+			Valid: true,
+		}
+	} else {
+		return user, nil
+	}
+
+	// only the lockout columns are updated, so concurrent changes to the user are not overwritten
+	err = s.database.LockUser(nil, user.Id, user.LockedUntil, user.LockedPermanently)
+	if err != nil {
+		return nil, err
+	}
+
+	if !wasLocked {
+		lib.LogAudit(r.Context(), constants.AuditAccountLocked, map[string]interface{}{
+			"userId":              user.Id,
+			"failedLoginAttempts": user.FailedLoginAttempts,
+			"lockedPermanently":   user.LockedPermanently,
+		})
+		s.sendAccountLockedEmail(r, user, settings, emailSender)
+	}
+
+	return user, nil
+}
+
+// resetFailedAuthAttempts clears the failed attempts counter after a successful authentication
+func (s *Server) resetFailedAuthAttempts(user *entities.User) error {
+	if user.FailedLoginAttempts == 0 && !user.LastFailedLoginAt.Valid && !user.LockedUntil.Valid {
+		return nil
+	}
+
+	err := s.database.ResetUserFailedLoginAttempts(nil, user.Id)
+	if err != nil {
+		return err
+	}
+
+	user.FailedLoginAttempts = 0
+	user.LastFailedLoginAt = sql.NullTime{Valid: false}
+	user.LockedUntil = sql.NullTime{Valid: false}
+	return nil
+}
+
+func (s *Server) sendAccountLockedEmail(r *http.Request, user *entities.User, settings *entities.Settings,
+	emailSender emailSender) {
+
+	if !settings.SMTPEnabled || len(user.Email) == 0 {
+		return
+	}
+
+	bind := map[string]interface{}{
+		"name":              user.GetFullName(),
+		"lockedPermanently": user.LockedPermanently,
+		"lockoutMinutes":    int(math.Ceil(float64(settings.AccountLockoutDurationInSeconds) / 60)),
+		"link":              lib.GetBaseUrl() + "/forgot-password",
+	}
+	buf, err := s.renderTemplateToBuffer(r, "/layouts/email_layout.html", "/emails/email_account_locked.html", bind)
+	if err != nil {
+		slog.Error(fmt.Sprintf("unable to render the account locked email: %+v", err))
+		return
+	}
+
+	input := &core_senders.SendEmailInput{
+		To:       user.Email,
+		Subject:  "Your account has been locked",
+		HtmlBody: buf.String(),
+	}
+	err = emailSender.SendEmail(r.Context(), input)
+	if err != nil {
+		slog.Error(fmt.Sprintf("unable to send the account locked email: %+v", err))
+	}
+}
@@ -84,7 +84,7 @@ func (s *Server) handleAccountActivateGet(userCreator userCreator, emailSender e
 			return
 		}
 
-		lib.LogAudit(constants.AuditCreatedUser, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditCreatedUser, map[string]interface{}{
 			"email": createdUser.Email,
 		})
 
@@ -93,7 +93,7 @@ func (s *Server) handleAccountActivateGet(userCreator userCreator, emailSender e
 			s.internalServerError(w, r, err)
 		}
 
-		lib.LogAudit(constants.AuditActivatedAccount, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditActivatedAccount, map[string]interface{}{
 			"email": createdUser.Email,
 		})
 
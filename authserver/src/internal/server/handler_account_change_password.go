@@ -105,7 +105,7 @@ func (s *Server) handleAccountChangePasswordPost(passwordValidator passwordValid
 			return
 		}
 
-		lib.LogAudit(constants.AuditChangedPassword, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditChangedPassword, map[string]interface{}{
 			"userId":       user.Id,
 			"loggedInUser": s.getLoggedInSubject(r),
 		})
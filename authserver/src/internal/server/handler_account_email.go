@@ -225,7 +225,7 @@ func (s *Server) handleAccountEmailVerifyGet() http.HandlerFunc {
 			return
 		}
 
-		lib.LogAudit(constants.AuditVerifiedEmail, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditVerifiedEmail, map[string]interface{}{
 			"userId":       user.Id,
 			"loggedInUser": s.getLoggedInSubject(r),
 		})
@@ -310,7 +310,7 @@ func (s *Server) handleAccountEmailPost(emailValidator emailValidator, inputSani
 				return
 			}
 
-			lib.LogAudit(constants.AuditUpdatedUserEmail, map[string]interface{}{
+			lib.LogAudit(r.Context(), constants.AuditUpdatedUserEmail, map[string]interface{}{
 				"userId":       user.Id,
 				"loggedInUser": s.getLoggedInSubject(r),
 			})
@@ -2,7 +2,9 @@ package server
 
 import (
 	"encoding/base64"
+	"html/template"
 	"net/http"
+	"net/url"
 
 	"github.com/gorilla/csrf"
 	"github.com/leodip/goiabada/internal/common"
@@ -58,7 +60,7 @@ func (s *Server) handleAccountLogoutGet() http.HandlerFunc {
 		postLogoutRedirectURI := getFromUrlQueryOrFormPost("post_logout_redirect_uri")
 
 		if len(postLogoutRedirectURI) == 0 {
-			renderErrorUi("The post_logout_redirect_uri parameter is required. This parameter must match one of the redirect URIs that was registered for this client.")
+			renderErrorUi("The post_logout_redirect_uri parameter is required. This parameter must match one of the post-logout redirect URIs that was registered for this client.")
 			return
 		}
 
@@ -141,15 +143,15 @@ func (s *Server) handleAccountLogoutGet() http.HandlerFunc {
 			return
 		}
 
-		err = s.database.ClientLoadRedirectURIs(nil, client)
+		err = s.database.ClientLoadPostLogoutRedirectURIs(nil, client)
 		if err != nil {
 			s.internalServerError(w, r, err)
 			return
 		}
 
-		// check if postLogoutRedirectURI is a valid redirect uri for the client
+		// check if postLogoutRedirectURI is registered for the client
 		found := false
-		for _, uri := range client.RedirectURIs {
+		for _, uri := range client.PostLogoutRedirectURIs {
 			if uri.URI == postLogoutRedirectURI {
 				found = true
 				break
@@ -157,7 +159,7 @@ func (s *Server) handleAccountLogoutGet() http.HandlerFunc {
 		}
 
 		if !found {
-			renderErrorUi("The post_logout_redirect_uri parameter is invalid: it is not registered as a redirect URI for the client.")
+			renderErrorUi("The post_logout_redirect_uri parameter is invalid: it is not registered as a post-logout redirect URI for the client.")
 			return
 		}
 
@@ -172,6 +174,7 @@ func (s *Server) handleAccountLogoutGet() http.HandlerFunc {
 			sessionIdentifier = r.Context().Value(common.ContextKeySessionIdentifier).(string)
 		}
 
+		frontchannelLogoutURIs := []string{}
 		if len(sessionIdentifier) > 0 {
 
 			sid := idToken.GetStringClaim("sid")
@@ -192,57 +195,31 @@ func (s *Server) handleAccountLogoutGet() http.HandlerFunc {
 			}
 
 			if userSession != nil {
-
-				err = s.database.UserSessionLoadClients(nil, userSession)
+				// end the whole session, so the user is signed out of all the clients that share it
+				frontchannelLogoutURIs, err = s.getFrontchannelLogoutURIs(settings, userSession)
 				if err != nil {
 					s.internalServerError(w, r, err)
 					return
 				}
 
-				err = s.database.UserSessionClientsLoadClients(nil, userSession.Clients)
+				err = s.backchannelLogoutNotifier.QueueLogout(userSession)
 				if err != nil {
 					s.internalServerError(w, r, err)
 					return
 				}
 
-				// find the user session client
-				var userSessionClient *entities.UserSessionClient
-				for idx, client := range userSession.Clients {
-					if client.Client.ClientIdentifier == clientIdentifier {
-						userSessionClient = &userSession.Clients[idx]
-						break
-					}
+				err = s.database.DeleteUserSession(nil, userSession.Id)
+				if err != nil {
+					s.internalServerError(w, r, err)
+					return
 				}
 
-				if userSessionClient != nil {
-					err := s.database.DeleteUserSessionClient(nil, userSessionClient.Id)
-					if err != nil {
-						s.internalServerError(w, r, err)
-						return
-					}
-
-					lib.LogAudit(constants.AuditDeletedUserSessionClient, map[string]interface{}{
-						"userId":        userSession.UserId,
-						"userSessionId": userSession.Id,
-						"clientId":      userSessionClient.Client.Id,
-						"loggedInUser":  s.getLoggedInSubject(r),
-					})
-
-					if len(userSession.Clients) == 1 {
-						// this was the only client in the session, so delete the session
-						err := s.database.DeleteUserSession(nil, userSession.Id)
-						if err != nil {
-							s.internalServerError(w, r, err)
-							return
-						}
-
-						lib.LogAudit(constants.AuditLogout, map[string]interface{}{
-							"userId":            userSession.UserId,
-							"sessionIdentifier": sessionIdentifier,
-							"loggedInUser":      s.getLoggedInSubject(r),
-						})
-					}
-				}
+				lib.LogAudit(r.Context(), constants.AuditLogout, map[string]interface{}{
+					"userId":            userSession.UserId,
+					"sessionIdentifier": sessionIdentifier,
+					"clientId":          client.Id,
+					"loggedInUser":      s.getLoggedInSubject(r),
+				})
 			}
 		}
 
@@ -253,6 +230,7 @@ func (s *Server) handleAccountLogoutGet() http.HandlerFunc {
 			s.internalServerError(w, r, err)
 			return
 		}
+		clearBrowserStateCookie(w)
 
 		state := getFromUrlQueryOrFormPost("state")
 		sid := sessionIdentifier
@@ -262,13 +240,18 @@ func (s *Server) handleAccountLogoutGet() http.HandlerFunc {
 			logoutUri += "&state=" + state
 		}
 
-		http.Redirect(w, r, logoutUri, http.StatusFound)
+		err = s.completeLogout(w, r, frontchannelLogoutURIs, logoutUri)
+		if err != nil {
+			s.internalServerError(w, r, err)
+		}
 	}
 }
 
 func (s *Server) handleAccountLogoutPost() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
+		settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
+
 		sess, err := s.sessionStore.Get(r, common.SessionName)
 		if err != nil {
 			s.internalServerError(w, r, err)
@@ -281,6 +264,7 @@ func (s *Server) handleAccountLogoutPost() http.HandlerFunc {
 		}
 
 		userId := int64(0)
+		frontchannelLogoutURIs := []string{}
 
 		if len(sessionIdentifier) > 0 {
 			userSession, err := s.database.GetUserSessionBySessionIdentifier(nil, sessionIdentifier)
@@ -291,6 +275,25 @@ func (s *Server) handleAccountLogoutPost() http.HandlerFunc {
 
 			if userSession != nil {
 				userId = userSession.UserId
+
+				frontchannelLogoutURIs, err = s.getFrontchannelLogoutURIs(settings, userSession)
+				if err != nil {
+					s.internalServerError(w, r, err)
+					return
+				}
+
+				err = s.backchannelLogoutNotifier.QueueLogout(userSession)
+				if err != nil {
+					s.internalServerError(w, r, err)
+					return
+				}
+
+				// the refresh tokens of the session stop working along with it
+				err = s.database.DeleteUserSession(nil, userSession.Id)
+				if err != nil {
+					s.internalServerError(w, r, err)
+					return
+				}
 			}
 		}
 
@@ -301,13 +304,77 @@ func (s *Server) handleAccountLogoutPost() http.HandlerFunc {
 			s.internalServerError(w, r, err)
 			return
 		}
+		clearBrowserStateCookie(w)
 
-		lib.LogAudit(constants.AuditLogout, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditLogout, map[string]interface{}{
 			"userId":            userId,
 			"sessionIdentifier": sessionIdentifier,
 			"loggedInUser":      s.getLoggedInSubject(r),
 		})
 
-		http.Redirect(w, r, lib.GetBaseUrl(), http.StatusFound)
+		err = s.completeLogout(w, r, frontchannelLogoutURIs, lib.GetBaseUrl())
+		if err != nil {
+			s.internalServerError(w, r, err)
+		}
+	}
+}
+
+// getFrontchannelLogoutURIs returns the front-channel logout URIs of the clients of the user
+// session, with the iss and sid parameters that identify the session that ended
+func (s *Server) getFrontchannelLogoutURIs(settings *entities.Settings, userSession *entities.UserSession) ([]string, error) {
+	err := s.database.UserSessionLoadClients(nil, userSession)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.database.UserSessionClientsLoadClients(nil, userSession.Clients)
+	if err != nil {
+		return nil, err
+	}
+
+	frontchannelLogoutURIs := []string{}
+	for _, sessionClient := range userSession.Clients {
+		if len(sessionClient.Client.FrontchannelLogoutURI) == 0 {
+			continue
+		}
+
+		frontchannelLogoutURI, err := url.Parse(sessionClient.Client.FrontchannelLogoutURI)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to parse the frontchannel logout uri")
+		}
+		values := frontchannelLogoutURI.Query()
+		values.Set("iss", settings.Issuer)
+		values.Set("sid", userSession.SessionIdentifier)
+		frontchannelLogoutURI.RawQuery = values.Encode()
+
+		frontchannelLogoutURIs = append(frontchannelLogoutURIs, frontchannelLogoutURI.String())
+	}
+	return frontchannelLogoutURIs, nil
+}
+
+// completeLogout redirects the user agent once the logout is done. When clients of the session
+// have a front-channel logout URI, a page that loads each of them in a hidden iframe is rendered
+// first, and the redirect happens after the iframes have loaded
+func (s *Server) completeLogout(w http.ResponseWriter, r *http.Request, frontchannelLogoutURIs []string,
+	redirectURI string) error {
+
+	if len(frontchannelLogoutURIs) == 0 {
+		http.Redirect(w, r, redirectURI, http.StatusFound)
+		return nil
+	}
+
+	t, err := template.ParseFS(s.templateFS, "logout_frontchannel.html")
+	if err != nil {
+		return errors.Wrap(err, "unable to parse template")
+	}
+
+	bind := map[string]interface{}{
+		"frontchannelLogoutURIs": frontchannelLogoutURIs,
+		"redirectURI":            redirectURI,
+	}
+	err = t.Execute(w, bind)
+	if err != nil {
+		return errors.Wrap(err, "unable to execute template")
 	}
+	return nil
 }
@@ -1,17 +1,42 @@
 package server
 
 import (
+	"database/sql"
 	"net/http"
 
+	"github.com/pkg/errors"
+
 	"github.com/gorilla/csrf"
 	"github.com/leodip/goiabada/internal/common"
 	"github.com/leodip/goiabada/internal/constants"
 	"github.com/leodip/goiabada/internal/dtos"
 	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
 	"github.com/leodip/goiabada/internal/lib"
 	"github.com/pquerna/otp/totp"
 )
 
+// getAccountOtpBind returns the data shared by all renders of the account OTP page
+func (s *Server) getAccountOtpBind(r *http.Request, user *entities.User) (map[string]interface{}, error) {
+	userRecoveryCodes, err := s.database.GetUserRecoveryCodesByUserId(nil, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
+
+	bind := map[string]interface{}{
+		"otpEnabled":             user.OTPEnabled,
+		"otpMethod":              user.GetOTPMethod().String(),
+		"email":                  user.Email,
+		"emailOtpAvailable":      settings.SMTPEnabled && user.EmailVerified,
+		"recoveryCodes":          []string{},
+		"remainingRecoveryCodes": len(userRecoveryCodes),
+		"csrfField":              csrf.TemplateField(r),
+	}
+	return bind, nil
+}
+
 func (s *Server) handleAccountOtpGet(otpSecretGenerator otpSecretGenerator) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -32,9 +57,16 @@ func (s *Server) handleAccountOtpGet(otpSecretGenerator otpSecretGenerator) http
 			return
 		}
 
-		bind := map[string]interface{}{
-			"otpEnabled": user.OTPEnabled,
-			"csrfField":  csrf.TemplateField(r),
+		sess, err := s.sessionStore.Get(r, common.SessionName)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		bind, err := s.getAccountOtpBind(r, user)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
 		}
 
 		if !user.OTPEnabled {
@@ -48,12 +80,6 @@ func (s *Server) handleAccountOtpGet(otpSecretGenerator otpSecretGenerator) http
 			bind["base64Image"] = base64Image
 			bind["secretKey"] = secretKey
 
-			sess, err := s.sessionStore.Get(r, common.SessionName)
-			if err != nil {
-				s.internalServerError(w, r, err)
-				return
-			}
-
 			// save image and secret in the session state
 			sess.Values[common.SessionKeyOTPSecret] = secretKey
 			sess.Values[common.SessionKeyOTPImage] = base64Image
@@ -94,19 +120,35 @@ func (s *Server) handleAccountOtpPost() http.HandlerFunc {
 
 		password := r.FormValue("password")
 
-		renderError := func(message string, base64Image string, secretKey string) {
-			bind := map[string]interface{}{
-				"error":      message,
-				"otpEnabled": user.OTPEnabled,
-				"csrfField":  csrf.TemplateField(r),
+		sess, err := s.sessionStore.Get(r, common.SessionName)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		base64Image, secretKey := "", ""
+		if val, ok := sess.Values[common.SessionKeyOTPImage]; ok {
+			base64Image = val.(string)
+		}
+		if val, ok := sess.Values[common.SessionKeyOTPSecret]; ok {
+			secretKey = val.(string)
+		}
+
+		// errorKey selects the form where the error is displayed
+		renderError := func(errorKey string, message string) {
+			bind, err := s.getAccountOtpBind(r, user)
+			if err != nil {
+				s.internalServerError(w, r, err)
+				return
 			}
+			bind[errorKey] = message
 
-			if len(base64Image) > 0 {
+			if !user.OTPEnabled {
 				bind["base64Image"] = base64Image
 				bind["secretKey"] = secretKey
 			}
 
-			err := s.renderTemplate(w, r, "/layouts/menu_layout.html", "/account_otp.html", bind)
+			err = s.renderTemplate(w, r, "/layouts/menu_layout.html", "/account_otp.html", bind)
 			if err != nil {
 				s.internalServerError(w, r, err)
 			}
@@ -117,67 +159,169 @@ func (s *Server) handleAccountOtpPost() http.HandlerFunc {
 		if user.OTPEnabled {
 
 			if !lib.VerifyPasswordHash(user.PasswordHash, password) {
-				renderError(authFailedError, "", "")
+				renderError("error", authFailedError)
 				return
 			}
 
 			// disable OTP
 			user.OTPSecret = ""
 			user.OTPEnabled = false
+			user.OTPMethod = enums.OTPMethodTOTP.String()
+			user.OTPCodeEncrypted = nil
+			user.OTPCodeIssuedAt = sql.NullTime{Valid: false}
 			err = s.database.UpdateUser(nil, user)
 			if err != nil {
 				s.internalServerError(w, r, err)
 				return
 			}
-		} else {
-			// enable OTP
 
-			sess, err := s.sessionStore.Get(r, common.SessionName)
+			err = s.database.DeleteUserRecoveryCodesByUserId(nil, user.Id)
 			if err != nil {
 				s.internalServerError(w, r, err)
 				return
 			}
 
-			base64Image, secretKey := "", ""
-			if val, ok := sess.Values[common.SessionKeyOTPImage]; ok {
-				base64Image = val.(string)
+			http.Redirect(w, r, lib.GetBaseUrl()+"/account/otp", http.StatusFound)
+			return
+		}
+
+		// enable OTP
+
+		otpMethod, err := enums.OTPMethodFromString(r.FormValue("otpMethod"))
+		if err != nil {
+			otpMethod = enums.OTPMethodTOTP
+		}
+
+		if otpMethod == enums.OTPMethodEmail {
+
+			if !lib.VerifyPasswordHash(user.PasswordHash, password) {
+				renderError("emailOtpError", authFailedError)
+				return
 			}
-			if val, ok := sess.Values[common.SessionKeyOTPSecret]; ok {
-				secretKey = val.(string)
+
+			settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
+			if !settings.SMTPEnabled || !user.EmailVerified {
+				renderError("emailOtpError", "To receive codes by email, your email address must be verified first.")
+				return
 			}
 
+			user.OTPSecret = ""
+		} else {
+
 			if !lib.VerifyPasswordHash(user.PasswordHash, password) {
-				renderError(authFailedError, base64Image, secretKey)
+				renderError("error", authFailedError)
 				return
 			}
 
 			otpCode := r.FormValue("otp")
 			if len(otpCode) == 0 {
-				renderError("OTP code is required.", base64Image, secretKey)
+				renderError("error", "OTP code is required.")
 				return
 			}
 
 			otpValid := totp.Validate(otpCode, secretKey)
 			if !otpValid {
-				renderError("Incorrect OTP Code. OTP codes are time-sensitive and change every 30 seconds. Make sure you're using the most recent code generated by your authenticator app.", base64Image, secretKey)
+				renderError("error", "Incorrect OTP Code. OTP codes are time-sensitive and change every 30 seconds. Make sure you're using the most recent code generated by your authenticator app.")
 				return
 			}
 
-			// save OTP secret
 			user.OTPSecret = secretKey
-			user.OTPEnabled = true
-			err = s.database.UpdateUser(nil, user)
+		}
+
+		// save OTP settings
+		user.OTPEnabled = true
+		user.OTPMethod = otpMethod.String()
+		user.OTPCodeEncrypted = nil
+		user.OTPCodeIssuedAt = sql.NullTime{Valid: false}
+		err = s.database.UpdateUser(nil, user)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		lib.LogAudit(r.Context(), constants.AuditEnrolledOTP, map[string]interface{}{
+			"userId":       user.Id,
+			"otpMethod":    otpMethod.String(),
+			"loggedInUser": s.getLoggedInSubject(r),
+		})
+
+		recoveryCodes, err := s.generateRecoveryCodes(user)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		lib.LogAudit(r.Context(), constants.AuditGeneratedRecoveryCodes, map[string]interface{}{
+			"userId":       user.Id,
+			"loggedInUser": s.getLoggedInSubject(r),
+		})
+
+		s.renderAccountOtpRecoveryCodes(w, r, user, recoveryCodes)
+	}
+}
+
+func (s *Server) handleAccountOtpRecoveryCodesPost() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		user, err := s.getAccountUser(r)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		if !user.OTPEnabled {
+			s.internalServerError(w, r, errors.WithStack(errors.New("OTP is not enabled for this user")))
+			return
+		}
+
+		password := r.FormValue("recoveryCodesPassword")
+		if !lib.VerifyPasswordHash(user.PasswordHash, password) {
+			bind, err := s.getAccountOtpBind(r, user)
 			if err != nil {
 				s.internalServerError(w, r, err)
 				return
 			}
+			bind["recoveryCodesError"] = "Authentication failed. Check your password and try again."
 
-			lib.LogAudit(constants.AuditEnrolledOTP, map[string]interface{}{
-				"userId":       user.Id,
-				"loggedInUser": s.getLoggedInSubject(r),
-			})
+			err = s.renderTemplate(w, r, "/layouts/menu_layout.html", "/account_otp.html", bind)
+			if err != nil {
+				s.internalServerError(w, r, err)
+			}
+			return
 		}
 
-		http.Redirect(w, r, lib.GetBaseUrl()+"/account/otp", http.StatusFound)
+		recoveryCodes, err := s.generateRecoveryCodes(user)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		lib.LogAudit(r.Context(), constants.AuditGeneratedRecoveryCodes, map[string]interface{}{
+			"userId":       user.Id,
+			"loggedInUser": s.getLoggedInSubject(r),
+		})
+
+		s.renderAccountOtpRecoveryCodes(w, r, user, recoveryCodes)
+	}
+}
+
+// renderAccountOtpRecoveryCodes shows freshly generated recovery codes. They are rendered in the
+// response of the request that generated them and never stored in the session, so they are
+// shown only once
+func (s *Server) renderAccountOtpRecoveryCodes(w http.ResponseWriter, r *http.Request, user *entities.User,
+	recoveryCodes []string) {
+
+	bind, err := s.getAccountOtpBind(r, user)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+	bind["recoveryCodes"] = recoveryCodes
+
+	w.Header().Set("Cache-Control", "no-store")
+	err = s.renderTemplate(w, r, "/layouts/menu_layout.html", "/account_otp.html", bind)
+	if err != nil {
+		s.internalServerError(w, r, err)
 	}
 }
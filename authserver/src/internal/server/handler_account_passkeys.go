@@ -0,0 +1,305 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/gorilla/csrf"
+	"github.com/leodip/goiabada/internal/common"
+	"github.com/leodip/goiabada/internal/constants"
+	"github.com/leodip/goiabada/internal/customerrors"
+	"github.com/leodip/goiabada/internal/dtos"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/lib"
+)
+
+const maxPasskeyNameLength = 64
+
+func (s *Server) getAccountUser(r *http.Request) (*entities.User, error) {
+	var jwtInfo dtos.JwtInfo
+	if r.Context().Value(common.ContextKeyJwtInfo) != nil {
+		jwtInfo = r.Context().Value(common.ContextKeyJwtInfo).(dtos.JwtInfo)
+	}
+
+	sub, err := jwtInfo.IdToken.Claims.GetSubject()
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.database.GetUserBySubject(nil, sub)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.WithStack(errors.New("user not found"))
+	}
+	return user, nil
+}
+
+func (s *Server) handleAccountPasskeysGet() http.HandlerFunc {
+
+	type passkeyInfo struct {
+		WebAuthnCredentialId int64
+		Name                 string
+		CreatedAt            string
+		LastUsedAt           string
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		user, err := s.getAccountUser(r)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		webAuthnCredentials, err := s.database.GetWebAuthnCredentialsByUserId(nil, user.Id)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		passkeys := []passkeyInfo{}
+		for _, c := range webAuthnCredentials {
+			passkey := passkeyInfo{
+				WebAuthnCredentialId: c.Id,
+				Name:                 c.Name,
+				CreatedAt:            c.CreatedAt.Time.Format(time.RFC1123),
+				LastUsedAt:           "-",
+			}
+			if c.LastUsedAt.Valid {
+				passkey.LastUsedAt = c.LastUsedAt.Time.Format(time.RFC1123)
+			}
+			passkeys = append(passkeys, passkey)
+		}
+
+		bind := map[string]interface{}{
+			"passkeys":  passkeys,
+			"csrfField": csrf.TemplateField(r),
+		}
+
+		err = s.renderTemplate(w, r, "/layouts/menu_layout.html", "/account_passkeys.html", bind)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+	}
+}
+
+func (s *Server) handleAccountPasskeysRegisterBeginPost() http.HandlerFunc {
+
+	type pubKeyCredParam struct {
+		Type string `json:"type"`
+		Alg  int    `json:"alg"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		user, err := s.getAccountUser(r)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+
+		webAuthnCredentials, err := s.database.GetWebAuthnCredentialsByUserId(nil, user.Id)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+
+		rp, err := s.getWebAuthnRelyingParty(r)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+
+		challenge, err := s.newWebAuthnChallenge(w, r)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+
+		displayName := user.GetFullName()
+		if len(strings.TrimSpace(displayName)) == 0 {
+			displayName = user.Email
+		}
+
+		result := map[string]interface{}{
+			"challenge": challenge,
+			"rp": map[string]string{
+				"id":   rp.Id,
+				"name": rp.Name,
+			},
+			"user": map[string]string{
+				"id":          getWebAuthnUserHandle(user),
+				"name":        user.Email,
+				"displayName": displayName,
+			},
+			// ES256, EdDSA and RS256, in order of preference
+			"pubKeyCredParams": []pubKeyCredParam{
+				{Type: "public-key", Alg: -7},
+				{Type: "public-key", Alg: -8},
+				{Type: "public-key", Alg: -257},
+			},
+			"timeout":     webAuthnTimeoutInMilliseconds,
+			"attestation": "none",
+			"authenticatorSelection": map[string]interface{}{
+				"residentKey":        "preferred",
+				"requireResidentKey": false,
+				"userVerification":   "preferred",
+			},
+			"excludeCredentials": getWebAuthnCredentialDescriptors(webAuthnCredentials),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func (s *Server) handleAccountPasskeysRegisterFinishPost() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		user, err := s.getAccountUser(r)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+
+		var data struct {
+			Name       string                           `json:"name"`
+			Credential lib.WebAuthnRegistrationResponse `json:"credential"`
+		}
+		err = json.NewDecoder(r.Body).Decode(&data)
+		if err != nil {
+			s.jsonError(w, r, customerrors.NewValidationError("", "Unable to parse the passkey response."))
+			return
+		}
+
+		name := strings.TrimSpace(data.Name)
+		if len(name) == 0 {
+			s.jsonError(w, r, customerrors.NewValidationError("", "Please enter a name for the passkey."))
+			return
+		}
+		if len(name) > maxPasskeyNameLength {
+			s.jsonError(w, r, customerrors.NewValidationError("",
+				fmt.Sprintf("The name of the passkey cannot exceed a maximum length of %v characters.", maxPasskeyNameLength)))
+			return
+		}
+
+		challenge, err := s.takeWebAuthnChallenge(w, r)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+
+		rp, err := s.getWebAuthnRelyingParty(r)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+
+		credentialData, err := lib.VerifyWebAuthnRegistration(rp, challenge, &data.Credential)
+		if err != nil {
+			s.jsonError(w, r, customerrors.NewValidationError("", "The passkey could not be verified: "+errors.Cause(err).Error()))
+			return
+		}
+
+		existing, err := s.database.GetWebAuthnCredentialByCredentialId(nil, credentialData.CredentialId)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+		if existing != nil {
+			s.jsonError(w, r, customerrors.NewValidationError("", "This passkey is already registered."))
+			return
+		}
+
+		webAuthnCredential := &entities.WebAuthnCredential{
+			UserId:       user.Id,
+			Name:         name,
+			CredentialId: credentialData.CredentialId,
+			PublicKey:    credentialData.PublicKey,
+			SignCount:    credentialData.SignCount,
+			AAGUID:       credentialData.AAGUID,
+			Transports:   strings.Join(data.Credential.Transports, " "),
+		}
+		err = s.database.CreateWebAuthnCredential(nil, webAuthnCredential)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+
+		lib.LogAudit(r.Context(), constants.AuditRegisteredPasskey, map[string]interface{}{
+			"userId":               user.Id,
+			"webAuthnCredentialId": webAuthnCredential.Id,
+			"loggedInUser":         s.getLoggedInSubject(r),
+		})
+
+		result := struct {
+			Success bool
+		}{
+			Success: true,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func (s *Server) handleAccountPasskeysDeletePost() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		user, err := s.getAccountUser(r)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+
+		var data map[string]interface{}
+		decoder := json.NewDecoder(r.Body)
+		if err := decoder.Decode(&data); err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+
+		webAuthnCredentialId, ok := data["webAuthnCredentialId"].(float64)
+		if !ok || webAuthnCredentialId == 0 {
+			s.jsonError(w, r, errors.WithStack(errors.New("could not find the passkey id to delete")))
+			return
+		}
+
+		webAuthnCredential, err := s.database.GetWebAuthnCredentialById(nil, int64(webAuthnCredentialId))
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+		if webAuthnCredential == nil || webAuthnCredential.UserId != user.Id {
+			s.jsonError(w, r, errors.WithStack(errors.New("passkey not found")))
+			return
+		}
+
+		err = s.database.DeleteWebAuthnCredential(nil, webAuthnCredential.Id)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+
+		lib.LogAudit(r.Context(), constants.AuditDeletedPasskey, map[string]interface{}{
+			"userId":               user.Id,
+			"webAuthnCredentialId": webAuthnCredential.Id,
+			"loggedInUser":         s.getLoggedInSubject(r),
+		})
+
+		result := struct {
+			Success bool
+		}{
+			Success: true,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
@@ -204,7 +204,7 @@ func (s *Server) handleAccountProfilePost(profileValidator profileValidator, inp
 			return
 		}
 
-		lib.LogAudit(constants.AuditUpdatedUserProfile, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditUpdatedUserProfile, map[string]interface{}{
 			"userId":       user.Id,
 			"loggedInUser": s.getLoggedInSubject(r),
 		})
@@ -155,7 +155,7 @@ func (s *Server) handleAccountRegisterPost(userCreator userCreator, emailValidat
 				return
 			}
 
-			lib.LogAudit(constants.AuditCreatedPreRegistration, map[string]interface{}{
+			lib.LogAudit(r.Context(), constants.AuditCreatedPreRegistration, map[string]interface{}{
 				"email": preRegistration.Email,
 			})
 
@@ -205,7 +205,7 @@ func (s *Server) handleAccountRegisterPost(userCreator userCreator, emailValidat
 				return
 			}
 
-			lib.LogAudit(constants.AuditCreatedUser, map[string]interface{}{
+			lib.LogAudit(r.Context(), constants.AuditCreatedUser, map[string]interface{}{
 				"email": email,
 			})
 
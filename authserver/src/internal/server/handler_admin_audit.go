@@ -0,0 +1,436 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/lib"
+	"github.com/unknwon/paginater"
+)
+
+type auditEventFilter struct {
+	Event  string
+	User   string
+	Client string
+	From   string
+	To     string
+
+	userId   int64
+	clientId int64
+	from     time.Time
+	to       time.Time
+}
+
+// queryString returns the filter as url query parameters, to be used in the
+// paginator and export links
+func (f *auditEventFilter) queryString() string {
+	values := url.Values{}
+	if f.Event != "" {
+		values.Set("event", f.Event)
+	}
+	if f.User != "" {
+		values.Set("user", f.User)
+	}
+	if f.Client != "" {
+		values.Set("client", f.Client)
+	}
+	if f.From != "" {
+		values.Set("from", f.From)
+	}
+	if f.To != "" {
+		values.Set("to", f.To)
+	}
+	return values.Encode()
+}
+
+// parseAuditEventFilter reads the filter from the query string. The user can be
+// given by email, subject or username, and the client by its client identifier.
+// When the filter can't be applied, a message for the admin is returned
+func (s *Server) parseAuditEventFilter(r *http.Request) (*auditEventFilter, string, error) {
+
+	filter := &auditEventFilter{
+		Event:  strings.TrimSpace(r.URL.Query().Get("event")),
+		User:   strings.TrimSpace(r.URL.Query().Get("user")),
+		Client: strings.TrimSpace(r.URL.Query().Get("client")),
+		From:   strings.TrimSpace(r.URL.Query().Get("from")),
+		To:     strings.TrimSpace(r.URL.Query().Get("to")),
+	}
+
+	if filter.User != "" {
+		user, err := s.database.GetUserByEmail(nil, filter.User)
+		if err != nil {
+			return nil, "", err
+		}
+		if user == nil {
+			user, err = s.database.GetUserBySubject(nil, filter.User)
+			if err != nil {
+				return nil, "", err
+			}
+		}
+		if user == nil {
+			user, err = s.database.GetUserByUsername(nil, filter.User)
+			if err != nil {
+				return nil, "", err
+			}
+		}
+		if user == nil {
+			return filter, "Could not find a user with the given email, subject or username.", nil
+		}
+		filter.userId = user.Id
+	}
+
+	if filter.Client != "" {
+		client, err := s.database.GetClientByClientIdentifier(nil, filter.Client)
+		if err != nil {
+			return nil, "", err
+		}
+		if client == nil {
+			return filter, "Could not find a client with the given client identifier.", nil
+		}
+		filter.clientId = client.Id
+	}
+
+	if filter.From != "" {
+		from, err := time.Parse("2006-01-02", filter.From)
+		if err != nil {
+			return filter, "Invalid 'from' date.", nil
+		}
+		filter.from = from
+	}
+
+	if filter.To != "" {
+		to, err := time.Parse("2006-01-02", filter.To)
+		if err != nil {
+			return filter, "Invalid 'to' date.", nil
+		}
+		// the 'to' date is inclusive
+		filter.to = to.AddDate(0, 0, 1)
+	}
+
+	if !filter.from.IsZero() && !filter.to.IsZero() && !filter.from.Before(filter.to) {
+		return filter, "The 'from' date must be before the 'to' date.", nil
+	}
+
+	return filter, "", nil
+}
+
+func (s *Server) handleAdminAuditGet() http.HandlerFunc {
+
+	type auditEventInfo struct {
+		CreatedAt string
+		Event     string
+		Actor     string
+		User      string
+		Client    string
+		IpAddress string
+		RequestId string
+		UserAgent string
+		Details   string
+	}
+
+	type pageResult struct {
+		AuditEvents []auditEventInfo
+		Total       int
+		Page        int
+		PageSize    int
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		page := r.URL.Query().Get("page")
+		pageInt, err := strconv.Atoi(page)
+		if err != nil {
+			pageInt = 1
+		}
+		if pageInt < 1 {
+			pageInt = 1
+		}
+
+		eventTypes, err := s.database.GetAuditEventTypes(nil)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		filter, filterError, err := s.parseAuditEventFilter(r)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		const pageSize = 20
+		var auditEvents []entities.AuditEvent
+		total := 0
+		if filterError == "" {
+			auditEvents, total, err = s.database.SearchAuditEventsPaginated(nil, filter.Event, filter.userId,
+				filter.clientId, filter.from, filter.to, pageInt, pageSize)
+			if err != nil {
+				s.internalServerError(w, r, err)
+				return
+			}
+		}
+
+		userIds := []int64{}
+		clientIds := []int64{}
+		for _, auditEvent := range auditEvents {
+			if auditEvent.UserId.Valid {
+				userIds = append(userIds, auditEvent.UserId.Int64)
+			}
+			if auditEvent.ClientId.Valid {
+				clientIds = append(clientIds, auditEvent.ClientId.Int64)
+			}
+		}
+
+		users := map[int64]entities.User{}
+		if len(userIds) > 0 {
+			users, err = s.database.GetUsersByIds(nil, userIds)
+			if err != nil {
+				s.internalServerError(w, r, err)
+				return
+			}
+		}
+
+		clientIdentifiers := map[int64]string{}
+		if len(clientIds) > 0 {
+			clients, err := s.database.GetClientsByIds(nil, clientIds)
+			if err != nil {
+				s.internalServerError(w, r, err)
+				return
+			}
+			for _, client := range clients {
+				clientIdentifiers[client.Id] = client.ClientIdentifier
+			}
+		}
+
+		auditEventInfoArr := []auditEventInfo{}
+		for _, auditEvent := range auditEvents {
+			aei := auditEventInfo{
+				CreatedAt: auditEvent.CreatedAt.Time.Format(time.RFC1123),
+				Event:     auditEvent.Event,
+				Actor:     auditEvent.Actor,
+				IpAddress: auditEvent.IpAddress,
+				RequestId: auditEvent.RequestId,
+				UserAgent: auditEvent.UserAgent,
+				Details:   auditEvent.Details,
+			}
+			if auditEvent.UserId.Valid {
+				if user, ok := users[auditEvent.UserId.Int64]; ok {
+					aei.User = user.Email
+				} else {
+					aei.User = fmt.Sprintf("(deleted user %v)", auditEvent.UserId.Int64)
+				}
+			}
+			if auditEvent.ClientId.Valid {
+				if clientIdentifier, ok := clientIdentifiers[auditEvent.ClientId.Int64]; ok {
+					aei.Client = clientIdentifier
+				} else {
+					aei.Client = fmt.Sprintf("(deleted client %v)", auditEvent.ClientId.Int64)
+				}
+			}
+			auditEventInfoArr = append(auditEventInfoArr, aei)
+		}
+
+		pageResult := pageResult{
+			AuditEvents: auditEventInfoArr,
+			Total:       total,
+			Page:        pageInt,
+			PageSize:    pageSize,
+		}
+
+		p := paginater.New(total, pageSize, pageInt, 5)
+
+		filterQuery := filter.queryString()
+		paginatorUrl := "/admin/audit"
+		exportCsvUrl := "/admin/audit/export?format=csv"
+		exportJsonUrl := "/admin/audit/export?format=json"
+		if filterQuery != "" {
+			paginatorUrl += "?" + filterQuery
+			exportCsvUrl += "&" + filterQuery
+			exportJsonUrl += "&" + filterQuery
+		}
+
+		bind := map[string]interface{}{
+			"pageResult":    pageResult,
+			"paginator":     p,
+			"paginatorUrl":  paginatorUrl,
+			"exportCsvUrl":  exportCsvUrl,
+			"exportJsonUrl": exportJsonUrl,
+			"eventTypes":    eventTypes,
+			"filter":        filter,
+			"isFiltered":    filterQuery != "",
+			"filterError":   filterError,
+			"droppedEvents": lib.GetAuditDroppedEvents(),
+		}
+
+		err = s.renderTemplate(w, r, "/layouts/menu_layout.html", "/admin_audit.html", bind)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+	}
+}
+
+func (s *Server) handleAdminAuditExportGet() http.HandlerFunc {
+
+	type auditEventExport struct {
+		Id        int64           `json:"id"`
+		CreatedAt time.Time       `json:"created_at"`
+		Event     string          `json:"event"`
+		Actor     string          `json:"actor,omitempty"`
+		UserId    *int64          `json:"user_id,omitempty"`
+		ClientId  *int64          `json:"client_id,omitempty"`
+		IpAddress string          `json:"ip_address,omitempty"`
+		RequestId string          `json:"request_id,omitempty"`
+		UserAgent string          `json:"user_agent,omitempty"`
+		Details   json.RawMessage `json:"details,omitempty"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		format := r.URL.Query().Get("format")
+		if format != "csv" && format != "json" {
+			http.Error(w, "Invalid export format. Supported formats are csv and json.", http.StatusBadRequest)
+			return
+		}
+
+		filter, filterError, err := s.parseAuditEventFilter(r)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+		if filterError != "" {
+			http.Error(w, filterError, http.StatusBadRequest)
+			return
+		}
+
+		// the events are read in batches (keyset pagination) and streamed to the response,
+		// so large exports never need to fit in memory
+		const batchSize = 500
+		nextBatch := func(afterId int64) ([]entities.AuditEvent, error) {
+			return s.database.GetAuditEventsAfterId(nil, filter.Event, filter.userId,
+				filter.clientId, filter.from, filter.to, afterId, batchSize)
+		}
+
+		batch, err := nextBatch(0)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		fileName := fmt.Sprintf("audit_events_%v.%v", time.Now().UTC().Format("20060102150405"), format)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%v", fileName))
+
+		var writeAuditEvent func(auditEvent *entities.AuditEvent) error
+		var finish func() error
+
+		if format == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			_, err = w.Write([]byte("["))
+			first := true
+			writeAuditEvent = func(auditEvent *entities.AuditEvent) error {
+				aee := auditEventExport{
+					Id:        auditEvent.Id,
+					CreatedAt: auditEvent.CreatedAt.Time,
+					Event:     auditEvent.Event,
+					Actor:     auditEvent.Actor,
+					IpAddress: auditEvent.IpAddress,
+					RequestId: auditEvent.RequestId,
+					UserAgent: auditEvent.UserAgent,
+				}
+				if auditEvent.UserId.Valid {
+					userId := auditEvent.UserId.Int64
+					aee.UserId = &userId
+				}
+				if auditEvent.ClientId.Valid {
+					clientId := auditEvent.ClientId.Int64
+					aee.ClientId = &clientId
+				}
+				if json.Valid([]byte(auditEvent.Details)) {
+					aee.Details = json.RawMessage(auditEvent.Details)
+				}
+				aeeJson, err := json.Marshal(aee)
+				if err != nil {
+					return err
+				}
+				if !first {
+					aeeJson = append([]byte(","), aeeJson...)
+				}
+				first = false
+				_, err = w.Write(aeeJson)
+				return err
+			}
+			finish = func() error {
+				_, err := w.Write([]byte("]\n"))
+				return err
+			}
+		} else {
+			w.Header().Set("Content-Type", "text/csv")
+			csvWriter := csv.NewWriter(w)
+			err = csvWriter.Write([]string{"id", "created_at", "event", "actor", "user_id", "client_id",
+				"ip_address", "request_id", "user_agent", "details"})
+			writeAuditEvent = func(auditEvent *entities.AuditEvent) error {
+				userId := ""
+				if auditEvent.UserId.Valid {
+					userId = strconv.FormatInt(auditEvent.UserId.Int64, 10)
+				}
+				clientId := ""
+				if auditEvent.ClientId.Valid {
+					clientId = strconv.FormatInt(auditEvent.ClientId.Int64, 10)
+				}
+				return csvWriter.Write([]string{
+					strconv.FormatInt(auditEvent.Id, 10),
+					auditEvent.CreatedAt.Time.Format(time.RFC3339),
+					escapeCsvFormula(auditEvent.Event),
+					escapeCsvFormula(auditEvent.Actor),
+					userId,
+					clientId,
+					escapeCsvFormula(auditEvent.IpAddress),
+					escapeCsvFormula(auditEvent.RequestId),
+					escapeCsvFormula(auditEvent.UserAgent),
+					escapeCsvFormula(auditEvent.Details),
+				})
+			}
+			finish = func() error {
+				csvWriter.Flush()
+				return csvWriter.Error()
+			}
+		}
+
+		// once the response has started, errors can only be logged
+		for err == nil && len(batch) > 0 {
+			for i := range batch {
+				err = writeAuditEvent(&batch[i])
+				if err != nil {
+					break
+				}
+			}
+			if err != nil || len(batch) < batchSize {
+				break
+			}
+			batch, err = nextBatch(batch[len(batch)-1].Id)
+		}
+		if err == nil {
+			err = finish()
+		}
+		if err != nil {
+			slog.Error(fmt.Sprintf("unable to export the audit events: %+v", err))
+		}
+	}
+}
+
+// escapeCsvFormula prevents a value from being interpreted as a formula when the csv
+// is opened in a spreadsheet (CSV injection)
+func escapeCsvFormula(value string) string {
+	if len(value) > 0 && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
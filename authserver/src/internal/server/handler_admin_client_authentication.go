@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 
@@ -13,6 +15,7 @@ import (
 	"github.com/leodip/goiabada/internal/common"
 	"github.com/leodip/goiabada/internal/constants"
 	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
 	"github.com/leodip/goiabada/internal/lib"
 )
 
@@ -53,17 +56,25 @@ func (s *Server) handleAdminClientAuthenticationGet() http.HandlerFunc {
 		}
 
 		adminClientAuthentication := struct {
-			ClientId            int64
-			ClientIdentifier    string
-			IsPublic            bool
-			ClientSecret        string
-			IsSystemLevelClient bool
+			ClientId                int64
+			ClientIdentifier        string
+			IsPublic                bool
+			ClientSecret            string
+			TokenEndpointAuthMethod string
+			JWKS                    string
+			JWKSURI                 string
+			TLSClientAuthSubjectDN  string
+			IsSystemLevelClient     bool
 		}{
-			ClientId:            client.Id,
-			ClientIdentifier:    client.ClientIdentifier,
-			IsPublic:            client.IsPublic,
-			ClientSecret:        clientSecretDecrypted,
-			IsSystemLevelClient: client.IsSystemLevelClient(),
+			ClientId:                client.Id,
+			ClientIdentifier:        client.ClientIdentifier,
+			IsPublic:                client.IsPublic,
+			ClientSecret:            clientSecretDecrypted,
+			TokenEndpointAuthMethod: client.TokenEndpointAuthMethod,
+			JWKS:                    client.JWKS,
+			JWKSURI:                 client.JWKSURI,
+			TLSClientAuthSubjectDN:  client.TLSClientAuthSubjectDN,
+			IsSystemLevelClient:     client.IsSystemLevelClient(),
 		}
 
 		sess, err := s.sessionStore.Get(r, common.SessionName)
@@ -139,18 +150,35 @@ func (s *Server) handleAdminClientAuthenticationPost() http.HandlerFunc {
 			return
 		}
 
+		tokenEndpointAuthMethod := enums.TokenEndpointAuthMethodClientSecretBasic
+		if !isPublic {
+			tokenEndpointAuthMethod, err = enums.TokenEndpointAuthMethodFromString(r.FormValue("tokenEndpointAuthMethod"))
+			if err != nil {
+				s.internalServerError(w, r, err)
+				return
+			}
+		}
+
 		adminClientAuthentication := struct {
-			ClientId            int64
-			ClientIdentifier    string
-			IsPublic            bool
-			ClientSecret        string
-			IsSystemLevelClient bool
+			ClientId                int64
+			ClientIdentifier        string
+			IsPublic                bool
+			ClientSecret            string
+			TokenEndpointAuthMethod string
+			JWKS                    string
+			JWKSURI                 string
+			TLSClientAuthSubjectDN  string
+			IsSystemLevelClient     bool
 		}{
-			ClientId:            client.Id,
-			ClientIdentifier:    client.ClientIdentifier,
-			IsPublic:            isPublic,
-			ClientSecret:        r.FormValue("clientSecret"),
-			IsSystemLevelClient: isSystemLevelClient,
+			ClientId:                client.Id,
+			ClientIdentifier:        client.ClientIdentifier,
+			IsPublic:                isPublic,
+			ClientSecret:            r.FormValue("clientSecret"),
+			TokenEndpointAuthMethod: tokenEndpointAuthMethod.String(),
+			JWKS:                    strings.TrimSpace(r.FormValue("jwks")),
+			JWKSURI:                 strings.TrimSpace(r.FormValue("jwksUri")),
+			TLSClientAuthSubjectDN:  strings.TrimSpace(r.FormValue("tlsClientAuthSubjectDn")),
+			IsSystemLevelClient:     isSystemLevelClient,
 		}
 
 		renderError := func(message string) {
@@ -171,8 +199,61 @@ func (s *Server) handleAdminClientAuthenticationPost() http.HandlerFunc {
 			return
 		}
 
+		// the public keys are used to verify the client assertions (private_key_jwt), or
+		// to match the self-signed certificate presented by the client
+		usesJWKS := tokenEndpointAuthMethod == enums.TokenEndpointAuthMethodPrivateKeyJwt ||
+			tokenEndpointAuthMethod == enums.TokenEndpointAuthMethodSelfSignedTLSClientAuth
+
+		if usesJWKS {
+			if len(adminClientAuthentication.JWKS) == 0 && len(adminClientAuthentication.JWKSURI) == 0 {
+				renderError("Please provide the public keys of the client, either as a JWKS or as a JWKS URI.")
+				return
+			}
+			if len(adminClientAuthentication.JWKS) > 0 && len(adminClientAuthentication.JWKSURI) > 0 {
+				renderError("Please provide either a JWKS or a JWKS URI, not both.")
+				return
+			}
+			if len(adminClientAuthentication.JWKS) > 0 {
+				_, err = lib.ParseJSONWebKeySet([]byte(adminClientAuthentication.JWKS))
+				if err != nil {
+					renderError("Invalid JWKS: " + err.Error() + ".")
+					return
+				}
+			}
+			if len(adminClientAuthentication.JWKSURI) > 0 {
+				jwksURI, err := url.ParseRequestURI(adminClientAuthentication.JWKSURI)
+				if err != nil || (jwksURI.Scheme != "https" && jwksURI.Scheme != "http") || len(jwksURI.Host) == 0 {
+					renderError("Invalid JWKS URI. Please enter an absolute http or https URL.")
+					return
+				}
+			}
+		}
+
+		if tokenEndpointAuthMethod == enums.TokenEndpointAuthMethodTLSClientAuth {
+			if len(adminClientAuthentication.TLSClientAuthSubjectDN) == 0 {
+				renderError("Please provide the subject DN of the client certificate.")
+				return
+			}
+			if len(adminClientAuthentication.TLSClientAuthSubjectDN) > 512 {
+				renderError("The subject DN cannot exceed a maximum length of 512 characters.")
+				return
+			}
+		}
+
 		settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
 
+		client.TokenEndpointAuthMethod = adminClientAuthentication.TokenEndpointAuthMethod
+		client.JWKS = ""
+		client.JWKSURI = ""
+		client.TLSClientAuthSubjectDN = ""
+		if usesJWKS {
+			client.JWKS = adminClientAuthentication.JWKS
+			client.JWKSURI = adminClientAuthentication.JWKSURI
+		}
+		if tokenEndpointAuthMethod == enums.TokenEndpointAuthMethodTLSClientAuth {
+			client.TLSClientAuthSubjectDN = adminClientAuthentication.TLSClientAuthSubjectDN
+		}
+
 		if adminClientAuthentication.IsPublic {
 			client.IsPublic = true
 			client.ClientSecretEncrypted = nil
@@ -206,9 +287,10 @@ func (s *Server) handleAdminClientAuthenticationPost() http.HandlerFunc {
 			return
 		}
 
-		lib.LogAudit(constants.AuditUpdatedClientAuthentication, map[string]interface{}{
-			"clientId":     client.Id,
-			"loggedInUser": s.getLoggedInSubject(r),
+		lib.LogAudit(r.Context(), constants.AuditUpdatedClientAuthentication, map[string]interface{}{
+			"clientId":                client.Id,
+			"tokenEndpointAuthMethod": client.TokenEndpointAuthMethod,
+			"loggedInUser":            s.getLoggedInSubject(r),
 		})
 
 		http.Redirect(w, r, fmt.Sprintf("%v/admin/clients/%v/authentication", lib.GetBaseUrl(), client.Id), http.StatusFound)
@@ -0,0 +1,254 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/csrf"
+	"github.com/leodip/goiabada/internal/common"
+	"github.com/leodip/goiabada/internal/constants"
+	"github.com/leodip/goiabada/internal/lib"
+)
+
+type backchannelLogoutDeliveryInfo struct {
+	CreatedAt         string
+	Subject           string
+	SessionIdentifier string
+	Status            string
+	Attempts          int
+	LastError         string
+	DeliveredAt       string
+}
+
+func (s *Server) getBackchannelLogoutDeliveryInfos(clientId int64) ([]backchannelLogoutDeliveryInfo, error) {
+	deliveries, err := s.database.GetBackchannelLogoutDeliveriesByClientId(nil, clientId, 20)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveryInfos := []backchannelLogoutDeliveryInfo{}
+	for _, delivery := range deliveries {
+		deliveryInfo := backchannelLogoutDeliveryInfo{
+			CreatedAt:         delivery.CreatedAt.Time.Format(time.RFC1123),
+			Subject:           delivery.Subject,
+			SessionIdentifier: delivery.SessionIdentifier,
+			Status:            delivery.Status,
+			Attempts:          delivery.Attempts,
+			LastError:         delivery.LastError,
+		}
+		if delivery.DeliveredAt.Valid {
+			deliveryInfo.DeliveredAt = delivery.DeliveredAt.Time.Format(time.RFC1123)
+		}
+		deliveryInfos = append(deliveryInfos, deliveryInfo)
+	}
+	return deliveryInfos, nil
+}
+
+// isValidLogoutURI checks that a logout URI is an absolute http or https URL, without a fragment
+func isValidLogoutURI(logoutURI string) bool {
+	parsedURL, err := url.ParseRequestURI(logoutURI)
+	if err != nil {
+		return false
+	}
+	return (parsedURL.Scheme == "http" || parsedURL.Scheme == "https") &&
+		len(parsedURL.Host) > 0 && len(parsedURL.Fragment) == 0
+}
+
+func (s *Server) handleAdminClientLogoutGet() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		idStr := chi.URLParam(r, "clientId")
+		if len(idStr) == 0 {
+			s.internalServerError(w, r, errors.WithStack(errors.New("clientId is required")))
+			return
+		}
+
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+		client, err := s.database.GetClientById(nil, id)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+		if client == nil {
+			s.internalServerError(w, r, errors.WithStack(errors.New("client not found")))
+			return
+		}
+
+		adminClientLogout := struct {
+			ClientId              int64
+			ClientIdentifier      string
+			BackchannelLogoutURI  string
+			FrontchannelLogoutURI string
+			IsSystemLevelClient   bool
+		}{
+			ClientId:              client.Id,
+			ClientIdentifier:      client.ClientIdentifier,
+			BackchannelLogoutURI:  client.BackchannelLogoutURI,
+			FrontchannelLogoutURI: client.FrontchannelLogoutURI,
+			IsSystemLevelClient:   client.IsSystemLevelClient(),
+		}
+
+		deliveries, err := s.getBackchannelLogoutDeliveryInfos(client.Id)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		sess, err := s.sessionStore.Get(r, common.SessionName)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		savedSuccessfully := sess.Flashes("savedSuccessfully")
+		if savedSuccessfully != nil {
+			err = sess.Save(r, w)
+			if err != nil {
+				s.internalServerError(w, r, err)
+				return
+			}
+		}
+
+		bind := map[string]interface{}{
+			"client":            adminClientLogout,
+			"deliveries":        deliveries,
+			"savedSuccessfully": len(savedSuccessfully) > 0,
+			"csrfField":         csrf.TemplateField(r),
+		}
+
+		err = s.renderTemplate(w, r, "/layouts/menu_layout.html", "/admin_clients_logout.html", bind)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+	}
+}
+
+func (s *Server) handleAdminClientLogoutPost() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "clientId")
+		if len(idStr) == 0 {
+			s.internalServerError(w, r, errors.WithStack(errors.New("clientId is required")))
+			return
+		}
+
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		client, err := s.database.GetClientById(nil, id)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+		if client == nil {
+			s.internalServerError(w, r, errors.WithStack(errors.New("client not found")))
+			return
+		}
+
+		isSystemLevelClient := client.IsSystemLevelClient()
+		if isSystemLevelClient {
+			s.internalServerError(w, r, errors.WithStack(errors.New("trying to edit a system level client")))
+			return
+		}
+
+		adminClientLogout := struct {
+			ClientId              int64
+			ClientIdentifier      string
+			BackchannelLogoutURI  string
+			FrontchannelLogoutURI string
+			IsSystemLevelClient   bool
+		}{
+			ClientId:              client.Id,
+			ClientIdentifier:      client.ClientIdentifier,
+			BackchannelLogoutURI:  strings.TrimSpace(r.FormValue("backchannelLogoutURI")),
+			FrontchannelLogoutURI: strings.TrimSpace(r.FormValue("frontchannelLogoutURI")),
+			IsSystemLevelClient:   isSystemLevelClient,
+		}
+
+		renderError := func(message string) {
+			deliveries, err := s.getBackchannelLogoutDeliveryInfos(client.Id)
+			if err != nil {
+				s.internalServerError(w, r, err)
+				return
+			}
+
+			bind := map[string]interface{}{
+				"client":     adminClientLogout,
+				"deliveries": deliveries,
+				"error":      message,
+				"csrfField":  csrf.TemplateField(r),
+			}
+
+			err = s.renderTemplate(w, r, "/layouts/menu_layout.html", "/admin_clients_logout.html", bind)
+			if err != nil {
+				s.internalServerError(w, r, err)
+			}
+		}
+
+		const maxLengthLogoutURI = 512
+		if len(adminClientLogout.BackchannelLogoutURI) > maxLengthLogoutURI {
+			renderError("The back-channel logout URI cannot exceed a maximum length of " +
+				strconv.Itoa(maxLengthLogoutURI) + " characters.")
+			return
+		}
+		if len(adminClientLogout.FrontchannelLogoutURI) > maxLengthLogoutURI {
+			renderError("The front-channel logout URI cannot exceed a maximum length of " +
+				strconv.Itoa(maxLengthLogoutURI) + " characters.")
+			return
+		}
+
+		if len(adminClientLogout.BackchannelLogoutURI) > 0 && !isValidLogoutURI(adminClientLogout.BackchannelLogoutURI) {
+			renderError("Please enter a valid back-channel logout URI. It must be an absolute http or https URL, without a fragment.")
+			return
+		}
+		if len(adminClientLogout.FrontchannelLogoutURI) > 0 && !isValidLogoutURI(adminClientLogout.FrontchannelLogoutURI) {
+			renderError("Please enter a valid front-channel logout URI. It must be an absolute http or https URL, without a fragment.")
+			return
+		}
+
+		client.BackchannelLogoutURI = adminClientLogout.BackchannelLogoutURI
+		client.FrontchannelLogoutURI = adminClientLogout.FrontchannelLogoutURI
+
+		err = s.database.UpdateClient(nil, client)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		sess, err := s.sessionStore.Get(r, common.SessionName)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		sess.AddFlash("true", "savedSuccessfully")
+		err = sess.Save(r, w)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		lib.LogAudit(r.Context(), constants.AuditUpdatedClientLogout, map[string]interface{}{
+			"clientId":     client.Id,
+			"loggedInUser": s.getLoggedInSubject(r),
+		})
+
+		http.Redirect(w, r, fmt.Sprintf("%v/admin/clients/%v/logout", lib.GetBaseUrl(), client.Id), http.StatusFound)
+	}
+}
@@ -98,15 +98,18 @@ func (s *Server) handleAdminClientNewPost(identifierValidator identifierValidato
 		}
 
 		client := &entities.Client{
-			ClientIdentifier:         strings.TrimSpace(inputSanitizer.Sanitize(clientIdentifier)),
-			Description:              strings.TrimSpace(inputSanitizer.Sanitize(description)),
-			ClientSecretEncrypted:    clientSecretEncrypted,
-			IsPublic:                 false,
-			ConsentRequired:          false,
-			Enabled:                  true,
-			DefaultAcrLevel:          enums.AcrLevel2,
-			AuthorizationCodeEnabled: authorizationCodeEnabled,
-			ClientCredentialsEnabled: clientCredentialsEnabled,
+			ClientIdentifier:            strings.TrimSpace(inputSanitizer.Sanitize(clientIdentifier)),
+			Description:                 strings.TrimSpace(inputSanitizer.Sanitize(description)),
+			ClientSecretEncrypted:       clientSecretEncrypted,
+			IsPublic:                    false,
+			ConsentRequired:             false,
+			Enabled:                     true,
+			DefaultAcrLevel:             enums.AcrLevel2,
+			AuthorizationCodeEnabled:    authorizationCodeEnabled,
+			ClientCredentialsEnabled:    clientCredentialsEnabled,
+			RefreshTokenRotationEnabled: enums.ThreeStateSettingDefault.String(),
+			TokenEndpointAuthMethod:     enums.TokenEndpointAuthMethodClientSecretBasic.String(),
+			DPoPMode:                    enums.DPoPModeDisabled.String(),
 		}
 		err = s.database.CreateClient(nil, client)
 		if err != nil {
@@ -114,7 +117,7 @@ func (s *Server) handleAdminClientNewPost(identifierValidator identifierValidato
 			return
 		}
 
-		lib.LogAudit(constants.AuditCreatedClient, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditCreatedClient, map[string]interface{}{
 			"clientId":         client.Id,
 			"clientIdentifier": client.ClientIdentifier,
 			"loggedInUser":     s.getLoggedInSubject(r),
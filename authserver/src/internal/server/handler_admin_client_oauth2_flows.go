@@ -40,19 +40,25 @@ func (s *Server) handleAdminClientOAuth2Get() http.HandlerFunc {
 		}
 
 		adminClientOAuth2Flows := struct {
-			ClientId                 int64
-			ClientIdentifier         string
-			IsPublic                 bool
-			AuthorizationCodeEnabled bool
-			ClientCredentialsEnabled bool
-			IsSystemLevelClient      bool
+			ClientId                           int64
+			ClientIdentifier                   string
+			IsPublic                           bool
+			AuthorizationCodeEnabled           bool
+			ClientCredentialsEnabled           bool
+			DeviceCodeEnabled                  bool
+			RequirePushedAuthorizationRequests bool
+			RequireSignedRequestObject         bool
+			IsSystemLevelClient                bool
 		}{
-			ClientId:                 client.Id,
-			ClientIdentifier:         client.ClientIdentifier,
-			IsPublic:                 client.IsPublic,
-			AuthorizationCodeEnabled: client.AuthorizationCodeEnabled,
-			ClientCredentialsEnabled: client.ClientCredentialsEnabled,
-			IsSystemLevelClient:      client.IsSystemLevelClient(),
+			ClientId:                           client.Id,
+			ClientIdentifier:                   client.ClientIdentifier,
+			IsPublic:                           client.IsPublic,
+			AuthorizationCodeEnabled:           client.AuthorizationCodeEnabled,
+			ClientCredentialsEnabled:           client.ClientCredentialsEnabled,
+			DeviceCodeEnabled:                  client.DeviceCodeEnabled,
+			RequirePushedAuthorizationRequests: client.RequirePushedAuthorizationRequests,
+			RequireSignedRequestObject:         client.RequireSignedRequestObject,
+			IsSystemLevelClient:                client.IsSystemLevelClient(),
 		}
 
 		sess, err := s.sessionStore.Get(r, common.SessionName)
@@ -124,12 +130,62 @@ func (s *Server) handleAdminClientOAuth2Post() http.HandlerFunc {
 		if r.FormValue("clientCredentialsEnabled") == "on" {
 			clientCredentialsEnabled = true
 		}
+		deviceCodeEnabled := false
+		if r.FormValue("deviceCodeEnabled") == "on" {
+			deviceCodeEnabled = true
+		}
+		requirePushedAuthorizationRequests := false
+		if r.FormValue("requirePushedAuthorizationRequests") == "on" {
+			requirePushedAuthorizationRequests = true
+		}
+
+		requireSignedRequestObject := false
+		if r.FormValue("requireSignedRequestObject") == "on" {
+			requireSignedRequestObject = true
+		}
+
+		if authCodeEnabled && requireSignedRequestObject && len(client.JWKS) == 0 && len(client.JWKSURI) == 0 {
+			bind := map[string]interface{}{
+				"client": struct {
+					ClientId                           int64
+					ClientIdentifier                   string
+					IsPublic                           bool
+					AuthorizationCodeEnabled           bool
+					ClientCredentialsEnabled           bool
+					DeviceCodeEnabled                  bool
+					RequirePushedAuthorizationRequests bool
+					RequireSignedRequestObject         bool
+					IsSystemLevelClient                bool
+				}{
+					ClientId:                           client.Id,
+					ClientIdentifier:                   client.ClientIdentifier,
+					IsPublic:                           client.IsPublic,
+					AuthorizationCodeEnabled:           authCodeEnabled,
+					ClientCredentialsEnabled:           clientCredentialsEnabled,
+					DeviceCodeEnabled:                  deviceCodeEnabled,
+					RequirePushedAuthorizationRequests: requirePushedAuthorizationRequests,
+					RequireSignedRequestObject:         requireSignedRequestObject,
+					IsSystemLevelClient:                isSystemLevelClient,
+				},
+				"error":     "To require signed request objects, please register the public keys of the client (JWKS or JWKS URI) in the authentication tab first.",
+				"csrfField": csrf.TemplateField(r),
+			}
+
+			err = s.renderTemplate(w, r, "/layouts/menu_layout.html", "/admin_clients_oauth2_flows.html", bind)
+			if err != nil {
+				s.internalServerError(w, r, err)
+			}
+			return
+		}
 
 		client.AuthorizationCodeEnabled = authCodeEnabled
 		client.ClientCredentialsEnabled = clientCredentialsEnabled
 		if client.IsPublic {
 			client.ClientCredentialsEnabled = false
 		}
+		client.DeviceCodeEnabled = deviceCodeEnabled
+		client.RequirePushedAuthorizationRequests = authCodeEnabled && requirePushedAuthorizationRequests
+		client.RequireSignedRequestObject = authCodeEnabled && requireSignedRequestObject
 
 		err = s.database.UpdateClient(nil, client)
 		if err != nil {
@@ -150,7 +206,7 @@ func (s *Server) handleAdminClientOAuth2Post() http.HandlerFunc {
 			return
 		}
 
-		lib.LogAudit(constants.AuditUpdatedClientOAuth2Flows, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditUpdatedClientOAuth2Flows, map[string]interface{}{
 			"clientId":     client.Id,
 			"loggedInUser": s.getLoggedInSubject(r),
 		})
@@ -248,7 +248,7 @@ func (s *Server) handleAdminClientPermissionsPost() http.HandlerFunc {
 			return
 		}
 
-		lib.LogAudit(constants.AuditUpdatedClientPermissions, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditUpdatedClientPermissions, map[string]interface{}{
 			"clientId":     client.Id,
 			"loggedInUser": s.getLoggedInSubject(r),
 		})
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/csrf"
+	"github.com/leodip/goiabada/internal/common"
+	"github.com/leodip/goiabada/internal/constants"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/lib"
+)
+
+func (s *Server) handleAdminClientPostLogoutRedirectURIsGet() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		idStr := chi.URLParam(r, "clientId")
+		if len(idStr) == 0 {
+			s.internalServerError(w, r, errors.WithStack(errors.New("clientId is required")))
+			return
+		}
+
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+		client, err := s.database.GetClientById(nil, id)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+		if client == nil {
+			s.internalServerError(w, r, errors.WithStack(errors.New("client not found")))
+			return
+		}
+
+		err = s.database.ClientLoadPostLogoutRedirectURIs(nil, client)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		adminClientPostLogoutRedirectURIs := struct {
+			ClientId                 int64
+			ClientIdentifier         string
+			AuthorizationCodeEnabled bool
+			PostLogoutRedirectURIs   map[int64]string
+			IsSystemLevelClient      bool
+		}{
+			ClientId:                 client.Id,
+			ClientIdentifier:         client.ClientIdentifier,
+			AuthorizationCodeEnabled: client.AuthorizationCodeEnabled,
+			IsSystemLevelClient:      client.IsSystemLevelClient(),
+		}
+
+		sort.Slice(client.PostLogoutRedirectURIs, func(i, j int) bool {
+			return client.PostLogoutRedirectURIs[i].URI < client.PostLogoutRedirectURIs[j].URI
+		})
+
+		adminClientPostLogoutRedirectURIs.PostLogoutRedirectURIs = make(map[int64]string)
+		for _, postLogoutRedirectURI := range client.PostLogoutRedirectURIs {
+			adminClientPostLogoutRedirectURIs.PostLogoutRedirectURIs[postLogoutRedirectURI.Id] = postLogoutRedirectURI.URI
+		}
+
+		sess, err := s.sessionStore.Get(r, common.SessionName)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		savedSuccessfully := sess.Flashes("savedSuccessfully")
+		if savedSuccessfully != nil {
+			err = sess.Save(r, w)
+			if err != nil {
+				s.internalServerError(w, r, err)
+				return
+			}
+		}
+
+		bind := map[string]interface{}{
+			"client":            adminClientPostLogoutRedirectURIs,
+			"savedSuccessfully": len(savedSuccessfully) > 0,
+			"csrfField":         csrf.TemplateField(r),
+		}
+
+		err = s.renderTemplate(w, r, "/layouts/menu_layout.html", "/admin_clients_post_logout_redirect_uris.html", bind)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+	}
+}
+
+func (s *Server) handleAdminClientPostLogoutRedirectURIsPost() http.HandlerFunc {
+
+	type postLogoutRedirectURIsPostInput struct {
+		ClientId               int64    `json:"clientId"`
+		PostLogoutRedirectURIs []string `json:"postLogoutRedirectURIs"`
+		Ids                    []int64  `json:"ids"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+
+		var data postLogoutRedirectURIsPostInput
+		err = json.Unmarshal(body, &data)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+
+		client, err := s.database.GetClientById(nil, data.ClientId)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+		if client == nil {
+			s.jsonError(w, r, errors.WithStack(errors.New("client not found")))
+			return
+		}
+
+		if client.IsSystemLevelClient() {
+			s.jsonError(w, r, errors.WithStack(errors.New("trying to edit a system level client")))
+			return
+		}
+
+		err = s.database.ClientLoadPostLogoutRedirectURIs(nil, client)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		for idx, postLogoutURI := range data.PostLogoutRedirectURIs {
+			_, err := url.ParseRequestURI(postLogoutURI)
+			if err != nil {
+				s.jsonError(w, r, err)
+				return
+			}
+			id := data.Ids[idx]
+			if id == 0 {
+				// new post-logout redirect URI (add)
+				err := s.database.CreatePostLogoutRedirectURI(nil, &entities.PostLogoutRedirectURI{
+					ClientId: client.Id,
+					URI:      strings.TrimSpace(postLogoutURI),
+				})
+				if err != nil {
+					s.jsonError(w, r, err)
+					return
+				}
+			} else {
+				// existing post-logout redirect URI
+				found := false
+				for _, postLogoutRedirectURI := range client.PostLogoutRedirectURIs {
+					if postLogoutRedirectURI.Id == id {
+						found = true
+						break
+					}
+				}
+
+				if !found {
+					s.jsonError(w, r, errors.WithStack(fmt.Errorf("post-logout redirect URI with Id %d not found in client %v", id, client.ClientIdentifier)))
+					return
+				}
+			}
+		}
+
+		// delete post-logout redirect URIs that have been removed
+		toDelete := []int64{}
+		for _, postLogoutRedirectURI := range client.PostLogoutRedirectURIs {
+			found := false
+			for _, id := range data.Ids {
+				if postLogoutRedirectURI.Id == id {
+					found = true
+					break
+				}
+			}
+			if !found {
+				toDelete = append(toDelete, postLogoutRedirectURI.Id)
+			}
+		}
+
+		for _, id := range toDelete {
+			err := s.database.DeletePostLogoutRedirectURI(nil, id)
+			if err != nil {
+				s.jsonError(w, r, err)
+				return
+			}
+		}
+
+		sess, err := s.sessionStore.Get(r, common.SessionName)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+
+		sess.AddFlash("true", "savedSuccessfully")
+		err = sess.Save(r, w)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+
+		lib.LogAudit(r.Context(), constants.AuditUpdatedPostLogoutRedirectURIs, map[string]interface{}{
+			"clientId":     client.Id,
+			"loggedInUser": s.getLoggedInSubject(r),
+		})
+
+		result := struct {
+			Success bool
+		}{
+			Success: true,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
@@ -203,13 +203,26 @@ func (s *Server) handleAdminClientUserSessionsPost() http.HandlerFunc {
 			return
 		}
 
+		userSession, err := s.database.GetUserSessionById(nil, int64(userSessionId))
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+		if userSession != nil {
+			err = s.backchannelLogoutNotifier.QueueLogout(userSession)
+			if err != nil {
+				s.jsonError(w, r, err)
+				return
+			}
+		}
+
 		err = s.database.DeleteUserSession(nil, int64(userSessionId))
 		if err != nil {
 			s.jsonError(w, r, err)
 			return
 		}
 
-		lib.LogAudit(constants.AuditDeletedUserSession, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditDeletedUserSession, map[string]interface{}{
 			"userSessionId": userSessionId,
 			"loggedInUser":  s.getLoggedInSubject(r),
 		})
@@ -50,6 +50,8 @@ func (s *Server) handleAdminClientSettingsGet() http.HandlerFunc {
 			ConsentRequired          bool
 			AuthorizationCodeEnabled bool
 			DefaultAcrLevel          string
+			SMSOTPAllowedAcrLevel2   bool
+			SMSOTPAllowedAcrLevel3   bool
 			IsSystemLevelClient      bool
 		}{
 			ClientId:                 client.Id,
@@ -59,6 +61,8 @@ func (s *Server) handleAdminClientSettingsGet() http.HandlerFunc {
 			ConsentRequired:          client.ConsentRequired,
 			AuthorizationCodeEnabled: client.AuthorizationCodeEnabled,
 			DefaultAcrLevel:          client.DefaultAcrLevel.String(),
+			SMSOTPAllowedAcrLevel2:   client.SMSOTPAllowedAcrLevel2,
+			SMSOTPAllowedAcrLevel3:   client.SMSOTPAllowedAcrLevel3,
 			IsSystemLevelClient:      client.IsSystemLevelClient(),
 		}
 
@@ -140,6 +144,8 @@ func (s *Server) handleAdminClientSettingsPost(identifierValidator identifierVal
 			ConsentRequired          bool
 			AuthorizationCodeEnabled bool
 			DefaultAcrLevel          string
+			SMSOTPAllowedAcrLevel2   bool
+			SMSOTPAllowedAcrLevel3   bool
 			IsSystemLevelClient      bool
 		}{
 			ClientId:                 id,
@@ -149,6 +155,8 @@ func (s *Server) handleAdminClientSettingsPost(identifierValidator identifierVal
 			ConsentRequired:          consentRequired,
 			AuthorizationCodeEnabled: client.AuthorizationCodeEnabled,
 			DefaultAcrLevel:          r.FormValue("defaultAcrLevel"),
+			SMSOTPAllowedAcrLevel2:   r.FormValue("smsOtpAllowedAcrLevel2") == "on",
+			SMSOTPAllowedAcrLevel3:   r.FormValue("smsOtpAllowedAcrLevel3") == "on",
 			IsSystemLevelClient:      isSystemLevelClient,
 		}
 
@@ -205,6 +213,8 @@ func (s *Server) handleAdminClientSettingsPost(identifierValidator identifierVal
 				return
 			}
 			client.DefaultAcrLevel = acrLevel
+			client.SMSOTPAllowedAcrLevel2 = adminClientSettings.SMSOTPAllowedAcrLevel2
+			client.SMSOTPAllowedAcrLevel3 = adminClientSettings.SMSOTPAllowedAcrLevel3
 		}
 
 		err = s.database.UpdateClient(nil, client)
@@ -226,7 +236,7 @@ func (s *Server) handleAdminClientSettingsPost(identifierValidator identifierVal
 			return
 		}
 
-		lib.LogAudit(constants.AuditUpdatedClientSettings, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditUpdatedClientSettings, map[string]interface{}{
 			"clientId":     client.Id,
 			"loggedInUser": s.getLoggedInSubject(r),
 		})
@@ -3,6 +3,7 @@ package server
 import (
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 
 	"github.com/pkg/errors"
@@ -45,11 +46,15 @@ func (s *Server) handleAdminClientTokensGet() http.HandlerFunc {
 			RefreshTokenOfflineIdleTimeoutInSeconds int
 			RefreshTokenOfflineMaxLifetimeInSeconds int
 			IncludeOpenIDConnectClaimsInAccessToken string
+			RefreshTokenRotationEnabled             string
+			IdTokenSignedResponseAlg                string
 		}{
 			TokenExpirationInSeconds:                client.TokenExpirationInSeconds,
 			RefreshTokenOfflineIdleTimeoutInSeconds: client.RefreshTokenOfflineIdleTimeoutInSeconds,
 			RefreshTokenOfflineMaxLifetimeInSeconds: client.RefreshTokenOfflineMaxLifetimeInSeconds,
 			IncludeOpenIDConnectClaimsInAccessToken: client.IncludeOpenIDConnectClaimsInAccessToken,
+			RefreshTokenRotationEnabled:             client.RefreshTokenRotationEnabled,
+			IdTokenSignedResponseAlg:                client.IdTokenSignedResponseAlg,
 		}
 
 		sess, err := s.sessionStore.Get(r, common.SessionName)
@@ -67,8 +72,15 @@ func (s *Server) handleAdminClientTokensGet() http.HandlerFunc {
 			}
 		}
 
+		algorithms, err := s.getIdTokenSigningAlgorithms()
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
 		bind := map[string]interface{}{
 			"settings":          settingsInfo,
+			"algorithms":        algorithms,
 			"client":            client,
 			"savedSuccessfully": len(savedSuccessfully) > 0,
 			"csrfField":         csrf.TemplateField(r),
@@ -118,20 +130,31 @@ func (s *Server) handleAdminClientTokensPost() http.HandlerFunc {
 			RefreshTokenOfflineIdleTimeoutInSeconds string
 			RefreshTokenOfflineMaxLifetimeInSeconds string
 			IncludeOpenIDConnectClaimsInAccessToken string
+			RefreshTokenRotationEnabled             string
+			IdTokenSignedResponseAlg                string
 		}{
 			TokenExpirationInSeconds:                r.FormValue("tokenExpirationInSeconds"),
 			RefreshTokenOfflineIdleTimeoutInSeconds: r.FormValue("refreshTokenOfflineIdleTimeoutInSeconds"),
 			RefreshTokenOfflineMaxLifetimeInSeconds: r.FormValue("refreshTokenOfflineMaxLifetimeInSeconds"),
 			IncludeOpenIDConnectClaimsInAccessToken: r.FormValue("includeOpenIDConnectClaimsInAccessToken"),
+			RefreshTokenRotationEnabled:             r.FormValue("refreshTokenRotationEnabled"),
+			IdTokenSignedResponseAlg:                r.FormValue("idTokenSignedResponseAlg"),
+		}
+
+		algorithms, err := s.getIdTokenSigningAlgorithms()
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
 		}
 
 		renderError := func(message string) {
 
 			bind := map[string]interface{}{
-				"settings":  settingsInfo,
-				"client":    client,
-				"csrfField": csrf.TemplateField(r),
-				"error":     message,
+				"settings":   settingsInfo,
+				"algorithms": algorithms,
+				"client":     client,
+				"csrfField":  csrf.TemplateField(r),
+				"error":      message,
 			}
 
 			err := s.renderTemplate(w, r, "/layouts/menu_layout.html", "/admin_clients_tokens.html", bind)
@@ -188,10 +211,38 @@ func (s *Server) handleAdminClientTokensPost() http.HandlerFunc {
 			return
 		}
 
+		refreshTokenRotationSetting, err := enums.ThreeStateSettingFromString(settingsInfo.RefreshTokenRotationEnabled)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		dpopMode, err := enums.DPoPModeFromString(r.FormValue("dpopMode"))
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		if len(settingsInfo.IdTokenSignedResponseAlg) > 0 {
+			_, err = enums.SigningAlgorithmFromString(settingsInfo.IdTokenSignedResponseAlg)
+			if err != nil {
+				renderError("Invalid value for the ID token signing algorithm.")
+				return
+			}
+			if !slices.Contains(algorithms, settingsInfo.IdTokenSignedResponseAlg) {
+				renderError("The ID token signing algorithm must be the algorithm of the current signing key.")
+				return
+			}
+		}
+
 		client.TokenExpirationInSeconds = tokenExpirationInSeconds
 		client.RefreshTokenOfflineIdleTimeoutInSeconds = refreshTokenOfflineIdleTimeoutInSeconds
 		client.RefreshTokenOfflineMaxLifetimeInSeconds = refreshTokenOfflineMaxLifetimeInSeconds
 		client.IncludeOpenIDConnectClaimsInAccessToken = threeStateSetting.String()
+		client.RefreshTokenRotationEnabled = refreshTokenRotationSetting.String()
+		client.IdTokenSignedResponseAlg = settingsInfo.IdTokenSignedResponseAlg
+		client.DPoPMode = dpopMode.String()
+		client.DPoPNonceRequired = r.FormValue("dpopNonceRequired") == "on"
 
 		err = s.database.UpdateClient(nil, client)
 		if err != nil {
@@ -212,8 +263,9 @@ func (s *Server) handleAdminClientTokensPost() http.HandlerFunc {
 			return
 		}
 
-		lib.LogAudit(constants.AuditUpdatedClientTokens, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditUpdatedClientTokens, map[string]interface{}{
 			"clientId":     client.Id,
+			"dpopMode":     client.DPoPMode,
 			"loggedInUser": s.getLoggedInSubject(r),
 		})
 
@@ -216,7 +216,7 @@ func (s *Server) handleAdminClientWebOriginsPost() http.HandlerFunc {
 			return
 		}
 
-		lib.LogAudit(constants.AuditUpdatedWebOrigins, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditUpdatedWebOrigins, map[string]interface{}{
 			"clientId":     client.Id,
 			"loggedInUser": s.getLoggedInSubject(r),
 		})
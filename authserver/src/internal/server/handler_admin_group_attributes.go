@@ -122,7 +122,7 @@ func (s *Server) handleAdminGroupAttributesRemovePost() http.HandlerFunc {
 			return
 		}
 
-		lib.LogAudit(constants.AuditDeleteGroupAttribute, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditDeleteGroupAttribute, map[string]interface{}{
 			"groupAttributeId": attributeId,
 			"groupId":          group.Id,
 			"groupIdentifier":  group.GroupIdentifier,
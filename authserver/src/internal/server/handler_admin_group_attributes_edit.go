@@ -165,7 +165,7 @@ func (s *Server) handleAdminGroupAttributesEditPost(identifierValidator identifi
 			return
 		}
 
-		lib.LogAudit(constants.AuditUpdatedGroupAttribute, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditUpdatedGroupAttribute, map[string]interface{}{
 			"groupAttributeId": attribute.Id,
 			"groupId":          group.Id,
 			"groupIdentifier":  group.GroupIdentifier,
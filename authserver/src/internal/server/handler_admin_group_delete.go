@@ -119,7 +119,7 @@ func (s *Server) handleAdminGroupDeletePost() http.HandlerFunc {
 			return
 		}
 
-		lib.LogAudit(constants.AuditDeletedGroup, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditDeletedGroup, map[string]interface{}{
 			"groupId":         group.Id,
 			"groupIdentifier": group.GroupIdentifier,
 			"loggedInUser":    s.getLoggedInSubject(r),
@@ -200,7 +200,7 @@ func (s *Server) handleAdminGroupMembersAddPost() http.HandlerFunc {
 			return
 		}
 
-		lib.LogAudit(constants.AuditUserAddedToGroup, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditUserAddedToGroup, map[string]interface{}{
 			"userId":       user.Id,
 			"groupId":      group.Id,
 			"loggedInUser": s.getLoggedInSubject(r),
@@ -156,7 +156,7 @@ func (s *Server) handleAdminGroupSettingsPost(identifierValidator identifierVali
 			return
 		}
 
-		lib.LogAudit(constants.AuditUpdatedGroup, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditUpdatedGroup, map[string]interface{}{
 			"groupId":         group.Id,
 			"groupIdentifier": group.GroupIdentifier,
 			"loggedInUser":    s.getLoggedInSubject(r),
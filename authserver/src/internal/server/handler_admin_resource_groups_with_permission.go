@@ -333,7 +333,7 @@ func (s *Server) handleAdminResourceGroupsWithPermissionAddPermissionPost() http
 			return
 		}
 
-		lib.LogAudit(constants.AuditAddedGroupPermission, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditAddedGroupPermission, map[string]interface{}{
 			"groupId":      group.Id,
 			"permissionId": permissionId,
 			"loggedInUser": s.getLoggedInSubject(r),
@@ -478,7 +478,7 @@ func (s *Server) handleAdminResourceGroupsWithPermissionRemovePermissionPost() h
 			return
 		}
 
-		lib.LogAudit(constants.AuditDeletedGroupPermission, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditDeletedGroupPermission, map[string]interface{}{
 			"groupId":      group.Id,
 			"permissionId": permissionId,
 			"loggedInUser": s.getLoggedInSubject(r),
@@ -264,7 +264,7 @@ func (s *Server) handleAdminResourcePermissionsPost(identifierValidator identifi
 			return
 		}
 
-		lib.LogAudit(constants.AuditUpdatedResourcePermissions, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditUpdatedResourcePermissions, map[string]interface{}{
 			"resourceId":   resource.Id,
 			"loggedInUser": s.getLoggedInSubject(r),
 		})
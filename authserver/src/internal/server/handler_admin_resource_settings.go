@@ -171,7 +171,7 @@ func (s *Server) handleAdminResourceSettingsPost(identifierValidator identifierV
 			return
 		}
 
-		lib.LogAudit(constants.AuditUpdatedResource, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditUpdatedResource, map[string]interface{}{
 			"resourceId":         resource.Id,
 			"resourceIdentifier": resource.ResourceIdentifier,
 			"loggedInUser":       s.getLoggedInSubject(r),
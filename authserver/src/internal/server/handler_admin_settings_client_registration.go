@@ -0,0 +1,147 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/csrf"
+	"github.com/leodip/goiabada/internal/common"
+	"github.com/leodip/goiabada/internal/constants"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/leodip/goiabada/internal/lib"
+)
+
+func (s *Server) handleAdminSettingsClientRegistrationGet() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
+
+		initialAccessToken := ""
+		if len(settings.ClientRegistrationInitialAccessTokenEncrypted) > 0 {
+			var err error
+			initialAccessToken, err = lib.DecryptText(settings.ClientRegistrationInitialAccessTokenEncrypted, settings.AESEncryptionKey)
+			if err != nil {
+				s.internalServerError(w, r, err)
+				return
+			}
+		}
+
+		settingsInfo := struct {
+			ClientRegistrationMode string
+			InitialAccessToken     string
+		}{
+			ClientRegistrationMode: settings.ClientRegistrationMode,
+			InitialAccessToken:     initialAccessToken,
+		}
+
+		sess, err := s.sessionStore.Get(r, common.SessionName)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		savedSuccessfully := sess.Flashes("savedSuccessfully")
+		if savedSuccessfully != nil {
+			err = sess.Save(r, w)
+			if err != nil {
+				s.internalServerError(w, r, err)
+				return
+			}
+		}
+
+		bind := map[string]interface{}{
+			"settings":             settingsInfo,
+			"registrationEndpoint": lib.GetBaseUrl() + "/connect/register",
+			"savedSuccessfully":    len(savedSuccessfully) > 0,
+			"csrfField":            csrf.TemplateField(r),
+		}
+
+		err = s.renderTemplate(w, r, "/layouts/menu_layout.html", "/admin_settings_client_registration.html", bind)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+	}
+}
+
+func (s *Server) handleAdminSettingsClientRegistrationPost() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		settingsInfo := struct {
+			ClientRegistrationMode string
+			InitialAccessToken     string
+		}{
+			ClientRegistrationMode: r.FormValue("clientRegistrationMode"),
+			InitialAccessToken:     r.FormValue("initialAccessToken"),
+		}
+
+		renderError := func(message string) {
+
+			bind := map[string]interface{}{
+				"settings":             settingsInfo,
+				"registrationEndpoint": lib.GetBaseUrl() + "/connect/register",
+				"csrfField":            csrf.TemplateField(r),
+				"error":                message,
+			}
+
+			err := s.renderTemplate(w, r, "/layouts/menu_layout.html", "/admin_settings_client_registration.html", bind)
+			if err != nil {
+				s.internalServerError(w, r, err)
+			}
+		}
+
+		registrationMode, err := enums.ClientRegistrationModeFromString(settingsInfo.ClientRegistrationMode)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		if registrationMode == enums.ClientRegistrationModeInitialAccessToken && len(settingsInfo.InitialAccessToken) != 60 {
+			renderError("Invalid initial access token. Please generate a new one.")
+			return
+		}
+
+		settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
+		settings.ClientRegistrationMode = registrationMode.String()
+
+		if len(settingsInfo.InitialAccessToken) > 0 {
+			initialAccessTokenEncrypted, err := lib.EncryptText(settingsInfo.InitialAccessToken, settings.AESEncryptionKey)
+			if err != nil {
+				s.internalServerError(w, r, err)
+				return
+			}
+			settings.ClientRegistrationInitialAccessTokenEncrypted = initialAccessTokenEncrypted
+		} else {
+			settings.ClientRegistrationInitialAccessTokenEncrypted = nil
+		}
+
+		err = s.database.UpdateSettings(nil, settings)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		lib.LogAudit(r.Context(), constants.AuditUpdatedClientRegistrationSettings, map[string]interface{}{
+			"clientRegistrationMode": settings.ClientRegistrationMode,
+			"loggedInUser":           s.getLoggedInSubject(r),
+		})
+
+		sess, err := s.sessionStore.Get(r, common.SessionName)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		sess.AddFlash("true", "savedSuccessfully")
+		err = sess.Save(r, w)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		http.Redirect(w, r, fmt.Sprintf("%v/admin/settings/client-registration", lib.GetBaseUrl()), http.StatusFound)
+	}
+}
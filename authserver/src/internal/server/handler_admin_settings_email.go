@@ -223,7 +223,7 @@ func (s *Server) handleAdminSettingsEmailPost(emailValidator emailValidator, inp
 			return
 		}
 
-		lib.LogAudit(constants.AuditUpdatedSMTPSettings, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditUpdatedSMTPSettings, map[string]interface{}{
 			"loggedInUser": s.getLoggedInSubject(r),
 		})
 
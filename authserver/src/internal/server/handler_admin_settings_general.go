@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/gorilla/csrf"
@@ -27,12 +28,14 @@ func (s *Server) handleAdminSettingsGeneralGet() http.HandlerFunc {
 			SelfRegistrationEnabled                   bool
 			SelfRegistrationRequiresEmailVerification bool
 			PasswordPolicy                            string
+			AuditLogRetentionInDays                   string
 		}{
 			AppName:                 settings.AppName,
 			Issuer:                  settings.Issuer,
 			SelfRegistrationEnabled: settings.SelfRegistrationEnabled,
 			SelfRegistrationRequiresEmailVerification: settings.SelfRegistrationRequiresEmailVerification,
-			PasswordPolicy: settings.PasswordPolicy.String(),
+			PasswordPolicy:          settings.PasswordPolicy.String(),
+			AuditLogRetentionInDays: strconv.Itoa(settings.AuditLogRetentionInDays),
 		}
 
 		sess, err := s.sessionStore.Get(r, common.SessionName)
@@ -74,12 +77,14 @@ func (s *Server) handleAdminSettingsGeneralPost(inputSanitizer inputSanitizer) h
 			SelfRegistrationEnabled                   bool
 			SelfRegistrationRequiresEmailVerification bool
 			PasswordPolicy                            string
+			AuditLogRetentionInDays                   string
 		}{
 			AppName:                 strings.TrimSpace(r.FormValue("appName")),
 			Issuer:                  strings.TrimSpace(r.FormValue("issuer")),
 			SelfRegistrationEnabled: r.FormValue("selfRegistrationEnabled") == "on",
 			SelfRegistrationRequiresEmailVerification: r.FormValue("selfRegistrationRequiresEmailVerification") == "on",
-			PasswordPolicy: r.FormValue("passwordPolicy"),
+			PasswordPolicy:          r.FormValue("passwordPolicy"),
+			AuditLogRetentionInDays: strings.TrimSpace(r.FormValue("auditLogRetentionInDays")),
 		}
 
 		renderError := func(message string) {
@@ -142,6 +147,18 @@ func (s *Server) handleAdminSettingsGeneralPost(inputSanitizer inputSanitizer) h
 			return
 		}
 
+		auditLogRetentionInDays, err := strconv.Atoi(settingsInfo.AuditLogRetentionInDays)
+		if err != nil {
+			renderError("Invalid value for audit log retention in days.")
+			return
+		}
+
+		const maxAuditLogRetentionInDays = 3650
+		if auditLogRetentionInDays < 0 || auditLogRetentionInDays > maxAuditLogRetentionInDays {
+			renderError(fmt.Sprintf("Audit log retention in days must be between 0 and %v.", maxAuditLogRetentionInDays))
+			return
+		}
+
 		settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
 		settings.AppName = inputSanitizer.Sanitize(settingsInfo.AppName)
 		settings.Issuer = inputSanitizer.Sanitize(settingsInfo.Issuer)
@@ -152,6 +169,7 @@ func (s *Server) handleAdminSettingsGeneralPost(inputSanitizer inputSanitizer) h
 			settings.SelfRegistrationRequiresEmailVerification = false
 		}
 		settings.PasswordPolicy = passwordPolicy
+		settings.AuditLogRetentionInDays = auditLogRetentionInDays
 
 		err = s.database.UpdateSettings(nil, settings)
 		if err != nil {
@@ -159,7 +177,7 @@ func (s *Server) handleAdminSettingsGeneralPost(inputSanitizer inputSanitizer) h
 			return
 		}
 
-		lib.LogAudit(constants.AuditUpdatedGeneralSettings, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditUpdatedGeneralSettings, map[string]interface{}{
 			"loggedInUser": s.getLoggedInSubject(r),
 		})
 
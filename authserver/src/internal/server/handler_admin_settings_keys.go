@@ -1,15 +1,15 @@
 package server
 
 import (
-	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
-	"encoding/pem"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
-	"github.com/google/uuid"
 	"github.com/gorilla/csrf"
+	"github.com/leodip/goiabada/internal/common"
 	"github.com/leodip/goiabada/internal/constants"
 	"github.com/leodip/goiabada/internal/entities"
 	"github.com/leodip/goiabada/internal/enums"
@@ -17,76 +17,43 @@ import (
 	"github.com/pkg/errors"
 )
 
-func (s *Server) handleAdminSettingsKeysGet() http.HandlerFunc {
+type keyRotationSettingsInfo struct {
+	KeyRotationIntervalInDays string
+	KeyRotationOverlapInDays  string
+}
 
-	type keyInfo struct {
-		Id               int64
-		CreatedAt        string
-		State            string
-		KeyIdentifier    string
-		Type             string
-		Algorithm        string
-		PublicKeyASN1DER string
-		PublicKeyPEM     string
-		PublicKeyJWK     string
-	}
+func (s *Server) handleAdminSettingsKeysGet() http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		allSigningKeys, err := s.database.GetAllSigningKeys(nil)
+		settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
+
+		bind, err := s.getAdminSettingsKeysBind(r, settings)
 		if err != nil {
 			s.internalServerError(w, r, err)
 			return
 		}
 
-		keys := make([]keyInfo, 0, len(allSigningKeys))
-		for _, signingKey := range allSigningKeys {
+		sess, err := s.sessionStore.Get(r, common.SessionName)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
 
-			keyState, err := enums.KeyStateFromString(signingKey.State)
+		savedSuccessfully := sess.Flashes("savedSuccessfully")
+		if savedSuccessfully != nil {
+			err = sess.Save(r, w)
 			if err != nil {
 				s.internalServerError(w, r, err)
 				return
 			}
-
-			ki := keyInfo{
-				Id:            signingKey.Id,
-				CreatedAt:     signingKey.CreatedAt.Time.Format("02 Jan 2006 15:04:05 MST"),
-				State:         keyState.String(),
-				KeyIdentifier: signingKey.KeyIdentifier,
-				Type:          signingKey.Type,
-				Algorithm:     signingKey.Algorithm,
-			}
-
-			ki.PublicKeyASN1DER = base64.StdEncoding.EncodeToString(signingKey.PublicKeyASN1_DER)
-			ki.PublicKeyPEM = string(signingKey.PublicKeyPEM)
-			ki.PublicKeyJWK = string(signingKey.PublicKeyJWK)
-
-			keys = append(keys, ki)
-		}
-
-		orderedKeys := make([]keyInfo, 0, len(keys))
-		for _, ki := range keys {
-			if ki.State == enums.KeyStateNext.String() {
-				orderedKeys = append(orderedKeys, ki)
-				break
-			}
-		}
-		for _, ki := range keys {
-			if ki.State == enums.KeyStateCurrent.String() {
-				orderedKeys = append(orderedKeys, ki)
-				break
-			}
-		}
-		for _, ki := range keys {
-			if ki.State == enums.KeyStatePrevious.String() {
-				orderedKeys = append(orderedKeys, ki)
-			}
 		}
 
-		bind := map[string]interface{}{
-			"keys":      orderedKeys,
-			"csrfField": csrf.TemplateField(r),
+		bind["settings"] = keyRotationSettingsInfo{
+			KeyRotationIntervalInDays: strconv.Itoa(settings.KeyRotationIntervalInDays),
+			KeyRotationOverlapInDays:  strconv.Itoa(settings.KeyRotationOverlapInDays),
 		}
+		bind["savedSuccessfully"] = len(savedSuccessfully) > 0
 
 		err = s.renderTemplate(w, r, "/layouts/menu_layout.html", "/admin_settings_keys.html", bind)
 		if err != nil {
@@ -96,116 +63,205 @@ func (s *Server) handleAdminSettingsKeysGet() http.HandlerFunc {
 	}
 }
 
-func (s *Server) handleAdminSettingsKeysRotatePost() http.HandlerFunc {
+func (s *Server) handleAdminSettingsKeysPost() http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		allSigningKeys, err := s.database.GetAllSigningKeys(nil)
-		if err != nil {
-			s.jsonError(w, r, err)
-			return
+		settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
+
+		settingsInfo := keyRotationSettingsInfo{
+			KeyRotationIntervalInDays: strings.TrimSpace(r.FormValue("keyRotationIntervalInDays")),
+			KeyRotationOverlapInDays:  strings.TrimSpace(r.FormValue("keyRotationOverlapInDays")),
 		}
 
-		var currentKey *entities.KeyPair
-		var nextKey *entities.KeyPair
-		var previousKey *entities.KeyPair
-		for i, signingKey := range allSigningKeys {
-			keyState, err := enums.KeyStateFromString(signingKey.State)
+		renderError := func(message string) {
+			bind, err := s.getAdminSettingsKeysBind(r, settings)
 			if err != nil {
-				s.jsonError(w, r, err)
+				s.internalServerError(w, r, err)
 				return
 			}
-			switch keyState {
-			case enums.KeyStateCurrent:
-				currentKey = &allSigningKeys[i]
-			case enums.KeyStateNext:
-				nextKey = &allSigningKeys[i]
-			case enums.KeyStatePrevious:
-				previousKey = &allSigningKeys[i]
-			}
-		}
+			bind["settings"] = settingsInfo
+			bind["error"] = message
 
-		if previousKey != nil {
-			err = s.database.DeleteKeyPair(nil, previousKey.Id)
+			err = s.renderTemplate(w, r, "/layouts/menu_layout.html", "/admin_settings_keys.html", bind)
 			if err != nil {
-				s.jsonError(w, r, err)
-				return
+				s.internalServerError(w, r, err)
 			}
 		}
 
-		if currentKey == nil {
-			s.jsonError(w, r, errors.WithStack(fmt.Errorf("no current key found")))
+		const maxKeyRotationIntervalInDays = 3650
+
+		keyRotationIntervalInDays, err := strconv.Atoi(settingsInfo.KeyRotationIntervalInDays)
+		if err != nil {
+			renderError("Invalid value for key rotation interval in days.")
 			return
 		}
-
-		if nextKey == nil {
-			s.jsonError(w, r, errors.WithStack(fmt.Errorf("no next key found")))
+		if keyRotationIntervalInDays < 0 || keyRotationIntervalInDays > maxKeyRotationIntervalInDays {
+			renderError(fmt.Sprintf("Key rotation interval in days must be between 0 and %v.", maxKeyRotationIntervalInDays))
 			return
 		}
 
-		// current key becomes previous
-		currentKey.State = enums.KeyStatePrevious.String()
-		err = s.database.UpdateKeyPair(nil, currentKey)
+		keyRotationOverlapInDays, err := strconv.Atoi(settingsInfo.KeyRotationOverlapInDays)
 		if err != nil {
-			s.jsonError(w, r, err)
+			renderError("Invalid value for key rotation overlap in days.")
+			return
+		}
+		if keyRotationOverlapInDays < 0 || keyRotationOverlapInDays > maxKeyRotationIntervalInDays {
+			renderError(fmt.Sprintf("Key rotation overlap in days must be between 0 and %v.", maxKeyRotationIntervalInDays))
+			return
+		}
+		if keyRotationIntervalInDays > 0 && keyRotationOverlapInDays >= keyRotationIntervalInDays {
+			renderError("The key rotation overlap must be shorter than the rotation interval.")
 			return
 		}
 
-		// next key becomes current
-		nextKey.State = enums.KeyStateCurrent.String()
-		err = s.database.UpdateKeyPair(nil, nextKey)
+		settings.KeyRotationIntervalInDays = keyRotationIntervalInDays
+		settings.KeyRotationOverlapInDays = keyRotationOverlapInDays
+
+		err = s.database.UpdateSettings(nil, settings)
 		if err != nil {
-			s.jsonError(w, r, err)
+			s.internalServerError(w, r, err)
 			return
 		}
 
-		// create a new next key
-		privateKey, err := lib.GeneratePrivateKey(4096)
+		lib.LogAudit(r.Context(), constants.AuditUpdatedKeysSettings, map[string]interface{}{
+			"loggedInUser": s.getLoggedInSubject(r),
+		})
+
+		sess, err := s.sessionStore.Get(r, common.SessionName)
 		if err != nil {
-			s.jsonError(w, r, errors.Wrap(err, "unable to generate a private key"))
+			s.internalServerError(w, r, err)
 			return
 		}
-		privateKeyPEM := lib.EncodePrivateKeyToPEM(privateKey)
 
-		publicKeyASN1_DER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+		sess.AddFlash("true", "savedSuccessfully")
+		err = sess.Save(r, w)
 		if err != nil {
-			s.jsonError(w, r, errors.Wrap(err, "unable to marshal public key to PKIX"))
+			s.internalServerError(w, r, err)
 			return
 		}
 
-		publicKeyPEM := pem.EncodeToMemory(
-			&pem.Block{
-				Type:  "RSA PUBLIC KEY",
-				Bytes: publicKeyASN1_DER,
-			},
-		)
+		http.Redirect(w, r, fmt.Sprintf("%v/admin/settings/keys", lib.GetBaseUrl()), http.StatusFound)
+	}
+}
+
+// getAdminSettingsKeysBind loads the signing keys, ordered as next, current and previous, along
+// with the automatic rotation schedule
+func (s *Server) getAdminSettingsKeysBind(r *http.Request, settings *entities.Settings) (map[string]interface{}, error) {
+
+	type keyInfo struct {
+		Id               int64
+		CreatedAt        string
+		State            string
+		KeyIdentifier    string
+		Type             string
+		Algorithm        string
+		PublicKeyASN1DER string
+		PublicKeyPEM     string
+		PublicKeyJWK     string
+	}
+
+	allSigningKeys, err := s.database.GetAllSigningKeys(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]keyInfo, 0, len(allSigningKeys))
+	for _, signingKey := range allSigningKeys {
 
-		kid := uuid.New().String()
-		publicKeyJWK, err := lib.MarshalRSAPublicKeyToJWK(&privateKey.PublicKey, kid)
+		keyState, err := enums.KeyStateFromString(signingKey.State)
 		if err != nil {
-			s.jsonError(w, r, err)
-			return
+			return nil, err
 		}
 
-		keyPair := &entities.KeyPair{
-			State:             enums.KeyStateNext.String(),
-			KeyIdentifier:     kid,
-			Type:              "RSA",
-			Algorithm:         "RS256",
-			PrivateKeyPEM:     privateKeyPEM,
-			PublicKeyPEM:      publicKeyPEM,
-			PublicKeyASN1_DER: publicKeyASN1_DER,
-			PublicKeyJWK:      publicKeyJWK,
+		ki := keyInfo{
+			Id:            signingKey.Id,
+			CreatedAt:     signingKey.CreatedAt.Time.Format("02 Jan 2006 15:04:05 MST"),
+			State:         keyState.String(),
+			KeyIdentifier: signingKey.KeyIdentifier,
+			Type:          signingKey.Type,
+			Algorithm:     signingKey.Algorithm,
 		}
-		err = s.database.CreateKeyPair(nil, keyPair)
-		if err != nil {
+
+		ki.PublicKeyASN1DER = base64.StdEncoding.EncodeToString(signingKey.PublicKeyASN1_DER)
+		ki.PublicKeyPEM = string(signingKey.PublicKeyPEM)
+		ki.PublicKeyJWK = string(signingKey.PublicKeyJWK)
+
+		keys = append(keys, ki)
+	}
+
+	orderedKeys := make([]keyInfo, 0, len(keys))
+	for _, ki := range keys {
+		if ki.State == enums.KeyStateNext.String() {
+			orderedKeys = append(orderedKeys, ki)
+			break
+		}
+	}
+	for _, ki := range keys {
+		if ki.State == enums.KeyStateCurrent.String() {
+			orderedKeys = append(orderedKeys, ki)
+			break
+		}
+	}
+	for _, ki := range keys {
+		if ki.State == enums.KeyStatePrevious.String() {
+			orderedKeys = append(orderedKeys, ki)
+		}
+	}
+
+	nextKeyAlgorithm := enums.SigningAlgorithmRS256.String()
+	if len(orderedKeys) > 0 && orderedKeys[0].State == enums.KeyStateNext.String() {
+		nextKeyAlgorithm = orderedKeys[0].Algorithm
+	}
+
+	schedule, err := s.keyRotator.GetSchedule(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	nextRotation := ""
+	if !schedule.NextRotation.IsZero() {
+		nextRotation = schedule.NextRotation.Format("02 Jan 2006 15:04:05 MST")
+	}
+	previousKeyRevocation := ""
+	if !schedule.PreviousKeyRevocation.IsZero() {
+		previousKeyRevocation = schedule.PreviousKeyRevocation.Format("02 Jan 2006 15:04:05 MST")
+	}
+
+	bind := map[string]interface{}{
+		"keys":                  orderedKeys,
+		"algorithms":            getSigningAlgorithms(),
+		"nextKeyAlgorithm":      nextKeyAlgorithm,
+		"nextRotation":          nextRotation,
+		"previousKeyRevocation": previousKeyRevocation,
+		"csrfField":             csrf.TemplateField(r),
+	}
+	return bind, nil
+}
+
+func (s *Server) handleAdminSettingsKeysRotatePost() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		var data map[string]interface{}
+		decoder := json.NewDecoder(r.Body)
+		if err := decoder.Decode(&data); err != nil {
 			s.jsonError(w, r, err)
 			return
 		}
 
-		lib.LogAudit(constants.AuditRotatedKeys, map[string]interface{}{
+		// the new next key keeps the algorithm of the existing one, unless another is chosen
+		algorithm, _ := data["algorithm"].(string)
+
+		settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
+
+		err := s.keyRotator.RotateKeys(r.Context(), settings, algorithm, map[string]interface{}{
 			"loggedInUser": s.getLoggedInSubject(r),
 		})
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
 
 		result := struct {
 			Success bool
@@ -257,17 +313,14 @@ func (s *Server) handleAdminSettingsKeysRevokePost() http.HandlerFunc {
 			return
 		}
 
-		err = s.database.DeleteKeyPair(nil, previousKey.Id)
+		err = s.keyRotator.RevokeKey(r.Context(), previousKey, map[string]interface{}{
+			"loggedInUser": s.getLoggedInSubject(r),
+		})
 		if err != nil {
 			s.jsonError(w, r, err)
 			return
 		}
 
-		lib.LogAudit(constants.AuditRevokedKey, map[string]interface{}{
-			"loggedInUser": s.getLoggedInSubject(r),
-			"keyId":        previousKey.KeyIdentifier,
-		})
-
 		result := struct {
 			Success bool
 		}{
@@ -277,3 +330,24 @@ func (s *Server) handleAdminSettingsKeysRevokePost() http.HandlerFunc {
 		json.NewEncoder(w).Encode(result)
 	}
 }
+
+func getSigningAlgorithms() []string {
+	algorithms := []string{}
+	for _, algorithm := range enums.SigningAlgorithms() {
+		algorithms = append(algorithms, algorithm.String())
+	}
+	return algorithms
+}
+
+// getIdTokenSigningAlgorithms returns the algorithms id tokens can be signed with. Id tokens are
+// only signed with the current key, so that's the algorithm of the current key
+func (s *Server) getIdTokenSigningAlgorithms() ([]string, error) {
+	currentKey, err := s.database.GetCurrentSigningKey(nil)
+	if err != nil {
+		return nil, err
+	}
+	if currentKey == nil {
+		return []string{}, nil
+	}
+	return []string{currentKey.Algorithm}, nil
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/csrf"
+	"github.com/leodip/goiabada/internal/common"
+	"github.com/leodip/goiabada/internal/constants"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/lib"
+)
+
+func (s *Server) handleAdminSettingsSecurityGet() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
+
+		settingsInfo := struct {
+			AccountLockoutThreshold          string
+			AccountLockoutDurationInSeconds  string
+			AccountPermanentLockoutThreshold string
+			FailedLoginDelayInMilliseconds   string
+		}{
+			AccountLockoutThreshold:          strconv.Itoa(settings.AccountLockoutThreshold),
+			AccountLockoutDurationInSeconds:  strconv.Itoa(settings.AccountLockoutDurationInSeconds),
+			AccountPermanentLockoutThreshold: strconv.Itoa(settings.AccountPermanentLockoutThreshold),
+			FailedLoginDelayInMilliseconds:   strconv.Itoa(settings.FailedLoginDelayInMilliseconds),
+		}
+
+		sess, err := s.sessionStore.Get(r, common.SessionName)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		savedSuccessfully := sess.Flashes("savedSuccessfully")
+		if savedSuccessfully != nil {
+			err = sess.Save(r, w)
+			if err != nil {
+				s.internalServerError(w, r, err)
+				return
+			}
+		}
+
+		bind := map[string]interface{}{
+			"settings":          settingsInfo,
+			"savedSuccessfully": len(savedSuccessfully) > 0,
+			"csrfField":         csrf.TemplateField(r),
+		}
+
+		err = s.renderTemplate(w, r, "/layouts/menu_layout.html", "/admin_settings_security.html", bind)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+	}
+}
+
+func (s *Server) handleAdminSettingsSecurityPost() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		settingsInfo := struct {
+			AccountLockoutThreshold          string
+			AccountLockoutDurationInSeconds  string
+			AccountPermanentLockoutThreshold string
+			FailedLoginDelayInMilliseconds   string
+		}{
+			AccountLockoutThreshold:          strings.TrimSpace(r.FormValue("accountLockoutThreshold")),
+			AccountLockoutDurationInSeconds:  strings.TrimSpace(r.FormValue("accountLockoutDurationInSeconds")),
+			AccountPermanentLockoutThreshold: strings.TrimSpace(r.FormValue("accountPermanentLockoutThreshold")),
+			FailedLoginDelayInMilliseconds:   strings.TrimSpace(r.FormValue("failedLoginDelayInMilliseconds")),
+		}
+
+		renderError := func(message string) {
+
+			bind := map[string]interface{}{
+				"settings":  settingsInfo,
+				"csrfField": csrf.TemplateField(r),
+				"error":     message,
+			}
+
+			err := s.renderTemplate(w, r, "/layouts/menu_layout.html", "/admin_settings_security.html", bind)
+			if err != nil {
+				s.internalServerError(w, r, err)
+			}
+		}
+
+		accountLockoutThreshold, err := strconv.Atoi(settingsInfo.AccountLockoutThreshold)
+		if err != nil {
+			renderError("Invalid value for account lockout - failed attempts threshold.")
+			return
+		}
+
+		accountLockoutDurationInSeconds, err := strconv.Atoi(settingsInfo.AccountLockoutDurationInSeconds)
+		if err != nil {
+			renderError("Invalid value for account lockout - duration in seconds.")
+			return
+		}
+
+		accountPermanentLockoutThreshold, err := strconv.Atoi(settingsInfo.AccountPermanentLockoutThreshold)
+		if err != nil {
+			renderError("Invalid value for permanent lockout - failed attempts threshold.")
+			return
+		}
+
+		failedLoginDelayInMilliseconds, err := strconv.Atoi(settingsInfo.FailedLoginDelayInMilliseconds)
+		if err != nil {
+			renderError("Invalid value for progressive delay - initial delay in milliseconds.")
+			return
+		}
+
+		const maxThreshold = 1000
+		if accountLockoutThreshold < 0 || accountLockoutThreshold > maxThreshold {
+			renderError(fmt.Sprintf("Account lockout - failed attempts threshold must be between 0 and %v.", maxThreshold))
+			return
+		}
+
+		if accountPermanentLockoutThreshold < 0 || accountPermanentLockoutThreshold > maxThreshold {
+			renderError(fmt.Sprintf("Permanent lockout - failed attempts threshold must be between 0 and %v.", maxThreshold))
+			return
+		}
+
+		if accountLockoutThreshold > 0 && accountPermanentLockoutThreshold > 0 &&
+			accountPermanentLockoutThreshold <= accountLockoutThreshold {
+			renderError("Permanent lockout - failed attempts threshold must be greater than the account lockout threshold.")
+			return
+		}
+
+		const maxLockoutDurationInSeconds = 2592000 // 30 days
+		if accountLockoutThreshold > 0 &&
+			(accountLockoutDurationInSeconds <= 0 || accountLockoutDurationInSeconds > maxLockoutDurationInSeconds) {
+			renderError(fmt.Sprintf("Account lockout - duration in seconds must be between 1 and %v.", maxLockoutDurationInSeconds))
+			return
+		}
+
+		const maxFailedLoginDelayInMilliseconds = 10000
+		if failedLoginDelayInMilliseconds < 0 || failedLoginDelayInMilliseconds > maxFailedLoginDelayInMilliseconds {
+			renderError(fmt.Sprintf("Progressive delay - initial delay in milliseconds must be between 0 and %v.", maxFailedLoginDelayInMilliseconds))
+			return
+		}
+
+		settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
+		settings.AccountLockoutThreshold = accountLockoutThreshold
+		if accountLockoutThreshold > 0 {
+			settings.AccountLockoutDurationInSeconds = accountLockoutDurationInSeconds
+		}
+		settings.AccountPermanentLockoutThreshold = accountPermanentLockoutThreshold
+		settings.FailedLoginDelayInMilliseconds = failedLoginDelayInMilliseconds
+
+		err = s.database.UpdateSettings(nil, settings)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		lib.LogAudit(r.Context(), constants.AuditUpdatedSecuritySettings, map[string]interface{}{
+			"loggedInUser": s.getLoggedInSubject(r),
+		})
+
+		sess, err := s.sessionStore.Get(r, common.SessionName)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		sess.AddFlash("true", "savedSuccessfully")
+		err = sess.Save(r, w)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		http.Redirect(w, r, fmt.Sprintf("%v/admin/settings/security", lib.GetBaseUrl()), http.StatusFound)
+	}
+}
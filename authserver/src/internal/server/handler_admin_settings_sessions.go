@@ -132,7 +132,7 @@ func (s *Server) handleAdminSettingsSessionsPost() http.HandlerFunc {
 			return
 		}
 
-		lib.LogAudit(constants.AuditUpdatedSessionsSettings, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditUpdatedSessionsSettings, map[string]interface{}{
 			"loggedInUser": s.getLoggedInSubject(r),
 		})
 
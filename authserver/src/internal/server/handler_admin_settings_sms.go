@@ -167,7 +167,7 @@ func (s *Server) handleAdminSettingsSMSPost(inputSanitizer inputSanitizer) http.
 			return
 		}
 
-		lib.LogAudit(constants.AuditUpdatedSMSSettings, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditUpdatedSMSSettings, map[string]interface{}{
 			"loggedInUser": s.getLoggedInSubject(r),
 		})
 
@@ -23,11 +23,13 @@ func (s *Server) handleAdminSettingsTokensGet() http.HandlerFunc {
 			RefreshTokenOfflineIdleTimeoutInSeconds int
 			RefreshTokenOfflineMaxLifetimeInSeconds int
 			IncludeOpenIDConnectClaimsInAccessToken bool
+			RefreshTokenRotationEnabled             bool
 		}{
 			TokenExpirationInSeconds:                settings.TokenExpirationInSeconds,
 			RefreshTokenOfflineIdleTimeoutInSeconds: settings.RefreshTokenOfflineIdleTimeoutInSeconds,
 			RefreshTokenOfflineMaxLifetimeInSeconds: settings.RefreshTokenOfflineMaxLifetimeInSeconds,
 			IncludeOpenIDConnectClaimsInAccessToken: settings.IncludeOpenIDConnectClaimsInAccessToken,
+			RefreshTokenRotationEnabled:             settings.RefreshTokenRotationEnabled,
 		}
 
 		sess, err := s.sessionStore.Get(r, common.SessionName)
@@ -70,11 +72,13 @@ func (s *Server) handleAdminSettingsTokensPost() http.HandlerFunc {
 			RefreshTokenOfflineIdleTimeoutInSeconds string
 			RefreshTokenOfflineMaxLifetimeInSeconds string
 			IncludeOpenIDConnectClaimsInAccessToken bool
+			RefreshTokenRotationEnabled             bool
 		}{
 			TokenExpirationInSeconds:                r.FormValue("tokenExpirationInSeconds"),
 			RefreshTokenOfflineIdleTimeoutInSeconds: r.FormValue("refreshTokenOfflineIdleTimeoutInSeconds"),
 			RefreshTokenOfflineMaxLifetimeInSeconds: r.FormValue("refreshTokenOfflineMaxLifetimeInSeconds"),
 			IncludeOpenIDConnectClaimsInAccessToken: r.FormValue("includeOpenIDConnectClaimsInAccessToken") == "on",
+			RefreshTokenRotationEnabled:             r.FormValue("refreshTokenRotationEnabled") == "on",
 		}
 
 		renderError := func(message string) {
@@ -152,6 +156,7 @@ func (s *Server) handleAdminSettingsTokensPost() http.HandlerFunc {
 		settings.RefreshTokenOfflineIdleTimeoutInSeconds = refreshTokenOfflineIdleTimeoutInSeconds
 		settings.RefreshTokenOfflineMaxLifetimeInSeconds = refreshTokenOfflineMaxLifetimeInSeconds
 		settings.IncludeOpenIDConnectClaimsInAccessToken = settingsInfo.IncludeOpenIDConnectClaimsInAccessToken
+		settings.RefreshTokenRotationEnabled = settingsInfo.RefreshTokenRotationEnabled
 
 		err = s.database.UpdateSettings(nil, settings)
 		if err != nil {
@@ -159,7 +164,7 @@ func (s *Server) handleAdminSettingsTokensPost() http.HandlerFunc {
 			return
 		}
 
-		lib.LogAudit(constants.AuditUpdatedTokensSettings, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditUpdatedTokensSettings, map[string]interface{}{
 			"loggedInUser": s.getLoggedInSubject(r),
 		})
 
@@ -104,7 +104,7 @@ func (s *Server) handleAdminSettingsUIThemePost() http.HandlerFunc {
 			return
 		}
 
-		lib.LogAudit(constants.AuditUpdatedUIThemeSettings, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditUpdatedUIThemeSettings, map[string]interface{}{
 			"loggedInUser": s.getLoggedInSubject(r),
 		})
 
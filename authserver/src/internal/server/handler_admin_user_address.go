@@ -189,7 +189,7 @@ func (s *Server) handleAdminUserAddressPost(addressValidator addressValidator,
 			return
 		}
 
-		lib.LogAudit(constants.AuditUpdatedUserAddress, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditUpdatedUserAddress, map[string]interface{}{
 			"userId":       user.Id,
 			"loggedInUser": s.getLoggedInSubject(r),
 		})
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -12,6 +13,7 @@ import (
 	"github.com/gorilla/csrf"
 	"github.com/leodip/goiabada/internal/common"
 	"github.com/leodip/goiabada/internal/constants"
+	"github.com/leodip/goiabada/internal/enums"
 	"github.com/leodip/goiabada/internal/lib"
 )
 
@@ -58,6 +60,7 @@ func (s *Server) handleAdminUserAuthenticationGet() http.HandlerFunc {
 		bind := map[string]interface{}{
 			"user":              user,
 			"otpEnabled":        user.OTPEnabled,
+			"isLocked":          user.IsLocked(time.Now().UTC()),
 			"page":              r.URL.Query().Get("page"),
 			"query":             r.URL.Query().Get("query"),
 			"savedSuccessfully": len(savedSuccessfully) > 0,
@@ -102,6 +105,7 @@ func (s *Server) handleAdminUserAuthenticationPost(passwordValidator passwordVal
 			bind := map[string]interface{}{
 				"user":       user,
 				"otpEnabled": r.FormValue("otpEnabled") == "on",
+				"isLocked":   user.IsLocked(time.Now().UTC()),
 				"page":       r.URL.Query().Get("page"),
 				"query":      r.URL.Query().Get("query"),
 				"csrfField":  csrf.TemplateField(r),
@@ -132,11 +136,28 @@ func (s *Server) handleAdminUserAuthenticationPost(passwordValidator passwordVal
 			user.ForgotPasswordCodeIssuedAt = sql.NullTime{Valid: false}
 		}
 
+		otpDisabled := false
 		if user.OTPEnabled {
 			otpEnabled := r.FormValue("otpEnabled") == "on"
 			if !otpEnabled {
 				user.OTPEnabled = false
 				user.OTPSecret = ""
+				user.OTPMethod = enums.OTPMethodTOTP.String()
+				user.OTPCodeEncrypted = nil
+				user.OTPCodeIssuedAt = sql.NullTime{Valid: false}
+				otpDisabled = true
+			}
+		}
+
+		unlocked := false
+		if user.IsLocked(time.Now().UTC()) {
+			accountLocked := r.FormValue("accountLocked") == "on"
+			if !accountLocked {
+				user.FailedLoginAttempts = 0
+				user.LastFailedLoginAt = sql.NullTime{Valid: false}
+				user.LockedUntil = sql.NullTime{Valid: false}
+				user.LockedPermanently = false
+				unlocked = true
 			}
 		}
 
@@ -146,6 +167,21 @@ func (s *Server) handleAdminUserAuthenticationPost(passwordValidator passwordVal
 			return
 		}
 
+		if otpDisabled {
+			err = s.database.DeleteUserRecoveryCodesByUserId(nil, user.Id)
+			if err != nil {
+				s.internalServerError(w, r, err)
+				return
+			}
+		}
+
+		if unlocked {
+			lib.LogAudit(r.Context(), constants.AuditUnlockedUserAccount, map[string]interface{}{
+				"userId":       user.Id,
+				"loggedInUser": s.getLoggedInSubject(r),
+			})
+		}
+
 		sess, err := s.sessionStore.Get(r, common.SessionName)
 		if err != nil {
 			s.internalServerError(w, r, err)
@@ -159,7 +195,7 @@ func (s *Server) handleAdminUserAuthenticationPost(passwordValidator passwordVal
 			return
 		}
 
-		lib.LogAudit(constants.AuditUpdatedUserAuthentication, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditUpdatedUserAuthentication, map[string]interface{}{
 			"userId":       user.Id,
 			"loggedInUser": s.getLoggedInSubject(r),
 		})
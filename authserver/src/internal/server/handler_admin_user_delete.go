@@ -84,7 +84,7 @@ func (s *Server) handleAdminUserDeletePost() http.HandlerFunc {
 			return
 		}
 
-		lib.LogAudit(constants.AuditDeletedUser, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditDeletedUser, map[string]interface{}{
 			"userId":       user.Id,
 			"loggedInUser": s.getLoggedInSubject(r),
 		})
@@ -117,7 +117,7 @@ func (s *Server) handleAdminUserDetailsPost() http.HandlerFunc {
 			return
 		}
 
-		lib.LogAudit(constants.AuditUpdatedUserDetails, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditUpdatedUserDetails, map[string]interface{}{
 			"userId":       user.Id,
 			"loggedInUser": s.getLoggedInSubject(r),
 		})
@@ -157,7 +157,7 @@ func (s *Server) handleAdminUserEmailPost(emailValidator emailValidator,
 			return
 		}
 
-		lib.LogAudit(constants.AuditUpdatedUserEmail, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditUpdatedUserEmail, map[string]interface{}{
 			"userId":       user.Id,
 			"loggedInUser": s.getLoggedInSubject(r),
 		})
@@ -169,7 +169,7 @@ func (s *Server) handleAdminUserGroupsPost() http.HandlerFunc {
 					return
 				}
 
-				lib.LogAudit(constants.AuditUserAddedToGroup, map[string]interface{}{
+				lib.LogAudit(r.Context(), constants.AuditUserAddedToGroup, map[string]interface{}{
 					"userId":       user.Id,
 					"groupId":      group.Id,
 					"loggedInUser": s.getLoggedInSubject(r),
@@ -212,7 +212,7 @@ func (s *Server) handleAdminUserGroupsPost() http.HandlerFunc {
 				return
 			}
 
-			lib.LogAudit(constants.AuditUserRemovedFromGroup, map[string]interface{}{
+			lib.LogAudit(r.Context(), constants.AuditUserRemovedFromGroup, map[string]interface{}{
 				"userId":       user.Id,
 				"groupId":      group.Id,
 				"loggedInUser": s.getLoggedInSubject(r),
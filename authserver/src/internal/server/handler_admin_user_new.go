@@ -147,7 +147,7 @@ func (s *Server) handleAdminUserNewPost(userCreator userCreator, profileValidato
 			return
 		}
 
-		lib.LogAudit(constants.AuditCreatedUser, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditCreatedUser, map[string]interface{}{
 			"email":        user.Email,
 			"loggedInUser": s.getLoggedInSubject(r),
 		})
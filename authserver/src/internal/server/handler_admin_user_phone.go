@@ -174,7 +174,7 @@ func (s *Server) handleAdminUserPhonePost(phoneValidator phoneValidator,
 			return
 		}
 
-		lib.LogAudit(constants.AuditUpdatedUserPhone, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditUpdatedUserPhone, map[string]interface{}{
 			"userId":       user.Id,
 			"loggedInUser": s.getLoggedInSubject(r),
 		})
@@ -220,7 +220,7 @@ func (s *Server) handleAdminUserProfilePost(profileValidator profileValidator,
 			return
 		}
 
-		lib.LogAudit(constants.AuditUpdatedUserProfile, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditUpdatedUserProfile, map[string]interface{}{
 			"userId":       user.Id,
 			"loggedInUser": s.getLoggedInSubject(r),
 		})
@@ -174,13 +174,19 @@ func (s *Server) handleAdminUserSessionsPost() http.HandlerFunc {
 
 		for _, us := range allUserSessions {
 			if us.Id == int64(userSessionId) {
-				err := s.database.DeleteUserSession(nil, us.Id)
+				err := s.backchannelLogoutNotifier.QueueLogout(&us)
 				if err != nil {
 					s.jsonError(w, r, err)
 					return
 				}
 
-				lib.LogAudit(constants.AuditDeletedUserSession, map[string]interface{}{
+				err = s.database.DeleteUserSession(nil, us.Id)
+				if err != nil {
+					s.jsonError(w, r, err)
+					return
+				}
+
+				lib.LogAudit(r.Context(), constants.AuditDeletedUserSession, map[string]interface{}{
 					"userSessionId": us.Id,
 					"loggedInUser":  s.getLoggedInSubject(r),
 				})
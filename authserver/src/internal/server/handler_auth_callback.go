@@ -99,8 +99,10 @@ func (s *Server) handleAuthCallbackPost(tokenIssuer tokenIssuer, tokenValidator
 			Code:         code,
 			RedirectURI:  redirectURI,
 			CodeVerifier: codeVerifier,
-			ClientId:     client.ClientIdentifier,
-			ClientSecret: clientSecretDecrypted,
+			ClientCredentials: core_validators.ClientCredentials{
+				ClientId:     client.ClientIdentifier,
+				ClientSecret: clientSecretDecrypted,
+			},
 		}
 
 		validateTokenRequestResult, err := tokenValidator.ValidateTokenRequest(r.Context(), &input)
@@ -1,6 +1,8 @@
 package server
 
 import (
+	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -9,12 +11,92 @@ import (
 	"github.com/gorilla/csrf"
 	"github.com/leodip/goiabada/internal/common"
 	"github.com/leodip/goiabada/internal/constants"
+	"github.com/leodip/goiabada/internal/dtos"
 	"github.com/leodip/goiabada/internal/entities"
 	"github.com/leodip/goiabada/internal/enums"
 	"github.com/leodip/goiabada/internal/lib"
 	"github.com/pquerna/otp/totp"
 )
 
+// getAuthOtpBind returns the data shared by all renders of the OTP pages
+func (s *Server) getAuthOtpBind(r *http.Request, authContext *dtos.AuthContext,
+	user *entities.User) (map[string]interface{}, error) {
+
+	webAuthnCredentials, err := s.database.GetWebAuthnCredentialsByUserId(nil, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := s.database.GetClientByClientIdentifier(nil, authContext.ClientId)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, errors.WithStack(errors.New("client not found"))
+	}
+
+	otpMethod := getAuthOtpMethod(authContext, user)
+	otpDestination := user.Email
+	if otpMethod == enums.OTPMethodSMS {
+		otpDestination = maskPhoneNumber(user.PhoneNumber)
+	}
+
+	// codes are sent only from a POST (the send button), never when the page is loaded
+	otpCodeSent := otpMethod == enums.OTPMethodTOTP || hasPendingOTPCode(user, time.Now().UTC())
+
+	bind := map[string]interface{}{
+		"error":          nil,
+		"csrfField":      csrf.TemplateField(r),
+		"hasPasskeys":    len(webAuthnCredentials) > 0,
+		"otpMethod":      otpMethod.String(),
+		"otpDestination": otpDestination,
+		"smsAvailable":   otpMethod != enums.OTPMethodSMS && isSMSOTPAvailable(r, authContext, user, client),
+		"otpCodeSent":    otpCodeSent,
+	}
+	return bind, nil
+}
+
+// renderAuthOtpError shows the error in the OTP page, or in the enrollment page if the user
+// is enrolling in OTP now
+func (s *Server) renderAuthOtpError(w http.ResponseWriter, r *http.Request, authContext *dtos.AuthContext,
+	user *entities.User, message string) {
+
+	bind, err := s.getAuthOtpBind(r, authContext, user)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+	bind["error"] = message
+
+	template := "/auth_otp.html"
+	if !user.OTPEnabled && getAuthOtpMethod(authContext, user) != enums.OTPMethodSMS {
+		sess, err := s.sessionStore.Get(r, common.SessionName)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		base64Image, secretKey := "", ""
+		if val, ok := sess.Values[common.SessionKeyOTPImage]; ok {
+			base64Image = val.(string)
+		}
+		if val, ok := sess.Values[common.SessionKeyOTPSecret]; ok {
+			secretKey = val.(string)
+		}
+
+		if len(base64Image) > 0 && len(secretKey) > 0 {
+			template = "/auth_otp_enrollment.html"
+			bind["base64Image"] = base64Image
+			bind["secretKey"] = secretKey
+		}
+	}
+
+	err = s.renderTemplate(w, r, "/layouts/auth_layout.html", template, bind)
+	if err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
 func (s *Server) handleAuthOtpGet(otpSecretGenerator otpSecretGenerator) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -36,7 +118,15 @@ func (s *Server) handleAuthOtpGet(otpSecretGenerator otpSecretGenerator) http.Ha
 			return
 		}
 
-		if !user.OTPEnabled {
+		bind, err := s.getAuthOtpBind(r, authContext, user)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		otpMethod := getAuthOtpMethod(authContext, user)
+
+		if !user.OTPEnabled && otpMethod != enums.OTPMethodSMS {
 			// must enroll first
 
 			// generate secret
@@ -47,12 +137,8 @@ func (s *Server) handleAuthOtpGet(otpSecretGenerator otpSecretGenerator) http.Ha
 				return
 			}
 
-			bind := map[string]interface{}{
-				"error":       nil,
-				"csrfField":   csrf.TemplateField(r),
-				"base64Image": base64Image,
-				"secretKey":   secretKey,
-			}
+			bind["base64Image"] = base64Image
+			bind["secretKey"] = secretKey
 
 			// save image and secret in the session state
 			sess.Values[common.SessionKeyOTPSecret] = secretKey
@@ -78,11 +164,6 @@ func (s *Server) handleAuthOtpGet(otpSecretGenerator otpSecretGenerator) http.Ha
 				return
 			}
 
-			bind := map[string]interface{}{
-				"error":     nil,
-				"csrfField": csrf.TemplateField(r),
-			}
-
 			err = s.renderTemplate(w, r, "/layouts/auth_layout.html", "/auth_otp.html", bind)
 			if err != nil {
 				s.internalServerError(w, r, err)
@@ -92,7 +173,7 @@ func (s *Server) handleAuthOtpGet(otpSecretGenerator otpSecretGenerator) http.Ha
 	}
 }
 
-func (s *Server) handleAuthOtpPost() http.HandlerFunc {
+func (s *Server) handleAuthOtpPost(emailSender emailSender) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 
@@ -107,10 +188,7 @@ func (s *Server) handleAuthOtpPost() http.HandlerFunc {
 			return
 		}
 
-		base64Image, secretKey := "", ""
-		if val, ok := sess.Values[common.SessionKeyOTPImage]; ok {
-			base64Image = val.(string)
-		}
+		secretKey := ""
 		if val, ok := sess.Values[common.SessionKeyOTPSecret]; ok {
 			secretKey = val.(string)
 		}
@@ -121,23 +199,18 @@ func (s *Server) handleAuthOtpPost() http.HandlerFunc {
 			return
 		}
 
-		renderError := func(message string) {
-			bind := map[string]interface{}{
-				"error":     message,
-				"csrfField": csrf.TemplateField(r),
-			}
-
-			template := "/auth_otp.html"
-			if len(base64Image) > 0 && len(secretKey) > 0 {
-				template = "/auth_otp_enrollment.html"
-				bind["base64Image"] = base64Image
-				bind["secretKey"] = secretKey
-			}
+		client, err := s.database.GetClientByClientIdentifier(nil, authContext.ClientId)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+		if client == nil {
+			s.internalServerError(w, r, errors.WithStack(errors.New("client not found")))
+			return
+		}
 
-			err = s.renderTemplate(w, r, "/layouts/auth_layout.html", template, bind)
-			if err != nil {
-				s.internalServerError(w, r, err)
-			}
+		renderError := func(message string) {
+			s.renderAuthOtpError(w, r, authContext, user, message)
 		}
 
 		otpCode := r.FormValue("otp")
@@ -146,15 +219,69 @@ func (s *Server) handleAuthOtpPost() http.HandlerFunc {
 			return
 		}
 
+		settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
+
+		lockoutMessage := getAccountLockoutMessage(user, settings)
+		if len(lockoutMessage) > 0 {
+			lib.LogAudit(r.Context(), constants.AuditAuthFailedAccountLocked, map[string]interface{}{
+				"userId": user.Id,
+			})
+			renderError(lockoutMessage)
+			return
+		}
+
+		otpMethod := getAuthOtpMethod(authContext, user)
+		if otpMethod == enums.OTPMethodSMS && !isSMSOTPAvailable(r, authContext, user, client) {
+			s.internalServerError(w, r, errors.WithStack(errors.New("SMS is not allowed as a second factor")))
+			return
+		}
+
 		incorrectOtpError := "Incorrect OTP Code. OTP codes are time-sensitive and change every 30 seconds. Make sure you're using the most recent code generated by your authenticator app."
 
-		if user.OTPEnabled {
-			// already has OTP enrolled
-			otpValid := totp.Validate(otpCode, user.OTPSecret)
-			if !otpValid {
-				lib.LogAudit(constants.AuditAuthFailedOtp, map[string]interface{}{
-					"userId": user.Id,
+		usedRecoveryCode := false
+		if user.OTPEnabled || otpMethod == enums.OTPMethodSMS {
+			// already has OTP enrolled, or has received a code by SMS
+			otpValid := false
+			if otpMethod == enums.OTPMethodTOTP {
+				otpValid = totp.Validate(otpCode, user.OTPSecret)
+			} else {
+				incorrectOtpError = "Incorrect or expired code. Please check the code we sent you, or request a new one."
+				otpValid, err = s.verifyOTPCode(user, settings, otpCode)
+				if err != nil {
+					s.internalServerError(w, r, err)
+					return
+				}
+			}
+			remainingRecoveryCodes := 0
+			if !otpValid && user.OTPEnabled {
+				// a recovery code can be used in place of the OTP code
+				usedRecoveryCode, remainingRecoveryCodes, err = s.useRecoveryCode(user, otpCode)
+				if err != nil {
+					s.internalServerError(w, r, err)
+					return
+				}
+			}
+			if usedRecoveryCode {
+				lib.LogAudit(r.Context(), constants.AuditAuthSuccessRecoveryCode, map[string]interface{}{
+					"userId":                 user.Id,
+					"remainingRecoveryCodes": remainingRecoveryCodes,
+				})
+			} else if !otpValid {
+				lib.LogAudit(r.Context(), constants.AuditAuthFailedOtp, map[string]interface{}{
+					"userId":    user.Id,
+					"otpMethod": otpMethod.String(),
 				})
+				// count failures against an enrolled OTP or a code sent by SMS, even to a user that
+				// is not enrolled. A typo while enrolling to TOTP (below) is not counted
+				user, err = s.registerFailedAuthAttempt(r, user, settings, emailSender)
+				if err != nil {
+					s.internalServerError(w, r, err)
+					return
+				}
+				if user.IsLocked(time.Now().UTC()) {
+					renderError(getAccountLockoutMessage(user, settings))
+					return
+				}
 				renderError(incorrectOtpError)
 				return
 			}
@@ -162,8 +289,9 @@ func (s *Server) handleAuthOtpPost() http.HandlerFunc {
 			// is enrolling to TOTP now
 			otpValid := totp.Validate(otpCode, secretKey)
 			if !otpValid {
-				lib.LogAudit(constants.AuditAuthFailedOtp, map[string]interface{}{
-					"userId": user.Id,
+				lib.LogAudit(r.Context(), constants.AuditAuthFailedOtp, map[string]interface{}{
+					"userId":    user.Id,
+					"otpMethod": otpMethod.String(),
 				})
 				renderError(incorrectOtpError)
 				return
@@ -172,6 +300,7 @@ func (s *Server) handleAuthOtpPost() http.HandlerFunc {
 			// save TOTP secret
 			user.OTPSecret = secretKey
 			user.OTPEnabled = true
+			user.OTPMethod = enums.OTPMethodTOTP.String()
 			err = s.database.UpdateUser(nil, user)
 			if err != nil {
 				s.internalServerError(w, r, err)
@@ -179,34 +308,28 @@ func (s *Server) handleAuthOtpPost() http.HandlerFunc {
 			}
 		}
 
-		lib.LogAudit(constants.AuditAuthSuccessOtp, map[string]interface{}{
-			"userId": user.Id,
-		})
+		if !usedRecoveryCode {
+			lib.LogAudit(r.Context(), constants.AuditAuthSuccessOtp, map[string]interface{}{
+				"userId":    user.Id,
+				"otpMethod": otpMethod.String(),
+			})
+		}
 
 		if !user.Enabled {
-			lib.LogAudit(constants.AuditUserDisabled, map[string]interface{}{
+			lib.LogAudit(r.Context(), constants.AuditUserDisabled, map[string]interface{}{
 				"userId": user.Id,
 			})
 			renderError("Your account is disabled.")
 			return
 		}
 
-		client, err := s.database.GetClientByClientIdentifier(nil, authContext.ClientId)
+		err = s.resetFailedAuthAttempts(user)
 		if err != nil {
 			s.internalServerError(w, r, err)
 			return
 		}
-		if client == nil {
-			s.internalServerError(w, r, errors.WithStack(errors.New("client not found")))
-			return
-		}
-
-		requestedAcrValues := authContext.ParseRequestedAcrValues()
-		targetAcrLevel := client.DefaultAcrLevel
 
-		if len(requestedAcrValues) > 0 {
-			targetAcrLevel = requestedAcrValues[0]
-		}
+		targetAcrLevel := getTargetAcrLevel(authContext, client)
 
 		// start new session
 		_, err = s.startNewUserSession(w, r, user.Id, client.Id,
@@ -221,6 +344,7 @@ func (s *Server) handleAuthOtpPost() http.HandlerFunc {
 		authContext.AuthMethods = enums.AuthMethodPassword.String() + " " + enums.AuthMethodOTP.String()
 		authContext.AuthTime = time.Now().UTC()
 		authContext.AuthCompleted = true
+		authContext.OTPMethod = ""
 		err = s.saveAuthContext(w, r, authContext)
 		if err != nil {
 			s.internalServerError(w, r, err)
@@ -230,3 +354,116 @@ func (s *Server) handleAuthOtpPost() http.HandlerFunc {
 		http.Redirect(w, r, lib.GetBaseUrl()+"/auth/consent", http.StatusFound)
 	}
 }
+
+// deliverAuthOtpCode sends a new code by the given method, respecting the lockout and the
+// minimum interval between codes. It returns an error message for the user, if any
+func (s *Server) deliverAuthOtpCode(r *http.Request, user *entities.User, otpMethod enums.OTPMethod,
+	emailSender emailSender, smsSender smsSender) string {
+
+	settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
+
+	lockoutMessage := getAccountLockoutMessage(user, settings)
+	if len(lockoutMessage) > 0 {
+		return lockoutMessage
+	}
+
+	waitInSeconds := getOTPCodeWaitInSeconds(user, time.Now().UTC())
+	if waitInSeconds > 0 {
+		return fmt.Sprintf("A code was sent recently. Please wait %v seconds before requesting a new one.", waitInSeconds)
+	}
+
+	err := s.sendOTPCode(r, user, settings, otpMethod, emailSender, smsSender)
+	if err != nil {
+		slog.Error(fmt.Sprintf("unable to send the OTP code: %+v", err))
+		return "We were unable to send you a code. Please try again later."
+	}
+	return ""
+}
+
+func (s *Server) handleAuthOtpSendPost(emailSender emailSender, smsSender smsSender) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		authContext, err := s.getAuthContext(r)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		user, err := s.database.GetUserById(nil, authContext.UserId)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+		if user == nil {
+			s.internalServerError(w, r, errors.WithStack(errors.New("user not found")))
+			return
+		}
+
+		otpMethod := getAuthOtpMethod(authContext, user)
+		if otpMethod == enums.OTPMethodTOTP || (!user.OTPEnabled && otpMethod != enums.OTPMethodSMS) {
+			s.internalServerError(w, r, errors.WithStack(errors.New("the OTP method of the user does not deliver codes")))
+			return
+		}
+
+		errorMessage := s.deliverAuthOtpCode(r, user, otpMethod, emailSender, smsSender)
+		if len(errorMessage) > 0 {
+			s.renderAuthOtpError(w, r, authContext, user, errorMessage)
+			return
+		}
+
+		http.Redirect(w, r, lib.GetBaseUrl()+"/auth/otp", http.StatusFound)
+	}
+}
+
+func (s *Server) handleAuthOtpSmsPost(emailSender emailSender, smsSender smsSender) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		authContext, err := s.getAuthContext(r)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		user, err := s.database.GetUserById(nil, authContext.UserId)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+		if user == nil {
+			s.internalServerError(w, r, errors.WithStack(errors.New("user not found")))
+			return
+		}
+
+		client, err := s.database.GetClientByClientIdentifier(nil, authContext.ClientId)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+		if client == nil {
+			s.internalServerError(w, r, errors.WithStack(errors.New("client not found")))
+			return
+		}
+
+		if !isSMSOTPAvailable(r, authContext, user, client) {
+			s.renderAuthOtpError(w, r, authContext, user, "Receiving the code by SMS is not available.")
+			return
+		}
+
+		errorMessage := s.deliverAuthOtpCode(r, user, enums.OTPMethodSMS, emailSender, smsSender)
+		if len(errorMessage) > 0 {
+			s.renderAuthOtpError(w, r, authContext, user, errorMessage)
+			return
+		}
+
+		authContext.OTPMethod = enums.OTPMethodSMS.String()
+		err = s.saveAuthContext(w, r, authContext)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		http.Redirect(w, r, lib.GetBaseUrl()+"/auth/otp", http.StatusFound)
+	}
+}
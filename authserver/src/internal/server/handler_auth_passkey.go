@@ -0,0 +1,352 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/gorilla/csrf"
+	"github.com/leodip/goiabada/internal/common"
+	"github.com/leodip/goiabada/internal/constants"
+	"github.com/leodip/goiabada/internal/customerrors"
+	"github.com/leodip/goiabada/internal/dtos"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/leodip/goiabada/internal/lib"
+)
+
+const passkeyAuthFailedMessage = "Authentication with the passkey failed."
+
+func (s *Server) handleAuthPasskeyGet() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		authContext, err := s.getAuthContext(r)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		user, err := s.database.GetUserById(nil, authContext.UserId)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+		if user == nil {
+			s.internalServerError(w, r, errors.WithStack(errors.New("user not found")))
+			return
+		}
+
+		webAuthnCredentials, err := s.database.GetWebAuthnCredentialsByUserId(nil, user.Id)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		bind := map[string]interface{}{
+			"error":       nil,
+			"hasPasskeys": len(webAuthnCredentials) > 0,
+			"csrfField":   csrf.TemplateField(r),
+		}
+
+		err = s.renderTemplate(w, r, "/layouts/auth_layout.html", "/auth_passkey.html", bind)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+	}
+}
+
+// handleAuthPasskeyBeginPost starts a passkey authentication for a user that was already
+// identified (password verified, or existing session being stepped up)
+func (s *Server) handleAuthPasskeyBeginPost() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		authContext, err := s.getAuthContext(r)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+		if authContext.UserId == 0 {
+			s.jsonError(w, r, customerrors.NewValidationError("", "The user has not been identified yet. Please start the authentication again."))
+			return
+		}
+
+		webAuthnCredentials, err := s.database.GetWebAuthnCredentialsByUserId(nil, authContext.UserId)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+		if len(webAuthnCredentials) == 0 {
+			s.jsonError(w, r, customerrors.NewValidationError("", "You don't have any passkeys registered."))
+			return
+		}
+
+		s.writeWebAuthnRequestOptions(w, r, getWebAuthnCredentialDescriptors(webAuthnCredentials), "preferred")
+	}
+}
+
+func (s *Server) handleAuthPasskeyFinishPost(emailSender emailSender) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		authContext, err := s.getAuthContext(r)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+		if authContext.UserId == 0 {
+			s.jsonError(w, r, customerrors.NewValidationError("", "The user has not been identified yet. Please start the authentication again."))
+			return
+		}
+
+		var response lib.WebAuthnAssertionResponse
+		err = json.NewDecoder(r.Body).Decode(&response)
+		if err != nil {
+			s.jsonError(w, r, customerrors.NewValidationError("", "Unable to parse the passkey response."))
+			return
+		}
+
+		webAuthnCredential, err := s.database.GetWebAuthnCredentialByCredentialId(nil, response.Id)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+		if webAuthnCredential == nil || webAuthnCredential.UserId != authContext.UserId {
+			lib.LogAudit(r.Context(), constants.AuditAuthFailedPasskey, map[string]interface{}{
+				"userId": authContext.UserId,
+				"reason": "unknown credential",
+			})
+			s.jsonError(w, r, customerrors.NewValidationError("", passkeyAuthFailedMessage))
+			return
+		}
+
+		s.finishPasskeyAuth(w, r, authContext, webAuthnCredential, &response, false, emailSender,
+			enums.AuthMethodPassword.String()+" "+enums.AuthMethodPasskey.String())
+	}
+}
+
+// handleAuthPasskeyLoginBeginPost starts a passwordless authentication. The user is not known
+// yet; the authenticator offers its discoverable credentials
+func (s *Server) handleAuthPasskeyLoginBeginPost() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		_, err := s.getAuthContext(r)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+
+		s.writeWebAuthnRequestOptions(w, r, []webAuthnCredentialDescriptor{}, "required")
+	}
+}
+
+func (s *Server) handleAuthPasskeyLoginFinishPost(emailSender emailSender) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		authContext, err := s.getAuthContext(r)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+
+		var response lib.WebAuthnAssertionResponse
+		err = json.NewDecoder(r.Body).Decode(&response)
+		if err != nil {
+			s.jsonError(w, r, customerrors.NewValidationError("", "Unable to parse the passkey response."))
+			return
+		}
+
+		webAuthnCredential, err := s.database.GetWebAuthnCredentialByCredentialId(nil, response.Id)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+		if webAuthnCredential == nil {
+			lib.LogAudit(r.Context(), constants.AuditAuthFailedPasskey, map[string]interface{}{
+				"reason": "unknown credential",
+			})
+			s.jsonError(w, r, customerrors.NewValidationError("", passkeyAuthFailedMessage))
+			return
+		}
+
+		s.finishPasskeyAuth(w, r, authContext, webAuthnCredential, &response, true, emailSender,
+			enums.AuthMethodPasskey.String())
+	}
+}
+
+func (s *Server) writeWebAuthnRequestOptions(w http.ResponseWriter, r *http.Request,
+	allowCredentials []webAuthnCredentialDescriptor, userVerification string) {
+
+	rp, err := s.getWebAuthnRelyingParty(r)
+	if err != nil {
+		s.jsonError(w, r, err)
+		return
+	}
+
+	challenge, err := s.newWebAuthnChallenge(w, r)
+	if err != nil {
+		s.jsonError(w, r, err)
+		return
+	}
+
+	result := struct {
+		Challenge        string                         `json:"challenge"`
+		RpId             string                         `json:"rpId"`
+		Timeout          int                            `json:"timeout"`
+		UserVerification string                         `json:"userVerification"`
+		AllowCredentials []webAuthnCredentialDescriptor `json:"allowCredentials"`
+	}{
+		Challenge:        challenge,
+		RpId:             rp.Id,
+		Timeout:          webAuthnTimeoutInMilliseconds,
+		UserVerification: userVerification,
+		AllowCredentials: allowCredentials,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// finishPasskeyAuth verifies the assertion and, when valid, completes the authentication
+// with the passkey acr level
+func (s *Server) finishPasskeyAuth(w http.ResponseWriter, r *http.Request, authContext *dtos.AuthContext,
+	webAuthnCredential *entities.WebAuthnCredential, response *lib.WebAuthnAssertionResponse,
+	passwordless bool, emailSender emailSender, authMethods string) {
+
+	challenge, err := s.takeWebAuthnChallenge(w, r)
+	if err != nil {
+		s.jsonError(w, r, err)
+		return
+	}
+
+	user, err := s.database.GetUserById(nil, webAuthnCredential.UserId)
+	if err != nil {
+		s.jsonError(w, r, err)
+		return
+	}
+	if user == nil {
+		s.jsonError(w, r, errors.WithStack(errors.New("user not found")))
+		return
+	}
+
+	settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
+
+	// like the password form, the response doesn't reveal that the account is locked
+	if len(getAccountLockoutMessage(user, settings)) > 0 {
+		lib.LogAudit(r.Context(), constants.AuditAuthFailedAccountLocked, map[string]interface{}{
+			"userId": user.Id,
+		})
+		s.jsonError(w, r, customerrors.NewValidationError("", passkeyAuthFailedMessage))
+		return
+	}
+
+	rp, err := s.getWebAuthnRelyingParty(r)
+	if err != nil {
+		s.jsonError(w, r, err)
+		return
+	}
+
+	// in a passwordless login the authenticator chose the credential, so it must also return
+	// the user handle, and that handle must belong to the owner of the credential
+	userHandleMatches := true
+	if passwordless {
+		userHandleMatches = strings.TrimRight(response.UserHandle, "=") == getWebAuthnUserHandle(user)
+	}
+
+	var assertionResult *lib.WebAuthnAssertionResult
+	if userHandleMatches {
+		assertionResult, err = lib.VerifyWebAuthnAssertion(rp, challenge, response, webAuthnCredential.PublicKey,
+			webAuthnCredential.SignCount, passwordless)
+	} else {
+		err = errors.WithStack(errors.New("user handle does not match the credential"))
+	}
+	if err != nil {
+		slog.Warn(fmt.Sprintf("passkey assertion rejected for user %v: %v", user.Id, err))
+		lib.LogAudit(r.Context(), constants.AuditAuthFailedPasskey, map[string]interface{}{
+			"userId":               user.Id,
+			"webAuthnCredentialId": webAuthnCredential.Id,
+		})
+		_, err = s.registerFailedAuthAttempt(r, user, settings, emailSender)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+		s.jsonError(w, r, customerrors.NewValidationError("", passkeyAuthFailedMessage))
+		return
+	}
+
+	if !user.Enabled {
+		lib.LogAudit(r.Context(), constants.AuditUserDisabled, map[string]interface{}{
+			"userId": user.Id,
+		})
+		s.jsonError(w, r, customerrors.NewValidationError("", "Your account is disabled."))
+		return
+	}
+
+	webAuthnCredential.SignCount = assertionResult.SignCount
+	webAuthnCredential.LastUsedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	err = s.database.UpdateWebAuthnCredential(nil, webAuthnCredential)
+	if err != nil {
+		s.jsonError(w, r, err)
+		return
+	}
+
+	lib.LogAudit(r.Context(), constants.AuditAuthSuccessPasskey, map[string]interface{}{
+		"userId":               user.Id,
+		"webAuthnCredentialId": webAuthnCredential.Id,
+		"passwordless":         passwordless,
+	})
+
+	err = s.resetFailedAuthAttempts(user)
+	if err != nil {
+		s.jsonError(w, r, err)
+		return
+	}
+
+	client, err := s.database.GetClientByClientIdentifier(nil, authContext.ClientId)
+	if err != nil {
+		s.jsonError(w, r, err)
+		return
+	}
+	if client == nil {
+		s.jsonError(w, r, errors.WithStack(errors.New("client not found")))
+		return
+	}
+
+	// a passkey satisfies any acr level, so the session gets the highest one
+	_, err = s.startNewUserSession(w, r, user.Id, client.Id, authMethods, enums.AcrLevelPasskey.String())
+	if err != nil {
+		s.jsonError(w, r, err)
+		return
+	}
+
+	authContext.UserId = user.Id
+	authContext.AcrLevel = enums.AcrLevelPasskey.String()
+	authContext.AuthMethods = authMethods
+	authContext.AuthTime = time.Now().UTC()
+	authContext.AuthCompleted = true
+	err = s.saveAuthContext(w, r, authContext)
+	if err != nil {
+		s.jsonError(w, r, err)
+		return
+	}
+
+	result := struct {
+		Success     bool
+		RedirectUri string
+	}{
+		Success:     true,
+		RedirectUri: lib.GetBaseUrl() + "/auth/consent",
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
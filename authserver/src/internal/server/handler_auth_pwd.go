@@ -22,7 +22,7 @@ func (s *Server) handleAuthPwdGet() http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		_, err := s.getAuthContext(r)
+		authContext, err := s.getAuthContext(r)
 		if err != nil {
 			if errors.Is(err, customerrors.ErrNoAuthContext) {
 				slog.Warn("no auth context, redirecting to " + lib.GetBaseUrl() + "/account/profile")
@@ -38,9 +38,10 @@ func (s *Server) handleAuthPwdGet() http.HandlerFunc {
 			sessionIdentifier = r.Context().Value(common.ContextKeySessionIdentifier).(string)
 		}
 
-		// try to get email from session
-		email := ""
-		if len(sessionIdentifier) > 0 {
+		// the client can tell which user is about to log in (login_hint),
+		// otherwise try to get email from session
+		email := authContext.LoginHint
+		if len(email) == 0 && len(sessionIdentifier) > 0 {
 			userSession, err := s.database.GetUserSessionBySessionIdentifier(nil, sessionIdentifier)
 			if err != nil {
 				s.internalServerError(w, r, err)
@@ -70,7 +71,8 @@ func (s *Server) handleAuthPwdGet() http.HandlerFunc {
 	}
 }
 
-func (s *Server) handleAuthPwdPost(authorizeValidator authorizeValidator, loginManager loginManager) http.HandlerFunc {
+func (s *Server) handleAuthPwdPost(authorizeValidator authorizeValidator, loginManager loginManager,
+	emailSender emailSender) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 
@@ -118,29 +120,44 @@ func (s *Server) handleAuthPwdPost(authorizeValidator authorizeValidator, loginM
 
 		authFailedMessage := "Authentication failed."
 		if user == nil {
-			lib.LogAudit(constants.AuditAuthFailedPwd, map[string]interface{}{
+			lib.LogAudit(r.Context(), constants.AuditAuthFailedPwd, map[string]interface{}{
 				"email": email,
 			})
 			renderError(authFailedMessage)
 			return
 		}
 
+		// a locked account gets the same message as a wrong password, so the response
+		// doesn't reveal which accounts exist or are locked
+		if len(getAccountLockoutMessage(user, settings)) > 0 {
+			lib.LogAudit(r.Context(), constants.AuditAuthFailedAccountLocked, map[string]interface{}{
+				"userId": user.Id,
+			})
+			renderError(authFailedMessage)
+			return
+		}
+
 		if !lib.VerifyPasswordHash(user.PasswordHash, password) {
-			lib.LogAudit(constants.AuditAuthFailedPwd, map[string]interface{}{
+			lib.LogAudit(r.Context(), constants.AuditAuthFailedPwd, map[string]interface{}{
 				"email": email,
 			})
+			_, err = s.registerFailedAuthAttempt(r, user, settings, emailSender)
+			if err != nil {
+				s.internalServerError(w, r, err)
+				return
+			}
 			renderError(authFailedMessage)
 			return
 		}
 
 		// from this point the user is considered authenticated with pwd
 
-		lib.LogAudit(constants.AuditAuthSuccessPwd, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditAuthSuccessPwd, map[string]interface{}{
 			"userId": user.Id,
 		})
 
 		if !user.Enabled {
-			lib.LogAudit(constants.AuditUserDisabled, map[string]interface{}{
+			lib.LogAudit(r.Context(), constants.AuditUserDisabled, map[string]interface{}{
 				"userId": user.Id,
 			})
 			renderError("Your account is disabled.")
@@ -198,6 +215,19 @@ func (s *Server) handleAuthPwdPost(authorizeValidator authorizeValidator, loginM
 
 		}
 
+		// the passkey level always requires a passkey, on top of the password
+
+		if targetAcrLevel == enums.AcrLevelPasskey {
+			authContext.UserId = user.Id
+			err = s.saveAuthContext(w, r, authContext)
+			if err != nil {
+				s.internalServerError(w, r, err)
+				return
+			}
+			http.Redirect(w, r, lib.GetBaseUrl()+"/auth/passkey", http.StatusFound)
+			return
+		}
+
 		// if the client accepts AcrLevel1 that means only the password is sufficient to authenticate
 		// no need to check anything else
 
@@ -223,6 +253,12 @@ func (s *Server) handleAuthPwdPost(authorizeValidator authorizeValidator, loginM
 
 		// user is fully authenticated
 
+		err = s.resetFailedAuthAttempts(user)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
 		// start new session
 
 		_, err = s.startNewUserSession(w, r, user.Id, client.Id, enums.AuthMethodPassword.String(), targetAcrLevel.String())
@@ -1,10 +1,11 @@
 package server
 
 import (
-	"fmt"
+	"context"
 	"html/template"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -15,6 +16,8 @@ import (
 	core_validators "github.com/leodip/goiabada/internal/core/validators"
 	"github.com/leodip/goiabada/internal/customerrors"
 	"github.com/leodip/goiabada/internal/dtos"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
 	"github.com/leodip/goiabada/internal/lib"
 )
 
@@ -25,28 +28,6 @@ func (s *Server) handleAuthorizeGet(authorizeValidator authorizeValidator,
 
 		requestId := middleware.GetReqID(r.Context())
 
-		authContext := dtos.AuthContext{
-			ClientId:            r.URL.Query().Get("client_id"),
-			RedirectURI:         r.URL.Query().Get("redirect_uri"),
-			ResponseType:        r.URL.Query().Get("response_type"),
-			CodeChallengeMethod: r.URL.Query().Get("code_challenge_method"),
-			CodeChallenge:       r.URL.Query().Get("code_challenge"),
-			ResponseMode:        r.URL.Query().Get("response_mode"),
-			MaxAge:              r.URL.Query().Get("max_age"),
-			RequestedAcrValues:  r.URL.Query().Get("acr_values"),
-			State:               r.URL.Query().Get("state"),
-			Nonce:               r.URL.Query().Get("nonce"),
-			UserAgent:           r.UserAgent(),
-			IpAddress:           r.RemoteAddr,
-		}
-		authContext.SetScope(r.URL.Query().Get("scope"))
-
-		err := s.saveAuthContext(w, r, &authContext)
-		if err != nil {
-			s.internalServerError(w, r, err)
-			return
-		}
-
 		renderErrorUi := func(message string) {
 			bind := map[string]interface{}{
 				"title": "Unable to authorize",
@@ -59,10 +40,62 @@ func (s *Server) handleAuthorizeGet(authorizeValidator authorizeValidator,
 			}
 		}
 
+		renderParametersError := func(err error) {
+			if valError, ok := err.(*customerrors.ValidationError); ok {
+				renderErrorUi(valError.Description)
+			} else {
+				s.internalServerError(w, r, err)
+			}
+		}
+
+		// with a request_uri, the parameters are the ones the client pushed to the PAR endpoint
+		params := r.URL.Query()
+		isPushedAuthorizationRequest := len(params.Get("request_uri")) > 0
+		if isPushedAuthorizationRequest {
+			var err error
+			params, err = s.redeemPushedAuthorizationRequest(params.Get("request_uri"), params.Get("client_id"))
+			if err != nil {
+				renderParametersError(err)
+				return
+			}
+		}
+
+		params, isSignedRequestObject, err := s.applyRequestObject(r.Context(), authorizeValidator, params, isPushedAuthorizationRequest)
+		if err != nil {
+			renderParametersError(err)
+			return
+		}
+
+		authContext := dtos.AuthContext{
+			ClientId:            params.Get("client_id"),
+			RedirectURI:         params.Get("redirect_uri"),
+			ResponseType:        params.Get("response_type"),
+			CodeChallengeMethod: params.Get("code_challenge_method"),
+			CodeChallenge:       params.Get("code_challenge"),
+			ResponseMode:        params.Get("response_mode"),
+			MaxAge:              params.Get("max_age"),
+			RequestedAcrValues:  params.Get("acr_values"),
+			State:               params.Get("state"),
+			Nonce:               params.Get("nonce"),
+			Prompt:              params.Get("prompt"),
+			LoginHint:           params.Get("login_hint"),
+			UserAgent:           r.UserAgent(),
+			IpAddress:           r.RemoteAddr,
+		}
+		authContext.SetScope(params.Get("scope"))
+
+		err = s.saveAuthContext(w, r, &authContext)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
 		err = authorizeValidator.ValidateClientAndRedirectURI(r.Context(), &core_validators.ValidateClientAndRedirectURIInput{
-			RequestId:   requestId,
-			ClientId:    authContext.ClientId,
-			RedirectURI: authContext.RedirectURI,
+			RequestId:                    requestId,
+			ClientId:                     authContext.ClientId,
+			RedirectURI:                  authContext.RedirectURI,
+			IsPushedAuthorizationRequest: isPushedAuthorizationRequest,
+			IsSignedRequestObject:        isSignedRequestObject,
 		})
 
 		if err != nil {
@@ -78,7 +111,7 @@ func (s *Server) handleAuthorizeGet(authorizeValidator authorizeValidator,
 
 		redirToClientWithError := func(validationError *customerrors.ValidationError) {
 			err := s.redirToClientWithError(w, r, validationError.Code, validationError.Description,
-				r.URL.Query().Get("response_mode"), r.URL.Query().Get("redirect_uri"), r.URL.Query().Get("state"))
+				authContext.ResponseMode, authContext.RedirectURI, authContext.State)
 			if err != nil {
 				s.internalServerError(w, r, err)
 			}
@@ -89,6 +122,7 @@ func (s *Server) handleAuthorizeGet(authorizeValidator authorizeValidator,
 			CodeChallengeMethod: authContext.CodeChallengeMethod,
 			CodeChallenge:       authContext.CodeChallenge,
 			ResponseMode:        authContext.ResponseMode,
+			Prompt:              authContext.Prompt,
 		})
 
 		if err != nil {
@@ -115,118 +149,236 @@ func (s *Server) handleAuthorizeGet(authorizeValidator authorizeValidator,
 			}
 		}
 
-		sessionIdentifier := ""
-		if r.Context().Value(common.ContextKeySessionIdentifier) != nil {
-			sessionIdentifier = r.Context().Value(common.ContextKeySessionIdentifier).(string)
+		idTokenHint := params.Get("id_token_hint")
+		if len(idTokenHint) > 0 {
+			hintedUser, err := s.getIdTokenHintUser(r.Context(), idTokenHint, authContext.ClientId)
+			if err != nil {
+				valError, ok := err.(*customerrors.ValidationError)
+				if ok {
+					redirToClientWithError(valError)
+					return
+				} else {
+					s.internalServerError(w, r, err)
+					return
+				}
+			}
+			authContext.IdTokenHintSubject = hintedUser.Subject.String()
+			if len(authContext.LoginHint) == 0 {
+				authContext.LoginHint = hintedUser.Email
+			}
 		}
 
-		userSession, err := s.database.GetUserSessionBySessionIdentifier(nil, sessionIdentifier)
-		if err != nil {
-			s.internalServerError(w, r, err)
-			return
-		}
+		s.continueAuthentication(w, r, loginManager, &authContext, redirToClientWithError)
+	}
+}
 
-		err = s.database.UserSessionLoadUser(nil, userSession)
-		if err != nil {
-			s.internalServerError(w, r, err)
-			return
-		}
+// getIdTokenHintUser returns the user of the id_token_hint, which must be an ID token issued
+// by this server to the client. The token may have expired
+func (s *Server) getIdTokenHintUser(ctx context.Context, idTokenHint string, clientId string) (*entities.User, error) {
 
-		client, err := s.database.GetClientByClientIdentifier(nil, authContext.ClientId)
-		if err != nil {
-			s.internalServerError(w, r, err)
-			return
-		}
-		if client == nil {
-			s.internalServerError(w, r, errors.WithStack(errors.New("client not found")))
-			return
-		}
+	invalidHint := customerrors.NewValidationError("invalid_request", "The id_token_hint parameter is invalid.")
 
-		requestedAcrValues := authContext.ParseRequestedAcrValues()
-		targetAcrLevel := client.DefaultAcrLevel
+	idToken, err := s.tokenParser.ParseToken(ctx, idTokenHint, false)
+	if err != nil {
+		return nil, invalidHint
+	}
 
-		hasValidUserSession := loginManager.HasValidUserSession(r.Context(), userSession, authContext.ParseRequestedMaxAge())
-		if hasValidUserSession {
-			// valid user session
+	settings := ctx.Value(common.ContextKeySettings).(*entities.Settings)
+	if idToken.GetStringClaim("iss") != settings.Issuer ||
+		idToken.GetStringClaim("typ") != enums.TokenTypeId.String() ||
+		!slices.Contains(idToken.GetAudience(), clientId) {
+		return nil, invalidHint
+	}
 
-			if !userSession.User.Enabled {
+	user, err := s.database.GetUserBySubject(nil, idToken.GetStringClaim("sub"))
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, invalidHint
+	}
+	return user, nil
+}
 
-				lib.LogAudit(constants.AuditUserDisabled, map[string]interface{}{
-					"userId": userSession.UserId,
-				})
+// continueAuthentication takes the user to the next step of the authentication. When the user
+// session is still valid and no further authentication is needed, that's the consent
+func (s *Server) continueAuthentication(w http.ResponseWriter, r *http.Request, loginManager loginManager,
+	authContext *dtos.AuthContext, redirToClientWithError func(validationError *customerrors.ValidationError)) {
 
-				redirToClientWithError(&customerrors.ValidationError{
-					Code:        "access_denied",
-					Description: "The user account is disabled.",
-				})
-				return
-			}
+	sessionIdentifier := ""
+	if r.Context().Value(common.ContextKeySessionIdentifier) != nil {
+		sessionIdentifier = r.Context().Value(common.ContextKeySessionIdentifier).(string)
+	}
 
-			if len(requestedAcrValues) > 0 {
-				targetAcrLevel = requestedAcrValues[0]
-			}
+	userSession, err := s.database.GetUserSessionBySessionIdentifier(nil, sessionIdentifier)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
 
-			mustPerformOTPAuth := loginManager.MustPerformOTPAuth(r.Context(), client, userSession, targetAcrLevel)
-			if mustPerformOTPAuth {
-				authContext.UserId = userSession.User.Id
-				err = s.saveAuthContext(w, r, &authContext)
-				if err != nil {
-					s.internalServerError(w, r, err)
-					return
-				}
-				http.Redirect(w, r, lib.GetBaseUrl()+"/auth/otp", http.StatusFound)
+	err = s.database.UserSessionLoadUser(nil, userSession)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	client, err := s.database.GetClientByClientIdentifier(nil, authContext.ClientId)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+	if client == nil {
+		s.internalServerError(w, r, errors.WithStack(errors.New("client not found")))
+		return
+	}
+
+	requestedAcrValues := authContext.ParseRequestedAcrValues()
+	targetAcrLevel := client.DefaultAcrLevel
+
+	hasValidUserSession := loginManager.HasValidUserSession(r.Context(), userSession, authContext.ParseRequestedMaxAge())
+
+	// the user must authenticate again when asked to, or when the session belongs to
+	// someone else than the user of the id_token_hint
+	if hasValidUserSession && (authContext.HasPrompt("login") || authContext.HasPrompt("select_account")) {
+		hasValidUserSession = false
+	}
+	if hasValidUserSession && len(authContext.IdTokenHintSubject) > 0 &&
+		userSession.User.Subject.String() != authContext.IdTokenHintSubject {
+		hasValidUserSession = false
+	}
+
+	if !hasValidUserSession && authContext.HasPrompt("none") {
+		redirToClientWithError(&customerrors.ValidationError{
+			Code:        "login_required",
+			Description: "The user needs to authenticate, but the prompt parameter is 'none'.",
+		})
+		return
+	}
+
+	if hasValidUserSession {
+		// valid user session
+
+		if !userSession.User.Enabled {
+
+			lib.LogAudit(r.Context(), constants.AuditUserDisabled, map[string]interface{}{
+				"userId": userSession.UserId,
+			})
+
+			redirToClientWithError(&customerrors.ValidationError{
+				Code:        "access_denied",
+				Description: "The user account is disabled.",
+			})
+			return
+		}
+
+		if len(requestedAcrValues) > 0 {
+			targetAcrLevel = requestedAcrValues[0]
+		}
+
+		mustPerformPasskeyAuth := loginManager.MustPerformPasskeyAuth(r.Context(), client, userSession, targetAcrLevel)
+		if mustPerformPasskeyAuth {
+			if authContext.HasPrompt("none") {
+				redirToClientWithError(&customerrors.ValidationError{
+					Code:        "interaction_required",
+					Description: "The user needs to authenticate again, but the prompt parameter is 'none'.",
+				})
 				return
 			}
-
-		} else {
-			// no valid session
-			fmt.Println("request", r.URL)
-			err = s.saveAuthContext(w, r, &authContext)
+			authContext.UserId = userSession.User.Id
+			err = s.saveAuthContext(w, r, authContext)
 			if err != nil {
 				s.internalServerError(w, r, err)
 				return
 			}
+			http.Redirect(w, r, lib.GetBaseUrl()+"/auth/passkey", http.StatusFound)
+			return
+		}
 
-			authContext, err := s.getAuthContext(r)
+		mustPerformOTPAuth := loginManager.MustPerformOTPAuth(r.Context(), client, userSession, targetAcrLevel)
+		if mustPerformOTPAuth {
+			if authContext.HasPrompt("none") {
+				redirToClientWithError(&customerrors.ValidationError{
+					Code:        "interaction_required",
+					Description: "The user needs to authenticate again, but the prompt parameter is 'none'.",
+				})
+				return
+			}
+			authContext.UserId = userSession.User.Id
+			err = s.saveAuthContext(w, r, authContext)
 			if err != nil {
 				s.internalServerError(w, r, err)
 				return
 			}
-			fmt.Println("authContext", authContext)
-			// http.Redirect(w, r, lib.GetBaseUrl()+"/auth/pwd", http.StatusFound)
-			http.Redirect(w, r, "http://localhost:5173/login", http.StatusFound)
+			http.Redirect(w, r, lib.GetBaseUrl()+"/auth/otp", http.StatusFound)
 			return
 		}
 
-		// no further authentication is needed
-
-		authContext.UserId = userSession.User.Id
-		err = authContext.SetAcrLevel(targetAcrLevel, userSession)
+	} else {
+		// no valid session
+		err = s.saveAuthContext(w, r, authContext)
 		if err != nil {
 			s.internalServerError(w, r, err)
 			return
 		}
-		authContext.AuthMethods = userSession.AuthMethods
-		authContext.AuthTime = userSession.AuthTime
-		authContext.AuthCompleted = true
+		http.Redirect(w, r, lib.GetBaseUrl()+"/auth/pwd", http.StatusFound)
+		return
+	}
 
-		// bump session
-		_, err = s.bumpUserSession(w, r, sessionIdentifier, client.Id)
-		if err != nil {
-			s.internalServerError(w, r, err)
-			return
-		}
+	// no further authentication is needed
 
-		// save auth context
-		err = s.saveAuthContext(w, r, &authContext)
-		if err != nil {
-			s.internalServerError(w, r, err)
-			return
-		}
+	authContext.UserId = userSession.User.Id
+	err = authContext.SetAcrLevel(targetAcrLevel, userSession)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+	authContext.AuthMethods = userSession.AuthMethods
+	authContext.AuthTime = userSession.AuthTime
+	authContext.AuthCompleted = true
+
+	// bump session
+	_, err = s.bumpUserSession(w, r, sessionIdentifier, client.Id)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
 
-		// redirect to consent
-		http.Redirect(w, r, lib.GetBaseUrl()+"/auth/consent", http.StatusFound)
+	// save auth context
+	err = s.saveAuthContext(w, r, authContext)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
 	}
+
+	// redirect to consent
+	http.Redirect(w, r, lib.GetBaseUrl()+"/auth/consent", http.StatusFound)
+}
+
+// applyRequestObject verifies the request object in the parameters, if there's one (RFC 9101).
+// The authorization request parameters are then only taken from the request object. Outside of
+// it only the client_id is used, so that nothing can be added to or changed in a signed request
+func (s *Server) applyRequestObject(ctx context.Context, authorizeValidator authorizeValidator,
+	params url.Values, isPushedAuthorizationRequest bool) (url.Values, bool, error) {
+
+	requestObject := params.Get("request")
+	if len(requestObject) == 0 {
+		return params, false, nil
+	}
+
+	result, err := authorizeValidator.ValidateRequestObject(ctx, &core_validators.ValidateRequestObjectInput{
+		ClientId:      params.Get("client_id"),
+		ResponseType:  params.Get("response_type"),
+		RequestObject: requestObject,
+
+		IsPushedAuthorizationRequest: isPushedAuthorizationRequest,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	requestParams := result.Parameters
+	requestParams.Set("client_id", params.Get("client_id"))
+	return requestParams, result.IsSigned, nil
 }
 
 func (s *Server) redirToClientWithError(w http.ResponseWriter, r *http.Request, code string,
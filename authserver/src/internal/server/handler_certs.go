@@ -10,13 +10,17 @@ import (
 
 func (s *Server) handleCertsGet() http.HandlerFunc {
 
+	// RSA keys have n and e, EC keys have crv, x and y, and OKP (EdDSA) keys have crv and x
 	type jwk struct {
 		Alg string `json:"alg"`
 		Kid string `json:"kid"`
 		Kty string `json:"kty"`
 		Use string `json:"use"`
-		N   string `json:"n"`
-		E   string `json:"e"`
+		N   string `json:"n,omitempty"`
+		E   string `json:"e,omitempty"`
+		Crv string `json:"crv,omitempty"`
+		X   string `json:"x,omitempty"`
+		Y   string `json:"y,omitempty"`
 	}
 
 	type jwks struct {
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/leodip/goiabada/internal/common"
+	"github.com/pkg/errors"
+)
+
+func (s *Server) handleCheckSessionIframeGet() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		t, err := template.ParseFS(s.templateFS, "check_session_iframe.html")
+		if err != nil {
+			s.internalServerError(w, r, errors.Wrap(err, "unable to parse template"))
+			return
+		}
+
+		bind := map[string]interface{}{
+			"cookieName": common.BrowserStateCookieName,
+		}
+		err = t.Execute(w, bind)
+		if err != nil {
+			s.internalServerError(w, r, errors.Wrap(err, "unable to execute template"))
+		}
+	}
+}
@@ -0,0 +1,525 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pkg/errors"
+
+	"github.com/leodip/goiabada/internal/common"
+	"github.com/leodip/goiabada/internal/constants"
+	core_validators "github.com/leodip/goiabada/internal/core/validators"
+	"github.com/leodip/goiabada/internal/customerrors"
+	"github.com/leodip/goiabada/internal/dtos"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/leodip/goiabada/internal/lib"
+)
+
+func (s *Server) handleClientRegistrationPost(clientRegistrationValidator clientRegistrationValidator,
+	inputSanitizer inputSanitizer) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
+
+		if !s.isClientRegistrationEnabled(w, r, settings) {
+			return
+		}
+
+		registrationMode, _ := enums.ClientRegistrationModeFromString(settings.ClientRegistrationMode)
+		if registrationMode == enums.ClientRegistrationModeInitialAccessToken {
+			initialAccessToken := ""
+			if len(settings.ClientRegistrationInitialAccessTokenEncrypted) > 0 {
+				var err error
+				initialAccessToken, err = lib.DecryptText(settings.ClientRegistrationInitialAccessTokenEncrypted, settings.AESEncryptionKey)
+				if err != nil {
+					s.internalServerError(w, r, err)
+					return
+				}
+			}
+			bearerToken := getBearerToken(r)
+			if len(bearerToken) == 0 || len(initialAccessToken) == 0 ||
+				subtle.ConstantTimeCompare([]byte(bearerToken), []byte(initialAccessToken)) != 1 {
+				s.invalidTokenError(w, r, "The initial access token is missing or invalid.")
+				return
+			}
+		}
+
+		var metadata dtos.ClientMetadata
+		err := json.NewDecoder(r.Body).Decode(&metadata)
+		if err != nil {
+			s.jsonError(w, r, customerrors.NewValidationError("invalid_client_metadata", "The request body must be a JSON object with the client metadata."))
+			return
+		}
+
+		err = clientRegistrationValidator.ValidateClientMetadata(r.Context(), &metadata)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+
+		clientIdentifier, err := s.generateClientIdentifier()
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		registrationAccessToken := lib.GenerateSecureRandomString(64)
+		registrationAccessTokenHash, err := lib.HashString(registrationAccessToken)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		client := &entities.Client{
+			ClientIdentifier:            clientIdentifier,
+			Enabled:                     true,
+			ConsentRequired:             true,
+			DefaultAcrLevel:             enums.AcrLevel2,
+			RefreshTokenRotationEnabled: enums.ThreeStateSettingDefault.String(),
+			DPoPMode:                    enums.DPoPModeDisabled.String(),
+			RegistrationAccessTokenHash: registrationAccessTokenHash,
+		}
+		err = s.applyClientMetadata(client, &metadata, settings, inputSanitizer)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		err = s.saveRegisteredClient(client, &metadata, true)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		lib.LogAudit(r.Context(), constants.AuditRegisteredClient, map[string]interface{}{
+			"clientId":         client.Id,
+			"clientIdentifier": client.ClientIdentifier,
+		})
+
+		response, err := s.getClientRegistrationResponse(client, settings)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+		response.RegistrationAccessToken = registrationAccessToken
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Pragma", "no-cache")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+func (s *Server) handleClientConfigurationGet() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
+
+		client := s.getRegisteredClient(w, r, settings)
+		if client == nil {
+			return
+		}
+
+		response, err := s.getClientRegistrationResponse(client, settings)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Pragma", "no-cache")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+func (s *Server) handleClientConfigurationPut(clientRegistrationValidator clientRegistrationValidator,
+	inputSanitizer inputSanitizer) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
+
+		client := s.getRegisteredClient(w, r, settings)
+		if client == nil {
+			return
+		}
+
+		// the request replaces all the metadata of the client (RFC 7592, section 2.2)
+		var data struct {
+			ClientId     string `json:"client_id"`
+			ClientSecret string `json:"client_secret"`
+			dtos.ClientMetadata
+		}
+		err := json.NewDecoder(r.Body).Decode(&data)
+		if err != nil {
+			s.jsonError(w, r, customerrors.NewValidationError("invalid_client_metadata", "The request body must be a JSON object with the client metadata."))
+			return
+		}
+
+		if data.ClientId != client.ClientIdentifier {
+			s.jsonError(w, r, customerrors.NewValidationError("invalid_client_metadata", "The client_id does not match the client being updated."))
+			return
+		}
+
+		if len(data.ClientSecret) > 0 {
+			clientSecret := ""
+			if !client.IsPublic {
+				clientSecret, err = lib.DecryptText(client.ClientSecretEncrypted, settings.AESEncryptionKey)
+				if err != nil {
+					s.internalServerError(w, r, err)
+					return
+				}
+			}
+			if subtle.ConstantTimeCompare([]byte(data.ClientSecret), []byte(clientSecret)) != 1 {
+				s.jsonError(w, r, customerrors.NewValidationError("invalid_client_metadata", "The client_secret does not match the secret of the client."))
+				return
+			}
+		}
+
+		metadata := data.ClientMetadata
+		err = clientRegistrationValidator.ValidateClientMetadata(r.Context(), &metadata)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+
+		err = s.applyClientMetadata(client, &metadata, settings, inputSanitizer)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		err = s.saveRegisteredClient(client, &metadata, false)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		lib.LogAudit(r.Context(), constants.AuditUpdatedRegisteredClient, map[string]interface{}{
+			"clientId":         client.Id,
+			"clientIdentifier": client.ClientIdentifier,
+		})
+
+		response, err := s.getClientRegistrationResponse(client, settings)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Pragma", "no-cache")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+func (s *Server) handleClientConfigurationDelete() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
+
+		client := s.getRegisteredClient(w, r, settings)
+		if client == nil {
+			return
+		}
+
+		err := s.database.DeleteClient(nil, client.Id)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		lib.LogAudit(r.Context(), constants.AuditDeletedRegisteredClient, map[string]interface{}{
+			"clientId":         client.Id,
+			"clientIdentifier": client.ClientIdentifier,
+		})
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// isClientRegistrationEnabled writes an error response and returns false when the
+// dynamic client registration is disabled in the settings
+func (s *Server) isClientRegistrationEnabled(w http.ResponseWriter, r *http.Request, settings *entities.Settings) bool {
+	registrationMode, err := enums.ClientRegistrationModeFromString(settings.ClientRegistrationMode)
+	if err != nil || registrationMode == enums.ClientRegistrationModeDisabled {
+		s.jsonErrorWithStatus(w, r, customerrors.NewValidationError("access_denied", "Dynamic client registration is disabled."), http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// getRegisteredClient returns the client of the client configuration endpoint, after checking
+// the registration access token. When the client can't be returned, it writes the error
+// response and returns nil
+func (s *Server) getRegisteredClient(w http.ResponseWriter, r *http.Request, settings *entities.Settings) *entities.Client {
+
+	if !s.isClientRegistrationEnabled(w, r, settings) {
+		return nil
+	}
+
+	client, err := s.database.GetClientByClientIdentifier(nil, chi.URLParam(r, "clientIdentifier"))
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return nil
+	}
+
+	// an unknown client gets the same response as an invalid token (RFC 7592, section 2.1)
+	bearerToken := getBearerToken(r)
+	if client == nil || !client.IsDynamicallyRegistered() || len(bearerToken) == 0 {
+		s.invalidTokenError(w, r, "The registration access token is missing or invalid.")
+		return nil
+	}
+
+	bearerTokenHash, err := lib.HashString(bearerToken)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return nil
+	}
+	if subtle.ConstantTimeCompare([]byte(bearerTokenHash), []byte(client.RegistrationAccessTokenHash)) != 1 {
+		s.invalidTokenError(w, r, "The registration access token is missing or invalid.")
+		return nil
+	}
+
+	err = s.database.ClientLoadRedirectURIs(nil, client)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return nil
+	}
+
+	err = s.database.ClientLoadPostLogoutRedirectURIs(nil, client)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return nil
+	}
+
+	err = s.database.ClientLoadWebOrigins(nil, client)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return nil
+	}
+
+	return client
+}
+
+func (s *Server) invalidTokenError(w http.ResponseWriter, r *http.Request, description string) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	s.jsonErrorWithStatus(w, r, customerrors.NewValidationError("invalid_token", description), http.StatusUnauthorized)
+}
+
+func getBearerToken(r *http.Request) string {
+	const BEARER_SCHEMA = "Bearer "
+	authHeader := r.Header.Get("Authorization")
+	if len(authHeader) <= len(BEARER_SCHEMA) || !strings.EqualFold(authHeader[:len(BEARER_SCHEMA)], BEARER_SCHEMA) {
+		return ""
+	}
+	return strings.TrimSpace(authHeader[len(BEARER_SCHEMA):])
+}
+
+// generateClientIdentifier returns a client identifier that is not in use yet
+func (s *Server) generateClientIdentifier() (string, error) {
+	for attempt := 0; attempt < 5; attempt++ {
+		bytes := make([]byte, 8)
+		_, err := rand.Read(bytes)
+		if err != nil {
+			return "", errors.Wrap(err, "unable to generate random bytes")
+		}
+		clientIdentifier := "client-" + hex.EncodeToString(bytes)
+
+		existing, err := s.database.GetClientByClientIdentifier(nil, clientIdentifier)
+		if err != nil {
+			return "", err
+		}
+		if existing == nil {
+			return clientIdentifier, nil
+		}
+	}
+	return "", errors.WithStack(errors.New("unable to generate a unique client identifier"))
+}
+
+// applyClientMetadata sets the fields of the client from its (validated) metadata. The
+// redirect URIs, post-logout redirect URIs and web origins are saved by saveRegisteredClient
+func (s *Server) applyClientMetadata(client *entities.Client, metadata *dtos.ClientMetadata,
+	settings *entities.Settings, inputSanitizer inputSanitizer) error {
+
+	client.Description = inputSanitizer.Sanitize(metadata.ClientName)
+	client.AuthorizationCodeEnabled = false
+	client.ClientCredentialsEnabled = false
+	client.DeviceCodeEnabled = false
+	for _, grantType := range metadata.GrantTypes {
+		switch grantType {
+		case "authorization_code":
+			client.AuthorizationCodeEnabled = true
+		case "client_credentials":
+			client.ClientCredentialsEnabled = true
+		case core_validators.DeviceCodeGrantType:
+			client.DeviceCodeEnabled = true
+		}
+	}
+
+	client.JWKS = string(metadata.JWKS)
+	client.JWKSURI = metadata.JWKSURI
+	client.TLSClientAuthSubjectDN = metadata.TLSClientAuthSubjectDN
+
+	if metadata.TokenEndpointAuthMethod == core_validators.TokenEndpointAuthMethodNone {
+		client.IsPublic = true
+		client.ClientSecretEncrypted = nil
+		client.TokenEndpointAuthMethod = enums.TokenEndpointAuthMethodClientSecretBasic.String()
+		return nil
+	}
+
+	client.TokenEndpointAuthMethod = metadata.TokenEndpointAuthMethod
+	if client.IsPublic || len(client.ClientSecretEncrypted) == 0 {
+		client.IsPublic = false
+		clientSecretEncrypted, err := lib.EncryptText(lib.GenerateSecureRandomString(60), settings.AESEncryptionKey)
+		if err != nil {
+			return err
+		}
+		client.ClientSecretEncrypted = clientSecretEncrypted
+	}
+	return nil
+}
+
+// saveRegisteredClient creates or updates the client, and replaces its redirect URIs,
+// post-logout redirect URIs and web origins with the ones in the metadata
+func (s *Server) saveRegisteredClient(client *entities.Client, metadata *dtos.ClientMetadata, isNew bool) error {
+
+	tx, err := s.database.BeginTransaction()
+	if err != nil {
+		return err
+	}
+	defer s.database.RollbackTransaction(tx)
+
+	if isNew {
+		err = s.database.CreateClient(tx, client)
+	} else {
+		err = s.database.UpdateClient(tx, client)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, redirectURI := range client.RedirectURIs {
+		err = s.database.DeleteRedirectURI(tx, redirectURI.Id)
+		if err != nil {
+			return err
+		}
+	}
+	for _, postLogoutRedirectURI := range client.PostLogoutRedirectURIs {
+		err = s.database.DeletePostLogoutRedirectURI(tx, postLogoutRedirectURI.Id)
+		if err != nil {
+			return err
+		}
+	}
+	for _, webOrigin := range client.WebOrigins {
+		err = s.database.DeleteWebOrigin(tx, webOrigin.Id)
+		if err != nil {
+			return err
+		}
+	}
+
+	client.RedirectURIs = []entities.RedirectURI{}
+	for _, uri := range metadata.RedirectURIs {
+		redirectURI := entities.RedirectURI{ClientId: client.Id, URI: uri}
+		err = s.database.CreateRedirectURI(tx, &redirectURI)
+		if err != nil {
+			return err
+		}
+		client.RedirectURIs = append(client.RedirectURIs, redirectURI)
+	}
+
+	client.PostLogoutRedirectURIs = []entities.PostLogoutRedirectURI{}
+	for _, uri := range metadata.PostLogoutRedirectURIs {
+		postLogoutRedirectURI := entities.PostLogoutRedirectURI{ClientId: client.Id, URI: uri}
+		err = s.database.CreatePostLogoutRedirectURI(tx, &postLogoutRedirectURI)
+		if err != nil {
+			return err
+		}
+		client.PostLogoutRedirectURIs = append(client.PostLogoutRedirectURIs, postLogoutRedirectURI)
+	}
+
+	client.WebOrigins = []entities.WebOrigin{}
+	for _, origin := range metadata.WebOrigins {
+		webOrigin := entities.WebOrigin{ClientId: client.Id, Origin: origin}
+		err = s.database.CreateWebOrigin(tx, &webOrigin)
+		if err != nil {
+			return err
+		}
+		client.WebOrigins = append(client.WebOrigins, webOrigin)
+	}
+
+	return s.database.CommitTransaction(tx)
+}
+
+func (s *Server) getClientRegistrationResponse(client *entities.Client, settings *entities.Settings) (*dtos.ClientRegistrationResponse, error) {
+
+	response := &dtos.ClientRegistrationResponse{
+		ClientId:              client.ClientIdentifier,
+		ClientIdIssuedAt:      client.CreatedAt.Time.Unix(),
+		ClientSecretExpiresAt: 0, // the secret doesn't expire
+		RegistrationClientURI: lib.GetBaseUrl() + "/connect/register/" + client.ClientIdentifier,
+		ClientMetadata: dtos.ClientMetadata{
+			ClientName:             client.Description,
+			RedirectURIs:           []string{},
+			PostLogoutRedirectURIs: []string{},
+			WebOrigins:             []string{},
+			GrantTypes:             []string{},
+			ResponseTypes:          []string{},
+			JWKSURI:                client.JWKSURI,
+			TLSClientAuthSubjectDN: client.TLSClientAuthSubjectDN,
+		},
+	}
+
+	if client.IsPublic {
+		response.TokenEndpointAuthMethod = core_validators.TokenEndpointAuthMethodNone
+	} else {
+		response.TokenEndpointAuthMethod = client.TokenEndpointAuthMethod
+		clientSecret, err := lib.DecryptText(client.ClientSecretEncrypted, settings.AESEncryptionKey)
+		if err != nil {
+			return nil, err
+		}
+		response.ClientSecret = clientSecret
+	}
+
+	if len(client.JWKS) > 0 {
+		response.JWKS = json.RawMessage(client.JWKS)
+	}
+
+	// refresh tokens are issued in the authorization code and device code flows
+	if client.AuthorizationCodeEnabled {
+		response.GrantTypes = append(response.GrantTypes, "authorization_code")
+		response.ResponseTypes = append(response.ResponseTypes, "code")
+	}
+	if client.ClientCredentialsEnabled {
+		response.GrantTypes = append(response.GrantTypes, "client_credentials")
+	}
+	if client.DeviceCodeEnabled {
+		response.GrantTypes = append(response.GrantTypes, core_validators.DeviceCodeGrantType)
+	}
+	if client.AuthorizationCodeEnabled || client.DeviceCodeEnabled {
+		response.GrantTypes = append(response.GrantTypes, "refresh_token")
+	}
+
+	for _, redirectURI := range client.RedirectURIs {
+		response.RedirectURIs = append(response.RedirectURIs, redirectURI.URI)
+	}
+	for _, postLogoutRedirectURI := range client.PostLogoutRedirectURIs {
+		response.PostLogoutRedirectURIs = append(response.PostLogoutRedirectURIs, postLogoutRedirectURI.URI)
+	}
+	for _, webOrigin := range client.WebOrigins {
+		response.WebOrigins = append(response.WebOrigins, webOrigin.Origin)
+	}
+
+	return response, nil
+}
@@ -106,12 +106,11 @@ func (s *Server) handleConsentGet(codeIssuer codeIssuer, permissionChecker *core
 		}
 
 		if !user.Enabled {
-			lib.LogAudit(constants.AuditUserDisabled, map[string]interface{}{
+			lib.LogAudit(r.Context(), constants.AuditUserDisabled, map[string]interface{}{
 				"userId": user.Id,
 			})
 
-			s.redirToClientWithError(w, r, "access_denied", "The user is not enabled", authContext.ResponseMode,
-				authContext.RedirectURI, authContext.State)
+			s.denyAuthorization(w, r, authContext, "access_denied", "The user is not enabled")
 			return
 		}
 
@@ -122,8 +121,7 @@ func (s *Server) handleConsentGet(codeIssuer codeIssuer, permissionChecker *core
 		}
 		authContext.SetScope(newScope)
 		if len(authContext.Scope) == 0 {
-			s.redirToClientWithError(w, r, "access_denied", "The user is not authorized to access any of the requested scopes", authContext.ResponseMode,
-				authContext.RedirectURI, authContext.State)
+			s.denyAuthorization(w, r, authContext, "access_denied", "The user is not authorized to access any of the requested scopes")
 			return
 		}
 		err = s.saveAuthContext(w, r, authContext)
@@ -147,8 +145,11 @@ func (s *Server) handleConsentGet(codeIssuer codeIssuer, permissionChecker *core
 			sessionIdentifier = r.Context().Value(common.ContextKeySessionIdentifier).(string)
 		}
 
-		// if the client requested an offline refresh token, consent is mandatory
-		if client.ConsentRequired || authContext.HasScope("offline_access") {
+		// if the client requested an offline refresh token, consent is mandatory. The same goes for
+		// a device, since the user must confirm that the device being connected is theirs
+		mustAskForConsent := authContext.HasScope("offline_access") || authContext.HasPrompt("consent") ||
+			authContext.DeviceCodeId != 0
+		if client.ConsentRequired || mustAskForConsent {
 
 			consent, err := s.database.GetConsentByUserIdAndClientId(nil, user.Id, client.Id)
 			if err != nil {
@@ -163,7 +164,12 @@ func (s *Server) handleConsentGet(codeIssuer codeIssuer, permissionChecker *core
 				scopesFullyConsented = scopesFullyConsented && scopeInfo.AlreadyConsented
 			}
 
-			if !scopesFullyConsented || authContext.HasScope("offline_access") {
+			if !scopesFullyConsented || mustAskForConsent {
+				if authContext.HasPrompt("none") {
+					s.denyAuthorization(w, r, authContext, "consent_required", "The user needs to give consent, but the prompt parameter is 'none'.")
+					return
+				}
+
 				bind := map[string]interface{}{
 					"csrfField":         csrf.TemplateField(r),
 					"clientIdentifier":  client.ClientIdentifier,
@@ -196,7 +202,7 @@ func (s *Server) handleConsentGet(codeIssuer codeIssuer, permissionChecker *core
 			s.internalServerError(w, r, err)
 			return
 		}
-		err = s.issueAuthCode(w, r, code, authContext.ResponseMode)
+		err = s.completeAuthorization(w, r, authContext, code)
 		if err != nil {
 			s.internalServerError(w, r, err)
 		}
@@ -232,8 +238,7 @@ func (s *Server) handleConsentPost(codeIssuer codeIssuer) http.HandlerFunc {
 			consented = strings.TrimSpace(consented)
 
 			if len(consented) == 0 {
-				s.redirToClientWithError(w, r, "access_denied", "The user did not provide consent", authContext.ResponseMode,
-					authContext.RedirectURI, authContext.State)
+				s.denyAuthorization(w, r, authContext, "access_denied", "The user did not provide consent")
 			} else {
 
 				client, err := s.database.GetClientByClientIdentifier(nil, authContext.ClientId)
@@ -296,7 +301,7 @@ func (s *Server) handleConsentPost(codeIssuer codeIssuer) http.HandlerFunc {
 				}
 				authContext.ConsentedScope = consent.Scope
 
-				lib.LogAudit(constants.AuditSavedConsent, map[string]interface{}{
+				lib.LogAudit(r.Context(), constants.AuditSavedConsent, map[string]interface{}{
 					"userId":   consent.UserId,
 					"clientId": consent.ClientId,
 				})
@@ -321,7 +326,7 @@ func (s *Server) handleConsentPost(codeIssuer codeIssuer) http.HandlerFunc {
 					s.internalServerError(w, r, err)
 					return
 				}
-				err = s.issueAuthCode(w, r, code, authContext.ResponseMode)
+				err = s.completeAuthorization(w, r, authContext, code)
 				if err != nil {
 					s.internalServerError(w, r, err)
 				}
@@ -329,8 +334,7 @@ func (s *Server) handleConsentPost(codeIssuer codeIssuer) http.HandlerFunc {
 			}
 
 		} else if btn == "cancel" {
-			s.redirToClientWithError(w, r, "access_denied", "The user did not provide consent", authContext.ResponseMode,
-				authContext.RedirectURI, authContext.State)
+			s.denyAuthorization(w, r, authContext, "access_denied", "The user did not provide consent")
 		}
 	}
 }
@@ -341,10 +345,18 @@ func (s *Server) issueAuthCode(w http.ResponseWriter, r *http.Request, code *ent
 		responseMode = "query"
 	}
 
+	sessionState, err := s.getSessionState(code)
+	if err != nil {
+		return err
+	}
+
 	if responseMode == "fragment" {
 		values := url.Values{}
 		values.Add("code", code.Code)
 		values.Add("state", code.State)
+		if len(sessionState) > 0 {
+			values.Add("session_state", sessionState)
+		}
 		http.Redirect(w, r, code.RedirectURI+"#"+values.Encode(), http.StatusFound)
 		return nil
 	}
@@ -355,6 +367,9 @@ func (s *Server) issueAuthCode(w http.ResponseWriter, r *http.Request, code *ent
 		if len(strings.TrimSpace(code.State)) > 0 {
 			m["state"] = code.State
 		}
+		if len(sessionState) > 0 {
+			m["sessionState"] = sessionState
+		}
 
 		t, err := template.ParseFS(s.templateFS, "form_post.html")
 		if err != nil {
@@ -372,7 +387,54 @@ func (s *Server) issueAuthCode(w http.ResponseWriter, r *http.Request, code *ent
 	values := redirUrl.Query()
 	values.Add("code", code.Code)
 	values.Add("state", code.State)
+	if len(sessionState) > 0 {
+		values.Add("session_state", sessionState)
+	}
 	redirUrl.RawQuery = values.Encode()
 	http.Redirect(w, r, redirUrl.String(), http.StatusFound)
 	return nil
 }
+
+// getSessionState returns the session_state of the authorization response, or an empty
+// string when the origin of the redirect URI is not one of the web origins of the client
+func (s *Server) getSessionState(code *entities.Code) (string, error) {
+
+	if len(code.SessionIdentifier) == 0 {
+		return "", nil
+	}
+
+	client, err := s.database.GetClientById(nil, code.ClientId)
+	if err != nil {
+		return "", err
+	}
+	if client == nil {
+		return "", errors.WithStack(fmt.Errorf("client %v not found", code.ClientId))
+	}
+
+	err = s.database.ClientLoadWebOrigins(nil, client)
+	if err != nil {
+		return "", err
+	}
+
+	origin, err := lib.GetOrigin(code.RedirectURI)
+	if err != nil {
+		return "", err
+	}
+
+	originAllowed := false
+	for _, webOrigin := range client.WebOrigins {
+		if strings.TrimSuffix(webOrigin.Origin, "/") == origin {
+			originAllowed = true
+			break
+		}
+	}
+	if !originAllowed {
+		return "", nil
+	}
+
+	browserState, err := lib.GetBrowserState(code.SessionIdentifier)
+	if err != nil {
+		return "", err
+	}
+	return lib.GetSessionState(client.ClientIdentifier, origin, browserState, lib.GenerateSecureRandomString(16))
+}
@@ -0,0 +1,368 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/csrf"
+	"github.com/pkg/errors"
+
+	"github.com/leodip/goiabada/internal/constants"
+	core_validators "github.com/leodip/goiabada/internal/core/validators"
+	"github.com/leodip/goiabada/internal/customerrors"
+	"github.com/leodip/goiabada/internal/dtos"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/leodip/goiabada/internal/lib"
+)
+
+// deviceCodeLifetime is how long the user has to enter the user code and approve the device
+const deviceCodeLifetime = 10 * time.Minute
+
+// deviceCodePollingInterval is the minimum number of seconds the device must wait between
+// requests to the token endpoint (RFC 8628, section 3.5)
+const deviceCodePollingInterval = 5
+
+// deviceMaxFailedAttempts is how many invalid user codes can be entered from an IP address
+// within deviceFailedAttemptsWindow. Beyond that, the entry of user codes is locked until the
+// oldest of them falls out of the window
+const deviceMaxFailedAttempts = 5
+
+const deviceFailedAttemptsWindow = 5 * time.Minute
+
+func (s *Server) handleDeviceAuthorizationPost(tokenValidator tokenValidator,
+	authorizeValidator authorizeValidator) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		r.ParseForm()
+		clientCredentials, err := s.getClientCredentials(r)
+		if err != nil {
+			s.clientAuthenticationError(w, r, err)
+			return
+		}
+
+		result, err := tokenValidator.ValidateDeviceAuthorizationRequest(r.Context(), &core_validators.ValidateDeviceAuthorizationRequestInput{
+			ClientCredentials: *clientCredentials,
+		})
+		if err != nil {
+			s.clientAuthenticationError(w, r, err)
+			return
+		}
+		client := result.Client
+
+		space := regexp.MustCompile(`\s+`)
+		scope := strings.TrimSpace(space.ReplaceAllString(r.PostForm.Get("scope"), " "))
+
+		err = authorizeValidator.ValidateScopes(r.Context(), scope)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+
+		deviceCode := lib.GenerateSecureRandomString(64)
+		deviceCodeHash, err := lib.HashString(deviceCode)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		// the user code is short, so make sure it's not in use by another device
+		userCode := ""
+		userCodeHash := ""
+		for attempt := 0; attempt < 5 && len(userCodeHash) == 0; attempt++ {
+			candidate := lib.GenerateUserCode()
+			candidateHash, err := lib.HashString(lib.NormalizeUserCode(candidate))
+			if err != nil {
+				s.internalServerError(w, r, err)
+				return
+			}
+			existing, err := s.database.GetDeviceCodeByUserCodeHash(nil, candidateHash)
+			if err != nil {
+				s.internalServerError(w, r, err)
+				return
+			}
+			if existing == nil {
+				userCode = candidate
+				userCodeHash = candidateHash
+			}
+		}
+		if len(userCodeHash) == 0 {
+			s.internalServerError(w, r, errors.WithStack(errors.New("unable to generate a unique user code")))
+			return
+		}
+
+		err = s.database.CreateDeviceCode(nil, &entities.DeviceCode{
+			ClientId:          client.Id,
+			DeviceCodeHash:    deviceCodeHash,
+			UserCodeHash:      userCodeHash,
+			Scope:             scope,
+			Status:            enums.DeviceCodeStatusPending.String(),
+			IntervalInSeconds: deviceCodePollingInterval,
+			ExpiresAt:         sql.NullTime{Time: time.Now().UTC().Add(deviceCodeLifetime), Valid: true},
+		})
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		lib.LogAudit(r.Context(), constants.AuditCreatedDeviceCode, map[string]interface{}{
+			"clientId": client.Id,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Pragma", "no-cache")
+		json.NewEncoder(w).Encode(dtos.DeviceAuthorizationResponse{
+			DeviceCode:              deviceCode,
+			UserCode:                userCode,
+			VerificationURI:         lib.GetBaseUrl() + "/device",
+			VerificationURIComplete: lib.GetBaseUrl() + "/device?user_code=" + url.QueryEscape(userCode),
+			ExpiresIn:               int64(deviceCodeLifetime.Seconds()),
+			Interval:                deviceCodePollingInterval,
+		})
+	}
+}
+
+func (s *Server) handleDeviceGet() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		bind := map[string]interface{}{
+			"userCode":  r.URL.Query().Get("user_code"),
+			"csrfField": csrf.TemplateField(r),
+		}
+
+		err := s.renderTemplate(w, r, "/layouts/auth_layout.html", "/device.html", bind)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+	}
+}
+
+func (s *Server) handleDevicePost(loginManager loginManager) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		userCode := strings.TrimSpace(r.FormValue("userCode"))
+
+		renderError := func(message string) {
+			bind := map[string]interface{}{
+				"userCode":  userCode,
+				"error":     message,
+				"csrfField": csrf.TemplateField(r),
+			}
+
+			err := s.renderTemplate(w, r, "/layouts/auth_layout.html", "/device.html", bind)
+			if err != nil {
+				s.internalServerError(w, r, err)
+			}
+		}
+
+		// the user codes are short, so the number of guesses from an IP address is limited. The
+		// invalid codes are kept in the database, so that they count across sessions and instances
+		ipAddress, _, _ := net.SplitHostPort(r.RemoteAddr)
+		if len(ipAddress) == 0 {
+			ipAddress = r.RemoteAddr
+		}
+		failedAttempts, err := s.database.CountDeviceUserCodeAttemptsSince(nil, ipAddress,
+			time.Now().UTC().Add(-deviceFailedAttemptsWindow))
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		const lockedMessage = "Too many invalid codes were entered. Please wait a few minutes and try again."
+		if failedAttempts >= deviceMaxFailedAttempts {
+			renderError(lockedMessage)
+			return
+		}
+
+		if len(userCode) == 0 {
+			renderError("Please enter the code displayed on your device.")
+			return
+		}
+
+		const invalidCodeMessage = "The code is invalid or has expired. Please check the code displayed on your device."
+
+		renderInvalidCode := func() {
+			err := s.database.CreateDeviceUserCodeAttempt(nil, &entities.DeviceUserCodeAttempt{
+				IpAddress: ipAddress,
+			})
+			if err != nil {
+				s.internalServerError(w, r, err)
+				return
+			}
+
+			if failedAttempts+1 >= deviceMaxFailedAttempts {
+				lib.LogAudit(r.Context(), constants.AuditDeviceCodeEntryLocked, map[string]interface{}{
+					"ipAddress": ipAddress,
+				})
+				renderError(lockedMessage)
+				return
+			}
+			renderError(invalidCodeMessage)
+		}
+
+		userCodeHash, err := lib.HashString(lib.NormalizeUserCode(userCode))
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		deviceCode, err := s.database.GetDeviceCodeByUserCodeHash(nil, userCodeHash)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		if deviceCode == nil || deviceCode.Status != enums.DeviceCodeStatusPending.String() ||
+			time.Now().UTC().After(deviceCode.ExpiresAt.Time) {
+			renderInvalidCode()
+			return
+		}
+
+		client, err := s.database.GetClientById(nil, deviceCode.ClientId)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+		if client == nil || !client.Enabled || !client.DeviceCodeEnabled {
+			renderError(invalidCodeMessage)
+			return
+		}
+
+		authContext := dtos.AuthContext{
+			ClientId:     client.ClientIdentifier,
+			UserAgent:    r.UserAgent(),
+			IpAddress:    r.RemoteAddr,
+			DeviceCodeId: deviceCode.Id,
+		}
+		authContext.SetScope(deviceCode.Scope)
+
+		err = s.saveAuthContext(w, r, &authContext)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		s.continueAuthentication(w, r, loginManager, &authContext, func(validationError *customerrors.ValidationError) {
+			s.denyAuthorization(w, r, &authContext, validationError.Code, validationError.Description)
+		})
+	}
+}
+
+// completeAuthorization hands the authorization code over to the client. A device gets it
+// when it polls the token endpoint, so the user is just told to go back to the device
+func (s *Server) completeAuthorization(w http.ResponseWriter, r *http.Request, authContext *dtos.AuthContext,
+	code *entities.Code) error {
+
+	if authContext.DeviceCodeId == 0 {
+		return s.issueAuthCode(w, r, code, authContext.ResponseMode)
+	}
+
+	deviceCode, err := s.getPendingDeviceCode(authContext.DeviceCodeId)
+	if err != nil {
+		return err
+	}
+	if deviceCode == nil {
+		return s.renderDeviceResult(w, r, false, "The code has expired. Please start again on your device.")
+	}
+
+	approved, err := s.database.ApproveDeviceCode(nil, deviceCode.Id, code.Id)
+	if err != nil {
+		return err
+	}
+	if !approved {
+		return s.renderDeviceResult(w, r, false, "The code is no longer valid. Please start again on your device.")
+	}
+
+	lib.LogAudit(r.Context(), constants.AuditApprovedDeviceCode, map[string]interface{}{
+		"clientId": deviceCode.ClientId,
+		"userId":   code.UserId,
+	})
+
+	return s.renderDeviceResult(w, r, true, "Your device is now connected. You can close this window and return to your device.")
+}
+
+// denyAuthorization ends the authorization with an error. A device has no redirect URI, so
+// the user sees the error here and the device gets access_denied when it polls
+func (s *Server) denyAuthorization(w http.ResponseWriter, r *http.Request, authContext *dtos.AuthContext,
+	code string, description string) {
+
+	if authContext.DeviceCodeId == 0 {
+		err := s.redirToClientWithError(w, r, code, description, authContext.ResponseMode,
+			authContext.RedirectURI, authContext.State)
+		if err != nil {
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	deviceCode, err := s.getPendingDeviceCode(authContext.DeviceCodeId)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+	denied := false
+	if deviceCode != nil {
+		denied, err = s.database.DenyDeviceCode(nil, deviceCode.Id)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+	}
+	if denied {
+		lib.LogAudit(r.Context(), constants.AuditDeniedDeviceCode, map[string]interface{}{
+			"clientId": deviceCode.ClientId,
+			"userId":   authContext.UserId,
+		})
+	}
+
+	err = s.clearAuthContext(w, r)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	err = s.renderDeviceResult(w, r, false, description)
+	if err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+func (s *Server) getPendingDeviceCode(deviceCodeId int64) (*entities.DeviceCode, error) {
+
+	deviceCode, err := s.database.GetDeviceCodeById(nil, deviceCodeId)
+	if err != nil {
+		return nil, err
+	}
+	if deviceCode == nil || deviceCode.Status != enums.DeviceCodeStatusPending.String() ||
+		time.Now().UTC().After(deviceCode.ExpiresAt.Time) {
+		return nil, nil
+	}
+	return deviceCode, nil
+}
+
+func (s *Server) renderDeviceResult(w http.ResponseWriter, r *http.Request, approved bool, message string) error {
+
+	title := "Device connected"
+	if !approved {
+		title = "Unable to connect the device"
+	}
+
+	bind := map[string]interface{}{
+		"title":    title,
+		"approved": approved,
+		"message":  message,
+	}
+
+	return s.renderTemplate(w, r, "/layouts/no_menu_layout.html", "/device_result.html", bind)
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/leodip/goiabada/internal/constants"
+	core_validators "github.com/leodip/goiabada/internal/core/validators"
+	"github.com/leodip/goiabada/internal/customerrors"
+	"github.com/leodip/goiabada/internal/dtos"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/lib"
+)
+
+const requestURIPrefix = "urn:ietf:params:oauth:request_uri:"
+
+// pushedAuthorizationRequestLifetime is how long the client has to use the request_uri at
+// the authorization endpoint. It's meant to be used right away, and only once
+const pushedAuthorizationRequestLifetime = 60 * time.Second
+
+// authorizationRequestParameters are the parameters of an authorization request that can be
+// pushed to the PAR endpoint and later redeemed at the authorization endpoint
+var authorizationRequestParameters = []string{
+	"client_id", "redirect_uri", "response_type", "code_challenge_method", "code_challenge",
+	"response_mode", "max_age", "acr_values", "state", "nonce", "scope", "request",
+	"prompt", "login_hint", "id_token_hint",
+}
+
+func (s *Server) handlePushedAuthorizationRequestPost(tokenValidator tokenValidator,
+	authorizeValidator authorizeValidator) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		r.ParseForm()
+		clientCredentials, err := s.getClientCredentials(r)
+		if err != nil {
+			s.clientAuthenticationError(w, r, err)
+			return
+		}
+
+		result, err := tokenValidator.ValidatePushedAuthorizationRequest(r.Context(), &core_validators.ValidatePushedAuthorizationRequestInput{
+			ClientCredentials: *clientCredentials,
+			RequestURI:        r.PostForm.Get("request_uri"),
+		})
+		if err != nil {
+			s.clientAuthenticationError(w, r, err)
+			return
+		}
+		client := result.Client
+
+		parameters := url.Values{}
+		for _, name := range authorizationRequestParameters {
+			if value := r.PostForm.Get(name); len(value) > 0 {
+				parameters.Set(name, value)
+			}
+		}
+		// the client_id may have been sent with HTTP Basic only
+		parameters.Set("client_id", client.ClientIdentifier)
+
+		// the request object is kept with the parameters, and verified again when the
+		// request_uri is used. Here it's verified so that errors are reported to the client
+		params, isSignedRequestObject, err := s.applyRequestObject(r.Context(), authorizeValidator, parameters, false)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+
+		err = authorizeValidator.ValidateClientAndRedirectURI(r.Context(), &core_validators.ValidateClientAndRedirectURIInput{
+			RequestId:                    middleware.GetReqID(r.Context()),
+			ClientId:                     client.ClientIdentifier,
+			RedirectURI:                  params.Get("redirect_uri"),
+			IsPushedAuthorizationRequest: true,
+			IsSignedRequestObject:        isSignedRequestObject,
+		})
+		if err != nil {
+			if valError, ok := err.(*customerrors.ValidationError); ok {
+				// these errors are meant for the UI of the authorization endpoint, and have no code
+				err = customerrors.NewValidationError("invalid_request", valError.Description)
+			}
+			s.jsonError(w, r, err)
+			return
+		}
+
+		err = authorizeValidator.ValidateRequest(r.Context(), &core_validators.ValidateRequestInput{
+			ResponseType:        params.Get("response_type"),
+			CodeChallengeMethod: params.Get("code_challenge_method"),
+			CodeChallenge:       params.Get("code_challenge"),
+			ResponseMode:        params.Get("response_mode"),
+			Prompt:              params.Get("prompt"),
+		})
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+
+		err = authorizeValidator.ValidateScopes(r.Context(), params.Get("scope"))
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+
+		requestURI := requestURIPrefix + lib.GenerateSecureRandomString(32)
+		requestURIHash, err := lib.HashString(requestURI)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		err = s.database.CreatePushedAuthorizationRequest(nil, &entities.PushedAuthorizationRequest{
+			ClientId:       client.Id,
+			RequestURIHash: requestURIHash,
+			Parameters:     parameters.Encode(),
+			ExpiresAt:      sql.NullTime{Time: time.Now().UTC().Add(pushedAuthorizationRequestLifetime), Valid: true},
+		})
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		lib.LogAudit(r.Context(), constants.AuditCreatedPushedAuthorizationRequest, map[string]interface{}{
+			"clientId": client.Id,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Pragma", "no-cache")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(dtos.PushedAuthorizationResponse{
+			RequestURI: requestURI,
+			ExpiresIn:  int64(pushedAuthorizationRequestLifetime.Seconds()),
+		})
+	}
+}
+
+// redeemPushedAuthorizationRequest returns the parameters pushed by the client for the
+// request_uri (RFC 9126, section 4). A request_uri can only be used once
+func (s *Server) redeemPushedAuthorizationRequest(requestURI string, clientIdentifier string) (url.Values, error) {
+
+	invalidRequestURI := customerrors.NewValidationError("", "The request_uri parameter is invalid or has expired.")
+
+	requestURIHash, err := lib.HashString(requestURI)
+	if err != nil {
+		return nil, err
+	}
+
+	pushedAuthorizationRequest, err := s.database.GetPushedAuthorizationRequestByRequestURIHash(nil, requestURIHash)
+	if err != nil {
+		return nil, err
+	}
+	if pushedAuthorizationRequest == nil {
+		return nil, invalidRequestURI
+	}
+
+	// only the request that deletes the row may redeem it
+	deleted, err := s.database.DeletePushedAuthorizationRequest(nil, pushedAuthorizationRequest.Id)
+	if err != nil {
+		return nil, err
+	}
+	if !deleted {
+		return nil, invalidRequestURI
+	}
+
+	if pushedAuthorizationRequest.ExpiresAt.Time.Before(time.Now().UTC()) {
+		return nil, invalidRequestURI
+	}
+
+	parameters, err := url.ParseQuery(pushedAuthorizationRequest.Parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	if parameters.Get("client_id") != clientIdentifier {
+		return nil, customerrors.NewValidationError("", "The client_id parameter does not match the client that pushed the authorization request.")
+	}
+
+	return parameters, nil
+}
@@ -143,6 +143,11 @@ func (s *Server) handleResetPasswordPost(passwordValidator passwordValidator) ht
 		user.PasswordHash = passwordHash
 		user.ForgotPasswordCodeEncrypted = nil
 		user.ForgotPasswordCodeIssuedAt = sql.NullTime{Valid: false}
+		// the user proved access to the email, so a temporary lockout can be lifted.
+		// Permanent locks still require an administrator
+		user.FailedLoginAttempts = 0
+		user.LastFailedLoginAt = sql.NullTime{Valid: false}
+		user.LockedUntil = sql.NullTime{Valid: false}
 		err = s.database.UpdateUser(nil, user)
 		if err != nil {
 			s.internalServerError(w, r, err)
@@ -4,39 +4,110 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/leodip/goiabada/internal/common"
 	"github.com/leodip/goiabada/internal/constants"
 	core_token "github.com/leodip/goiabada/internal/core/token"
 	core_validators "github.com/leodip/goiabada/internal/core/validators"
 	"github.com/leodip/goiabada/internal/customerrors"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
 	"github.com/leodip/goiabada/internal/lib"
 )
 
-func (s *Server) handleTokenPost(tokenIssuer tokenIssuer, tokenValidator tokenValidator) http.HandlerFunc {
+func (s *Server) handleTokenPost(tokenIssuer tokenIssuer, tokenValidator tokenValidator,
+	dpopValidator dpopValidator) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		r.ParseForm()
+		clientCredentials, err := s.getClientCredentials(r)
+		if err != nil {
+			s.clientAuthenticationError(w, r, err)
+			return
+		}
+
 		input := core_validators.ValidateTokenRequestInput{
-			GrantType:    r.PostForm.Get("grant_type"),
-			Code:         r.PostForm.Get("code"),
-			RedirectURI:  r.PostForm.Get("redirect_uri"),
-			CodeVerifier: r.PostForm.Get("code_verifier"),
-			ClientId:     r.PostForm.Get("client_id"),
-			ClientSecret: r.PostForm.Get("client_secret"),
-			Scope:        r.PostForm.Get("scope"),
-			RefreshToken: r.PostForm.Get("refresh_token"),
+			ClientCredentials: *clientCredentials,
+			GrantType:         r.PostForm.Get("grant_type"),
+			Code:              r.PostForm.Get("code"),
+			RedirectURI:       r.PostForm.Get("redirect_uri"),
+			CodeVerifier:      r.PostForm.Get("code_verifier"),
+			Scope:             r.PostForm.Get("scope"),
+			RefreshToken:      r.PostForm.Get("refresh_token"),
+			DeviceCode:        r.PostForm.Get("device_code"),
 		}
 
 		validateTokenRequestResult, err := tokenValidator.ValidateTokenRequest(r.Context(), &input)
 		if err != nil {
+			s.clientAuthenticationError(w, r, err)
+			return
+		}
+
+		settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
+		client := validateTokenRequestResult.Client
+
+		dpopError := func(err error) {
+			if valError, ok := err.(*customerrors.ValidationError); ok && valError.Code == "use_dpop_nonce" {
+				w.Header().Set("DPoP-Nonce", dpopValidator.GenerateNonce(settings))
+			}
 			s.jsonError(w, r, err)
+		}
+
+		// a refresh token issued to a public client can be bound to the DPoP key (RFC 9449, section 5)
+		refreshTokenJkt := ""
+		if validateTokenRequestResult.RefreshTokenInfo != nil {
+			refreshTokenJkt = validateTokenRequestResult.RefreshTokenInfo.GetConfirmationClaim()["jkt"]
+		}
+
+		dpopJkt := ""
+		dpopProofs := r.Header.Values("DPoP")
+		if len(dpopProofs) > 1 {
+			dpopError(customerrors.NewValidationError("invalid_dpop_proof", "Only one DPoP proof can be provided."))
+			return
+		}
+		if len(dpopProofs) == 1 && (client.IsDPoPEnabled() || len(refreshTokenJkt) > 0) {
+			dpopResult, err := dpopValidator.ValidateDPoPProof(r.Context(), &core_validators.ValidateDPoPProofInput{
+				Proof:            dpopProofs[0],
+				HttpMethod:       r.Method,
+				HttpURL:          lib.GetBaseUrl() + r.URL.Path,
+				ClientIdentifier: client.ClientIdentifier,
+			})
+			if err != nil {
+				dpopError(err)
+				return
+			}
+			dpopJkt = dpopResult.Jkt
+		}
+
+		if client.IsDPoPRequired() && len(dpopJkt) == 0 {
+			dpopError(customerrors.NewValidationError("invalid_dpop_proof", "This client is required to use DPoP. Please provide a DPoP proof in the DPoP header."))
+			return
+		}
+		if len(refreshTokenJkt) > 0 && refreshTokenJkt != dpopJkt {
+			dpopError(customerrors.NewValidationError("invalid_dpop_proof", "The refresh token is bound to a DPoP key. Please provide a DPoP proof signed with the same key."))
 			return
 		}
+		if client.DPoPNonceRequired && client.IsDPoPEnabled() {
+			w.Header().Set("DPoP-Nonce", dpopValidator.GenerateNonce(settings))
+		}
+
+		// the access token is bound to the certificate the client presented (RFC 8705),
+		// and to the key of the DPoP proof (RFC 9449)
+		var confirmation *core_token.TokenConfirmation
+		if len(clientCredentials.ClientCertificates) > 0 || len(dpopJkt) > 0 {
+			confirmation = &core_token.TokenConfirmation{
+				Jkt: dpopJkt,
+			}
+			if len(clientCredentials.ClientCertificates) > 0 {
+				confirmation.X5tS256 = lib.GetCertificateThumbprint(clientCredentials.ClientCertificates[0])
+			}
+		}
 
-		if input.GrantType == "authorization_code" {
+		if input.GrantType == "authorization_code" || input.GrantType == core_validators.DeviceCodeGrantType {
 
 			tokenResp, err := tokenIssuer.GenerateTokenResponseForAuthCode(r.Context(),
 				&core_token.GenerateTokenResponseForAuthCodeInput{
-					Code: validateTokenRequestResult.CodeEntity,
+					Code:         validateTokenRequestResult.CodeEntity,
+					Confirmation: confirmation,
 				})
 			if err != nil {
 				s.internalServerError(w, r, err)
@@ -49,7 +120,11 @@ func (s *Server) handleTokenPost(tokenIssuer tokenIssuer, tokenValidator tokenVa
 				return
 			}
 
-			lib.LogAudit(constants.AuditTokenIssuedAuthorizationCodeResponse, map[string]interface{}{
+			auditEvent := constants.AuditTokenIssuedAuthorizationCodeResponse
+			if input.GrantType == core_validators.DeviceCodeGrantType {
+				auditEvent = constants.AuditTokenIssuedDeviceCodeResponse
+			}
+			lib.LogAudit(r.Context(), auditEvent, map[string]interface{}{
 				"codeId": validateTokenRequestResult.CodeEntity.Id,
 			})
 
@@ -62,13 +137,13 @@ func (s *Server) handleTokenPost(tokenIssuer tokenIssuer, tokenValidator tokenVa
 		} else if input.GrantType == "client_credentials" {
 
 			tokenResp, err := tokenIssuer.GenerateTokenResponseForClientCred(r.Context(),
-				validateTokenRequestResult.Client, validateTokenRequestResult.Scope)
+				validateTokenRequestResult.Client, validateTokenRequestResult.Scope, confirmation)
 			if err != nil {
 				s.internalServerError(w, r, err)
 				return
 			}
 
-			lib.LogAudit(constants.AuditTokenIssuedClientCredentialsResponse, map[string]interface{}{
+			lib.LogAudit(r.Context(), constants.AuditTokenIssuedClientCredentialsResponse, map[string]interface{}{
 				"clientId": validateTokenRequestResult.Client.Id,
 			})
 
@@ -80,23 +155,34 @@ func (s *Server) handleTokenPost(tokenIssuer tokenIssuer, tokenValidator tokenVa
 
 		} else if input.GrantType == "refresh_token" {
 			refreshToken := validateTokenRequestResult.RefreshToken
-			if refreshToken.Revoked {
-				s.jsonError(w, r, customerrors.NewValidationError("invalid_grant", "This refresh token has been revoked."))
+
+			refreshTokenRotationEnabled := settings.RefreshTokenRotationEnabled
+			if validateTokenRequestResult.Client.RefreshTokenRotationEnabled != enums.ThreeStateSettingDefault.String() {
+				refreshTokenRotationEnabled = validateTokenRequestResult.Client.RefreshTokenRotationEnabled == enums.ThreeStateSettingOn.String()
+			}
+
+			// a refresh token can be used only once. With rotation, it's also marked as used,
+			// so that if it's presented again the whole family of refresh tokens will be
+			// revoked (reuse detection)
+			redeemed, err := s.database.RedeemRefreshToken(nil, refreshToken.Id, refreshTokenRotationEnabled)
+			if err != nil {
+				s.internalServerError(w, r, err)
+				return
+			}
+			if !redeemed {
+				// another request used the same refresh token in the meantime
+				s.jsonError(w, r, customerrors.NewValidationError("invalid_grant", "This refresh token has already been used."))
 				return
-			} else {
-				refreshToken.Revoked = true
-				err = s.database.UpdateRefreshToken(nil, refreshToken)
-				if err != nil {
-					s.internalServerError(w, r, err)
-					return
-				}
 			}
+			refreshToken.Revoked = true
+			refreshToken.Used = refreshTokenRotationEnabled
 
 			input := &core_token.GenerateTokenForRefreshInput{
 				Code:             validateTokenRequestResult.CodeEntity,
 				ScopeRequested:   input.Scope,
 				RefreshToken:     validateTokenRequestResult.RefreshToken,
 				RefreshTokenInfo: validateTokenRequestResult.RefreshTokenInfo,
+				Confirmation:     confirmation,
 			}
 
 			tokenResp, err := tokenIssuer.GenerateTokenResponseForRefresh(r.Context(), input)
@@ -114,7 +200,7 @@ func (s *Server) handleTokenPost(tokenIssuer tokenIssuer, tokenValidator tokenVa
 				}
 			}
 
-			lib.LogAudit(constants.AuditTokenIssuedRefreshTokenResponse, map[string]interface{}{
+			lib.LogAudit(r.Context(), constants.AuditTokenIssuedRefreshTokenResponse, map[string]interface{}{
 				"codeId":          validateTokenRequestResult.CodeEntity.Id,
 				"refreshTokenJti": validateTokenRequestResult.RefreshToken.RefreshTokenJti,
 			})
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/leodip/goiabada/internal/common"
+	core_validators "github.com/leodip/goiabada/internal/core/validators"
+	"github.com/leodip/goiabada/internal/dtos"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+)
+
+func (s *Server) handleTokenIntrospectionPost(tokenValidator tokenValidator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		r.ParseForm()
+		clientCredentials, err := s.getClientCredentials(r)
+		if err != nil {
+			s.clientAuthenticationError(w, r, err)
+			return
+		}
+
+		input := core_validators.ValidateTokenIntrospectionRequestInput{
+			ClientCredentials: *clientCredentials,
+			Token:             r.PostForm.Get("token"),
+			TokenTypeHint:     r.PostForm.Get("token_type_hint"),
+		}
+
+		validateTokenIntrospectionResult, err := tokenValidator.ValidateTokenIntrospectionRequest(r.Context(), &input)
+		if err != nil {
+			s.clientAuthenticationError(w, r, err)
+			return
+		}
+
+		introspectionResponse, err := s.introspectToken(r, input.Token, validateTokenIntrospectionResult.Client)
+		if err != nil {
+			s.jsonError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Pragma", "no-cache")
+		json.NewEncoder(w).Encode(introspectionResponse)
+	}
+}
+
+// introspectToken builds the RFC 7662 response for a token. Any token that can't be
+// parsed, is expired, or is no longer backed by a valid session is reported as inactive
+func (s *Server) introspectToken(r *http.Request, token string, client *entities.Client) (*dtos.TokenIntrospectionResponse, error) {
+
+	settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
+
+	inactive := &dtos.TokenIntrospectionResponse{
+		Active: false,
+	}
+
+	tokenInfo, err := s.tokenParser.ParseToken(r.Context(), token, true)
+	if err != nil || tokenInfo.Claims == nil || !tokenInfo.SignatureIsValid || tokenInfo.IsExpired {
+		return inactive, nil
+	}
+
+	response := &dtos.TokenIntrospectionResponse{
+		Active:    true,
+		Scope:     tokenInfo.GetStringClaim("scope"),
+		ClientId:  tokenInfo.GetStringClaim("client_id"),
+		Username:  tokenInfo.GetStringClaim("preferred_username"),
+		TokenType: tokenInfo.GetStringClaim("typ"),
+		Exp:       tokenInfo.GetTimeClaim("exp").Unix(),
+		Iat:       tokenInfo.GetTimeClaim("iat").Unix(),
+		Sub:       tokenInfo.GetStringClaim("sub"),
+		Iss:       tokenInfo.GetStringClaim("iss"),
+		Jti:       tokenInfo.GetStringClaim("jti"),
+		Acr:       tokenInfo.GetStringClaim("acr"),
+		Sid:       tokenInfo.GetStringClaim("sid"),
+	}
+	if cnf := tokenInfo.GetConfirmationClaim(); len(cnf) > 0 {
+		response.Cnf = cnf
+	}
+	if nbf := tokenInfo.GetTimeClaim("nbf"); !nbf.IsZero() {
+		response.Nbf = nbf.Unix()
+	}
+	if aud := tokenInfo.GetAudience(); len(aud) == 1 {
+		response.Aud = aud[0]
+	} else if len(aud) > 1 {
+		response.Aud = aud
+	}
+
+	switch response.TokenType {
+	case enums.TokenTypeBearer.String():
+		if len(response.Sid) > 0 {
+			// access token issued to a user, it's only active while the user session is
+			isSessionValid, err := s.isUserSessionValid(settings, response.Sid)
+			if err != nil {
+				return nil, err
+			}
+			if !isSessionValid {
+				return inactive, nil
+			}
+		}
+		if len(response.Cnf["jkt"]) > 0 {
+			// access token bound to a DPoP key (RFC 9449, section 6.2)
+			response.TokenType = "DPoP"
+		}
+		return response, nil
+	case "Refresh", "Offline":
+		refreshToken, err := s.database.GetRefreshTokenByJti(nil, response.Jti)
+		if err != nil {
+			return nil, err
+		}
+		if refreshToken == nil || refreshToken.Revoked || refreshToken.Used {
+			return inactive, nil
+		}
+
+		err = s.database.RefreshTokenLoadCode(nil, refreshToken)
+		if err != nil {
+			return nil, err
+		}
+
+		// refresh tokens are only disclosed to the client they were issued to
+		if refreshToken.Code.ClientId != client.Id {
+			return inactive, nil
+		}
+		response.ClientId = client.ClientIdentifier
+
+		if response.TokenType == "Refresh" {
+			isSessionValid, err := s.isUserSessionValid(settings, refreshToken.SessionIdentifier)
+			if err != nil {
+				return nil, err
+			}
+			if !isSessionValid {
+				return inactive, nil
+			}
+		} else {
+			maxLifetime := tokenInfo.GetTimeClaim("offline_access_max_lifetime")
+			if maxLifetime.IsZero() || time.Now().UTC().After(maxLifetime) {
+				return inactive, nil
+			}
+		}
+		return response, nil
+	default:
+		// id tokens and unknown token types are not meant to be introspected
+		return inactive, nil
+	}
+}
+
+func (s *Server) isUserSessionValid(settings *entities.Settings, sessionIdentifier string) (bool, error) {
+	userSession, err := s.database.GetUserSessionBySessionIdentifier(nil, sessionIdentifier)
+	if err != nil {
+		return false, err
+	}
+	if userSession == nil {
+		return false, nil
+	}
+	return userSession.IsValid(settings.UserSessionIdleTimeoutInSeconds, settings.UserSessionMaxLifetimeInSeconds, nil), nil
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/leodip/goiabada/internal/constants"
+	core_validators "github.com/leodip/goiabada/internal/core/validators"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/lib"
+)
+
+func (s *Server) handleTokenRevocationPost(tokenValidator tokenValidator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		r.ParseForm()
+		clientCredentials, err := s.getClientCredentials(r)
+		if err != nil {
+			s.clientAuthenticationError(w, r, err)
+			return
+		}
+
+		input := core_validators.ValidateTokenRevocationRequestInput{
+			ClientCredentials: *clientCredentials,
+			Token:             r.PostForm.Get("token"),
+			TokenTypeHint:     r.PostForm.Get("token_type_hint"),
+		}
+
+		validateTokenRevocationResult, err := tokenValidator.ValidateTokenRevocationRequest(r.Context(), &input)
+		if err != nil {
+			s.clientAuthenticationError(w, r, err)
+			return
+		}
+
+		refreshToken := validateTokenRevocationResult.RefreshToken
+		if refreshToken != nil {
+
+			refreshTokensToRevoke := []entities.RefreshToken{*refreshToken}
+
+			// revoke_chain is an extension parameter that allows the client to revoke
+			// every refresh token that was derived from the same original refresh token
+			revokeChain := r.PostForm.Get("revoke_chain") == "true"
+			if revokeChain && len(refreshToken.FirstRefreshTokenJti) > 0 {
+				refreshTokensToRevoke, err = s.database.GetRefreshTokensByFirstRefreshTokenJti(nil, refreshToken.FirstRefreshTokenJti)
+				if err != nil {
+					s.jsonError(w, r, err)
+					return
+				}
+			}
+
+			tx, err := s.database.BeginTransaction()
+			if err != nil {
+				s.jsonError(w, r, err)
+				return
+			}
+			defer s.database.RollbackTransaction(tx)
+
+			revokedCount := 0
+			for i := range refreshTokensToRevoke {
+				if refreshTokensToRevoke[i].Revoked {
+					continue
+				}
+				refreshTokensToRevoke[i].Revoked = true
+				err = s.database.UpdateRefreshToken(tx, &refreshTokensToRevoke[i])
+				if err != nil {
+					s.jsonError(w, r, err)
+					return
+				}
+				revokedCount++
+			}
+
+			err = s.database.CommitTransaction(tx)
+			if err != nil {
+				s.jsonError(w, r, err)
+				return
+			}
+
+			lib.LogAudit(r.Context(), constants.AuditRevokedRefreshToken, map[string]interface{}{
+				"clientId":        validateTokenRevocationResult.Client.Id,
+				"refreshTokenJti": refreshToken.RefreshTokenJti,
+				"revokeChain":     revokeChain,
+				"revokedCount":    revokedCount,
+			})
+		}
+
+		// RFC 7009: the server responds with 200 if the token has been revoked
+		// successfully or if the client submitted an invalid token
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Pragma", "no-cache")
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -44,6 +44,18 @@ func (s *Server) handleUserInfoGetPost() http.HandlerFunc {
 			return
 		}
 
+		// a certificate-bound access token can only be used over a TLS connection
+		// authenticated with the same certificate (RFC 8705, section 3)
+		if x5tS256 := jwtToken.GetConfirmationClaim()["x5t#S256"]; len(x5tS256) > 0 {
+			if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 ||
+				lib.GetCertificateThumbprint(r.TLS.PeerCertificates[0]) != x5tS256 {
+				sendJsonError("invalid_token",
+					"The access token is bound to a client certificate, which was not presented in this request.",
+					http.StatusUnauthorized)
+				return
+			}
+		}
+
 		isAuthorized := jwtToken.HasScope(constants.AuthServerResourceIdentifier + ":" + constants.UserinfoPermissionIdentifier)
 
 		if !isAuthorized {
@@ -73,7 +85,7 @@ func (s *Server) handleUserInfoGetPost() http.HandlerFunc {
 		}
 
 		if !user.Enabled {
-			lib.LogAudit(constants.AuditUserDisabled, map[string]interface{}{
+			lib.LogAudit(r.Context(), constants.AuditUserDisabled, map[string]interface{}{
 				"userId": user.Id,
 			})
 
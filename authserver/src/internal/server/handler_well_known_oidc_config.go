@@ -5,7 +5,9 @@ import (
 	"net/http"
 
 	"github.com/leodip/goiabada/internal/common"
+	core_validators "github.com/leodip/goiabada/internal/core/validators"
 	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
 	"github.com/leodip/goiabada/internal/lib"
 )
 
@@ -15,6 +17,8 @@ func (s *Server) handleWellKnownOIDCConfigGet() http.HandlerFunc {
 		Issuer                            string   `json:"issuer"`
 		AuthorizationEndpoint             string   `json:"authorization_endpoint"`
 		TokenEndpoint                     string   `json:"token_endpoint"`
+		RevocationEndpoint                string   `json:"revocation_endpoint"`
+		IntrospectionEndpoint             string   `json:"introspection_endpoint"`
 		UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
 		EndSessionEndpoint                string   `json:"end_session_endpoint"`
 		JWKsURI                           string   `json:"jwks_uri"`
@@ -27,24 +31,62 @@ func (s *Server) handleWellKnownOIDCConfigGet() http.HandlerFunc {
 		ClaimsSupported                   []string `json:"claims_supported"`
 		TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
 		CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+
+		TokenEndpointAuthSigningAlgValuesSupported []string `json:"token_endpoint_auth_signing_alg_values_supported"`
+		RevocationEndpointAuthMethodsSupported     []string `json:"revocation_endpoint_auth_methods_supported"`
+		IntrospectionEndpointAuthMethodsSupported  []string `json:"introspection_endpoint_auth_methods_supported"`
+		TLSClientCertificateBoundAccessTokens      bool     `json:"tls_client_certificate_bound_access_tokens,omitempty"`
+		DPoPSigningAlgValuesSupported              []string `json:"dpop_signing_alg_values_supported"`
+		PushedAuthorizationRequestEndpoint         string   `json:"pushed_authorization_request_endpoint"`
+		RequirePushedAuthorizationRequests         bool     `json:"require_pushed_authorization_requests"`
+		RequestParameterSupported                  bool     `json:"request_parameter_supported"`
+		RequestURIParameterSupported               bool     `json:"request_uri_parameter_supported"`
+		RequestObjectSigningAlgValuesSupported     []string `json:"request_object_signing_alg_values_supported"`
+		DeviceAuthorizationEndpoint                string   `json:"device_authorization_endpoint"`
+		BackchannelLogoutSupported                 bool     `json:"backchannel_logout_supported"`
+		BackchannelLogoutSessionSupported          bool     `json:"backchannel_logout_session_supported"`
+		FrontchannelLogoutSupported                bool     `json:"frontchannel_logout_supported"`
+		FrontchannelLogoutSessionSupported         bool     `json:"frontchannel_logout_session_supported"`
+		CheckSessionIframe                         string   `json:"check_session_iframe"`
+		RegistrationEndpoint                       string   `json:"registration_endpoint,omitempty"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
 
+		idTokenSigningAlgorithms, err := s.getIdTokenSigningAlgorithms()
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		clientAuthMethods := []string{
+			enums.TokenEndpointAuthMethodClientSecretBasic.String(),
+			enums.TokenEndpointAuthMethodClientSecretPost.String(),
+			enums.TokenEndpointAuthMethodPrivateKeyJwt.String(),
+		}
+		if lib.IsMTLSEnabled() {
+			clientAuthMethods = append(clientAuthMethods,
+				enums.TokenEndpointAuthMethodTLSClientAuth.String(),
+				enums.TokenEndpointAuthMethodSelfSignedTLSClientAuth.String(),
+			)
+		}
+
 		config := oidcConfig{
 			Issuer:                           settings.Issuer,
 			AuthorizationEndpoint:            lib.GetBaseUrl() + "/auth/authorize",
 			TokenEndpoint:                    lib.GetBaseUrl() + "/auth/token",
+			RevocationEndpoint:               lib.GetBaseUrl() + "/auth/revoke",
+			IntrospectionEndpoint:            lib.GetBaseUrl() + "/auth/introspect",
 			UserInfoEndpoint:                 lib.GetBaseUrl() + "/userinfo",
 			EndSessionEndpoint:               lib.GetBaseUrl() + "/auth/logout",
 			JWKsURI:                          lib.GetBaseUrl() + "/certs",
-			GrantTypesSupported:              []string{"authorization_code", "refresh_token", "client_credentials"},
+			GrantTypesSupported:              []string{"authorization_code", "refresh_token", "client_credentials", core_validators.DeviceCodeGrantType},
 			ResponseTypesSupported:           []string{"code"},
-			ACRValuesSupported:               []string{"urn:goiabada:pwd", "urn:goiabada:pwd:otp_ifpossible", "urn:goiabada:pwd:otp_mandatory"},
+			ACRValuesSupported:               []string{"urn:goiabada:pwd", "urn:goiabada:pwd:otp_ifpossible", "urn:goiabada:pwd:otp_mandatory", "urn:goiabada:passkey"},
 			SubjectTypesSupported:            []string{"public"},
-			IdTokenSigningAlgValuesSupported: []string{"RS256"},
+			IdTokenSigningAlgValuesSupported: idTokenSigningAlgorithms,
 			ScopesSupported: []string{
 				"openid", "profile", "email", "address", "phone", "groups", "attributes", "offline_access"},
 			ClaimsSupported: []string{
@@ -57,8 +99,29 @@ func (s *Server) handleWellKnownOIDCConfigGet() http.HandlerFunc {
 				"groups",     // groups
 				"attributes", // attributes
 			},
-			TokenEndpointAuthMethodsSupported: []string{"client_secret_post"},
+			TokenEndpointAuthMethodsSupported: clientAuthMethods,
 			CodeChallengeMethodsSupported:     []string{"S256"},
+
+			TokenEndpointAuthSigningAlgValuesSupported: core_validators.ClientAssertionSigningAlgorithms(),
+			RevocationEndpointAuthMethodsSupported:     clientAuthMethods,
+			IntrospectionEndpointAuthMethodsSupported:  clientAuthMethods,
+			TLSClientCertificateBoundAccessTokens:      lib.IsMTLSEnabled(),
+			DPoPSigningAlgValuesSupported:              core_validators.ClientAssertionSigningAlgorithms(),
+			PushedAuthorizationRequestEndpoint:         lib.GetBaseUrl() + "/auth/par",
+			RequirePushedAuthorizationRequests:         false,
+			RequestParameterSupported:                  true,
+			RequestURIParameterSupported:               false,
+			RequestObjectSigningAlgValuesSupported:     core_validators.RequestObjectSigningAlgorithms(),
+			DeviceAuthorizationEndpoint:                lib.GetBaseUrl() + "/auth/device_authorization",
+			BackchannelLogoutSupported:                 true,
+			BackchannelLogoutSessionSupported:          true,
+			FrontchannelLogoutSupported:                true,
+			FrontchannelLogoutSessionSupported:         true,
+			CheckSessionIframe:                         lib.GetBaseUrl() + "/auth/check_session_iframe",
+		}
+
+		if settings.ClientRegistrationMode != enums.ClientRegistrationModeDisabled.String() {
+			config.RegistrationEndpoint = lib.GetBaseUrl() + "/connect/register"
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -23,6 +23,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/leodip/goiabada/internal/common"
 	"github.com/leodip/goiabada/internal/constants"
+	core_validators "github.com/leodip/goiabada/internal/core/validators"
 	"github.com/leodip/goiabada/internal/customerrors"
 	"github.com/leodip/goiabada/internal/dtos"
 	"github.com/leodip/goiabada/internal/entities"
@@ -61,6 +62,7 @@ func (s *Server) includeLeftPanelImage(templateName string) bool {
 		"/forgot_password.html",
 		"/reset_password.html",
 		"/consent.html",
+		"/device.html",
 		"/account_register.html",
 		"/account_register_activation.html",
 		"/account_register_activation_result.html",
@@ -176,6 +178,22 @@ func (s *Server) internalServerError(w http.ResponseWriter, r *http.Request, err
 }
 
 func (s *Server) jsonError(w http.ResponseWriter, r *http.Request, err error) {
+	s.jsonErrorWithStatus(w, r, err, http.StatusBadRequest)
+}
+
+// clientAuthenticationError is the jsonError of the endpoints that authenticate clients. A client
+// that fails to authenticate with HTTP Basic gets a 401 response (RFC 6749, section 5.2)
+func (s *Server) clientAuthenticationError(w http.ResponseWriter, r *http.Request, err error) {
+	valError, ok := err.(*customerrors.ValidationError)
+	if _, _, isBasicAuth := r.BasicAuth(); ok && isBasicAuth && valError.Code == "invalid_client" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="goiabada"`)
+		s.jsonErrorWithStatus(w, r, err, http.StatusUnauthorized)
+		return
+	}
+	s.jsonError(w, r, err)
+}
+
+func (s *Server) jsonErrorWithStatus(w http.ResponseWriter, r *http.Request, err error, validationErrorStatus int) {
 
 	w.Header().Set("Content-Type", "application/json")
 
@@ -187,7 +205,7 @@ func (s *Server) jsonError(w http.ResponseWriter, r *http.Request, err error) {
 	valError, ok := err.(*customerrors.ValidationError)
 	if ok {
 		// validation error
-		w.WriteHeader(http.StatusBadRequest)
+		w.WriteHeader(validationErrorStatus)
 		errorStr = valError.Code
 		errorDescriptionStr = valError.Description
 	} else {
@@ -205,6 +223,49 @@ func (s *Server) jsonError(w http.ResponseWriter, r *http.Request, err error) {
 	json.NewEncoder(w).Encode(values)
 }
 
+// getClientCredentials reads the client credentials from the request. The client secret can be
+// sent in the request body (client_secret_post) or with HTTP Basic (client_secret_basic), and the
+// client assertion in the request body (private_key_jwt). Only one method can be used at a time.
+// The certificate chain presented during the TLS handshake, if any, is included as well
+func (s *Server) getClientCredentials(r *http.Request) (*core_validators.ClientCredentials, error) {
+
+	credentials := &core_validators.ClientCredentials{
+		ClientId:            r.PostForm.Get("client_id"),
+		ClientSecret:        r.PostForm.Get("client_secret"),
+		ClientAssertionType: r.PostForm.Get("client_assertion_type"),
+		ClientAssertion:     r.PostForm.Get("client_assertion"),
+	}
+	if r.TLS != nil {
+		credentials.ClientCertificates = r.TLS.PeerCertificates
+	}
+
+	basicClientId, basicClientSecret, isBasicAuth := r.BasicAuth()
+	if !isBasicAuth {
+		if len(credentials.ClientSecret) > 0 && len(credentials.ClientAssertion) > 0 {
+			return nil, customerrors.NewValidationError("invalid_request", "The client must use only one authentication method, but both client_secret and client_assertion were provided.")
+		}
+		return credentials, nil
+	}
+
+	if len(credentials.ClientSecret) > 0 || len(credentials.ClientAssertion) > 0 {
+		return nil, customerrors.NewValidationError("invalid_request", "The client must use only one authentication method, but the Authorization header was provided along with credentials in the request body.")
+	}
+
+	// the credentials are form-urlencoded before being base64 encoded (RFC 6749, section 2.3.1)
+	basicClientId, errClientId := url.QueryUnescape(basicClientId)
+	basicClientSecret, errClientSecret := url.QueryUnescape(basicClientSecret)
+	if errClientId != nil || errClientSecret != nil {
+		return nil, customerrors.NewValidationError("invalid_client", "The client credentials in the Authorization header are not properly encoded.")
+	}
+
+	if len(credentials.ClientId) > 0 && credentials.ClientId != basicClientId {
+		return nil, customerrors.NewValidationError("invalid_request", "The client_id parameter does not match the client identifier in the Authorization header.")
+	}
+	credentials.ClientId = basicClientId
+	credentials.ClientSecret = basicClientSecret
+	return credentials, nil
+}
+
 func (s *Server) getAuthContext(r *http.Request) (*dtos.AuthContext, error) {
 	sess, err := s.sessionStore.Get(r, common.SessionName)
 	if err != nil {
@@ -265,7 +326,7 @@ func (s *Server) isAuthorizedToAccessResource(jwtInfo dtos.JwtInfo, scopesAnyOf
 	if jwtInfo.AccessToken != nil && jwtInfo.AccessToken.SignatureIsValid {
 		acrLevel := jwtInfo.AccessToken.GetAcrLevel()
 		if acrLevel != nil &&
-			(*acrLevel == enums.AcrLevel2 || *acrLevel == enums.AcrLevel3) {
+			(*acrLevel == enums.AcrLevel2 || *acrLevel == enums.AcrLevel3 || *acrLevel == enums.AcrLevelPasskey) {
 			for _, scope := range scopesAnyOf {
 				if jwtInfo.AccessToken.HasScope(scope) {
 					return true
@@ -408,7 +469,7 @@ func (s *Server) startNewUserSession(w http.ResponseWriter, r *http.Request,
 		return nil, err
 	}
 
-	lib.LogAudit(constants.AuditStartedNewUserSesson, map[string]interface{}{
+	lib.LogAudit(r.Context(), constants.AuditStartedNewUserSesson, map[string]interface{}{
 		"userId":   userId,
 		"clientId": clientId,
 	})
@@ -500,7 +561,7 @@ func (s *Server) bumpUserSession(w http.ResponseWriter, r *http.Request, session
 			return nil, err
 		}
 
-		lib.LogAudit(constants.AuditBumpedUserSession, map[string]interface{}{
+		lib.LogAudit(r.Context(), constants.AuditBumpedUserSession, map[string]interface{}{
 			"userId":   userSession.UserId,
 			"clientId": clientId,
 		})
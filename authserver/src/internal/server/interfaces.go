@@ -19,7 +19,7 @@ type otpSecretGenerator interface {
 
 type tokenIssuer interface {
 	GenerateTokenResponseForAuthCode(ctx context.Context, input *core_token.GenerateTokenResponseForAuthCodeInput) (*dtos.TokenResponse, error)
-	GenerateTokenResponseForClientCred(ctx context.Context, client *entities.Client, scope string) (*dtos.TokenResponse, error)
+	GenerateTokenResponseForClientCred(ctx context.Context, client *entities.Client, scope string, confirmation *core_token.TokenConfirmation) (*dtos.TokenResponse, error)
 	GenerateTokenResponseForRefresh(ctx context.Context, input *core_token.GenerateTokenForRefreshInput) (*dtos.TokenResponse, error)
 }
 
@@ -27,6 +27,7 @@ type authorizeValidator interface {
 	ValidateScopes(ctx context.Context, scope string) error
 	ValidateClientAndRedirectURI(ctx context.Context, input *core_validators.ValidateClientAndRedirectURIInput) error
 	ValidateRequest(ctx context.Context, input *core_validators.ValidateRequestInput) error
+	ValidateRequestObject(ctx context.Context, input *core_validators.ValidateRequestObjectInput) (*core_validators.ValidateRequestObjectResult, error)
 }
 
 type codeIssuer interface {
@@ -38,10 +39,22 @@ type loginManager interface {
 
 	MustPerformOTPAuth(ctx context.Context, client *entities.Client, userSession *entities.UserSession,
 		targetAcrLevel enums.AcrLevel) bool
+
+	MustPerformPasskeyAuth(ctx context.Context, client *entities.Client, userSession *entities.UserSession,
+		targetAcrLevel enums.AcrLevel) bool
 }
 
 type tokenValidator interface {
 	ValidateTokenRequest(ctx context.Context, input *core_validators.ValidateTokenRequestInput) (*core_validators.ValidateTokenRequestResult, error)
+	ValidateTokenRevocationRequest(ctx context.Context, input *core_validators.ValidateTokenRevocationRequestInput) (*core_validators.ValidateTokenRevocationRequestResult, error)
+	ValidateTokenIntrospectionRequest(ctx context.Context, input *core_validators.ValidateTokenIntrospectionRequestInput) (*core_validators.ValidateTokenIntrospectionRequestResult, error)
+	ValidatePushedAuthorizationRequest(ctx context.Context, input *core_validators.ValidatePushedAuthorizationRequestInput) (*core_validators.ValidatePushedAuthorizationRequestResult, error)
+	ValidateDeviceAuthorizationRequest(ctx context.Context, input *core_validators.ValidateDeviceAuthorizationRequestInput) (*core_validators.ValidateDeviceAuthorizationRequestResult, error)
+}
+
+type dpopValidator interface {
+	ValidateDPoPProof(ctx context.Context, input *core_validators.ValidateDPoPProofInput) (*core_validators.ValidateDPoPProofResult, error)
+	GenerateNonce(settings *entities.Settings) string
 }
 
 type profileValidator interface {
@@ -78,6 +91,10 @@ type identifierValidator interface {
 	ValidateIdentifier(identifier string, enforceMinLength bool) error
 }
 
+type clientRegistrationValidator interface {
+	ValidateClientMetadata(ctx context.Context, metadata *dtos.ClientMetadata) error
+}
+
 type inputSanitizer interface {
 	Sanitize(str string) string
 }
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/leodip/goiabada/internal/lib"
+)
+
+// MiddlewareAuditRequestInfo adds the request details that are stored along with
+// the audit events (ip address, request id and user agent) to the request context
+func MiddlewareAuditRequestInfo() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ipWithoutPort, _, _ := net.SplitHostPort(r.RemoteAddr)
+			if len(ipWithoutPort) == 0 {
+				ipWithoutPort = r.RemoteAddr
+			}
+
+			userAgent := r.UserAgent()
+			const maxUserAgentLength = 512
+			if len(userAgent) > maxUserAgentLength {
+				userAgent = userAgent[:maxUserAgentLength]
+			}
+
+			ctx := lib.WithAuditRequestInfo(r.Context(), lib.AuditRequestInfo{
+				IpAddress: ipWithoutPort,
+				RequestId: middleware.GetReqID(r.Context()),
+				UserAgent: userAgent,
+			})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
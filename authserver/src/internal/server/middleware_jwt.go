@@ -2,14 +2,19 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/gorilla/sessions"
 	"net/http"
+	"strings"
 
 	"github.com/leodip/goiabada/internal/common"
 	"github.com/leodip/goiabada/internal/constants"
 	core_token "github.com/leodip/goiabada/internal/core/token"
+	core_validators "github.com/leodip/goiabada/internal/core/validators"
+	"github.com/leodip/goiabada/internal/customerrors"
 	"github.com/leodip/goiabada/internal/dtos"
+	"github.com/leodip/goiabada/internal/entities"
 	"github.com/leodip/goiabada/internal/lib"
 )
 
@@ -42,28 +47,88 @@ func MiddlewareJwtSessionToContext(next http.Handler, sessionStore sessions.Stor
 }
 
 func MiddlewareJwtAuthorizationHeaderToContext(next http.Handler, sessionStore sessions.Store,
-	tokenParser *core_token.TokenParser) http.HandlerFunc {
+	tokenParser *core_token.TokenParser, dpopValidator *core_validators.DPoPValidator) http.HandlerFunc {
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
 		const BEARER_SCHEMA = "Bearer "
+		const DPOP_SCHEMA = "DPoP "
 		authHeader := r.Header.Get("Authorization")
-		if len(authHeader) < len(BEARER_SCHEMA) {
+		isDPoP := len(authHeader) >= len(DPOP_SCHEMA) && strings.EqualFold(authHeader[:len(DPOP_SCHEMA)], DPOP_SCHEMA)
+		if !isDPoP && len(authHeader) < len(BEARER_SCHEMA) {
 			next.ServeHTTP(w, r.WithContext(ctx))
 			return
 		}
 		tokenStr := authHeader[len(BEARER_SCHEMA):]
+		if isDPoP {
+			tokenStr = authHeader[len(DPOP_SCHEMA):]
+		}
 
 		token, err := tokenParser.ParseToken(ctx, tokenStr, true)
-		if err == nil {
-			ctx = context.WithValue(ctx, common.ContextKeyJwtInfo, *token)
+		if err != nil {
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		// a token bound to a DPoP key can't be used as a bearer token, and must be sent
+		// along with a proof signed with the same key (RFC 9449, section 7)
+		jkt := token.GetConfirmationClaim()["jkt"]
+		isBoundToDPoPKey := len(jkt) > 0
+		if isBoundToDPoPKey != isDPoP {
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+		if isDPoP {
+			dpopProofs := r.Header.Values("DPoP")
+			if len(dpopProofs) != 1 {
+				writeDPoPError(w, r, dpopValidator, "invalid_dpop_proof", "Please provide exactly one DPoP proof in the DPoP header.")
+				return
+			}
+			dpopResult, err := dpopValidator.ValidateDPoPProof(ctx, &core_validators.ValidateDPoPProofInput{
+				Proof:            dpopProofs[0],
+				HttpMethod:       r.Method,
+				HttpURL:          lib.GetBaseUrl() + r.URL.Path,
+				AccessToken:      tokenStr,
+				ClientIdentifier: token.GetStringClaim("client_id"),
+			})
+			if err != nil {
+				if valError, ok := err.(*customerrors.ValidationError); ok {
+					writeDPoPError(w, r, dpopValidator, valError.Code, valError.Description)
+				} else {
+					http.Error(w, fmt.Sprintf("unable to validate the DPoP proof in JwtAuthorizationHeaderToContext middleware: %v", err.Error()), http.StatusInternalServerError)
+				}
+				return
+			}
+			if dpopResult.Jkt != jkt {
+				writeDPoPError(w, r, dpopValidator, "invalid_dpop_proof", "The DPoP proof is not signed with the key the access token is bound to.")
+				return
+			}
 		}
 
+		ctx = context.WithValue(ctx, common.ContextKeyJwtInfo, *token)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// writeDPoPError rejects a request to a protected resource with a DPoP error (RFC 9449, section 7.1)
+func writeDPoPError(w http.ResponseWriter, r *http.Request, dpopValidator *core_validators.DPoPValidator,
+	code string, description string) {
+
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`DPoP error="%v", error_description="%v", algs="%v"`,
+		code, strings.ReplaceAll(description, `"`, `'`), strings.Join(core_validators.ClientAssertionSigningAlgorithms(), " ")))
+	if code == "use_dpop_nonce" {
+		settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
+		w.Header().Set("DPoP-Nonce", dpopValidator.GenerateNonce(settings))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
 func MiddlewareRequiresScope(next http.Handler, server *Server, clientIdentifier string,
 	scopesAnyOf []string) http.HandlerFunc {
 
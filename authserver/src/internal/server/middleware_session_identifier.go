@@ -10,6 +10,7 @@ import (
 	"github.com/gorilla/sessions"
 	"github.com/leodip/goiabada/internal/common"
 	"github.com/leodip/goiabada/internal/data"
+	"github.com/leodip/goiabada/internal/lib"
 )
 
 func MiddlewareSessionIdentifier(sessionStore sessions.Store, database data.Database) func(next http.Handler) http.Handler {
@@ -27,6 +28,7 @@ func MiddlewareSessionIdentifier(sessionStore sessions.Store, database data.Data
 				return
 			}
 
+			browserState := ""
 			if sess.Values[common.SessionKeySessionIdentifier] != nil {
 				sessionIdentifier := sess.Values[common.SessionKeySessionIdentifier].(string)
 
@@ -47,11 +49,54 @@ func MiddlewareSessionIdentifier(sessionStore sessions.Store, database data.Data
 					}
 				} else {
 					ctx = context.WithValue(ctx, common.ContextKeySessionIdentifier, sessionIdentifier)
+
+					browserState, err = lib.GetBrowserState(sessionIdentifier)
+					if err != nil {
+						slog.Error(fmt.Sprintf("unable to get the browser state: %+v", err), "request-id", requestId)
+						http.Error(w, errorMsg, http.StatusInternalServerError)
+						return
+					}
 				}
 			}
 
+			// keep the browser state cookie in sync with the user session
+			cookie, err := r.Cookie(common.BrowserStateCookieName)
+			if err != nil {
+				cookie = nil
+			}
+			if len(browserState) > 0 && (cookie == nil || cookie.Value != browserState) {
+				setBrowserStateCookie(w, browserState)
+			} else if len(browserState) == 0 && cookie != nil {
+				clearBrowserStateCookie(w)
+			}
+
 			next.ServeHTTP(w, r.WithContext(ctx))
 		}
 		return http.HandlerFunc(fn)
 	}
 }
+
+func setBrowserStateCookie(w http.ResponseWriter, browserState string) {
+	http.SetCookie(w, newBrowserStateCookie(browserState, 0))
+}
+
+func clearBrowserStateCookie(w http.ResponseWriter) {
+	http.SetCookie(w, newBrowserStateCookie("", -1))
+}
+
+func newBrowserStateCookie(value string, maxAge int) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     common.BrowserStateCookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: false, // the check_session_iframe needs to read it
+		SameSite: http.SameSiteLaxMode,
+	}
+	if lib.IsHttpsEnabled() {
+		// the check_session_iframe is loaded by the relying parties, from another site
+		cookie.Secure = true
+		cookie.SameSite = http.SameSiteNoneMode
+	}
+	return cookie
+}
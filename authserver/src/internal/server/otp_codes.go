@@ -0,0 +1,196 @@
+package server
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/leodip/goiabada/internal/common"
+	"github.com/leodip/goiabada/internal/constants"
+	core_senders "github.com/leodip/goiabada/internal/core/senders"
+	"github.com/leodip/goiabada/internal/dtos"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/enums"
+	"github.com/leodip/goiabada/internal/lib"
+)
+
+// one-time codes delivered to the user (as opposed to TOTP codes from an authenticator app)
+const otpCodeLength = 6
+const otpCodeExpiration = 5 * time.Minute
+const otpCodeResendWait = 60 * time.Second
+const otpCodeMaxAttempts = 5
+
+// hasPendingOTPCode returns true if a code was sent to the user and has not expired yet
+func hasPendingOTPCode(user *entities.User, now time.Time) bool {
+	return len(user.OTPCodeEncrypted) > 0 && user.OTPCodeIssuedAt.Valid &&
+		now.Before(user.OTPCodeIssuedAt.Time.Add(otpCodeExpiration))
+}
+
+// getOTPCodeWaitInSeconds returns how many seconds the user must wait before a new code can be sent
+func getOTPCodeWaitInSeconds(user *entities.User, now time.Time) int {
+	if len(user.OTPCodeEncrypted) == 0 || !user.OTPCodeIssuedAt.Valid {
+		return 0
+	}
+	remainingTime := int(user.OTPCodeIssuedAt.Time.Add(otpCodeResendWait).Sub(now).Seconds())
+	if remainingTime < 0 {
+		return 0
+	}
+	return remainingTime
+}
+
+// sendOTPCode generates a new code, stores it encrypted and delivers it using the given OTP method
+func (s *Server) sendOTPCode(r *http.Request, user *entities.User, settings *entities.Settings,
+	otpMethod enums.OTPMethod, emailSender emailSender, smsSender smsSender) error {
+
+	otpCode := lib.GenerateRandomNumbers(otpCodeLength)
+	if len(otpCode) != otpCodeLength {
+		return errors.WithStack(errors.New("unable to generate the OTP code"))
+	}
+
+	otpCodeEncrypted, err := lib.EncryptText(otpCode, settings.AESEncryptionKey)
+	if err != nil {
+		return err
+	}
+
+	switch otpMethod {
+	case enums.OTPMethodEmail:
+		if !settings.SMTPEnabled {
+			return errors.WithStack(errors.New("SMTP is not enabled"))
+		}
+
+		bind := map[string]interface{}{
+			"name":              user.GetFullName(),
+			"code":              otpCode,
+			"expirationMinutes": int(otpCodeExpiration.Minutes()),
+		}
+		buf, err := s.renderTemplateToBuffer(r, "/layouts/email_layout.html", "/emails/email_otp_code.html", bind)
+		if err != nil {
+			return err
+		}
+
+		input := &core_senders.SendEmailInput{
+			To:       user.Email,
+			Subject:  "Your sign-in code",
+			HtmlBody: buf.String(),
+		}
+		err = emailSender.SendEmail(r.Context(), input)
+		if err != nil {
+			return err
+		}
+	case enums.OTPMethodSMS:
+		if len(settings.SMSProvider) == 0 {
+			return errors.WithStack(errors.New("SMS is not enabled"))
+		}
+
+		input := &core_senders.SendSMSInput{
+			To: user.PhoneNumber,
+			Body: fmt.Sprintf("Your %v sign-in code is %v. It expires in %v minutes.",
+				settings.AppName, otpCode, int(otpCodeExpiration.Minutes())),
+		}
+		err = smsSender.SendSMS(r.Context(), input)
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.WithStack(errors.New("the OTP method " + otpMethod.String() + " does not deliver codes"))
+	}
+
+	err = s.database.SetUserOTPCode(nil, user.Id, otpCodeEncrypted, sql.NullTime{Time: time.Now().UTC(), Valid: true})
+	if err != nil {
+		return err
+	}
+
+	lib.LogAudit(r.Context(), constants.AuditSentOTPCode, map[string]interface{}{
+		"userId":    user.Id,
+		"otpMethod": otpMethod.String(),
+	})
+	return nil
+}
+
+// verifyOTPCode checks the code against the pending code of the user. A code that was
+// accepted is removed, so it can't be used again. After otpCodeMaxAttempts wrong attempts
+// the code is removed as well, and the user must request a new one
+func (s *Server) verifyOTPCode(user *entities.User, settings *entities.Settings, otpCode string) (bool, error) {
+	if !hasPendingOTPCode(user, time.Now().UTC()) {
+		return false, nil
+	}
+
+	// the attempt is counted before comparing, so concurrent guesses can't exceed the limit
+	attemptAllowed, err := s.database.IncrementUserOTPCodeAttempts(nil, user.Id, otpCodeMaxAttempts)
+	if err != nil {
+		return false, err
+	}
+	if !attemptAllowed {
+		_, err = s.database.ClearUserOTPCode(nil, user.Id)
+		if err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+	user.OTPCodeAttempts++
+
+	expectedOtpCode, err := lib.DecryptText(user.OTPCodeEncrypted, settings.AESEncryptionKey)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to decrypt the OTP code")
+	}
+
+	codeMatches := subtle.ConstantTimeCompare([]byte(expectedOtpCode), []byte(otpCode)) == 1
+	if !codeMatches && user.OTPCodeAttempts < otpCodeMaxAttempts {
+		return false, nil
+	}
+
+	// a concurrent request may have used the code first
+	cleared, err := s.database.ClearUserOTPCode(nil, user.Id)
+	if err != nil {
+		return false, err
+	}
+	user.OTPCodeEncrypted = nil
+	user.OTPCodeIssuedAt = sql.NullTime{Valid: false}
+	user.OTPCodeAttempts = 0
+
+	return codeMatches && cleared, nil
+}
+
+// getAuthOtpMethod returns the OTP method of the current authentication: SMS if the user
+// picked it on the OTP page, otherwise the method the user is enrolled with
+func getAuthOtpMethod(authContext *dtos.AuthContext, user *entities.User) enums.OTPMethod {
+	if authContext.OTPMethod == enums.OTPMethodSMS.String() {
+		return enums.OTPMethodSMS
+	}
+	return user.GetOTPMethod()
+}
+
+func getTargetAcrLevel(authContext *dtos.AuthContext, client *entities.Client) enums.AcrLevel {
+	requestedAcrValues := authContext.ParseRequestedAcrValues()
+	if len(requestedAcrValues) > 0 {
+		return requestedAcrValues[0]
+	}
+	return client.DefaultAcrLevel
+}
+
+// isSMSOTPAvailable returns true if the user can receive the OTP code by SMS in this authentication.
+// The phone number must be verified, and the client must allow SMS for the target ACR level
+func isSMSOTPAvailable(r *http.Request, authContext *dtos.AuthContext, user *entities.User,
+	client *entities.Client) bool {
+
+	settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
+	if len(settings.SMSProvider) == 0 || !user.PhoneNumberVerified || len(user.PhoneNumber) == 0 {
+		return false
+	}
+	return client.IsSMSOTPAllowed(getTargetAcrLevel(authContext, client))
+}
+
+// maskPhoneNumber hides all but the last digits of the phone number
+func maskPhoneNumber(phoneNumber string) string {
+	const visibleDigits = 4
+	digits := strings.ReplaceAll(phoneNumber, " ", "")
+	if len(digits) <= visibleDigits {
+		return digits
+	}
+	return strings.Repeat("*", len(digits)-visibleDigits) + digits[len(digits)-visibleDigits:]
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/lib"
+)
+
+const recoveryCodesCount = 10
+
+// generateRecoveryCodes replaces the recovery codes of the user with a new set. Only the
+// hashes are stored, so the plain codes returned here can be shown to the user just once
+func (s *Server) generateRecoveryCodes(user *entities.User) ([]string, error) {
+	// the old codes are only replaced if all the new ones are stored
+	tx, err := s.database.BeginTransaction()
+	if err != nil {
+		return nil, err
+	}
+	defer s.database.RollbackTransaction(tx)
+
+	err = s.database.DeleteUserRecoveryCodesByUserId(tx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodesCount)
+	for i := 0; i < recoveryCodesCount; i++ {
+		code := lib.GenerateRecoveryCode()
+		if len(code) == 0 {
+			return nil, errors.WithStack(errors.New("unable to generate a recovery code"))
+		}
+		codeHash, err := lib.HashString(lib.NormalizeRecoveryCode(code))
+		if err != nil {
+			return nil, err
+		}
+		err = s.database.CreateUserRecoveryCode(tx, &entities.UserRecoveryCode{
+			UserId:   user.Id,
+			CodeHash: codeHash,
+		})
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+
+	err = s.database.CommitTransaction(tx)
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// useRecoveryCode consumes the recovery code if it belongs to the user. It returns whether the
+// code was accepted and how many codes the user has left
+func (s *Server) useRecoveryCode(user *entities.User, code string) (bool, int, error) {
+	userRecoveryCodes, err := s.database.GetUserRecoveryCodesByUserId(nil, user.Id)
+	if err != nil {
+		return false, 0, err
+	}
+
+	normalizedCode := lib.NormalizeRecoveryCode(code)
+	if len(normalizedCode) == 0 {
+		return false, len(userRecoveryCodes), nil
+	}
+
+	for _, userRecoveryCode := range userRecoveryCodes {
+		if lib.VerifyStringHash(userRecoveryCode.CodeHash, normalizedCode) {
+			// a concurrent request may have consumed the same code first
+			deleted, err := s.database.DeleteUserRecoveryCode(nil, userRecoveryCode.Id)
+			if err != nil {
+				return false, 0, err
+			}
+			if !deleted {
+				return false, len(userRecoveryCodes) - 1, nil
+			}
+			return true, len(userRecoveryCodes) - 1, nil
+		}
+	}
+	return false, len(userRecoveryCodes), nil
+}
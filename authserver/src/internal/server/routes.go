@@ -17,21 +17,21 @@ import (
 func (s *Server) initRoutes() {
 
 	authorizeValidator := core_validators.NewAuthorizeValidator(s.database)
-	tokenParser := core_token.NewTokenParser(s.database)
 	permissionChecker := core.NewPermissionChecker(s.database)
-	tokenValidator := core_validators.NewTokenValidator(s.database, tokenParser, permissionChecker)
+	tokenValidator := core_validators.NewTokenValidator(s.database, s.tokenParser, permissionChecker)
 	profileValidator := core_validators.NewProfileValidator(s.database)
 	emailValidator := core_validators.NewEmailValidator(s.database)
 	addressValidator := core_validators.NewAddressValidator(s.database)
 	phoneValidator := core_validators.NewPhoneValidator(s.database)
 	passwordValidator := core_validators.NewPasswordValidator()
 	identifierValidator := core_validators.NewIdentifierValidator(s.database)
+	clientRegistrationValidator := core_validators.NewClientRegistrationValidator()
 	inputSanitizer := core.NewInputSanitizer()
 
 	codeIssuer := core_authorize.NewCodeIssuer(s.database)
 	loginManager := core_authorize.NewLoginManager(codeIssuer)
 	otpSecretGenerator := core.NewOTPSecretGenerator()
-	tokenIssuer := core_token.NewTokenIssuer(s.database, tokenParser)
+	tokenIssuer := core_token.NewTokenIssuer(s.database, s.tokenParser)
 	emailSender := core_senders.NewEmailSender(s.database)
 	smsSender := core_senders.NewSMSSender(s.database)
 	userCreator := core.NewUserCreator(s.database)
@@ -48,20 +48,40 @@ func (s *Server) initRoutes() {
 	s.router.With(s.jwtAuthorizationHeaderToContext).Get("/userinfo", s.handleUserInfoGetPost())
 	s.router.With(s.jwtAuthorizationHeaderToContext).Post("/userinfo", s.handleUserInfoGetPost())
 	s.router.Get("/health", s.handleHealthCheckGet())
+	s.router.Route("/connect/register", func(r chi.Router) {
+		r.Post("/", s.handleClientRegistrationPost(clientRegistrationValidator, inputSanitizer))
+		r.Get("/{clientIdentifier}", s.handleClientConfigurationGet())
+		r.Put("/{clientIdentifier}", s.handleClientConfigurationPut(clientRegistrationValidator, inputSanitizer))
+		r.Delete("/{clientIdentifier}", s.handleClientConfigurationDelete())
+	})
 	s.router.Get("/test", s.handleRequestTestGet())
-	s.router.Post("/login", s.handleAuthPwdPost(authorizeValidator, loginManager))
+	s.router.Post("/login", s.handleAuthPwdPost(authorizeValidator, loginManager, emailSender))
+	s.router.With(s.jwtSessionToContext).Get("/device", s.handleDeviceGet())
+	s.router.With(s.jwtSessionToContext).Post("/device", s.handleDevicePost(loginManager))
 
 	s.router.With(s.jwtSessionToContext).Route("/auth", func(r chi.Router) {
 		r.Get("/authorize", s.handleAuthorizeGet(authorizeValidator, codeIssuer, loginManager))
 		r.Get("/pwd", s.handleAuthPwdGet())
-		r.Post("/pwd", s.handleAuthPwdPost(authorizeValidator, loginManager))
+		r.Post("/pwd", s.handleAuthPwdPost(authorizeValidator, loginManager, emailSender))
 		r.Get("/otp", s.handleAuthOtpGet(otpSecretGenerator))
-		r.Post("/otp", s.handleAuthOtpPost())
+		r.Post("/otp", s.handleAuthOtpPost(emailSender))
+		r.Post("/otp/send", s.handleAuthOtpSendPost(emailSender, smsSender))
+		r.Post("/otp/sms", s.handleAuthOtpSmsPost(emailSender, smsSender))
+		r.Get("/passkey", s.handleAuthPasskeyGet())
+		r.Post("/passkey/begin", s.handleAuthPasskeyBeginPost())
+		r.Post("/passkey/finish", s.handleAuthPasskeyFinishPost(emailSender))
+		r.Post("/passkey/login/begin", s.handleAuthPasskeyLoginBeginPost())
+		r.Post("/passkey/login/finish", s.handleAuthPasskeyLoginFinishPost(emailSender))
 		r.Get("/consent", s.handleConsentGet(codeIssuer, permissionChecker))
 		r.Post("/consent", s.handleConsentPost(codeIssuer))
-		r.Post("/token", s.handleTokenPost(tokenIssuer, tokenValidator))
+		r.Post("/token", s.handleTokenPost(tokenIssuer, tokenValidator, s.dpopValidator))
+		r.Post("/revoke", s.handleTokenRevocationPost(tokenValidator))
+		r.Post("/introspect", s.handleTokenIntrospectionPost(tokenValidator))
+		r.Post("/par", s.handlePushedAuthorizationRequestPost(tokenValidator, authorizeValidator))
+		r.Post("/device_authorization", s.handleDeviceAuthorizationPost(tokenValidator, authorizeValidator))
 		r.Post("/callback", s.handleAuthCallbackPost(tokenIssuer, tokenValidator))
 		r.Get("/logout", s.handleAccountLogoutGet())
+		r.Get("/check_session_iframe", s.handleCheckSessionIframeGet())
 		r.Post("/logout", s.handleAccountLogoutPost())
 		r.Post("/logout", s.handleAccountLogoutPost())
 	})
@@ -86,13 +106,18 @@ func (s *Server) initRoutes() {
 		r.With(s.jwtSessionToContext).With(s.requiresAccountScope).Post("/change-password", s.handleAccountChangePasswordPost(passwordValidator))
 		r.With(s.jwtSessionToContext).With(s.requiresAccountScope).Get("/otp", s.handleAccountOtpGet(otpSecretGenerator))
 		r.With(s.jwtSessionToContext).With(s.requiresAccountScope).Post("/otp", s.handleAccountOtpPost())
+		r.With(s.jwtSessionToContext).With(s.requiresAccountScope).Post("/otp/recovery-codes", s.handleAccountOtpRecoveryCodesPost())
+		r.With(s.jwtSessionToContext).With(s.requiresAccountScope).Get("/passkeys", s.handleAccountPasskeysGet())
+		r.With(s.jwtSessionToContext).With(s.requiresAccountScope).Post("/passkeys/register/begin", s.handleAccountPasskeysRegisterBeginPost())
+		r.With(s.jwtSessionToContext).With(s.requiresAccountScope).Post("/passkeys/register/finish", s.handleAccountPasskeysRegisterFinishPost())
+		r.With(s.jwtSessionToContext).With(s.requiresAccountScope).Post("/passkeys/delete", s.handleAccountPasskeysDeletePost())
 		r.With(s.jwtSessionToContext).With(s.requiresAccountScope).Get("/manage-consents", s.handleAccountManageConsentsGet())
 		r.With(s.jwtSessionToContext).With(s.requiresAccountScope).Post("/manage-consents", s.handleAccountManageConsentsRevokePost())
 		r.With(s.jwtSessionToContext).With(s.requiresAccountScope).Get("/sessions", s.handleAccountSessionsGet())
 		r.With(s.jwtSessionToContext).With(s.requiresAccountScope).Post("/sessions", s.handleAccountSessionsEndSesssionPost())
 		r.Get("/register", s.handleAccountRegisterGet())
 		r.Post("/register", s.handleAccountRegisterPost(userCreator, emailValidator, passwordValidator, emailSender))
-		r.Post("/login", s.handleAuthPwdPost(authorizeValidator, loginManager))
+		r.Post("/login", s.handleAuthPwdPost(authorizeValidator, loginManager, emailSender))
 		r.Get("/activate", s.handleAccountActivateGet(userCreator, emailSender))
 	})
 
@@ -111,8 +136,12 @@ func (s *Server) initRoutes() {
 		r.Post("/clients/{clientId}/oauth2-flows", s.handleAdminClientOAuth2Post())
 		r.Get("/clients/{clientId}/redirect-uris", s.handleAdminClientRedirectURIsGet())
 		r.Post("/clients/{clientId}/redirect-uris", s.handleAdminClientRedirectURIsPost())
+		r.Get("/clients/{clientId}/post-logout-redirect-uris", s.handleAdminClientPostLogoutRedirectURIsGet())
+		r.Post("/clients/{clientId}/post-logout-redirect-uris", s.handleAdminClientPostLogoutRedirectURIsPost())
 		r.Get("/clients/{clientId}/web-origins", s.handleAdminClientWebOriginsGet())
 		r.Post("/clients/{clientId}/web-origins", s.handleAdminClientWebOriginsPost())
+		r.Get("/clients/{clientId}/logout", s.handleAdminClientLogoutGet())
+		r.Post("/clients/{clientId}/logout", s.handleAdminClientLogoutPost())
 		r.Get("/clients/{clientId}/user-sessions", s.handleAdminClientUserSessionsGet())
 		r.Post("/clients/{clientId}/user-sessions/delete", s.handleAdminClientUserSessionsPost())
 		r.Get("/clients/{clientId}/permissions", s.handleAdminClientPermissionsGet())
@@ -195,15 +224,21 @@ func (s *Server) initRoutes() {
 		r.Get("/users/new", s.handleAdminUserNewGet())
 		r.Post("/users/new", s.handleAdminUserNewPost(userCreator, profileValidator, emailValidator, passwordValidator, inputSanitizer, emailSender))
 
+		r.Get("/audit", s.handleAdminAuditGet())
+		r.Get("/audit/export", s.handleAdminAuditExportGet())
+
 		r.Get("/settings/general", s.handleAdminSettingsGeneralGet())
 		r.Post("/settings/general", s.handleAdminSettingsGeneralPost(inputSanitizer))
 		r.Get("/settings/ui-theme", s.handleAdminSettingsUIThemeGet())
 		r.Post("/settings/ui-theme", s.handleAdminSettingsUIThemePost())
 		r.Get("/settings/sessions", s.handleAdminSettingsSessionsGet())
 		r.Post("/settings/sessions", s.handleAdminSettingsSessionsPost())
+		r.Get("/settings/security", s.handleAdminSettingsSecurityGet())
+		r.Post("/settings/security", s.handleAdminSettingsSecurityPost())
 		r.Get("/settings/tokens", s.handleAdminSettingsTokensGet())
 		r.Post("/settings/tokens", s.handleAdminSettingsTokensPost())
 		r.Get("/settings/keys", s.handleAdminSettingsKeysGet())
+		r.Post("/settings/keys", s.handleAdminSettingsKeysPost())
 		r.Post("/settings/keys/rotate", s.handleAdminSettingsKeysRotatePost())
 		r.Post("/settings/keys/revoke", s.handleAdminSettingsKeysRevokePost())
 		r.Get("/settings/email", s.handleAdminSettingsEmailGet())
@@ -212,6 +247,8 @@ func (s *Server) initRoutes() {
 		r.Post("/settings/email/send-test-email", s.handleAdminSettingsEmailSendTestPost(emailValidator, emailSender))
 		r.Get("/settings/sms", s.handleAdminSettingsSMSGet())
 		r.Post("/settings/sms", s.handleAdminSettingsSMSPost(inputSanitizer))
+		r.Get("/settings/client-registration", s.handleAdminSettingsClientRegistrationGet())
+		r.Post("/settings/client-registration", s.handleAdminSettingsClientRegistrationPost())
 	})
 }
 
@@ -220,7 +257,7 @@ func (s *Server) jwtSessionToContext(handler http.Handler) http.Handler {
 }
 
 func (s *Server) jwtAuthorizationHeaderToContext(handler http.Handler) http.Handler {
-	return MiddlewareJwtAuthorizationHeaderToContext(handler, s.sessionStore, s.tokenParser)
+	return MiddlewareJwtAuthorizationHeaderToContext(handler, s.sessionStore, s.tokenParser, s.dpopValidator)
 }
 
 func (s *Server) requiresAdminScope(handler http.Handler) http.Handler {
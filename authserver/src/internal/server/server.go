@@ -1,6 +1,8 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io/fs"
 	"log"
@@ -17,6 +19,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	core_token "github.com/leodip/goiabada/internal/core/token"
+	core_validators "github.com/leodip/goiabada/internal/core/validators"
 	"github.com/leodip/goiabada/internal/data"
 	"github.com/leodip/goiabada/internal/entities"
 	"github.com/leodip/goiabada/internal/lib"
@@ -25,10 +28,14 @@ import (
 )
 
 type Server struct {
-	router       *chi.Mux
-	database     data.Database
-	sessionStore sessions.Store
-	tokenParser  *core_token.TokenParser
+	router        *chi.Mux
+	database      data.Database
+	sessionStore  sessions.Store
+	tokenParser   *core_token.TokenParser
+	keyRotator    *core_token.KeyRotator
+	dpopValidator *core_validators.DPoPValidator
+
+	backchannelLogoutNotifier *core_token.BackchannelLogoutNotifier
 
 	staticFS   fs.FS
 	templateFS fs.FS
@@ -42,6 +49,10 @@ func NewServer(router *chi.Mux, database data.Database, sessionStore sessions.St
 		sessionStore: sessionStore,
 		tokenParser:  core_token.NewTokenParser(database),
 	}
+	s.keyRotator = core_token.NewKeyRotator(database, s.tokenParser)
+	s.dpopValidator = core_validators.NewDPoPValidator(database)
+	s.backchannelLogoutNotifier = core_token.NewBackchannelLogoutNotifier(database,
+		core_token.NewTokenIssuer(database, s.tokenParser))
 
 	if envVar := viper.GetString("StaticDir"); len(envVar) == 0 {
 		s.staticFS = web.StaticFS()
@@ -62,6 +73,50 @@ func NewServer(router *chi.Mux, database data.Database, sessionStore sessions.St
 	return &s
 }
 
+// RunKeyRotationScheduler periodically checks the automatic key rotation schedule, rotating
+// the signing keys and revoking the previous key when they're due
+func (s *Server) RunKeyRotationScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		settings, err := s.database.GetSettingsById(nil, 1)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("unable to load settings for key rotation: %+v", err))
+		} else {
+			err = s.keyRotator.RunScheduledRotation(context.Background(), settings, time.Now().UTC())
+			if err != nil {
+				slog.Warn(fmt.Sprintf("unable to run the scheduled key rotation: %+v", err))
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// RunBackchannelLogoutWorker delivers the queued back-channel logout notifications. It runs
+// on every tick, to retry failed deliveries, and whenever new notifications are queued
+func (s *Server) RunBackchannelLogoutWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		settings, err := s.database.GetSettingsById(nil, 1)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("unable to load settings for backchannel logout: %+v", err))
+		} else {
+			err = s.backchannelLogoutNotifier.DeliverPending(settings, time.Now().UTC())
+			if err != nil {
+				slog.Warn(fmt.Sprintf("unable to deliver the backchannel logout notifications: %+v", err))
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.backchannelLogoutNotifier.Pending():
+		}
+	}
+}
+
 func (s *Server) Start(settings *entities.Settings) {
 	s.initMiddleware(settings)
 
@@ -97,8 +152,20 @@ func (s *Server) Start(settings *entities.Settings) {
 		if !strings.HasPrefix(lib.GetBaseUrl(), "https://") {
 			slog.Warn(fmt.Sprintf("https is enabled but the base url '%v' is not using https. Please review your configuration.", lib.GetBaseUrl()))
 		}
+		httpServer := &http.Server{
+			Addr:    fmt.Sprintf("%v:%v", host, port),
+			Handler: s.router,
+		}
+		if lib.IsMTLSEnabled() {
+			// the client certificate is verified when the client authenticates, since
+			// self-signed certificates are accepted too (RFC 8705)
+			httpServer.TLSConfig = &tls.Config{
+				ClientAuth: tls.RequestClientCert,
+			}
+			slog.Info("mutual TLS enabled, client certificates are requested")
+		}
 		slog.Info(fmt.Sprintf("listening on host:port %v:%v (https)", host, port))
-		log.Fatal(http.ListenAndServeTLS(fmt.Sprintf("%v:%v", host, port), certFile, keyFile, s.router))
+		log.Fatal(httpServer.ListenAndServeTLS(certFile, keyFile))
 	} else {
 		// non-TLS mode
 		if !strings.HasPrefix(settings.Issuer, "http://") {
@@ -135,6 +202,9 @@ func (s *Server) initMiddleware(settings *entities.Settings) {
 	// Recoverer
 	s.router.Use(middleware.Recoverer)
 
+	// Adds the request details used by the audit log to the request context
+	s.router.Use(MiddlewareAuditRequestInfo())
+
 	// HTTP request logging
 	httpRequestLoggingEnabled := viper.GetBool("Logger.Router.HttpRequests.Enabled")
 	if httpRequestLoggingEnabled {
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/leodip/goiabada/internal/common"
+	"github.com/leodip/goiabada/internal/entities"
+	"github.com/leodip/goiabada/internal/lib"
+)
+
+const webAuthnTimeoutInMilliseconds = 120000
+
+type webAuthnCredentialDescriptor struct {
+	Type       string   `json:"type"`
+	Id         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+func (s *Server) getWebAuthnRelyingParty(r *http.Request) (*lib.WebAuthnRelyingParty, error) {
+	settings := r.Context().Value(common.ContextKeySettings).(*entities.Settings)
+	return lib.NewWebAuthnRelyingParty(lib.GetBaseUrl(), settings.AppName)
+}
+
+// newWebAuthnChallenge generates a challenge and keeps it in the session until the ceremony is finished
+func (s *Server) newWebAuthnChallenge(w http.ResponseWriter, r *http.Request) (string, error) {
+	challenge, err := lib.GenerateWebAuthnChallenge()
+	if err != nil {
+		return "", err
+	}
+
+	sess, err := s.sessionStore.Get(r, common.SessionName)
+	if err != nil {
+		return "", err
+	}
+	sess.Values[common.SessionKeyWebAuthnChallenge] = challenge
+	err = sess.Save(r, w)
+	if err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+// takeWebAuthnChallenge returns the pending challenge and removes it from the session, so
+// that each challenge can be used only once
+func (s *Server) takeWebAuthnChallenge(w http.ResponseWriter, r *http.Request) (string, error) {
+	sess, err := s.sessionStore.Get(r, common.SessionName)
+	if err != nil {
+		return "", err
+	}
+
+	challenge := ""
+	if val, ok := sess.Values[common.SessionKeyWebAuthnChallenge]; ok {
+		challenge = val.(string)
+	}
+	delete(sess.Values, common.SessionKeyWebAuthnChallenge)
+	err = sess.Save(r, w)
+	if err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+func getWebAuthnCredentialDescriptors(webAuthnCredentials []entities.WebAuthnCredential) []webAuthnCredentialDescriptor {
+	descriptors := make([]webAuthnCredentialDescriptor, 0, len(webAuthnCredentials))
+	for _, c := range webAuthnCredentials {
+		descriptor := webAuthnCredentialDescriptor{
+			Type: "public-key",
+			Id:   c.CredentialId,
+		}
+		if len(c.Transports) > 0 {
+			descriptor.Transports = strings.Fields(c.Transports)
+		}
+		descriptors = append(descriptors, descriptor)
+	}
+	return descriptors
+}
+
+// getWebAuthnUserHandle returns the user handle given to authenticators when a passkey is
+// registered. It is the subject of the user, which is opaque and never changes
+func getWebAuthnUserHandle(user *entities.User) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(user.Subject.String()))
+}
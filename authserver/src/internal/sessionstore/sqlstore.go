@@ -289,7 +289,7 @@ func (store *SQLStore) cleanup(interval time.Duration, quit <-chan struct{}, don
 			// Delete expired sessions on each tick.
 			err := store.deleteExpired()
 			if err != nil {
-				slog.Warn("SQLStore: unable to delete expired sessions: %v", err)
+				slog.Warn(fmt.Sprintf("SQLStore: unable to delete expired sessions: %v", err))
 			}
 		}
 	}
@@ -0,0 +1,111 @@
+// webauthn.js
+
+function base64UrlToBuffer(value) {
+  const base64 = value.replace(/-/g, "+").replace(/_/g, "/");
+  const padded = base64 + "===".slice((base64.length + 3) % 4);
+  const binary = atob(padded);
+  const bytes = new Uint8Array(binary.length);
+  for (let i = 0; i < binary.length; i++) {
+    bytes[i] = binary.charCodeAt(i);
+  }
+  return bytes.buffer;
+}
+
+function bufferToBase64Url(buffer) {
+  const bytes = new Uint8Array(buffer);
+  let binary = "";
+  for (let i = 0; i < bytes.length; i++) {
+    binary += String.fromCharCode(bytes[i]);
+  }
+  return btoa(binary).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+function isWebAuthnSupported() {
+  return window.PublicKeyCredential !== undefined && navigator.credentials !== undefined;
+}
+
+// startPasskeyRegistration asks the server for the creation options, creates the credential
+// with the authenticator and sends it back to the server
+function startPasskeyRegistration(props) {
+  sendAjaxRequest({
+    "url": props.beginUrl,
+    "method": "POST",
+    "bodyData": JSON.stringify({}),
+    "loadingElement": props.loadingElement,
+    "loadingClasses": props.loadingClasses,
+    "modalId": props.modalId,
+    "callback": function (options) {
+      options.challenge = base64UrlToBuffer(options.challenge);
+      options.user.id = base64UrlToBuffer(options.user.id);
+      (options.excludeCredentials || []).forEach(function (c) {
+        c.id = base64UrlToBuffer(c.id);
+      });
+
+      navigator.credentials.create({ "publicKey": options })
+        .then(function (credential) {
+          const transports = credential.response.getTransports ? credential.response.getTransports() : [];
+          sendAjaxRequest({
+            "url": props.finishUrl,
+            "method": "POST",
+            "bodyData": JSON.stringify({
+              "name": props.name,
+              "credential": {
+                "id": credential.id,
+                "clientDataJSON": bufferToBase64Url(credential.response.clientDataJSON),
+                "attestationObject": bufferToBase64Url(credential.response.attestationObject),
+                "transports": transports
+              }
+            }),
+            "loadingElement": props.loadingElement,
+            "loadingClasses": props.loadingClasses,
+            "modalId": props.modalId,
+            "callback": props.callback
+          });
+        })
+        .catch(function (err) {
+          showModalDialog(props.modalId, "Passkey", "The passkey was not created: <span class='text-error'>" + err.message + "</span>");
+        });
+    }
+  });
+}
+
+// startPasskeyAuthentication asks the server for the request options, signs the challenge
+// with the authenticator and sends the assertion back to the server
+function startPasskeyAuthentication(props) {
+  sendAjaxRequest({
+    "url": props.beginUrl,
+    "method": "POST",
+    "bodyData": JSON.stringify({}),
+    "loadingElement": props.loadingElement,
+    "loadingClasses": props.loadingClasses,
+    "modalId": props.modalId,
+    "callback": function (options) {
+      options.challenge = base64UrlToBuffer(options.challenge);
+      (options.allowCredentials || []).forEach(function (c) {
+        c.id = base64UrlToBuffer(c.id);
+      });
+
+      navigator.credentials.get({ "publicKey": options })
+        .then(function (assertion) {
+          sendAjaxRequest({
+            "url": props.finishUrl,
+            "method": "POST",
+            "bodyData": JSON.stringify({
+              "id": assertion.id,
+              "clientDataJSON": bufferToBase64Url(assertion.response.clientDataJSON),
+              "authenticatorData": bufferToBase64Url(assertion.response.authenticatorData),
+              "signature": bufferToBase64Url(assertion.response.signature),
+              "userHandle": assertion.response.userHandle ? bufferToBase64Url(assertion.response.userHandle) : ""
+            }),
+            "loadingElement": props.loadingElement,
+            "loadingClasses": props.loadingClasses,
+            "modalId": props.modalId,
+            "callback": props.callback
+          });
+        })
+        .catch(function (err) {
+          showModalDialog(props.modalId, "Passkey", "Authentication with the passkey was not completed: <span class='text-error'>" + err.message + "</span>");
+        });
+    }
+  });
+}